@@ -78,12 +78,13 @@ func runControllerManager(stopCh context.Context) (err error) {
 
 		// make sure LeaderElection is not nil
 		s = &controllerOpt.DevOpsControllerManagerOptions{
-			KubernetesOptions: conf.KubernetesOptions,
-			JenkinsOptions:    conf.JenkinsOptions,
-			S3Options:         conf.S3Options,
-			LeaderElection:    s.LeaderElection,
-			LeaderElect:       s.LeaderElect,
-			WebhookCertDir:    s.WebhookCertDir,
+			KubernetesOptions:           conf.KubernetesOptions,
+			JenkinsOptions:              conf.JenkinsOptions,
+			S3Options:                   conf.S3Options,
+			PipelineRunDataStoreOptions: conf.PipelineRunDataStoreOptions,
+			LeaderElection:              s.LeaderElection,
+			LeaderElect:                 s.LeaderElect,
+			WebhookCertDir:              s.WebhookCertDir,
 		}
 	} else {
 		klog.Fatal("Failed to load configuration from disk", err)
@@ -17,9 +17,12 @@ limitations under the License.
 package app
 
 import (
+	"context"
+
 	"github.com/kubesphere/ks-devops/controllers/addon"
 	"github.com/kubesphere/ks-devops/controllers/argocd"
 	"github.com/kubesphere/ks-devops/controllers/fluxcd"
+	"github.com/kubesphere/ks-devops/controllers/gitops"
 	"github.com/kubesphere/ks-devops/controllers/gitrepository"
 	"github.com/kubesphere/ks-devops/controllers/jenkins/devopscredential"
 	"github.com/kubesphere/ks-devops/controllers/jenkins/devopsproject"
@@ -32,7 +35,12 @@ import (
 	"github.com/kubesphere/ks-devops/controllers/jenkins/pipelinerun"
 	"github.com/kubesphere/ks-devops/pkg/client/devops"
 	"github.com/kubesphere/ks-devops/pkg/client/k8s"
+	"github.com/kubesphere/ks-devops/pkg/client/s3"
+	pkgconfig "github.com/kubesphere/ks-devops/pkg/config"
 	"github.com/kubesphere/ks-devops/pkg/informers"
+	devopsgitops "github.com/kubesphere/ks-devops/pkg/kapis/devops/v1alpha3/gitops"
+	"github.com/kubesphere/ks-devops/pkg/store/provider"
+	"github.com/kubesphere/ks-devops/pkg/store/store"
 	"k8s.io/klog/v2"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 )
@@ -46,6 +54,12 @@ func addControllers(mgr manager.Manager, client k8s.Client, informerFactory info
 
 	reconcilers := getAllControllers(mgr, client, informerFactory, devopsClient, s, jenkinsCore)
 	reconcilers["pipeline"] = func(mgr manager.Manager) (err error) {
+		var dataStore store.Provider
+		if dataStore, err = newPipelineRunDataStore(mgr, s); err != nil {
+			klog.Errorf("unable to create the PipelineRun data store, err: %v", err)
+			return
+		}
+
 		// add PipelineRun controller
 		if err = (&pipelinerun.Reconciler{
 			Client:               mgr.GetClient(),
@@ -53,6 +67,9 @@ func addControllers(mgr manager.Manager, client k8s.Client, informerFactory info
 			DevOpsClient:         devopsClient,
 			JenkinsCore:          jenkinsCore,
 			PipelineRunDataStore: s.FeatureOptions.PipelineRunDataStore,
+			DataStore:            dataStore,
+			ResyncPeriod:         s.FeatureOptions.PipelineRunResyncPeriod,
+			PodServiceAccount:    s.FeatureOptions.PipelineRunPodServiceAccount,
 			Options:              s.JenkinsOptions,
 		}).SetupWithManager(mgr); err != nil {
 			klog.Errorf("unable to create pipelinerun-controller, err: %v", err)
@@ -68,6 +85,46 @@ func addControllers(mgr manager.Manager, client k8s.Client, informerFactory info
 			return
 		}
 
+		// add PipelineRun retention controller
+		if err = (&pipelinerun.RetentionReconciler{
+			Client: mgr.GetClient(),
+		}).SetupWithManager(mgr); err != nil {
+			klog.Errorf("unable to create pipelinerun-retention, err: %v", err)
+			return
+		}
+
+		// add PipelineRun matrix controller
+		if err = (&pipelinerun.MatrixReconciler{
+			Client: mgr.GetClient(),
+		}).SetupWithManager(mgr); err != nil {
+			klog.Errorf("unable to create pipelinerun-matrix, err: %v", err)
+			return
+		}
+
+		// add Pipeline upstream trigger controller
+		if err = (&pipelinerun.UpstreamTriggerReconciler{
+			Client: mgr.GetClient(),
+		}).SetupWithManager(mgr); err != nil {
+			klog.Errorf("unable to create pipeline-upstream-trigger, err: %v", err)
+			return
+		}
+
+		// add Pipeline schedule controller
+		if err = (&pipelinerun.ScheduleReconciler{
+			Client: mgr.GetClient(),
+		}).SetupWithManager(mgr); err != nil {
+			klog.Errorf("unable to create pipeline-schedule, err: %v", err)
+			return
+		}
+
+		// add Pipeline revision controller
+		if err = (&pipelinerun.RevisionReconciler{
+			Client: mgr.GetClient(),
+		}).SetupWithManager(mgr); err != nil {
+			klog.Errorf("unable to create pipeline-revision, err: %v", err)
+			return
+		}
+
 		// add Pipeline metadata controller
 		err = (&jenkinspipeline.Reconciler{
 			Client:      mgr.GetClient(),
@@ -92,6 +149,41 @@ func addControllers(mgr manager.Manager, client k8s.Client, informerFactory info
 	return nil
 }
 
+// newPipelineRunDataStore creates the PipelineRun data store provider, the existing data in ConfigMaps will be moved
+// into the s3 or fs data store in the background if the migration is enabled
+func newPipelineRunDataStore(mgr manager.Manager, s *options.DevOpsControllerManagerOptions) (
+	dataStore store.Provider, err error) {
+	storeOptions := pkgconfig.NewPipelineRunDataStoreOptions()
+	if s.PipelineRunDataStoreOptions != nil {
+		*storeOptions = *s.PipelineRunDataStoreOptions
+	}
+	storeOptions.Type = s.FeatureOptions.PipelineRunDataStore
+
+	var s3Client s3.Interface
+	if storeOptions.Type == pkgconfig.PipelineRunDataStoreS3 && s.S3Options != nil && s.S3Options.Endpoint != "" {
+		if s3Client, err = s3.NewS3Client(s.S3Options); err != nil {
+			return
+		}
+	}
+	if dataStore, err = provider.NewProvider(storeOptions, mgr.GetClient(), s3Client); err != nil {
+		return
+	}
+
+	if storeOptions.Migrate && (storeOptions.Type == pkgconfig.PipelineRunDataStoreS3 ||
+		storeOptions.Type == pkgconfig.PipelineRunDataStoreFileSystem) {
+		err = mgr.Add(manager.RunnableFunc(func(ctx context.Context) error {
+			// the API reader avoids caching all the ConfigMaps
+			if count, err := provider.Migrate(ctx, mgr.GetAPIReader(), mgr.GetClient(), dataStore); err != nil {
+				klog.Errorf("failed to move the PipelineRun data from ConfigMaps, err: %v", err)
+			} else {
+				klog.Infof("moved the data of %d PipelineRuns from ConfigMaps", count)
+			}
+			return nil
+		}))
+	}
+	return
+}
+
 func getAllControllers(mgr manager.Manager, client k8s.Client, informerFactory informers.InformerFactory,
 	devopsClient devops.Interface, s *options.DevOpsControllerManagerOptions, jenkinsCore core.JenkinsCore) map[string]func(mgr manager.Manager) error {
 
@@ -138,6 +230,16 @@ func getAllControllers(mgr manager.Manager, client k8s.Client, informerFactory i
 	fluxcdAppStatusReconciler := &fluxcd.ApplicationStatusReconciler{
 		Client: mgr.GetClient(),
 	}
+	gitRepoFactory := devopsgitops.NewGitRepoFactory(mgr.GetClient(), pkgconfig.NewGitOpsOptions())
+	promotionReconciler := &gitops.PromotionReconciler{
+		Client:      mgr.GetClient(),
+		RepoFactory: gitRepoFactory,
+	}
+	applicationGeneratorReconciler := &gitops.ApplicationGeneratorReconciler{
+		Client:        mgr.GetClient(),
+		RepoFactory:   gitRepoFactory,
+		ArgoNamespace: s.ArgoCDOption.Namespace,
+	}
 
 	return map[string]func(mgr manager.Manager) error{
 		gitRepoReconcilers.GetName(): func(mgr manager.Manager) error {
@@ -149,6 +251,11 @@ func getAllControllers(mgr manager.Manager, client k8s.Client, informerFactory i
 			if err != nil {
 				return err
 			}
+			if err = (&gitrepository.PipelineSourceReconciler{
+				Client: mgr.GetClient(),
+			}).SetupWithManager(mgr); err != nil {
+				return err
+			}
 			return gitRepoReconcilers.SetupWithManager(mgr)
 		},
 		"addon": func(mgr manager.Manager) error {
@@ -239,5 +346,11 @@ func getAllControllers(mgr manager.Manager, client k8s.Client, informerFactory i
 			}
 			return fluxcdApplicationReconciler.SetupWithManager(mgr)
 		},
+		promotionReconciler.GetGroupName() + "-promotion": func(mgr manager.Manager) error {
+			return promotionReconciler.SetupWithManager(mgr)
+		},
+		applicationGeneratorReconciler.GetGroupName() + "-application-generator": func(mgr manager.Manager) error {
+			return applicationGeneratorReconciler.SetupWithManager(mgr)
+		},
 	}
 }
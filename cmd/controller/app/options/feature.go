@@ -18,7 +18,9 @@ package options
 
 import (
 	"strings"
+	"time"
 
+	"github.com/kubesphere/ks-devops/pkg/config"
 	"github.com/kubesphere/ks-devops/pkg/utils/reflectutils"
 	"github.com/spf13/pflag"
 	cliflag "k8s.io/component-base/cli/flag"
@@ -31,6 +33,11 @@ type FeatureOptions struct {
 	ExternalAddress      string
 	ClusterName          string
 	PipelineRunDataStore string
+	// PipelineRunResyncPeriod is the interval of retrieving the running data of the PipelineRuns which receive
+	// Jenkins events
+	PipelineRunResyncPeriod time.Duration
+	// PipelineRunPodServiceAccount is the ServiceAccount of the step Pods created by the kubernetes executor
+	PipelineRunPodServiceAccount string
 }
 
 // GetControllers returns the controllers map
@@ -61,7 +68,10 @@ func (o *FeatureOptions) GetControllers() map[string]bool {
 
 // NewFeatureOptions provide default options
 func NewFeatureOptions() *FeatureOptions {
-	return &FeatureOptions{}
+	return &FeatureOptions{
+		PipelineRunDataStore:    config.PipelineRunDataStoreConfigMap,
+		PipelineRunResyncPeriod: time.Minute,
+	}
 }
 
 // Validate checks validation of FeatureOptions.
@@ -82,8 +92,15 @@ func (o *FeatureOptions) AddFlags(fs *pflag.FlagSet, c *FeatureOptions) {
 		"The system namespace that contains ConfigMap, Secrets e.g.")
 	fs.StringVarP(&o.ExternalAddress, "external-address", "", "", "The external address for the UI")
 	fs.StringVarP(&o.ClusterName, "cluster-name", "", "default", "Current cluster name")
-	fs.StringVarP(&o.PipelineRunDataStore, "pipelinerun-data-store", "", "configmap",
-		"The data store type of the PipelineRun data, could be empty or configmap")
+	fs.StringVarP(&o.PipelineRunDataStore, "pipelinerun-data-store", "", c.PipelineRunDataStore,
+		"The data store type of the PipelineRun data, could be empty, configmap, s3 or fs. "+
+			"It overrides the type of pipelineRunDataStore in the configuration file")
+	fs.DurationVarP(&o.PipelineRunResyncPeriod, "pipelinerun-resync-period", "", c.PipelineRunResyncPeriod,
+		"The interval of retrieving the running data of the PipelineRuns which receive Jenkins events. "+
+			"The PipelineRuns without Jenkins events are still polled every 3 seconds")
+	fs.StringVarP(&o.PipelineRunPodServiceAccount, "pipelinerun-pod-service-account", "", c.PipelineRunPodServiceAccount,
+		"The ServiceAccount of the step Pods created by the kubernetes executor, it must exist in the namespaces of the "+
+			"PipelineRuns. The default ServiceAccount of the namespace is used if it's empty")
 }
 
 func (o *FeatureOptions) knownControllers() []string {
@@ -19,6 +19,7 @@ package options
 import (
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/spf13/pflag"
 	"github.com/stretchr/testify/assert"
@@ -98,6 +99,7 @@ func TestFeatureOptions_GetControllers(t *testing.T) {
 func TestFeatureOptions(t *testing.T) {
 	opt := NewFeatureOptions()
 	assert.NotNil(t, opt)
+	assert.Equal(t, time.Minute, opt.PipelineRunResyncPeriod)
 	assert.Equal(t, []error{}, opt.Validate())
 
 	opt.Controllers = map[string]bool{
@@ -44,6 +44,8 @@ type DevOpsControllerManagerOptions struct {
 	S3Options         *s3.Options
 	FeatureOptions    *FeatureOptions
 	ArgoCDOption      *config.ArgoCDOption
+	// PipelineRunDataStoreOptions is the storage of PipelineRun data, the type is overridden by the feature options
+	PipelineRunDataStoreOptions *config.PipelineRunDataStoreOptions
 
 	// KubeSphere is using sigs.k8s.io/application as fundamental object to implement Application Management.
 	// There are other projects also built on sigs.k8s.io/application, when KubeSphere installed along side
@@ -69,6 +71,8 @@ func NewDevOpsControllerManagerOptions() *DevOpsControllerManagerOptions {
 		ApplicationSelector: "",
 		KubernetesOptions:   &k8s.KubernetesOptions{},
 		ArgoCDOption:        &config.ArgoCDOption{},
+
+		PipelineRunDataStoreOptions: config.NewPipelineRunDataStoreOptions(),
 	}
 
 	return s
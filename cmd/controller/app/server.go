@@ -54,17 +54,23 @@ func NewControllerManagerCommand() *cobra.Command {
 		if conf.ArgoCDOption == nil {
 			conf.ArgoCDOption = &config.ArgoCDOption{}
 		}
+		if conf.PipelineRunDataStoreOptions == nil {
+			conf.PipelineRunDataStoreOptions = config.NewPipelineRunDataStoreOptions()
+		}
+		// the type of data store could be overridden by the command line flag
+		s.FeatureOptions.PipelineRunDataStore = conf.PipelineRunDataStoreOptions.Type
 		// make sure LeaderElection is not nil
 		// override devops controller manager options
 		s = &options.DevOpsControllerManagerOptions{
-			KubernetesOptions: conf.KubernetesOptions,
-			JenkinsOptions:    conf.JenkinsOptions,
-			S3Options:         conf.S3Options,
-			ArgoCDOption:      conf.ArgoCDOption,
-			FeatureOptions:    s.FeatureOptions,
-			LeaderElection:    s.LeaderElection,
-			LeaderElect:       s.LeaderElect,
-			WebhookCertDir:    s.WebhookCertDir,
+			KubernetesOptions:           conf.KubernetesOptions,
+			JenkinsOptions:              conf.JenkinsOptions,
+			S3Options:                   conf.S3Options,
+			PipelineRunDataStoreOptions: conf.PipelineRunDataStoreOptions,
+			ArgoCDOption:                conf.ArgoCDOption,
+			FeatureOptions:              s.FeatureOptions,
+			LeaderElection:              s.LeaderElection,
+			LeaderElect:                 s.LeaderElect,
+			WebhookCertDir:              s.WebhookCertDir,
 		}
 	} else {
 		klog.Fatal("Failed to load configuration from disk", err)
@@ -195,6 +201,9 @@ func Run(s *options.DevOpsControllerManagerOptions, ctx context.Context) error {
 	if err = indexers.CreatePipelineRunSCMRefNameIndexer(mgr.GetCache()); err != nil {
 		return err
 	}
+	if err = indexers.CreatePipelineUpstreamIndexer(mgr.GetCache()); err != nil {
+		return err
+	}
 
 	// Start cache data after all informer is registered
 	klog.V(0).Info("Starting cache resource from apiserver...")
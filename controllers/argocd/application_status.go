@@ -0,0 +1,132 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package argocd
+
+import (
+	"encoding/json"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/kubesphere/ks-devops/pkg/api/gitops/v1alpha1"
+)
+
+// argoApplicationStatus contains the fields of the Argo CD Application status for the engine-neutral status
+type argoApplicationStatus struct {
+	Sync struct {
+		Status   string `json:"status"`
+		Revision string `json:"revision"`
+	} `json:"sync"`
+	Health         argoHealthStatus `json:"health"`
+	OperationState *struct {
+		Phase      string       `json:"phase"`
+		Message    string       `json:"message"`
+		StartedAt  *metav1.Time `json:"startedAt"`
+		FinishedAt *metav1.Time `json:"finishedAt"`
+		SyncResult *struct {
+			Revision string `json:"revision"`
+		} `json:"syncResult"`
+	} `json:"operationState"`
+	Resources []struct {
+		Group     string            `json:"group"`
+		Version   string            `json:"version"`
+		Kind      string            `json:"kind"`
+		Namespace string            `json:"namespace"`
+		Name      string            `json:"name"`
+		Status    string            `json:"status"`
+		Health    *argoHealthStatus `json:"health"`
+	} `json:"resources"`
+	History []struct {
+		ID         int64                       `json:"id"`
+		Revision   string                      `json:"revision"`
+		DeployedAt metav1.Time                 `json:"deployedAt"`
+		Source     *v1alpha1.ApplicationSource `json:"source"`
+	} `json:"history"`
+}
+
+type argoHealthStatus struct {
+	Status  string `json:"status"`
+	Message string `json:"message"`
+}
+
+func (h *argoHealthStatus) toHealthStatus() *v1alpha1.HealthStatus {
+	if h == nil || h.Status == "" {
+		return nil
+	}
+	return &v1alpha1.HealthStatus{Status: v1alpha1.HealthStatusCode(h.Status), Message: h.Message}
+}
+
+// setApplicationStatus fills the engine-neutral status of an Application with the status of the Argo CD Application
+func setApplicationStatus(data []byte, status *v1alpha1.ApplicationStatus) (err error) {
+	argoStatus := &argoApplicationStatus{}
+	if err = json.Unmarshal(data, argoStatus); err != nil {
+		return
+	}
+
+	status.Sync = v1alpha1.SyncStatusCode(argoStatus.Sync.Status)
+	if status.Sync == "" {
+		status.Sync = v1alpha1.SyncStatusUnknown
+	}
+	status.Health = argoStatus.Health.toHealthStatus()
+	status.Revision = argoStatus.Sync.Revision
+
+	status.OperationState = nil
+	if op := argoStatus.OperationState; op != nil {
+		status.OperationState = &v1alpha1.OperationState{
+			Phase:      v1alpha1.OperationPhase(op.Phase),
+			Message:    op.Message,
+			StartedAt:  op.StartedAt,
+			FinishedAt: op.FinishedAt,
+		}
+		if op.SyncResult != nil {
+			status.OperationState.Revision = op.SyncResult.Revision
+		}
+	}
+
+	status.Resources = nil
+	for _, resource := range argoStatus.Resources {
+		status.Resources = append(status.Resources, v1alpha1.ResourceStatus{
+			Group:     resource.Group,
+			Version:   resource.Version,
+			Kind:      resource.Kind,
+			Namespace: resource.Namespace,
+			Name:      resource.Name,
+			Status:    v1alpha1.SyncStatusCode(resource.Status),
+			Health:    resource.Health.toHealthStatus(),
+		})
+	}
+
+	// the history only has the successful syncs
+	status.History = nil
+	for _, history := range argoStatus.History {
+		status.History = append(status.History, v1alpha1.RevisionHistory{
+			ID:         history.ID,
+			Revision:   history.Revision,
+			DeployedAt: history.DeployedAt,
+			Source:     history.Source,
+		})
+	}
+	if count := len(argoStatus.History); count > 0 {
+		status.LastSyncTime = argoStatus.History[count-1].DeployedAt.DeepCopy()
+		if status.Revision == "" {
+			status.Revision = argoStatus.History[count-1].Revision
+		}
+	} else if op := status.OperationState; op != nil && op.Phase == v1alpha1.OperationSucceeded && op.FinishedAt != nil {
+		status.LastSyncTime = op.FinishedAt.DeepCopy()
+	}
+	status.UpdateConditions()
+	return
+}
@@ -0,0 +1,112 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package argocd
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/kubesphere/ks-devops/pkg/api/gitops/v1alpha1"
+)
+
+func Test_setApplicationStatus(t *testing.T) {
+	parseTime := func(value string) *metav1.Time {
+		parsed, err := time.Parse(time.RFC3339, value)
+		assert.Nil(t, err)
+		return &metav1.Time{Time: parsed.Local()}
+	}
+
+	t.Run("full", func(t *testing.T) {
+		data, err := os.ReadFile("data/argo-status-full.json")
+		assert.Nil(t, err)
+		status := &v1alpha1.ApplicationStatus{}
+		assert.Nil(t, setApplicationStatus(data, status))
+
+		assert.Equal(t, v1alpha1.SyncStatusOutOfSync, status.Sync)
+		assert.Equal(t, &v1alpha1.HealthStatus{Status: v1alpha1.HealthStatusProgressing, Message: "Waiting for rollout to finish"}, status.Health)
+		assert.Equal(t, "1d4fcf1f56f5dbe2ae65abb001ffc304f1d58070", status.Revision)
+		assert.Equal(t, &v1alpha1.OperationState{
+			Phase:      v1alpha1.OperationSucceeded,
+			Message:    "successfully synced (all tasks run)",
+			Revision:   "1d4fcf1f56f5dbe2ae65abb001ffc304f1d58070",
+			StartedAt:  parseTime("2022-06-30T06:48:01Z"),
+			FinishedAt: parseTime("2022-06-30T06:48:05Z"),
+		}, status.OperationState)
+		assert.Equal(t, []v1alpha1.ResourceStatus{{
+			Version: "v1", Kind: "Service", Namespace: "default", Name: "open-podcasts", Status: v1alpha1.SyncStatusSynced,
+			Health: &v1alpha1.HealthStatus{Status: v1alpha1.HealthStatusHealthy},
+		}, {
+			Group: "apps", Version: "v1", Kind: "Deployment", Namespace: "default", Name: "open-podcasts", Status: v1alpha1.SyncStatusSynced,
+			Health: &v1alpha1.HealthStatus{Status: v1alpha1.HealthStatusProgressing, Message: "Waiting for rollout to finish"},
+		}, {
+			Version: "v1", Kind: "ConfigMap", Namespace: "default", Name: "open-podcasts", Status: v1alpha1.SyncStatusOutOfSync,
+		}}, status.Resources)
+		assert.Equal(t, parseTime("2022-06-30T06:48:05Z"), status.LastSyncTime)
+		source := &v1alpha1.ApplicationSource{
+			RepoURL:        "https://github.com/linuxsuren/open-podcasts",
+			Path:           "config/default",
+			TargetRevision: "master",
+		}
+		assert.Equal(t, []v1alpha1.RevisionHistory{{
+			ID: 1, Revision: "0a5b7c3e5cd1f1e4d3f56c8a30ff6f1d5f0b2b9e", DeployedAt: *parseTime("2022-06-30T06:40:05Z"), Source: source,
+		}, {
+			ID: 2, Revision: "1d4fcf1f56f5dbe2ae65abb001ffc304f1d58070", DeployedAt: *parseTime("2022-06-30T06:48:05Z"), Source: source,
+		}}, status.History)
+
+		synced := meta.FindStatusCondition(status.Conditions, v1alpha1.ApplicationConditionSynced)
+		assert.Equal(t, metav1.ConditionFalse, synced.Status)
+		ready := meta.FindStatusCondition(status.Conditions, v1alpha1.ApplicationConditionReady)
+		assert.Equal(t, metav1.ConditionUnknown, ready.Status)
+		assert.Equal(t, "Progressing", ready.Reason)
+	})
+
+	t.Run("without history", func(t *testing.T) {
+		data, err := os.ReadFile("data/argo-status.json")
+		assert.Nil(t, err)
+		status := &v1alpha1.ApplicationStatus{
+			OperationState: &v1alpha1.OperationState{Phase: v1alpha1.OperationRunning},
+			Resources:      []v1alpha1.ResourceStatus{{Kind: "Service", Name: "stale"}},
+		}
+		assert.Nil(t, setApplicationStatus(data, status))
+
+		assert.Equal(t, v1alpha1.SyncStatusSynced, status.Sync)
+		assert.Equal(t, &v1alpha1.HealthStatus{Status: v1alpha1.HealthStatusHealthy}, status.Health)
+		assert.Equal(t, "1d4fcf1f56f5dbe2ae65abb001ffc304f1d58070", status.Revision)
+		assert.Nil(t, status.OperationState)
+		assert.Nil(t, status.Resources)
+		assert.Nil(t, status.LastSyncTime)
+		assert.Nil(t, status.History)
+		assert.True(t, meta.IsStatusConditionTrue(status.Conditions, v1alpha1.ApplicationConditionSynced))
+		assert.True(t, meta.IsStatusConditionTrue(status.Conditions, v1alpha1.ApplicationConditionReady))
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		status := &v1alpha1.ApplicationStatus{}
+		assert.Nil(t, setApplicationStatus([]byte(`{}`), status))
+		assert.Equal(t, v1alpha1.SyncStatusUnknown, status.Sync)
+		assert.Nil(t, status.Health)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		assert.NotNil(t, setApplicationStatus([]byte(`invalid`), &v1alpha1.ApplicationStatus{}))
+	})
+}
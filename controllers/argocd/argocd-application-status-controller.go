@@ -26,6 +26,7 @@ import (
 	"github.com/go-logr/logr"
 	"github.com/kubesphere/ks-devops/controllers/predicate"
 	"github.com/kubesphere/ks-devops/pkg/api/gitops/v1alpha1"
+	"github.com/kubesphere/ks-devops/pkg/metrics"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
@@ -83,10 +84,12 @@ func (r *ApplicationStatusReconciler) Reconcile(ctx context.Context, req ctrl.Re
 			// set sync status into labels for filtering
 			if syncStatus, found, _ := unstructured.NestedString(status, "sync", "status"); found {
 				app.GetLabels()[v1alpha1.SyncStatusLabelKey] = syncStatus
+				metrics.SetApplicationSyncStatus(app.Namespace, app.Name, syncStatus)
 			}
 			// set health status into labels for filtering
 			if healthStatus, found, _ := unstructured.NestedString(status, "health", "status"); found {
 				app.GetLabels()[v1alpha1.HealthStatusLabelKey] = healthStatus
+				metrics.SetApplicationHealthStatus(app.Namespace, app.Name, healthStatus)
 			}
 
 			// unset operation field if it was absent
@@ -106,7 +109,11 @@ func (r *ApplicationStatusReconciler) Reconcile(ctx context.Context, req ctrl.Re
 
 			// update labels
 			if err = r.Update(ctx, app); err == nil {
+				app.Status.Kind = v1alpha1.ArgoCD
 				app.Status.ArgoApp = string(statusData)
+				if err = setApplicationStatus(statusData, &app.Status); err != nil {
+					r.log.Error(err, "cannot parse the status of the ArgoCD application", "namespace", appNs, "name", appName)
+				}
 				err = r.Status().Update(ctx, app)
 			}
 		}
@@ -210,7 +210,7 @@ func TestArgoCDApplicationStatusReconciler_Reconcile(t *testing.T) {
 	}, {
 		name: "have status from argo application",
 		fields: fields{
-			Client: fake.NewClientBuilder().WithScheme(schema).WithObjects(appWithStatus, defaultApp).Build(),
+			Client: fake.NewClientBuilder().WithScheme(schema).WithObjects(appWithStatus, defaultApp).WithStatusSubresource(defaultApp).Build(),
 		},
 		args: args{
 			req: controllerruntime.Request{
@@ -233,6 +233,9 @@ func TestArgoCDApplicationStatusReconciler_Reconcile(t *testing.T) {
 			assert.Nil(t, err)
 
 			assert.Equal(t, "nginx", app.Annotations[v1alpha1.AnnoKeyImages])
+			assert.Equal(t, v1alpha1.ArgoCD, app.Status.Kind)
+			assert.Equal(t, v1alpha1.SyncStatusCode("ready"), app.Status.Sync)
+			assert.Len(t, app.Status.Conditions, 2)
 			return true
 		},
 	}, {
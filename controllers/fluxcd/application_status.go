@@ -0,0 +1,207 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fluxcd
+
+import (
+	"fmt"
+	"sort"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/kubesphere/ks-devops/pkg/api/gitops/v1alpha1"
+	helmv2 "github.com/kubesphere/ks-devops/pkg/external/fluxcd/helm/v2beta1"
+	kusv1 "github.com/kubesphere/ks-devops/pkg/external/fluxcd/kustomize/v1beta2"
+	apimeta "github.com/kubesphere/ks-devops/pkg/external/fluxcd/meta"
+)
+
+// fluxResource is the common part of the HelmRelease and Kustomization status
+type fluxResource struct {
+	v1alpha1.ResourceStatus
+	conditions []metav1.Condition
+	applied    string
+	attempted  string
+}
+
+func (r *fluxResource) ready() *metav1.Condition {
+	return meta.FindStatusCondition(r.conditions, apimeta.ReadyCondition)
+}
+
+func (r *fluxResource) reconciling() bool {
+	return meta.IsStatusConditionTrue(r.conditions, apimeta.ReconcilingCondition)
+}
+
+// health returns the health according to the conditions. A resource which is neither ready nor failed is progressing.
+func (r *fluxResource) health() *v1alpha1.HealthStatus {
+	ready := r.ready()
+	switch {
+	case ready == nil || r.reconciling() || ready.Status == metav1.ConditionUnknown:
+		health := &v1alpha1.HealthStatus{Status: v1alpha1.HealthStatusProgressing}
+		if ready != nil {
+			health.Message = ready.Message
+		}
+		return health
+	case ready.Status == metav1.ConditionTrue:
+		return &v1alpha1.HealthStatus{Status: v1alpha1.HealthStatusHealthy, Message: ready.Message}
+	default:
+		return &v1alpha1.HealthStatus{Status: v1alpha1.HealthStatusDegraded, Message: ready.Message}
+	}
+}
+
+// getFluxResources returns the HelmReleases and Kustomizations of a FluxApp, they are sorted by the kind and name
+func getFluxResources(app *v1alpha1.Application) (resources []*fluxResource) {
+	for name, status := range app.Status.FluxApp.HelmReleaseStatus {
+		if status == nil {
+			continue
+		}
+		resources = append(resources, &fluxResource{
+			ResourceStatus: v1alpha1.ResourceStatus{
+				Group:     helmv2.GroupVersion.Group,
+				Version:   helmv2.GroupVersion.Version,
+				Kind:      string(HelmRelease),
+				Namespace: app.Namespace,
+				Name:      name,
+			},
+			conditions: status.Conditions,
+			applied:    status.LastAppliedRevision,
+			attempted:  status.LastAttemptedRevision,
+		})
+	}
+	for name, status := range app.Status.FluxApp.KustomizationStatus {
+		if status == nil {
+			continue
+		}
+		resources = append(resources, &fluxResource{
+			ResourceStatus: v1alpha1.ResourceStatus{
+				Group:     kusv1.GroupVersion.Group,
+				Version:   kusv1.GroupVersion.Version,
+				Kind:      string(Kustomization),
+				Namespace: app.Namespace,
+				Name:      name,
+			},
+			conditions: status.Conditions,
+			applied:    status.LastAppliedRevision,
+			attempted:  status.LastAttemptedRevision,
+		})
+	}
+	sort.Slice(resources, func(i, j int) bool {
+		if resources[i].Kind != resources[j].Kind {
+			return resources[i].Kind < resources[j].Kind
+		}
+		return resources[i].Name < resources[j].Name
+	})
+	return
+}
+
+// getFluxAppTotal returns the number of the HelmReleases and Kustomizations which are defined in a FluxApp
+func getFluxAppTotal(app *v1alpha1.Application) (total int) {
+	if app.Spec.FluxApp == nil || app.Spec.FluxApp.Spec.Config == nil {
+		return
+	}
+	config := app.Spec.FluxApp.Spec.Config
+	if config.HelmRelease != nil {
+		total += len(config.HelmRelease.Deploy)
+	}
+	total += len(config.Kustomization)
+	return
+}
+
+// setApplicationStatus aggregates the status of the HelmReleases and Kustomizations into the engine-neutral status
+// of a FluxApp. The health is the worst one of the resources, and it's progressing until all the resources are
+// reported. The latest ready transition is taken as the last sync operation. A newly applied chart version of the
+// HelmReleases is recorded in the history, the Kustomization revisions are not since they can't be rolled back to.
+func setApplicationStatus(app *v1alpha1.Application) {
+	status := &app.Status
+	status.Kind = v1alpha1.FluxCD
+	status.Sync = v1alpha1.SyncStatusUnknown
+	status.Health = nil
+	status.Resources = nil
+
+	resources := getFluxResources(app)
+	if len(resources) > 0 {
+		status.Sync = v1alpha1.SyncStatusSynced
+		status.Health = &v1alpha1.HealthStatus{Status: v1alpha1.HealthStatusHealthy}
+	}
+
+	var latest, failed, lastSynced, lastSyncedRelease *fluxResource
+	reconciling := false
+	for _, resource := range resources {
+		resource.Status = v1alpha1.SyncStatusOutOfSync
+		if resource.applied != "" && resource.applied == resource.attempted {
+			resource.Status = v1alpha1.SyncStatusSynced
+		} else {
+			status.Sync = v1alpha1.SyncStatusOutOfSync
+		}
+		resource.Health = resource.health()
+		if v1alpha1.IsWorseHealth(resource.Health.Status, status.Health.Status) {
+			status.Health = &v1alpha1.HealthStatus{
+				Status:  resource.Health.Status,
+				Message: fmt.Sprintf("%s/%s: %s", resource.Kind, resource.Name, resource.Health.Message),
+			}
+		}
+		status.Resources = append(status.Resources, resource.ResourceStatus)
+
+		reconciling = reconciling || resource.reconciling()
+		ready := resource.ready()
+		if ready == nil || ready.Status == metav1.ConditionUnknown {
+			continue
+		}
+		if latest == nil || latest.ready().LastTransitionTime.Before(&ready.LastTransitionTime) {
+			latest = resource
+		}
+		if ready.Status == metav1.ConditionFalse && failed == nil {
+			failed = resource
+		} else if ready.Status == metav1.ConditionTrue {
+			if lastSynced == nil || lastSynced.ready().LastTransitionTime.Before(&ready.LastTransitionTime) {
+				lastSynced = resource
+			}
+			if resource.Kind == string(HelmRelease) && (lastSyncedRelease == nil ||
+				lastSyncedRelease.ready().LastTransitionTime.Before(&ready.LastTransitionTime)) {
+				lastSyncedRelease = resource
+			}
+		}
+	}
+	if status.Health != nil && len(resources) < getFluxAppTotal(app) &&
+		v1alpha1.IsWorseHealth(v1alpha1.HealthStatusProgressing, status.Health.Status) {
+		status.Health = &v1alpha1.HealthStatus{Status: v1alpha1.HealthStatusProgressing,
+			Message: fmt.Sprintf("%d of %d resources are reported", len(resources), getFluxAppTotal(app))}
+	}
+
+	if latest != nil {
+		status.Revision = latest.applied
+		status.OperationState = &v1alpha1.OperationState{
+			Phase:      v1alpha1.OperationSucceeded,
+			Message:    latest.ready().Message,
+			Revision:   latest.attempted,
+			FinishedAt: latest.ready().LastTransitionTime.DeepCopy(),
+		}
+		if failed != nil {
+			status.OperationState.Phase = v1alpha1.OperationFailed
+			status.OperationState.Message = failed.ready().Message
+		}
+	}
+	if reconciling {
+		status.OperationState = &v1alpha1.OperationState{Phase: v1alpha1.OperationRunning}
+	}
+	if lastSynced != nil {
+		status.LastSyncTime = lastSynced.ready().LastTransitionTime.DeepCopy()
+	}
+	if lastSyncedRelease != nil && lastSyncedRelease.applied != "" {
+		status.AddHistory(lastSyncedRelease.applied, lastSyncedRelease.ready().LastTransitionTime)
+	}
+	status.UpdateConditions()
+}
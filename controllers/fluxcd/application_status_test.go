@@ -0,0 +1,148 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fluxcd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/kubesphere/ks-devops/pkg/api/gitops/v1alpha1"
+	helmv2 "github.com/kubesphere/ks-devops/pkg/external/fluxcd/helm/v2beta1"
+	kusv1 "github.com/kubesphere/ks-devops/pkg/external/fluxcd/kustomize/v1beta2"
+	apimeta "github.com/kubesphere/ks-devops/pkg/external/fluxcd/meta"
+)
+
+func Test_setApplicationStatus(t *testing.T) {
+	t1 := metav1.NewTime(time.Date(2022, 6, 30, 6, 0, 0, 0, time.UTC))
+	t2 := metav1.NewTime(t1.Add(time.Minute))
+	ready := func(status metav1.ConditionStatus, at metav1.Time, message string) metav1.Condition {
+		return metav1.Condition{Type: apimeta.ReadyCondition, Status: status, LastTransitionTime: at, Message: message}
+	}
+	newApp := func(deploys, kustomizations int) *v1alpha1.Application {
+		return &v1alpha1.Application{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "app"},
+			Spec: v1alpha1.ApplicationSpec{
+				Kind: v1alpha1.FluxCD,
+				FluxApp: &v1alpha1.FluxApplication{Spec: v1alpha1.FluxApplicationSpec{Config: &v1alpha1.FluxApplicationConfig{
+					HelmRelease:   &v1alpha1.HelmReleaseSpec{Deploy: make([]*v1alpha1.Deploy, deploys)},
+					Kustomization: make([]*v1alpha1.KustomizationSpec, kustomizations),
+				}}},
+			},
+		}
+	}
+
+	t.Run("no resources reported", func(t *testing.T) {
+		app := newApp(1, 0)
+		setApplicationStatus(app)
+		assert.Equal(t, v1alpha1.FluxCD, app.Status.Kind)
+		assert.Equal(t, v1alpha1.SyncStatusUnknown, app.Status.Sync)
+		assert.Nil(t, app.Status.Health)
+		assert.Nil(t, app.Status.OperationState)
+		assert.Len(t, app.Status.Conditions, 2)
+	})
+
+	t.Run("all ready", func(t *testing.T) {
+		app := newApp(1, 1)
+		app.Status.FluxApp.HelmReleaseStatus = map[string]*helmv2.HelmReleaseStatus{"hr": {
+			Conditions:          []metav1.Condition{ready(metav1.ConditionTrue, t1, "Release reconciliation succeeded")},
+			LastAppliedRevision: "0.1.0", LastAttemptedRevision: "0.1.0",
+		}}
+		app.Status.FluxApp.KustomizationStatus = map[string]*kusv1.KustomizationStatus{"kus": {
+			Conditions:          []metav1.Condition{ready(metav1.ConditionTrue, t2, "Applied revision: main/abc")},
+			LastAppliedRevision: "main/abc", LastAttemptedRevision: "main/abc",
+		}}
+		setApplicationStatus(app)
+
+		assert.Equal(t, v1alpha1.SyncStatusSynced, app.Status.Sync)
+		assert.Equal(t, &v1alpha1.HealthStatus{Status: v1alpha1.HealthStatusHealthy}, app.Status.Health)
+		assert.Equal(t, "main/abc", app.Status.Revision)
+		assert.Equal(t, &v1alpha1.OperationState{
+			Phase:      v1alpha1.OperationSucceeded,
+			Message:    "Applied revision: main/abc",
+			Revision:   "main/abc",
+			FinishedAt: &t2,
+		}, app.Status.OperationState)
+		assert.Equal(t, &t2, app.Status.LastSyncTime)
+		// the Kustomization revision can't be rolled back to
+		assert.Equal(t, []v1alpha1.RevisionHistory{{ID: 1, Revision: "0.1.0", DeployedAt: t1}}, app.Status.History)
+		assert.Equal(t, []v1alpha1.ResourceStatus{{
+			Group: "helm.toolkit.fluxcd.io", Version: "v2beta1", Kind: "HelmRelease", Namespace: "ns", Name: "hr",
+			Status: v1alpha1.SyncStatusSynced,
+			Health: &v1alpha1.HealthStatus{Status: v1alpha1.HealthStatusHealthy, Message: "Release reconciliation succeeded"},
+		}, {
+			Group: "kustomize.toolkit.fluxcd.io", Version: "v1beta2", Kind: "Kustomization", Namespace: "ns", Name: "kus",
+			Status: v1alpha1.SyncStatusSynced,
+			Health: &v1alpha1.HealthStatus{Status: v1alpha1.HealthStatusHealthy, Message: "Applied revision: main/abc"},
+		}}, app.Status.Resources)
+		assert.True(t, meta.IsStatusConditionTrue(app.Status.Conditions, v1alpha1.ApplicationConditionSynced))
+		assert.True(t, meta.IsStatusConditionTrue(app.Status.Conditions, v1alpha1.ApplicationConditionReady))
+	})
+
+	t.Run("partially reported", func(t *testing.T) {
+		app := newApp(2, 0)
+		app.Status.FluxApp.HelmReleaseStatus = map[string]*helmv2.HelmReleaseStatus{"hr": {
+			Conditions:          []metav1.Condition{ready(metav1.ConditionTrue, t1, "")},
+			LastAppliedRevision: "0.1.0", LastAttemptedRevision: "0.1.0",
+		}}
+		setApplicationStatus(app)
+		assert.Equal(t, v1alpha1.HealthStatusProgressing, app.Status.Health.Status)
+		assert.Equal(t, "1 of 2 resources are reported", app.Status.Health.Message)
+	})
+
+	t.Run("one failed", func(t *testing.T) {
+		app := newApp(2, 0)
+		app.Status.LastSyncTime = &t1
+		app.Status.FluxApp.HelmReleaseStatus = map[string]*helmv2.HelmReleaseStatus{"a": {
+			Conditions:          []metav1.Condition{ready(metav1.ConditionTrue, t1, "")},
+			LastAppliedRevision: "0.1.0", LastAttemptedRevision: "0.1.0",
+		}, "b": {
+			Conditions:          []metav1.Condition{ready(metav1.ConditionFalse, t2, "install failed")},
+			LastAppliedRevision: "0.1.0", LastAttemptedRevision: "0.2.0",
+		}}
+		setApplicationStatus(app)
+
+		assert.Equal(t, v1alpha1.SyncStatusOutOfSync, app.Status.Sync)
+		assert.Equal(t, &v1alpha1.HealthStatus{Status: v1alpha1.HealthStatusDegraded, Message: "HelmRelease/b: install failed"}, app.Status.Health)
+		assert.Equal(t, v1alpha1.OperationFailed, app.Status.OperationState.Phase)
+		assert.Equal(t, "install failed", app.Status.OperationState.Message)
+		assert.Equal(t, "0.2.0", app.Status.OperationState.Revision)
+		assert.Equal(t, &t1, app.Status.LastSyncTime)
+		assert.Equal(t, []v1alpha1.RevisionHistory{{ID: 1, Revision: "0.1.0", DeployedAt: t1}}, app.Status.History)
+		synced := meta.FindStatusCondition(app.Status.Conditions, v1alpha1.ApplicationConditionSynced)
+		assert.Equal(t, "SyncFailed", synced.Reason)
+		assert.True(t, meta.IsStatusConditionFalse(app.Status.Conditions, v1alpha1.ApplicationConditionReady))
+	})
+
+	t.Run("reconciling", func(t *testing.T) {
+		app := newApp(1, 0)
+		app.Status.FluxApp.HelmReleaseStatus = map[string]*helmv2.HelmReleaseStatus{"hr": {
+			Conditions: []metav1.Condition{ready(metav1.ConditionUnknown, t1, "Reconciliation in progress"),
+				{Type: apimeta.ReconcilingCondition, Status: metav1.ConditionTrue, LastTransitionTime: t1}},
+			LastAttemptedRevision: "0.1.0",
+		}}
+		setApplicationStatus(app)
+
+		assert.Equal(t, v1alpha1.SyncStatusOutOfSync, app.Status.Sync)
+		assert.Equal(t, v1alpha1.HealthStatusProgressing, app.Status.Health.Status)
+		assert.Equal(t, &v1alpha1.OperationState{Phase: v1alpha1.OperationRunning}, app.Status.OperationState)
+		assert.Nil(t, app.Status.LastSyncTime)
+	})
+}
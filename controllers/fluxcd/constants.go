@@ -43,4 +43,9 @@ const (
 
 	// FluxAppLastRevision is the revision of the last successfully applied source.
 	FluxAppLastRevision = "gitops.kubesphere.io/last-revision"
+
+	// FluxAppHealthy indicates all the HelmRelease or Kustomization are ready
+	FluxAppHealthy = "Healthy"
+	// FluxAppProgressing indicates some of the HelmRelease or Kustomization are not ready yet
+	FluxAppProgressing = "Progressing"
 )
@@ -26,6 +26,7 @@ import (
 	helmv2 "github.com/kubesphere/ks-devops/pkg/external/fluxcd/helm/v2beta1"
 	kusv1 "github.com/kubesphere/ks-devops/pkg/external/fluxcd/kustomize/v1beta2"
 	apimeta "github.com/kubesphere/ks-devops/pkg/external/fluxcd/meta"
+	"github.com/kubesphere/ks-devops/pkg/metrics"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apimachinery/pkg/types"
@@ -93,6 +94,7 @@ func (r *ApplicationStatusReconciler) reconcileHelmRelease(ctx context.Context,
 		app.Status.FluxApp.HelmReleaseStatus = make(map[string]*helmv2.HelmReleaseStatus, totalHRNum)
 	}
 	app.Status.FluxApp.HelmReleaseStatus[hr.GetAnnotations()["app.kubernetes.io/name"]] = hr.Status.DeepCopy()
+	setApplicationStatus(app)
 	// Update status
 	if err = r.Status().Update(ctx, app); err != nil {
 		return
@@ -114,6 +116,7 @@ func (r *ApplicationStatusReconciler) reconcileHelmRelease(ctx context.Context,
 		}
 	}
 	app.GetLabels()[FluxAppReadyNumKey] = strconv.Itoa(readyHRNum) + "-" + strconv.Itoa(totalHRNum)
+	metrics.SetApplicationHealthStatus(app.Namespace, app.Name, getFluxAppHealthStatus(readyHRNum, totalHRNum))
 	// TODO: should find a better way to add AppType
 	app.GetLabels()[FluxAppTypeKey] = string(HelmRelease)
 
@@ -140,6 +143,7 @@ func (r *ApplicationStatusReconciler) reconcileKustomization(ctx context.Context
 		app.Status.FluxApp.KustomizationStatus = make(map[string]*kusv1.KustomizationStatus, totalKusNum)
 	}
 	app.Status.FluxApp.KustomizationStatus[kus.GetAnnotations()["app.kubernetes.io/name"]] = kus.Status.DeepCopy()
+	setApplicationStatus(app)
 	// Update status
 	if err = r.Status().Update(ctx, app); err != nil {
 		return
@@ -161,6 +165,7 @@ func (r *ApplicationStatusReconciler) reconcileKustomization(ctx context.Context
 		}
 	}
 	app.GetLabels()[FluxAppReadyNumKey] = strconv.Itoa(readyKusNum) + "-" + strconv.Itoa(totalKusNum)
+	metrics.SetApplicationHealthStatus(app.Namespace, app.Name, getFluxAppHealthStatus(readyKusNum, totalKusNum))
 	// TODO: should find a better way to add AppType
 	app.GetLabels()[FluxAppTypeKey] = string(Kustomization)
 	// update label
@@ -170,6 +175,14 @@ func (r *ApplicationStatusReconciler) reconcileKustomization(ctx context.Context
 	return
 }
 
+// getFluxAppHealthStatus returns the health status of a FluxApp, it's healthy once all the deployments are ready
+func getFluxAppHealthStatus(readyNum, totalNum int) string {
+	if readyNum >= totalNum {
+		return FluxAppHealthy
+	}
+	return FluxAppProgressing
+}
+
 // GetName returns the name of this controller
 func (r *ApplicationStatusReconciler) GetName() string {
 	return "FluxCDApplicationStatusController"
@@ -831,3 +831,9 @@ func TestApplicationStatusReconciler_SetupWithManager(t *testing.T) {
 		})
 	}
 }
+
+func Test_getFluxAppHealthStatus(t *testing.T) {
+	assert.Equal(t, FluxAppHealthy, getFluxAppHealthStatus(2, 2))
+	assert.Equal(t, FluxAppProgressing, getFluxAppHealthStatus(1, 2))
+	assert.Equal(t, FluxAppProgressing, getFluxAppHealthStatus(0, 1))
+}
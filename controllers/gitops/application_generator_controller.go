@@ -0,0 +1,358 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gitops
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/go-logr/logr"
+	"github.com/kubesphere/ks-devops/pkg/api/gitops/v1alpha1"
+	"github.com/kubesphere/ks-devops/pkg/constants"
+	devopsgitops "github.com/kubesphere/ks-devops/pkg/kapis/devops/v1alpha3/gitops"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/retry"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+//+kubebuilder:rbac:groups=gitops.kubesphere.io,resources=applicationgenerators,verbs=get;list;watch
+//+kubebuilder:rbac:groups=gitops.kubesphere.io,resources=applicationgenerators/status,verbs=get;update
+//+kubebuilder:rbac:groups=gitops.kubesphere.io,resources=applications,verbs=get;list;watch;create;update;delete
+//+kubebuilder:rbac:groups=cluster.kubesphere.io,resources=clusters,verbs=list
+//+kubebuilder:rbac:groups=tenant.kubesphere.io,resources=workspacetemplates,verbs=get
+//+kubebuilder:rbac:groups="",resources=namespaces,verbs=get
+//+kubebuilder:rbac:groups=devops.kubesphere.io,resources=gitrepositories,verbs=get
+//+kubebuilder:rbac:groups="",resources=secrets,verbs=get
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+
+// generatorUser is the user who reads the GitRepositories of the Git generators
+const generatorUser = "application-generator-controller"
+
+// ApplicationGeneratorReconciler generates the Applications of an ApplicationGenerator, the Applications which are not
+// generated anymore are pruned
+type ApplicationGeneratorReconciler struct {
+	client.Client
+	RepoFactory devopsgitops.GitRepoFactory
+	// ArgoNamespace is the namespace of Argo CD, it's set to the generated Argo CD Applications
+	ArgoNamespace string
+	log           logr.Logger
+	recorder      record.EventRecorder
+}
+
+// Reconcile generates the Applications, then prunes the ones which are not generated anymore
+func (r *ApplicationGeneratorReconciler) Reconcile(ctx context.Context, req ctrl.Request) (result ctrl.Result, err error) {
+	r.log.V(6).Info(fmt.Sprintf("start to reconcile application generator: %s", req.String()))
+
+	generator := &v1alpha1.ApplicationGenerator{}
+	if err = r.Get(ctx, req.NamespacedName, generator); err != nil {
+		err = client.IgnoreNotFound(err)
+		return
+	}
+	// the generated Applications are deleted by the garbage collector
+	if !generator.DeletionTimestamp.IsZero() {
+		return
+	}
+
+	status := generator.Status.DeepCopy()
+	var apps []*v1alpha1.Application
+	var counts []int
+	if apps, counts, err = r.generateApplications(ctx, generator); err != nil {
+		// keep the Applications as they are, the parameter sets might be incomplete
+		status.Message = err.Error()
+	} else {
+		var errs []error
+		var names []string
+		for _, app := range apps {
+			names = append(names, app.Name)
+			if err = r.applyApplication(ctx, generator, app); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		if !generator.Spec.SkipPrune {
+			if err = r.pruneApplications(ctx, generator, names); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		sort.Strings(names)
+		status.Applications, status.ParameterSets, status.Message = names, counts, ""
+		if err = utilerrors.NewAggregate(errs); err != nil {
+			status.Message = err.Error()
+		}
+	}
+
+	if !reflect.DeepEqual(status, &generator.Status) {
+		if err = r.updateStatus(ctx, generator, status); err != nil {
+			return
+		}
+	}
+	err = nil
+	if status.Message != "" || generator.IsDynamic() {
+		result.RequeueAfter = generator.GetInterval()
+	}
+	return
+}
+
+func (r *ApplicationGeneratorReconciler) updateStatus(ctx context.Context, generator *v1alpha1.ApplicationGenerator,
+	status *v1alpha1.ApplicationGeneratorStatus) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		latest := &v1alpha1.ApplicationGenerator{}
+		if err := r.Get(ctx, types.NamespacedName{Namespace: generator.Namespace, Name: generator.Name}, latest); err != nil {
+			return err
+		}
+		latest.Status = *status
+		return r.Status().Update(ctx, latest)
+	})
+}
+
+// generateApplications renders the template with each parameter set, it returns the numbers of the parameter sets of
+// each generator as well
+func (r *ApplicationGeneratorReconciler) generateApplications(ctx context.Context, generator *v1alpha1.ApplicationGenerator) (
+	apps []*v1alpha1.Application, counts []int, err error) {
+	var paramSets []map[string]string
+	last := generator.Status.ParameterSets
+	for i, item := range generator.Spec.Generators {
+		var params []map[string]string
+		if params, err = r.generatorParams(ctx, generator.Namespace, item); err != nil {
+			return
+		}
+		// the clusters or the Git directories might be missing temporarily, don't prune all the Applications of them
+		if len(params) == 0 && item.IsDynamic() && !generator.Spec.SkipPrune &&
+			len(last) == len(generator.Spec.Generators) && last[i] > 0 {
+			return nil, nil, fmt.Errorf("generator %d got no parameter set but got %d last time, "+
+				"remove the generator to prune its applications", i, last[i])
+		}
+		counts = append(counts, len(params))
+		paramSets = append(paramSets, params...)
+	}
+
+	names := map[string]bool{}
+	for _, params := range paramSets {
+		var app *v1alpha1.Application
+		if app, err = renderTemplate(&generator.Spec.Template, params); err != nil {
+			return nil, nil, fmt.Errorf("failed to render the template: %v", err)
+		}
+		if errs := validation.IsDNS1123Subdomain(app.Name); len(errs) > 0 {
+			return nil, nil, fmt.Errorf("invalid application name %q: %s", app.Name, errs[0])
+		}
+		if names[app.Name] {
+			return nil, nil, fmt.Errorf("duplicate application name %q, the name should contain the parameters", app.Name)
+		}
+		names[app.Name] = true
+
+		app.Namespace = generator.Namespace
+		if app.Labels == nil {
+			app.Labels = map[string]string{}
+		}
+		app.Labels[v1alpha1.AppGeneratorLabelKey] = generator.Name
+		if app.Spec.ArgoApp != nil && app.Labels[v1alpha1.ArgoCDLocationLabelKey] == "" && r.ArgoNamespace != "" {
+			app.Labels[v1alpha1.ArgoCDLocationLabelKey] = r.ArgoNamespace
+		}
+		apps = append(apps, app)
+	}
+	return
+}
+
+// generatorParams returns the parameter sets of a generator
+func (r *ApplicationGeneratorReconciler) generatorParams(ctx context.Context, namespace string, generator v1alpha1.Generator) (
+	[]map[string]string, error) {
+	if generator.Matrix == nil {
+		return r.baseGeneratorParams(ctx, namespace, generator.BaseGenerator)
+	}
+	if generator.List != nil || generator.Clusters != nil || generator.Git != nil {
+		return nil, errors.New("only one generator should be set")
+	}
+
+	var paramSets [][]map[string]string
+	for _, item := range generator.Matrix.Generators {
+		params, err := r.baseGeneratorParams(ctx, namespace, item)
+		if err != nil {
+			return nil, err
+		}
+		paramSets = append(paramSets, params)
+	}
+	return matrixParams(paramSets...), nil
+}
+
+func (r *ApplicationGeneratorReconciler) baseGeneratorParams(ctx context.Context, namespace string,
+	generator v1alpha1.BaseGenerator) ([]map[string]string, error) {
+	switch {
+	case generator.List != nil && generator.Clusters == nil && generator.Git == nil:
+		return listParams(generator.List), nil
+	case generator.Clusters != nil && generator.List == nil && generator.Git == nil:
+		clusters, err := r.listWorkspaceClusters(ctx, namespace)
+		if err != nil {
+			return nil, err
+		}
+		return clusterParams(clusters, generator.Clusters)
+	case generator.Git != nil && generator.List == nil && generator.Clusters == nil:
+		repo := types.NamespacedName{Namespace: namespace, Name: generator.Git.Repository.Name}
+		repoService, err := r.RepoFactory.NewRepoService(ctx, &user.DefaultInfo{Name: generatorUser}, repo)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open the git repository %s: %v", repo.Name, err)
+		}
+		tree, err := repoService.GetTree(ctx, &devopsgitops.GetTreeInput{Revision: generator.Git.Revision})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get the files of the git repository %s: %v", repo.Name, err)
+		}
+		return gitParams(tree.Files, generator.Git)
+	case generator.List == nil && generator.Clusters == nil && generator.Git == nil:
+		return nil, errors.New("no generator is set")
+	default:
+		return nil, errors.New("only one generator should be set")
+	}
+}
+
+// listWorkspaceClusters returns the KubeSphere clusters which the workspace of the namespace is placed in
+func (r *ApplicationGeneratorReconciler) listWorkspaceClusters(ctx context.Context, namespace string) (
+	[]unstructured.Unstructured, error) {
+	ns := &corev1.Namespace{}
+	if err := r.Get(ctx, types.NamespacedName{Name: namespace}, ns); err != nil {
+		return nil, fmt.Errorf("failed to get namespace %s: %v", namespace, err)
+	}
+	workspace := ns.Labels[constants.WorkspaceLabelKey]
+	if workspace == "" {
+		return nil, fmt.Errorf("namespace %s doesn't belong to any workspace", namespace)
+	}
+	template := &unstructured.Unstructured{}
+	template.SetGroupVersionKind(schema.GroupVersionKind{
+		Group:   "tenant.kubesphere.io",
+		Version: "v1beta1",
+		Kind:    "WorkspaceTemplate",
+	})
+	if err := r.Get(ctx, types.NamespacedName{Name: workspace}, template); err != nil {
+		return nil, fmt.Errorf("failed to get workspace %s: %v", workspace, err)
+	}
+
+	clusters := &unstructured.UnstructuredList{}
+	clusters.SetGroupVersionKind(schema.GroupVersionKind{
+		Group:   "cluster.kubesphere.io",
+		Version: "v1alpha1",
+		Kind:    "ClusterList",
+	})
+	if err := r.List(ctx, clusters); err != nil {
+		return nil, fmt.Errorf("failed to list the clusters: %v", err)
+	}
+	return placedClusters(clusters.Items, template)
+}
+
+// applyApplication creates or updates a generated Application, the Applications which are not generated by the
+// generator are left untouched
+func (r *ApplicationGeneratorReconciler) applyApplication(ctx context.Context, generator *v1alpha1.ApplicationGenerator,
+	app *v1alpha1.Application) (err error) {
+	existing := &v1alpha1.Application{}
+	if err = r.Get(ctx, types.NamespacedName{Namespace: app.Namespace, Name: app.Name}, existing); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return
+		}
+		if err = controllerutil.SetControllerReference(generator, app, r.Scheme()); err != nil {
+			return
+		}
+		if err = r.Create(ctx, app); err != nil {
+			return fmt.Errorf("failed to create application %s: %v", app.Name, err)
+		}
+		r.recorder.Eventf(generator, corev1.EventTypeNormal, "Created", "created application %s", app.Name)
+		return
+	}
+	if !metav1.IsControlledBy(existing, generator) {
+		return fmt.Errorf("application %s already exists and is not generated by %s", app.Name, generator.Name)
+	}
+
+	updated := existing.DeepCopy()
+	// keep the labels and annotations set by the others, like the sync status
+	updated.Labels = mergeParams(existing.Labels, app.Labels)
+	updated.Annotations = mergeParams(existing.Annotations, app.Annotations)
+	if len(updated.Annotations) == 0 {
+		updated.Annotations = existing.Annotations
+	}
+	updated.Spec = app.Spec
+	// keep the operation which was requested by a user, like syncing
+	if existing.Spec.ArgoApp != nil && updated.Spec.ArgoApp != nil {
+		updated.Spec.ArgoApp.Operation = existing.Spec.ArgoApp.Operation
+	}
+	if equality.Semantic.DeepEqual(existing, updated) {
+		return
+	}
+	if err = r.Update(ctx, updated); err != nil {
+		return fmt.Errorf("failed to update application %s: %v", app.Name, err)
+	}
+	r.recorder.Eventf(generator, corev1.EventTypeNormal, "Updated", "updated application %s", app.Name)
+	return
+}
+
+// pruneApplications deletes the Applications which were generated by the generator but not generated anymore
+func (r *ApplicationGeneratorReconciler) pruneApplications(ctx context.Context, generator *v1alpha1.ApplicationGenerator,
+	names []string) error {
+	apps := &v1alpha1.ApplicationList{}
+	if err := r.List(ctx, apps, client.InNamespace(generator.Namespace),
+		client.MatchingLabels{v1alpha1.AppGeneratorLabelKey: generator.Name}); err != nil {
+		return fmt.Errorf("failed to list the generated applications: %v", err)
+	}
+
+	generated := map[string]bool{}
+	for _, name := range names {
+		generated[name] = true
+	}
+	var errs []error
+	for i := range apps.Items {
+		app := &apps.Items[i]
+		if generated[app.Name] || !metav1.IsControlledBy(app, generator) || !app.DeletionTimestamp.IsZero() {
+			continue
+		}
+		if err := r.Delete(ctx, app); err != nil && !apierrors.IsNotFound(err) {
+			errs = append(errs, fmt.Errorf("failed to delete application %s: %v", app.Name, err))
+			continue
+		}
+		r.recorder.Eventf(generator, corev1.EventTypeNormal, "Deleted", "deleted application %s", app.Name)
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+// GetName returns the name of this controller
+func (r *ApplicationGeneratorReconciler) GetName() string {
+	return "ApplicationGeneratorController"
+}
+
+// GetGroupName returns the group name of this controller
+func (r *ApplicationGeneratorReconciler) GetGroupName() string {
+	return controllerGroupName
+}
+
+// SetupWithManager setups the log and recorder
+func (r *ApplicationGeneratorReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	r.log = ctrl.Log.WithName(r.GetName())
+	r.recorder = mgr.GetEventRecorderFor(r.GetName())
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("gitops_application_generator_controller").
+		For(&v1alpha1.ApplicationGenerator{}).
+		Owns(&v1alpha1.Application{}).
+		Complete(r)
+}
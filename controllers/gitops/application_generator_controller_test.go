@@ -0,0 +1,323 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gitops
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/kubesphere/ks-devops/pkg/api/gitops/v1alpha1"
+	helmv2 "github.com/kubesphere/ks-devops/pkg/external/fluxcd/helm/v2beta1"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func newApplicationGenerator(generators ...v1alpha1.Generator) *v1alpha1.ApplicationGenerator {
+	app := newArgoApp("")
+	app.Spec.ArgoApp.Spec.Source.Path = "{{path}}"
+	app.Spec.ArgoApp.Spec.Destination.Name = "{{name}}"
+	return &v1alpha1.ApplicationGenerator{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "web", UID: "uid"},
+		Spec: v1alpha1.ApplicationGeneratorSpec{
+			Generators: generators,
+			Template: v1alpha1.ApplicationTemplate{
+				Metadata: v1alpha1.ApplicationTemplateMeta{Name: "web-{{name}}", Labels: map[string]string{"team": "web"}},
+				Spec:     app.Spec,
+			},
+		},
+	}
+}
+
+func newListGenerator(elements ...map[string]string) v1alpha1.Generator {
+	return v1alpha1.Generator{BaseGenerator: v1alpha1.BaseGenerator{List: &v1alpha1.ListGenerator{Elements: elements}}}
+}
+
+func TestApplicationGeneratorReconciler_Reconcile(t *testing.T) {
+	clusterGVK := schema.GroupVersionKind{Group: "cluster.kubesphere.io", Version: "v1alpha1", Kind: "Cluster"}
+	workspaceGVK := schema.GroupVersionKind{Group: "tenant.kubesphere.io", Version: "v1beta1", Kind: "WorkspaceTemplate"}
+	newReconciler := func(withClusters bool, objects ...client.Object) (*ApplicationGeneratorReconciler, *fakeRepoService) {
+		s := newPromotionScheme(t)
+		s.AddKnownTypeWithName(workspaceGVK, &unstructured.Unstructured{})
+		if withClusters {
+			s.AddKnownTypeWithName(clusterGVK, &unstructured.Unstructured{})
+			s.AddKnownTypeWithName(clusterGVK.GroupVersion().WithKind("ClusterList"), &unstructured.UnstructuredList{})
+		}
+		service := &fakeRepoService{files: map[string][]byte{}}
+		return &ApplicationGeneratorReconciler{
+			Client: fake.NewClientBuilder().WithScheme(s).WithObjects(objects...).
+				WithStatusSubresource(&v1alpha1.ApplicationGenerator{}).Build(),
+			RepoFactory:   &fakeRepoFactory{service: service},
+			ArgoNamespace: "argocd",
+			log:           logr.Discard(),
+			recorder:      &record.FakeRecorder{},
+		}, service
+	}
+	reconcileAndGet := func(t *testing.T, r *ApplicationGeneratorReconciler) (*v1alpha1.ApplicationGenerator, ctrl.Result) {
+		result, err := r.Reconcile(context.Background(), reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "ns", Name: "web"}})
+		assert.Nil(t, err)
+		generator := &v1alpha1.ApplicationGenerator{}
+		assert.Nil(t, r.Get(context.Background(), types.NamespacedName{Namespace: "ns", Name: "web"}, generator))
+		return generator, result
+	}
+	// the namespace ns belongs to the workspace ws which is placed in the clusters
+	newWorkspace := func(clusters ...string) []client.Object {
+		ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "ns", Labels: map[string]string{"kubesphere.io/workspace": "ws"}}}
+		workspace := &unstructured.Unstructured{}
+		workspace.SetGroupVersionKind(workspaceGVK)
+		workspace.SetName("ws")
+		var refs []interface{}
+		for _, cluster := range clusters {
+			refs = append(refs, map[string]interface{}{"name": cluster})
+		}
+		assert.Nil(t, unstructured.SetNestedSlice(workspace.Object, refs, "spec", "placement", "clusters"))
+		return []client.Object{ns, workspace}
+	}
+	listApps := func(t *testing.T, r *ApplicationGeneratorReconciler) map[string]*v1alpha1.Application {
+		apps := &v1alpha1.ApplicationList{}
+		assert.Nil(t, r.List(context.Background(), apps, client.InNamespace("ns")))
+		result := map[string]*v1alpha1.Application{}
+		for i := range apps.Items {
+			result[apps.Items[i].Name] = &apps.Items[i]
+		}
+		return result
+	}
+
+	t.Run("create, update and prune the applications", func(t *testing.T) {
+		generator := newApplicationGenerator(newListGenerator(
+			map[string]string{"name": "host", "path": "apps/web"},
+			map[string]string{"name": "member", "path": "apps/web"}))
+		r, _ := newReconciler(false, generator)
+
+		generator, result := reconcileAndGet(t, r)
+		assert.Equal(t, ctrl.Result{}, result)
+		assert.Equal(t, v1alpha1.ApplicationGeneratorStatus{
+			Applications:  []string{"web-host", "web-member"},
+			ParameterSets: []int{2},
+		}, generator.Status)
+		apps := listApps(t, r)
+		if assert.Len(t, apps, 2) {
+			app := apps["web-host"]
+			assert.Equal(t, map[string]string{
+				"team":                          "web",
+				v1alpha1.AppGeneratorLabelKey:   "web",
+				v1alpha1.ArgoCDLocationLabelKey: "argocd",
+			}, app.Labels)
+			assert.True(t, metav1.IsControlledBy(app, generator))
+			assert.Equal(t, "apps/web", app.Spec.ArgoApp.Spec.Source.Path)
+			assert.Equal(t, "host", app.Spec.ArgoApp.Spec.Destination.Name)
+		}
+
+		// the labels and the operation set by the others are kept
+		app := apps["web-host"]
+		app.Labels[v1alpha1.SyncStatusLabelKey] = "Synced"
+		app.Spec.ArgoApp.Operation = &v1alpha1.Operation{Sync: &v1alpha1.SyncOperation{Revision: "abc"}}
+		app.Spec.ArgoApp.Spec.Source.Path = "changed"
+		assert.Nil(t, r.Update(context.Background(), app))
+
+		generator.Spec.Generators = []v1alpha1.Generator{newListGenerator(map[string]string{"name": "host", "path": "apps/api"})}
+		assert.Nil(t, r.Update(context.Background(), generator))
+		generator, _ = reconcileAndGet(t, r)
+		assert.Equal(t, []string{"web-host"}, generator.Status.Applications)
+		apps = listApps(t, r)
+		if assert.Len(t, apps, 1) {
+			app = apps["web-host"]
+			assert.Equal(t, "apps/api", app.Spec.ArgoApp.Spec.Source.Path)
+			assert.Equal(t, "Synced", app.Labels[v1alpha1.SyncStatusLabelKey])
+			assert.Equal(t, "abc", app.Spec.ArgoApp.Operation.Sync.Revision)
+		}
+	})
+
+	t.Run("skip pruning", func(t *testing.T) {
+		generator := newApplicationGenerator(newListGenerator(map[string]string{"name": "host"}))
+		generator.Spec.SkipPrune = true
+		r, _ := newReconciler(false, generator)
+		generator, _ = reconcileAndGet(t, r)
+
+		generator.Spec.Generators = []v1alpha1.Generator{newListGenerator(map[string]string{"name": "member"})}
+		assert.Nil(t, r.Update(context.Background(), generator))
+		generator, _ = reconcileAndGet(t, r)
+		assert.Equal(t, []string{"web-member"}, generator.Status.Applications)
+		assert.Len(t, listApps(t, r), 2)
+	})
+
+	t.Run("the application is not generated by the generator", func(t *testing.T) {
+		existing := newArgoApp("web-host")
+		existing.Namespace = "ns"
+		r, _ := newReconciler(false, existing, newApplicationGenerator(newListGenerator(
+			map[string]string{"name": "host"}, map[string]string{"name": "member"})))
+
+		generator, result := reconcileAndGet(t, r)
+		assert.Equal(t, v1alpha1.DefaultGenerateInterval, result.RequeueAfter)
+		assert.Equal(t, "application web-host already exists and is not generated by web", generator.Status.Message)
+		apps := listApps(t, r)
+		assert.Len(t, apps, 2)
+		assert.Empty(t, apps["web-host"].OwnerReferences)
+		assert.True(t, metav1.IsControlledBy(apps["web-member"], generator))
+	})
+
+	t.Run("the generated applications are kept if the generation failed", func(t *testing.T) {
+		generator := newApplicationGenerator(newListGenerator(map[string]string{"name": "host"}))
+		r, _ := newReconciler(false, generator)
+		generator, _ = reconcileAndGet(t, r)
+
+		generator.Spec.Generators = append(generator.Spec.Generators, newListGenerator(map[string]string{"name": "host", "path": "other"}))
+		assert.Nil(t, r.Update(context.Background(), generator))
+		generator, _ = reconcileAndGet(t, r)
+		assert.Equal(t, `duplicate application name "web-host", the name should contain the parameters`, generator.Status.Message)
+		assert.Equal(t, []string{"web-host"}, generator.Status.Applications)
+		assert.Len(t, listApps(t, r), 1)
+
+		generator.Spec.Generators = []v1alpha1.Generator{newListGenerator(map[string]string{"path": "other"})}
+		assert.Nil(t, r.Update(context.Background(), generator))
+		generator, _ = reconcileAndGet(t, r)
+		assert.Contains(t, generator.Status.Message, `invalid application name "web-{{name}}"`)
+		assert.Len(t, listApps(t, r), 1)
+	})
+
+	t.Run("matrix of the clusters and the git directories", func(t *testing.T) {
+		fluxApp := newFluxApp("", "{}")
+		deploy := fluxApp.Spec.FluxApp.Spec.Config.HelmRelease.Deploy[0]
+		deploy.Destination.KubeConfig = &helmv2.KubeConfig{}
+		deploy.Destination.KubeConfig.SecretRef.Name = "{{name}}"
+		deploy.Destination.TargetNamespace = "{{path.basename}}"
+		generator := newApplicationGenerator(v1alpha1.Generator{Matrix: &v1alpha1.MatrixGenerator{
+			Generators: []v1alpha1.BaseGenerator{
+				{Clusters: &v1alpha1.ClusterGenerator{Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"env": "prod"}}}},
+				{Git: &v1alpha1.GitGenerator{
+					Repository:  corev1.LocalObjectReference{Name: "apps"},
+					Revision:    "main",
+					Directories: []v1alpha1.GitDirectory{{Path: "apps/*"}},
+				}},
+			},
+		}})
+		generator.Spec.Template.Metadata.Name = "{{path.basenameNormalized}}-{{name}}"
+		generator.Spec.Template.Spec = fluxApp.Spec
+		generator.Spec.Interval = &metav1.Duration{Duration: time.Minute}
+
+		cluster := func(name, env string) *unstructured.Unstructured {
+			cluster := newCluster(name, "https://"+name, map[string]string{"env": env})
+			return &cluster
+		}
+		// the cluster other is not placed in the workspace
+		objects := append(newWorkspace("host", "member", "dev"), generator,
+			cluster("host", "prod"), cluster("member", "prod"), cluster("dev", "dev"), cluster("other", "prod"))
+		r, service := newReconciler(true, objects...)
+		service.files["apps/web/values.yaml"] = nil
+		service.files["apps/My_API/values.yaml"] = nil
+
+		generator, result := reconcileAndGet(t, r)
+		assert.Equal(t, time.Minute, result.RequeueAfter)
+		assert.Empty(t, generator.Status.Message)
+		assert.Equal(t, []string{"my-api-host", "my-api-member", "web-host", "web-member"}, generator.Status.Applications)
+		assert.Equal(t, []int{4}, generator.Status.ParameterSets)
+		assert.Equal(t, []types.NamespacedName{{Namespace: "ns", Name: "apps"}}, service.repos)
+		app := listApps(t, r)["web-member"]
+		if assert.NotNil(t, app) {
+			deploy = app.Spec.FluxApp.Spec.Config.HelmRelease.Deploy[0]
+			assert.Equal(t, "member", deploy.Destination.KubeConfig.SecretRef.Name)
+			assert.Equal(t, "web", deploy.Destination.TargetNamespace)
+			assert.NotContains(t, app.Labels, v1alpha1.ArgoCDLocationLabelKey)
+		}
+	})
+
+	t.Run("the applications are kept if the clusters are gone", func(t *testing.T) {
+		generator := newApplicationGenerator(v1alpha1.Generator{
+			BaseGenerator: v1alpha1.BaseGenerator{Clusters: &v1alpha1.ClusterGenerator{}}})
+		host := newCluster("host", "https://host", nil)
+		r, _ := newReconciler(true, append(newWorkspace("host"), generator, &host)...)
+		generator, _ = reconcileAndGet(t, r)
+		assert.Equal(t, []string{"web-host"}, generator.Status.Applications)
+
+		assert.Nil(t, r.Delete(context.Background(), &host))
+		generator, result := reconcileAndGet(t, r)
+		assert.Equal(t, v1alpha1.DefaultGenerateInterval, result.RequeueAfter)
+		assert.Equal(t, "generator 0 got no parameter set but got 1 last time, remove the generator to prune its applications",
+			generator.Status.Message)
+		assert.Equal(t, []string{"web-host"}, generator.Status.Applications)
+		assert.Len(t, listApps(t, r), 1)
+
+		// the applications are pruned without the generator
+		generator.Spec.Generators = []v1alpha1.Generator{newListGenerator(map[string]string{"name": "member"})}
+		assert.Nil(t, r.Update(context.Background(), generator))
+		generator, _ = reconcileAndGet(t, r)
+		assert.Empty(t, generator.Status.Message)
+		assert.Equal(t, []string{"web-member"}, generator.Status.Applications)
+		assert.Len(t, listApps(t, r), 1)
+	})
+
+	t.Run("no cluster is generated if the clusters are not available", func(t *testing.T) {
+		newGenerator := func() *v1alpha1.ApplicationGenerator {
+			return newApplicationGenerator(v1alpha1.Generator{
+				BaseGenerator: v1alpha1.BaseGenerator{Clusters: &v1alpha1.ClusterGenerator{}}})
+		}
+		host := newCluster("host", "https://host", nil)
+
+		// the multi-cluster is not enabled
+		r, _ := newReconciler(false, append(newWorkspace("host"), newGenerator())...)
+		r.Client = interceptor.NewClient(r.Client.(client.WithWatch), interceptor.Funcs{
+			List: func(ctx context.Context, c client.WithWatch, list client.ObjectList, opts ...client.ListOption) error {
+				if list.GetObjectKind().GroupVersionKind().Kind == "ClusterList" {
+					return &meta.NoKindMatchError{GroupKind: clusterGVK.GroupKind()}
+				}
+				return c.List(ctx, list, opts...)
+			},
+		})
+		generator, result := reconcileAndGet(t, r)
+		assert.Equal(t, v1alpha1.DefaultGenerateInterval, result.RequeueAfter)
+		assert.Contains(t, generator.Status.Message, "failed to list the clusters")
+
+		r, _ = newReconciler(true, newGenerator(), &host, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "ns"}})
+		generator, _ = reconcileAndGet(t, r)
+		assert.Equal(t, "namespace ns doesn't belong to any workspace", generator.Status.Message)
+
+		r, _ = newReconciler(true, append(newWorkspace(), newGenerator(), &host)...)
+		generator, _ = reconcileAndGet(t, r)
+		assert.Empty(t, generator.Status.Message)
+		assert.Empty(t, generator.Status.Applications)
+	})
+
+	t.Run("invalid generators", func(t *testing.T) {
+		r, _ := newReconciler(false, newApplicationGenerator(v1alpha1.Generator{}))
+		generator, _ := reconcileAndGet(t, r)
+		assert.Equal(t, "no generator is set", generator.Status.Message)
+
+		both := newListGenerator(map[string]string{"name": "host"})
+		both.Matrix = &v1alpha1.MatrixGenerator{}
+		r, _ = newReconciler(false, newApplicationGenerator(both))
+		generator, _ = reconcileAndGet(t, r)
+		assert.Equal(t, "only one generator should be set", generator.Status.Message)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		r, _ := newReconciler(false)
+		_, err := r.Reconcile(context.Background(), reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "ns", Name: "web"}})
+		assert.Nil(t, err)
+	})
+}
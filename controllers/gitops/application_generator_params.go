@@ -0,0 +1,245 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gitops
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/kubesphere/ks-devops/pkg/api/gitops/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// paramPattern matches the parameters like {{name}} or {{ path.basename }}
+var paramPattern = regexp.MustCompile(`{{\s*([^{}\s]+)\s*}}`)
+
+// invalidNameChars matches the characters which are not allowed in a DNS-1123 label
+var invalidNameChars = regexp.MustCompile(`[^a-z0-9]+`)
+
+// listParams returns a copy of the elements of a list generator
+func listParams(generator *v1alpha1.ListGenerator) (result []map[string]string) {
+	for _, element := range generator.Elements {
+		result = append(result, mergeParams(element))
+	}
+	return
+}
+
+// placedClusters returns the clusters in the placement of a WorkspaceTemplate, the clusterSelector is ignored if the
+// clusters are listed
+func placedClusters(clusters []unstructured.Unstructured, workspace *unstructured.Unstructured) (
+	result []unstructured.Unstructured, err error) {
+	refs, _, _ := unstructured.NestedSlice(workspace.Object, "spec", "placement", "clusters")
+	names := map[string]bool{}
+	for _, ref := range refs {
+		if ref, ok := ref.(map[string]interface{}); ok {
+			name, _, _ := unstructured.NestedString(ref, "name")
+			names[name] = true
+		}
+	}
+
+	selector := labels.Nothing()
+	if rawSelector, ok, _ := unstructured.NestedMap(workspace.Object, "spec", "placement", "clusterSelector"); ok && len(names) == 0 {
+		clusterSelector := &metav1.LabelSelector{}
+		if err = runtime.DefaultUnstructuredConverter.FromUnstructured(rawSelector, clusterSelector); err == nil {
+			selector, err = metav1.LabelSelectorAsSelector(clusterSelector)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid cluster selector of workspace %s: %v", workspace.GetName(), err)
+		}
+	}
+	for _, cluster := range clusters {
+		if names[cluster.GetName()] || selector.Matches(labels.Set(cluster.GetLabels())) {
+			result = append(result, cluster)
+		}
+	}
+	return
+}
+
+// clusterParams returns the parameters of the clusters selected by a cluster generator
+func clusterParams(clusters []unstructured.Unstructured, generator *v1alpha1.ClusterGenerator) (result []map[string]string, err error) {
+	selector := labels.Everything()
+	if generator.Selector != nil {
+		if selector, err = metav1.LabelSelectorAsSelector(generator.Selector); err != nil {
+			return nil, fmt.Errorf("invalid cluster selector: %v", err)
+		}
+	}
+	for _, cluster := range clusters {
+		if !selector.Matches(labels.Set(cluster.GetLabels())) {
+			continue
+		}
+		params := map[string]string{"name": cluster.GetName()}
+		params["server"], _, _ = unstructured.NestedString(cluster.Object, "spec", "connection", "kubernetesAPIEndpoint")
+		for key, val := range cluster.GetLabels() {
+			params["metadata.labels."+key] = val
+		}
+		result = append(result, mergeParams(params, generator.Values))
+	}
+	return
+}
+
+// gitParams returns the parameters of the directories selected by a Git generator
+func gitParams(files map[string][]byte, generator *v1alpha1.GitGenerator) (result []map[string]string, err error) {
+	var dirs []string
+	if dirs, err = matchDirectories(listDirectories(files), generator.Directories); err != nil {
+		return
+	}
+	for _, dir := range dirs {
+		params := map[string]string{
+			"path":                    dir,
+			"path.basename":           path.Base(dir),
+			"path.basenameNormalized": normalizeName(path.Base(dir)),
+		}
+		result = append(result, mergeParams(params, generator.Values))
+	}
+	return
+}
+
+// listDirectories returns the sorted directories which contain the files, the root directory is not included
+func listDirectories(files map[string][]byte) []string {
+	dirs := map[string]bool{}
+	for name := range files {
+		for dir := path.Dir(strings.TrimPrefix(name, "/")); dir != "." && dir != "/" && !dirs[dir]; dir = path.Dir(dir) {
+			dirs[dir] = true
+		}
+	}
+	result := make([]string, 0, len(dirs))
+	for dir := range dirs {
+		result = append(result, dir)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// matchDirectories returns the directories which are matched by any pattern without exclude, and not matched by
+// any pattern with exclude
+func matchDirectories(dirs []string, patterns []v1alpha1.GitDirectory) (result []string, err error) {
+	for _, dir := range dirs {
+		included, excluded := false, false
+		for _, pattern := range patterns {
+			var matched bool
+			if matched, err = path.Match(strings.Trim(pattern.Path, "/"), dir); err != nil {
+				return nil, fmt.Errorf("invalid directory pattern %q: %v", pattern.Path, err)
+			}
+			if matched && pattern.Exclude {
+				excluded = true
+			} else if matched {
+				included = true
+			}
+		}
+		if included && !excluded {
+			result = append(result, dir)
+		}
+	}
+	return
+}
+
+// matrixParams returns the cartesian product of the parameter sets, the latter parameters override the former ones
+func matrixParams(paramSets ...[]map[string]string) []map[string]string {
+	result := []map[string]string{{}}
+	for _, params := range paramSets {
+		var product []map[string]string
+		for _, former := range result {
+			for _, latter := range params {
+				product = append(product, mergeParams(former, latter))
+			}
+		}
+		result = product
+	}
+	return result
+}
+
+// mergeParams merges the parameters into a new map, the latter parameters override the former ones
+func mergeParams(params ...map[string]string) map[string]string {
+	result := map[string]string{}
+	for _, item := range params {
+		for key, val := range item {
+			result[key] = val
+		}
+	}
+	return result
+}
+
+// normalizeName converts a string to a valid DNS-1123 label, like apps/My_App to apps-my-app
+func normalizeName(name string) string {
+	return strings.Trim(invalidNameChars.ReplaceAllString(strings.ToLower(name), "-"), "-")
+}
+
+// renderTemplate replaces the parameters in the strings of the template with a parameter set
+func renderTemplate(template *v1alpha1.ApplicationTemplate, params map[string]string) (app *v1alpha1.Application, err error) {
+	var data []byte
+	if data, err = json.Marshal(template); err != nil {
+		return
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	// keep the numbers as they are
+	decoder.UseNumber()
+	var obj interface{}
+	if err = decoder.Decode(&obj); err != nil {
+		return
+	}
+	if data, err = json.Marshal(renderValue(obj, params)); err != nil {
+		return
+	}
+
+	rendered := &v1alpha1.ApplicationTemplate{}
+	if err = json.Unmarshal(data, rendered); err != nil {
+		return
+	}
+	app = &v1alpha1.Application{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        rendered.Metadata.Name,
+			Labels:      rendered.Metadata.Labels,
+			Annotations: rendered.Metadata.Annotations,
+		},
+		Spec: rendered.Spec,
+	}
+	return
+}
+
+func renderValue(value interface{}, params map[string]string) interface{} {
+	switch val := value.(type) {
+	case string:
+		return renderString(val, params)
+	case map[string]interface{}:
+		for key, item := range val {
+			val[key] = renderValue(item, params)
+		}
+	case []interface{}:
+		for i, item := range val {
+			val[i] = renderValue(item, params)
+		}
+	}
+	return value
+}
+
+// renderString replaces the known parameters, the unknown ones like {{ .Values.name }} are kept as they are
+func renderString(text string, params map[string]string) string {
+	return paramPattern.ReplaceAllStringFunc(text, func(param string) string {
+		if val, ok := params[paramPattern.FindStringSubmatch(param)[1]]; ok {
+			return val
+		}
+		return param
+	})
+}
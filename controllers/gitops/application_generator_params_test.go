@@ -0,0 +1,228 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gitops
+
+import (
+	"testing"
+	"time"
+
+	"github.com/kubesphere/ks-devops/pkg/api/gitops/v1alpha1"
+	helmv2 "github.com/kubesphere/ks-devops/pkg/external/fluxcd/helm/v2beta1"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/utils/ptr"
+)
+
+func newCluster(name, server string, labels map[string]string) unstructured.Unstructured {
+	cluster := unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "cluster.kubesphere.io/v1alpha1",
+		"kind":       "Cluster",
+		"spec": map[string]interface{}{
+			"connection": map[string]interface{}{"kubernetesAPIEndpoint": server},
+		},
+	}}
+	cluster.SetName(name)
+	cluster.SetLabels(labels)
+	return cluster
+}
+
+func TestPlacedClusters(t *testing.T) {
+	clusters := []unstructured.Unstructured{
+		newCluster("host", "https://host:6443", map[string]string{"env": "prod"}),
+		newCluster("member", "https://member:6443", map[string]string{"env": "dev"}),
+	}
+	newWorkspace := func(placement map[string]interface{}) *unstructured.Unstructured {
+		workspace := &unstructured.Unstructured{Object: map[string]interface{}{}}
+		workspace.SetName("ws")
+		if placement != nil {
+			workspace.Object["spec"] = map[string]interface{}{"placement": placement}
+		}
+		return workspace
+	}
+	names := func(clusters []unstructured.Unstructured) (result []string) {
+		for _, cluster := range clusters {
+			result = append(result, cluster.GetName())
+		}
+		return
+	}
+
+	tests := []struct {
+		name      string
+		placement map[string]interface{}
+		expected  []string
+		wantErr   bool
+	}{{
+		name: "no placement",
+	}, {
+		name:      "clusters",
+		placement: map[string]interface{}{"clusters": []interface{}{map[string]interface{}{"name": "member"}}},
+		expected:  []string{"member"},
+	}, {
+		name: "the cluster selector is ignored if the clusters are listed",
+		placement: map[string]interface{}{
+			"clusters":        []interface{}{map[string]interface{}{"name": "member"}},
+			"clusterSelector": map[string]interface{}{},
+		},
+		expected: []string{"member"},
+	}, {
+		name:      "empty cluster selector",
+		placement: map[string]interface{}{"clusterSelector": map[string]interface{}{}},
+		expected:  []string{"host", "member"},
+	}, {
+		name: "cluster selector",
+		placement: map[string]interface{}{"clusterSelector": map[string]interface{}{
+			"matchLabels": map[string]interface{}{"env": "prod"}}},
+		expected: []string{"host"},
+	}, {
+		name: "invalid cluster selector",
+		placement: map[string]interface{}{"clusterSelector": map[string]interface{}{
+			"matchExpressions": []interface{}{map[string]interface{}{"key": "env", "operator": "Invalid"}}}},
+		wantErr: true,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := placedClusters(clusters, newWorkspace(tt.placement))
+			assert.Equal(t, tt.wantErr, err != nil, err)
+			assert.Equal(t, tt.expected, names(result))
+		})
+	}
+}
+
+func TestClusterParams(t *testing.T) {
+	clusters := []unstructured.Unstructured{
+		newCluster("host", "https://host:6443", map[string]string{"env": "prod"}),
+		newCluster("member", "https://member:6443", map[string]string{"env": "dev"}),
+	}
+
+	params, err := clusterParams(clusters, &v1alpha1.ClusterGenerator{Values: map[string]string{"team": "web"}})
+	assert.Nil(t, err)
+	assert.Equal(t, []map[string]string{
+		{"name": "host", "server": "https://host:6443", "metadata.labels.env": "prod", "team": "web"},
+		{"name": "member", "server": "https://member:6443", "metadata.labels.env": "dev", "team": "web"},
+	}, params)
+
+	params, err = clusterParams(clusters, &v1alpha1.ClusterGenerator{
+		Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"env": "dev"}}})
+	assert.Nil(t, err)
+	assert.Equal(t, []map[string]string{{"name": "member", "server": "https://member:6443", "metadata.labels.env": "dev"}}, params)
+
+	_, err = clusterParams(clusters, &v1alpha1.ClusterGenerator{Selector: &metav1.LabelSelector{
+		MatchExpressions: []metav1.LabelSelectorRequirement{{Key: "env", Operator: "Invalid"}}}})
+	assert.NotNil(t, err)
+}
+
+func TestGitParams(t *testing.T) {
+	files := map[string][]byte{
+		"README.md":                    nil,
+		"apps/web/kustomization.yaml":  nil,
+		"apps/web/base/service.yaml":   nil,
+		"apps/My_API/deployment.yaml":  nil,
+		"apps/legacy/deployment.yaml":  nil,
+		"infra/redis/deployment.yaml":  nil,
+		"infra/redis/values/prod.yaml": nil,
+	}
+	assert.Equal(t, []string{"apps", "apps/My_API", "apps/legacy", "apps/web", "apps/web/base", "infra", "infra/redis",
+		"infra/redis/values"}, listDirectories(files))
+
+	params, err := gitParams(files, &v1alpha1.GitGenerator{
+		Directories: []v1alpha1.GitDirectory{{Path: "apps/*"}, {Path: "/infra/*/"}, {Path: "apps/legacy", Exclude: true}},
+		Values:      map[string]string{"path": "overridden", "cluster": "host"},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, []map[string]string{
+		{"path": "overridden", "path.basename": "My_API", "path.basenameNormalized": "my-api", "cluster": "host"},
+		{"path": "overridden", "path.basename": "web", "path.basenameNormalized": "web", "cluster": "host"},
+		{"path": "overridden", "path.basename": "redis", "path.basenameNormalized": "redis", "cluster": "host"},
+	}, params)
+
+	params, err = gitParams(files, &v1alpha1.GitGenerator{Directories: []v1alpha1.GitDirectory{{Path: "infra/redis"}}})
+	assert.Nil(t, err)
+	assert.Equal(t, []map[string]string{{"path": "infra/redis", "path.basename": "redis", "path.basenameNormalized": "redis"}}, params)
+
+	_, err = gitParams(files, &v1alpha1.GitGenerator{Directories: []v1alpha1.GitDirectory{{Path: "apps/["}}})
+	assert.NotNil(t, err)
+}
+
+func TestMatrixParams(t *testing.T) {
+	assert.Equal(t, []map[string]string{
+		{"name": "host", "path": "web"},
+		{"name": "host", "path": "api"},
+		{"name": "member", "path": "web"},
+		{"name": "member", "path": "api"},
+	}, matrixParams(
+		[]map[string]string{{"name": "host"}, {"name": "member"}},
+		[]map[string]string{{"path": "web"}, {"path": "api"}},
+	))
+	assert.Equal(t, []map[string]string{{"name": "b"}}, matrixParams(
+		[]map[string]string{{"name": "a"}}, []map[string]string{{"name": "b"}}))
+	assert.Nil(t, matrixParams([]map[string]string{{"name": "a"}}, nil))
+}
+
+func TestNormalizeName(t *testing.T) {
+	assert.Equal(t, "web", normalizeName("web"))
+	assert.Equal(t, "my-api-v2", normalizeName("_My_API.v2_"))
+}
+
+func TestRenderTemplate(t *testing.T) {
+	argoApp := newArgoApp("")
+	argoApp.Spec.ArgoApp.Spec.Source.Path = "apps/{{path.basename}}"
+	argoApp.Spec.ArgoApp.Spec.Source.Helm = &v1alpha1.ApplicationSourceHelm{Values: "name: {{ .Values.name }}"}
+	argoApp.Spec.ArgoApp.Spec.Destination.Name = "{{ name }}"
+	argoApp.Spec.ArgoApp.Spec.RevisionHistoryLimit = ptr.To[int64](9007199254740993)
+	template := &v1alpha1.ApplicationTemplate{
+		Metadata: v1alpha1.ApplicationTemplateMeta{
+			Name:        "{{path.basename}}-{{name}}",
+			Labels:      map[string]string{"cluster": "{{name}}"},
+			Annotations: map[string]string{"unknown": "{{unknown}}"},
+		},
+		Spec: argoApp.Spec,
+	}
+	params := map[string]string{"name": "host", "path.basename": "web"}
+
+	app, err := renderTemplate(template, params)
+	assert.Nil(t, err)
+	assert.Equal(t, "web-host", app.Name)
+	assert.Equal(t, map[string]string{"cluster": "host"}, app.Labels)
+	assert.Equal(t, map[string]string{"unknown": "{{unknown}}"}, app.Annotations)
+	assert.Equal(t, "apps/web", app.Spec.ArgoApp.Spec.Source.Path)
+	assert.Equal(t, "name: {{ .Values.name }}", app.Spec.ArgoApp.Spec.Source.Helm.Values)
+	assert.Equal(t, "host", app.Spec.ArgoApp.Spec.Destination.Name)
+	assert.Equal(t, int64(9007199254740993), *app.Spec.ArgoApp.Spec.RevisionHistoryLimit)
+	// the template is not changed
+	assert.Equal(t, "{{ name }}", template.Spec.ArgoApp.Spec.Destination.Name)
+
+	fluxApp := newFluxApp("", `{"cluster":"{{name}}","replicas":2}`)
+	fluxApp.Spec.FluxApp.Spec.Config.HelmRelease.Deploy[0].Interval = metav1.Duration{Duration: time.Minute}
+	fluxApp.Spec.FluxApp.Spec.Config.HelmRelease.Deploy[0].Destination.KubeConfig = &helmv2.KubeConfig{}
+	fluxApp.Spec.FluxApp.Spec.Config.HelmRelease.Deploy[0].Destination.KubeConfig.SecretRef.Name = "{{name}}"
+	fluxApp.Spec.FluxApp.Spec.Config.Kustomization[0].Path = "./{{path}}"
+	template = &v1alpha1.ApplicationTemplate{
+		Metadata: v1alpha1.ApplicationTemplateMeta{Name: "web-{{name}}"},
+		Spec:     fluxApp.Spec,
+	}
+	params["path"] = "apps/web"
+
+	app, err = renderTemplate(template, params)
+	assert.Nil(t, err)
+	assert.Equal(t, "web-host", app.Name)
+	deploy := app.Spec.FluxApp.Spec.Config.HelmRelease.Deploy[0]
+	assert.Equal(t, "host", deploy.Destination.KubeConfig.SecretRef.Name)
+	assert.Equal(t, time.Minute, deploy.Interval.Duration)
+	assert.JSONEq(t, `{"cluster":"host","replicas":2}`, string(deploy.Values.Raw))
+	assert.Equal(t, "./apps/web", app.Spec.FluxApp.Spec.Config.Kustomization[0].Path)
+}
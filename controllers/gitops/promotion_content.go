@@ -0,0 +1,481 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gitops
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/kubesphere/ks-devops/pkg/api/gitops/v1alpha1"
+	kusv1 "github.com/kubesphere/ks-devops/pkg/external/fluxcd/kustomize/v1beta2"
+	"github.com/kubesphere/ks-devops/pkg/utils/sliceutil"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// kustomizationFileNames are the file names which Kustomize recognizes
+var kustomizationFileNames = []string{"kustomization.yaml", "kustomization.yml", "Kustomization"}
+
+// getRevision returns the revision of an Application. It's the deployed revision if deployed is true, or the target
+// revision in the spec. An empty string is returned if the revision can't be promoted.
+func getRevision(app *v1alpha1.Application, deployed bool) string {
+	switch {
+	case app.Spec.ArgoApp != nil:
+		if deployed {
+			return app.Status.Revision
+		}
+		return app.Spec.ArgoApp.Spec.Source.TargetRevision
+	case isHelmRepositoryRelease(app):
+		if deployed {
+			// the revision of a HelmRelease might have the build metadata like "0.1.0+1"
+			return strings.SplitN(app.Status.Revision, "+", 2)[0]
+		}
+		return app.Spec.FluxApp.Spec.Config.HelmRelease.Chart.Version
+	}
+	return ""
+}
+
+// setRevision sets the target revision of an Application, false is returned if the revision can't be promoted
+func setRevision(app *v1alpha1.Application, revision string) bool {
+	switch {
+	case app.Spec.ArgoApp != nil:
+		app.Spec.ArgoApp.Spec.Source.TargetRevision = revision
+	case isHelmRepositoryRelease(app):
+		app.Spec.FluxApp.Spec.Config.HelmRelease.Chart.Version = revision
+	default:
+		return false
+	}
+	return true
+}
+
+// isHelmRepositoryRelease returns true if an Application is a FluxCD HelmRelease from a HelmRepository. The chart
+// version is ignored by FluxCD for the charts from a GitRepository or Bucket.
+func isHelmRepositoryRelease(app *v1alpha1.Application) bool {
+	fluxApp := app.Spec.FluxApp
+	if fluxApp == nil || fluxApp.Spec.Source == nil || fluxApp.Spec.Source.SourceRef.Kind != "HelmRepository" {
+		return false
+	}
+	config := fluxApp.Spec.Config
+	return config != nil && config.HelmRelease != nil && config.HelmRelease.Chart != nil
+}
+
+// getHelmValues returns the Helm values of an Application by the paths, the missing ones are skipped
+func getHelmValues(app *v1alpha1.Application, paths []string) (result map[string]string, err error) {
+	result = map[string]string{}
+	switch {
+	case app.Spec.ArgoApp != nil:
+		if helm := app.Spec.ArgoApp.Spec.Source.Helm; helm != nil {
+			for _, param := range helm.Parameters {
+				if sliceutil.HasString(paths, param.Name) {
+					result[param.Name] = param.Value
+				}
+			}
+		}
+	case app.Spec.FluxApp != nil:
+		config := app.Spec.FluxApp.Spec.Config
+		if config == nil || config.HelmRelease == nil || len(config.HelmRelease.Deploy) == 0 {
+			break
+		}
+		var values map[string]interface{}
+		if values, err = unmarshalValues(config.HelmRelease.Deploy[0].Values); err == nil {
+			result = lookupValues(values, paths)
+		}
+	}
+	return
+}
+
+// setHelmValues sets the Helm values of an Application. They are the Helm parameters of an Argo CD Application, or
+// the values of all the deployments of a FluxCD HelmRelease.
+func setHelmValues(app *v1alpha1.Application, helmValues map[string]string) error {
+	if len(helmValues) == 0 {
+		return nil
+	}
+	switch {
+	case app.Spec.ArgoApp != nil:
+		source := &app.Spec.ArgoApp.Spec.Source
+		if source.Helm == nil {
+			source.Helm = &v1alpha1.ApplicationSourceHelm{}
+		}
+		for _, name := range sortedKeys(helmValues) {
+			found := false
+			for i := range source.Helm.Parameters {
+				if source.Helm.Parameters[i].Name == name {
+					source.Helm.Parameters[i].Value = helmValues[name]
+					found = true
+				}
+			}
+			if !found {
+				source.Helm.Parameters = append(source.Helm.Parameters, v1alpha1.HelmParameter{Name: name, Value: helmValues[name]})
+			}
+		}
+	case app.Spec.FluxApp != nil:
+		config := app.Spec.FluxApp.Spec.Config
+		if config == nil || config.HelmRelease == nil || len(config.HelmRelease.Deploy) == 0 {
+			return fmt.Errorf("application %s has no HelmRelease", app.Name)
+		}
+		for _, deploy := range config.HelmRelease.Deploy {
+			values, err := unmarshalValues(deploy.Values)
+			if err != nil {
+				return err
+			}
+			if err = setValues(values, helmValues); err != nil {
+				return err
+			}
+			var data []byte
+			if data, err = json.Marshal(values); err != nil {
+				return err
+			}
+			deploy.Values = &apiextensionsv1.JSON{Raw: data}
+		}
+	default:
+		return fmt.Errorf("application %s has no Helm values", app.Name)
+	}
+	return nil
+}
+
+// getKustomizeImages returns the Kustomize images of an Application by the names, the missing ones are skipped
+func getKustomizeImages(app *v1alpha1.Application, names []string) map[string]string {
+	result := map[string]string{}
+	switch {
+	case app.Spec.ArgoApp != nil:
+		if kustomize := app.Spec.ArgoApp.Spec.Source.Kustomize; kustomize != nil {
+			for _, image := range kustomize.Images {
+				if name, override := parseArgoImage(string(image)); sliceutil.HasString(names, name) {
+					result[name] = override
+				}
+			}
+		}
+	case app.Spec.FluxApp != nil:
+		config := app.Spec.FluxApp.Spec.Config
+		if config == nil || len(config.Kustomization) == 0 {
+			break
+		}
+		for _, image := range config.Kustomization[0].Images {
+			if sliceutil.HasString(names, image.Name) {
+				result[image.Name] = formatImage(image)
+			}
+		}
+	}
+	return result
+}
+
+// setKustomizeImages sets the Kustomize images of an Application. They are the images of an Argo CD Application, or
+// the images of all the FluxCD Kustomizations.
+func setKustomizeImages(app *v1alpha1.Application, images map[string]string) error {
+	if len(images) == 0 {
+		return nil
+	}
+	switch {
+	case app.Spec.ArgoApp != nil:
+		source := &app.Spec.ArgoApp.Spec.Source
+		if source.Kustomize == nil {
+			source.Kustomize = &v1alpha1.ApplicationSourceKustomize{}
+		}
+		for _, name := range sortedKeys(images) {
+			image := v1alpha1.KustomizeImage(name + "=" + images[name])
+			found := false
+			for i := range source.Kustomize.Images {
+				if existing, _ := parseArgoImage(string(source.Kustomize.Images[i])); existing == name {
+					source.Kustomize.Images[i] = image
+					found = true
+				}
+			}
+			if !found {
+				source.Kustomize.Images = append(source.Kustomize.Images, image)
+			}
+		}
+	case app.Spec.FluxApp != nil:
+		config := app.Spec.FluxApp.Spec.Config
+		if config == nil || len(config.Kustomization) == 0 {
+			return fmt.Errorf("application %s has no Kustomization", app.Name)
+		}
+		for _, kustomization := range config.Kustomization {
+			kustomization.Images = mergeImages(kustomization.Images, images)
+		}
+	default:
+		return fmt.Errorf("application %s has no Kustomize images", app.Name)
+	}
+	return nil
+}
+
+// getGitContent returns the Helm values and the Kustomize images from the files of a Git target
+func getGitContent(files map[string][]byte, target *v1alpha1.PromotionGitTarget, promote *v1alpha1.PromotionTarget,
+	content *v1alpha1.PromotionContent) error {
+	if len(promote.HelmValues) > 0 {
+		if data, ok := files[getValuesFile(target)]; ok {
+			values := map[string]interface{}{}
+			if err := yaml.Unmarshal(data, &values); err != nil {
+				return fmt.Errorf("failed to parse %s: %v", getValuesFile(target), err)
+			}
+			content.HelmValues = lookupValues(values, promote.HelmValues)
+		}
+	}
+	if len(promote.KustomizeImages) > 0 {
+		if name := findKustomization(files, target.Path); name != "" {
+			kustomization := &struct {
+				Images []kusv1.Image `json:"images,omitempty"`
+			}{}
+			if err := yaml.Unmarshal(files[name], kustomization); err != nil {
+				return fmt.Errorf("failed to parse %s: %v", name, err)
+			}
+			content.KustomizeImages = map[string]string{}
+			for _, image := range kustomization.Images {
+				if sliceutil.HasString(promote.KustomizeImages, image.Name) {
+					content.KustomizeImages[image.Name] = formatImage(image)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// setGitContent writes the Helm values and the Kustomize images into the files of a Git target, the changed files
+// are returned
+func setGitContent(files map[string][]byte, target *v1alpha1.PromotionGitTarget,
+	content *v1alpha1.PromotionContent) (changed map[string][]byte, err error) {
+	changed = map[string][]byte{}
+	if len(content.HelmValues) > 0 {
+		name := getValuesFile(target)
+		values := map[string]interface{}{}
+		if err = yaml.Unmarshal(files[name], &values); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %v", name, err)
+		}
+		if values == nil {
+			values = map[string]interface{}{}
+		}
+		if err = setValues(values, content.HelmValues); err != nil {
+			return
+		}
+		if changed[name], err = yaml.Marshal(values); err != nil {
+			return
+		}
+	}
+	if len(content.KustomizeImages) > 0 {
+		name := findKustomization(files, target.Path)
+		if name == "" {
+			return nil, fmt.Errorf("no kustomization file found in %q", target.Path)
+		}
+		kustomization := map[string]interface{}{}
+		if err = yaml.Unmarshal(files[name], &kustomization); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %v", name, err)
+		}
+		var images []kusv1.Image
+		if data, ok := kustomization["images"]; ok {
+			var raw []byte
+			if raw, err = json.Marshal(data); err == nil {
+				err = json.Unmarshal(raw, &images)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse the images of %s: %v", name, err)
+			}
+		}
+		kustomization["images"] = mergeImages(images, content.KustomizeImages)
+		if changed[name], err = yaml.Marshal(kustomization); err != nil {
+			return
+		}
+	}
+	for name, data := range changed {
+		if yamlEqual(files[name], data) {
+			delete(changed, name)
+		}
+	}
+	return
+}
+
+// yamlEqual returns true if the YAML documents have the same content, the order of the keys is ignored
+func yamlEqual(a, b []byte) bool {
+	var objA, objB interface{}
+	if yaml.Unmarshal(a, &objA) != nil || yaml.Unmarshal(b, &objB) != nil {
+		return false
+	}
+	return reflect.DeepEqual(objA, objB)
+}
+
+func getValuesFile(target *v1alpha1.PromotionGitTarget) string {
+	valuesFile := target.ValuesFile
+	if valuesFile == "" {
+		valuesFile = "values.yaml"
+	}
+	return cleanPath(path.Join(target.Path, valuesFile))
+}
+
+func findKustomization(files map[string][]byte, dir string) string {
+	for _, name := range kustomizationFileNames {
+		if file := cleanPath(path.Join(dir, name)); files[file] != nil {
+			return file
+		}
+	}
+	return ""
+}
+
+func cleanPath(name string) string {
+	return strings.TrimPrefix(path.Clean("/"+name), "/")
+}
+
+func unmarshalValues(raw *apiextensionsv1.JSON) (values map[string]interface{}, err error) {
+	values = map[string]interface{}{}
+	if raw != nil && len(raw.Raw) > 0 {
+		err = json.Unmarshal(raw.Raw, &values)
+	}
+	return
+}
+
+// lookupValues returns the values by the paths, the values which are not strings are formatted in JSON
+func lookupValues(values map[string]interface{}, paths []string) map[string]string {
+	result := map[string]string{}
+	for _, valuePath := range paths {
+		var current interface{} = values
+		for _, key := range splitValuePath(valuePath) {
+			container, ok := current.(map[string]interface{})
+			if !ok {
+				current = nil
+				break
+			}
+			if current, ok = container[key]; !ok {
+				break
+			}
+		}
+		switch val := current.(type) {
+		case nil:
+		case string:
+			result[valuePath] = val
+		default:
+			if data, err := json.Marshal(val); err == nil {
+				result[valuePath] = string(data)
+			}
+		}
+	}
+	return result
+}
+
+// setValues sets the values by the paths, a value is parsed as JSON if possible, or it's a string
+func setValues(values map[string]interface{}, helmValues map[string]string) error {
+	for valuePath, raw := range helmValues {
+		var val interface{} = raw
+		var parsed interface{}
+		if err := json.Unmarshal([]byte(raw), &parsed); err == nil {
+			val = parsed
+		}
+
+		keys := splitValuePath(valuePath)
+		container := values
+		for _, key := range keys[:len(keys)-1] {
+			child, ok := container[key].(map[string]interface{})
+			if !ok {
+				if _, exists := container[key]; exists {
+					return fmt.Errorf("the value of %q is not a map", key)
+				}
+				child = map[string]interface{}{}
+				container[key] = child
+			}
+			container = child
+		}
+		container[keys[len(keys)-1]] = val
+	}
+	return nil
+}
+
+// splitValuePath splits a path like "image.tag" into keys, the dots could be escaped by a backslash
+func splitValuePath(valuePath string) (keys []string) {
+	var key strings.Builder
+	for i := 0; i < len(valuePath); i++ {
+		switch {
+		case valuePath[i] == '\\' && i+1 < len(valuePath):
+			i++
+			key.WriteByte(valuePath[i])
+		case valuePath[i] == '.':
+			keys = append(keys, key.String())
+			key.Reset()
+		default:
+			key.WriteByte(valuePath[i])
+		}
+	}
+	return append(keys, key.String())
+}
+
+// parseArgoImage parses an Argo CD Kustomize image like "[old_image_name=]<image_name>:<image_tag>"
+func parseArgoImage(image string) (name, override string) {
+	name, override = image, image
+	if i := strings.Index(image, "="); i >= 0 {
+		name, override = image[:i], image[i+1:]
+	}
+	name, _, _ = splitImage(name)
+	return
+}
+
+// splitImage splits an image reference into the name, tag and digest
+func splitImage(image string) (name, tag, digest string) {
+	name = image
+	if i := strings.Index(name, "@"); i >= 0 {
+		name, digest = name[:i], name[i+1:]
+	}
+	if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		name, tag = name[:i], name[i+1:]
+	}
+	return
+}
+
+// formatImage returns the image reference which a Kustomize image is replaced with
+func formatImage(image kusv1.Image) string {
+	ref := image.NewName
+	if ref == "" {
+		ref = image.Name
+	}
+	if image.NewTag != "" {
+		ref += ":" + image.NewTag
+	}
+	if image.Digest != "" {
+		ref += "@" + image.Digest
+	}
+	return ref
+}
+
+// mergeImages replaces or appends the Kustomize images by the names
+func mergeImages(images []kusv1.Image, overrides map[string]string) []kusv1.Image {
+	for _, name := range sortedKeys(overrides) {
+		image := kusv1.Image{Name: name}
+		newName, tag, digest := splitImage(overrides[name])
+		if newName != name {
+			image.NewName = newName
+		}
+		image.NewTag, image.Digest = tag, digest
+
+		found := false
+		for i := range images {
+			if images[i].Name == name {
+				images[i] = image
+				found = true
+			}
+		}
+		if !found {
+			images = append(images, image)
+		}
+	}
+	return images
+}
+
+func sortedKeys(m map[string]string) (keys []string) {
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return
+}
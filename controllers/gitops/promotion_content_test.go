@@ -0,0 +1,205 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gitops
+
+import (
+	"testing"
+
+	"github.com/kubesphere/ks-devops/pkg/api/gitops/v1alpha1"
+	helmv2 "github.com/kubesphere/ks-devops/pkg/external/fluxcd/helm/v2beta1"
+	kusv1 "github.com/kubesphere/ks-devops/pkg/external/fluxcd/kustomize/v1beta2"
+	"github.com/stretchr/testify/assert"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+)
+
+func newArgoApp(name string) *v1alpha1.Application {
+	app := &v1alpha1.Application{Spec: v1alpha1.ApplicationSpec{Kind: v1alpha1.ArgoCD, ArgoApp: &v1alpha1.ArgoApplication{}}}
+	app.Name = name
+	app.Spec.ArgoApp.Spec.Source.RepoURL = "https://github.com/example/apps"
+	return app
+}
+
+func newFluxApp(name string, values string, images ...kusv1.Image) *v1alpha1.Application {
+	app := &v1alpha1.Application{Spec: v1alpha1.ApplicationSpec{Kind: v1alpha1.FluxCD, FluxApp: &v1alpha1.FluxApplication{
+		Spec: v1alpha1.FluxApplicationSpec{
+			Source: &v1alpha1.FluxApplicationSource{SourceRef: helmv2.CrossNamespaceObjectReference{Kind: "HelmRepository", Name: "charts"}},
+			Config: &v1alpha1.FluxApplicationConfig{
+				HelmRelease: &v1alpha1.HelmReleaseSpec{
+					Chart: &v1alpha1.HelmChartTemplateSpec{Chart: "web", Version: "0.1.0"},
+					Deploy: []*v1alpha1.Deploy{
+						{Values: &apiextensionsv1.JSON{Raw: []byte(values)}},
+						{Destination: v1alpha1.FluxApplicationDestination{TargetNamespace: "other"}},
+					},
+				},
+				Kustomization: []*v1alpha1.KustomizationSpec{{Images: images}, {}},
+			},
+		},
+	}}}
+	app.Name = name
+	return app
+}
+
+func TestRevision(t *testing.T) {
+	argoApp := newArgoApp("argo")
+	argoApp.Spec.ArgoApp.Spec.Source.TargetRevision = "main"
+	argoApp.Status.Revision = "abc"
+	assert.Equal(t, "abc", getRevision(argoApp, true))
+	assert.Equal(t, "main", getRevision(argoApp, false))
+	assert.True(t, setRevision(argoApp, "def"))
+	assert.Equal(t, "def", argoApp.Spec.ArgoApp.Spec.Source.TargetRevision)
+
+	fluxApp := newFluxApp("flux", "{}")
+	fluxApp.Status.Revision = "0.2.0+1"
+	assert.Equal(t, "0.2.0", getRevision(fluxApp, true))
+	assert.Equal(t, "0.1.0", getRevision(fluxApp, false))
+	assert.True(t, setRevision(fluxApp, "0.2.0"))
+	assert.Equal(t, "0.2.0", fluxApp.Spec.FluxApp.Spec.Config.HelmRelease.Chart.Version)
+
+	// the chart version is ignored for the charts from a GitRepository
+	fluxApp.Spec.FluxApp.Spec.Source.SourceRef.Kind = "GitRepository"
+	assert.Equal(t, "", getRevision(fluxApp, true))
+	assert.False(t, setRevision(fluxApp, "0.3.0"))
+}
+
+func TestHelmValues(t *testing.T) {
+	argoApp := newArgoApp("argo")
+	values, err := getHelmValues(argoApp, []string{"image.tag"})
+	assert.Nil(t, err)
+	assert.Empty(t, values)
+
+	assert.Nil(t, setHelmValues(argoApp, map[string]string{"image.tag": "v1", "replicas": "2"}))
+	assert.Nil(t, setHelmValues(argoApp, map[string]string{"image.tag": "v2"}))
+	assert.Equal(t, []v1alpha1.HelmParameter{{Name: "image.tag", Value: "v2"}, {Name: "replicas", Value: "2"}},
+		argoApp.Spec.ArgoApp.Spec.Source.Helm.Parameters)
+	values, err = getHelmValues(argoApp, []string{"image.tag", "missing"})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{"image.tag": "v2"}, values)
+
+	fluxApp := newFluxApp("flux", `{"image":{"repository":"nginx","tag":"v1"},"replicas":1}`)
+	values, err = getHelmValues(fluxApp, []string{"image.tag", "replicas", "missing.key"})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{"image.tag": "v1", "replicas": "1"}, values)
+
+	assert.Nil(t, setHelmValues(fluxApp, map[string]string{"image.tag": "v2", "replicas": "3", "a\\.b.c": "true"}))
+	for _, deploy := range fluxApp.Spec.FluxApp.Spec.Config.HelmRelease.Deploy {
+		values, err = getHelmValues(&v1alpha1.Application{Spec: v1alpha1.ApplicationSpec{FluxApp: &v1alpha1.FluxApplication{
+			Spec: v1alpha1.FluxApplicationSpec{Config: &v1alpha1.FluxApplicationConfig{
+				HelmRelease: &v1alpha1.HelmReleaseSpec{Deploy: []*v1alpha1.Deploy{deploy}}}}}}}, []string{"image.tag", "replicas", "a\\.b.c"})
+		assert.Nil(t, err)
+		assert.Equal(t, map[string]string{"image.tag": "v2", "replicas": "3", "a\\.b.c": "true"}, values)
+	}
+	assert.Contains(t, string(fluxApp.Spec.FluxApp.Spec.Config.HelmRelease.Deploy[0].Values.Raw), `"repository":"nginx"`)
+
+	fluxApp = newFluxApp("flux", `{"image":"nginx"}`)
+	assert.NotNil(t, setHelmValues(fluxApp, map[string]string{"image.tag": "v2"}))
+	assert.NotNil(t, setHelmValues(&v1alpha1.Application{}, map[string]string{"image.tag": "v2"}))
+}
+
+func TestKustomizeImages(t *testing.T) {
+	argoApp := newArgoApp("argo")
+	argoApp.Spec.ArgoApp.Spec.Source.Kustomize = &v1alpha1.ApplicationSourceKustomize{
+		Images: v1alpha1.KustomizeImages{"nginx=nginx:1.20", "redis:6", "busybox"},
+	}
+	assert.Equal(t, map[string]string{"nginx": "nginx:1.20", "redis": "redis:6"},
+		getKustomizeImages(argoApp, []string{"nginx", "redis"}))
+	assert.Nil(t, setKustomizeImages(argoApp, map[string]string{"nginx": "registry/nginx:1.21", "web": "web@sha256:abc"}))
+	assert.Equal(t, v1alpha1.KustomizeImages{"nginx=registry/nginx:1.21", "redis:6", "busybox", "web=web@sha256:abc"},
+		argoApp.Spec.ArgoApp.Spec.Source.Kustomize.Images)
+
+	fluxApp := newFluxApp("flux", "{}", kusv1.Image{Name: "nginx", NewTag: "1.20"}, kusv1.Image{Name: "redis", NewName: "registry/redis", Digest: "sha256:abc"})
+	assert.Equal(t, map[string]string{"nginx": "nginx:1.20", "redis": "registry/redis@sha256:abc"},
+		getKustomizeImages(fluxApp, []string{"nginx", "redis"}))
+	assert.Nil(t, setKustomizeImages(fluxApp, map[string]string{"nginx": "registry/nginx:1.21"}))
+	for _, kustomization := range fluxApp.Spec.FluxApp.Spec.Config.Kustomization {
+		assert.Contains(t, kustomization.Images, kusv1.Image{Name: "nginx", NewName: "registry/nginx", NewTag: "1.21"})
+	}
+	assert.NotNil(t, setKustomizeImages(&v1alpha1.Application{}, map[string]string{"nginx": "nginx:1.21"}))
+}
+
+func TestGitContent(t *testing.T) {
+	files := map[string][]byte{
+		"envs/prod/kustomization.yaml": []byte(`resources:
+- ../../base
+images:
+- name: nginx
+  newTag: "1.20"
+`),
+		"envs/prod/values.yaml": []byte(`image:
+  tag: v1
+replicas: 2
+`),
+	}
+	target := &v1alpha1.PromotionGitTarget{Path: "envs/prod/"}
+	promote := &v1alpha1.PromotionTarget{HelmValues: []string{"image.tag", "replicas"}, KustomizeImages: []string{"nginx"}}
+
+	content := &v1alpha1.PromotionContent{}
+	assert.Nil(t, getGitContent(files, target, promote, content))
+	assert.Equal(t, &v1alpha1.PromotionContent{
+		HelmValues:      map[string]string{"image.tag": "v1", "replicas": "2"},
+		KustomizeImages: map[string]string{"nginx": "nginx:1.20"},
+	}, content)
+
+	changed, err := setGitContent(files, target, &v1alpha1.PromotionContent{
+		HelmValues:      map[string]string{"image.tag": "v2", "replicas": "2"},
+		KustomizeImages: map[string]string{"nginx": "nginx:1.21", "redis": "redis:6"},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, `image:
+  tag: v2
+replicas: 2
+`, string(changed["envs/prod/values.yaml"]))
+	assert.Equal(t, `images:
+- name: nginx
+  newTag: "1.21"
+- name: redis
+  newTag: "6"
+resources:
+- ../../base
+`, string(changed["envs/prod/kustomization.yaml"]))
+
+	// nothing changed
+	changed, err = setGitContent(files, target, content)
+	assert.Nil(t, err)
+	assert.Empty(t, changed)
+
+	// the values file is created if it doesn't exist
+	changed, err = setGitContent(files, &v1alpha1.PromotionGitTarget{Path: "envs/dev", ValuesFile: "helm/values.yaml"},
+		&v1alpha1.PromotionContent{HelmValues: map[string]string{"image.tag": "v2"}})
+	assert.Nil(t, err)
+	assert.Equal(t, "image:\n  tag: v2\n", string(changed["envs/dev/helm/values.yaml"]))
+
+	_, err = setGitContent(files, &v1alpha1.PromotionGitTarget{Path: "envs/dev"},
+		&v1alpha1.PromotionContent{KustomizeImages: map[string]string{"nginx": "nginx:1.21"}})
+	assert.EqualError(t, err, `no kustomization file found in "envs/dev"`)
+}
+
+func TestSplitImage(t *testing.T) {
+	tests := []struct {
+		image, name, tag, digest string
+	}{
+		{image: "nginx", name: "nginx"},
+		{image: "nginx:1.21", name: "nginx", tag: "1.21"},
+		{image: "registry:5000/nginx", name: "registry:5000/nginx"},
+		{image: "registry:5000/nginx:1.21@sha256:abc", name: "registry:5000/nginx", tag: "1.21", digest: "sha256:abc"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.image, func(t *testing.T) {
+			name, tag, digest := splitImage(tt.image)
+			assert.Equal(t, []string{tt.name, tt.tag, tt.digest}, []string{name, tag, digest})
+		})
+	}
+}
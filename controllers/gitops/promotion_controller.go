@@ -0,0 +1,487 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gitops
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/kubesphere/ks-devops/pkg/api/devops/v1alpha3"
+	"github.com/kubesphere/ks-devops/pkg/api/gitops/v1alpha1"
+	devopsgitops "github.com/kubesphere/ks-devops/pkg/kapis/devops/v1alpha3/gitops"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/retry"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+//+kubebuilder:rbac:groups=gitops.kubesphere.io,resources=promotions,verbs=get;list;watch
+//+kubebuilder:rbac:groups=gitops.kubesphere.io,resources=promotions/status,verbs=get;update
+//+kubebuilder:rbac:groups=gitops.kubesphere.io,resources=applications,verbs=get;list;watch;update
+//+kubebuilder:rbac:groups=devops.kubesphere.io,resources=pipelineruns,verbs=list
+//+kubebuilder:rbac:groups=devops.kubesphere.io,resources=gitrepositories,verbs=get
+//+kubebuilder:rbac:groups="",resources=secrets,verbs=get
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+
+// promotionUser is the user who commits the promoted content when the secret of a GitRepository has no username
+const promotionUser = "promotion-controller"
+
+// waitingRequeuePeriod is the period to check the gates again, the PipelineRun gates are not watched
+const waitingRequeuePeriod = time.Minute
+
+// PromotionReconciler promotes the content of an Application through the environments of a Promotion. The content
+// of an environment is promoted to the next one once it's different and the gates of the next one are passed.
+type PromotionReconciler struct {
+	client.Client
+	RepoFactory devopsgitops.GitRepoFactory
+	log         logr.Logger
+	recorder    record.EventRecorder
+}
+
+// Reconcile promotes the environments of a Promotion in order
+func (r *PromotionReconciler) Reconcile(ctx context.Context, req ctrl.Request) (result ctrl.Result, err error) {
+	r.log.V(6).Info(fmt.Sprintf("start to reconcile promotion: %s", req.String()))
+
+	promotion := &v1alpha1.Promotion{}
+	if err = r.Get(ctx, req.NamespacedName, promotion); err != nil {
+		err = client.IgnoreNotFound(err)
+		return
+	}
+	if promotion.Spec.Suspend || !promotion.DeletionTimestamp.IsZero() {
+		return
+	}
+
+	status := promotion.Status.DeepCopy()
+	waiting := false
+	for i := 1; i < len(promotion.Spec.Environments); i++ {
+		if r.promoteEnvironment(ctx, promotion, i, status) {
+			waiting = true
+		}
+	}
+	status.Environments = pruneEnvironmentStatus(status.Environments, promotion.Spec.Environments)
+
+	if !reflect.DeepEqual(status, &promotion.Status) {
+		if err = r.updateStatus(ctx, promotion, status); err != nil {
+			return
+		}
+	}
+	if waiting {
+		result.RequeueAfter = waitingRequeuePeriod
+	}
+	return
+}
+
+// promoteEnvironment promotes the content of the previous environment to the environment of the index, true is
+// returned if it needs to check again later
+func (r *PromotionReconciler) promoteEnvironment(ctx context.Context, promotion *v1alpha1.Promotion, index int,
+	status *v1alpha1.PromotionStatus) (waiting bool) {
+	source, target := &promotion.Spec.Environments[index-1], &promotion.Spec.Environments[index]
+	envStatus := status.GetEnvironment(target.Name)
+	if envStatus == nil {
+		status.Environments = append(status.Environments, v1alpha1.PromotionEnvironmentStatus{Name: target.Name})
+		envStatus = &status.Environments[len(status.Environments)-1]
+	}
+	fail := func(err error) bool {
+		envStatus.Phase, envStatus.Message = v1alpha1.PromotionPhaseFailed, err.Error()
+		return true
+	}
+
+	sourceApp, err := r.getApplication(ctx, promotion.Namespace, source.Application.Name)
+	if err != nil {
+		return fail(err)
+	}
+	targetApp, err := r.getApplication(ctx, promotion.Namespace, target.Application.Name)
+	if err != nil {
+		return fail(err)
+	}
+	candidate, err := r.getContent(ctx, promotion, source, sourceApp, true)
+	if err != nil {
+		return fail(fmt.Errorf("failed to get the content of environment %s: %v", source.Name, err))
+	}
+	if target.Write == v1alpha1.WriteMethodGit {
+		// the environment follows the branch of the Git target, the revision can't be pinned
+		candidate.Revision = ""
+	}
+	if candidate.IsEmpty() {
+		envStatus.Phase, envStatus.Message = v1alpha1.PromotionPhaseWaiting, fmt.Sprintf("no content to promote from environment %s", source.Name)
+		envStatus.Candidate, envStatus.Gates, envStatus.Decisions = nil, nil, nil
+		return false
+	}
+	current, err := r.getContent(ctx, promotion, target, targetApp, false)
+	if err != nil {
+		return fail(fmt.Errorf("failed to get the content of environment %s: %v", target.Name, err))
+	}
+	if isPromoted(candidate, current) {
+		if envStatus.Phase == v1alpha1.PromotionPhasePromoting && envStatus.Candidate != nil {
+			// the content was written, but the status was not updated after that
+			r.markPromoted(promotion, status, envStatus, source.Name, "")
+			return false
+		}
+		envStatus.Phase, envStatus.Message = v1alpha1.PromotionPhasePromoted, ""
+		envStatus.Candidate, envStatus.Gates, envStatus.Decisions = nil, nil, nil
+		return false
+	}
+
+	if !candidate.Equal(envStatus.Candidate) {
+		// the decisions were made on the previous candidate
+		envStatus.Candidate, envStatus.Decisions = candidate, nil
+	}
+	envStatus.Gates, envStatus.Message = nil, ""
+	passed := true
+	for i := range target.Gates {
+		gateStatus := r.checkGate(ctx, promotion.Namespace, &target.Gates[i], sourceApp, envStatus)
+		envStatus.Gates = append(envStatus.Gates, gateStatus)
+		passed = passed && gateStatus.Passed
+	}
+	if envStatus.IsRejected() {
+		envStatus.Phase, envStatus.Message = v1alpha1.PromotionPhaseRejected, "the candidate was rejected"
+		return false
+	}
+	if !passed {
+		envStatus.Phase = v1alpha1.PromotionPhaseWaiting
+		return true
+	}
+
+	// the candidate and its approvers are saved before writing, so they are not lost if the status fails to be
+	// updated after that
+	envStatus.Phase = v1alpha1.PromotionPhasePromoting
+	if err = r.updateStatus(ctx, promotion, status); err != nil {
+		return fail(fmt.Errorf("failed to save the candidate of environment %s: %v", target.Name, err))
+	}
+	commit, err := r.writeContent(ctx, promotion, target, candidate)
+	if err != nil {
+		return fail(fmt.Errorf("failed to promote to environment %s: %v", target.Name, err))
+	}
+	r.markPromoted(promotion, status, envStatus, source.Name, commit)
+	return false
+}
+
+// markPromoted records the candidate of an environment in the history, then marks the environment as promoted
+func (r *PromotionReconciler) markPromoted(promotion *v1alpha1.Promotion, status *v1alpha1.PromotionStatus,
+	envStatus *v1alpha1.PromotionEnvironmentStatus, source, commit string) {
+	now := metav1.Now()
+	status.AddHistory(v1alpha1.PromotionHistory{
+		Environment: envStatus.Name,
+		Source:      source,
+		Content:     *envStatus.Candidate,
+		Commit:      commit,
+		Approvers:   envStatus.GetApprovers(),
+		PromotedAt:  now,
+	})
+	envStatus.Phase, envStatus.Message, envStatus.LastPromotionTime = v1alpha1.PromotionPhasePromoted, "", &now
+	envStatus.Candidate, envStatus.Gates, envStatus.Decisions = nil, nil, nil
+	r.recorder.Eventf(promotion, corev1.EventTypeNormal, "Promoted", "promoted environment %s to %s", source, envStatus.Name)
+}
+
+// updateStatus updates the status of a Promotion. The decisions which were made during the reconciling are kept
+// once there is a conflict.
+func (r *PromotionReconciler) updateStatus(ctx context.Context, promotion *v1alpha1.Promotion, status *v1alpha1.PromotionStatus) error {
+	latest := promotion
+	return retry.RetryOnConflict(retry.DefaultRetry, func() (err error) {
+		if latest == nil {
+			latest = &v1alpha1.Promotion{}
+			if err = r.Get(ctx, types.NamespacedName{Namespace: promotion.Namespace, Name: promotion.Name}, latest); err != nil {
+				return
+			}
+			mergeDecisions(status, &latest.Status)
+		}
+		latest.Status = *status.DeepCopy()
+		if err = r.Status().Update(ctx, latest); err != nil {
+			latest = nil
+		}
+		return
+	})
+}
+
+// mergeDecisions copies the decisions from the latest status if the candidates are the same
+func mergeDecisions(status, latest *v1alpha1.PromotionStatus) {
+	for i := range status.Environments {
+		envStatus := &status.Environments[i]
+		if latestEnv := latest.GetEnvironment(envStatus.Name); latestEnv != nil && envStatus.Candidate != nil &&
+			envStatus.Candidate.Equal(latestEnv.Candidate) && len(latestEnv.Decisions) > len(envStatus.Decisions) {
+			envStatus.Decisions = latestEnv.Decisions
+		}
+	}
+}
+
+func (r *PromotionReconciler) getApplication(ctx context.Context, namespace, name string) (*v1alpha1.Application, error) {
+	app := &v1alpha1.Application{}
+	if err := r.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, app); err != nil {
+		return nil, fmt.Errorf("failed to get application %s: %v", name, err)
+	}
+	return app, nil
+}
+
+// getContent returns the content of an environment. The revision is the deployed one if deployed is true, or the
+// target one. The Helm values and the Kustomize images are read from the Git target of the git write method.
+func (r *PromotionReconciler) getContent(ctx context.Context, promotion *v1alpha1.Promotion,
+	env *v1alpha1.PromotionEnvironment, app *v1alpha1.Application, deployed bool) (content *v1alpha1.PromotionContent, err error) {
+	promote := &promotion.Spec.Promote
+	content = &v1alpha1.PromotionContent{}
+	if promote.Revision {
+		content.Revision = getRevision(app, deployed)
+	}
+
+	if env.Write == v1alpha1.WriteMethodGit {
+		if len(promote.HelmValues) == 0 && len(promote.KustomizeImages) == 0 {
+			return
+		}
+		var tree *devopsgitops.GetTreeOutput
+		if tree, err = r.getTree(ctx, promotion.Namespace, env); err == nil {
+			err = getGitContent(tree.Files, env.Git, promote, content)
+		}
+		return
+	}
+	if len(promote.HelmValues) > 0 {
+		if content.HelmValues, err = getHelmValues(app, promote.HelmValues); err != nil {
+			return
+		}
+	}
+	if len(promote.KustomizeImages) > 0 {
+		content.KustomizeImages = getKustomizeImages(app, promote.KustomizeImages)
+	}
+	return
+}
+
+// writeContent promotes the content to an environment, the commit is returned for the git write method
+func (r *PromotionReconciler) writeContent(ctx context.Context, promotion *v1alpha1.Promotion,
+	env *v1alpha1.PromotionEnvironment, content *v1alpha1.PromotionContent) (commit string, err error) {
+	if env.Write == v1alpha1.WriteMethodGit {
+		return r.commitContent(ctx, promotion, env, content)
+	}
+
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		app, err := r.getApplication(ctx, promotion.Namespace, env.Application.Name)
+		if err != nil {
+			return err
+		}
+		if content.Revision != "" && !setRevision(app, content.Revision) {
+			return fmt.Errorf("the revision of application %s can't be promoted", app.Name)
+		}
+		if err = setHelmValues(app, content.HelmValues); err != nil {
+			return err
+		}
+		if err = setKustomizeImages(app, content.KustomizeImages); err != nil {
+			return err
+		}
+		return r.Update(ctx, app)
+	})
+	return
+}
+
+// commitContent commits the content to the Git target of an environment
+func (r *PromotionReconciler) commitContent(ctx context.Context, promotion *v1alpha1.Promotion,
+	env *v1alpha1.PromotionEnvironment, content *v1alpha1.PromotionContent) (commit string, err error) {
+	var repoService devopsgitops.GitRepoService
+	if repoService, err = r.newRepoService(ctx, promotion.Namespace, env); err != nil {
+		return
+	}
+	var tree *devopsgitops.GetTreeOutput
+	if tree, err = repoService.GetTree(ctx, &devopsgitops.GetTreeInput{Revision: env.Git.Branch}); err != nil {
+		return
+	}
+	var changed map[string][]byte
+	if changed, err = setGitContent(tree.Files, env.Git, content); err != nil || len(changed) == 0 {
+		return
+	}
+
+	var names []string
+	for name := range changed {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	input := &devopsgitops.AddFilesInput{
+		Branch:    env.Git.Branch,
+		Message:   fmt.Sprintf("Promote %s to environment %s", promotion.Name, env.Name),
+		Overwrite: true,
+	}
+	for _, name := range names {
+		input.Files = append(input.Files, &devopsgitops.FileNameData{Name: name, Data: changed[name]})
+	}
+	// the files are committed and pushed by CommitAndPush
+	var output *devopsgitops.AddFilesOutput
+	if output, err = repoService.AddFiles(ctx, input); err != nil {
+		if errors.Is(err, devopsgitops.ErrWorkTreeClean) {
+			err = nil
+		}
+		return
+	}
+	if output.Commit != nil {
+		commit = output.Commit.Hash
+	}
+	return
+}
+
+func (r *PromotionReconciler) getTree(ctx context.Context, namespace string, env *v1alpha1.PromotionEnvironment) (*devopsgitops.GetTreeOutput, error) {
+	repoService, err := r.newRepoService(ctx, namespace, env)
+	if err != nil {
+		return nil, err
+	}
+	return repoService.GetTree(ctx, &devopsgitops.GetTreeInput{Revision: env.Git.Branch})
+}
+
+func (r *PromotionReconciler) newRepoService(ctx context.Context, namespace string, env *v1alpha1.PromotionEnvironment) (devopsgitops.GitRepoService, error) {
+	if env.Git == nil || env.Git.Repository.Name == "" || env.Git.Branch == "" {
+		return nil, fmt.Errorf("the Git target of environment %s requires a repository and a branch", env.Name)
+	}
+	if r.RepoFactory == nil {
+		return nil, fmt.Errorf("the git write method is not supported")
+	}
+	return r.RepoFactory.NewRepoService(ctx, &user.DefaultInfo{Name: promotionUser},
+		types.NamespacedName{Namespace: namespace, Name: env.Git.Repository.Name})
+}
+
+// checkGate checks a gate of an environment, the source Application is the one of the previous environment
+func (r *PromotionReconciler) checkGate(ctx context.Context, namespace string, gate *v1alpha1.PromotionGate,
+	sourceApp *v1alpha1.Application, envStatus *v1alpha1.PromotionEnvironmentStatus) (gateStatus v1alpha1.PromotionGateStatus) {
+	gateStatus.Type = gate.Type
+	switch gate.Type {
+	case v1alpha1.PromotionGateHealth:
+		health := v1alpha1.HealthStatusUnknown
+		if sourceApp.Status.Health != nil && sourceApp.Status.Health.Status != "" {
+			health = sourceApp.Status.Health.Status
+		}
+		sync := sourceApp.Status.Sync
+		if sync == "" {
+			sync = v1alpha1.SyncStatusUnknown
+		}
+		gateStatus.Passed = health == v1alpha1.HealthStatusHealthy && sync == v1alpha1.SyncStatusSynced
+		gateStatus.Message = fmt.Sprintf("application %s is %s and %s", sourceApp.Name, sync, health)
+	case v1alpha1.PromotionGatePipelineRun:
+		gateStatus.Passed, gateStatus.Message = r.checkPipelineRun(ctx, namespace, gate.Pipeline, sourceApp)
+	case v1alpha1.PromotionGateApproval:
+		approvals, required := envStatus.CountApprovals(), gate.GetRequiredApprovals()
+		gateStatus.Passed = approvals >= required
+		gateStatus.Message = fmt.Sprintf("%d of %d approvals", approvals, required)
+	default:
+		gateStatus.Message = fmt.Sprintf("unknown gate type %q", gate.Type)
+	}
+	return
+}
+
+// checkPipelineRun checks if the latest PipelineRun of a Pipeline succeeded after the source Application was
+// synchronized
+func (r *PromotionReconciler) checkPipelineRun(ctx context.Context, namespace, pipeline string,
+	sourceApp *v1alpha1.Application) (passed bool, message string) {
+	if pipeline == "" {
+		return false, "the pipeline of the gate is required"
+	}
+	pipelineRuns := &v1alpha3.PipelineRunList{}
+	if err := r.List(ctx, pipelineRuns, client.InNamespace(namespace),
+		client.MatchingLabels{v1alpha3.PipelineNameLabelKey: pipeline}); err != nil {
+		return false, fmt.Sprintf("failed to list the PipelineRuns of pipeline %s: %v", pipeline, err)
+	}
+	var latest *v1alpha3.PipelineRun
+	for i := range pipelineRuns.Items {
+		pr := &pipelineRuns.Items[i]
+		if latest == nil || latest.CreationTimestamp.Before(&pr.CreationTimestamp) {
+			latest = pr
+		}
+	}
+	switch {
+	case latest == nil:
+		return false, fmt.Sprintf("no PipelineRun of pipeline %s found", pipeline)
+	case latest.Status.Phase != v1alpha3.Succeeded:
+		return false, fmt.Sprintf("the latest PipelineRun %s is %s", latest.Name, latest.Status.Phase)
+	case sourceApp.Status.LastSyncTime != nil && (latest.Status.CompletionTime == nil ||
+		latest.Status.CompletionTime.Before(sourceApp.Status.LastSyncTime)):
+		return false, fmt.Sprintf("the latest PipelineRun %s completed before application %s was synchronized", latest.Name, sourceApp.Name)
+	}
+	return true, fmt.Sprintf("the latest PipelineRun %s succeeded", latest.Name)
+}
+
+// isPromoted returns true if the current content of an environment has the candidate
+func isPromoted(candidate, current *v1alpha1.PromotionContent) bool {
+	if candidate.Revision != "" && candidate.Revision != current.Revision {
+		return false
+	}
+	for key, val := range candidate.HelmValues {
+		if currentVal, ok := current.HelmValues[key]; !ok || currentVal != val {
+			return false
+		}
+	}
+	for key, val := range candidate.KustomizeImages {
+		if currentVal, ok := current.KustomizeImages[key]; !ok || currentVal != val {
+			return false
+		}
+	}
+	return true
+}
+
+// pruneEnvironmentStatus removes the states of the environments which don't exist, the first environment has no state
+func pruneEnvironmentStatus(statuses []v1alpha1.PromotionEnvironmentStatus, envs []v1alpha1.PromotionEnvironment) (result []v1alpha1.PromotionEnvironmentStatus) {
+	for i := 1; i < len(envs); i++ {
+		for _, envStatus := range statuses {
+			if envStatus.Name == envs[i].Name {
+				result = append(result, envStatus)
+				break
+			}
+		}
+	}
+	return
+}
+
+// GetName returns the name of this controller
+func (r *PromotionReconciler) GetName() string {
+	return "PromotionController"
+}
+
+// GetGroupName returns the group name of this controller
+func (r *PromotionReconciler) GetGroupName() string {
+	return controllerGroupName
+}
+
+// SetupWithManager setups the log and recorder
+func (r *PromotionReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	r.log = ctrl.Log.WithName(r.GetName())
+	r.recorder = mgr.GetEventRecorderFor(r.GetName())
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("gitops_promotion_controller").
+		For(&v1alpha1.Promotion{}).
+		Watches(&v1alpha1.Application{}, handler.EnqueueRequestsFromMapFunc(r.mapApplicationToPromotions)).
+		Complete(r)
+}
+
+// mapApplicationToPromotions returns the Promotions which have the Application as an environment
+func (r *PromotionReconciler) mapApplicationToPromotions(ctx context.Context, obj client.Object) (requests []reconcile.Request) {
+	promotions := &v1alpha1.PromotionList{}
+	if err := r.List(ctx, promotions, client.InNamespace(obj.GetNamespace())); err != nil {
+		r.log.Error(err, "failed to list promotions", "namespace", obj.GetNamespace())
+		return
+	}
+	for _, promotion := range promotions.Items {
+		for _, env := range promotion.Spec.Environments {
+			if env.Application.Name == obj.GetName() {
+				requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{
+					Namespace: promotion.Namespace, Name: promotion.Name}})
+				break
+			}
+		}
+	}
+	return
+}
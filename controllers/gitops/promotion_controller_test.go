@@ -0,0 +1,403 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gitops
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/kubesphere/ks-devops/pkg/api/devops/v1alpha3"
+	"github.com/kubesphere/ks-devops/pkg/api/gitops/v1alpha1"
+	devopsgitops "github.com/kubesphere/ks-devops/pkg/kapis/devops/v1alpha3/gitops"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+type fakeRepoFactory struct {
+	devopsgitops.GitRepoFactory
+	service *fakeRepoService
+}
+
+func (f *fakeRepoFactory) NewRepoService(ctx context.Context, user user.Info, repo types.NamespacedName) (devopsgitops.GitRepoService, error) {
+	f.service.repos = append(f.service.repos, repo)
+	return f.service, nil
+}
+
+type fakeRepoService struct {
+	devopsgitops.GitRepoService
+	files  map[string][]byte
+	repos  []types.NamespacedName
+	inputs []*devopsgitops.AddFilesInput
+}
+
+func (s *fakeRepoService) GetTree(ctx context.Context, input *devopsgitops.GetTreeInput) (*devopsgitops.GetTreeOutput, error) {
+	return &devopsgitops.GetTreeOutput{Commit: &devopsgitops.Commit{Hash: "abc"}, Files: s.files}, nil
+}
+
+func (s *fakeRepoService) AddFiles(ctx context.Context, input *devopsgitops.AddFilesInput) (*devopsgitops.AddFilesOutput, error) {
+	s.inputs = append(s.inputs, input)
+	for _, file := range input.Files {
+		s.files[file.Name] = file.Data
+	}
+	return &devopsgitops.AddFilesOutput{Commit: &devopsgitops.Commit{Hash: "def"}}, nil
+}
+
+func newPromotionScheme(t *testing.T) *runtime.Scheme {
+	s := runtime.NewScheme()
+	assert.Nil(t, scheme.AddToScheme(s))
+	assert.Nil(t, v1alpha1.AddToScheme(s))
+	assert.Nil(t, v1alpha3.AddToScheme(s))
+	return s
+}
+
+func newPromotion(envs ...v1alpha1.PromotionEnvironment) *v1alpha1.Promotion {
+	return &v1alpha1.Promotion{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "web"},
+		Spec: v1alpha1.PromotionSpec{
+			Environments: envs,
+			Promote:      v1alpha1.PromotionTarget{Revision: true},
+		},
+	}
+}
+
+func newEnvironment(name string, gates ...v1alpha1.PromotionGate) v1alpha1.PromotionEnvironment {
+	return v1alpha1.PromotionEnvironment{
+		Name:        name,
+		Application: corev1.LocalObjectReference{Name: "web-" + name},
+		Gates:       gates,
+	}
+}
+
+func newDeployedApp(name, revision string, health v1alpha1.HealthStatusCode) *v1alpha1.Application {
+	app := newArgoApp(name)
+	app.Namespace = "ns"
+	app.Spec.ArgoApp.Spec.Source.TargetRevision = "main"
+	app.Status.Revision = revision
+	app.Status.Sync = v1alpha1.SyncStatusSynced
+	app.Status.Health = &v1alpha1.HealthStatus{Status: health}
+	return app
+}
+
+func TestPromotionReconciler_Reconcile(t *testing.T) {
+	newReconciler := func(objects ...client.Object) (*PromotionReconciler, *fakeRepoService) {
+		service := &fakeRepoService{files: map[string][]byte{}}
+		return &PromotionReconciler{
+			Client: fake.NewClientBuilder().WithScheme(newPromotionScheme(t)).WithObjects(objects...).
+				WithStatusSubresource(&v1alpha1.Promotion{}).Build(),
+			RepoFactory: &fakeRepoFactory{service: service},
+			log:         logr.Discard(),
+			recorder:    &record.FakeRecorder{},
+		}, service
+	}
+	reconcileAndGet := func(t *testing.T, r *PromotionReconciler) (*v1alpha1.Promotion, ctrl.Result) {
+		result, err := r.Reconcile(context.Background(), reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "ns", Name: "web"}})
+		assert.Nil(t, err)
+		promotion := &v1alpha1.Promotion{}
+		assert.Nil(t, r.Get(context.Background(), types.NamespacedName{Namespace: "ns", Name: "web"}, promotion))
+		return promotion, result
+	}
+	getApp := func(t *testing.T, r *PromotionReconciler, name string) *v1alpha1.Application {
+		app := &v1alpha1.Application{}
+		assert.Nil(t, r.Get(context.Background(), types.NamespacedName{Namespace: "ns", Name: name}, app))
+		return app
+	}
+
+	t.Run("promote through the environments", func(t *testing.T) {
+		promotion := newPromotion(newEnvironment("dev"),
+			newEnvironment("staging", v1alpha1.PromotionGate{Type: v1alpha1.PromotionGateHealth}),
+			newEnvironment("prod", v1alpha1.PromotionGate{Type: v1alpha1.PromotionGateApproval}))
+		r, _ := newReconciler(promotion,
+			newDeployedApp("web-dev", "abc", v1alpha1.HealthStatusHealthy),
+			newDeployedApp("web-staging", "", v1alpha1.HealthStatusHealthy),
+			newDeployedApp("web-prod", "", v1alpha1.HealthStatusHealthy))
+
+		promotion, result := reconcileAndGet(t, r)
+		assert.Equal(t, ctrl.Result{}, result)
+		assert.Equal(t, "abc", getApp(t, r, "web-staging").Spec.ArgoApp.Spec.Source.TargetRevision)
+		if assert.Len(t, promotion.Status.Environments, 2) {
+			assert.Equal(t, v1alpha1.PromotionPhasePromoted, promotion.Status.Environments[0].Phase)
+			assert.NotNil(t, promotion.Status.Environments[0].LastPromotionTime)
+			assert.Equal(t, v1alpha1.PromotionPhaseWaiting, promotion.Status.Environments[1].Phase)
+			assert.Equal(t, "no content to promote from environment staging", promotion.Status.Environments[1].Message)
+		}
+		if assert.Len(t, promotion.Status.History, 1) {
+			assert.Equal(t, "staging", promotion.Status.History[0].Environment)
+			assert.Equal(t, "dev", promotion.Status.History[0].Source)
+			assert.Equal(t, "abc", promotion.Status.History[0].Content.Revision)
+		}
+
+		// staging is deployed, prod waits for the approval
+		staging := getApp(t, r, "web-staging")
+		staging.Status.Revision = "abc"
+		assert.Nil(t, r.Update(context.Background(), staging))
+		promotion, result = reconcileAndGet(t, r)
+		assert.Equal(t, waitingRequeuePeriod, result.RequeueAfter)
+		prod := promotion.Status.GetEnvironment("prod")
+		assert.Equal(t, v1alpha1.PromotionPhaseWaiting, prod.Phase)
+		assert.Equal(t, &v1alpha1.PromotionContent{Revision: "abc"}, prod.Candidate)
+		assert.Equal(t, []v1alpha1.PromotionGateStatus{{Type: v1alpha1.PromotionGateApproval, Message: "0 of 1 approvals"}}, prod.Gates)
+		assert.Equal(t, "main", getApp(t, r, "web-prod").Spec.ArgoApp.Spec.Source.TargetRevision)
+
+		prod.Decisions = append(prod.Decisions, v1alpha3.ApprovalDecision{User: "admin", Decision: v1alpha3.ApprovalDecisionApprove, Time: metav1.Now()})
+		assert.Nil(t, r.Status().Update(context.Background(), promotion))
+		promotion, _ = reconcileAndGet(t, r)
+		assert.Equal(t, "abc", getApp(t, r, "web-prod").Spec.ArgoApp.Spec.Source.TargetRevision)
+		prod = promotion.Status.GetEnvironment("prod")
+		assert.Equal(t, v1alpha1.PromotionPhasePromoted, prod.Phase)
+		assert.Nil(t, prod.Candidate)
+		assert.Nil(t, prod.Decisions)
+		if assert.Len(t, promotion.Status.History, 2) {
+			assert.Equal(t, int64(2), promotion.Status.History[1].ID)
+			assert.Equal(t, []string{"admin"}, promotion.Status.History[1].Approvers)
+		}
+
+		// nothing changes once all environments are promoted
+		promotion, _ = reconcileAndGet(t, r)
+		assert.Len(t, promotion.Status.History, 2)
+	})
+
+	t.Run("the gates are not passed", func(t *testing.T) {
+		promotion := newPromotion(newEnvironment("dev"), newEnvironment("prod",
+			v1alpha1.PromotionGate{Type: v1alpha1.PromotionGateHealth},
+			v1alpha1.PromotionGate{Type: v1alpha1.PromotionGatePipelineRun, Pipeline: "e2e"}))
+		r, _ := newReconciler(promotion,
+			newDeployedApp("web-dev", "abc", v1alpha1.HealthStatusDegraded),
+			newDeployedApp("web-prod", "", v1alpha1.HealthStatusHealthy))
+
+		promotion, result := reconcileAndGet(t, r)
+		assert.Equal(t, waitingRequeuePeriod, result.RequeueAfter)
+		assert.Equal(t, []v1alpha1.PromotionGateStatus{
+			{Type: v1alpha1.PromotionGateHealth, Message: "application web-dev is Synced and Degraded"},
+			{Type: v1alpha1.PromotionGatePipelineRun, Message: "no PipelineRun of pipeline e2e found"},
+		}, promotion.Status.Environments[0].Gates)
+		assert.Equal(t, "main", getApp(t, r, "web-prod").Spec.ArgoApp.Spec.Source.TargetRevision)
+	})
+
+	t.Run("the latest PipelineRun succeeded after the sync", func(t *testing.T) {
+		syncTime := metav1.NewTime(time.Now().Add(-time.Hour).Truncate(time.Second))
+		staleTime := metav1.NewTime(syncTime.Add(-time.Minute))
+		completionTime := metav1.NewTime(syncTime.Add(time.Minute))
+		newPipelineRun := func(name string, created time.Time, phase v1alpha3.RunPhase, completion *metav1.Time) *v1alpha3.PipelineRun {
+			return &v1alpha3.PipelineRun{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: name, CreationTimestamp: metav1.NewTime(created),
+					Labels: map[string]string{v1alpha3.PipelineNameLabelKey: "e2e"}},
+				Status: v1alpha3.PipelineRunStatus{Phase: phase, CompletionTime: completion},
+			}
+		}
+		dev := newDeployedApp("web-dev", "abc", v1alpha1.HealthStatusHealthy)
+		dev.Status.LastSyncTime = &syncTime
+		promotion := newPromotion(newEnvironment("dev"), newEnvironment("prod",
+			v1alpha1.PromotionGate{Type: v1alpha1.PromotionGatePipelineRun, Pipeline: "e2e"}))
+
+		r, _ := newReconciler(promotion, dev, newDeployedApp("web-prod", "", v1alpha1.HealthStatusHealthy),
+			newPipelineRun("e2e-1", syncTime.Add(-time.Hour), v1alpha3.Succeeded, &completionTime),
+			newPipelineRun("e2e-2", syncTime.Add(-time.Minute), v1alpha3.Succeeded, &staleTime))
+		promotion, _ = reconcileAndGet(t, r)
+		assert.Equal(t, "the latest PipelineRun e2e-2 completed before application web-dev was synchronized",
+			promotion.Status.Environments[0].Gates[0].Message)
+
+		r, _ = newReconciler(promotion, dev, newDeployedApp("web-prod", "", v1alpha1.HealthStatusHealthy),
+			newPipelineRun("e2e-3", syncTime.Time, v1alpha3.Running, nil))
+		promotion, _ = reconcileAndGet(t, r)
+		assert.Equal(t, "the latest PipelineRun e2e-3 is Running", promotion.Status.Environments[0].Gates[0].Message)
+
+		r, _ = newReconciler(promotion, dev, newDeployedApp("web-prod", "", v1alpha1.HealthStatusHealthy),
+			newPipelineRun("e2e-3", syncTime.Time, v1alpha3.Succeeded, &completionTime))
+		promotion, _ = reconcileAndGet(t, r)
+		assert.Equal(t, v1alpha1.PromotionPhasePromoted, promotion.Status.Environments[0].Phase)
+		assert.Equal(t, "abc", getApp(t, r, "web-prod").Spec.ArgoApp.Spec.Source.TargetRevision)
+	})
+
+	t.Run("the candidate was rejected", func(t *testing.T) {
+		promotion := newPromotion(newEnvironment("dev"), newEnvironment("prod",
+			v1alpha1.PromotionGate{Type: v1alpha1.PromotionGateApproval}))
+		promotion.Status.Environments = []v1alpha1.PromotionEnvironmentStatus{{
+			Name:      "prod",
+			Phase:     v1alpha1.PromotionPhaseWaiting,
+			Candidate: &v1alpha1.PromotionContent{Revision: "abc"},
+			Decisions: []v1alpha3.ApprovalDecision{{User: "admin", Decision: v1alpha3.ApprovalDecisionReject}},
+		}}
+		r, _ := newReconciler(promotion,
+			newDeployedApp("web-dev", "abc", v1alpha1.HealthStatusHealthy),
+			newDeployedApp("web-prod", "", v1alpha1.HealthStatusHealthy))
+		promotion, result := reconcileAndGet(t, r)
+		assert.Equal(t, ctrl.Result{}, result)
+		assert.Equal(t, v1alpha1.PromotionPhaseRejected, promotion.Status.Environments[0].Phase)
+		assert.Equal(t, "main", getApp(t, r, "web-prod").Spec.ArgoApp.Spec.Source.TargetRevision)
+
+		// a new candidate requires new decisions
+		dev := getApp(t, r, "web-dev")
+		dev.Status.Revision = "def"
+		assert.Nil(t, r.Update(context.Background(), dev))
+		promotion, _ = reconcileAndGet(t, r)
+		assert.Equal(t, v1alpha1.PromotionPhaseWaiting, promotion.Status.Environments[0].Phase)
+		assert.Equal(t, &v1alpha1.PromotionContent{Revision: "def"}, promotion.Status.Environments[0].Candidate)
+		assert.Nil(t, promotion.Status.Environments[0].Decisions)
+	})
+
+	t.Run("the status fails to be updated after promoting", func(t *testing.T) {
+		promotion := newPromotion(newEnvironment("dev"), newEnvironment("prod",
+			v1alpha1.PromotionGate{Type: v1alpha1.PromotionGateApproval}))
+		promotion.Status.Environments = []v1alpha1.PromotionEnvironmentStatus{{
+			Name:      "prod",
+			Phase:     v1alpha1.PromotionPhaseWaiting,
+			Candidate: &v1alpha1.PromotionContent{Revision: "abc"},
+			Decisions: []v1alpha3.ApprovalDecision{{User: "admin", Decision: v1alpha3.ApprovalDecisionApprove}},
+		}}
+		r, _ := newReconciler()
+		var phaseWhenWriting v1alpha1.PromotionPhase
+		written := false
+		r.Client = fake.NewClientBuilder().WithScheme(newPromotionScheme(t)).WithObjects(promotion,
+			newDeployedApp("web-dev", "abc", v1alpha1.HealthStatusHealthy),
+			newDeployedApp("web-prod", "", v1alpha1.HealthStatusHealthy)).
+			WithStatusSubresource(&v1alpha1.Promotion{}).
+			WithInterceptorFuncs(interceptor.Funcs{
+				Update: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.UpdateOption) error {
+					latest := &v1alpha1.Promotion{}
+					assert.Nil(t, c.Get(ctx, types.NamespacedName{Namespace: "ns", Name: "web"}, latest))
+					phaseWhenWriting, written = latest.Status.Environments[0].Phase, true
+					return c.Update(ctx, obj, opts...)
+				},
+				SubResourceUpdate: func(ctx context.Context, c client.Client, subResourceName string, obj client.Object, opts ...client.SubResourceUpdateOption) error {
+					if written {
+						return errors.New("connection refused")
+					}
+					return c.SubResource(subResourceName).Update(ctx, obj, opts...)
+				},
+			}).Build()
+
+		_, err := r.Reconcile(context.Background(), reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "ns", Name: "web"}})
+		assert.Error(t, err)
+		assert.Equal(t, v1alpha1.PromotionPhasePromoting, phaseWhenWriting)
+		assert.Equal(t, "abc", getApp(t, r, "web-prod").Spec.ArgoApp.Spec.Source.TargetRevision)
+
+		// the saved candidate and approvers are recorded once the content is found promoted
+		written = false
+		promotion, _ = reconcileAndGet(t, r)
+		assert.Equal(t, v1alpha1.PromotionPhasePromoted, promotion.Status.Environments[0].Phase)
+		assert.Nil(t, promotion.Status.Environments[0].Candidate)
+		if assert.Len(t, promotion.Status.History, 1) {
+			assert.Equal(t, v1alpha1.PromotionContent{Revision: "abc"}, promotion.Status.History[0].Content)
+			assert.Equal(t, []string{"admin"}, promotion.Status.History[0].Approvers)
+		}
+	})
+
+	t.Run("commit to git", func(t *testing.T) {
+		promotion := newPromotion(newEnvironment("dev"), newEnvironment("prod"))
+		promotion.Spec.Promote = v1alpha1.PromotionTarget{Revision: true, HelmValues: []string{"image.tag"}}
+		promotion.Spec.Environments[1].Write = v1alpha1.WriteMethodGit
+		promotion.Spec.Environments[1].Git = &v1alpha1.PromotionGitTarget{
+			Repository: corev1.LocalObjectReference{Name: "apps"},
+			Branch:     "main",
+			Path:       "prod",
+		}
+		dev := newFluxApp("web-dev", `{"image":{"tag":"v2"}}`)
+		dev.Namespace = "ns"
+		prod := newFluxApp("web-prod", `{}`)
+		prod.Namespace = "ns"
+		r, service := newReconciler(promotion, dev, prod)
+		service.files["prod/values.yaml"] = []byte("image:\n  tag: v1\n")
+
+		promotion, _ = reconcileAndGet(t, r)
+		assert.Equal(t, v1alpha1.PromotionPhasePromoted, promotion.Status.Environments[0].Phase)
+		if assert.Len(t, service.inputs, 1) {
+			assert.Equal(t, "main", service.inputs[0].Branch)
+			assert.Equal(t, "Promote web to environment prod", service.inputs[0].Message)
+		}
+		assert.Equal(t, "image:\n  tag: v2\n", string(service.files["prod/values.yaml"]))
+		assert.Equal(t, types.NamespacedName{Namespace: "ns", Name: "apps"}, service.repos[0])
+		if assert.Len(t, promotion.Status.History, 1) {
+			assert.Equal(t, "def", promotion.Status.History[0].Commit)
+			// the revision is not promoted with the git write method
+			assert.Equal(t, v1alpha1.PromotionContent{HelmValues: map[string]string{"image.tag": "v2"}}, promotion.Status.History[0].Content)
+		}
+		assert.Equal(t, "0.1.0", getApp(t, r, "web-prod").Spec.FluxApp.Spec.Config.HelmRelease.Chart.Version)
+
+		promotion, _ = reconcileAndGet(t, r)
+		assert.Len(t, service.inputs, 1)
+	})
+
+	t.Run("the application does not exist", func(t *testing.T) {
+		r, _ := newReconciler(newPromotion(newEnvironment("dev"), newEnvironment("prod")),
+			newDeployedApp("web-dev", "abc", v1alpha1.HealthStatusHealthy))
+		promotion, result := reconcileAndGet(t, r)
+		assert.Equal(t, waitingRequeuePeriod, result.RequeueAfter)
+		assert.Equal(t, v1alpha1.PromotionPhaseFailed, promotion.Status.Environments[0].Phase)
+		assert.Contains(t, promotion.Status.Environments[0].Message, "failed to get application web-prod")
+	})
+
+	t.Run("suspended", func(t *testing.T) {
+		promotion := newPromotion(newEnvironment("dev"), newEnvironment("prod"))
+		promotion.Spec.Suspend = true
+		r, _ := newReconciler(promotion,
+			newDeployedApp("web-dev", "abc", v1alpha1.HealthStatusHealthy),
+			newDeployedApp("web-prod", "", v1alpha1.HealthStatusHealthy))
+		promotion, _ = reconcileAndGet(t, r)
+		assert.Empty(t, promotion.Status.Environments)
+		assert.Equal(t, "main", getApp(t, r, "web-prod").Spec.ArgoApp.Spec.Source.TargetRevision)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		r, _ := newReconciler()
+		_, err := r.Reconcile(context.Background(), reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "ns", Name: "web"}})
+		assert.Nil(t, err)
+	})
+}
+
+func TestPromotionReconciler_mapApplicationToPromotions(t *testing.T) {
+	r := &PromotionReconciler{
+		Client: fake.NewClientBuilder().WithScheme(newPromotionScheme(t)).WithObjects(
+			newPromotion(newEnvironment("dev"), newEnvironment("prod")),
+			&v1alpha1.Promotion{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "other"}},
+			&v1alpha1.Promotion{ObjectMeta: metav1.ObjectMeta{Namespace: "other", Name: "web"},
+				Spec: v1alpha1.PromotionSpec{Environments: []v1alpha1.PromotionEnvironment{newEnvironment("prod")}}},
+		).Build(),
+		log: logr.Discard(),
+	}
+	app := newDeployedApp("web-prod", "", v1alpha1.HealthStatusHealthy)
+	assert.Equal(t, []reconcile.Request{{NamespacedName: types.NamespacedName{Namespace: "ns", Name: "web"}}},
+		r.mapApplicationToPromotions(context.Background(), app))
+}
+
+func TestMergeDecisions(t *testing.T) {
+	decisions := []v1alpha3.ApprovalDecision{{User: "admin", Decision: v1alpha3.ApprovalDecisionApprove}}
+	status := &v1alpha1.PromotionStatus{Environments: []v1alpha1.PromotionEnvironmentStatus{
+		{Name: "staging", Candidate: &v1alpha1.PromotionContent{Revision: "abc"}},
+		{Name: "prod", Candidate: &v1alpha1.PromotionContent{Revision: "abc"}},
+	}}
+	latest := &v1alpha1.PromotionStatus{Environments: []v1alpha1.PromotionEnvironmentStatus{
+		{Name: "staging", Candidate: &v1alpha1.PromotionContent{Revision: "abc"}, Decisions: decisions},
+		{Name: "prod", Candidate: &v1alpha1.PromotionContent{Revision: "old"}, Decisions: decisions},
+	}}
+	mergeDecisions(status, latest)
+	assert.Equal(t, decisions, status.Environments[0].Decisions)
+	assert.Nil(t, status.Environments[1].Decisions)
+}
@@ -0,0 +1,296 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gitrepository
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/jenkins-x/go-scm/scm"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/yaml"
+
+	"github.com/kubesphere/ks-devops/pkg/api/devops/v1alpha3"
+	"github.com/kubesphere/ks-devops/pkg/client/git"
+)
+
+const (
+	// SourceSynced indicates the Pipeline was synchronized from its Git source
+	SourceSynced = "SourceSynced"
+	// FailedSourceSync indicates the controller failed to synchronize the Pipeline from its Git source
+	FailedSourceSync = "FailedSourceSync"
+)
+
+// PipelineSourceReconciler synchronizes the Git-managed Pipelines from their GitRepositories. A Pipeline is
+// synchronized once its source changed, the SCM webhook requested it, or the interval of the source passed.
+type PipelineSourceReconciler struct {
+	client.Client
+
+	log      logr.Logger
+	recorder record.EventRecorder
+	// getGitClient is for testing, it creates the git client by the provider of the GitRepository by default
+	getGitClient func(repo *v1alpha3.GitRepository) (*scm.Client, error)
+}
+
+//+kubebuilder:rbac:groups=devops.kubesphere.io,resources=pipelines,verbs=get;list;watch;update
+//+kubebuilder:rbac:groups=devops.kubesphere.io,resources=gitrepositories,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=secrets,verbs=get
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+
+// Reconcile pulls the definition of a Git-managed Pipeline, then updates the Pipeline if the definition changed
+func (r *PipelineSourceReconciler) Reconcile(ctx context.Context, req ctrl.Request) (result ctrl.Result, err error) {
+	pipeline := &v1alpha3.Pipeline{}
+	if err = r.Get(ctx, req.NamespacedName, pipeline); err != nil {
+		err = client.IgnoreNotFound(err)
+		return
+	}
+	if !pipeline.IsGitManaged() || !pipeline.DeletionTimestamp.IsZero() {
+		return
+	}
+
+	source := pipeline.Spec.Source
+	if source.Interval != nil && source.Interval.Duration > 0 {
+		result.RequeueAfter = source.Interval.Duration
+	}
+
+	var commit string
+	var spec *v1alpha3.PipelineSpec
+	if commit, spec, err = r.pullDefinition(ctx, pipeline); err != nil {
+		r.log.Error(err, "failed to pull the Pipeline definition", "Pipeline", req.NamespacedName)
+		r.recorder.Eventf(pipeline, v1.EventTypeWarning, FailedSourceSync, "Failed to pull the definition from %s: %v",
+			getSourceDescription(source), err)
+		return
+	}
+
+	_, requested := pipeline.Annotations[v1alpha3.PipelineRequestToSyncSourceAnnoKey]
+	if !requested && pipeline.Annotations[v1alpha3.PipelineSourceCommitAnnoKey] == commit &&
+		pipeline.Spec.HasSameDefinition(spec) {
+		return
+	}
+
+	pipeline = pipeline.DeepCopy()
+	pipeline.Spec = *spec
+	if pipeline.Annotations == nil {
+		pipeline.Annotations = map[string]string{}
+	}
+	pipeline.Annotations[v1alpha3.PipelineSourceCommitAnnoKey] = commit
+	pipeline.Annotations[v1alpha3.PipelineSourceSyncTimeAnnoKey] = time.Now().UTC().Format(time.RFC3339)
+	delete(pipeline.Annotations, v1alpha3.PipelineRequestToSyncSourceAnnoKey)
+	// the last API user is not the author of this change
+	pipeline.SetSpecAuthor("", fmt.Sprintf("Synchronized from commit %s of GitRepository %s", commit, source.GitRepository))
+	if err = r.Update(ctx, pipeline); err == nil {
+		r.recorder.Eventf(pipeline, v1.EventTypeNormal, SourceSynced, "Synchronized the definition from %s at commit %s",
+			getSourceDescription(source), commit)
+	}
+	return
+}
+
+// pullDefinition returns the commit and the Pipeline spec which is converted from the definition in Git
+func (r *PipelineSourceReconciler) pullDefinition(ctx context.Context, pipeline *v1alpha3.Pipeline) (
+	commit string, spec *v1alpha3.PipelineSpec, err error) {
+	source := pipeline.Spec.Source
+	repo := &v1alpha3.GitRepository{}
+	if err = r.Get(ctx, types.NamespacedName{Namespace: pipeline.Namespace, Name: source.GitRepository}, repo); err != nil {
+		err = fmt.Errorf("cannot get GitRepository %s, error: %v", source.GitRepository, err)
+		return
+	}
+	repoName := GetRepoFullName(repo)
+	if repoName == "" {
+		err = fmt.Errorf("cannot find out the repository name from GitRepository %s", repo.Name)
+		return
+	}
+
+	var gitClient *scm.Client
+	if gitClient, err = r.newGitClient(repo); err != nil {
+		return
+	}
+
+	ref := source.Ref
+	if ref == "" {
+		var scmRepo *scm.Repository
+		if scmRepo, _, err = gitClient.Repositories.Find(ctx, repoName); err != nil {
+			err = fmt.Errorf("cannot find the default branch of %s, error: %v", repoName, err)
+			return
+		}
+		ref = scmRepo.Branch
+	}
+
+	var scmCommit *scm.Commit
+	if scmCommit, _, err = gitClient.Git.FindCommit(ctx, repoName, ref); err != nil || scmCommit == nil {
+		err = fmt.Errorf("cannot find the commit of %s in %s, error: %v", ref, repoName, err)
+		return
+	}
+	commit = scmCommit.Sha
+
+	var content *scm.Content
+	if content, _, err = gitClient.Contents.Find(ctx, repoName, source.Path, commit); err != nil {
+		err = fmt.Errorf("cannot find %s at commit %s, error: %v", source.Path, commit, err)
+		return
+	}
+	spec, err = convertDefinition(pipeline, content.Data)
+	return
+}
+
+func (r *PipelineSourceReconciler) newGitClient(repo *v1alpha3.GitRepository) (*scm.Client, error) {
+	if r.getGitClient != nil {
+		return r.getGitClient(repo)
+	}
+
+	secretRef := repo.Spec.Secret.DeepCopy()
+	if secretRef != nil && secretRef.Namespace == "" {
+		secretRef.Namespace = repo.Namespace
+	}
+	factory := git.NewClientFactory(repo.Spec.Provider, secretRef, r.Client)
+	factory.Server = repo.Spec.Server
+	return factory.GetClient()
+}
+
+// convertDefinition converts the definition in Git to the spec of a Pipeline. A Pipeline manifest replaces the whole
+// spec except the protected fields, and a Jenkinsfile only replaces the Jenkinsfile. The Git source is always kept.
+func convertDefinition(pipeline *v1alpha3.Pipeline, data []byte) (spec *v1alpha3.PipelineSpec, err error) {
+	source := pipeline.Spec.Source
+	if source.IsManifest() {
+		manifest := &v1alpha3.Pipeline{}
+		if err = yaml.UnmarshalStrict(data, manifest); err != nil {
+			err = fmt.Errorf("invalid Pipeline manifest %s, error: %v", source.Path, err)
+			return
+		}
+		if manifest.Kind != "" && manifest.Kind != v1alpha3.ResourceKindPipeline {
+			err = fmt.Errorf("expect kind %s in %s, got %s", v1alpha3.ResourceKindPipeline, source.Path, manifest.Kind)
+			return
+		}
+		if manifest.Spec.Type == "" {
+			err = fmt.Errorf("the Pipeline type is required in %s", source.Path)
+			return
+		}
+		if fields := getProtectedFields(&manifest.Spec); len(fields) > 0 {
+			err = fmt.Errorf("%s in %s are not allowed, they are only managed through the API",
+				strings.Join(fields, ", "), source.Path)
+			return
+		}
+		spec = &manifest.Spec
+		current := pipeline.Spec.DeepCopy()
+		spec.ApprovalPolicy = current.ApprovalPolicy
+		spec.UpstreamTriggers = current.UpstreamTriggers
+	} else {
+		if pipeline.Spec.Type != "" && pipeline.Spec.Type != v1alpha3.NoScmPipelineType {
+			err = fmt.Errorf("a Jenkinsfile source is only for the %s type, the type is %s",
+				v1alpha3.NoScmPipelineType, pipeline.Spec.Type)
+			return
+		}
+		spec = pipeline.Spec.DeepCopy()
+		spec.Type = v1alpha3.NoScmPipelineType
+		if spec.Pipeline == nil {
+			spec.Pipeline = &v1alpha3.NoScmPipeline{Name: pipeline.Name}
+		}
+		spec.Pipeline.Jenkinsfile = string(data)
+	}
+	spec.Source = source.DeepCopy()
+	return
+}
+
+// getProtectedFields returns the protected fields which are set in a Pipeline spec. They decide who is able to approve
+// the PipelineRuns and which parameters are passed from other Pipelines, so the ones who are only able to push to the
+// repository are not allowed to change them.
+func getProtectedFields(spec *v1alpha3.PipelineSpec) (fields []string) {
+	if spec.ApprovalPolicy != nil {
+		fields = append(fields, "approvalPolicy")
+	}
+	if len(spec.UpstreamTriggers) > 0 {
+		fields = append(fields, "upstreamTriggers")
+	}
+	return
+}
+
+// GetRepoFullName returns the full name of a repository, such as owner/repo
+func GetRepoFullName(repo *v1alpha3.GitRepository) string {
+	if repo.Spec.Owner != "" && repo.Spec.Repo != "" {
+		if strings.HasPrefix(repo.Spec.Repo, repo.Spec.Owner+"/") {
+			// the repo format of Gitlab could be owner/repo
+			return repo.Spec.Repo
+		}
+		return repo.Spec.Owner + "/" + repo.Spec.Repo
+	}
+
+	address := repo.Spec.URL
+	if parsed, err := url.Parse(address); err == nil && parsed.Host != "" {
+		address = parsed.Path
+	} else if index := strings.Index(address, ":"); index >= 0 {
+		// the SSH address, such as git@github.com:owner/repo.git
+		address = address[index+1:]
+	}
+	return strings.TrimSuffix(strings.Trim(address, "/"), ".git")
+}
+
+func getSourceDescription(source *v1alpha3.PipelineSource) string {
+	if source.Ref == "" {
+		return fmt.Sprintf("%s/%s", source.GitRepository, source.Path)
+	}
+	return fmt.Sprintf("%s/%s@%s", source.GitRepository, source.Path, source.Ref)
+}
+
+// sourcePredicate only cares about the Git-managed Pipelines whose source changed or the synchronization was requested
+var sourcePredicate = predicate.Funcs{
+	CreateFunc: func(e event.CreateEvent) bool {
+		pipeline, ok := e.Object.(*v1alpha3.Pipeline)
+		return ok && pipeline.IsGitManaged()
+	},
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		oldPipeline, oldOK := e.ObjectOld.(*v1alpha3.Pipeline)
+		newPipeline, newOK := e.ObjectNew.(*v1alpha3.Pipeline)
+		if !oldOK || !newOK || !newPipeline.IsGitManaged() {
+			return false
+		}
+		return oldPipeline.Generation != newPipeline.Generation ||
+			oldPipeline.Annotations[v1alpha3.PipelineRequestToSyncSourceAnnoKey] !=
+				newPipeline.Annotations[v1alpha3.PipelineRequestToSyncSourceAnnoKey]
+	},
+	DeleteFunc: func(e event.DeleteEvent) bool {
+		return false
+	},
+}
+
+// GetName returns the name of this reconciler
+func (r *PipelineSourceReconciler) GetName() string {
+	return "pipeline-source-controller"
+}
+
+// GetGroupName returns the group name of the set of reconcilers
+func (r *PipelineSourceReconciler) GetGroupName() string {
+	return groupName
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *PipelineSourceReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	r.recorder = mgr.GetEventRecorderFor(r.GetName())
+	r.log = ctrl.Log.WithName(r.GetName())
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("git_repository_pipeline_source_controller").
+		For(&v1alpha3.Pipeline{}, builder.WithPredicates(sourcePredicate)).
+		Complete(r)
+}
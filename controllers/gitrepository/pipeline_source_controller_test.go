@@ -0,0 +1,287 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gitrepository
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/jenkins-x/go-scm/scm"
+	scmfake "github.com/jenkins-x/go-scm/scm/driver/fake"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/kubesphere/ks-devops/pkg/api/devops/v1alpha3"
+)
+
+const pipelineManifest = `apiVersion: devops.kubesphere.io/v1alpha3
+kind: Pipeline
+spec:
+  type: pipeline
+  pipeline:
+    name: demo
+    description: from git
+    jenkinsfile: pipeline {}
+`
+
+func TestPipelineSourceReconciler(t *testing.T) {
+	schema, err := v1alpha3.SchemeBuilder.Register().Build()
+	assert.Nil(t, err)
+
+	contentDir := t.TempDir()
+	writeFile := func(path, content string) {
+		path = filepath.Join(contentDir, "owner", "repo", path)
+		assert.Nil(t, os.MkdirAll(filepath.Dir(path), 0755))
+		assert.Nil(t, os.WriteFile(path, []byte(content), 0644))
+	}
+	writeFile("Jenkinsfile", "pipeline { agent any }")
+	writeFile(".kubesphere/pipeline.yaml", pipelineManifest)
+	writeFile(".kubesphere/invalid.yaml", "kind: Deployment\nspec: {}")
+	writeFile(".kubesphere/approvers.yaml", pipelineManifest+"  approvalPolicy:\n    approvers:\n      users: [mallory]\n")
+
+	repo := &v1alpha3.GitRepository{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "repo"},
+		Spec:       v1alpha3.GitRepositorySpec{Provider: "github", URL: "https://github.com/owner/repo.git"},
+	}
+	newPipeline := func(source *v1alpha3.PipelineSource, annotations map[string]string) *v1alpha3.Pipeline {
+		return &v1alpha3.Pipeline{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "demo", Annotations: annotations},
+			Spec: v1alpha3.PipelineSpec{
+				Type:     v1alpha3.NoScmPipelineType,
+				Pipeline: &v1alpha3.NoScmPipeline{Name: "demo", Jenkinsfile: "pipeline { agent any }"},
+				Source:   source,
+			},
+		}
+	}
+	jenkinsfileSource := &v1alpha3.PipelineSource{GitRepository: "repo", Path: "Jenkinsfile"}
+
+	tests := []struct {
+		name     string
+		pipeline *v1alpha3.Pipeline
+		objects  []client.Object
+		wantErr  bool
+		verify   func(t *testing.T, pipeline *v1alpha3.Pipeline, changed bool)
+	}{{
+		name:     "not managed by Git",
+		pipeline: newPipeline(nil, nil),
+		objects:  []client.Object{repo},
+		verify: func(t *testing.T, pipeline *v1alpha3.Pipeline, changed bool) {
+			assert.False(t, changed)
+		},
+	}, {
+		name:     "GitRepository not found",
+		pipeline: newPipeline(jenkinsfileSource, nil),
+		wantErr:  true,
+		verify: func(t *testing.T, pipeline *v1alpha3.Pipeline, changed bool) {
+			assert.False(t, changed)
+		},
+	}, {
+		name: "sync a Jenkinsfile from the default branch",
+		pipeline: newPipeline(jenkinsfileSource, map[string]string{
+			v1alpha3.PipelineSpecAuthorAnnoKey:      "admin",
+			v1alpha3.PipelineSpecChangeCauseAnnoKey: "Rolled back to revision 1",
+		}),
+		objects: []client.Object{repo},
+		verify: func(t *testing.T, pipeline *v1alpha3.Pipeline, changed bool) {
+			assert.True(t, changed)
+			assert.Equal(t, "sha-main", pipeline.Annotations[v1alpha3.PipelineSourceCommitAnnoKey])
+			assert.NotEmpty(t, pipeline.Annotations[v1alpha3.PipelineSourceSyncTimeAnnoKey])
+			assert.Equal(t, "pipeline { agent any }", pipeline.Spec.Pipeline.Jenkinsfile)
+			author, cause := pipeline.GetSpecAuthor()
+			assert.Empty(t, author, "the last API user should not be credited")
+			assert.Equal(t, "Synchronized from commit sha-main of GitRepository repo", cause)
+		},
+	}, {
+		name: "already synchronized",
+		pipeline: newPipeline(jenkinsfileSource, map[string]string{
+			v1alpha3.PipelineSourceCommitAnnoKey: "sha-main",
+		}),
+		objects: []client.Object{repo},
+		verify: func(t *testing.T, pipeline *v1alpha3.Pipeline, changed bool) {
+			assert.False(t, changed)
+		},
+	}, {
+		name: "requested by the webhook",
+		pipeline: newPipeline(jenkinsfileSource, map[string]string{
+			v1alpha3.PipelineSourceCommitAnnoKey:        "sha-main",
+			v1alpha3.PipelineRequestToSyncSourceAnnoKey: "2022-06-01T00:00:00Z",
+		}),
+		objects: []client.Object{repo},
+		verify: func(t *testing.T, pipeline *v1alpha3.Pipeline, changed bool) {
+			assert.True(t, changed)
+			assert.NotContains(t, pipeline.Annotations, v1alpha3.PipelineRequestToSyncSourceAnnoKey)
+		},
+	}, {
+		name: "sync a Pipeline manifest from a branch",
+		pipeline: newPipeline(&v1alpha3.PipelineSource{
+			GitRepository: "repo", Path: ".kubesphere/pipeline.yaml", Ref: "dev",
+			Interval: &metav1.Duration{Duration: time.Minute},
+		}, nil),
+		objects: []client.Object{repo},
+		verify: func(t *testing.T, pipeline *v1alpha3.Pipeline, changed bool) {
+			assert.True(t, changed)
+			assert.Equal(t, "sha-dev", pipeline.Annotations[v1alpha3.PipelineSourceCommitAnnoKey])
+			assert.Equal(t, "from git", pipeline.Spec.Pipeline.Description)
+			assert.Equal(t, "pipeline {}", pipeline.Spec.Pipeline.Jenkinsfile)
+			if assert.NotNil(t, pipeline.Spec.Source) {
+				assert.Equal(t, "dev", pipeline.Spec.Source.Ref)
+			}
+		},
+	}, {
+		name: "keep the protected fields of the Pipeline",
+		pipeline: func() *v1alpha3.Pipeline {
+			pipeline := newPipeline(&v1alpha3.PipelineSource{GitRepository: "repo", Path: ".kubesphere/pipeline.yaml"}, nil)
+			pipeline.Spec.ApprovalPolicy = &v1alpha3.ApprovalPolicy{Approvers: v1alpha3.Approvers{Users: []string{"alice"}}}
+			pipeline.Spec.UpstreamTriggers = []v1alpha3.UpstreamTrigger{{Pipeline: "build"}}
+			return pipeline
+		}(),
+		objects: []client.Object{repo},
+		verify: func(t *testing.T, pipeline *v1alpha3.Pipeline, changed bool) {
+			assert.True(t, changed)
+			assert.Equal(t, "from git", pipeline.Spec.Pipeline.Description)
+			if assert.NotNil(t, pipeline.Spec.ApprovalPolicy) {
+				assert.Equal(t, []string{"alice"}, pipeline.Spec.ApprovalPolicy.Approvers.Users)
+			}
+			assert.Equal(t, []v1alpha3.UpstreamTrigger{{Pipeline: "build"}}, pipeline.Spec.UpstreamTriggers)
+		},
+	}, {
+		name: "the manifest changes the approvers",
+		pipeline: newPipeline(&v1alpha3.PipelineSource{
+			GitRepository: "repo", Path: ".kubesphere/approvers.yaml",
+		}, nil),
+		objects: []client.Object{repo},
+		wantErr: true,
+		verify: func(t *testing.T, pipeline *v1alpha3.Pipeline, changed bool) {
+			assert.False(t, changed)
+			assert.Nil(t, pipeline.Spec.ApprovalPolicy)
+		},
+	}, {
+		name: "invalid Pipeline manifest",
+		pipeline: newPipeline(&v1alpha3.PipelineSource{
+			GitRepository: "repo", Path: ".kubesphere/invalid.yaml",
+		}, nil),
+		objects: []client.Object{repo},
+		wantErr: true,
+		verify: func(t *testing.T, pipeline *v1alpha3.Pipeline, changed bool) {
+			assert.False(t, changed)
+		},
+	}, {
+		name: "file not found",
+		pipeline: newPipeline(&v1alpha3.PipelineSource{
+			GitRepository: "repo", Path: "not-found",
+		}, nil),
+		objects: []client.Object{repo},
+		wantErr: true,
+		verify: func(t *testing.T, pipeline *v1alpha3.Pipeline, changed bool) {
+			assert.False(t, changed)
+		},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := fake.NewClientBuilder().WithScheme(schema).WithObjects(append(tt.objects, tt.pipeline)...).Build()
+			gitClient, data := scmfake.NewDefault()
+			data.ContentDir = contentDir
+			data.Repositories = []*scm.Repository{{FullName: "owner/repo", Branch: "main"}}
+			data.Commits = map[string]*scm.Commit{"main": {Sha: "sha-main"}, "dev": {Sha: "sha-dev"}}
+
+			r := &PipelineSourceReconciler{
+				Client:   c,
+				log:      logr.New(log.NullLogSink{}),
+				recorder: record.NewFakeRecorder(10),
+				getGitClient: func(repo *v1alpha3.GitRepository) (*scm.Client, error) {
+					return gitClient, nil
+				},
+			}
+			key := types.NamespacedName{Namespace: "ns", Name: "demo"}
+			result, err := r.Reconcile(context.TODO(), ctrl.Request{NamespacedName: key})
+			assert.Equal(t, tt.wantErr, err != nil, err)
+			if source := tt.pipeline.Spec.Source; err == nil && source != nil && source.Interval != nil {
+				assert.Equal(t, source.Interval.Duration, result.RequeueAfter)
+			}
+
+			pipeline := &v1alpha3.Pipeline{}
+			assert.Nil(t, c.Get(context.TODO(), key, pipeline))
+			tt.verify(t, pipeline, pipeline.ResourceVersion != tt.pipeline.ResourceVersion)
+		})
+	}
+}
+
+func TestGetRepoFullName(t *testing.T) {
+	tests := []struct {
+		name string
+		spec v1alpha3.GitRepositorySpec
+		want string
+	}{{
+		name: "owner and repo",
+		spec: v1alpha3.GitRepositorySpec{Owner: "owner", Repo: "repo"},
+		want: "owner/repo",
+	}, {
+		name: "Gitlab repo",
+		spec: v1alpha3.GitRepositorySpec{Owner: "owner", Repo: "owner/repo"},
+		want: "owner/repo",
+	}, {
+		name: "HTTP URL",
+		spec: v1alpha3.GitRepositorySpec{URL: "https://gitlab.com/group/sub/repo.git"},
+		want: "group/sub/repo",
+	}, {
+		name: "SSH URL",
+		spec: v1alpha3.GitRepositorySpec{URL: "git@github.com:owner/repo.git"},
+		want: "owner/repo",
+	}, {
+		name: "empty",
+		want: "",
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, GetRepoFullName(&v1alpha3.GitRepository{Spec: tt.spec}))
+		})
+	}
+}
+
+func TestSourcePredicate(t *testing.T) {
+	managed := &v1alpha3.Pipeline{
+		ObjectMeta: metav1.ObjectMeta{Generation: 1},
+		Spec:       v1alpha3.PipelineSpec{Source: &v1alpha3.PipelineSource{GitRepository: "repo", Path: "Jenkinsfile"}},
+	}
+	notManaged := &v1alpha3.Pipeline{}
+
+	assert.True(t, sourcePredicate.Create(event.CreateEvent{Object: managed}))
+	assert.False(t, sourcePredicate.Create(event.CreateEvent{Object: notManaged}))
+	assert.False(t, sourcePredicate.Delete(event.DeleteEvent{Object: managed}))
+
+	assert.False(t, sourcePredicate.Update(event.UpdateEvent{ObjectOld: managed, ObjectNew: managed.DeepCopy()}))
+	assert.False(t, sourcePredicate.Update(event.UpdateEvent{ObjectOld: notManaged, ObjectNew: notManaged.DeepCopy()}))
+
+	changed := managed.DeepCopy()
+	changed.Generation = 2
+	assert.True(t, sourcePredicate.Update(event.UpdateEvent{ObjectOld: managed, ObjectNew: changed}))
+
+	requested := managed.DeepCopy()
+	requested.Annotations = map[string]string{v1alpha3.PipelineRequestToSyncSourceAnnoKey: "now"}
+	assert.True(t, sourcePredicate.Update(event.UpdateEvent{ObjectOld: managed, ObjectNew: requested}))
+}
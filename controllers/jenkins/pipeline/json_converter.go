@@ -27,10 +27,12 @@ import (
 
 	"github.com/go-logr/logr"
 	"github.com/jenkins-zh/jenkins-client/pkg/core"
+	v1 "k8s.io/api/core/v1"
 	"k8s.io/client-go/tools/record"
 	"k8s.io/client-go/util/retry"
 
 	v1alpha3 "github.com/kubesphere/ks-devops/pkg/api/devops/v1alpha3"
+	"github.com/kubesphere/ks-devops/pkg/models/jenkinsfile"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/event"
@@ -40,10 +42,14 @@ import (
 // HttpTimeoutErrStr indicates that connection in http request is timeout(the str in error).
 const HttpTimeoutErrStr = " (Client.Timeout exceeded while awaiting headers)"
 
+// InvalidJenkinsfile is the event reason when the Jenkinsfile has syntax errors
+const InvalidJenkinsfile = "InvalidJenkinsfile"
+
 //+kubebuilder:rbac:groups=devops.kubesphere.io,resources=pipelines,verbs=get;list;update;patch;watch
 //+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
 
-// JenkinsfileReconciler will convert between JSON and Jenkinsfile (as groovy) formats
+// JenkinsfileReconciler will convert between JSON and Jenkinsfile (as groovy) formats. The native converter is used
+// first, and Jenkins is only requested for the constructs which are not supported by the native converter.
 type JenkinsfileReconciler struct {
 	log      logr.Logger
 	recorder record.EventRecorder
@@ -86,33 +92,50 @@ func (r *JenkinsfileReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 
 func (r *JenkinsfileReconciler) reconcileJenkinsfileEditMode(pip *v1alpha3.Pipeline, pipelineKey client.ObjectKey) (
 	result ctrl.Result, err error) {
-	jenkinsfile := pip.Spec.Pipeline.Jenkinsfile
+	jenkinsfileContent := pip.Spec.Pipeline.Jenkinsfile
 	toJsonJenkinsfile := ""
 	if pip.Annotations == nil {
 		pip.Annotations = map[string]string{}
 	}
+	delete(pip.Annotations, v1alpha3.PipelineJenkinsfileValidateMessageAnnoKey)
 
 	// Users are able to clean jenkinsfile
-	if jenkinsfile != "" {
-		var toJSONResult core.GenericResult
-		jenkinsfile = strings.ReplaceAll(jenkinsfile, "\\", "\\\\") // escape backslash
-		if toJSONResult, err = r.JenkinsClient.ToJSON(jenkinsfile); err != nil || toJSONResult.GetStatus() != "success" {
-			r.log.Error(err, "failed to convert jenkinsfile to json format")
-			if err != nil {
-				// ConnectRefused || Timeout when jenkins is starting(not ready), retry
-				if errors.Is(err, syscall.ECONNREFUSED) || strings.Contains(err.Error(), HttpTimeoutErrStr) {
-					r.log.Info("connect to jenkins failed, retry..")
-					return ctrl.Result{RequeueAfter: 10 * time.Second}, nil
-				}
-			}
-
+	if jenkinsfileContent != "" {
+		var convertErr error
+		toJsonJenkinsfile, convertErr = jenkinsfile.ToJSON(jenkinsfileContent)
+		switch {
+		case convertErr == nil:
+		case !jenkinsfile.IsUnsupported(convertErr):
+			r.log.Info("the Jenkinsfile is invalid", "pipeline", pipelineKey, "error", convertErr.Error())
+			r.recorder.Eventf(pip, v1.EventTypeWarning, InvalidJenkinsfile, "Invalid Jenkinsfile: %v", convertErr)
 			pip.Annotations[v1alpha3.PipelineJenkinsfileValueAnnoKey] = ""
 			pip.Annotations[v1alpha3.PipelineJenkinsfileEditModeAnnoKey] = ""
 			pip.Annotations[v1alpha3.PipelineJenkinsfileValidateAnnoKey] = v1alpha3.PipelineJenkinsfileValidateFailure
+			pip.Annotations[v1alpha3.PipelineJenkinsfileValidateMessageAnnoKey] = convertErr.Error()
 			err = r.updateAnnotations(pip.Annotations, pipelineKey)
 			return
+		default:
+			r.log.V(4).Info("fall back to the Jenkins converter", "pipeline", pipelineKey, "reason", convertErr.Error())
+			var toJSONResult core.GenericResult
+			jenkinsfileContent = strings.ReplaceAll(jenkinsfileContent, "\\", "\\\\") // escape backslash
+			if toJSONResult, err = r.JenkinsClient.ToJSON(jenkinsfileContent); err != nil || toJSONResult.GetStatus() != "success" {
+				r.log.Error(err, "failed to convert jenkinsfile to json format")
+				if err != nil {
+					// ConnectRefused || Timeout when jenkins is starting(not ready), retry
+					if errors.Is(err, syscall.ECONNREFUSED) || strings.Contains(err.Error(), HttpTimeoutErrStr) {
+						r.log.Info("connect to jenkins failed, retry..")
+						return ctrl.Result{RequeueAfter: 10 * time.Second}, nil
+					}
+				}
+
+				pip.Annotations[v1alpha3.PipelineJenkinsfileValueAnnoKey] = ""
+				pip.Annotations[v1alpha3.PipelineJenkinsfileEditModeAnnoKey] = ""
+				pip.Annotations[v1alpha3.PipelineJenkinsfileValidateAnnoKey] = v1alpha3.PipelineJenkinsfileValidateFailure
+				err = r.updateAnnotations(pip.Annotations, pipelineKey)
+				return
+			}
+			toJsonJenkinsfile = toJSONResult.GetResult()
 		}
-		toJsonJenkinsfile = toJSONResult.GetResult()
 	}
 
 	pip.Annotations[v1alpha3.PipelineJenkinsfileValueAnnoKey] = toJsonJenkinsfile
@@ -136,6 +159,7 @@ func (r *JenkinsfileReconciler) updateAnnotations(annotations map[string]string,
 		pipeline.Annotations[v1alpha3.PipelineJenkinsfileValueAnnoKey] = annotations[v1alpha3.PipelineJenkinsfileValueAnnoKey]
 		pipeline.Annotations[v1alpha3.PipelineJenkinsfileEditModeAnnoKey] = annotations[v1alpha3.PipelineJenkinsfileEditModeAnnoKey]
 		pipeline.Annotations[v1alpha3.PipelineJenkinsfileValidateAnnoKey] = annotations[v1alpha3.PipelineJenkinsfileValidateAnnoKey]
+		setValidateMessage(pipeline.Annotations, annotations)
 		return r.Update(context.Background(), pipeline)
 	})
 }
@@ -144,21 +168,27 @@ func (r *JenkinsfileReconciler) reconcileJSONEditMode(pip *v1alpha3.Pipeline, pi
 	result ctrl.Result, err error) {
 	var jsonData string
 	if jsonData = pip.Annotations[v1alpha3.PipelineJenkinsfileValueAnnoKey]; jsonData != "" {
-		var toResult core.GenericResult
-		if toResult, err = r.JenkinsClient.ToJenkinsfile(jsonData); err != nil || toResult.GetStatus() != "success" {
-			r.log.Error(err, "failed to convert json format to Jenkinsfile")
-			pip.Annotations[v1alpha3.PipelineJenkinsfileEditModeAnnoKey] = ""
-			pip.Annotations[v1alpha3.PipelineJenkinsfileValidateAnnoKey] = v1alpha3.PipelineJenkinsfileValidateFailure
-			err = r.updateAnnotations(pip.Annotations, pipelineKey)
-			return
+		delete(pip.Annotations, v1alpha3.PipelineJenkinsfileValidateMessageAnnoKey)
+		jenkinsfileContent, convertErr := jenkinsfile.ToJenkinsfile(jsonData)
+		if convertErr != nil {
+			// the JSON comes from the Pipeline editor, let Jenkins tell whether it's valid
+			r.log.V(4).Info("fall back to the Jenkins converter", "pipeline", pipelineKey, "reason", convertErr.Error())
+			var toResult core.GenericResult
+			if toResult, err = r.JenkinsClient.ToJenkinsfile(jsonData); err != nil || toResult.GetStatus() != "success" {
+				r.log.Error(err, "failed to convert json format to Jenkinsfile")
+				pip.Annotations[v1alpha3.PipelineJenkinsfileEditModeAnnoKey] = ""
+				pip.Annotations[v1alpha3.PipelineJenkinsfileValidateAnnoKey] = v1alpha3.PipelineJenkinsfileValidateFailure
+				err = r.updateAnnotations(pip.Annotations, pipelineKey)
+				return
+			}
+			jenkinsfileContent = toResult.GetResult()
+			jenkinsfileContent = strings.ReplaceAll(jenkinsfileContent, "\\\\", "\\") // unescape backslash
+			jenkinsfileContent = strings.ReplaceAll(jenkinsfileContent, `\'`, `'`)    // unescape single quote
 		}
-		jenkinsfile := toResult.GetResult()
-		jenkinsfile = strings.ReplaceAll(jenkinsfile, "\\\\", "\\") // unescape backslash
-		jenkinsfile = strings.ReplaceAll(jenkinsfile, `\'`, `'`)    // unescape single quote
 
 		pip.Annotations[v1alpha3.PipelineJenkinsfileEditModeAnnoKey] = ""
 		pip.Annotations[v1alpha3.PipelineJenkinsfileValidateAnnoKey] = v1alpha3.PipelineJenkinsfileValidateSuccess
-		err = r.updateAnnotationsAndJenkinsfile(pip.Annotations, jenkinsfile, pipelineKey)
+		err = r.updateAnnotationsAndJenkinsfile(pip.Annotations, jenkinsfileContent, pipelineKey)
 	}
 	return
 }
@@ -178,11 +208,24 @@ func (r *JenkinsfileReconciler) updateAnnotationsAndJenkinsfile(annotations map[
 		// update annotations
 		pipeline.Annotations[v1alpha3.PipelineJenkinsfileEditModeAnnoKey] = annotations[v1alpha3.PipelineJenkinsfileEditModeAnnoKey]
 		pipeline.Annotations[v1alpha3.PipelineJenkinsfileValidateAnnoKey] = annotations[v1alpha3.PipelineJenkinsfileValidateAnnoKey]
+		setValidateMessage(pipeline.Annotations, annotations)
+		// the converted Jenkinsfile still belongs to the user who edited the JSON
+		author, cause := pipeline.GetSpecAuthor()
 		pipeline.Spec.Pipeline.Jenkinsfile = jenkinsfile
+		pipeline.SetSpecAuthor(author, cause)
 		return r.Update(context.Background(), pipeline)
 	})
 }
 
+// setValidateMessage copies the validate message, the annotation is removed if there is no message
+func setValidateMessage(target, source map[string]string) {
+	if message := source[v1alpha3.PipelineJenkinsfileValidateMessageAnnoKey]; message != "" {
+		target[v1alpha3.PipelineJenkinsfileValidateMessageAnnoKey] = message
+	} else {
+		delete(target, v1alpha3.PipelineJenkinsfileValidateMessageAnnoKey)
+	}
+}
+
 // GetName returns the name of this controller
 func (r *JenkinsfileReconciler) GetName() string {
 	return "JenkinsfileController"
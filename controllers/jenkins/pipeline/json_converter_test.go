@@ -74,6 +74,15 @@ func TestJenkinsfileReconciler_Reconcile(t *testing.T) {
 	irregularPip := pip.DeepCopy()
 	irregularPip.Spec.Type = ""
 
+	declarativePip := pip.DeepCopy()
+	declarativePip.Spec.Pipeline.Jenkinsfile = "pipeline {\n  agent any\n  stages {\n    stage('build') {\n      steps {\n        sh 'make'\n      }\n    }\n  }\n}\n"
+
+	invalidPip := pip.DeepCopy()
+	invalidPip.Spec.Pipeline.Jenkinsfile = "pipeline {\n  agent any\n  stages {\n  }\n}\n"
+
+	declarativeJSONPip := jsonEditModePip.DeepCopy()
+	declarativeJSONPip.Annotations[v1alpha3.PipelineJenkinsfileValueAnnoKey] = `{"pipeline":{"agent":{"type":"any"},"stages":[{"name":"build","branches":[{"name":"default","steps":[{"name":"sh","arguments":[{"key":"script","value":{"isLiteral":true,"value":"make"}}]}]}]}]}}`
+
 	type fields struct {
 		Client        client.Client
 		log           logr.Logger
@@ -212,6 +221,64 @@ func TestJenkinsfileReconciler_Reconcile(t *testing.T) {
 			assert.Nil(t, err)
 			return true
 		},
+	}, {
+		name: "a declarative Jenkinsfile is converted without Jenkins",
+		fields: fields{
+			Client: fake.NewClientBuilder().WithScheme(schema).WithRuntimeObjects(declarativePip).Build(),
+		},
+		args: args{
+			req: defaultReq,
+		},
+		verify: func(t *testing.T, Client client.Client) {
+			pip := &v1alpha3.Pipeline{}
+			err := Client.Get(context.Background(), defaultReq.NamespacedName, pip)
+			assert.Nil(t, err)
+			assert.JSONEq(t, declarativeJSONPip.Annotations[v1alpha3.PipelineJenkinsfileValueAnnoKey], pip.Annotations[v1alpha3.PipelineJenkinsfileValueAnnoKey])
+			assert.Equal(t, v1alpha3.PipelineJenkinsfileValidateSuccess, pip.Annotations[v1alpha3.PipelineJenkinsfileValidateAnnoKey])
+		},
+		wantErr: func(t assert.TestingT, err error, i ...interface{}) bool {
+			assert.Nil(t, err)
+			return true
+		},
+	}, {
+		name: "an invalid Jenkinsfile",
+		fields: fields{
+			Client:   fake.NewClientBuilder().WithScheme(schema).WithRuntimeObjects(invalidPip).Build(),
+			recorder: record.NewFakeRecorder(10),
+		},
+		args: args{
+			req: defaultReq,
+		},
+		verify: func(t *testing.T, Client client.Client) {
+			pip := &v1alpha3.Pipeline{}
+			err := Client.Get(context.Background(), defaultReq.NamespacedName, pip)
+			assert.Nil(t, err)
+			assert.Equal(t, v1alpha3.PipelineJenkinsfileValidateFailure, pip.Annotations[v1alpha3.PipelineJenkinsfileValidateAnnoKey])
+			assert.Equal(t, "line 3, column 10: no stage in the block", pip.Annotations[v1alpha3.PipelineJenkinsfileValidateMessageAnnoKey])
+		},
+		wantErr: func(t assert.TestingT, err error, i ...interface{}) bool {
+			assert.Nil(t, err)
+			return true
+		},
+	}, {
+		name: "the JSON is converted without Jenkins",
+		fields: fields{
+			Client: fake.NewClientBuilder().WithScheme(schema).WithRuntimeObjects(declarativeJSONPip).Build(),
+		},
+		args: args{
+			req: defaultReq,
+		},
+		verify: func(t *testing.T, Client client.Client) {
+			pip := &v1alpha3.Pipeline{}
+			err := Client.Get(context.Background(), defaultReq.NamespacedName, pip)
+			assert.Nil(t, err)
+			assert.Equal(t, declarativePip.Spec.Pipeline.Jenkinsfile, pip.Spec.Pipeline.Jenkinsfile)
+			assert.Equal(t, v1alpha3.PipelineJenkinsfileValidateSuccess, pip.Annotations[v1alpha3.PipelineJenkinsfileValidateAnnoKey])
+		},
+		wantErr: func(t assert.TestingT, err error, i ...interface{}) bool {
+			assert.Nil(t, err)
+			return true
+		},
 	}}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
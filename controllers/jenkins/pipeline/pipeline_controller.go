@@ -260,10 +260,11 @@ func (c *Controller) syncHandler(key string) error {
 
 		// Check pipeline config exists, otherwise we will create it.
 		// if pipeline exists, check & update config
+		desiredPipeline := getJenkinsPipeline(copyPipeline)
 		jenkinsPipeline, err := c.devopsClient.GetProjectPipelineConfig(nsName, pipeline.Name)
 		if err == nil {
-			if !reflect.DeepEqual(jenkinsPipeline.Spec, copyPipeline.Spec) {
-				_, err := c.devopsClient.UpdateProjectPipeline(nsName, copyPipeline)
+			if !isJenkinsPipelineSynced(&jenkinsPipeline.Spec, &desiredPipeline.Spec) {
+				_, err := c.devopsClient.UpdateProjectPipeline(nsName, desiredPipeline)
 				if err != nil {
 					klog.ErrorS(err, fmt.Sprintf("failed to update pipeline config %s ", key))
 					return err
@@ -272,7 +273,7 @@ func (c *Controller) syncHandler(key string) error {
 				klog.V(8).Info(fmt.Sprintf("nothing was changed, pipeline '%v'", copyPipeline.Spec))
 			}
 		} else {
-			_, err = c.devopsClient.CreateProjectPipeline(nsName, copyPipeline)
+			_, err = c.devopsClient.CreateProjectPipeline(nsName, desiredPipeline)
 			if err != nil {
 				klog.ErrorS(err, fmt.Sprintf("failed to create copyPipeline %s ", key))
 				return err
@@ -346,3 +347,21 @@ func (c *Controller) updatePipeline(ctx context.Context, name string, nsName str
 		return err
 	})
 }
+
+// getJenkinsPipeline returns the Pipeline which is synchronized to Jenkins. The cron of the timer trigger is handled by
+// the schedule controller, so Jenkins should not trigger the builds again.
+func getJenkinsPipeline(pipeline *devopsv1alpha3.Pipeline) *devopsv1alpha3.Pipeline {
+	if pipeline.Spec.Pipeline == nil || pipeline.Spec.Pipeline.TimerTrigger == nil {
+		return pipeline
+	}
+	pipeline = pipeline.DeepCopy()
+	pipeline.Spec.Pipeline.TimerTrigger = nil
+	return pipeline
+}
+
+// isJenkinsPipelineSynced checks the fields which are synchronized to Jenkins only, the others are never set by Jenkins
+func isJenkinsPipelineSynced(jenkinsSpec, desiredSpec *devopsv1alpha3.PipelineSpec) bool {
+	return jenkinsSpec.Type == desiredSpec.Type &&
+		reflect.DeepEqual(jenkinsSpec.Pipeline, desiredSpec.Pipeline) &&
+		reflect.DeepEqual(jenkinsSpec.MultiBranchPipeline, desiredSpec.MultiBranchPipeline)
+}
@@ -45,6 +45,7 @@ import (
 	"github.com/jenkins-zh/jenkins-client/pkg/mock/mhttp"
 	"github.com/kubesphere/ks-devops/pkg/client/clientset/versioned/fake"
 	informers "github.com/kubesphere/ks-devops/pkg/client/informers/externalversions"
+	"github.com/stretchr/testify/assert"
 )
 
 var (
@@ -438,3 +439,40 @@ func TestUpdatePipelineConfig(t *testing.T) {
 	f.expectPipeline = []*devops.Pipeline{expectPipeline}
 	f.run(getKey(modifiedPipeline, t))
 }
+
+func TestGetJenkinsPipeline(t *testing.T) {
+	pipeline := newPipeline("ns", "test", devops.PipelineSpec{
+		Type: devops.NoScmPipelineType,
+		Pipeline: &devops.NoScmPipeline{
+			Name:         "test",
+			TimerTrigger: &devops.TimerTrigger{Cron: "H * * * *"},
+		},
+	}, true, false)
+
+	jenkinsPipeline := getJenkinsPipeline(pipeline)
+	assert.Nil(t, jenkinsPipeline.Spec.Pipeline.TimerTrigger)
+	assert.NotNil(t, pipeline.Spec.Pipeline.TimerTrigger)
+
+	noTrigger := newPipeline("ns", "test", devops.PipelineSpec{}, true, false)
+	assert.Equal(t, noTrigger, getJenkinsPipeline(noTrigger))
+}
+
+func TestIsJenkinsPipelineSynced(t *testing.T) {
+	jenkinsSpec := &devops.PipelineSpec{
+		Type:     devops.NoScmPipelineType,
+		Pipeline: &devops.NoScmPipeline{Name: "test"},
+	}
+
+	// the fields which are not synchronized to Jenkins are ignored
+	desiredSpec := jenkinsSpec.DeepCopy()
+	desiredSpec.ConcurrencyPolicy = &devops.ConcurrencyPolicy{Type: devops.ConcurrencyForbid}
+	desiredSpec.Schedule = &devops.Schedule{Cron: "H * * * *"}
+	assert.True(t, isJenkinsPipelineSynced(jenkinsSpec, desiredSpec))
+
+	desiredSpec.Pipeline.Description = "changed"
+	assert.False(t, isJenkinsPipelineSynced(jenkinsSpec, desiredSpec))
+
+	desiredSpec = jenkinsSpec.DeepCopy()
+	desiredSpec.Type = devops.MultiBranchPipelineType
+	assert.False(t, isJenkinsPipelineSynced(jenkinsSpec, desiredSpec))
+}
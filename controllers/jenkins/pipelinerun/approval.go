@@ -0,0 +1,111 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinerun
+
+import (
+	"context"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/kubesphere/ks-devops/pkg/api/devops/v1alpha3"
+	devopsClient "github.com/kubesphere/ks-devops/pkg/client/devops"
+	"github.com/kubesphere/ks-devops/pkg/models/pipelinerun"
+)
+
+//+kubebuilder:rbac:groups=devops.kubesphere.io,resources=approvals,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=devops.kubesphere.io,resources=approvals/status,verbs=get;update;patch
+
+// syncApprovals creates the Approvals for the paused input steps, and aborts the input steps whose Approvals timed out.
+// The decisions of the completed Approvals are forwarded to Jenkins until they succeeded.
+func (r *Reconciler) syncApprovals(ctx context.Context, pipeline *v1alpha3.Pipeline, pr *v1alpha3.PipelineRun,
+	nodeDetails []pipelinerun.NodeDetail) (err error) {
+	for _, node := range nodeDetails {
+		for _, step := range node.Steps {
+			if step.Input == nil || step.State != devopsClient.StatePaused {
+				continue
+			}
+			approval := pipelinerun.NewApproval(pr, pipeline.Spec.ApprovalPolicy, node, step)
+			if err = r.Create(ctx, approval); err == nil {
+				r.recorder.Eventf(pr, corev1.EventTypeNormal, v1alpha3.ApprovalRequested,
+					"Step %s of PipelineRun %s/%s is waiting for the approval %s", step.DisplayName, pr.Namespace, pr.Name, approval.Name)
+			} else if !apierrors.IsAlreadyExists(err) {
+				return
+			}
+		}
+	}
+
+	approvalList := &v1alpha3.ApprovalList{}
+	if err = r.List(ctx, approvalList, client.InNamespace(pr.Namespace),
+		client.MatchingLabels{v1alpha3.PipelineRunNameLabelKey: pr.Name}); err != nil {
+		return
+	}
+	now := time.Now()
+	for i := range approvalList.Items {
+		approval := &approvalList.Items[i]
+		if !approval.HasCompleted() && approval.HasTimedOut(now) {
+			// record the timeout before aborting the input step
+			approval.Status.Phase = v1alpha3.ApprovalTimedOut
+			approval.Status.CompletionTime = &v1.Time{Time: now}
+			if err = r.Status().Update(ctx, approval); err != nil {
+				return
+			}
+			r.recorder.Eventf(pr, corev1.EventTypeNormal, v1alpha3.ApprovalCompleted,
+				"Approval %s of PipelineRun %s/%s timed out, the input step is aborted", approval.Name, pr.Namespace, pr.Name)
+		}
+		if !approval.NeedsSubmission() {
+			continue
+		}
+		if err = r.submitApproval(ctx, pr, approval, isInputPaused(nodeDetails, approval)); err != nil {
+			r.recorder.Eventf(pr, corev1.EventTypeWarning, v1alpha3.ApprovalFailed,
+				"Failed to submit the approval %s to Jenkins, and error was %v", approval.Name, err)
+			return
+		}
+	}
+	return
+}
+
+// submitApproval forwards the decision of a completed Approval to Jenkins, then marks it as submitted. The decision is
+// not forwarded if the input step is not paused anymore, it has been forwarded or the run has stopped.
+func (r *Reconciler) submitApproval(ctx context.Context, pr *v1alpha3.PipelineRun, approval *v1alpha3.Approval,
+	paused bool) (err error) {
+	if paused && r.DevOpsClient != nil {
+		if err = pipelinerun.SubmitApproval(r.DevOpsClient, pr, approval); err != nil {
+			return
+		}
+	}
+	approval.Status.Submitted = true
+	return r.Status().Update(ctx, approval)
+}
+
+// isInputPaused checks if the input step of the Approval is paused
+func isInputPaused(nodeDetails []pipelinerun.NodeDetail, approval *v1alpha3.Approval) bool {
+	for _, node := range nodeDetails {
+		if node.ID != approval.Spec.NodeID {
+			continue
+		}
+		for _, step := range node.Steps {
+			if step.ID == approval.Spec.StepID {
+				return step.Input != nil && step.State == devopsClient.StatePaused
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,145 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinerun
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jenkins-zh/jenkins-client/pkg/job"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/kubesphere/ks-devops/pkg/api/devops/v1alpha3"
+	"github.com/kubesphere/ks-devops/pkg/models/pipelinerun"
+)
+
+func TestReconciler_syncApprovals(t *testing.T) {
+	schema, err := v1alpha3.SchemeBuilder.Register().Build()
+	assert.Nil(t, err)
+	err = corev1.SchemeBuilder.AddToScheme(schema)
+	assert.Nil(t, err)
+
+	pr := &v1alpha3.PipelineRun{
+		ObjectMeta: v1.ObjectMeta{
+			Namespace: "ns",
+			Name:      "run",
+			Labels:    map[string]string{v1alpha3.PipelineNameLabelKey: "pipeline"},
+		},
+	}
+	pipeline := &v1alpha3.Pipeline{
+		ObjectMeta: v1.ObjectMeta{Namespace: "ns", Name: "pipeline"},
+		Spec: v1alpha3.PipelineSpec{ApprovalPolicy: &v1alpha3.ApprovalPolicy{
+			Approvers:         v1alpha3.Approvers{Roles: []string{"reviewer"}},
+			RequiredApprovals: 2,
+			Timeout:           &v1.Duration{Duration: time.Hour},
+		}},
+	}
+	nodeDetails := []pipelinerun.NodeDetail{{
+		Node: job.Node{ID: "10", State: "FINISHED"},
+		Steps: []pipelinerun.Step{{
+			Step: job.Step{ID: "11", State: "FINISHED"},
+		}},
+	}, {
+		Node: job.Node{ID: "20", State: "PAUSED"},
+		Steps: []pipelinerun.Step{{
+			Step: job.Step{ID: "21", State: "PAUSED", Input: &job.Input{ID: "Deploy", Message: "deploy?", Submitter: "admin, ops"}},
+		}},
+	}}
+	timedOut := &v1alpha3.Approval{
+		ObjectMeta: v1.ObjectMeta{
+			Namespace:         "ns",
+			Name:              "run-30-31",
+			Labels:            map[string]string{v1alpha3.PipelineRunNameLabelKey: "run"},
+			CreationTimestamp: v1.NewTime(time.Now().Add(-2 * time.Hour)),
+		},
+		Spec: v1alpha3.ApprovalSpec{
+			ApprovalPolicy: v1alpha3.ApprovalPolicy{Timeout: &v1.Duration{Duration: time.Hour}},
+			NodeID:         "30",
+			StepID:         "31",
+		},
+		Status: v1alpha3.ApprovalStatus{Phase: v1alpha3.ApprovalPending},
+	}
+
+	// the decision was recorded, but the input step had proceeded before it was marked as submitted
+	approved := &v1alpha3.Approval{
+		ObjectMeta: v1.ObjectMeta{
+			Namespace: "ns",
+			Name:      "run-40-41",
+			Labels:    map[string]string{v1alpha3.PipelineRunNameLabelKey: "run"},
+		},
+		Spec:   v1alpha3.ApprovalSpec{NodeID: "40", StepID: "41"},
+		Status: v1alpha3.ApprovalStatus{Phase: v1alpha3.ApprovalApproved},
+	}
+
+	c := fake.NewClientBuilder().WithScheme(schema).WithObjects(pr.DeepCopy(), timedOut.DeepCopy(), approved.DeepCopy()).
+		WithStatusSubresource(&v1alpha3.Approval{}).Build()
+	recorder := record.NewFakeRecorder(10)
+	r := &Reconciler{Client: c, recorder: recorder}
+
+	// it is safe to sync again
+	assert.Nil(t, r.syncApprovals(context.Background(), pipeline, pr, nodeDetails))
+	assert.Nil(t, r.syncApprovals(context.Background(), pipeline, pr, nodeDetails))
+	assert.Len(t, recorder.Events, 2)
+
+	list := &v1alpha3.ApprovalList{}
+	assert.Nil(t, c.List(context.Background(), list, client.InNamespace("ns")))
+	assert.Len(t, list.Items, 3)
+
+	approval := &v1alpha3.Approval{}
+	assert.Nil(t, c.Get(context.Background(), client.ObjectKey{Namespace: "ns", Name: "run-20-21"}, approval))
+	assert.Equal(t, "run", approval.Spec.PipelineRunRef.Name)
+	assert.Equal(t, "Deploy", approval.Spec.InputID)
+	assert.Equal(t, "deploy?", approval.Spec.Message)
+	assert.Equal(t, []string{"admin", "ops"}, approval.Spec.Approvers.Users)
+	assert.Equal(t, []string{"admin", "ops"}, approval.Spec.Approvers.Groups)
+	assert.Equal(t, []string{"reviewer"}, approval.Spec.Approvers.Roles)
+	assert.Equal(t, 2, approval.GetRequiredApprovals())
+	assert.Equal(t, "pipeline", approval.Labels[v1alpha3.PipelineNameLabelKey])
+	assert.Equal(t, v1alpha3.ApprovalPending, approval.Status.Phase)
+	assert.False(t, approval.Status.Submitted)
+
+	assert.Nil(t, c.Get(context.Background(), client.ObjectKey{Namespace: "ns", Name: "run-30-31"}, approval))
+	assert.Equal(t, v1alpha3.ApprovalTimedOut, approval.Status.Phase)
+	assert.NotNil(t, approval.Status.CompletionTime)
+	assert.True(t, approval.Status.Submitted)
+
+	assert.Nil(t, c.Get(context.Background(), client.ObjectKey{Namespace: "ns", Name: "run-40-41"}, approval))
+	assert.True(t, approval.Status.Submitted)
+}
+
+func Test_isInputPaused(t *testing.T) {
+	nodeDetails := []pipelinerun.NodeDetail{{
+		Node: job.Node{ID: "20", State: "PAUSED"},
+		Steps: []pipelinerun.Step{{
+			Step: job.Step{ID: "21", State: "PAUSED", Input: &job.Input{ID: "Deploy"}},
+		}, {
+			Step: job.Step{ID: "22", State: "FINISHED", Input: &job.Input{ID: "Test"}},
+		}},
+	}}
+	newApproval := func(nodeID, stepID string) *v1alpha3.Approval {
+		return &v1alpha3.Approval{Spec: v1alpha3.ApprovalSpec{NodeID: nodeID, StepID: stepID}}
+	}
+	assert.True(t, isInputPaused(nodeDetails, newApproval("20", "21")))
+	assert.False(t, isInputPaused(nodeDetails, newApproval("20", "22")))
+	assert.False(t, isInputPaused(nodeDetails, newApproval("30", "31")))
+}
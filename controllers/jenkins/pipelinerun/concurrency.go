@@ -0,0 +1,226 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinerun
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/kubesphere/ks-devops/pkg/api/devops/v1alpha3"
+)
+
+// queuePollInterval is the interval of checking if a queued PipelineRun is able to run
+var queuePollInterval = 5 * time.Second
+
+// applyConcurrencyPolicy applies the concurrency policy of the Pipeline before triggering the PipelineRun.
+// It returns false if the PipelineRun cannot run now, it was either queued or cancelled.
+func (r *Reconciler) applyConcurrencyPolicy(ctx context.Context, pipeline *v1alpha3.Pipeline, pr *v1alpha3.PipelineRun) (
+	proceed bool, err error) {
+	policy := pipeline.Spec.GetConcurrencyPolicy()
+	if policy.Type == v1alpha3.ConcurrencyAllow {
+		return true, nil
+	}
+
+	var previous []v1alpha3.PipelineRun
+	if previous, err = r.getPreviousPipelineRuns(ctx, pipeline, pr, policy); err != nil {
+		return
+	}
+
+	switch policy.Type {
+	case v1alpha3.ConcurrencyForbid:
+		if len(previous) > 0 {
+			err = r.cancelPipelineRun(ctx, pr, v1alpha3.ConcurrencyForbidden,
+				fmt.Sprintf("the PipelineRun was cancelled because PipelineRun %s is not completed", previous[0].Name))
+			return
+		}
+	case v1alpha3.ConcurrencyReplace:
+		for i := range previous {
+			if err = stopPipelineRun(ctx, r.Client, &previous[i]); err != nil {
+				return
+			}
+			r.recorder.Eventf(&previous[i], corev1.EventTypeNormal, v1alpha3.Replaced,
+				"PipelineRun %s/%s was replaced by PipelineRun %s", previous[i].Namespace, previous[i].Name, pr.Name)
+		}
+	case v1alpha3.ConcurrencyQueue:
+		if len(previous) > 0 {
+			// only the queued PipelineRuns are counted in the position
+			position := 1
+			for i := range previous {
+				if !previous[i].HasStarted() {
+					position++
+				}
+			}
+			if policy.MaxQueueDepth > 0 && position > policy.MaxQueueDepth {
+				err = r.cancelPipelineRun(ctx, pr, v1alpha3.QueueFull,
+					fmt.Sprintf("the PipelineRun was cancelled because the queue is full, the max depth is %d", policy.MaxQueueDepth))
+				return
+			}
+			err = r.queuePipelineRun(ctx, pr, position)
+			return
+		}
+		if condition := getCondition(&pr.Status, v1alpha3.ConditionQueued); condition != nil && condition.Status == v1alpha3.ConditionTrue {
+			// the status will be updated once the PipelineRun is triggered
+			now := v1.Now()
+			pr.Status.AddCondition(&v1alpha3.Condition{
+				Type:          v1alpha3.ConditionQueued,
+				Status:        v1alpha3.ConditionFalse,
+				Reason:        v1alpha3.Dequeued,
+				Message:       "the PipelineRun left the queue",
+				LastProbeTime: now,
+			})
+			r.recorder.Eventf(pr, corev1.EventTypeNormal, v1alpha3.Dequeued, "PipelineRun %s/%s left the queue", pr.Namespace, pr.Name)
+		}
+	}
+	return true, nil
+}
+
+// getPreviousPipelineRuns returns the PipelineRuns in the same group which are not completed, and they either started
+// or were created before the current one
+func (r *Reconciler) getPreviousPipelineRuns(ctx context.Context, pipeline *v1alpha3.Pipeline, pr *v1alpha3.PipelineRun,
+	policy v1alpha3.ConcurrencyPolicy) (previous []v1alpha3.PipelineRun, err error) {
+	pipelineRuns := &v1alpha3.PipelineRunList{}
+	if err = r.List(ctx, pipelineRuns, client.InNamespace(pipeline.Namespace),
+		client.MatchingLabels{v1alpha3.PipelineNameLabelKey: pipeline.Name}); err != nil {
+		return
+	}
+
+	key := concurrencyGroupKey(policy, pr)
+	for _, item := range pipelineRuns.Items {
+		if item.Name == pr.Name || !item.Buildable() || !item.DeletionTimestamp.IsZero() || item.IsMatrix() ||
+			concurrencyGroupKey(policy, &item) != key {
+			continue
+		}
+		if item.HasStarted() || createdBefore(&item, pr) {
+			previous = append(previous, item)
+		}
+	}
+	return
+}
+
+// queuePipelineRun keeps the PipelineRun pending, the position in the queue is recorded in the Queued condition
+func (r *Reconciler) queuePipelineRun(ctx context.Context, pr *v1alpha3.PipelineRun, position int) (err error) {
+	message := fmt.Sprintf("the PipelineRun is at position %d of the queue", position)
+	if condition := getCondition(&pr.Status, v1alpha3.ConditionQueued); condition != nil &&
+		condition.Status == v1alpha3.ConditionTrue && condition.Message == message && pr.Status.Phase == v1alpha3.Pending {
+		return
+	}
+	// the Pipeline label is required to find out the queued PipelineRuns
+	if err = r.updateLabelsAndAnnotations(ctx, pr); err != nil {
+		return
+	}
+
+	now := v1.Now()
+	status := pr.Status.DeepCopy()
+	status.Phase = v1alpha3.Pending
+	status.UpdateTime = &now
+	status.AddCondition(&v1alpha3.Condition{
+		Type:          v1alpha3.ConditionQueued,
+		Status:        v1alpha3.ConditionTrue,
+		Reason:        v1alpha3.Queued,
+		Message:       message,
+		LastProbeTime: now,
+	})
+	if err = r.updateStatus(ctx, status, client.ObjectKeyFromObject(pr)); err == nil {
+		r.recorder.Eventf(pr, corev1.EventTypeNormal, v1alpha3.Queued, "PipelineRun %s/%s is at position %d of the queue",
+			pr.Namespace, pr.Name, position)
+	}
+	return
+}
+
+// cancelPipelineRun completes the PipelineRun which has not started as cancelled
+func (r *Reconciler) cancelPipelineRun(ctx context.Context, pr *v1alpha3.PipelineRun, reason, message string) (err error) {
+	now := v1.Now()
+	status := pr.Status.DeepCopy()
+	status.Phase = v1alpha3.Cancelled
+	status.CompletionTime = &now
+	status.UpdateTime = &now
+	if condition := getCondition(status, v1alpha3.ConditionQueued); condition != nil {
+		condition.Status = v1alpha3.ConditionFalse
+		condition.LastProbeTime = now
+	}
+	status.AddCondition(&v1alpha3.Condition{
+		Type:          v1alpha3.ConditionSucceeded,
+		Status:        v1alpha3.ConditionFalse,
+		Reason:        reason,
+		Message:       message,
+		LastProbeTime: now,
+	})
+	if err = r.updateStatus(ctx, status, client.ObjectKeyFromObject(pr)); err == nil {
+		r.recorder.Eventf(pr, corev1.EventTypeWarning, reason, "PipelineRun %s/%s was cancelled: %s", pr.Namespace, pr.Name, message)
+	}
+	return
+}
+
+// stopPipelineRun sets the Stop action to the PipelineRun, then it will be stopped in its own reconciling
+func stopPipelineRun(ctx context.Context, c client.Client, pr *v1alpha3.PipelineRun) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() (err error) {
+		latest := &v1alpha3.PipelineRun{}
+		if err = c.Get(ctx, client.ObjectKeyFromObject(pr), latest); err != nil {
+			return client.IgnoreNotFound(err)
+		}
+		if latest.Spec.Action != nil && *latest.Spec.Action == v1alpha3.Stop {
+			return
+		}
+		action := v1alpha3.Stop
+		latest.Spec.Action = &action
+		return c.Update(ctx, latest)
+	})
+}
+
+// concurrencyGroupKey returns the group key of a PipelineRun, the concurrency policy limits the PipelineRuns in a group
+func concurrencyGroupKey(policy v1alpha3.ConcurrencyPolicy, pr *v1alpha3.PipelineRun) string {
+	var items []string
+	if policy.KeyByBranch && pr.Spec.SCM != nil {
+		items = append(items, pr.Spec.SCM.RefName)
+	}
+	for _, name := range policy.KeyByParameters {
+		var value string
+		for _, param := range pr.Spec.Parameters {
+			if param.Name == name {
+				value = param.Value
+				break
+			}
+		}
+		items = append(items, name+"="+value)
+	}
+	return strings.Join(items, ",")
+}
+
+// createdBefore checks if the PipelineRun a was created before b, the name decides the order if they have the same
+// creation timestamp
+func createdBefore(a, b *v1alpha3.PipelineRun) bool {
+	if !a.CreationTimestamp.Equal(&b.CreationTimestamp) {
+		return a.CreationTimestamp.Before(&b.CreationTimestamp)
+	}
+	return a.Name < b.Name
+}
+
+func getCondition(status *v1alpha3.PipelineRunStatus, conditionType v1alpha3.ConditionType) *v1alpha3.Condition {
+	for i := range status.Conditions {
+		if status.Conditions[i].Type == conditionType {
+			return &status.Conditions[i]
+		}
+	}
+	return nil
+}
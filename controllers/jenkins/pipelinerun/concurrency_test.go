@@ -0,0 +1,211 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinerun
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/kubesphere/ks-devops/pkg/api/devops/v1alpha3"
+)
+
+func TestReconciler_applyConcurrencyPolicy(t *testing.T) {
+	schema, err := v1alpha3.SchemeBuilder.Register().Build()
+	assert.Nil(t, err)
+	err = corev1.SchemeBuilder.AddToScheme(schema)
+	assert.Nil(t, err)
+
+	now := time.Now()
+	newPipelineRun := func(name string, created time.Time, started bool, params ...v1alpha3.Parameter) *v1alpha3.PipelineRun {
+		pr := &v1alpha3.PipelineRun{
+			ObjectMeta: v1.ObjectMeta{
+				Namespace:         "ns",
+				Name:              name,
+				CreationTimestamp: v1.Time{Time: created},
+				Labels:            map[string]string{v1alpha3.PipelineNameLabelKey: "pipeline"},
+				Annotations:       map[string]string{},
+			},
+			Spec: v1alpha3.PipelineRunSpec{Parameters: params},
+		}
+		if started {
+			pr.Annotations[v1alpha3.JenkinsPipelineRunIDAnnoKey] = "1"
+		}
+		return pr
+	}
+	newPipeline := func(policy *v1alpha3.ConcurrencyPolicy) *v1alpha3.Pipeline {
+		return &v1alpha3.Pipeline{
+			ObjectMeta: v1.ObjectMeta{Namespace: "ns", Name: "pipeline"},
+			Spec:       v1alpha3.PipelineSpec{ConcurrencyPolicy: policy},
+		}
+	}
+
+	running := newPipelineRun("running", now.Add(-2*time.Minute), true)
+	queued := newPipelineRun("queued", now.Add(-time.Minute), false)
+	later := newPipelineRun("later", now.Add(time.Minute), false)
+	completed := newPipelineRun("completed", now.Add(-time.Hour), true)
+	completed.Status.CompletionTime = &v1.Time{Time: now}
+	current := newPipelineRun("current", now, false)
+	matrix := newPipelineRun("matrix", now.Add(-time.Minute), false)
+	matrix.Spec.Matrix = &v1alpha3.Matrix{Axes: []v1alpha3.MatrixAxis{{Name: "jdk", Values: []string{"8"}}}}
+
+	tests := []struct {
+		name        string
+		policy      *v1alpha3.ConcurrencyPolicy
+		objects     []client.Object
+		pr          *v1alpha3.PipelineRun
+		wantProceed bool
+		verify      func(t *testing.T, c client.Client, pr *v1alpha3.PipelineRun)
+	}{{
+		name:        "allow by default",
+		objects:     []client.Object{running},
+		pr:          current,
+		wantProceed: true,
+	}, {
+		name:        "forbid without previous PipelineRuns",
+		policy:      &v1alpha3.ConcurrencyPolicy{Type: v1alpha3.ConcurrencyForbid},
+		objects:     []client.Object{completed, later},
+		pr:          current,
+		wantProceed: true,
+	}, {
+		name:    "forbid",
+		policy:  &v1alpha3.ConcurrencyPolicy{Type: v1alpha3.ConcurrencyForbid},
+		objects: []client.Object{running},
+		pr:      current,
+		verify: func(t *testing.T, c client.Client, pr *v1alpha3.PipelineRun) {
+			assert.Equal(t, v1alpha3.Cancelled, pr.Status.Phase)
+			assert.True(t, pr.HasCompleted())
+			assert.Equal(t, v1alpha3.ConcurrencyForbidden, getCondition(&pr.Status, v1alpha3.ConditionSucceeded).Reason)
+		},
+	}, {
+		name:        "replace",
+		policy:      &v1alpha3.ConcurrencyPolicy{Type: v1alpha3.ConcurrencyReplace},
+		objects:     []client.Object{running, queued, later},
+		pr:          current,
+		wantProceed: true,
+		verify: func(t *testing.T, c client.Client, _ *v1alpha3.PipelineRun) {
+			for _, name := range []string{"running", "queued"} {
+				pr := &v1alpha3.PipelineRun{}
+				assert.Nil(t, c.Get(context.Background(), client.ObjectKey{Namespace: "ns", Name: name}, pr))
+				assert.Equal(t, v1alpha3.Stop, *pr.Spec.Action)
+			}
+			pr := &v1alpha3.PipelineRun{}
+			assert.Nil(t, c.Get(context.Background(), client.ObjectKey{Namespace: "ns", Name: "later"}, pr))
+			assert.Nil(t, pr.Spec.Action)
+		},
+	}, {
+		name:        "the matrix PipelineRuns are not counted",
+		policy:      &v1alpha3.ConcurrencyPolicy{Type: v1alpha3.ConcurrencyForbid},
+		objects:     []client.Object{matrix},
+		pr:          current,
+		wantProceed: true,
+	}, {
+		name:    "queue",
+		policy:  &v1alpha3.ConcurrencyPolicy{Type: v1alpha3.ConcurrencyQueue},
+		objects: []client.Object{running, queued, later},
+		pr:      current,
+		verify: func(t *testing.T, c client.Client, pr *v1alpha3.PipelineRun) {
+			assert.Equal(t, v1alpha3.Pending, pr.Status.Phase)
+			assert.False(t, pr.HasCompleted())
+			condition := getCondition(&pr.Status, v1alpha3.ConditionQueued)
+			assert.Equal(t, v1alpha3.ConditionTrue, condition.Status)
+			assert.Equal(t, "the PipelineRun is at position 2 of the queue", condition.Message)
+		},
+	}, {
+		name:    "the queue is full",
+		policy:  &v1alpha3.ConcurrencyPolicy{Type: v1alpha3.ConcurrencyQueue, MaxQueueDepth: 1},
+		objects: []client.Object{running, queued},
+		pr:      current,
+		verify: func(t *testing.T, c client.Client, pr *v1alpha3.PipelineRun) {
+			assert.Equal(t, v1alpha3.Cancelled, pr.Status.Phase)
+			assert.Equal(t, v1alpha3.QueueFull, getCondition(&pr.Status, v1alpha3.ConditionSucceeded).Reason)
+		},
+	}, {
+		name:        "queue in the groups keyed by parameters",
+		policy:      &v1alpha3.ConcurrencyPolicy{Type: v1alpha3.ConcurrencyQueue, KeyByParameters: []string{"env"}},
+		objects:     []client.Object{newPipelineRun("running-dev", now.Add(-time.Minute), true, v1alpha3.Parameter{Name: "env", Value: "dev"})},
+		pr:          newPipelineRun("current", now, false, v1alpha3.Parameter{Name: "env", Value: "prod"}),
+		wantProceed: true,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pr := tt.pr.DeepCopy()
+			objects := append([]client.Object{pr}, tt.objects...)
+			c := fake.NewClientBuilder().WithScheme(schema).WithObjects(objects...).WithStatusSubresource(pr).Build()
+			r := &Reconciler{Client: c, recorder: &record.FakeRecorder{}}
+
+			proceed, err := r.applyConcurrencyPolicy(context.Background(), newPipeline(tt.policy), pr)
+			assert.Nil(t, err)
+			assert.Equal(t, tt.wantProceed, proceed)
+			if tt.verify != nil {
+				latest := &v1alpha3.PipelineRun{}
+				assert.Nil(t, c.Get(context.Background(), client.ObjectKeyFromObject(pr), latest))
+				tt.verify(t, c, latest)
+			}
+		})
+	}
+}
+
+func TestReconciler_applyConcurrencyPolicy_dequeue(t *testing.T) {
+	schema, err := v1alpha3.SchemeBuilder.Register().Build()
+	assert.Nil(t, err)
+
+	pr := &v1alpha3.PipelineRun{
+		ObjectMeta: v1.ObjectMeta{
+			Namespace: "ns",
+			Name:      "pr",
+			Labels:    map[string]string{v1alpha3.PipelineNameLabelKey: "pipeline"},
+		},
+	}
+	pr.Status.AddCondition(&v1alpha3.Condition{Type: v1alpha3.ConditionQueued, Status: v1alpha3.ConditionTrue})
+	pipeline := &v1alpha3.Pipeline{
+		ObjectMeta: v1.ObjectMeta{Namespace: "ns", Name: "pipeline"},
+		Spec:       v1alpha3.PipelineSpec{Pipeline: &v1alpha3.NoScmPipeline{DisableConcurrent: true}},
+	}
+	r := &Reconciler{
+		Client:   fake.NewClientBuilder().WithScheme(schema).WithObjects(pr.DeepCopy()).Build(),
+		recorder: &record.FakeRecorder{},
+	}
+
+	proceed, err := r.applyConcurrencyPolicy(context.Background(), pipeline, pr)
+	assert.Nil(t, err)
+	assert.True(t, proceed)
+	condition := getCondition(&pr.Status, v1alpha3.ConditionQueued)
+	assert.Equal(t, v1alpha3.ConditionFalse, condition.Status)
+	assert.Equal(t, v1alpha3.Dequeued, condition.Reason)
+}
+
+func Test_concurrencyGroupKey(t *testing.T) {
+	pr := &v1alpha3.PipelineRun{Spec: v1alpha3.PipelineRunSpec{
+		SCM:        &v1alpha3.SCM{RefName: "main"},
+		Parameters: []v1alpha3.Parameter{{Name: "env", Value: "prod"}, {Name: "debug", Value: "true"}},
+	}}
+
+	assert.Equal(t, "", concurrencyGroupKey(v1alpha3.ConcurrencyPolicy{}, pr))
+	assert.Equal(t, "main", concurrencyGroupKey(v1alpha3.ConcurrencyPolicy{KeyByBranch: true}, pr))
+	assert.Equal(t, "main,env=prod,region=", concurrencyGroupKey(v1alpha3.ConcurrencyPolicy{
+		KeyByBranch:     true,
+		KeyByParameters: []string{"env", "region"},
+	}, pr))
+}
@@ -0,0 +1,122 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinerun
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jenkins-zh/jenkins-client/pkg/job"
+	"github.com/kubesphere/ks-devops/pkg/api/devops/v1alpha3"
+	"github.com/kubesphere/ks-devops/pkg/constants"
+	"github.com/kubesphere/ks-devops/pkg/models/pipelinerun"
+	"github.com/kubesphere/ks-devops/pkg/models/testreport"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// executor runs PipelineRuns on a specific backend.
+// The status of a run is represented as a Jenkins BlueOcean run, because it's the format of the stored PipelineRun data.
+type executor interface {
+	// trigger starts a new run of the PipelineRun
+	trigger(ctx context.Context, pipeline *v1alpha3.Pipeline, pr *v1alpha3.PipelineRun) (*job.PipelineRun, error)
+	// getStatus returns the latest status of the run
+	getStatus(ctx context.Context, pipeline *v1alpha3.Pipeline, pr *v1alpha3.PipelineRun) (*job.PipelineRun, error)
+	// getNodeDetails returns the stages and steps of the run
+	getNodeDetails(ctx context.Context, pipeline *v1alpha3.Pipeline, pr *v1alpha3.PipelineRun) ([]pipelinerun.NodeDetail, error)
+	// stop aborts the run
+	stop(ctx context.Context, pr *v1alpha3.PipelineRun) error
+	// delete cleans up the records of the run
+	delete(ctx context.Context, pr *v1alpha3.PipelineRun) error
+}
+
+// pausableExecutor is an executor which is able to pause or resume a run
+type pausableExecutor interface {
+	executor
+
+	pause(ctx context.Context, pr *v1alpha3.PipelineRun) error
+	resume(ctx context.Context, pr *v1alpha3.PipelineRun, paused bool) error
+}
+
+// logExecutor is an executor which is able to retrieve the logs of a run progressively
+type logExecutor interface {
+	executor
+
+	// getStepLog returns the log of a step from the start offset
+	getStepLog(ctx context.Context, pipeline *v1alpha3.Pipeline, pr *v1alpha3.PipelineRun, nodeID, stepID string, start int64) (text string, hasMore bool, err error)
+	// getRunLog returns the whole log of a run from the start offset
+	getRunLog(ctx context.Context, pipeline *v1alpha3.Pipeline, pr *v1alpha3.PipelineRun, start int64) (text string, hasMore bool, err error)
+}
+
+// testReportExecutor is an executor which is able to retrieve the test report of a run
+type testReportExecutor interface {
+	executor
+
+	// getTestReport returns the test cases of a finished run, it's empty if there is no test report
+	getTestReport(ctx context.Context, pipeline *v1alpha3.Pipeline, pr *v1alpha3.PipelineRun) ([]testreport.Case, error)
+}
+
+// getExecutor returns the executor by name, the Jenkins executor is the default one
+func (r *Reconciler) getExecutor(name string) (exec executor, err error) {
+	switch name {
+	case "", v1alpha3.ExecutorJenkins:
+		exec = &jenkinsHandler{JenkinsCore: &r.JenkinsCore, stepsCache: r.stepsCache}
+	case v1alpha3.ExecutorKubernetes:
+		exec = &podExecutor{Client: r.Client, serviceAccount: r.PodServiceAccount}
+	default:
+		err = fmt.Errorf("unknown PipelineRun executor: %s", name)
+	}
+	return
+}
+
+// getExecutorName returns the executor name of a PipelineRun. The executor name is taken from the PipelineRun
+// if it has started, then the Pipeline, finally the DevOpsProject which the Pipeline belongs to.
+func getExecutorName(ctx context.Context, c client.Client, pipeline *v1alpha3.Pipeline, pr *v1alpha3.PipelineRun) (name string, err error) {
+	if name = pr.Annotations[v1alpha3.PipelineExecutorAnnoKey]; name != "" || pr.HasStarted() {
+		return
+	}
+	if pipeline == nil {
+		return
+	}
+	if name = pipeline.Annotations[v1alpha3.PipelineExecutorAnnoKey]; name != "" {
+		return
+	}
+
+	var projectName string
+	if projectName, err = getDevOpsProjectName(ctx, c, pipeline.Namespace); err != nil || projectName == "" {
+		return
+	}
+	project := &v1alpha3.DevOpsProject{}
+	if err = c.Get(ctx, types.NamespacedName{Name: projectName}, project); err != nil {
+		err = client.IgnoreNotFound(err)
+		return
+	}
+	name = project.Annotations[v1alpha3.PipelineExecutorAnnoKey]
+	return
+}
+
+// getDevOpsProjectName returns the name of DevOpsProject which the namespace belongs to
+func getDevOpsProjectName(ctx context.Context, c client.Client, namespace string) (name string, err error) {
+	ns := &corev1.Namespace{}
+	if err = c.Get(ctx, types.NamespacedName{Name: namespace}, ns); err != nil {
+		err = client.IgnoreNotFound(err)
+		return
+	}
+	name = ns.Labels[constants.DevOpsProjectLabelKey]
+	return
+}
@@ -0,0 +1,139 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinerun
+
+import (
+	"context"
+	"testing"
+
+	"github.com/kubesphere/ks-devops/pkg/api/devops/v1alpha3"
+	"github.com/kubesphere/ks-devops/pkg/constants"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestReconciler_getExecutor(t *testing.T) {
+	r := &Reconciler{PodServiceAccount: "runner"}
+
+	exec, err := r.getExecutor("")
+	assert.Nil(t, err)
+	assert.IsType(t, &jenkinsHandler{}, exec)
+
+	exec, err = r.getExecutor(v1alpha3.ExecutorJenkins)
+	assert.Nil(t, err)
+	assert.IsType(t, &jenkinsHandler{}, exec)
+	_, pausable := exec.(pausableExecutor)
+	assert.True(t, pausable)
+
+	exec, err = r.getExecutor(v1alpha3.ExecutorKubernetes)
+	assert.Nil(t, err)
+	if assert.IsType(t, &podExecutor{}, exec) {
+		assert.Equal(t, "runner", exec.(*podExecutor).serviceAccount)
+	}
+	_, pausable = exec.(pausableExecutor)
+	assert.False(t, pausable)
+
+	_, err = r.getExecutor("fake")
+	assert.NotNil(t, err)
+}
+
+func Test_getExecutorName(t *testing.T) {
+	schema, err := v1alpha3.SchemeBuilder.Register().Build()
+	assert.Nil(t, err)
+	err = corev1.SchemeBuilder.AddToScheme(schema)
+	assert.Nil(t, err)
+
+	ns := &corev1.Namespace{
+		ObjectMeta: v1.ObjectMeta{
+			Name:   "ns",
+			Labels: map[string]string{constants.DevOpsProjectLabelKey: "project"},
+		},
+	}
+	project := &v1alpha3.DevOpsProject{
+		ObjectMeta: v1.ObjectMeta{
+			Name:        "project",
+			Annotations: map[string]string{v1alpha3.PipelineExecutorAnnoKey: v1alpha3.ExecutorKubernetes},
+		},
+	}
+	pipeline := &v1alpha3.Pipeline{
+		ObjectMeta: v1.ObjectMeta{Namespace: "ns", Name: "pipeline"},
+	}
+	pipelineWithExecutor := pipeline.DeepCopy()
+	pipelineWithExecutor.Annotations = map[string]string{v1alpha3.PipelineExecutorAnnoKey: v1alpha3.ExecutorJenkins}
+	startedPipelineRun := &v1alpha3.PipelineRun{
+		ObjectMeta: v1.ObjectMeta{
+			Namespace:   "ns",
+			Name:        "run",
+			Annotations: map[string]string{v1alpha3.JenkinsPipelineRunIDAnnoKey: "1"},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		objects  []runtime.Object
+		pipeline *v1alpha3.Pipeline
+		pr       *v1alpha3.PipelineRun
+		wantName string
+	}{{
+		name:     "from the PipelineRun",
+		pipeline: pipelineWithExecutor,
+		pr: &v1alpha3.PipelineRun{ObjectMeta: v1.ObjectMeta{
+			Annotations: map[string]string{v1alpha3.PipelineExecutorAnnoKey: v1alpha3.ExecutorKubernetes},
+		}},
+		wantName: v1alpha3.ExecutorKubernetes,
+	}, {
+		name:     "a started PipelineRun without executor",
+		objects:  []runtime.Object{ns, project},
+		pipeline: pipeline,
+		pr:       startedPipelineRun,
+		wantName: "",
+	}, {
+		name:     "from the Pipeline",
+		objects:  []runtime.Object{ns, project},
+		pipeline: pipelineWithExecutor,
+		pr:       &v1alpha3.PipelineRun{},
+		wantName: v1alpha3.ExecutorJenkins,
+	}, {
+		name:     "from the DevOpsProject",
+		objects:  []runtime.Object{ns, project},
+		pipeline: pipeline,
+		pr:       &v1alpha3.PipelineRun{},
+		wantName: v1alpha3.ExecutorKubernetes,
+	}, {
+		name:     "namespace not found",
+		pipeline: pipeline,
+		pr:       &v1alpha3.PipelineRun{},
+		wantName: "",
+	}, {
+		name:     "DevOpsProject not found",
+		objects:  []runtime.Object{ns},
+		pipeline: pipeline,
+		pr:       &v1alpha3.PipelineRun{},
+		wantName: "",
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := fake.NewClientBuilder().WithScheme(schema).WithRuntimeObjects(tt.objects...).Build()
+			name, err := getExecutorName(context.Background(), c, tt.pipeline, tt.pr)
+			assert.Nil(t, err)
+			assert.Equal(t, tt.wantName, name)
+		})
+	}
+}
@@ -17,20 +17,27 @@ limitations under the License.
 package pipelinerun
 
 import (
+	"context"
 	"fmt"
+	"io"
+	"net/http"
 	"strconv"
 	"strings"
 
 	"github.com/jenkins-zh/jenkins-client/pkg/core"
 	"github.com/jenkins-zh/jenkins-client/pkg/job"
 	"github.com/kubesphere/ks-devops/pkg/api/devops/v1alpha3"
+	"github.com/kubesphere/ks-devops/pkg/client/devops/jenkins"
 	"github.com/kubesphere/ks-devops/pkg/models/pipelinerun"
+	"github.com/kubesphere/ks-devops/pkg/models/testreport"
 	"k8s.io/klog/v2"
 )
 
 // jenkinsHandler handles some actions with Jenkins endpoint.
 type jenkinsHandler struct {
 	*core.JenkinsCore
+	// stepsCache keeps the steps of finished nodes, it's optional
+	stepsCache *finishedStepsCache
 }
 
 // getPipelineNodeDetails gets node details including pipeline steps.
@@ -53,12 +60,17 @@ func (handler *jenkinsHandler) getPipelineNodeDetails(pipelineName, namespace st
 		return nil, err
 	}
 
-	// get steps for every node
+	// get steps for every node, the steps of the finished nodes are retrieved only once
 	nodeDetails := []pipelinerun.NodeDetail{}
 	for _, node := range nodes {
-		jobSteps, err := handler.getSteps(node.ID, pipelineName, namespace, pr)
-		if err != nil {
-			return nil, err
+		jobSteps, cached := handler.stepsCache.get(pr.UID, runID, node.ID)
+		if !cached {
+			if jobSteps, err = handler.getSteps(node.ID, pipelineName, namespace, pr); err != nil {
+				return nil, err
+			}
+			if node.State == string(Finished) {
+				handler.stepsCache.set(pr.UID, runID, node.ID, jobSteps)
+			}
 		}
 		steps := make([]pipelinerun.Step, 0, len(jobSteps))
 		for i := range jobSteps {
@@ -144,6 +156,158 @@ func (handler *jenkinsHandler) deleteJenkinsJobHistory(pipelineRun *v1alpha3.Pip
 	return
 }
 
+// stopJenkinsJob aborts the Jenkins build which is related with the PipelineRun.
+func (handler *jenkinsHandler) stopJenkinsJob(pipelineRun *v1alpha3.PipelineRun) (err error) {
+	var buildNum int
+	if buildNum = getJenkinsBuildNumber(pipelineRun); buildNum < 0 {
+		return
+	}
+
+	jenkinsClient := job.Client{JenkinsCore: *handler.JenkinsCore}
+	jobPath := getJenkinsJobPath(pipelineRun)
+	if err = jenkinsClient.StopJob(jobPath, buildNum); err != nil {
+		err = fmt.Errorf("failed to stop Jenkins job: %s, build: %d, error: %v", jobPath, buildNum, err)
+	}
+	return
+}
+
+// pauseJenkinsJob holds the Jenkins build which is related with the PipelineRun.
+func (handler *jenkinsHandler) pauseJenkinsJob(pipelineRun *v1alpha3.PipelineRun) (err error) {
+	var buildNum int
+	if buildNum = getJenkinsBuildNumber(pipelineRun); buildNum < 0 {
+		return
+	}
+	return handler.togglePause(getJenkinsJobPath(pipelineRun), buildNum)
+}
+
+// resumeJenkinsJob releases the Jenkins build which is related with the PipelineRun.
+// Only the pause is cleared, the pending input steps are left to the approval.
+func (handler *jenkinsHandler) resumeJenkinsJob(pipelineRun *v1alpha3.PipelineRun, paused bool) (err error) {
+	var buildNum int
+	if buildNum = getJenkinsBuildNumber(pipelineRun); buildNum < 0 || !paused {
+		return
+	}
+	return handler.togglePause(getJenkinsJobPath(pipelineRun), buildNum)
+}
+
+// togglePause pauses or unpauses a Jenkins build, see also the PauseUnpauseAction of the workflow-job plugin
+func (handler *jenkinsHandler) togglePause(jobPath string, buildNum int) (err error) {
+	api := fmt.Sprintf("%s/%d/pause/toggle", jobPath, buildNum)
+	if _, err = handler.RequestWithoutData(http.MethodPost, api, nil, nil, http.StatusOK); err != nil {
+		err = fmt.Errorf("failed to toggle pause of Jenkins job: %s, build: %d, error: %v", jobPath, buildNum, err)
+	}
+	return
+}
+
+func (handler *jenkinsHandler) trigger(_ context.Context, pipeline *v1alpha3.Pipeline, pr *v1alpha3.PipelineRun) (*job.PipelineRun, error) {
+	return handler.triggerJenkinsJob(pipeline.Namespace, pipeline.Name, &pr.Spec)
+}
+
+func (handler *jenkinsHandler) getStatus(_ context.Context, pipeline *v1alpha3.Pipeline, pr *v1alpha3.PipelineRun) (*job.PipelineRun, error) {
+	return handler.getPipelineRunResult(pipeline.Namespace, pipeline.Name, pr)
+}
+
+func (handler *jenkinsHandler) getNodeDetails(_ context.Context, pipeline *v1alpha3.Pipeline, pr *v1alpha3.PipelineRun) ([]pipelinerun.NodeDetail, error) {
+	return handler.getPipelineNodeDetails(pipeline.Name, pipeline.Namespace, pr)
+}
+
+func (handler *jenkinsHandler) stop(_ context.Context, pr *v1alpha3.PipelineRun) error {
+	return handler.stopJenkinsJob(pr)
+}
+
+func (handler *jenkinsHandler) delete(_ context.Context, pr *v1alpha3.PipelineRun) error {
+	return handler.deleteJenkinsJobHistory(pr)
+}
+
+func (handler *jenkinsHandler) pause(_ context.Context, pr *v1alpha3.PipelineRun) error {
+	return handler.pauseJenkinsJob(pr)
+}
+
+func (handler *jenkinsHandler) resume(_ context.Context, pr *v1alpha3.PipelineRun, paused bool) error {
+	return handler.resumeJenkinsJob(pr, paused)
+}
+
+func (handler *jenkinsHandler) getStepLog(_ context.Context, pipeline *v1alpha3.Pipeline, pr *v1alpha3.PipelineRun,
+	nodeID, stepID string, start int64) (text string, hasMore bool, err error) {
+	runID, exists := pr.GetPipelineRunID()
+	if !exists {
+		err = fmt.Errorf("unable to get PipelineRun step log due to not found run ID")
+		return
+	}
+	api := fmt.Sprintf(jenkins.GetStepLogUrl, pipeline.Namespace, pipeline.Name, runID, nodeID, stepID)
+	if pr.Spec.IsMultiBranchPipeline() {
+		api = fmt.Sprintf(jenkins.GetBranchStepLogUrl, pipeline.Namespace, pipeline.Name, pr.GetRefName(), runID, nodeID, stepID)
+	}
+	return handler.getProgressiveLog(api, start)
+}
+
+func (handler *jenkinsHandler) getRunLog(_ context.Context, pipeline *v1alpha3.Pipeline, pr *v1alpha3.PipelineRun,
+	start int64) (text string, hasMore bool, err error) {
+	runID, exists := pr.GetPipelineRunID()
+	if !exists {
+		err = fmt.Errorf("unable to get PipelineRun log due to not found run ID")
+		return
+	}
+	api := fmt.Sprintf(jenkins.GetRunLogUrl, pipeline.Namespace, pipeline.Name, runID)
+	if pr.Spec.IsMultiBranchPipeline() {
+		api = fmt.Sprintf(jenkins.GetBranchRunLogUrl, pipeline.Namespace, pipeline.Name, pr.GetRefName(), runID)
+	}
+	return handler.getProgressiveLog(api, start)
+}
+
+func (handler *jenkinsHandler) getTestReport(_ context.Context, _ *v1alpha3.Pipeline, pr *v1alpha3.PipelineRun) (cases []testreport.Case, err error) {
+	var buildNum int
+	if buildNum = getJenkinsBuildNumber(pr); buildNum < 0 {
+		err = fmt.Errorf("unable to get PipelineRun test report due to not found run ID")
+		return
+	}
+	api := fmt.Sprintf("%s/%d/testReport/api/json", getJenkinsJobPath(pr), buildNum)
+	var response *http.Response
+	if response, err = handler.RequestWithResponse(http.MethodGet, api, nil, nil); err != nil {
+		return
+	}
+	defer func() {
+		_ = response.Body.Close()
+	}()
+	switch response.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		// there is no test report if the run did not publish any test results
+		return
+	default:
+		err = fmt.Errorf("failed to get test report from %s, status code: %d", api, response.StatusCode)
+		return
+	}
+
+	var data []byte
+	if data, err = io.ReadAll(response.Body); err == nil {
+		cases, err = testreport.ParseJenkinsReport(data)
+	}
+	return
+}
+
+// getProgressiveLog returns the log from the start offset, the header X-More-Data indicates if there is more log
+func (handler *jenkinsHandler) getProgressiveLog(api string, start int64) (text string, hasMore bool, err error) {
+	var response *http.Response
+	if response, err = handler.RequestWithResponse(http.MethodGet, fmt.Sprintf("%sstart=%d", api, start), nil, nil); err != nil {
+		return
+	}
+	defer func() {
+		_ = response.Body.Close()
+	}()
+	if response.StatusCode != http.StatusOK {
+		err = fmt.Errorf("failed to get log from %s, status code: %d", api, response.StatusCode)
+		return
+	}
+
+	var data []byte
+	if data, err = io.ReadAll(response.Body); err == nil {
+		text = string(data)
+		hasMore, _ = strconv.ParseBool(response.Header.Get("X-More-Data"))
+	}
+	return
+}
+
 // getJenkinsJobPath returns the corresponding Jenkins job path
 // only a regular or multi-branch Pipeline supported
 func getJenkinsJobPath(run *v1alpha3.PipelineRun) (jobPath string) {
@@ -18,6 +18,7 @@ package pipelinerun
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"github.com/stretchr/testify/assert"
@@ -102,7 +103,7 @@ var _ = Describe("Test deleteJenkinsJobHistory", func() {
 				RoundTripper: roundTripper,
 			},
 		}
-		jHandler = &jenkinsHandler{&reconciler.JenkinsCore}
+		jHandler = &jenkinsHandler{JenkinsCore: &reconciler.JenkinsCore}
 	})
 
 	It("delete an empty PipelineRun", func() {
@@ -311,3 +312,171 @@ func Test_getJenkinsJobPath(t *testing.T) {
 		})
 	}
 }
+
+var _ = Describe("Test actions of Jenkins job", func() {
+	var (
+		ctrl         *gomock.Controller
+		roundTripper *mhttp.MockRoundTripper
+		jHandler     *jenkinsHandler
+		pipelineRun  *v1alpha3.PipelineRun
+	)
+
+	const (
+		namespace    = "project1"
+		pipelineName = "testPipeline"
+	)
+
+	expectCrumb := func() {
+		requestCrumb, _ := http.NewRequest(http.MethodGet, "http://localhost/crumbIssuer/api/json", nil)
+		responseCrumb := &http.Response{
+			StatusCode: 200,
+			Proto:      "HTTP/1.1",
+			Request:    requestCrumb,
+			Body: ioutil.NopCloser(bytes.NewBufferString(`
+				{"crumbRequestField":"CrumbRequestField","crumb":"Crumb"}
+				`)),
+		}
+		roundTripper.EXPECT().
+			RoundTrip(core.NewRequestMatcher(requestCrumb)).Return(responseCrumb, nil)
+	}
+
+	expectPost := func(api string, statusCode int) {
+		request, _ := http.NewRequest(http.MethodPost, fmt.Sprintf("http://localhost/job/%s/job/%s/2/%s", namespace, pipelineName, api), nil)
+		request.Header.Set("CrumbRequestField", "Crumb")
+		response := &http.Response{
+			Request:    request,
+			StatusCode: statusCode,
+		}
+		roundTripper.EXPECT().
+			RoundTrip(core.NewRequestMatcher(request)).Return(response, nil)
+	}
+
+	BeforeEach(func() {
+		ctrl = gomock.NewController(GinkgoT())
+		roundTripper = mhttp.NewMockRoundTripper(ctrl)
+		jHandler = &jenkinsHandler{JenkinsCore: &core.JenkinsCore{
+			URL:          "http://localhost",
+			RoundTripper: roundTripper,
+		}}
+		pipelineRun = &v1alpha3.PipelineRun{
+			ObjectMeta: v1.ObjectMeta{
+				Namespace: namespace,
+				Annotations: map[string]string{
+					v1alpha3.JenkinsPipelineRunIDAnnoKey: "2",
+				},
+			},
+			Spec: v1alpha3.PipelineRunSpec{
+				PipelineRef: &corev1.ObjectReference{
+					Name: pipelineName,
+				},
+			},
+		}
+	})
+
+	It("apply actions to a PipelineRun without build number", func() {
+		Expect(jHandler.stopJenkinsJob(&v1alpha3.PipelineRun{})).NotTo(HaveOccurred())
+		Expect(jHandler.pauseJenkinsJob(&v1alpha3.PipelineRun{})).NotTo(HaveOccurred())
+		Expect(jHandler.resumeJenkinsJob(&v1alpha3.PipelineRun{}, true)).NotTo(HaveOccurred())
+	})
+
+	It("stop a valid PipelineRun", func() {
+		expectCrumb()
+		expectPost("stop", http.StatusOK)
+		Expect(jHandler.stopJenkinsJob(pipelineRun)).NotTo(HaveOccurred())
+	})
+
+	It("failed to stop a PipelineRun", func() {
+		expectCrumb()
+		expectPost("stop", http.StatusInternalServerError)
+		Expect(jHandler.stopJenkinsJob(pipelineRun)).To(HaveOccurred())
+	})
+
+	It("pause a valid PipelineRun", func() {
+		expectCrumb()
+		expectPost("pause/toggle", http.StatusOK)
+		Expect(jHandler.pauseJenkinsJob(pipelineRun)).NotTo(HaveOccurred())
+	})
+
+	It("resume a paused PipelineRun", func() {
+		expectCrumb()
+		expectPost("pause/toggle", http.StatusOK)
+		Expect(jHandler.resumeJenkinsJob(pipelineRun, true)).NotTo(HaveOccurred())
+	})
+
+	It("resume a PipelineRun which is not paused", func() {
+		// neither the pause is toggled nor the pending input steps are submitted
+		Expect(jHandler.resumeJenkinsJob(pipelineRun, false)).NotTo(HaveOccurred())
+	})
+})
+
+var _ = Describe("Test logs of Jenkins job", func() {
+	var (
+		ctrl         *gomock.Controller
+		roundTripper *mhttp.MockRoundTripper
+		jHandler     *jenkinsHandler
+		pipeline     *v1alpha3.Pipeline
+		pipelineRun  *v1alpha3.PipelineRun
+	)
+
+	expectGet := func(api, body string, statusCode int, moreData string) {
+		request, _ := http.NewRequest(http.MethodGet, "http://localhost"+api, nil)
+		response := &http.Response{
+			Request:    request,
+			StatusCode: statusCode,
+			Header:     http.Header{"X-More-Data": []string{moreData}},
+			Body:       ioutil.NopCloser(bytes.NewBufferString(body)),
+		}
+		roundTripper.EXPECT().
+			RoundTrip(core.NewRequestMatcher(request).WithQuery()).Return(response, nil)
+	}
+
+	BeforeEach(func() {
+		ctrl = gomock.NewController(GinkgoT())
+		roundTripper = mhttp.NewMockRoundTripper(ctrl)
+		jHandler = &jenkinsHandler{JenkinsCore: &core.JenkinsCore{
+			URL:          "http://localhost",
+			RoundTripper: roundTripper,
+		}}
+		pipeline = &v1alpha3.Pipeline{ObjectMeta: v1.ObjectMeta{Namespace: "project1", Name: "pipeline"}}
+		pipelineRun = &v1alpha3.PipelineRun{
+			ObjectMeta: v1.ObjectMeta{
+				Namespace:   "project1",
+				Annotations: map[string]string{v1alpha3.JenkinsPipelineRunIDAnnoKey: "2"},
+			},
+		}
+	})
+
+	It("get the log of a step", func() {
+		expectGet("/blue/rest/organizations/jenkins/pipelines/project1/pipelines/pipeline/runs/2/nodes/3/steps/4/log/?start=5",
+			"hello", http.StatusOK, "true")
+		text, hasMore, err := jHandler.getStepLog(context.TODO(), pipeline, pipelineRun, "3", "4", 5)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(text).To(Equal("hello"))
+		Expect(hasMore).To(BeTrue())
+	})
+
+	It("get the log of a multi-branch Pipeline run", func() {
+		pipelineRun.Spec.PipelineSpec = &v1alpha3.PipelineSpec{Type: v1alpha3.MultiBranchPipelineType}
+		pipelineRun.Spec.SCM = &v1alpha3.SCM{RefName: "master"}
+		expectGet("/blue/rest/organizations/jenkins/pipelines/project1/pipelines/pipeline/branches/master/runs/2/log/?start=0",
+			"hello", http.StatusOK, "false")
+		text, hasMore, err := jHandler.getRunLog(context.TODO(), pipeline, pipelineRun, 0)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(text).To(Equal("hello"))
+		Expect(hasMore).To(BeFalse())
+	})
+
+	It("get the log with an unexpected status code", func() {
+		expectGet("/blue/rest/organizations/jenkins/pipelines/project1/pipelines/pipeline/runs/2/log/?start=0",
+			"", http.StatusNotFound, "")
+		_, _, err := jHandler.getRunLog(context.TODO(), pipeline, pipelineRun, 0)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("get the log without run ID", func() {
+		_, _, err := jHandler.getRunLog(context.TODO(), pipeline, &v1alpha3.PipelineRun{}, 0)
+		Expect(err).To(HaveOccurred())
+		_, _, err = jHandler.getStepLog(context.TODO(), pipeline, &v1alpha3.PipelineRun{}, "1", "2", 0)
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,130 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinerun
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jenkins-zh/jenkins-client/pkg/job"
+	"github.com/kubesphere/ks-devops/pkg/api/devops/v1alpha3"
+	"github.com/kubesphere/ks-devops/pkg/models/pipelinerun"
+	"github.com/kubesphere/ks-devops/pkg/store/store"
+	"k8s.io/klog/v2"
+)
+
+// maxConfigMapLogSize is the max size of the step logs of a run, and the whole log as well, in the ConfigMap store.
+// The logs are truncated beyond it, because a ConfigMap is limited to 1 MiB.
+const maxConfigMapLogSize = 384 * 1024
+
+// logCollectInterval is the min interval of collecting the logs of a running run, the logs of a finished run are
+// collected at once
+const logCollectInterval = 10 * time.Second
+
+// logTruncatedMessage is appended to the logs which are truncated due to the max log size
+const logTruncatedMessage = "\n... the log is truncated due to the size limitation of the PipelineRun data store\n"
+
+// logCollector saves the logs of a run into the PipelineRun data store incrementally.
+// The length of the stored log is the offset of the next retrieval.
+type logCollector struct {
+	exec          logExecutor
+	pipeline      *v1alpha3.Pipeline
+	pr            *v1alpha3.PipelineRun
+	pipelineBuild *job.PipelineRun
+	nodeDetails   []pipelinerun.NodeDetail
+	// maxLogSize limits the size of the stored logs, there is no limitation if it's zero
+	maxLogSize int
+}
+
+func (c *logCollector) collect(ctx context.Context, dataStore store.PipelineRunDataStore) {
+	// fetching logs on every reconcile is expensive, so the logs of a running run are collected less frequently
+	now := time.Now()
+	finished := c.pipelineBuild != nil && c.pipelineBuild.State == Finished.String()
+	if collectedTime, err := time.Parse(time.RFC3339Nano, dataStore.Get(store.DataKeyLogCollectedTime)); !finished &&
+		err == nil && now.Sub(collectedTime) < logCollectInterval {
+		return
+	}
+	dataStore.Set(store.DataKeyLogCollectedTime, now.Format(time.RFC3339Nano))
+
+	var stepLogSize int
+	for _, node := range c.nodeDetails {
+		stage, err := strconv.Atoi(node.ID)
+		if err != nil {
+			continue
+		}
+		for _, step := range node.Steps {
+			stepIndex, err := strconv.Atoi(step.ID)
+			if err != nil || step.State == "" || step.State == Queued.String() {
+				continue
+			}
+			stepLog := dataStore.GetStepLog(stage, stepIndex)
+			stepLogSize += len(stepLog)
+			completedKey := store.StepLogCompletedKey(stage, stepIndex)
+			if dataStore.Get(completedKey) == "true" {
+				continue
+			}
+
+			text, hasMore, err := c.exec.getStepLog(ctx, c.pipeline, c.pr, node.ID, step.ID, int64(len(stepLog)))
+			if err != nil {
+				klog.V(4).Infof("failed to get the log of step %s-%s of PipelineRun %s/%s, error: %v",
+					node.ID, step.ID, c.pr.Namespace, c.pr.Name, err)
+				continue
+			}
+			var truncated bool
+			if text, truncated = c.truncateLog(text, stepLogSize); truncated {
+				hasMore = false
+			}
+			stepLogSize += len(text)
+			dataStore.SetStepLog(stage, stepIndex, stepLog+text)
+			if step.State == Finished.String() && !hasMore {
+				dataStore.Set(completedKey, "true")
+			}
+		}
+	}
+
+	allLog := dataStore.GetAllLog()
+	if strings.HasSuffix(allLog, logTruncatedMessage) {
+		return
+	}
+	if text, _, err := c.exec.getRunLog(ctx, c.pipeline, c.pr, int64(len(allLog))); err != nil {
+		klog.V(4).Infof("failed to get the log of PipelineRun %s/%s, error: %v", c.pr.Namespace, c.pr.Name, err)
+	} else {
+		text, _ = c.truncateLog(text, len(allLog))
+		dataStore.SetAllLog(allLog + text)
+	}
+}
+
+// truncateLog cuts the text if it exceeds the rest of the max log size after the stored size
+func (c *logCollector) truncateLog(text string, storedSize int) (string, bool) {
+	if c.maxLogSize <= 0 {
+		return text, false
+	}
+	return truncateLog(text, c.maxLogSize-storedSize)
+}
+
+// truncateLog cuts the text if it exceeds the size, the truncated text ends with logTruncatedMessage
+func truncateLog(text string, size int) (string, bool) {
+	if len(text) <= size {
+		return text, false
+	}
+	if size < 0 {
+		size = 0
+	}
+	return text[:size] + logTruncatedMessage, true
+}
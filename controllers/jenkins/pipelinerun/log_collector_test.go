@@ -0,0 +1,182 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinerun
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jenkins-zh/jenkins-client/pkg/job"
+	"github.com/kubesphere/ks-devops/pkg/api/devops/v1alpha3"
+	"github.com/kubesphere/ks-devops/pkg/models/pipelinerun"
+	"github.com/kubesphere/ks-devops/pkg/store/fake"
+	"github.com/kubesphere/ks-devops/pkg/store/store"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeLogExecutor returns the log "{node}-{step}@{start}" for each request
+type fakeLogExecutor struct {
+	executor
+	hasMore  bool
+	err      error
+	requests int
+}
+
+func (e *fakeLogExecutor) getStepLog(_ context.Context, _ *v1alpha3.Pipeline, _ *v1alpha3.PipelineRun,
+	nodeID, stepID string, start int64) (string, bool, error) {
+	e.requests++
+	return fmt.Sprintf("%s-%s@%d;", nodeID, stepID, start), e.hasMore, e.err
+}
+
+func (e *fakeLogExecutor) getRunLog(_ context.Context, _ *v1alpha3.Pipeline, _ *v1alpha3.PipelineRun,
+	start int64) (string, bool, error) {
+	e.requests++
+	return fmt.Sprintf("all@%d;", start), e.hasMore, e.err
+}
+
+func newNodeDetail(id string, stepState string) pipelinerun.NodeDetail {
+	return pipelinerun.NodeDetail{
+		Node: job.Node{ID: id},
+		Steps: []pipelinerun.Step{{
+			Step: job.Step{ID: "1", State: stepState},
+		}},
+	}
+}
+
+func Test_logCollector_collect(t *testing.T) {
+	exec := &fakeLogExecutor{hasMore: true}
+	dataStore := fake.NewFakeStore()
+	pipelineBuild := &job.PipelineRun{}
+	pipelineBuild.State = Running.String()
+	collector := &logCollector{
+		exec:          exec,
+		pr:            &v1alpha3.PipelineRun{},
+		pipelineBuild: pipelineBuild,
+		nodeDetails: []pipelinerun.NodeDetail{
+			newNodeDetail("3", Running.String()),
+			newNodeDetail("4", Queued.String()),
+			newNodeDetail("invalid", Running.String()),
+		},
+	}
+
+	// the logs of a running run are collected once in the collecting interval
+	collector.collect(context.TODO(), dataStore)
+	collector.collect(context.TODO(), dataStore)
+	assert.Equal(t, 2, exec.requests)
+	assert.Equal(t, "3-1@0;", dataStore.GetStepLog(3, 1))
+	assert.Equal(t, "all@0;", dataStore.GetAllLog())
+
+	// the logs are appended from the end of stored logs
+	dataStore.Set(store.DataKeyLogCollectedTime, time.Now().Add(-logCollectInterval).Format(time.RFC3339Nano))
+	collector.collect(context.TODO(), dataStore)
+	assert.Equal(t, "3-1@0;3-1@6;", dataStore.GetStepLog(3, 1))
+	assert.Equal(t, "", dataStore.GetStepLog(4, 1))
+	assert.Equal(t, "all@0;all@6;", dataStore.GetAllLog())
+	assert.Equal(t, 4, exec.requests)
+
+	// the logs of a finished run are collected at once
+	collector.pipelineBuild.State = Finished.String()
+
+	// the step log is completed once the step finished and there is no more data
+	exec.hasMore = false
+	collector.nodeDetails = []pipelinerun.NodeDetail{newNodeDetail("3", Finished.String())}
+	collector.collect(context.TODO(), dataStore)
+	assert.Equal(t, "true", dataStore.Get(store.StepLogCompletedKey(3, 1)))
+	assert.Equal(t, 6, exec.requests)
+	exec.requests = 0
+	collector.collect(context.TODO(), dataStore)
+	assert.Equal(t, 1, exec.requests)
+	assert.Equal(t, "3-1@0;3-1@6;3-1@12;", dataStore.GetStepLog(3, 1))
+
+	// keep the stored logs if failed to get logs
+	exec.err = errors.New("fake")
+	collector.nodeDetails = []pipelinerun.NodeDetail{newNodeDetail("4", Running.String())}
+	collector.collect(context.TODO(), dataStore)
+	assert.Equal(t, "", dataStore.GetStepLog(4, 1))
+	assert.Equal(t, "all@0;all@6;all@12;all@19;", dataStore.GetAllLog())
+}
+
+// fakeLargeLogExecutor returns the logs of the given size
+type fakeLargeLogExecutor struct {
+	executor
+	size int
+}
+
+func (e *fakeLargeLogExecutor) getStepLog(_ context.Context, _ *v1alpha3.Pipeline, _ *v1alpha3.PipelineRun,
+	_, _ string, _ int64) (string, bool, error) {
+	return strings.Repeat("s", e.size), false, nil
+}
+
+func (e *fakeLargeLogExecutor) getRunLog(_ context.Context, _ *v1alpha3.Pipeline, _ *v1alpha3.PipelineRun,
+	_ int64) (string, bool, error) {
+	return strings.Repeat("a", e.size), false, nil
+}
+
+func Test_logCollector_collectLargeLogs(t *testing.T) {
+	dataStore := fake.NewFakeStore()
+	pipelineBuild := &job.PipelineRun{}
+	pipelineBuild.State = Finished.String()
+	collector := &logCollector{
+		exec:          &fakeLargeLogExecutor{size: maxConfigMapLogSize - 10},
+		pr:            &v1alpha3.PipelineRun{},
+		pipelineBuild: pipelineBuild,
+		nodeDetails: []pipelinerun.NodeDetail{
+			newNodeDetail("1", Finished.String()),
+			newNodeDetail("2", Finished.String()),
+		},
+		maxLogSize: maxConfigMapLogSize,
+	}
+	collector.collect(context.TODO(), dataStore)
+
+	// the step logs share the size limitation
+	assert.Equal(t, maxConfigMapLogSize-10, len(dataStore.GetStepLog(1, 1)))
+	assert.Equal(t, strings.Repeat("s", 10)+logTruncatedMessage, dataStore.GetStepLog(2, 1))
+	assert.Equal(t, "true", dataStore.Get(store.StepLogCompletedKey(2, 1)))
+	assert.Equal(t, maxConfigMapLogSize-10, len(dataStore.GetAllLog()))
+
+	// the whole log is truncated once it exceeds the limitation, and it's not collected anymore
+	collector.collect(context.TODO(), dataStore)
+	assert.Equal(t, strings.Repeat("a", maxConfigMapLogSize)+logTruncatedMessage, dataStore.GetAllLog())
+	collector.collect(context.TODO(), dataStore)
+	assert.Equal(t, strings.Repeat("a", maxConfigMapLogSize)+logTruncatedMessage, dataStore.GetAllLog())
+
+	// there is no limitation without the max log size
+	dataStore = fake.NewFakeStore()
+	collector.maxLogSize = 0
+	collector.collect(context.TODO(), dataStore)
+	collector.collect(context.TODO(), dataStore)
+	assert.Equal(t, maxConfigMapLogSize-10, len(dataStore.GetStepLog(2, 1)))
+	assert.Equal(t, 2*(maxConfigMapLogSize-10), len(dataStore.GetAllLog()))
+}
+
+func Test_truncateLog(t *testing.T) {
+	text, truncated := truncateLog("log", 3)
+	assert.Equal(t, "log", text)
+	assert.False(t, truncated)
+
+	text, truncated = truncateLog("log", 1)
+	assert.Equal(t, "l"+logTruncatedMessage, text)
+	assert.True(t, truncated)
+
+	text, truncated = truncateLog("log", -1)
+	assert.Equal(t, logTruncatedMessage, text)
+	assert.True(t, truncated)
+}
@@ -0,0 +1,288 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinerun
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/kubesphere/ks-devops/pkg/api/devops/v1alpha3"
+)
+
+// MatrixReconciler fans out the matrix PipelineRuns. It creates a child PipelineRun for each combination of the
+// matrix, and aggregates the phases of the children into the status of the matrix PipelineRun.
+type MatrixReconciler struct {
+	client.Client
+	log      logr.Logger
+	recorder record.EventRecorder
+}
+
+// Reconcile creates the child PipelineRuns under the concurrency limit of the matrix, then updates the matrix status
+func (r *MatrixReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := r.log.WithValues("PipelineRun", req.NamespacedName)
+	pr := &v1alpha3.PipelineRun{}
+	if err := r.Get(ctx, req.NamespacedName, pr); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if !pr.IsMatrix() || pr.HasCompleted() || !pr.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, nil
+	}
+
+	status := pr.Status.DeepCopy()
+	combinations, err := pr.Spec.Matrix.GetCombinations()
+	if err != nil {
+		// there is nothing to do with an invalid matrix
+		r.recorder.Eventf(pr, corev1.EventTypeWarning, v1alpha3.MatrixFailed, "Invalid matrix: %v", err)
+		completeMatrix(status, v1alpha3.Failed, fmt.Sprintf("invalid matrix: %v", err))
+		return ctrl.Result{}, r.updateStatus(ctx, pr, status)
+	}
+
+	children, err := r.getChildren(ctx, pr)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	running, failed := 0, false
+	for _, child := range children {
+		if !child.HasCompleted() {
+			running++
+		} else if child.Status.Phase != v1alpha3.Succeeded && child.Status.Phase != v1alpha3.Cancelled {
+			failed = true
+		}
+	}
+	stopping := (pr.Spec.Action != nil && *pr.Spec.Action == v1alpha3.Stop) || (pr.Spec.Matrix.FailFast && failed)
+
+	completed := 0
+	status.Matrix = make([]v1alpha3.MatrixRunStatus, len(combinations))
+	for i, combination := range combinations {
+		row := &status.Matrix[i]
+		row.Parameters = combination
+		child, exists := children[i]
+		switch {
+		case exists:
+			row.PipelineRun, row.Phase = child.Name, child.Status.Phase
+			if child.HasCompleted() {
+				completed++
+			} else if stopping {
+				if err = stopPipelineRun(ctx, r.Client, child); err != nil {
+					return ctrl.Result{}, err
+				}
+			}
+		case stopping:
+			// the combinations which have not started are never going to run
+			row.Phase = v1alpha3.Cancelled
+			completed++
+		case pr.Spec.Matrix.MaxConcurrency == 0 || running < pr.Spec.Matrix.MaxConcurrency:
+			child = newMatrixPipelineRun(pr, i, combination)
+			if err = r.Create(ctx, child); err != nil && !apierrors.IsAlreadyExists(err) {
+				r.recorder.Eventf(pr, corev1.EventTypeWarning, v1alpha3.MatrixFailed,
+					"Failed to create PipelineRun %s for combination %d, and error was %v", child.Name, i, err)
+				return ctrl.Result{}, err
+			} else if err == nil {
+				log.V(4).Info("created a child PipelineRun", "child", child.Name)
+				r.recorder.Eventf(pr, corev1.EventTypeNormal, v1alpha3.MatrixRunCreated,
+					"Created PipelineRun %s for combination %d", child.Name, i)
+			}
+			row.PipelineRun = child.Name
+			running++
+		}
+		if row.Phase == "" {
+			row.Phase = v1alpha3.Pending
+		}
+	}
+
+	now := v1.Now()
+	if status.StartTime == nil && running+completed > 0 {
+		status.StartTime = &now
+	}
+	if completed == len(combinations) {
+		completeMatrix(status, aggregateMatrixPhase(status.Matrix), summarizeMatrix(status.Matrix))
+	} else if running+completed > 0 {
+		status.Phase = v1alpha3.Running
+	} else {
+		status.Phase = v1alpha3.Pending
+	}
+	return ctrl.Result{}, r.updateStatus(ctx, pr, status)
+}
+
+// getChildren returns the latest attempts of the child PipelineRuns by the combination index
+func (r *MatrixReconciler) getChildren(ctx context.Context, pr *v1alpha3.PipelineRun) (children map[int]*v1alpha3.PipelineRun, err error) {
+	list := &v1alpha3.PipelineRunList{}
+	if err = r.List(ctx, list, client.InNamespace(pr.Namespace),
+		client.MatchingLabels{v1alpha3.PipelineRunMatrixParentLabelKey: pr.Name}); err != nil {
+		return
+	}
+	children = map[int]*v1alpha3.PipelineRun{}
+	for i := range list.Items {
+		child := &list.Items[i]
+		index, err := strconv.Atoi(child.Annotations[v1alpha3.PipelineRunMatrixIndexAnnoKey])
+		if err != nil {
+			continue
+		}
+		// a retry of a child is a child as well
+		if latest, ok := children[index]; !ok || child.GetAttempt() > latest.GetAttempt() {
+			children[index] = child
+		}
+	}
+	return
+}
+
+// updateStatus updates the status of the matrix PipelineRun if it changed
+func (r *MatrixReconciler) updateStatus(ctx context.Context, pr *v1alpha3.PipelineRun, status *v1alpha3.PipelineRunStatus) error {
+	if equality.Semantic.DeepEqual(&pr.Status, status) {
+		return nil
+	}
+	pr = pr.DeepCopy()
+	pr.Status = *status
+	pr.Status.UpdateTime = &v1.Time{Time: time.Now()}
+	return r.Status().Update(ctx, pr)
+}
+
+// newMatrixPipelineRun creates the child PipelineRun of a combination, the name is decided by the combination index,
+// so a combination never runs twice
+func newMatrixPipelineRun(parent *v1alpha3.PipelineRun, index int, combination []v1alpha3.Parameter) *v1alpha3.PipelineRun {
+	isController := false
+	child := &v1alpha3.PipelineRun{
+		ObjectMeta: v1.ObjectMeta{
+			Namespace: parent.Namespace,
+			Name:      fmt.Sprintf("%s-%d", parent.Name, index),
+			OwnerReferences: append(append([]v1.OwnerReference{}, parent.OwnerReferences...), v1.OwnerReference{
+				APIVersion: v1alpha3.GroupVersion.String(),
+				Kind:       "PipelineRun",
+				Name:       parent.Name,
+				UID:        parent.UID,
+				Controller: &isController,
+			}),
+			Labels: map[string]string{
+				v1alpha3.PipelineRunMatrixParentLabelKey: parent.Name,
+			},
+			Annotations: map[string]string{
+				v1alpha3.PipelineRunMatrixIndexAnnoKey: strconv.Itoa(index),
+			},
+		},
+		Spec: *parent.Spec.DeepCopy(),
+	}
+	for key, value := range parent.Labels {
+		child.Labels[key] = value
+	}
+	if creator := parent.Annotations[v1alpha3.PipelineRunCreatorAnnoKey]; creator != "" {
+		child.Annotations[v1alpha3.PipelineRunCreatorAnnoKey] = creator
+	}
+	child.Spec.Matrix = nil
+	child.Spec.Action = nil
+
+	// the values of the combination override the parameters of the matrix PipelineRun
+	for _, parameter := range combination {
+		overridden := false
+		for i := range child.Spec.Parameters {
+			if overridden = child.Spec.Parameters[i].Name == parameter.Name; overridden {
+				child.Spec.Parameters[i].Value = parameter.Value
+				break
+			}
+		}
+		if !overridden {
+			child.Spec.Parameters = append(child.Spec.Parameters, parameter)
+		}
+	}
+	return child
+}
+
+// aggregateMatrixPhase returns the phase of a completed matrix. It's failed if any of the children failed, or
+// cancelled if any of the combinations was cancelled.
+func aggregateMatrixPhase(rows []v1alpha3.MatrixRunStatus) v1alpha3.RunPhase {
+	phase := v1alpha3.Succeeded
+	for _, row := range rows {
+		switch row.Phase {
+		case v1alpha3.Succeeded:
+		case v1alpha3.Cancelled:
+			if phase == v1alpha3.Succeeded {
+				phase = v1alpha3.Cancelled
+			}
+		default:
+			return v1alpha3.Failed
+		}
+	}
+	return phase
+}
+
+func summarizeMatrix(rows []v1alpha3.MatrixRunStatus) string {
+	counts := map[v1alpha3.RunPhase]int{}
+	for _, row := range rows {
+		counts[row.Phase]++
+	}
+	return fmt.Sprintf("%d of %d combinations succeeded, %d failed, %d cancelled", counts[v1alpha3.Succeeded], len(rows),
+		len(rows)-counts[v1alpha3.Succeeded]-counts[v1alpha3.Cancelled], counts[v1alpha3.Cancelled])
+}
+
+// completeMatrix sets the final phase of the matrix PipelineRun
+func completeMatrix(status *v1alpha3.PipelineRunStatus, phase v1alpha3.RunPhase, message string) {
+	now := v1.Now()
+	status.Phase = phase
+	status.CompletionTime = &now
+	conditionStatus := v1alpha3.ConditionFalse
+	if phase == v1alpha3.Succeeded {
+		conditionStatus = v1alpha3.ConditionTrue
+	}
+	status.AddCondition(&v1alpha3.Condition{
+		Type:          v1alpha3.ConditionSucceeded,
+		Status:        conditionStatus,
+		Reason:        string(phase),
+		Message:       message,
+		LastProbeTime: now,
+	})
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *MatrixReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	r.recorder = mgr.GetEventRecorderFor("pipelinerun-matrix")
+	r.log = ctrl.Log.WithName("pipelinerun-matrix")
+
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("jenkins_pipelinerun_matrix").
+		For(&v1alpha3.PipelineRun{}, builder.WithPredicates(predicate.NewPredicateFuncs(func(obj client.Object) bool {
+			pr, ok := obj.(*v1alpha3.PipelineRun)
+			return ok && pr.IsMatrix()
+		}))).
+		Watches(&v1alpha3.PipelineRun{}, handler.EnqueueRequestsFromMapFunc(mapPipelineRunToMatrix)).
+		Complete(r)
+}
+
+// mapPipelineRunToMatrix maps a child PipelineRun to its matrix PipelineRun
+func mapPipelineRunToMatrix(_ context.Context, obj client.Object) []reconcile.Request {
+	parent := obj.GetLabels()[v1alpha3.PipelineRunMatrixParentLabelKey]
+	if parent == "" {
+		return nil
+	}
+	return []reconcile.Request{{
+		NamespacedName: types.NamespacedName{Namespace: obj.GetNamespace(), Name: parent},
+	}}
+}
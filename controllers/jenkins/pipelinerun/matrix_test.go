@@ -0,0 +1,185 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinerun
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/kubesphere/ks-devops/pkg/api/devops/v1alpha3"
+)
+
+func TestMatrixReconciler(t *testing.T) {
+	schema, err := v1alpha3.SchemeBuilder.Register().Build()
+	assert.Nil(t, err)
+	ctx := context.Background()
+
+	newMatrix := func(matrix *v1alpha3.Matrix) *v1alpha3.PipelineRun {
+		return &v1alpha3.PipelineRun{
+			ObjectMeta: v1.ObjectMeta{
+				Namespace:   "ns",
+				Name:        "build-abc",
+				Labels:      map[string]string{v1alpha3.PipelineNameLabelKey: "build"},
+				Annotations: map[string]string{v1alpha3.PipelineRunCreatorAnnoKey: "admin"},
+			},
+			Spec: v1alpha3.PipelineRunSpec{
+				PipelineRef: &corev1.ObjectReference{Name: "build"},
+				Parameters:  []v1alpha3.Parameter{{Name: "jdk", Value: "17"}, {Name: "debug", Value: "true"}},
+				Matrix:      matrix,
+			},
+		}
+	}
+	setup := func(pr *v1alpha3.PipelineRun) (client.Client, *MatrixReconciler, *record.FakeRecorder) {
+		c := fake.NewClientBuilder().WithScheme(schema).WithObjects(pr).
+			WithStatusSubresource(&v1alpha3.PipelineRun{}).Build()
+		recorder := record.NewFakeRecorder(10)
+		return c, &MatrixReconciler{Client: c, log: logr.Discard(), recorder: recorder}, recorder
+	}
+	reconcile := func(r *MatrixReconciler, pr *v1alpha3.PipelineRun) *v1alpha3.PipelineRun {
+		_, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(pr)})
+		assert.Nil(t, err)
+		latest := &v1alpha3.PipelineRun{}
+		assert.Nil(t, r.Get(ctx, client.ObjectKeyFromObject(pr), latest))
+		return latest
+	}
+	complete := func(c client.Client, name string, phase v1alpha3.RunPhase) {
+		child := &v1alpha3.PipelineRun{}
+		assert.Nil(t, c.Get(ctx, client.ObjectKey{Namespace: "ns", Name: name}, child))
+		child.Status.Phase = phase
+		now := v1.Now()
+		child.Status.CompletionTime = &now
+		assert.Nil(t, c.Status().Update(ctx, child))
+	}
+	axes := []v1alpha3.MatrixAxis{{Name: "jdk", Values: []string{"8", "11", "17"}}}
+
+	t.Run("invalid matrix", func(t *testing.T) {
+		pr := newMatrix(&v1alpha3.Matrix{Axes: []v1alpha3.MatrixAxis{{Name: "jdk"}}})
+		_, r, recorder := setup(pr)
+		latest := reconcile(r, pr)
+		assert.Equal(t, v1alpha3.Failed, latest.Status.Phase)
+		assert.True(t, latest.HasCompleted())
+		assert.Equal(t, "Warning MatrixFailed Invalid matrix: the axis jdk has no values", <-recorder.Events)
+	})
+
+	t.Run("concurrency limit", func(t *testing.T) {
+		pr := newMatrix(&v1alpha3.Matrix{Axes: axes, MaxConcurrency: 2})
+		c, r, _ := setup(pr)
+
+		latest := reconcile(r, pr)
+		assert.Equal(t, v1alpha3.Running, latest.Status.Phase)
+		assert.NotNil(t, latest.Status.StartTime)
+		if assert.Len(t, latest.Status.Matrix, 3) {
+			assert.Equal(t, "build-abc-0", latest.Status.Matrix[0].PipelineRun)
+			assert.Equal(t, "build-abc-1", latest.Status.Matrix[1].PipelineRun)
+			assert.Equal(t, v1alpha3.MatrixRunStatus{
+				Parameters: []v1alpha3.Parameter{{Name: "jdk", Value: "17"}},
+				Phase:      v1alpha3.Pending,
+			}, latest.Status.Matrix[2])
+		}
+
+		child := &v1alpha3.PipelineRun{}
+		assert.Nil(t, c.Get(ctx, client.ObjectKey{Namespace: "ns", Name: "build-abc-1"}, child))
+		assert.Equal(t, []v1alpha3.Parameter{{Name: "jdk", Value: "11"}, {Name: "debug", Value: "true"}}, child.Spec.Parameters)
+		assert.Nil(t, child.Spec.Matrix)
+		assert.Equal(t, "build-abc", child.Labels[v1alpha3.PipelineRunMatrixParentLabelKey])
+		assert.Equal(t, "build", child.Labels[v1alpha3.PipelineNameLabelKey])
+		assert.Equal(t, "1", child.Annotations[v1alpha3.PipelineRunMatrixIndexAnnoKey])
+		assert.Equal(t, "admin", child.Annotations[v1alpha3.PipelineRunCreatorAnnoKey])
+
+		complete(c, "build-abc-0", v1alpha3.Succeeded)
+		latest = reconcile(r, pr)
+		assert.Equal(t, "build-abc-2", latest.Status.Matrix[2].PipelineRun)
+		assert.Equal(t, v1alpha3.Succeeded, latest.Status.Matrix[0].Phase)
+
+		complete(c, "build-abc-1", v1alpha3.Succeeded)
+		complete(c, "build-abc-2", v1alpha3.Failed)
+		latest = reconcile(r, pr)
+		assert.Equal(t, v1alpha3.Failed, latest.Status.Phase)
+		assert.True(t, latest.HasCompleted())
+		assert.Equal(t, "2 of 3 combinations succeeded, 1 failed, 0 cancelled", latest.Status.GetLatestCondition().Message)
+	})
+
+	t.Run("fail fast", func(t *testing.T) {
+		pr := newMatrix(&v1alpha3.Matrix{Axes: axes, MaxConcurrency: 2, FailFast: true})
+		c, r, _ := setup(pr)
+		reconcile(r, pr)
+
+		complete(c, "build-abc-0", v1alpha3.Failed)
+		latest := reconcile(r, pr)
+		assert.False(t, latest.HasCompleted())
+		assert.Equal(t, v1alpha3.Cancelled, latest.Status.Matrix[2].Phase)
+		assert.Empty(t, latest.Status.Matrix[2].PipelineRun)
+		child := &v1alpha3.PipelineRun{}
+		assert.Nil(t, c.Get(ctx, client.ObjectKey{Namespace: "ns", Name: "build-abc-1"}, child))
+		assert.Equal(t, v1alpha3.Stop, *child.Spec.Action)
+
+		complete(c, "build-abc-1", v1alpha3.Cancelled)
+		latest = reconcile(r, pr)
+		assert.Equal(t, v1alpha3.Failed, latest.Status.Phase)
+		assert.True(t, latest.HasCompleted())
+	})
+
+	t.Run("stop", func(t *testing.T) {
+		stop := v1alpha3.Stop
+		pr := newMatrix(&v1alpha3.Matrix{Axes: axes})
+		pr.Spec.Action = &stop
+		_, r, _ := setup(pr)
+		latest := reconcile(r, pr)
+		assert.Equal(t, v1alpha3.Cancelled, latest.Status.Phase)
+		assert.True(t, latest.HasCompleted())
+	})
+
+	t.Run("retry", func(t *testing.T) {
+		pr := newMatrix(&v1alpha3.Matrix{Axes: axes[:1]})
+		pr.Spec.Matrix.Axes = []v1alpha3.MatrixAxis{{Name: "jdk", Values: []string{"8"}}}
+		c, r, _ := setup(pr)
+		reconcile(r, pr)
+		complete(c, "build-abc-0", v1alpha3.Failed)
+
+		child := &v1alpha3.PipelineRun{}
+		assert.Nil(t, c.Get(ctx, client.ObjectKey{Namespace: "ns", Name: "build-abc-0"}, child))
+		retryRun := newRetryPipelineRun(child, 2, time.Now())
+		assert.Nil(t, c.Create(ctx, retryRun))
+		latest := reconcile(r, pr)
+		assert.Equal(t, v1alpha3.Running, latest.Status.Phase)
+		assert.Equal(t, "build-abc-0-retry-2", latest.Status.Matrix[0].PipelineRun)
+
+		complete(c, "build-abc-0-retry-2", v1alpha3.Succeeded)
+		latest = reconcile(r, pr)
+		assert.Equal(t, v1alpha3.Succeeded, latest.Status.Phase)
+	})
+}
+
+func Test_aggregateMatrixPhase(t *testing.T) {
+	succeeded := v1alpha3.MatrixRunStatus{Phase: v1alpha3.Succeeded}
+	failed := v1alpha3.MatrixRunStatus{Phase: v1alpha3.Failed}
+	cancelled := v1alpha3.MatrixRunStatus{Phase: v1alpha3.Cancelled}
+	assert.Equal(t, v1alpha3.Succeeded, aggregateMatrixPhase([]v1alpha3.MatrixRunStatus{succeeded, succeeded}))
+	assert.Equal(t, v1alpha3.Cancelled, aggregateMatrixPhase([]v1alpha3.MatrixRunStatus{succeeded, cancelled}))
+	assert.Equal(t, v1alpha3.Failed, aggregateMatrixPhase([]v1alpha3.MatrixRunStatus{cancelled, failed, succeeded}))
+	assert.Equal(t, v1alpha3.Failed, aggregateMatrixPhase([]v1alpha3.MatrixRunStatus{{Phase: v1alpha3.Unknown}}))
+}
@@ -36,11 +36,17 @@ import (
 	"k8s.io/client-go/util/retry"
 	"k8s.io/klog/v2"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
 
 	"github.com/kubesphere/ks-devops/pkg/api/devops/v1alpha3"
 	devopsClient "github.com/kubesphere/ks-devops/pkg/client/devops"
 	"github.com/kubesphere/ks-devops/pkg/client/devops/jenkins"
+	"github.com/kubesphere/ks-devops/pkg/config"
+	"github.com/kubesphere/ks-devops/pkg/event/common"
+	"github.com/kubesphere/ks-devops/pkg/metrics"
+	pipelinemodel "github.com/kubesphere/ks-devops/pkg/models/pipeline"
 	cmstore "github.com/kubesphere/ks-devops/pkg/store/configmap"
 	storeInter "github.com/kubesphere/ks-devops/pkg/store/store"
 	"github.com/kubesphere/ks-devops/pkg/utils/k8sutil"
@@ -62,8 +68,25 @@ type Reconciler struct {
 	JenkinsCore          core.JenkinsCore
 	recorder             record.EventRecorder
 	PipelineRunDataStore string
+	// DataStore provides the PipelineRun data stores, the ConfigMap stores are used if it is nil
+	DataStore storeInter.Provider
+	// ResyncPeriod is the interval of retrieving the running data of the PipelineRuns which receive Jenkins events,
+	// the defaultResyncPeriod is used if it is zero
+	ResyncPeriod time.Duration
+	// PodServiceAccount is the ServiceAccount of the step Pods created by the kubernetes executor, the default
+	// ServiceAccount of the namespace is used if it is empty
+	PodServiceAccount string
+	stepsCache        *finishedStepsCache
 }
 
+const (
+	// pollInterval is the interval of retrieving the running data of the PipelineRuns without Jenkins events
+	pollInterval = 3 * time.Second
+	// defaultResyncPeriod is the default interval of retrieving the running data of the PipelineRuns which receive
+	// Jenkins events, the events trigger the retrieving in time
+	defaultResyncPeriod = time.Minute
+)
+
 //+kubebuilder:rbac:groups=devops.kubesphere.io,resources=pipelineruns,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=devops.kubesphere.io,resources=pipelineruns/status,verbs=get;update;patch
 
@@ -81,21 +104,35 @@ func (r *Reconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Resu
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
 
-	jHandler := &jenkinsHandler{&r.JenkinsCore}
+	// a matrix PipelineRun doesn't run by itself, see also MatrixReconciler
+	if pipelineRun.IsMatrix() {
+		return ctrl.Result{}, nil
+	}
 
 	// don't modify the cache in other places, like informer cache.
 	pipelineRunCopied := pipelineRun.DeepCopy()
 
 	// DeletionTimestamp.IsZero() means copyPipeline has not been deleted.
 	if !pipelineRunCopied.ObjectMeta.DeletionTimestamp.IsZero() {
+		r.stepsCache.forget(pipelineRunCopied.UID)
 		// if the annotation value is true, we should keep the record in Jenkins
 		if keep, err := strconv.ParseBool(pipelineRunCopied.Annotations[v1alpha3.PipelineRunKeepJenkinsRecordAnnoKey]); err == nil && keep {
 			klog.V(4).Infof("try to delete PipelineRun: %s/%s, but need to keep Jenkins record",
 				pipelineRunCopied.Namespace, pipelineRunCopied.Name)
-		} else if err = jHandler.deleteJenkinsJobHistory(pipelineRunCopied); err != nil {
-			klog.V(4).Infof("failed to delete Jenkins job history from PipelineRun: %s/%s, error: %v",
+		} else if exec, err := r.getExecutor(pipelineRunCopied.Annotations[v1alpha3.PipelineExecutorAnnoKey]); err != nil {
+			klog.V(4).Infof("failed to get the executor of PipelineRun: %s/%s, error: %v",
+				pipelineRunCopied.Namespace, pipelineRunCopied.Name, err)
+		} else if err = exec.delete(ctx, pipelineRunCopied); err != nil {
+			klog.V(4).Infof("failed to delete the run history of PipelineRun: %s/%s, error: %v",
 				pipelineRunCopied.Namespace, pipelineRunCopied.Name, err)
 		}
+		// the data is not owned by the PipelineRun in some data stores, so remove it here
+		if dataStore, err := r.getDataStore(); err == nil {
+			if err = dataStore.Delete(ctx, req.NamespacedName); err != nil {
+				klog.V(4).Infof("failed to delete the data of PipelineRun: %s/%s, error: %v",
+					pipelineRunCopied.Namespace, pipelineRunCopied.Name, err)
+			}
+		}
 
 		err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
 			// Get the latest version of PipelineRun
@@ -136,6 +173,11 @@ func (r *Reconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Resu
 		}
 	}
 
+	// the test report of a completed PipelineRun is collected until it succeeded
+	if pipelineRunCopied.HasCompleted() && pipelineRunCopied.Annotations[v1alpha3.PipelineRunTestReportPendingAnnoKey] == "true" {
+		return r.collectPendingTestReport(ctx, pipelineRunCopied)
+	}
+
 	// the PipelineRun cannot allow building
 	if !pipelineRunCopied.Buildable() {
 		return ctrl.Result{}, nil
@@ -165,10 +207,28 @@ func (r *Reconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Resu
 
 	log = log.WithValues("namespace", namespaceName, "Pipeline", pipelineName)
 
+	// find out the executor which runs the PipelineRun
+	executorName, err := getExecutorName(ctx, r.Client, pipeline, pipelineRunCopied)
+	if err != nil {
+		log.Error(err, "unable to get the executor name")
+		return ctrl.Result{}, err
+	}
+	exec, err := r.getExecutor(executorName)
+	if err != nil {
+		log.Error(err, "unable to get the executor", "executor", executorName)
+		r.recordFailure(ctx, pipelineRunCopied, v1alpha3.TriggerFailed, "Failed to run PipelineRun %s, and error was %v", req.NamespacedName, err)
+		return ctrl.Result{}, err
+	}
+
+	// apply the pending action, a PipelineRun can be stopped even if it has not started yet
+	if action, pending := pipelineRunCopied.GetPendingAction(); pending && (action == v1alpha3.Stop || pipelineRunCopied.HasStarted()) {
+		return ctrl.Result{}, r.handleAction(ctx, exec, pipelineRunCopied, action)
+	}
+
 	// check PipelineRun status
 	if pipelineRunCopied.HasStarted() {
-		log.V(5).Info("pipeline has already started, and we are retrieving run data from Jenkins.")
-		pipelineBuild, err := jHandler.getPipelineRunResult(namespaceName, pipelineName, pipelineRunCopied)
+		log.V(5).Info("pipeline has already started, and we are retrieving run data from the executor.", "executor", executorName)
+		pipelineBuild, err := exec.getStatus(ctx, pipeline, pipelineRunCopied)
 		if err != nil {
 			if err.Error() == BuildNotExistMsg { // retry if get pipelinerun failed by not exist
 				runID, _ := pipelineRunCopied.GetPipelineRunID()
@@ -176,14 +236,18 @@ func (r *Reconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Resu
 				return ctrl.Result{RequeueAfter: 5 * time.Second}, nil
 			}
 			log.Error(err, "unable get PipelineRun data.")
-			r.recorder.Eventf(pipelineRunCopied, corev1.EventTypeWarning, v1alpha3.RetrieveFailed, "Failed to retrieve running data from Jenkins, and error was %v", err)
+			r.recordFailure(ctx, pipelineRunCopied, v1alpha3.RetrieveFailed, "Failed to retrieve running data from Jenkins, and error was %v", err)
 			return ctrl.Result{}, err
 		}
 
-		nodeDetails, err := jHandler.getPipelineNodeDetails(pipelineName, namespaceName, pipelineRunCopied)
+		nodeDetails, err := exec.getNodeDetails(ctx, pipeline, pipelineRunCopied)
 		if err != nil {
 			log.Error(err, "unable to get PipelineRun nodes detail")
-			r.recorder.Eventf(pipelineRunCopied, corev1.EventTypeWarning, v1alpha3.RetrieveFailed, "Failed to retrieve nodes detail from Jenkins, and error was %v", err)
+			r.recordFailure(ctx, pipelineRunCopied, v1alpha3.RetrieveFailed, "Failed to retrieve nodes detail from Jenkins, and error was %v", err)
+			return ctrl.Result{}, err
+		}
+		if err = r.syncApprovals(ctx, pipeline, pipelineRunCopied, nodeDetails); err != nil {
+			log.Error(err, "unable to sync the approvals")
 			return ctrl.Result{}, err
 		}
 		runResultJSON, err := json.Marshal(pipelineBuild)
@@ -197,42 +261,97 @@ func (r *Reconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Resu
 			return ctrl.Result{}, err
 		}
 
-		// store pipelinerun stage to configmap
-		if err = r.storePipelineRunData(string(runResultJSON), string(nodeDetailsJSON), pipelineRunCopied); err != nil {
-			log.Error(err, "unable to store pipeline stages to configmap.")
-			return ctrl.Result{}, err
+		// collect the logs and the test report if the executor supports
+		var collectors []dataCollector
+		var reportCollector *testReportCollector
+		if logExec, ok := exec.(logExecutor); ok {
+			collector := &logCollector{exec: logExec, pipeline: pipeline, pr: pipelineRunCopied,
+				pipelineBuild: pipelineBuild, nodeDetails: nodeDetails}
+			if r.DataStore == nil || r.PipelineRunDataStore == config.PipelineRunDataStoreConfigMap {
+				collector.maxLogSize = maxConfigMapLogSize
+			}
+			collectors = append(collectors, collector)
+		}
+		if testReportExec, ok := exec.(testReportExecutor); ok {
+			reportCollector = &testReportCollector{exec: testReportExec, pipeline: pipeline, pr: pipelineRunCopied, pipelineBuild: pipelineBuild}
+			collectors = append(collectors, reportCollector)
+		}
+
+		// store pipelinerun stage to configmap, the status is updated even if it failed
+		storeErr := r.storePipelineRunData(string(runResultJSON), string(nodeDetailsJSON), pipelineRunCopied, collectors...)
+		if storeErr != nil {
+			log.Error(storeErr, "unable to store pipeline stages to configmap.")
+			r.recordFailure(ctx, pipelineRunCopied, v1alpha3.StoreFailed, "Failed to store the running data of PipelineRun %s, and error was %v", req.NamespacedName, storeErr)
 		}
 
 		// update pipelinerun status with pipelineBuild
 		status := pipelineRunCopied.Status.DeepCopy()
 		pbApplier := pipelineBuildApplier{pipelineBuild}
 		pbApplier.apply(status)
+		// keep the PipelineRun paused until it gets resumed or completed
+		if pipelineRunCopied.Annotations[v1alpha3.PipelineRunActionAnnoKey] == string(v1alpha3.Pause) && status.CompletionTime.IsZero() {
+			actionApplier{v1alpha3.Pause}.apply(status)
+		}
+		// retry it before the status is updated, a completed PipelineRun won't be reconciled again
+		if status.Phase == v1alpha3.Failed && !status.CompletionTime.IsZero() {
+			completed := pipelineRunCopied.DeepCopy()
+			completed.Status = *status
+			if err := r.retryPipelineRun(ctx, pipeline, completed, pipelineBuild, nodeDetails); err != nil {
+				log.Error(err, "unable to retry the PipelineRun")
+				r.recordFailure(ctx, pipelineRunCopied, v1alpha3.RetryFailed, "Failed to retry PipelineRun %s, and error was %v", req.NamespacedName, err)
+				return ctrl.Result{}, err
+			}
+		}
+		// a completed PipelineRun won't be reconciled again, so mark the test report which must be collected later
+		if reportCollector != nil && r.PipelineRunDataStore != "" && !status.CompletionTime.IsZero() &&
+			(storeErr != nil || !reportCollector.collected) {
+			if err := r.setTestReportPending(ctx, pipelineRunCopied, true); err != nil {
+				log.Error(err, "unable to mark the test report as pending")
+				return ctrl.Result{}, err
+			}
+		}
 		// Because the status is a subresource of PipelineRun, we have to update status separately.
 		// See also: https://book-v1.book.kubebuilder.io/basics/status_subresource.html
 		if err := r.updateStatus(ctx, status, req.NamespacedName); err != nil {
 			log.Error(err, "unable to update PipelineRun status.")
 			return ctrl.Result{}, err
 		}
+		if !status.CompletionTime.IsZero() {
+			r.stepsCache.forget(pipelineRunCopied.UID)
+		}
 
-		if err := r.getAgentInfo(ctx, pipelineRunCopied); err != nil {
-			log.Error(err, "unable to get agent info")
-			r.recorder.Eventf(pipelineRunCopied, corev1.EventTypeWarning, v1alpha3.RetrieveFailed, "Failed to retrieve agent info from Jenkins, and error was %v", err)
-			return ctrl.Result{}, err
+		// only the Jenkins runs have agents
+		if _, isJenkins := exec.(*jenkinsHandler); isJenkins {
+			if err := r.getAgentInfo(ctx, pipelineRunCopied); err != nil {
+				log.Error(err, "unable to get agent info")
+				r.recordFailure(ctx, pipelineRunCopied, v1alpha3.RetrieveFailed, "Failed to retrieve agent info from Jenkins, and error was %v", err)
+				return ctrl.Result{}, err
+			}
 		}
 
 		r.recorder.Eventf(pipelineRunCopied, corev1.EventTypeNormal, v1alpha3.Updated, "Updated running data for PipelineRun %s", req.NamespacedName)
 		// until the status is okay
-		// TODO make the RequeueAfter configurable
-		return ctrl.Result{RequeueAfter: 3 * time.Second}, nil
+		return ctrl.Result{RequeueAfter: r.getPollInterval(pipelineRunCopied)}, nil
+	}
+
+	// a retry waits for the backoff of the retry policy
+	if wait := retryWaitTime(pipelineRunCopied); wait > 0 {
+		return ctrl.Result{RequeueAfter: wait}, nil
+	}
+
+	// the PipelineRun might be queued or cancelled due to the concurrency policy
+	if proceed, err := r.applyConcurrencyPolicy(ctx, pipeline, pipelineRunCopied); err != nil {
+		log.Error(err, "unable to apply the concurrency policy")
+		return ctrl.Result{}, err
+	} else if !proceed {
+		return ctrl.Result{RequeueAfter: queuePollInterval}, nil
 	}
 
-	// create trigger handler
-	triggerHandler := &jenkinsHandler{&r.JenkinsCore}
 	// first run
-	jobRun, err := triggerHandler.triggerJenkinsJob(namespaceName, pipelineName, &pipelineRunCopied.Spec)
+	jobRun, err := exec.trigger(ctx, pipeline, pipelineRunCopied)
 	if err != nil {
 		log.Error(err, "unable to run pipeline", "namespace", namespaceName, "pipeline", pipeline.Name)
-		r.recorder.Eventf(pipelineRunCopied, corev1.EventTypeWarning, v1alpha3.TriggerFailed, "Failed to trigger PipelineRun %s, and error was %v", req.NamespacedName, err)
+		r.recordFailure(ctx, pipelineRunCopied, v1alpha3.TriggerFailed, "Failed to trigger PipelineRun %s, and error was %v", req.NamespacedName, err)
 		return ctrl.Result{}, err
 	}
 	// check if there is still a same PipelineRun
@@ -255,6 +374,21 @@ func (r *Reconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Resu
 		pipelineRunCopied.Annotations = make(map[string]string)
 	}
 	pipelineRunCopied.Annotations[v1alpha3.JenkinsPipelineRunIDAnnoKey] = jobRun.ID
+	// pin the executor, the PipelineRun keeps running on it even if the Pipeline was changed
+	if executorName == "" {
+		executorName = v1alpha3.ExecutorJenkins
+	}
+	pipelineRunCopied.Annotations[v1alpha3.PipelineExecutorAnnoKey] = executorName
+
+	// record the revision of the Pipeline spec which the PipelineRun ran with
+	if revision, _, err := pipelinemodel.EnsureRevision(ctx, r.Client, pipeline); err != nil {
+		log.Error(err, "unable to record the revision of the Pipeline")
+	} else {
+		if pipelineRunCopied.Labels == nil {
+			pipelineRunCopied.Labels = make(map[string]string)
+		}
+		pipelineRunCopied.Labels[v1alpha3.PipelineRevisionLabelKey] = strconv.FormatInt(revision.Spec.Revision, 10)
+	}
 
 	// the Update method only updates fields except subresource: status
 	if err := r.updateLabelsAndAnnotations(ctx, pipelineRunCopied); err != nil {
@@ -276,6 +410,51 @@ func (r *Reconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Resu
 	return ctrl.Result{}, nil
 }
 
+// handleAction applies the pending Action of the PipelineRun to the executor, then records it into the annotations and status.
+func (r *Reconciler) handleAction(ctx context.Context, exec executor, pr *v1alpha3.PipelineRun, action v1alpha3.Action) (err error) {
+	var reason string
+	pausable, isPausable := exec.(pausableExecutor)
+	switch action {
+	case v1alpha3.Stop:
+		reason = v1alpha3.Stopped
+		err = exec.stop(ctx, pr)
+	case v1alpha3.Pause, v1alpha3.Resume:
+		if !isPausable {
+			err = fmt.Errorf("the executor does not support action %s", action)
+		} else if action == v1alpha3.Pause {
+			reason = v1alpha3.Paused
+			err = pausable.pause(ctx, pr)
+		} else {
+			reason = v1alpha3.Resumed
+			err = pausable.resume(ctx, pr, pr.Annotations[v1alpha3.PipelineRunActionAnnoKey] == string(v1alpha3.Pause))
+		}
+	default:
+		r.recorder.Eventf(pr, corev1.EventTypeWarning, v1alpha3.ActionFailed, "Unsupported action %s of PipelineRun %s/%s", action, pr.Namespace, pr.Name)
+		return nil
+	}
+	if err != nil {
+		r.log.Error(err, "unable to apply action to PipelineRun", "action", action)
+		r.recorder.Eventf(pr, corev1.EventTypeWarning, v1alpha3.ActionFailed, "Failed to apply action %s to PipelineRun %s/%s, and error was %v", action, pr.Namespace, pr.Name, err)
+		return
+	}
+
+	if pr.Annotations == nil {
+		pr.Annotations = make(map[string]string)
+	}
+	pr.Annotations[v1alpha3.PipelineRunActionAnnoKey] = string(action)
+	if err = r.updateLabelsAndAnnotations(ctx, pr); err != nil {
+		return
+	}
+
+	status := pr.Status.DeepCopy()
+	actionApplier{action}.apply(status)
+	if err = r.updateStatus(ctx, status, client.ObjectKeyFromObject(pr)); err != nil {
+		return
+	}
+	r.recorder.Eventf(pr, corev1.EventTypeNormal, reason, "Applied action %s to PipelineRun %s/%s", action, pr.Namespace, pr.Name)
+	return
+}
+
 // match /blue/rest/organizations/jenkins/pipelines/{devops}/{pipeline}/runs/{run}/log/?start=0
 // match /blue/rest/organizations/jenkins/pipelines/%s/pipelines/%s/branches/%s/runs/%s/log/?
 func (r *Reconciler) getAgentInfo(ctx context.Context, pr *v1alpha3.PipelineRun) error {
@@ -333,7 +512,13 @@ func (r *Reconciler) getAgentInfo(ctx context.Context, pr *v1alpha3.PipelineRun)
 	return r.updateLabelsAndAnnotations(ctx, pr)
 }
 
-func (r *Reconciler) storePipelineRunData(runResultJSON, nodeDetailsJSON string, pipelineRunCopied *v1alpha3.PipelineRun) (err error) {
+// dataCollector collects the data which are only available in the PipelineRun data stores
+type dataCollector interface {
+	collect(ctx context.Context, dataStore storeInter.PipelineRunDataStore)
+}
+
+func (r *Reconciler) storePipelineRunData(runResultJSON, nodeDetailsJSON string, pipelineRunCopied *v1alpha3.PipelineRun,
+	collectors ...dataCollector) (err error) {
 	if r.PipelineRunDataStore == "" {
 		if pipelineRunCopied.Annotations == nil {
 			pipelineRunCopied.Annotations = make(map[string]string)
@@ -345,19 +530,37 @@ func (r *Reconciler) storePipelineRunData(runResultJSON, nodeDetailsJSON string,
 		if err = r.updateLabelsAndAnnotations(r.ctx, pipelineRunCopied); err != nil {
 			r.log.Error(err, "unable to update PipelineRun labels and annotations.")
 		}
-	} else if r.PipelineRunDataStore == "configmap" {
-		var cmStore storeInter.ConfigMapStore
-		if cmStore, err = cmstore.NewConfigMapStore(r.ctx, r.req.NamespacedName, r.Client); err == nil {
-			cmStore.SetStatus(runResultJSON)
-			cmStore.SetStages(nodeDetailsJSON)
-			cmStore.SetOwnerReference(v1.OwnerReference{
+	} else {
+		var provider storeInter.Provider
+		if provider, err = r.getDataStore(); err != nil {
+			return
+		}
+		var dataStore storeInter.OwnedStore
+		if dataStore, err = provider.Get(r.ctx, r.req.NamespacedName); err == nil {
+			dataStore.SetStatus(runResultJSON)
+			dataStore.SetStages(nodeDetailsJSON)
+			// the logs and test reports are only available in the data stores due to the size limitation of annotations
+			for _, collector := range collectors {
+				collector.collect(r.ctx, dataStore)
+			}
+			dataStore.SetOwnerReference(v1.OwnerReference{
 				APIVersion: pipelineRunCopied.APIVersion,
 				Kind:       pipelineRunCopied.Kind,
 				Name:       pipelineRunCopied.Name,
 				UID:        pipelineRunCopied.UID,
 			})
-			err = cmStore.Save()
+			err = dataStore.Save()
 		}
+	}
+	return
+}
+
+// getDataStore returns the provider of PipelineRun data stores
+func (r *Reconciler) getDataStore() (provider storeInter.Provider, err error) {
+	if r.DataStore != nil {
+		provider = r.DataStore
+	} else if r.PipelineRunDataStore == "" || r.PipelineRunDataStore == config.PipelineRunDataStoreConfigMap {
+		provider = cmstore.NewProvider(r.Client)
 	} else {
 		err = fmt.Errorf("unknown pipelineRun data store type: %s", r.PipelineRunDataStore)
 	}
@@ -416,7 +619,9 @@ func (r *Reconciler) updateLabelsAndAnnotations(ctx context.Context, pr *v1alpha
 }
 
 func (r *Reconciler) updateStatus(ctx context.Context, desiredStatus *v1alpha3.PipelineRunStatus, prKey client.ObjectKey) error {
-	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+	var previous *v1alpha3.PipelineRun
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		previous = nil
 		prToUpdate := v1alpha3.PipelineRun{}
 		err := r.Get(ctx, prKey, &prToUpdate)
 		if err != nil {
@@ -425,10 +630,52 @@ func (r *Reconciler) updateStatus(ctx context.Context, desiredStatus *v1alpha3.P
 		if reflect.DeepEqual(*desiredStatus, prToUpdate.Status) {
 			return nil
 		}
+		previous = prToUpdate.DeepCopy()
 		prToUpdate = *prToUpdate.DeepCopy()
 		prToUpdate.Status = *desiredStatus
 		return r.Status().Update(ctx, &prToUpdate)
 	})
+	if err == nil && previous != nil {
+		r.recordStatusMetrics(ctx, previous, desiredStatus)
+	}
+	return err
+}
+
+// recordStatusMetrics records the phase transition, queue time and duration of a PipelineRun into metrics
+func (r *Reconciler) recordStatusMetrics(ctx context.Context, pr *v1alpha3.PipelineRun, desiredStatus *v1alpha3.PipelineRunStatus) {
+	labels := r.getMetricsLabels(ctx, pr)
+	if pr.Status.Phase != desiredStatus.Phase {
+		metrics.RecordPipelineRunPhaseTransition(labels, string(pr.Status.Phase), string(desiredStatus.Phase))
+	}
+	if pr.Status.StartTime.IsZero() && !desiredStatus.StartTime.IsZero() {
+		metrics.ObservePipelineRunQueueDuration(labels, desiredStatus.StartTime.Sub(pr.CreationTimestamp.Time))
+	}
+	if pr.Status.CompletionTime.IsZero() && !desiredStatus.CompletionTime.IsZero() && !desiredStatus.StartTime.IsZero() {
+		metrics.ObservePipelineRunDuration(labels, string(desiredStatus.Phase), desiredStatus.CompletionTime.Sub(desiredStatus.StartTime.Time))
+	}
+}
+
+// recordFailure records a warning event of the PipelineRun, and counts the failure into metrics
+func (r *Reconciler) recordFailure(ctx context.Context, pr *v1alpha3.PipelineRun, reason, messageFmt string, args ...interface{}) {
+	r.recorder.Eventf(pr, corev1.EventTypeWarning, reason, messageFmt, args...)
+	metrics.RecordPipelineRunFailure(r.getMetricsLabels(ctx, pr), reason)
+}
+
+func (r *Reconciler) getMetricsLabels(ctx context.Context, pr *v1alpha3.PipelineRun) metrics.PipelineRunLabels {
+	labels := metrics.PipelineRunLabels{
+		Namespace: pr.Namespace,
+		Pipeline:  pr.Labels[v1alpha3.PipelineNameLabelKey],
+	}
+	if labels.Pipeline == "" && pr.Spec.PipelineRef != nil {
+		labels.Pipeline = pr.Spec.PipelineRef.Name
+	}
+	// take the namespace as the DevOpsProject if it does not belong to any DevOpsProject
+	if project, err := getDevOpsProjectName(ctx, r.Client, pr.Namespace); err == nil && project != "" {
+		labels.DevOpsProject = project
+	} else {
+		labels.DevOpsProject = pr.Namespace
+	}
+	return labels
 }
 
 func (r *Reconciler) makePipelineRunOrphan(ctx context.Context, pr *v1alpha3.PipelineRun) (err error) {
@@ -457,8 +704,35 @@ func (r *Reconciler) SetupWithManager(mgr ctrl.Manager) error {
 	// the name should obey Kubernetes naming convention: https://kubernetes.io/docs/concepts/overview/working-with-objects/names/
 	r.recorder = mgr.GetEventRecorderFor("pipelinerun-controller")
 	r.log = ctrl.Log.WithName("pipelinerun-controller")
+	r.stepsCache = newFinishedStepsCache()
 	return ctrl.NewControllerManagedBy(mgr).
 		Named("jenkins_pipelinerun_controller").
-		For(&v1alpha3.PipelineRun{}).
+		// the status updates are ignored, the running PipelineRuns are requeued by themselves or the Jenkins events
+		For(&v1alpha3.PipelineRun{}, builder.WithPredicates(predicate.Or(predicate.GenerationChangedPredicate{},
+			predicate.AnnotationChangedPredicate{}, predicate.LabelChangedPredicate{}))).
+		// the step Pods of the kubernetes executor
+		Owns(&corev1.Pod{}, builder.WithPredicates(predicate.NewPredicateFuncs(func(object client.Object) bool {
+			_, ok := object.GetLabels()[v1alpha3.PipelineRunStepLabelKey]
+			return ok
+		}))).
 		Complete(r)
 }
+
+// getPollInterval returns the interval of retrieving the running data. It could be much longer if the PipelineRun
+// receives the stage or step events from Jenkins, because every event triggers the retrieving. The run events are not
+// enough, the progress of stages would be missed without polling. The step Pods of the kubernetes executor are watched,
+// so they don't need the polling either.
+func (r *Reconciler) getPollInterval(pr *v1alpha3.PipelineRun) time.Duration {
+	eventType := strings.SplitN(pr.Annotations[v1alpha3.PipelineRunLastEventAnnoKey], "@", 2)[0]
+	switch {
+	case pr.Annotations[v1alpha3.PipelineExecutorAnnoKey] == v1alpha3.ExecutorKubernetes:
+	case eventType == common.StageStarted, eventType == common.StageCompleted,
+		eventType == common.StepStarted, eventType == common.StepCompleted:
+	default:
+		return pollInterval
+	}
+	if r.ResyncPeriod > 0 {
+		return r.ResyncPeriod
+	}
+	return defaultResyncPeriod
+}
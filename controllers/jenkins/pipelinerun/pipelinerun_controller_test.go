@@ -20,6 +20,7 @@ import (
 	"context"
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/go-logr/logr"
 	"github.com/jenkins-zh/jenkins-client/pkg/job"
@@ -27,6 +28,8 @@ import (
 	"github.com/kubesphere/ks-devops/pkg/api/devops/v1alpha1"
 	"github.com/kubesphere/ks-devops/pkg/api/devops/v1alpha3"
 	"github.com/kubesphere/ks-devops/pkg/client/clientset/versioned/scheme"
+	"github.com/kubesphere/ks-devops/pkg/constants"
+	"github.com/kubesphere/ks-devops/pkg/store/object"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	"github.com/stretchr/testify/assert"
@@ -38,6 +41,7 @@ import (
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	ctrlmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
 
 	// nolint
 	// The fakeclient will undeprecated starting with v0.7.0
@@ -432,4 +436,100 @@ func TestStorePipelineRunData(t *testing.T) {
 		PipelineRunDataStore: "",
 	}
 	assert.Nil(t, r.storePipelineRunData("", "", pipelineRun.DeepCopy()))
+
+	// store the data into the provided data store
+	dataStore := object.NewProvider(object.NewFileSystemBucket(t.TempDir()))
+	r = &Reconciler{
+		Client: fake.NewClientBuilder().WithScheme(schema).WithObjects(pipelineRun.DeepCopy()).Build(),
+		log:    logr.New(log.NullLogSink{}),
+		req: ctrl.Request{
+			NamespacedName: types.NamespacedName{Name: "name", Namespace: "ns"},
+		},
+		PipelineRunDataStore: "fs",
+		DataStore:            dataStore,
+	}
+	assert.Nil(t, r.storePipelineRunData("status", "stages", pipelineRun.DeepCopy()))
+	prStore, err := dataStore.Get(context.Background(), types.NamespacedName{Name: "name", Namespace: "ns"})
+	assert.Nil(t, err)
+	assert.Equal(t, "status", prStore.GetStatus())
+	assert.Equal(t, "stages", prStore.GetStages())
+}
+
+func TestReconciler_updateStatusWithMetrics(t *testing.T) {
+	schema, err := v1alpha3.SchemeBuilder.Register().Build()
+	assert.Nil(t, err)
+	err = v1.SchemeBuilder.AddToScheme(schema)
+	assert.Nil(t, err)
+
+	ns := &v1.Namespace{ObjectMeta: metav1.ObjectMeta{
+		Name:   "metrics-ns",
+		Labels: map[string]string{constants.DevOpsProjectLabelKey: "metrics-project"},
+	}}
+	pr := &v1alpha3.PipelineRun{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "metrics-ns",
+			Name:      "run",
+			Labels:    map[string]string{v1alpha3.PipelineNameLabelKey: "pipeline"},
+		},
+		Status: v1alpha3.PipelineRunStatus{Phase: v1alpha3.Pending},
+	}
+	r := &Reconciler{
+		Client:   fake.NewClientBuilder().WithScheme(schema).WithObjects(ns, pr).WithStatusSubresource(pr).Build(),
+		recorder: &record.FakeRecorder{},
+	}
+	assert.Equal(t, "metrics-project", r.getMetricsLabels(context.Background(), pr).DevOpsProject)
+
+	now := metav1.Now()
+	status := pr.Status.DeepCopy()
+	status.Phase = v1alpha3.Running
+	status.StartTime = &now
+	assert.Nil(t, r.updateStatus(context.Background(), status, client.ObjectKeyFromObject(pr)))
+
+	status = status.DeepCopy()
+	status.Phase = v1alpha3.Succeeded
+	status.CompletionTime = &now
+	assert.Nil(t, r.updateStatus(context.Background(), status, client.ObjectKeyFromObject(pr)))
+	// nothing changed
+	assert.Nil(t, r.updateStatus(context.Background(), status, client.ObjectKeyFromObject(pr)))
+
+	r.recordFailure(context.Background(), pr, v1alpha3.RetrieveFailed, "failed")
+
+	families, err := ctrlmetrics.Registry.Gather()
+	assert.Nil(t, err)
+	count := map[string]int{}
+	for _, family := range families {
+		for _, metric := range family.GetMetric() {
+			for _, label := range metric.GetLabel() {
+				if label.GetName() == "devops_project" && label.GetValue() == "metrics-project" {
+					count[family.GetName()]++
+				}
+			}
+		}
+	}
+	assert.Equal(t, map[string]int{
+		"ks_devops_pipelinerun_phase_transitions_total": 2,
+		"ks_devops_pipelinerun_queue_duration_seconds":  1,
+		"ks_devops_pipelinerun_duration_seconds":        1,
+		"ks_devops_pipelinerun_failures_total":          1,
+	}, count)
+}
+
+func TestReconciler_getPollInterval(t *testing.T) {
+	pr := &v1alpha3.PipelineRun{}
+	r := &Reconciler{}
+	assert.Equal(t, pollInterval, r.getPollInterval(pr))
+
+	// the progress of stages is unknown without the stage or step events
+	pr.Annotations = map[string]string{v1alpha3.PipelineRunLastEventAnnoKey: "run.started@2022-01-01T00:00:00Z"}
+	assert.Equal(t, pollInterval, r.getPollInterval(pr))
+
+	pr.Annotations = map[string]string{v1alpha3.PipelineRunLastEventAnnoKey: "step.completed@2022-01-01T00:00:00Z"}
+	assert.Equal(t, defaultResyncPeriod, r.getPollInterval(pr))
+
+	r.ResyncPeriod = 5 * time.Minute
+	assert.Equal(t, 5*time.Minute, r.getPollInterval(pr))
+
+	// the step Pods are watched
+	pr.Annotations = map[string]string{v1alpha3.PipelineExecutorAnnoKey: v1alpha3.ExecutorKubernetes}
+	assert.Equal(t, 5*time.Minute, r.getPollInterval(pr))
 }
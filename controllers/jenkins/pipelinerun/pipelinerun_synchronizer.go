@@ -99,7 +99,7 @@ func (r *SyncReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.
 		log.V(5).Error(utilerrors.NewAggregate(errs), "failed to delete all of PipelineRuns", "pipelineRunsToBeDeleted", pipelineRunsToBeDeleted)
 	}
 
-	if err := r.synchronizedSuccessfully(req.NamespacedName); err != nil {
+	if err := r.synchronizedSuccessfully(req.NamespacedName, jobRuns); err != nil {
 		return ctrl.Result{}, err
 	}
 
@@ -113,6 +113,10 @@ func (r *SyncReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.
 func hasPendingPipelineRuns(items []v1alpha3.PipelineRun) bool {
 	for i := range items {
 		item := items[i]
+		// the PipelineRuns which were cancelled before running never get a run ID
+		if !runsOnJenkins(&item) || item.HasCompleted() {
+			continue
+		}
 		if id, ok := item.Annotations[v1alpha3.JenkinsPipelineRunIDAnnoKey]; !ok || id == "" {
 			return true
 		}
@@ -120,10 +124,28 @@ func hasPendingPipelineRuns(items []v1alpha3.PipelineRun) bool {
 	return false
 }
 
+// runsOnJenkins returns false if the PipelineRun runs on another executor, or it's a matrix PipelineRun which only
+// creates the PipelineRuns of its combinations. Such PipelineRuns never have a Jenkins run ID
+func runsOnJenkins(pr *v1alpha3.PipelineRun) bool {
+	if pr.IsMatrix() {
+		return false
+	}
+	name := pr.Annotations[v1alpha3.PipelineExecutorAnnoKey]
+	return name == "" || name == v1alpha3.ExecutorJenkins
+}
+
 func createBarePipelineRunsIfNotPresent(finder pipelineRunFinder, pipeline *v1alpha3.Pipeline, jobRuns []job.PipelineRun) []v1alpha3.PipelineRun {
 	var pipelineRunsToBeCreated []v1alpha3.PipelineRun
+	if len(jobRuns) == 0 {
+		return pipelineRunsToBeCreated
+	}
+	prunedRuns := getPrunedJenkinsRuns(pipeline)
 	for i := range jobRuns {
 		jobRun := &jobRuns[i]
+		if isPrunedJenkinsRun(pipeline, prunedRuns, jobRun.Pipeline, jobRun.ID) {
+			// the PipelineRun was pruned by the retention policy, but the Jenkins record was kept
+			continue
+		}
 		if _, ok := finder.find(jobRun, pipeline.IsMultiBranch()); !ok {
 			pipelineRunsToBeCreated = append(pipelineRunsToBeCreated, *createBarePipelineRun(pipeline, jobRun))
 		}
@@ -142,6 +164,10 @@ func collectPipelineRunsDeletedInJenkins(finder pipelineRunFinder, isMultiBranch
 	}
 	for i := range pipelineRuns {
 		pipelineRun := &pipelineRuns[i]
+		if !runsOnJenkins(pipelineRun) || !pipelineRun.HasStarted() {
+			// the runs of other executors, or the cancelled ones before running, never exist in Jenkins
+			continue
+		}
 		if _, exist := existingPipelineRunNameSet[pipelineRun.Name]; !exist {
 			pipelineRunsToBeDeleted = append(pipelineRunsToBeDeleted, *pipelineRun)
 		}
@@ -173,18 +199,23 @@ func (r *SyncReconciler) deletePipelineRuns(ctx context.Context, pipelineRuns []
 	return errs
 }
 
-func (r *SyncReconciler) synchronizedSuccessfully(key client.ObjectKey) error {
+func (r *SyncReconciler) synchronizedSuccessfully(key client.ObjectKey, jobRuns []job.PipelineRun) error {
 	//pipelineToUpdate := *pipeline.DeepCopy()
 	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
 		pipelineToUpdate := &v1alpha3.Pipeline{}
 		if err := r.Client.Get(context.Background(), key, pipelineToUpdate); err != nil {
 			return err
 		}
-		if _, ok := pipelineToUpdate.Annotations[v1alpha3.PipelineRequestToSyncRunsAnnoKey]; !ok {
+		prunedRuns := getPrunedJenkinsRuns(pipelineToUpdate)
+		remainingPrunedRuns := removeDeletedJenkinsRuns(pipelineToUpdate, prunedRuns, jobRuns)
+		if _, ok := pipelineToUpdate.Annotations[v1alpha3.PipelineRequestToSyncRunsAnnoKey]; !ok &&
+			len(remainingPrunedRuns) == len(prunedRuns) {
 			return nil
 		}
 		// remove the annotation
 		delete(pipelineToUpdate.Annotations, v1alpha3.PipelineRequestToSyncRunsAnnoKey)
+		// forget the pruned runs which were deleted in Jenkins as well
+		setPrunedJenkinsRuns(pipelineToUpdate, remainingPrunedRuns)
 		// update the Pipeline
 
 		// ignore the conflict
@@ -192,6 +223,18 @@ func (r *SyncReconciler) synchronizedSuccessfully(key client.ObjectKey) error {
 	})
 }
 
+// removeDeletedJenkinsRuns returns the pruned runs of the branches which still have pruned runs in Jenkins
+func removeDeletedJenkinsRuns(pipeline *v1alpha3.Pipeline, prunedRuns map[string]int, jobRuns []job.PipelineRun) map[string]int {
+	remainingRuns := map[string]int{}
+	for i := range jobRuns {
+		if isPrunedJenkinsRun(pipeline, prunedRuns, jobRuns[i].Pipeline, jobRuns[i].ID) {
+			key := getJenkinsBranchKey(pipeline, jobRuns[i].Pipeline)
+			remainingRuns[key] = prunedRuns[key]
+		}
+	}
+	return remainingRuns
+}
+
 func createBarePipelineRun(pipeline *v1alpha3.Pipeline, run *job.PipelineRun) *v1alpha3.PipelineRun {
 	var scm *v1alpha3.SCM
 	if pipeline.Spec.Type == v1alpha3.MultiBranchPipelineType {
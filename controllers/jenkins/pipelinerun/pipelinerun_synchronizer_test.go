@@ -19,6 +19,7 @@ package pipelinerun
 import (
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/jenkins-zh/jenkins-client/pkg/job"
 	"github.com/kubesphere/ks-devops/pkg/api/devops/v1alpha3"
@@ -341,6 +342,37 @@ func Test_collectExistingPipelineRuns(t *testing.T) {
 		pipelineRunsToBeDeleted: []v1alpha3.PipelineRun{
 			createMultiBranchPipelineRun("fake-pipeline-2", "1", "dev"),
 		},
+	}, {
+		name: "Should not delete the PipelineRuns of the kubernetes executor",
+		args: args{
+			pipelineRuns: []v1alpha3.PipelineRun{{
+				ObjectMeta: v1.ObjectMeta{
+					Name: "fake-pipeline-1",
+					Annotations: map[string]string{
+						v1alpha3.PipelineExecutorAnnoKey: v1alpha3.ExecutorKubernetes,
+					},
+				},
+			}},
+			jobRuns: []job.PipelineRun{
+				createJobRun("fake-jobrun-1", "1"),
+			},
+		},
+		pipelineRunsToBeDeleted: nil,
+	}, {
+		name: "Should not delete the PipelineRuns cancelled before running",
+		args: args{
+			pipelineRuns: []v1alpha3.PipelineRun{{
+				ObjectMeta: v1.ObjectMeta{Name: "fake-pipeline-1"},
+				Status: v1alpha3.PipelineRunStatus{
+					Phase:          v1alpha3.Cancelled,
+					CompletionTime: &v1.Time{Time: time.Now()},
+				},
+			}},
+			jobRuns: []job.PipelineRun{
+				createJobRun("fake-jobrun-1", "1"),
+			},
+		},
+		pipelineRunsToBeDeleted: nil,
 	},
 	}
 	for _, tt := range tests {
@@ -373,6 +405,10 @@ func Test_createBarePipelineRunsIfNotPresent(t *testing.T) {
 			Type: v1alpha3.MultiBranchPipelineType,
 		},
 	}
+	prunedPipeline := pipeline.DeepCopy()
+	setPrunedJenkinsRuns(prunedPipeline, map[string]int{"": 1})
+	prunedMultiBranchPipeline := multiBranchPipeline.DeepCopy()
+	setPrunedJenkinsRuns(prunedMultiBranchPipeline, map[string]int{"dev": 1})
 	type args struct {
 		pipelineRuns []v1alpha3.PipelineRun
 		pipeline     *v1alpha3.Pipeline
@@ -432,6 +468,30 @@ func Test_createBarePipelineRunsIfNotPresent(t *testing.T) {
 		expectedPipelineRuns: []v1alpha3.PipelineRun{
 			*createBarePipelineRun(multiBranchPipeline, &devJobRun1),
 		},
+	}, {
+		name: "Should not create PipelineRuns for the JobRuns pruned by the retention policy",
+		args: args{
+			pipeline: prunedPipeline,
+			jobRuns: []job.PipelineRun{
+				jobRun1,
+				jobRun2,
+			},
+		},
+		expectedPipelineRuns: []v1alpha3.PipelineRun{
+			*createBarePipelineRun(prunedPipeline, &jobRun2),
+		},
+	}, {
+		name: "Should not create PipelineRuns for the JobRuns pruned by the retention policy when Pipeline is multi-branch type",
+		args: args{
+			pipeline: prunedMultiBranchPipeline,
+			jobRuns: []job.PipelineRun{
+				mainJobRun1,
+				devJobRun1,
+			},
+		},
+		expectedPipelineRuns: []v1alpha3.PipelineRun{
+			*createBarePipelineRun(prunedMultiBranchPipeline, &mainJobRun1),
+		},
 	}}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -474,6 +534,49 @@ func Test_hasPendingPipelineRuns(t *testing.T) {
 			}},
 		},
 		want: false,
+	}, {
+		name: "PipelineRuns of the kubernetes executor",
+		args: args{
+			items: []v1alpha3.PipelineRun{{
+				ObjectMeta: v1.ObjectMeta{
+					Annotations: map[string]string{
+						v1alpha3.PipelineExecutorAnnoKey: v1alpha3.ExecutorKubernetes,
+					},
+				},
+			}},
+		},
+		want: false,
+	}, {
+		name: "PipelineRuns of the jenkins executor without run ID",
+		args: args{
+			items: []v1alpha3.PipelineRun{{
+				ObjectMeta: v1.ObjectMeta{
+					Annotations: map[string]string{
+						v1alpha3.PipelineExecutorAnnoKey: v1alpha3.ExecutorJenkins,
+					},
+				},
+			}},
+		},
+		want: true,
+	}, {
+		name: "PipelineRuns cancelled before running",
+		args: args{
+			items: []v1alpha3.PipelineRun{{
+				Status: v1alpha3.PipelineRunStatus{
+					Phase:          v1alpha3.Cancelled,
+					CompletionTime: &v1.Time{Time: time.Now()},
+				},
+			}},
+		},
+		want: false,
+	}, {
+		name: "matrix PipelineRuns",
+		args: args{
+			items: []v1alpha3.PipelineRun{{
+				Spec: v1alpha3.PipelineRunSpec{Matrix: &v1alpha3.Matrix{}},
+			}},
+		},
+		want: false,
 	}}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -481,3 +584,45 @@ func Test_hasPendingPipelineRuns(t *testing.T) {
 		})
 	}
 }
+
+func Test_removeDeletedJenkinsRuns(t *testing.T) {
+	pipeline := &v1alpha3.Pipeline{}
+	multiBranchPipeline := &v1alpha3.Pipeline{Spec: v1alpha3.PipelineSpec{Type: v1alpha3.MultiBranchPipelineType}}
+
+	assert.Equal(t, map[string]int{"": 2}, removeDeletedJenkinsRuns(pipeline, map[string]int{"": 2},
+		[]job.PipelineRun{createJobRun("fake-jobrun-1", "1"), createJobRun("fake-jobrun-3", "3")}))
+	assert.Empty(t, removeDeletedJenkinsRuns(pipeline, map[string]int{"": 2},
+		[]job.PipelineRun{createJobRun("fake-jobrun-3", "3")}))
+	assert.Equal(t, map[string]int{"dev": 1}, removeDeletedJenkinsRuns(multiBranchPipeline, map[string]int{"main": 1, "dev": 1},
+		[]job.PipelineRun{createMultiBranchJobRun("fake-jobrun-dev-1", "1", "dev"), createMultiBranchJobRun("fake-jobrun-main-2", "2", "main")}))
+	assert.Empty(t, removeDeletedJenkinsRuns(pipeline, map[string]int{"": 1}, nil))
+}
+
+func Test_isPrunedJenkinsRun(t *testing.T) {
+	pipeline := &v1alpha3.Pipeline{}
+	multiBranchPipeline := &v1alpha3.Pipeline{Spec: v1alpha3.PipelineSpec{Type: v1alpha3.MultiBranchPipelineType}}
+
+	assert.True(t, isPrunedJenkinsRun(pipeline, map[string]int{"": 2}, "", "1"))
+	assert.True(t, isPrunedJenkinsRun(pipeline, map[string]int{"": 2}, "", "2"))
+	assert.False(t, isPrunedJenkinsRun(pipeline, map[string]int{"": 2}, "", "3"))
+	assert.False(t, isPrunedJenkinsRun(pipeline, map[string]int{"": 2}, "", "invalid"))
+	assert.False(t, isPrunedJenkinsRun(pipeline, map[string]int{}, "", "1"))
+	assert.True(t, isPrunedJenkinsRun(multiBranchPipeline, map[string]int{"dev": 2}, "dev", "1"))
+	assert.False(t, isPrunedJenkinsRun(multiBranchPipeline, map[string]int{"dev": 2}, "main", "1"))
+}
+
+func Test_getPrunedJenkinsRuns(t *testing.T) {
+	pipeline := &v1alpha3.Pipeline{}
+	assert.Empty(t, getPrunedJenkinsRuns(pipeline))
+
+	setPrunedJenkinsRuns(pipeline, map[string]int{"main": 3})
+	assert.Equal(t, `{"main":3}`, pipeline.Annotations[v1alpha3.PipelinePrunedJenkinsRunsAnnoKey])
+	assert.Equal(t, map[string]int{"main": 3}, getPrunedJenkinsRuns(pipeline))
+
+	// the list of the previous versions is ignored
+	pipeline.Annotations[v1alpha3.PipelinePrunedJenkinsRunsAnnoKey] = `["main/3"]`
+	assert.Empty(t, getPrunedJenkinsRuns(pipeline))
+
+	setPrunedJenkinsRuns(pipeline, nil)
+	assert.NotContains(t, pipeline.Annotations, v1alpha3.PipelinePrunedJenkinsRunsAnnoKey)
+}
@@ -0,0 +1,290 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinerun
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/jenkins-zh/jenkins-client/pkg/job"
+	"github.com/kubesphere/ks-devops/pkg/api/devops/v1alpha3"
+	"github.com/kubesphere/ks-devops/pkg/models/pipelinerun"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+//+kubebuilder:rbac:groups="",resources=pods,verbs=get;list;watch;create;delete;deletecollection
+
+// podExecutor runs the steps of a NoScmPipeline as a sequence of Pods, one Pod for each step.
+// The Pod of next step will be created once the previous one succeeded.
+type podExecutor struct {
+	client.Client
+	// serviceAccount is the ServiceAccount of the step Pods, the default one of the namespace is used if it's empty
+	serviceAccount string
+}
+
+func (e *podExecutor) trigger(ctx context.Context, pipeline *v1alpha3.Pipeline, pr *v1alpha3.PipelineRun) (run *job.PipelineRun, err error) {
+	var steps []v1alpha3.PipelineStep
+	if steps, err = getPipelineSteps(pipeline, pr); err != nil {
+		return
+	}
+	if err = e.createStepPod(ctx, pr, steps, 0); err != nil {
+		return
+	}
+
+	run = &job.PipelineRun{}
+	run.ID = pr.Name
+	run.Pipeline = pipeline.Name
+	run.State = Queued.String()
+	run.EnQueueTime = job.Time{Time: time.Now()}
+	return
+}
+
+// getStatus returns the status of the run, and creates the Pod of next step if the previous one succeeded
+func (e *podExecutor) getStatus(ctx context.Context, pipeline *v1alpha3.Pipeline, pr *v1alpha3.PipelineRun) (run *job.PipelineRun, err error) {
+	var steps []v1alpha3.PipelineStep
+	if steps, err = getPipelineSteps(pipeline, pr); err != nil {
+		return
+	}
+	var pods map[int]*corev1.Pod
+	if pods, err = e.getStepPods(ctx, pr); err != nil {
+		return
+	}
+
+	run = &job.PipelineRun{}
+	run.ID = pr.Name
+	run.Pipeline = pipeline.Name
+	run.State = Finished.String()
+	run.Result = Success.String()
+	if pod := pods[0]; pod != nil {
+		run.StartTime = job.Time{Time: pod.CreationTimestamp.Time}
+	}
+
+	for i := range steps {
+		pod := pods[i]
+		if pod == nil {
+			if i == 0 {
+				// the Pod might not be synchronized into the cache yet
+				run.State, run.Result = Queued.String(), Unknown.String()
+				return
+			}
+			// the previous step succeeded
+			if err = e.createStepPod(ctx, pr, steps, i); err != nil {
+				return
+			}
+			run.State, run.Result = Running.String(), Unknown.String()
+			return
+		}
+
+		state, result := getStepStatus(pod)
+		if state == Finished.String() && result == Success.String() {
+			continue
+		}
+		if i == 0 && state == Queued.String() {
+			run.State, run.Result = Queued.String(), Unknown.String()
+		} else if state == Finished.String() {
+			run.State, run.Result = state, result
+			run.EndTime = job.Time{Time: getPodFinishedTime(pod)}
+		} else {
+			run.State, run.Result = Running.String(), Unknown.String()
+		}
+		return
+	}
+
+	if len(steps) > 0 {
+		run.EndTime = job.Time{Time: getPodFinishedTime(pods[len(steps)-1])}
+	}
+	return
+}
+
+func (e *podExecutor) getNodeDetails(ctx context.Context, pipeline *v1alpha3.Pipeline, pr *v1alpha3.PipelineRun) (nodeDetails []pipelinerun.NodeDetail, err error) {
+	var steps []v1alpha3.PipelineStep
+	if steps, err = getPipelineSteps(pipeline, pr); err != nil {
+		return
+	}
+	var pods map[int]*corev1.Pod
+	if pods, err = e.getStepPods(ctx, pr); err != nil {
+		return
+	}
+
+	nodeDetails = make([]pipelinerun.NodeDetail, 0, len(steps))
+	for i, step := range steps {
+		node := job.Node{
+			ID:          strconv.Itoa(i),
+			DisplayName: step.Name,
+			Type:        "STAGE",
+			State:       Queued.String(),
+			Result:      Unknown.String(),
+		}
+		if i > 0 {
+			node.FirstParent = strconv.Itoa(i - 1)
+		}
+		if i+1 < len(steps) {
+			node.Edges = []job.Edge{{ID: strconv.Itoa(i + 1), Type: "STAGE"}}
+		}
+		if pod := pods[i]; pod != nil {
+			node.State, node.Result = getStepStatus(pod)
+			node.StartTime = job.Time{Time: pod.CreationTimestamp.Time}
+			if node.State == Finished.String() {
+				node.DurationInMillis = getPodFinishedTime(pod).Sub(pod.CreationTimestamp.Time).Milliseconds()
+			}
+		}
+
+		nodeDetails = append(nodeDetails, pipelinerun.NodeDetail{
+			Node: node,
+			Steps: []pipelinerun.Step{{
+				Step: job.Step{
+					ID:               node.ID,
+					DisplayName:      step.Image,
+					Type:             "STEP",
+					State:            node.State,
+					Result:           node.Result,
+					StartTime:        node.StartTime,
+					DurationInMillis: node.DurationInMillis,
+				},
+			}},
+		})
+	}
+	return
+}
+
+func (e *podExecutor) stop(ctx context.Context, pr *v1alpha3.PipelineRun) error {
+	return e.deleteStepPods(ctx, pr)
+}
+
+func (e *podExecutor) delete(ctx context.Context, pr *v1alpha3.PipelineRun) error {
+	return e.deleteStepPods(ctx, pr)
+}
+
+func (e *podExecutor) deleteStepPods(ctx context.Context, pr *v1alpha3.PipelineRun) (err error) {
+	if err = e.DeleteAllOf(ctx, &corev1.Pod{}, client.InNamespace(pr.Namespace),
+		client.MatchingLabels{v1alpha3.PipelineRunNameLabelKey: pr.Name}); err != nil {
+		err = fmt.Errorf("failed to delete the Pods of PipelineRun: %s/%s, error: %v", pr.Namespace, pr.Name, err)
+	}
+	return
+}
+
+// getStepPods returns the Pods of a PipelineRun, the key is the index of step
+func (e *podExecutor) getStepPods(ctx context.Context, pr *v1alpha3.PipelineRun) (pods map[int]*corev1.Pod, err error) {
+	podList := &corev1.PodList{}
+	if err = e.List(ctx, podList, client.InNamespace(pr.Namespace),
+		client.MatchingLabels{v1alpha3.PipelineRunNameLabelKey: pr.Name}); err != nil {
+		return
+	}
+
+	pods = make(map[int]*corev1.Pod, len(podList.Items))
+	for i := range podList.Items {
+		pod := &podList.Items[i]
+		if index, convErr := strconv.Atoi(pod.Labels[v1alpha3.PipelineRunStepLabelKey]); convErr == nil {
+			pods[index] = pod
+		}
+	}
+	return
+}
+
+func (e *podExecutor) createStepPod(ctx context.Context, pr *v1alpha3.PipelineRun, steps []v1alpha3.PipelineStep, index int) (err error) {
+	pod := newStepPod(pr, steps[index], index)
+	pod.Spec.ServiceAccountName = e.serviceAccount
+	if err = e.Create(ctx, pod); err != nil {
+		if apierrors.IsAlreadyExists(err) {
+			err = nil
+		} else {
+			err = fmt.Errorf("failed to create the Pod of step %s, error: %v", steps[index].Name, err)
+		}
+	}
+	return
+}
+
+func newStepPod(pr *v1alpha3.PipelineRun, step v1alpha3.PipelineStep, index int) *corev1.Pod {
+	env := make([]corev1.EnvVar, 0, len(pr.Spec.Parameters)+len(step.Env))
+	for _, param := range pr.Spec.Parameters {
+		env = append(env, corev1.EnvVar{Name: param.Name, Value: param.Value})
+	}
+	env = append(env, step.Env...)
+
+	labels := map[string]string{
+		v1alpha3.PipelineRunNameLabelKey: pr.Name,
+		v1alpha3.PipelineRunStepLabelKey: strconv.Itoa(index),
+	}
+	if pr.Spec.PipelineRef != nil {
+		labels[v1alpha3.PipelineNameLabelKey] = pr.Spec.PipelineRef.Name
+	}
+
+	return &corev1.Pod{
+		ObjectMeta: v1.ObjectMeta{
+			Name:      fmt.Sprintf("%s-step-%d", pr.Name, index),
+			Namespace: pr.Namespace,
+			Labels:    labels,
+			OwnerReferences: []v1.OwnerReference{
+				*v1.NewControllerRef(pr, v1alpha3.GroupVersion.WithKind("PipelineRun")),
+			},
+		},
+		Spec: corev1.PodSpec{
+			RestartPolicy: corev1.RestartPolicyNever,
+			Containers: []corev1.Container{{
+				Name:       "step",
+				Image:      step.Image,
+				Command:    step.Command,
+				Args:       step.Args,
+				WorkingDir: step.WorkingDir,
+				Env:        env,
+			}},
+		},
+	}
+}
+
+// getPipelineSteps returns the steps from the Pipeline spec of a PipelineRun, or the Pipeline itself
+func getPipelineSteps(pipeline *v1alpha3.Pipeline, pr *v1alpha3.PipelineRun) (steps []v1alpha3.PipelineStep, err error) {
+	spec := pr.Spec.PipelineSpec
+	if spec == nil && pipeline != nil {
+		spec = &pipeline.Spec
+	}
+	if spec == nil || spec.Type != v1alpha3.NoScmPipelineType || spec.Pipeline == nil || len(spec.Pipeline.Steps) == 0 {
+		err = fmt.Errorf("the executor %s only supports the Pipeline with steps", v1alpha3.ExecutorKubernetes)
+		return
+	}
+	steps = spec.Pipeline.Steps
+	return
+}
+
+// getStepStatus converts the phase of a Pod to the state and result of a Jenkins run
+func getStepStatus(pod *corev1.Pod) (state, result string) {
+	switch pod.Status.Phase {
+	case corev1.PodSucceeded:
+		return Finished.String(), Success.String()
+	case corev1.PodFailed:
+		return Finished.String(), Failure.String()
+	case corev1.PodRunning:
+		return Running.String(), Unknown.String()
+	default:
+		return Queued.String(), Unknown.String()
+	}
+}
+
+// getPodFinishedTime returns the time when the container of a Pod terminated
+func getPodFinishedTime(pod *corev1.Pod) time.Time {
+	for _, status := range pod.Status.ContainerStatuses {
+		if status.State.Terminated != nil {
+			return status.State.Terminated.FinishedAt.Time
+		}
+	}
+	return time.Now()
+}
@@ -0,0 +1,170 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinerun
+
+import (
+	"context"
+	"testing"
+
+	"github.com/kubesphere/ks-devops/pkg/api/devops/v1alpha3"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func newStepsPipeline(steps ...v1alpha3.PipelineStep) *v1alpha3.Pipeline {
+	return &v1alpha3.Pipeline{
+		ObjectMeta: v1.ObjectMeta{Namespace: "ns", Name: "pipeline"},
+		Spec: v1alpha3.PipelineSpec{
+			Type:     v1alpha3.NoScmPipelineType,
+			Pipeline: &v1alpha3.NoScmPipeline{Name: "pipeline", Steps: steps},
+		},
+	}
+}
+
+func TestPodExecutor(t *testing.T) {
+	schema, err := v1alpha3.SchemeBuilder.Register().Build()
+	assert.Nil(t, err)
+	err = corev1.SchemeBuilder.AddToScheme(schema)
+	assert.Nil(t, err)
+
+	pipeline := newStepsPipeline(v1alpha3.PipelineStep{
+		Name:    "build",
+		Image:   "golang",
+		Command: []string{"make"},
+		Env:     []corev1.EnvVar{{Name: "GOOS", Value: "linux"}},
+	}, v1alpha3.PipelineStep{
+		Name:  "test",
+		Image: "golang",
+	})
+	pr := &v1alpha3.PipelineRun{
+		ObjectMeta: v1.ObjectMeta{Namespace: "ns", Name: "run"},
+		Spec: v1alpha3.PipelineRunSpec{
+			PipelineRef: &corev1.ObjectReference{Name: "pipeline"},
+			Parameters:  []v1alpha3.Parameter{{Name: "VERSION", Value: "v1"}},
+		},
+	}
+	ctx := context.Background()
+	exec := &podExecutor{Client: fake.NewClientBuilder().WithScheme(schema).Build(), serviceAccount: "runner"}
+
+	setPodPhase := func(name string, phase corev1.PodPhase) {
+		pod := &corev1.Pod{}
+		assert.Nil(t, exec.Get(ctx, types.NamespacedName{Namespace: "ns", Name: name}, pod))
+		pod.Status.Phase = phase
+		assert.Nil(t, exec.Status().Update(ctx, pod))
+	}
+
+	// trigger the first step
+	run, err := exec.trigger(ctx, pipeline, pr)
+	assert.Nil(t, err)
+	assert.Equal(t, "run", run.ID)
+	assert.Equal(t, Queued.String(), run.State)
+
+	pod := &corev1.Pod{}
+	assert.Nil(t, exec.Get(ctx, types.NamespacedName{Namespace: "ns", Name: "run-step-0"}, pod))
+	assert.Equal(t, "run", pod.Labels[v1alpha3.PipelineRunNameLabelKey])
+	assert.Equal(t, "0", pod.Labels[v1alpha3.PipelineRunStepLabelKey])
+	assert.Equal(t, "pipeline", pod.Labels[v1alpha3.PipelineNameLabelKey])
+	assert.Equal(t, corev1.RestartPolicyNever, pod.Spec.RestartPolicy)
+	assert.Equal(t, "runner", pod.Spec.ServiceAccountName)
+	assert.Equal(t, "golang", pod.Spec.Containers[0].Image)
+	assert.Equal(t, []string{"make"}, pod.Spec.Containers[0].Command)
+	assert.Equal(t, []corev1.EnvVar{{Name: "VERSION", Value: "v1"}, {Name: "GOOS", Value: "linux"}}, pod.Spec.Containers[0].Env)
+
+	run, err = exec.getStatus(ctx, pipeline, pr)
+	assert.Nil(t, err)
+	assert.Equal(t, Queued.String(), run.State)
+
+	// the second step is created once the first one succeeded
+	setPodPhase("run-step-0", corev1.PodSucceeded)
+	run, err = exec.getStatus(ctx, pipeline, pr)
+	assert.Nil(t, err)
+	assert.Equal(t, Running.String(), run.State)
+	assert.Equal(t, Unknown.String(), run.Result)
+	assert.Nil(t, exec.Get(ctx, types.NamespacedName{Namespace: "ns", Name: "run-step-1"}, &corev1.Pod{}))
+
+	nodeDetails, err := exec.getNodeDetails(ctx, pipeline, pr)
+	assert.Nil(t, err)
+	if assert.Equal(t, 2, len(nodeDetails)) {
+		assert.Equal(t, "build", nodeDetails[0].DisplayName)
+		assert.Equal(t, Finished.String(), nodeDetails[0].State)
+		assert.Equal(t, Success.String(), nodeDetails[0].Result)
+		assert.Equal(t, "0", nodeDetails[1].FirstParent)
+		assert.Equal(t, Queued.String(), nodeDetails[1].State)
+		assert.Equal(t, 1, len(nodeDetails[1].Steps))
+	}
+
+	// the run fails if any step failed
+	setPodPhase("run-step-1", corev1.PodFailed)
+	run, err = exec.getStatus(ctx, pipeline, pr)
+	assert.Nil(t, err)
+	assert.Equal(t, Finished.String(), run.State)
+	assert.Equal(t, Failure.String(), run.Result)
+
+	// all the Pods are deleted once it was stopped
+	assert.Nil(t, exec.stop(ctx, pr))
+	podList := &corev1.PodList{}
+	assert.Nil(t, exec.List(ctx, podList, client.InNamespace("ns")))
+	assert.Equal(t, 0, len(podList.Items))
+}
+
+func TestPodExecutor_succeeded(t *testing.T) {
+	schema, err := v1alpha3.SchemeBuilder.Register().Build()
+	assert.Nil(t, err)
+	err = corev1.SchemeBuilder.AddToScheme(schema)
+	assert.Nil(t, err)
+
+	pipeline := newStepsPipeline(v1alpha3.PipelineStep{Name: "build", Image: "golang"})
+	pr := &v1alpha3.PipelineRun{ObjectMeta: v1.ObjectMeta{Namespace: "ns", Name: "run"}}
+	pod := newStepPod(pr, pipeline.Spec.Pipeline.Steps[0], 0)
+	pod.Status.Phase = corev1.PodSucceeded
+
+	exec := &podExecutor{Client: fake.NewClientBuilder().WithScheme(schema).WithObjects(pod).Build()}
+	run, err := exec.getStatus(context.Background(), pipeline, pr)
+	assert.Nil(t, err)
+	assert.Equal(t, Finished.String(), run.State)
+	assert.Equal(t, Success.String(), run.Result)
+}
+
+func Test_getPipelineSteps(t *testing.T) {
+	steps := []v1alpha3.PipelineStep{{Name: "build", Image: "golang"}}
+
+	// take the steps from the Pipeline
+	result, err := getPipelineSteps(newStepsPipeline(steps...), &v1alpha3.PipelineRun{})
+	assert.Nil(t, err)
+	assert.Equal(t, steps, result)
+
+	// the spec of PipelineRun comes first
+	result, err = getPipelineSteps(newStepsPipeline(), &v1alpha3.PipelineRun{
+		Spec: v1alpha3.PipelineRunSpec{PipelineSpec: &newStepsPipeline(steps...).Spec},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, steps, result)
+
+	// no steps
+	_, err = getPipelineSteps(newStepsPipeline(), &v1alpha3.PipelineRun{})
+	assert.NotNil(t, err)
+
+	// not supported Pipeline type
+	_, err = getPipelineSteps(&v1alpha3.Pipeline{Spec: v1alpha3.PipelineSpec{Type: v1alpha3.MultiBranchPipelineType}}, &v1alpha3.PipelineRun{})
+	assert.NotNil(t, err)
+	_, err = getPipelineSteps(nil, &v1alpha3.PipelineRun{})
+	assert.NotNil(t, err)
+}
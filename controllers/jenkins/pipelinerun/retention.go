@@ -0,0 +1,320 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinerun
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/go-logr/logr"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/retry"
+	"k8s.io/klog/v2"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/kubesphere/ks-devops/pkg/api/devops/v1alpha3"
+	"github.com/kubesphere/ks-devops/pkg/utils/sliceutil"
+)
+
+// retentionInterval is the interval of checking the expired PipelineRuns if there is a max age
+var retentionInterval = 10 * time.Minute
+
+// RetentionReconciler prunes the completed PipelineRuns of a Pipeline according to its retention policy.
+// The data of the pruned PipelineRuns are removed by the finalizer of PipelineRun.
+type RetentionReconciler struct {
+	client.Client
+	log      logr.Logger
+	recorder record.EventRecorder
+}
+
+// Reconcile prunes the PipelineRuns of a Pipeline
+func (r *RetentionReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := r.log.WithValues("Pipeline", req.NamespacedName)
+	pipeline := &v1alpha3.Pipeline{}
+	if err := r.Get(ctx, req.NamespacedName, pipeline); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	policy := pipeline.Spec.GetRetentionPolicy()
+	if policy == nil || !pipeline.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, nil
+	}
+
+	prList := &v1alpha3.PipelineRunList{}
+	if err := r.List(ctx, prList, client.InNamespace(pipeline.Namespace),
+		client.MatchingLabels{v1alpha3.PipelineNameLabelKey: pipeline.Name}); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	var errs []error
+	pipelineRunsToBePruned := selectPipelineRunsToPrune(policy, prList.Items, time.Now())
+	// the kept Jenkins records must not be synchronized back as PipelineRuns
+	if err := r.recordPrunedJenkinsRuns(ctx, pipeline, pipelineRunsToBePruned, policy.KeepJenkinsRecords); err != nil {
+		return ctrl.Result{}, err
+	}
+	for i := range pipelineRunsToBePruned {
+		pr := &pipelineRunsToBePruned[i]
+		if err := r.prunePipelineRun(ctx, pipeline, pr, policy.KeepJenkinsRecords); err != nil {
+			r.recorder.Eventf(pipeline, v1.EventTypeWarning, v1alpha3.PruneFailed,
+				"Failed to prune PipelineRun %s, and error was %v", pr.Name, err)
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return ctrl.Result{}, utilerrors.NewAggregate(errs)
+	}
+	if len(pipelineRunsToBePruned) > 0 {
+		log.Info("pruned PipelineRuns", "count", len(pipelineRunsToBePruned))
+	}
+
+	// the PipelineRuns will expire even if nothing changed
+	if policy.MaxAge != nil {
+		return ctrl.Result{RequeueAfter: retentionInterval}, nil
+	}
+	return ctrl.Result{}, nil
+}
+
+// prunePipelineRun deletes a PipelineRun, the Jenkins build is kept if the PipelineRun has the annotation
+func (r *RetentionReconciler) prunePipelineRun(ctx context.Context, pipeline *v1alpha3.Pipeline, pr *v1alpha3.PipelineRun,
+	keepJenkinsRecord bool) (err error) {
+	if keepJenkinsRecord && !keepsJenkinsRecord(pr) {
+		if err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+			latest := &v1alpha3.PipelineRun{}
+			if err := r.Get(ctx, client.ObjectKeyFromObject(pr), latest); err != nil {
+				return err
+			}
+			if latest.Annotations == nil {
+				latest.Annotations = map[string]string{}
+			}
+			latest.Annotations[v1alpha3.PipelineRunKeepJenkinsRecordAnnoKey] = "true"
+			return r.Update(ctx, latest)
+		}); err != nil {
+			return client.IgnoreNotFound(err)
+		}
+		if pr.Annotations == nil {
+			pr.Annotations = map[string]string{}
+		}
+		pr.Annotations[v1alpha3.PipelineRunKeepJenkinsRecordAnnoKey] = "true"
+	}
+
+	if err = r.Delete(ctx, pr); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	if keepsJenkinsRecord(pr) {
+		r.recorder.Eventf(pipeline, v1.EventTypeNormal, v1alpha3.Pruned,
+			"Pruned PipelineRun %s due to the retention policy, and kept its Jenkins record", pr.Name)
+	} else {
+		r.recorder.Eventf(pipeline, v1.EventTypeNormal, v1alpha3.Pruned,
+			"Pruned PipelineRun %s due to the retention policy", pr.Name)
+	}
+	return
+}
+
+// recordPrunedJenkinsRuns raises the pruned run numbers of the Pipeline to the Jenkins runs whose records will be kept
+func (r *RetentionReconciler) recordPrunedJenkinsRuns(ctx context.Context, pipeline *v1alpha3.Pipeline,
+	pipelineRuns []v1alpha3.PipelineRun, keepJenkinsRecords bool) error {
+	runs := map[string]int{}
+	for i := range pipelineRuns {
+		pr := &pipelineRuns[i]
+		if !runsOnJenkins(pr) || !(keepJenkinsRecords || keepsJenkinsRecord(pr)) {
+			continue
+		}
+		runID, _ := pr.GetPipelineRunID()
+		if number, err := strconv.Atoi(runID); err == nil {
+			key := getJenkinsBranchKey(pipeline, pr.GetRefName())
+			if number > runs[key] {
+				runs[key] = number
+			}
+		}
+	}
+	if len(runs) == 0 {
+		return nil
+	}
+
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		latest := &v1alpha3.Pipeline{}
+		if err := r.Get(ctx, client.ObjectKeyFromObject(pipeline), latest); err != nil {
+			return err
+		}
+		prunedRuns := getPrunedJenkinsRuns(latest)
+		changed := false
+		for key, number := range runs {
+			if number > prunedRuns[key] {
+				prunedRuns[key] = number
+				changed = true
+			}
+		}
+		if !changed {
+			return nil
+		}
+		setPrunedJenkinsRuns(latest, prunedRuns)
+		return r.Update(ctx, latest)
+	})
+}
+
+// keepsJenkinsRecord returns true if the Jenkins record is kept when the PipelineRun is deleted, it's the same rule as
+// the finalizer of PipelineRun
+func keepsJenkinsRecord(pr *v1alpha3.PipelineRun) bool {
+	keep, err := strconv.ParseBool(pr.Annotations[v1alpha3.PipelineRunKeepJenkinsRecordAnnoKey])
+	return err == nil && keep
+}
+
+// getJenkinsBranchKey returns the key of a branch in the pruned runs of a Pipeline, it's empty if the Pipeline is not
+// multi-branch
+func getJenkinsBranchKey(pipeline *v1alpha3.Pipeline, refName string) string {
+	if pipeline.IsMultiBranch() {
+		return refName
+	}
+	return ""
+}
+
+// getPrunedJenkinsRuns returns the highest run number of each branch whose PipelineRun was pruned
+func getPrunedJenkinsRuns(pipeline *v1alpha3.Pipeline) map[string]int {
+	runs := map[string]int{}
+	if value := pipeline.Annotations[v1alpha3.PipelinePrunedJenkinsRunsAnnoKey]; value != "" {
+		if err := json.Unmarshal([]byte(value), &runs); err != nil {
+			klog.V(4).Infof("ignored the invalid pruned Jenkins runs of Pipeline %s/%s, error: %v",
+				pipeline.Namespace, pipeline.Name, err)
+			return map[string]int{}
+		}
+	}
+	return runs
+}
+
+// isPrunedJenkinsRun returns true if the number of the Jenkins run is not higher than the pruned one of its branch
+func isPrunedJenkinsRun(pipeline *v1alpha3.Pipeline, prunedRuns map[string]int, refName, runID string) bool {
+	pruned, ok := prunedRuns[getJenkinsBranchKey(pipeline, refName)]
+	if !ok {
+		return false
+	}
+	number, err := strconv.Atoi(runID)
+	return err == nil && number <= pruned
+}
+
+// setPrunedJenkinsRuns sets the highest pruned run number of each branch, the annotation is removed if there is none
+func setPrunedJenkinsRuns(pipeline *v1alpha3.Pipeline, runs map[string]int) {
+	if len(runs) == 0 {
+		delete(pipeline.Annotations, v1alpha3.PipelinePrunedJenkinsRunsAnnoKey)
+		return
+	}
+	if pipeline.Annotations == nil {
+		pipeline.Annotations = map[string]string{}
+	}
+	data, _ := json.Marshal(runs)
+	pipeline.Annotations[v1alpha3.PipelinePrunedJenkinsRunsAnnoKey] = string(data)
+}
+
+// selectPipelineRunsToPrune returns the completed PipelineRuns which exceed the max count or the max age. The newest
+// PipelineRuns are kept first.
+func selectPipelineRunsToPrune(policy *v1alpha3.RetentionPolicy, pipelineRuns []v1alpha3.PipelineRun, now time.Time) (
+	pipelineRunsToBePruned []v1alpha3.PipelineRun) {
+	var phases []string
+	for _, phase := range policy.Phases {
+		phases = append(phases, string(phase))
+	}
+
+	groups := map[string][]v1alpha3.PipelineRun{}
+	var keys []string
+	for i := range pipelineRuns {
+		pr := pipelineRuns[i]
+		if !pr.HasCompleted() || !pr.DeletionTimestamp.IsZero() || pr.Labels[v1alpha3.PipelineRunKeepForeverLabelKey] == "true" ||
+			(len(phases) > 0 && !sliceutil.HasString(phases, string(pr.Status.Phase))) {
+			continue
+		}
+		var key string
+		if policy.PerBranch && pr.Spec.SCM != nil {
+			key = pr.Spec.SCM.RefName
+		}
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], pr)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		group := groups[key]
+		sort.SliceStable(group, func(i, j int) bool {
+			a, b := group[i].Status.CompletionTime, group[j].Status.CompletionTime
+			if !a.Equal(b) {
+				return b.Before(a)
+			}
+			return group[i].Name > group[j].Name
+		})
+		for i := range group {
+			if (policy.MaxCount > 0 && i >= policy.MaxCount) ||
+				(policy.MaxAge != nil && now.Sub(group[i].Status.CompletionTime.Time) > policy.MaxAge.Duration) {
+				pipelineRunsToBePruned = append(pipelineRunsToBePruned, group[i])
+			}
+		}
+	}
+	return
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *RetentionReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	r.recorder = mgr.GetEventRecorderFor("pipelinerun-retention")
+	r.log = ctrl.Log.WithName("pipelinerun-retention")
+
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("jenkins_pipelinerun_retention").
+		For(&v1alpha3.Pipeline{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Watches(&v1alpha3.PipelineRun{}, handler.EnqueueRequestsFromMapFunc(mapPipelineRunToPipeline),
+			builder.WithPredicates(pipelineRunCompletedPredicate())).
+		Complete(r)
+}
+
+func mapPipelineRunToPipeline(_ context.Context, obj client.Object) []reconcile.Request {
+	pipelineName := obj.GetLabels()[v1alpha3.PipelineNameLabelKey]
+	if pipelineName == "" {
+		return nil
+	}
+	return []reconcile.Request{{
+		NamespacedName: types.NamespacedName{Namespace: obj.GetNamespace(), Name: pipelineName},
+	}}
+}
+
+// pipelineRunCompletedPredicate only accepts the PipelineRuns which just completed
+func pipelineRunCompletedPredicate() predicate.Predicate {
+	return predicate.Funcs{
+		CreateFunc: func(e event.CreateEvent) bool {
+			return false
+		},
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			oldPR, okOld := e.ObjectOld.(*v1alpha3.PipelineRun)
+			newPR, okNew := e.ObjectNew.(*v1alpha3.PipelineRun)
+			return okOld && okNew && !oldPR.HasCompleted() && newPR.HasCompleted()
+		},
+		DeleteFunc: func(e event.DeleteEvent) bool {
+			return false
+		},
+		GenericFunc: func(e event.GenericEvent) bool {
+			return false
+		},
+	}
+}
@@ -0,0 +1,245 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinerun
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+
+	"github.com/kubesphere/ks-devops/pkg/api/devops/v1alpha3"
+)
+
+func newCompletedPipelineRun(name string, completed time.Time, phase v1alpha3.RunPhase, branch string) *v1alpha3.PipelineRun {
+	pr := &v1alpha3.PipelineRun{
+		ObjectMeta: v1.ObjectMeta{
+			Namespace: "ns",
+			Name:      name,
+			Labels:    map[string]string{v1alpha3.PipelineNameLabelKey: "pipeline"},
+		},
+		Status: v1alpha3.PipelineRunStatus{Phase: phase, CompletionTime: &v1.Time{Time: completed}},
+	}
+	if branch != "" {
+		pr.Spec.SCM = &v1alpha3.SCM{RefName: branch}
+	}
+	return pr
+}
+
+func Test_selectPipelineRunsToPrune(t *testing.T) {
+	now := time.Now()
+	running := newCompletedPipelineRun("running", now, v1alpha3.Running, "")
+	running.Status.CompletionTime = nil
+	keepForever := newCompletedPipelineRun("keep-forever", now.Add(-time.Hour), v1alpha3.Succeeded, "")
+	keepForever.Labels[v1alpha3.PipelineRunKeepForeverLabelKey] = "true"
+	pipelineRuns := []v1alpha3.PipelineRun{
+		*newCompletedPipelineRun("a", now.Add(-3*time.Hour), v1alpha3.Succeeded, "main"),
+		*newCompletedPipelineRun("b", now.Add(-2*time.Hour), v1alpha3.Failed, "dev"),
+		*newCompletedPipelineRun("c", now.Add(-time.Minute), v1alpha3.Succeeded, "main"),
+		*newCompletedPipelineRun("d", now.Add(-2*time.Minute), v1alpha3.Failed, "dev"),
+		*running,
+		*keepForever,
+	}
+
+	tests := []struct {
+		name   string
+		policy *v1alpha3.RetentionPolicy
+		want   []string
+	}{{
+		name:   "keep all",
+		policy: &v1alpha3.RetentionPolicy{},
+	}, {
+		name:   "by count",
+		policy: &v1alpha3.RetentionPolicy{MaxCount: 2},
+		want:   []string{"b", "a"},
+	}, {
+		name:   "by age",
+		policy: &v1alpha3.RetentionPolicy{MaxAge: &v1.Duration{Duration: time.Hour}},
+		want:   []string{"b", "a"},
+	}, {
+		name:   "by phase",
+		policy: &v1alpha3.RetentionPolicy{MaxCount: 1, Phases: []v1alpha3.RunPhase{v1alpha3.Failed}},
+		want:   []string{"b"},
+	}, {
+		name:   "per branch",
+		policy: &v1alpha3.RetentionPolicy{MaxCount: 1, PerBranch: true},
+		want:   []string{"b", "a"},
+	}, {
+		name:   "per branch by count and age",
+		policy: &v1alpha3.RetentionPolicy{MaxCount: 2, PerBranch: true, MaxAge: &v1.Duration{Duration: 150 * time.Minute}},
+		want:   []string{"a"},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var names []string
+			for _, pr := range selectPipelineRunsToPrune(tt.policy, pipelineRuns, now) {
+				names = append(names, pr.Name)
+			}
+			assert.Equal(t, tt.want, names)
+		})
+	}
+}
+
+func TestRetentionReconciler_Reconcile(t *testing.T) {
+	schema, err := v1alpha3.SchemeBuilder.Register().Build()
+	assert.Nil(t, err)
+
+	now := time.Now()
+	pipeline := &v1alpha3.Pipeline{
+		ObjectMeta: v1.ObjectMeta{Namespace: "ns", Name: "pipeline"},
+		Spec: v1alpha3.PipelineSpec{
+			Pipeline:        &v1alpha3.NoScmPipeline{Discarder: &v1alpha3.DiscarderProperty{NumToKeep: "1"}},
+			RetentionPolicy: &v1alpha3.RetentionPolicy{FromDiscarder: true},
+		},
+	}
+	keepRecord := newCompletedPipelineRun("keep-record", now.Add(-2*time.Hour), v1alpha3.Succeeded, "")
+	keepRecord.Annotations = map[string]string{
+		v1alpha3.PipelineRunKeepJenkinsRecordAnnoKey: "true",
+		v1alpha3.JenkinsPipelineRunIDAnnoKey:         "1",
+	}
+	objects := []client.Object{
+		newCompletedPipelineRun("old", now.Add(-time.Hour), v1alpha3.Succeeded, ""),
+		newCompletedPipelineRun("new", now, v1alpha3.Succeeded, ""),
+		keepRecord,
+	}
+
+	t.Run("the policy comes from the discarder", func(t *testing.T) {
+		c := fake.NewClientBuilder().WithScheme(schema).WithObjects(pipeline.DeepCopy()).WithObjects(objects...).Build()
+		recorder := record.NewFakeRecorder(10)
+		r := &RetentionReconciler{Client: c, log: logr.Discard(), recorder: recorder}
+
+		result, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: client.ObjectKeyFromObject(pipeline)})
+		assert.Nil(t, err)
+		assert.Equal(t, ctrl.Result{}, result)
+
+		list := &v1alpha3.PipelineRunList{}
+		assert.Nil(t, c.List(context.Background(), list))
+		if assert.Len(t, list.Items, 1) {
+			assert.Equal(t, "new", list.Items[0].Name)
+		}
+		assert.Len(t, recorder.Events, 2)
+		assert.Contains(t, <-recorder.Events, "Pruned PipelineRun old")
+		assert.Contains(t, <-recorder.Events, "Pruned PipelineRun keep-record due to the retention policy, and kept its Jenkins record")
+
+		latest := &v1alpha3.Pipeline{}
+		assert.Nil(t, c.Get(context.Background(), client.ObjectKeyFromObject(pipeline), latest))
+		assert.Equal(t, map[string]int{"": 1}, getPrunedJenkinsRuns(latest))
+	})
+
+	t.Run("the discarder is not used without opting in", func(t *testing.T) {
+		notOptedIn := pipeline.DeepCopy()
+		notOptedIn.Spec.RetentionPolicy = nil
+		c := fake.NewClientBuilder().WithScheme(schema).WithObjects(notOptedIn).WithObjects(objects...).Build()
+		r := &RetentionReconciler{Client: c, log: logr.Discard(), recorder: record.NewFakeRecorder(10)}
+
+		_, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: client.ObjectKeyFromObject(pipeline)})
+		assert.Nil(t, err)
+		list := &v1alpha3.PipelineRunList{}
+		assert.Nil(t, c.List(context.Background(), list))
+		assert.Len(t, list.Items, 3)
+	})
+
+	t.Run("an invalid keep-record annotation is not treated as keeping", func(t *testing.T) {
+		invalid := newCompletedPipelineRun("invalid", now.Add(-time.Hour), v1alpha3.Succeeded, "")
+		invalid.Annotations = map[string]string{
+			v1alpha3.PipelineRunKeepJenkinsRecordAnnoKey: "invalid",
+			v1alpha3.JenkinsPipelineRunIDAnnoKey:         "2",
+		}
+		c := fake.NewClientBuilder().WithScheme(schema).WithObjects(pipeline.DeepCopy()).
+			WithObjects(invalid, newCompletedPipelineRun("new", now, v1alpha3.Succeeded, "")).Build()
+		recorder := record.NewFakeRecorder(10)
+		r := &RetentionReconciler{Client: c, log: logr.Discard(), recorder: recorder}
+
+		_, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: client.ObjectKeyFromObject(pipeline)})
+		assert.Nil(t, err)
+		if assert.Len(t, recorder.Events, 1) {
+			assert.NotContains(t, <-recorder.Events, "kept its Jenkins record")
+		}
+		latest := &v1alpha3.Pipeline{}
+		assert.Nil(t, c.Get(context.Background(), client.ObjectKeyFromObject(pipeline), latest))
+		assert.Empty(t, getPrunedJenkinsRuns(latest))
+	})
+
+	t.Run("keep the Jenkins records", func(t *testing.T) {
+		withPolicy := pipeline.DeepCopy()
+		withPolicy.Spec.RetentionPolicy = &v1alpha3.RetentionPolicy{MaxAge: &v1.Duration{Duration: time.Minute}, KeepJenkinsRecords: true}
+		// keep the PipelineRun in the fake client, the annotation could be checked after the deletion
+		old := newCompletedPipelineRun("old", now.Add(-time.Hour), v1alpha3.Succeeded, "")
+		old.Annotations = map[string]string{v1alpha3.JenkinsPipelineRunIDAnnoKey: "3"}
+		old.Finalizers = []string{v1alpha3.PipelineRunFinalizerName}
+		c := fake.NewClientBuilder().WithScheme(schema).WithObjects(withPolicy, old).Build()
+		r := &RetentionReconciler{Client: c, log: logr.Discard(), recorder: record.NewFakeRecorder(10)}
+
+		result, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: client.ObjectKeyFromObject(pipeline)})
+		assert.Nil(t, err)
+		assert.Equal(t, retentionInterval, result.RequeueAfter)
+
+		pr := &v1alpha3.PipelineRun{}
+		assert.Nil(t, c.Get(context.Background(), client.ObjectKeyFromObject(old), pr))
+		assert.False(t, pr.DeletionTimestamp.IsZero())
+		assert.Equal(t, "true", pr.Annotations[v1alpha3.PipelineRunKeepJenkinsRecordAnnoKey])
+
+		latest := &v1alpha3.Pipeline{}
+		assert.Nil(t, c.Get(context.Background(), client.ObjectKeyFromObject(pipeline), latest))
+		assert.Equal(t, map[string]int{"": 3}, getPrunedJenkinsRuns(latest))
+
+		// the pruned run number is never lowered
+		lower := newCompletedPipelineRun("lower", now.Add(-time.Hour), v1alpha3.Succeeded, "")
+		lower.Annotations = map[string]string{v1alpha3.JenkinsPipelineRunIDAnnoKey: "2"}
+		assert.Nil(t, c.Create(context.Background(), lower))
+		_, err = r.Reconcile(context.Background(), ctrl.Request{NamespacedName: client.ObjectKeyFromObject(pipeline)})
+		assert.Nil(t, err)
+		assert.Nil(t, c.Get(context.Background(), client.ObjectKeyFromObject(pipeline), latest))
+		assert.Equal(t, map[string]int{"": 3}, getPrunedJenkinsRuns(latest))
+	})
+
+	t.Run("no policy", func(t *testing.T) {
+		noPolicy := pipeline.DeepCopy()
+		noPolicy.Spec.Pipeline.Discarder = nil
+		c := fake.NewClientBuilder().WithScheme(schema).WithObjects(noPolicy).WithObjects(objects...).Build()
+		r := &RetentionReconciler{Client: c, log: logr.Discard(), recorder: record.NewFakeRecorder(10)}
+
+		_, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: client.ObjectKeyFromObject(pipeline)})
+		assert.Nil(t, err)
+		list := &v1alpha3.PipelineRunList{}
+		assert.Nil(t, c.List(context.Background(), list))
+		assert.Len(t, list.Items, 3)
+	})
+}
+
+func Test_pipelineRunCompletedPredicate(t *testing.T) {
+	running := newCompletedPipelineRun("run", time.Now(), v1alpha3.Running, "")
+	running.Status.CompletionTime = nil
+	completed := newCompletedPipelineRun("run", time.Now(), v1alpha3.Succeeded, "")
+
+	p := pipelineRunCompletedPredicate()
+	assert.True(t, p.Update(event.UpdateEvent{ObjectOld: running, ObjectNew: completed}))
+	assert.False(t, p.Update(event.UpdateEvent{ObjectOld: completed, ObjectNew: completed}))
+	assert.False(t, p.Update(event.UpdateEvent{ObjectOld: running, ObjectNew: running}))
+	assert.False(t, p.Create(event.CreateEvent{Object: completed}))
+
+	assert.Equal(t, "pipeline", mapPipelineRunToPipeline(context.Background(), completed)[0].Name)
+	assert.Empty(t, mapPipelineRunToPipeline(context.Background(), &v1alpha3.PipelineRun{}))
+}
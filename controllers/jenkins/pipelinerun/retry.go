@@ -0,0 +1,137 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinerun
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jenkins-zh/jenkins-client/pkg/job"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/kubesphere/ks-devops/pkg/api/devops/v1alpha3"
+	"github.com/kubesphere/ks-devops/pkg/models/pipelinerun"
+	"github.com/kubesphere/ks-devops/pkg/utils/sliceutil"
+)
+
+// maxBackoffExponent limits the growth of the retry backoff
+const maxBackoffExponent = 10
+
+// retryPipelineRun creates a new PipelineRun to retry the failed one if the retry policy matches the failure.
+// The name of the new PipelineRun is decided by the attempt, so it is safe to call it more than once.
+func (r *Reconciler) retryPipelineRun(ctx context.Context, pipeline *v1alpha3.Pipeline, pr *v1alpha3.PipelineRun,
+	pipelineBuild *job.PipelineRun, nodeDetails []pipelinerun.NodeDetail) (err error) {
+	policy := getRetryPolicy(pipeline, pr)
+	if policy == nil || pipelineBuild == nil || pr.GetAttempt() >= policy.MaxAttempts ||
+		!retryMatches(policy, pipelineBuild.Result, nodeDetails) {
+		return
+	}
+
+	attempt := pr.GetAttempt() + 1
+	retryAfter := time.Now().Add(retryBackoff(policy, attempt))
+	if pr.Status.CompletionTime != nil {
+		retryAfter = pr.Status.CompletionTime.Add(retryBackoff(policy, attempt))
+	}
+	retryRun := newRetryPipelineRun(pr, attempt, retryAfter)
+	if err = r.Create(ctx, retryRun); err != nil {
+		if apierrors.IsAlreadyExists(err) {
+			err = nil
+		}
+		return
+	}
+	r.recorder.Eventf(pr, corev1.EventTypeNormal, v1alpha3.Retrying, "PipelineRun %s/%s will be retried by PipelineRun %s, attempt %d of %d",
+		pr.Namespace, pr.Name, retryRun.Name, attempt, policy.MaxAttempts)
+	return
+}
+
+// getRetryPolicy returns the retry policy of the PipelineRun, it falls back to the one of the Pipeline
+func getRetryPolicy(pipeline *v1alpha3.Pipeline, pr *v1alpha3.PipelineRun) *v1alpha3.RetryPolicy {
+	if pr.Spec.RetryPolicy != nil {
+		return pr.Spec.RetryPolicy
+	}
+	return pipeline.Spec.RetryPolicy
+}
+
+// retryMatches checks if the failure matches the reasons and stages of the retry policy
+func retryMatches(policy *v1alpha3.RetryPolicy, result string, nodeDetails []pipelinerun.NodeDetail) bool {
+	if len(policy.Reasons) > 0 && !sliceutil.HasString(policy.Reasons, result) {
+		return false
+	}
+	if len(policy.Stages) == 0 {
+		return true
+	}
+	for _, node := range nodeDetails {
+		if node.Result != "" && node.Result != Success.String() && sliceutil.HasString(policy.Stages, node.DisplayName) {
+			return true
+		}
+	}
+	return false
+}
+
+// retryBackoff returns the delay before the given attempt, it is doubled for each retry
+func retryBackoff(policy *v1alpha3.RetryPolicy, attempt int) time.Duration {
+	if policy.Backoff == nil || attempt < 2 {
+		return 0
+	}
+	exponent := attempt - 2
+	if exponent > maxBackoffExponent {
+		exponent = maxBackoffExponent
+	}
+	return policy.Backoff.Duration << exponent
+}
+
+// retryWaitTime returns how long a retry should wait before it runs
+func retryWaitTime(pr *v1alpha3.PipelineRun) time.Duration {
+	retryAfter, err := time.Parse(time.RFC3339, pr.Annotations[v1alpha3.PipelineRunRetryAfterAnnoKey])
+	if err != nil {
+		return 0
+	}
+	return time.Until(retryAfter)
+}
+
+// newRetryPipelineRun creates a PipelineRun which retries the given one. All the attempts are named after the first one.
+func newRetryPipelineRun(pr *v1alpha3.PipelineRun, attempt int, retryAfter time.Time) *v1alpha3.PipelineRun {
+	firstName := strings.TrimSuffix(pr.Name, fmt.Sprintf("-retry-%d", pr.GetAttempt()))
+	retryRun := &v1alpha3.PipelineRun{
+		ObjectMeta: v1.ObjectMeta{
+			Namespace:       pr.Namespace,
+			Name:            fmt.Sprintf("%s-retry-%d", firstName, attempt),
+			OwnerReferences: pr.OwnerReferences,
+			Annotations: map[string]string{
+				v1alpha3.PipelineRunAttemptAnnoKey:     strconv.Itoa(attempt),
+				v1alpha3.PipelineRunRetryParentAnnoKey: pr.Name,
+				v1alpha3.PipelineRunRetryAfterAnnoKey:  retryAfter.UTC().Format(time.RFC3339),
+			},
+			Labels: map[string]string{},
+		},
+		Spec: *pr.Spec.DeepCopy(),
+	}
+	retryRun.Spec.Action = nil
+	// a retry of a matrix child stands for the same combination
+	if index, ok := pr.Annotations[v1alpha3.PipelineRunMatrixIndexAnnoKey]; ok {
+		retryRun.Annotations[v1alpha3.PipelineRunMatrixIndexAnnoKey] = index
+	}
+	for key, value := range pr.Labels {
+		retryRun.Labels[key] = value
+	}
+	return retryRun
+}
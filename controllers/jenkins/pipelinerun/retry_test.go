@@ -0,0 +1,187 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinerun
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jenkins-zh/jenkins-client/pkg/job"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/kubesphere/ks-devops/pkg/api/devops/v1alpha3"
+	"github.com/kubesphere/ks-devops/pkg/models/pipelinerun"
+)
+
+func TestReconciler_retryPipelineRun(t *testing.T) {
+	schema, err := v1alpha3.SchemeBuilder.Register().Build()
+	assert.Nil(t, err)
+	err = corev1.SchemeBuilder.AddToScheme(schema)
+	assert.Nil(t, err)
+
+	completionTime := v1.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	stop := v1alpha3.Stop
+	newPipelineRun := func(name string, attempt string) *v1alpha3.PipelineRun {
+		pr := &v1alpha3.PipelineRun{
+			ObjectMeta: v1.ObjectMeta{
+				Namespace: "ns",
+				Name:      name,
+				Labels:    map[string]string{v1alpha3.PipelineNameLabelKey: "pipeline"},
+				Annotations: map[string]string{
+					v1alpha3.JenkinsPipelineRunIDAnnoKey: "1",
+				},
+			},
+			Spec: v1alpha3.PipelineRunSpec{
+				PipelineRef: &corev1.ObjectReference{Name: "pipeline"},
+				Action:      &stop,
+			},
+			Status: v1alpha3.PipelineRunStatus{Phase: v1alpha3.Failed, CompletionTime: &completionTime},
+		}
+		if attempt != "" {
+			pr.Annotations[v1alpha3.PipelineRunAttemptAnnoKey] = attempt
+		}
+		return pr
+	}
+	newPipeline := func(policy *v1alpha3.RetryPolicy) *v1alpha3.Pipeline {
+		return &v1alpha3.Pipeline{
+			ObjectMeta: v1.ObjectMeta{Namespace: "ns", Name: "pipeline"},
+			Spec:       v1alpha3.PipelineSpec{RetryPolicy: policy},
+		}
+	}
+	nodeDetails := []pipelinerun.NodeDetail{{
+		Node: job.Node{DisplayName: "build", Result: "SUCCESS"},
+	}, {
+		Node: job.Node{DisplayName: "deploy", Result: "FAILURE"},
+	}}
+
+	tests := []struct {
+		name          string
+		pipeline      *v1alpha3.Pipeline
+		pr            *v1alpha3.PipelineRun
+		result        string
+		wantRetryName string
+		verify        func(t *testing.T, retryRun *v1alpha3.PipelineRun)
+	}{{
+		name:     "no retry policy",
+		pipeline: newPipeline(nil),
+		pr:       newPipelineRun("run", ""),
+		result:   "FAILURE",
+	}, {
+		name:          "the first retry",
+		pipeline:      newPipeline(&v1alpha3.RetryPolicy{MaxAttempts: 3, Backoff: &v1.Duration{Duration: time.Minute}}),
+		pr:            newPipelineRun("run", ""),
+		result:        "FAILURE",
+		wantRetryName: "run-retry-2",
+		verify: func(t *testing.T, retryRun *v1alpha3.PipelineRun) {
+			assert.Equal(t, 2, retryRun.GetAttempt())
+			assert.Equal(t, "run", retryRun.Annotations[v1alpha3.PipelineRunRetryParentAnnoKey])
+			assert.Equal(t, "2022-01-01T00:01:00Z", retryRun.Annotations[v1alpha3.PipelineRunRetryAfterAnnoKey])
+			assert.Equal(t, "pipeline", retryRun.Labels[v1alpha3.PipelineNameLabelKey])
+			assert.False(t, retryRun.HasStarted())
+			assert.Nil(t, retryRun.Spec.Action)
+		},
+	}, {
+		name:          "the backoff is doubled",
+		pipeline:      newPipeline(&v1alpha3.RetryPolicy{MaxAttempts: 3, Backoff: &v1.Duration{Duration: time.Minute}}),
+		pr:            newPipelineRun("run-retry-2", "2"),
+		result:        "FAILURE",
+		wantRetryName: "run-retry-3",
+		verify: func(t *testing.T, retryRun *v1alpha3.PipelineRun) {
+			assert.Equal(t, 3, retryRun.GetAttempt())
+			assert.Equal(t, "run-retry-2", retryRun.Annotations[v1alpha3.PipelineRunRetryParentAnnoKey])
+			assert.Equal(t, "2022-01-01T00:02:00Z", retryRun.Annotations[v1alpha3.PipelineRunRetryAfterAnnoKey])
+		},
+	}, {
+		name:     "reach the max attempts",
+		pipeline: newPipeline(&v1alpha3.RetryPolicy{MaxAttempts: 3}),
+		pr:       newPipelineRun("run-retry-3", "3"),
+		result:   "FAILURE",
+	}, {
+		name:     "the reason does not match",
+		pipeline: newPipeline(&v1alpha3.RetryPolicy{MaxAttempts: 3, Reasons: []string{"FAILURE"}}),
+		pr:       newPipelineRun("run", ""),
+		result:   "ABORTED",
+	}, {
+		name:          "the failed stage matches",
+		pipeline:      newPipeline(&v1alpha3.RetryPolicy{MaxAttempts: 3, Stages: []string{"deploy"}}),
+		pr:            newPipelineRun("run", ""),
+		result:        "FAILURE",
+		wantRetryName: "run-retry-2",
+	}, {
+		name:     "the stage did not fail",
+		pipeline: newPipeline(&v1alpha3.RetryPolicy{MaxAttempts: 3, Stages: []string{"build"}}),
+		pr:       newPipelineRun("run", ""),
+		result:   "FAILURE",
+	}, {
+		name:     "the policy of PipelineRun takes precedence",
+		pipeline: newPipeline(&v1alpha3.RetryPolicy{MaxAttempts: 3}),
+		pr: func() *v1alpha3.PipelineRun {
+			pr := newPipelineRun("run", "")
+			pr.Spec.RetryPolicy = &v1alpha3.RetryPolicy{MaxAttempts: 1}
+			return pr
+		}(),
+		result: "FAILURE",
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := fake.NewClientBuilder().WithScheme(schema).WithObjects(tt.pr.DeepCopy()).Build()
+			r := &Reconciler{Client: c, recorder: &record.FakeRecorder{}}
+
+			err := r.retryPipelineRun(context.Background(), tt.pipeline, tt.pr, &job.PipelineRun{
+				BlueItemRun: job.BlueItemRun{Result: tt.result},
+			}, nodeDetails)
+			assert.Nil(t, err)
+			// it is safe to retry again
+			err = r.retryPipelineRun(context.Background(), tt.pipeline, tt.pr, &job.PipelineRun{
+				BlueItemRun: job.BlueItemRun{Result: tt.result},
+			}, nodeDetails)
+			assert.Nil(t, err)
+
+			list := &v1alpha3.PipelineRunList{}
+			assert.Nil(t, c.List(context.Background(), list, client.InNamespace("ns")))
+			if tt.wantRetryName == "" {
+				assert.Len(t, list.Items, 1)
+				return
+			}
+			assert.Len(t, list.Items, 2)
+			retryRun := &v1alpha3.PipelineRun{}
+			assert.Nil(t, c.Get(context.Background(), client.ObjectKey{Namespace: "ns", Name: tt.wantRetryName}, retryRun))
+			if tt.verify != nil {
+				tt.verify(t, retryRun)
+			}
+		})
+	}
+}
+
+func Test_retryWaitTime(t *testing.T) {
+	pr := &v1alpha3.PipelineRun{}
+	assert.Equal(t, time.Duration(0), retryWaitTime(pr))
+
+	pr.Annotations = map[string]string{
+		v1alpha3.PipelineRunRetryAfterAnnoKey: time.Now().Add(time.Hour).UTC().Format(time.RFC3339),
+	}
+	assert.True(t, retryWaitTime(pr) > 59*time.Minute)
+
+	pr.Annotations[v1alpha3.PipelineRunRetryAfterAnnoKey] = time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+	assert.True(t, retryWaitTime(pr) < 0)
+}
@@ -0,0 +1,76 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinerun
+
+import (
+	"context"
+
+	"github.com/go-logr/logr"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	"github.com/kubesphere/ks-devops/pkg/api/devops/v1alpha3"
+	pipelinemodel "github.com/kubesphere/ks-devops/pkg/models/pipeline"
+)
+
+//+kubebuilder:rbac:groups=devops.kubesphere.io,resources=pipelinerevisions,verbs=get;list;watch;create
+
+// RevisionReconciler records every spec change of a Pipeline as a PipelineRevision
+type RevisionReconciler struct {
+	client.Client
+	log      logr.Logger
+	recorder record.EventRecorder
+}
+
+// Reconcile creates a PipelineRevision if the spec of the Pipeline differs from its latest revision
+func (r *RevisionReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	pipeline := &v1alpha3.Pipeline{}
+	if err := r.Get(ctx, req.NamespacedName, pipeline); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if !pipeline.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, nil
+	}
+
+	revision, created, err := pipelinemodel.EnsureRevision(ctx, r.Client, pipeline)
+	if err != nil {
+		r.recorder.Eventf(pipeline, v1.EventTypeWarning, v1alpha3.RevisionFailed,
+			"Failed to record the revision of the spec, and error was %v", err)
+		return ctrl.Result{}, err
+	}
+	if created {
+		r.log.V(4).Info("created a revision", "Pipeline", req.NamespacedName, "PipelineRevision", revision.Name)
+		r.recorder.Eventf(pipeline, v1.EventTypeNormal, v1alpha3.RevisionCreated,
+			"Created PipelineRevision %s", revision.Name)
+	}
+	return ctrl.Result{}, nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *RevisionReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	r.recorder = mgr.GetEventRecorderFor("pipeline-revision")
+	r.log = ctrl.Log.WithName("pipeline-revision")
+
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("jenkins_pipeline_revision").
+		For(&v1alpha3.Pipeline{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Complete(r)
+}
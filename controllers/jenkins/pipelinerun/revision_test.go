@@ -0,0 +1,74 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinerun
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/kubesphere/ks-devops/pkg/api/devops/v1alpha3"
+)
+
+func TestRevisionReconciler(t *testing.T) {
+	schema, err := v1alpha3.SchemeBuilder.Register().Build()
+	assert.Nil(t, err)
+
+	pipeline := &v1alpha3.Pipeline{
+		ObjectMeta: v1.ObjectMeta{Namespace: "ns", Name: "demo"},
+		Spec: v1alpha3.PipelineSpec{
+			Type:     v1alpha3.NoScmPipelineType,
+			Pipeline: &v1alpha3.NoScmPipeline{Name: "demo", Jenkinsfile: "pipeline {}"},
+		},
+	}
+	c := fake.NewClientBuilder().WithScheme(schema).WithObjects(pipeline).Build()
+	recorder := record.NewFakeRecorder(10)
+	r := &RevisionReconciler{Client: c, log: logr.Discard(), recorder: recorder}
+	req := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(pipeline)}
+
+	_, err = r.Reconcile(context.Background(), req)
+	assert.Nil(t, err)
+	assert.Equal(t, "Normal RevisionCreated Created PipelineRevision demo-1", <-recorder.Events)
+
+	// nothing changed
+	_, err = r.Reconcile(context.Background(), req)
+	assert.Nil(t, err)
+	assert.Empty(t, recorder.Events)
+
+	assert.Nil(t, c.Get(context.Background(), req.NamespacedName, pipeline))
+	pipeline.Spec.Pipeline.Jenkinsfile = "pipeline { }"
+	assert.Nil(t, c.Update(context.Background(), pipeline))
+	_, err = r.Reconcile(context.Background(), req)
+	assert.Nil(t, err)
+	assert.Equal(t, "Normal RevisionCreated Created PipelineRevision demo-2", <-recorder.Events)
+
+	revisions := &v1alpha3.PipelineRevisionList{}
+	assert.Nil(t, c.List(context.Background(), revisions))
+	assert.Len(t, revisions.Items, 2)
+
+	// the Pipeline was deleted
+	assert.Nil(t, c.Delete(context.Background(), pipeline))
+	_, err = r.Reconcile(context.Background(), req)
+	assert.Nil(t, err)
+}
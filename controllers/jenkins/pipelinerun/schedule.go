@@ -0,0 +1,216 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinerun
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	"github.com/kubesphere/ks-devops/pkg/api/devops/v1alpha3"
+	"github.com/kubesphere/ks-devops/pkg/kapis/devops/v1alpha3/pipelinerun"
+	"github.com/kubesphere/ks-devops/pkg/utils/cronutil"
+)
+
+const (
+	// onTimeTolerance is how late a scheduled time is still treated as on time if there is no starting deadline
+	onTimeTolerance = time.Minute
+	// maxMissedRuns limits the PipelineRuns which are created for the missed times at once
+	maxMissedRuns = 100
+)
+
+// ScheduleReconciler creates the PipelineRuns of a Pipeline according to its schedule. The schedule is handled in
+// Kubernetes instead of Jenkins, so it supports time zones and follows the concurrency policy of the Pipeline.
+type ScheduleReconciler struct {
+	client.Client
+	log      logr.Logger
+	recorder record.EventRecorder
+	clock    func() time.Time
+}
+
+// Reconcile creates the scheduled PipelineRuns of a Pipeline, then waits for the next scheduled time
+func (r *ScheduleReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := r.log.WithValues("Pipeline", req.NamespacedName)
+	pipeline := &v1alpha3.Pipeline{}
+	if err := r.Get(ctx, req.NamespacedName, pipeline); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if !pipeline.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, nil
+	}
+
+	status := pipeline.Status.DeepCopy()
+	schedule := pipeline.Spec.GetSchedule()
+	if schedule == nil || schedule.Suspend {
+		status.NextScheduleTime = nil
+		return ctrl.Result{}, r.updateStatus(ctx, pipeline, status)
+	}
+
+	parsed, err := cronutil.Parse(schedule.Cron, schedule.TimeZone, req.String())
+	if err == nil && pipeline.Spec.MultiBranchPipeline != nil && schedule.SCM == nil {
+		err = fmt.Errorf("the SCM reference is required by a multi-branch Pipeline")
+	}
+	if err != nil {
+		// there is nothing to do until the schedule is changed
+		r.recorder.Eventf(pipeline, v1.EventTypeWarning, v1alpha3.ScheduleFailed, "Invalid schedule: %v", err)
+		status.NextScheduleTime = nil
+		return ctrl.Result{}, r.updateStatus(ctx, pipeline, status)
+	}
+
+	now := r.now()
+	scheduledTimes := getScheduledTimes(parsed, schedule, pipeline, now)
+	for _, scheduledTime := range selectScheduledTimes(schedule, scheduledTimes, now) {
+		pr := newScheduledPipelineRun(pipeline, schedule, scheduledTime)
+		if err = r.Create(ctx, pr); err != nil && !apierrors.IsAlreadyExists(err) {
+			r.recorder.Eventf(pipeline, v1.EventTypeWarning, v1alpha3.ScheduleFailed,
+				"Failed to create PipelineRun %s scheduled at %s, and error was %v", pr.Name, scheduledTime.Format(time.RFC3339), err)
+			return ctrl.Result{}, err
+		} else if err == nil {
+			log.V(4).Info("created a scheduled PipelineRun", "PipelineRun", pr.Name)
+			r.recorder.Eventf(pipeline, v1.EventTypeNormal, v1alpha3.Scheduled,
+				"Created PipelineRun %s scheduled at %s", pr.Name, scheduledTime.Format(time.RFC3339))
+		}
+	}
+
+	// the skipped times are recorded as well, so they are not missed again
+	if len(scheduledTimes) > 0 {
+		status.LastScheduleTime = &metav1.Time{Time: scheduledTimes[len(scheduledTimes)-1]}
+	}
+	next := parsed.Next(now)
+	if next.IsZero() {
+		status.NextScheduleTime = nil
+	} else {
+		status.NextScheduleTime = &metav1.Time{Time: next}
+	}
+	if err = r.updateStatus(ctx, pipeline, status); err != nil || next.IsZero() {
+		return ctrl.Result{}, err
+	}
+	// the cron has a resolution of minute, so a second is enough for the clock drift
+	return ctrl.Result{RequeueAfter: next.Sub(now) + time.Second}, nil
+}
+
+func (r *ScheduleReconciler) now() time.Time {
+	if r.clock != nil {
+		return r.clock()
+	}
+	return time.Now()
+}
+
+// updateStatus updates the status of the Pipeline if it changed
+func (r *ScheduleReconciler) updateStatus(ctx context.Context, pipeline *v1alpha3.Pipeline, status *v1alpha3.PipelineStatus) error {
+	if timeEqual(pipeline.Status.LastScheduleTime, status.LastScheduleTime) &&
+		timeEqual(pipeline.Status.NextScheduleTime, status.NextScheduleTime) {
+		return nil
+	}
+	pipeline = pipeline.DeepCopy()
+	pipeline.Status = *status
+	return r.Status().Update(ctx, pipeline)
+}
+
+func timeEqual(a, b *metav1.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Unix() == b.Unix()
+}
+
+// getScheduledTimes returns the scheduled times since the last scheduled time. The scheduler starts counting the missed
+// times since the first time it sees the schedule, so the times before it are not treated as missed.
+func getScheduledTimes(parsed cronutil.Schedule, schedule *v1alpha3.Schedule, pipeline *v1alpha3.Pipeline, now time.Time) (
+	scheduledTimes []time.Time) {
+	earliest := now.Add(-onTimeTolerance)
+	if pipeline.Status.LastScheduleTime != nil {
+		earliest = pipeline.Status.LastScheduleTime.Time
+	} else if pipeline.CreationTimestamp.After(earliest) {
+		earliest = pipeline.CreationTimestamp.Time
+	}
+	if schedule.StartingDeadlineSeconds != nil {
+		// the times before the deadline are skipped, there is no need to count them
+		if start := now.Add(-time.Duration(*schedule.StartingDeadlineSeconds) * time.Second); start.After(earliest) {
+			earliest = start
+		}
+	}
+
+	for t := parsed.Next(earliest); !t.IsZero() && !t.After(now); t = parsed.Next(t) {
+		scheduledTimes = append(scheduledTimes, t)
+		if len(scheduledTimes) > maxMissedRuns {
+			scheduledTimes = scheduledTimes[1:]
+		}
+	}
+	return
+}
+
+// selectScheduledTimes returns the scheduled times which should create PipelineRuns according to the starting
+// deadline and the missed run policy. Only the latest time could be on time.
+func selectScheduledTimes(schedule *v1alpha3.Schedule, scheduledTimes []time.Time, now time.Time) (selected []time.Time) {
+	for _, t := range scheduledTimes {
+		if schedule.StartingDeadlineSeconds == nil || now.Sub(t) <= time.Duration(*schedule.StartingDeadlineSeconds)*time.Second {
+			selected = append(selected, t)
+		}
+	}
+	if len(selected) == 0 {
+		return
+	}
+
+	latest := selected[len(selected)-1]
+	switch schedule.MissedRunPolicy {
+	case v1alpha3.MissedRunAll:
+		return
+	case v1alpha3.MissedRunSkip:
+		tolerance := onTimeTolerance
+		if schedule.StartingDeadlineSeconds != nil {
+			tolerance = time.Duration(*schedule.StartingDeadlineSeconds) * time.Second
+		}
+		if now.Sub(latest) > tolerance {
+			return nil
+		}
+	}
+	return []time.Time{latest}
+}
+
+// newScheduledPipelineRun creates a PipelineRun for a scheduled time, the name is decided by the time, so a time is
+// never scheduled twice
+func newScheduledPipelineRun(pipeline *v1alpha3.Pipeline, schedule *v1alpha3.Schedule, scheduledTime time.Time) *v1alpha3.PipelineRun {
+	pipeline = pipeline.DeepCopy()
+	pipeline.SetGroupVersionKind(v1alpha3.GroupVersion.WithKind(v1alpha3.ResourceKindPipeline))
+	pr := pipelinerun.CreateBarePipelineRun(pipeline, schedule.Parameters, schedule.SCM.DeepCopy())
+	pr.GenerateName = ""
+	pr.Name = fmt.Sprintf("%s-cron-%d", pipeline.Name, scheduledTime.Unix())
+	pr.Annotations[v1alpha3.PipelineRunScheduledTimeAnnoKey] = scheduledTime.UTC().Format(time.RFC3339)
+	return pr
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *ScheduleReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	r.recorder = mgr.GetEventRecorderFor("pipeline-schedule")
+	r.log = ctrl.Log.WithName("pipeline-schedule")
+
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("jenkins_pipeline_schedule").
+		For(&v1alpha3.Pipeline{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Complete(r)
+}
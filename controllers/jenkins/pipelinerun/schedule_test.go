@@ -0,0 +1,219 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinerun
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/kubesphere/ks-devops/pkg/api/devops/v1alpha3"
+)
+
+func Test_selectScheduledTimes(t *testing.T) {
+	now := time.Date(2022, 1, 1, 10, 0, 30, 0, time.UTC)
+	scheduledTimes := []time.Time{now.Add(-2*time.Hour - 30*time.Second), now.Add(-time.Hour - 30*time.Second), now.Add(-30 * time.Second)}
+	missedTimes := scheduledTimes[:2]
+	deadline := int64(3600)
+
+	tests := []struct {
+		name           string
+		schedule       *v1alpha3.Schedule
+		scheduledTimes []time.Time
+		want           []time.Time
+	}{{
+		name:           "on time",
+		schedule:       &v1alpha3.Schedule{MissedRunPolicy: v1alpha3.MissedRunSkip},
+		scheduledTimes: scheduledTimes,
+		want:           scheduledTimes[2:],
+	}, {
+		name:           "skip the missed times",
+		schedule:       &v1alpha3.Schedule{MissedRunPolicy: v1alpha3.MissedRunSkip},
+		scheduledTimes: missedTimes,
+	}, {
+		name:           "run once by default",
+		schedule:       &v1alpha3.Schedule{},
+		scheduledTimes: missedTimes,
+		want:           missedTimes[1:],
+	}, {
+		name:           "run all",
+		schedule:       &v1alpha3.Schedule{MissedRunPolicy: v1alpha3.MissedRunAll},
+		scheduledTimes: scheduledTimes,
+		want:           scheduledTimes,
+	}, {
+		name:           "run all within the deadline",
+		schedule:       &v1alpha3.Schedule{MissedRunPolicy: v1alpha3.MissedRunAll, StartingDeadlineSeconds: &deadline},
+		scheduledTimes: scheduledTimes,
+		want:           scheduledTimes[2:],
+	}, {
+		name:           "the deadline is the tolerance of skipping",
+		schedule:       &v1alpha3.Schedule{MissedRunPolicy: v1alpha3.MissedRunSkip, StartingDeadlineSeconds: &deadline},
+		scheduledTimes: missedTimes,
+	}, {
+		name:           "nothing is in the deadline",
+		schedule:       &v1alpha3.Schedule{StartingDeadlineSeconds: &deadline},
+		scheduledTimes: missedTimes,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, selectScheduledTimes(tt.schedule, tt.scheduledTimes, now))
+		})
+	}
+}
+
+func TestScheduleReconciler_Reconcile(t *testing.T) {
+	schema, err := v1alpha3.SchemeBuilder.Register().Build()
+	assert.Nil(t, err)
+
+	now := time.Date(2022, 1, 1, 10, 0, 20, 0, time.UTC)
+	newPipeline := func(schedule *v1alpha3.Schedule, lastScheduleTime *v1.Time) *v1alpha3.Pipeline {
+		return &v1alpha3.Pipeline{
+			ObjectMeta: v1.ObjectMeta{
+				Namespace:         "ns",
+				Name:              "pipeline",
+				CreationTimestamp: v1.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC),
+			},
+			Spec:   v1alpha3.PipelineSpec{Type: v1alpha3.NoScmPipelineType, Pipeline: &v1alpha3.NoScmPipeline{}, Schedule: schedule},
+			Status: v1alpha3.PipelineStatus{LastScheduleTime: lastScheduleTime},
+		}
+	}
+	lastScheduleTime := v1.Date(2022, 1, 1, 7, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name              string
+		pipeline          *v1alpha3.Pipeline
+		wantRuns          []string
+		wantRequeue       time.Duration
+		wantLast          *v1.Time
+		wantNext          *v1.Time
+		wantEvent         string
+		verifyPipelineRun func(t *testing.T, pr *v1alpha3.PipelineRun)
+	}{{
+		name:        "on time",
+		pipeline:    newPipeline(&v1alpha3.Schedule{Cron: "0 * * * *", Parameters: []v1alpha3.Parameter{{Name: "a", Value: "b"}}}, nil),
+		wantRuns:    []string{"pipeline-cron-1641031200"},
+		wantRequeue: time.Hour - 20*time.Second + time.Second,
+		wantLast:    &v1.Time{Time: time.Date(2022, 1, 1, 10, 0, 0, 0, time.UTC)},
+		wantNext:    &v1.Time{Time: time.Date(2022, 1, 1, 11, 0, 0, 0, time.UTC)},
+		wantEvent:   "Created PipelineRun pipeline-cron-1641031200 scheduled at 2022-01-01T10:00:00Z",
+		verifyPipelineRun: func(t *testing.T, pr *v1alpha3.PipelineRun) {
+			assert.Equal(t, "2022-01-01T10:00:00Z", pr.Annotations[v1alpha3.PipelineRunScheduledTimeAnnoKey])
+			assert.Equal(t, "pipeline", pr.Labels[v1alpha3.PipelineNameLabelKey])
+			assert.Equal(t, []v1alpha3.Parameter{{Name: "a", Value: "b"}}, pr.Spec.Parameters)
+			if assert.Len(t, pr.OwnerReferences, 1) {
+				assert.Equal(t, v1alpha3.ResourceKindPipeline, pr.OwnerReferences[0].Kind)
+			}
+		},
+	}, {
+		name:        "with time zone",
+		pipeline:    newPipeline(&v1alpha3.Schedule{Cron: "0 18 * * *", TimeZone: "Asia/Shanghai"}, nil),
+		wantRuns:    []string{"pipeline-cron-1641031200"},
+		wantRequeue: 24*time.Hour - 20*time.Second + time.Second,
+		wantLast:    &v1.Time{Time: time.Date(2022, 1, 1, 10, 0, 0, 0, time.UTC)},
+		wantNext:    &v1.Time{Time: time.Date(2022, 1, 2, 10, 0, 0, 0, time.UTC)},
+	}, {
+		name: "fall back to the timer trigger",
+		pipeline: func() *v1alpha3.Pipeline {
+			pipeline := newPipeline(nil, nil)
+			pipeline.Spec.Pipeline.TimerTrigger = &v1alpha3.TimerTrigger{Cron: "0 * * * *"}
+			return pipeline
+		}(),
+		wantRuns:    []string{"pipeline-cron-1641031200"},
+		wantRequeue: time.Hour - 20*time.Second + time.Second,
+		wantLast:    &v1.Time{Time: time.Date(2022, 1, 1, 10, 0, 0, 0, time.UTC)},
+		wantNext:    &v1.Time{Time: time.Date(2022, 1, 1, 11, 0, 0, 0, time.UTC)},
+	}, {
+		name:        "the times before the first reconcile are not missed",
+		pipeline:    newPipeline(&v1alpha3.Schedule{Cron: "0 9 * * *", MissedRunPolicy: v1alpha3.MissedRunAll}, nil),
+		wantRequeue: 23*time.Hour - 20*time.Second + time.Second,
+		wantNext:    &v1.Time{Time: time.Date(2022, 1, 2, 9, 0, 0, 0, time.UTC)},
+	}, {
+		name:        "catch up the missed times",
+		pipeline:    newPipeline(&v1alpha3.Schedule{Cron: "0 * * * *", MissedRunPolicy: v1alpha3.MissedRunAll}, &lastScheduleTime),
+		wantRuns:    []string{"pipeline-cron-1641024000", "pipeline-cron-1641027600", "pipeline-cron-1641031200"},
+		wantLast:    &v1.Time{Time: time.Date(2022, 1, 1, 10, 0, 0, 0, time.UTC)},
+		wantNext:    &v1.Time{Time: time.Date(2022, 1, 1, 11, 0, 0, 0, time.UTC)},
+		wantRequeue: time.Hour - 20*time.Second + time.Second,
+	}, {
+		name:     "suspended",
+		pipeline: newPipeline(&v1alpha3.Schedule{Cron: "0 * * * *", Suspend: true}, &lastScheduleTime),
+		wantLast: &lastScheduleTime,
+	}, {
+		name:      "invalid cron",
+		pipeline:  newPipeline(&v1alpha3.Schedule{Cron: "0 25 * * *"}, nil),
+		wantEvent: "Invalid schedule",
+	}, {
+		name: "the SCM reference is required by a multi-branch Pipeline",
+		pipeline: func() *v1alpha3.Pipeline {
+			pipeline := newPipeline(&v1alpha3.Schedule{Cron: "0 * * * *"}, nil)
+			pipeline.Spec = v1alpha3.PipelineSpec{
+				Type:                v1alpha3.MultiBranchPipelineType,
+				MultiBranchPipeline: &v1alpha3.MultiBranchPipeline{},
+				Schedule:            pipeline.Spec.Schedule,
+			}
+			return pipeline
+		}(),
+		wantEvent: "the SCM reference is required",
+	}, {
+		name:     "no schedule",
+		pipeline: newPipeline(nil, nil),
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := fake.NewClientBuilder().WithScheme(schema).WithObjects(tt.pipeline).
+				WithStatusSubresource(tt.pipeline).Build()
+			recorder := record.NewFakeRecorder(10)
+			r := &ScheduleReconciler{Client: c, log: logr.Discard(), recorder: recorder, clock: func() time.Time {
+				return now
+			}}
+
+			result, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: client.ObjectKeyFromObject(tt.pipeline)})
+			assert.Nil(t, err)
+			assert.Equal(t, tt.wantRequeue, result.RequeueAfter)
+			// it is safe to reconcile again
+			_, err = r.Reconcile(context.Background(), ctrl.Request{NamespacedName: client.ObjectKeyFromObject(tt.pipeline)})
+			assert.Nil(t, err)
+
+			list := &v1alpha3.PipelineRunList{}
+			assert.Nil(t, c.List(context.Background(), list))
+			var names []string
+			for _, item := range list.Items {
+				names = append(names, item.Name)
+			}
+			assert.Equal(t, tt.wantRuns, names)
+			if tt.verifyPipelineRun != nil && len(list.Items) > 0 {
+				tt.verifyPipelineRun(t, &list.Items[0])
+			}
+
+			pipeline := &v1alpha3.Pipeline{}
+			assert.Nil(t, c.Get(context.Background(), client.ObjectKeyFromObject(tt.pipeline), pipeline))
+			assert.True(t, timeEqual(tt.wantLast, pipeline.Status.LastScheduleTime), "last: %v", pipeline.Status.LastScheduleTime)
+			assert.True(t, timeEqual(tt.wantNext, pipeline.Status.NextScheduleTime), "next: %v", pipeline.Status.NextScheduleTime)
+			if tt.wantEvent != "" {
+				assert.Contains(t, <-recorder.Events, tt.wantEvent)
+			}
+		})
+	}
+}
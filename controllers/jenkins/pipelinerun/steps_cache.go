@@ -0,0 +1,68 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinerun
+
+import (
+	"sync"
+
+	"github.com/jenkins-zh/jenkins-client/pkg/job"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// finishedStepsCache caches the steps of the finished nodes of the running PipelineRuns. The steps of a finished node
+// never change, so only the nodes which are still running are retrieved from Jenkins again.
+type finishedStepsCache struct {
+	mutex sync.Mutex
+	// steps is the steps of nodes keyed by the PipelineRun UID, then the run ID and the node ID
+	steps map[types.UID]map[string][]job.Step
+}
+
+func newFinishedStepsCache() *finishedStepsCache {
+	return &finishedStepsCache{steps: map[types.UID]map[string][]job.Step{}}
+}
+
+func (c *finishedStepsCache) get(uid types.UID, runID, nodeID string) (steps []job.Step, ok bool) {
+	if c == nil {
+		return
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	steps, ok = c.steps[uid][runID+"/"+nodeID]
+	return
+}
+
+func (c *finishedStepsCache) set(uid types.UID, runID, nodeID string, steps []job.Step) {
+	if c == nil {
+		return
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.steps[uid] == nil {
+		c.steps[uid] = map[string][]job.Step{}
+	}
+	c.steps[uid][runID+"/"+nodeID] = steps
+}
+
+// forget removes the steps of a PipelineRun, it should be called once the PipelineRun completed or was deleted
+func (c *finishedStepsCache) forget(uid types.UID) {
+	if c == nil {
+		return
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	delete(c.steps, uid)
+}
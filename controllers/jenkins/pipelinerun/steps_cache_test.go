@@ -0,0 +1,47 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinerun
+
+import (
+	"testing"
+
+	"github.com/jenkins-zh/jenkins-client/pkg/job"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_finishedStepsCache(t *testing.T) {
+	cache := newFinishedStepsCache()
+	steps := []job.Step{{}}
+	cache.set("uid", "1", "node", steps)
+
+	got, ok := cache.get("uid", "1", "node")
+	assert.True(t, ok)
+	assert.Equal(t, steps, got)
+	_, ok = cache.get("uid", "2", "node")
+	assert.False(t, ok)
+
+	cache.forget("uid")
+	_, ok = cache.get("uid", "1", "node")
+	assert.False(t, ok)
+
+	// a nil cache caches nothing
+	var nilCache *finishedStepsCache
+	nilCache.set("uid", "1", "node", steps)
+	_, ok = nilCache.get("uid", "1", "node")
+	assert.False(t, ok)
+	nilCache.forget("uid")
+}
@@ -0,0 +1,149 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinerun
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jenkins-zh/jenkins-client/pkg/job"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/kubesphere/ks-devops/pkg/api/devops/v1alpha3"
+	"github.com/kubesphere/ks-devops/pkg/models/testreport"
+	"github.com/kubesphere/ks-devops/pkg/store/store"
+)
+
+// testReportRetryInterval is the interval of collecting the pending test reports of the completed PipelineRuns
+const testReportRetryInterval = time.Minute
+
+// testReportCollector saves the test report of a finished run into the PipelineRun data store.
+// The report is merged with the uploaded ones, and it's collected only once.
+type testReportCollector struct {
+	exec          testReportExecutor
+	pipeline      *v1alpha3.Pipeline
+	pr            *v1alpha3.PipelineRun
+	pipelineBuild *job.PipelineRun
+
+	// collected indicates the test report of the finished run is in the data store
+	collected bool
+}
+
+func (c *testReportCollector) collect(ctx context.Context, dataStore store.PipelineRunDataStore) {
+	if c.pipelineBuild == nil || c.pipelineBuild.State != Finished.String() {
+		return
+	}
+	if dataStore.Get(store.DataKeyTestReportCollected) == "true" {
+		c.collected = true
+		return
+	}
+
+	cases, err := c.exec.getTestReport(ctx, c.pipeline, c.pr)
+	if err != nil {
+		klog.V(4).Infof("failed to get the test report of PipelineRun %s/%s, error: %v", c.pr.Namespace, c.pr.Name, err)
+		return
+	}
+	report, err := testreport.Parse(dataStore.GetTestReport())
+	if err != nil {
+		klog.V(4).Infof("ignored the invalid test report of PipelineRun %s/%s, error: %v", c.pr.Namespace, c.pr.Name, err)
+		report = &testreport.Report{}
+	}
+	report.Add(cases...)
+	if report.CommitID == "" {
+		report.CommitID = c.pipelineBuild.CommitID
+	}
+	report.Truncate(testreport.MaxStoredSize)
+	dataStore.SetTestReport(report.String())
+	dataStore.Set(store.DataKeyTestReportCollected, "true")
+	c.collected = true
+}
+
+// collectPendingTestReport collects the test report of a completed PipelineRun which failed to collect it, it's
+// retried until the report is in the data store or there is nothing to collect.
+func (r *Reconciler) collectPendingTestReport(ctx context.Context, pr *v1alpha3.PipelineRun) (ctrl.Result, error) {
+	retryLater := func(err error) (ctrl.Result, error) {
+		klog.V(4).Infof("failed to collect the test report of PipelineRun %s/%s, retry it later, error: %v",
+			pr.Namespace, pr.Name, err)
+		return ctrl.Result{RequeueAfter: testReportRetryInterval}, nil
+	}
+
+	pipeline := &v1alpha3.Pipeline{}
+	if pr.Spec.PipelineRef == nil {
+		return ctrl.Result{}, r.setTestReportPending(ctx, pr, false)
+	} else if err := r.Get(ctx, client.ObjectKey{Namespace: pr.Namespace, Name: pr.Spec.PipelineRef.Name}, pipeline); err != nil {
+		if client.IgnoreNotFound(err) == nil {
+			return ctrl.Result{}, r.setTestReportPending(ctx, pr, false)
+		}
+		return retryLater(err)
+	}
+	exec, err := r.getExecutor(pr.Annotations[v1alpha3.PipelineExecutorAnnoKey])
+	if err != nil {
+		return retryLater(err)
+	}
+	reportExec, ok := exec.(testReportExecutor)
+	if !ok {
+		return ctrl.Result{}, r.setTestReportPending(ctx, pr, false)
+	}
+	pipelineBuild, err := exec.getStatus(ctx, pipeline, pr)
+	if err != nil {
+		if err.Error() == BuildNotExistMsg {
+			// the run was removed from Jenkins, there is nothing to collect
+			return ctrl.Result{}, r.setTestReportPending(ctx, pr, false)
+		}
+		return retryLater(err)
+	}
+
+	provider, err := r.getDataStore()
+	if err != nil {
+		return retryLater(err)
+	}
+	dataStore, err := provider.Get(ctx, client.ObjectKeyFromObject(pr))
+	if err != nil {
+		return retryLater(err)
+	}
+	collector := &testReportCollector{exec: reportExec, pipeline: pipeline, pr: pr, pipelineBuild: pipelineBuild}
+	if collector.collect(ctx, dataStore); !collector.collected {
+		return retryLater(fmt.Errorf("the test report is not available"))
+	}
+	dataStore.SetOwnerReference(v1.OwnerReference{
+		APIVersion: v1alpha3.GroupVersion.String(),
+		Kind:       "PipelineRun",
+		Name:       pr.Name,
+		UID:        pr.UID,
+	})
+	if err = dataStore.Save(); err != nil {
+		return retryLater(err)
+	}
+	return ctrl.Result{}, r.setTestReportPending(ctx, pr, false)
+}
+
+// setTestReportPending marks or unmarks the test report of a PipelineRun as pending
+func (r *Reconciler) setTestReportPending(ctx context.Context, pr *v1alpha3.PipelineRun, pending bool) error {
+	if pending {
+		if pr.Annotations == nil {
+			pr.Annotations = map[string]string{}
+		}
+		pr.Annotations[v1alpha3.PipelineRunTestReportPendingAnnoKey] = "true"
+	} else {
+		delete(pr.Annotations, v1alpha3.PipelineRunTestReportPendingAnnoKey)
+	}
+	return r.updateLabelsAndAnnotations(ctx, pr)
+}
@@ -0,0 +1,147 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinerun
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/jenkins-zh/jenkins-client/pkg/job"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/kubesphere/ks-devops/pkg/api/devops/v1alpha3"
+	"github.com/kubesphere/ks-devops/pkg/models/testreport"
+	"github.com/kubesphere/ks-devops/pkg/store/fake"
+	"github.com/kubesphere/ks-devops/pkg/store/store"
+)
+
+type fakeTestReportExecutor struct {
+	executor
+	cases    []testreport.Case
+	err      error
+	requests int
+}
+
+func (e *fakeTestReportExecutor) getTestReport(_ context.Context, _ *v1alpha3.Pipeline, _ *v1alpha3.PipelineRun) ([]testreport.Case, error) {
+	e.requests++
+	return e.cases, e.err
+}
+
+func Test_testReportCollector_collect(t *testing.T) {
+	exec := &fakeTestReportExecutor{cases: []testreport.Case{
+		{ClassName: "a", Name: "pass", Status: testreport.CasePassed, Duration: 1},
+		{ClassName: "a", Name: "fail", Status: testreport.CaseFailed, Duration: 2, ErrorDetails: "expected"},
+	}}
+	dataStore := fake.NewFakeStore()
+	pipelineBuild := &job.PipelineRun{CommitID: "abc"}
+	pipelineBuild.State = Running.String()
+	collector := &testReportCollector{exec: exec, pr: &v1alpha3.PipelineRun{}, pipelineBuild: pipelineBuild}
+
+	// the report is collected after the run finished
+	collector.collect(context.TODO(), dataStore)
+	assert.Equal(t, 0, exec.requests)
+	assert.Empty(t, dataStore.GetTestReport())
+	assert.False(t, collector.collected)
+
+	// keep trying if failed to get the report
+	pipelineBuild.State = Finished.String()
+	exec.err = errors.New("fake")
+	collector.collect(context.TODO(), dataStore)
+	assert.Equal(t, 1, exec.requests)
+	assert.Empty(t, dataStore.GetTestReport())
+	assert.False(t, collector.collected)
+
+	// merge with the uploaded report
+	exec.err = nil
+	uploaded := &testreport.Report{CommitID: "uploaded"}
+	uploaded.Add(testreport.Case{Name: "upload", Status: testreport.CaseSkipped})
+	dataStore.SetTestReport(uploaded.String())
+	collector.collect(context.TODO(), dataStore)
+	collector.collect(context.TODO(), dataStore)
+	assert.Equal(t, 2, exec.requests)
+	assert.Equal(t, "true", dataStore.Get(store.DataKeyTestReportCollected))
+	assert.True(t, collector.collected)
+
+	report, err := testreport.Parse(dataStore.GetTestReport())
+	assert.Nil(t, err)
+	assert.Equal(t, testreport.Summary{Total: 3, Passed: 1, Failed: 1, Skipped: 1, Duration: 3}, report.Summary)
+	assert.Equal(t, "uploaded", report.CommitID)
+	assert.Equal(t, []string{"a.pass"}, report.Passed)
+	assert.Equal(t, "expected", report.Failed[0].ErrorDetails)
+}
+
+func TestReconciler_collectPendingTestReport(t *testing.T) {
+	schema, err := v1alpha3.SchemeBuilder.Register().Build()
+	assert.Nil(t, err)
+
+	newPipelineRun := func(name, pipeline, executor string) *v1alpha3.PipelineRun {
+		pr := &v1alpha3.PipelineRun{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: "ns",
+				Name:      name,
+				Annotations: map[string]string{
+					v1alpha3.PipelineRunTestReportPendingAnnoKey: "true",
+					v1alpha3.PipelineExecutorAnnoKey:             executor,
+				},
+			},
+		}
+		if pipeline != "" {
+			pr.Spec.PipelineRef = &corev1.ObjectReference{Name: pipeline}
+		}
+		return pr
+	}
+	pipeline := &v1alpha3.Pipeline{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "pipeline"}}
+	tests := []struct {
+		name        string
+		pr          *v1alpha3.PipelineRun
+		wantPending bool
+		wantResult  ctrl.Result
+	}{{
+		name: "without the Pipeline reference",
+		pr:   newPipelineRun("no-ref", "", ""),
+	}, {
+		name: "the Pipeline was deleted",
+		pr:   newPipelineRun("no-pipeline", "deleted", ""),
+	}, {
+		name: "the executor does not have test reports",
+		pr:   newPipelineRun("pod", "pipeline", v1alpha3.ExecutorKubernetes),
+	}, {
+		name:        "unknown executor",
+		pr:          newPipelineRun("unknown", "pipeline", "unknown"),
+		wantPending: true,
+		wantResult:  ctrl.Result{RequeueAfter: testReportRetryInterval},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &Reconciler{Client: fakeclient.NewClientBuilder().WithScheme(schema).WithObjects(pipeline.DeepCopy(), tt.pr.DeepCopy()).Build()}
+			result, err := r.collectPendingTestReport(context.TODO(), tt.pr.DeepCopy())
+			assert.Nil(t, err)
+			assert.Equal(t, tt.wantResult, result)
+
+			pr := &v1alpha3.PipelineRun{}
+			assert.Nil(t, r.Get(context.TODO(), client.ObjectKeyFromObject(tt.pr), pr))
+			_, pending := pr.Annotations[v1alpha3.PipelineRunTestReportPendingAnnoKey]
+			assert.Equal(t, tt.wantPending, pending)
+		})
+	}
+}
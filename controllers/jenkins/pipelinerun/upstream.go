@@ -0,0 +1,250 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinerun
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"time"
+
+	"github.com/go-logr/logr"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	"github.com/kubesphere/ks-devops/pkg/api/devops/v1alpha3"
+	"github.com/kubesphere/ks-devops/pkg/kapis/devops/v1alpha3/pipelinerun"
+)
+
+// upstreamTriggerWindow is how long a completed PipelineRun could still trigger the downstream Pipelines. The handled
+// PipelineRuns are marked, the window keeps the old PipelineRuns which were never marked, like the ones completed
+// before the downstream Pipelines were created, from triggering anything. The missed triggers are reported as events.
+const upstreamTriggerWindow = 10 * time.Minute
+
+// UpstreamTriggerReconciler creates the PipelineRuns of the downstream Pipelines once a PipelineRun completed. A
+// downstream Pipeline has an upstream trigger which matches the completed PipelineRun.
+type UpstreamTriggerReconciler struct {
+	client.Client
+	log      logr.Logger
+	recorder record.EventRecorder
+	clock    func() time.Time
+}
+
+// Reconcile triggers the downstream Pipelines of a completed PipelineRun
+func (r *UpstreamTriggerReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := r.log.WithValues("PipelineRun", req.NamespacedName)
+	upstream := &v1alpha3.PipelineRun{}
+	if err := r.Get(ctx, req.NamespacedName, upstream); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	// the children of a matrix PipelineRun trigger nothing, the matrix PipelineRun does once all of them completed
+	if !upstream.HasCompleted() || !upstream.DeletionTimestamp.IsZero() ||
+		upstream.Labels[v1alpha3.PipelineRunMatrixParentLabelKey] != "" ||
+		upstream.Annotations[v1alpha3.PipelineRunDownstreamTriggeredAnnoKey] != "" {
+		return ctrl.Result{}, nil
+	}
+
+	downstreams := &v1alpha3.PipelineList{}
+	if err := r.List(ctx, downstreams, client.MatchingFields{
+		v1alpha3.PipelineUpstreamIndexerName: v1alpha3.GetUpstreamKey(upstream.Namespace, upstream.GetPipelineName()),
+	}); err != nil || len(downstreams.Items) == 0 {
+		return ctrl.Result{}, err
+	}
+	chain, err := r.getUpstreamPipelines(ctx, upstream)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	// the upstream Pipeline decides which DevOpsProjects could be triggered, nothing is allowed once it was deleted
+	upstreamPipeline := &v1alpha3.Pipeline{}
+	if err = r.Get(ctx, client.ObjectKey{Namespace: upstream.Namespace, Name: upstream.GetPipelineName()}, upstreamPipeline); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return ctrl.Result{}, err
+		}
+		upstreamPipeline = &v1alpha3.Pipeline{ObjectMeta: metav1.ObjectMeta{Namespace: upstream.Namespace}}
+	}
+	missed := r.now().Sub(upstream.Status.CompletionTime.Time) > upstreamTriggerWindow
+
+	var errs []error
+	for i := range downstreams.Items {
+		pipeline := &downstreams.Items[i]
+		if !pipeline.DeletionTimestamp.IsZero() {
+			continue
+		}
+		for j := range pipeline.Spec.UpstreamTriggers {
+			trigger := &pipeline.Spec.UpstreamTriggers[j]
+			if !trigger.Matches(pipeline.Namespace, upstream) {
+				continue
+			}
+			// an upstream PipelineRun triggers a Pipeline once even if multiple triggers match it
+			if !upstreamPipeline.AllowsDownstream(pipeline.Namespace) {
+				r.recorder.Eventf(pipeline, v1.EventTypeWarning, v1alpha3.UpstreamTriggerFailed,
+					"Skipped the upstream PipelineRun %s/%s, DevOpsProject %s is not allowed by the upstream Pipeline",
+					upstream.Namespace, upstream.Name, pipeline.Namespace)
+			} else if missed {
+				r.recorder.Eventf(pipeline, v1.EventTypeWarning, v1alpha3.UpstreamTriggerFailed,
+					"Missed the upstream PipelineRun %s/%s, it completed at %s which is more than %v ago",
+					upstream.Namespace, upstream.Name, upstream.Status.CompletionTime.UTC().Format(time.RFC3339), upstreamTriggerWindow)
+			} else if err = r.trigger(ctx, pipeline, trigger, upstream, chain); err != nil {
+				errs = append(errs, err)
+			} else {
+				log.V(4).Info("triggered the downstream Pipeline", "Pipeline", client.ObjectKeyFromObject(pipeline))
+			}
+			break
+		}
+	}
+	if len(errs) > 0 {
+		return ctrl.Result{}, utilerrors.NewAggregate(errs)
+	}
+	return ctrl.Result{}, r.markTriggered(ctx, upstream)
+}
+
+// markTriggered marks the downstream Pipelines of a PipelineRun were handled
+func (r *UpstreamTriggerReconciler) markTriggered(ctx context.Context, upstream *v1alpha3.PipelineRun) error {
+	patch := client.MergeFrom(upstream.DeepCopy())
+	if upstream.Annotations == nil {
+		upstream.Annotations = map[string]string{}
+	}
+	upstream.Annotations[v1alpha3.PipelineRunDownstreamTriggeredAnnoKey] = r.now().UTC().Format(time.RFC3339)
+	return client.IgnoreNotFound(r.Patch(ctx, upstream, patch))
+}
+
+// trigger creates a PipelineRun of the downstream Pipeline. The loops are broken, so a Pipeline never runs twice in
+// the same chain.
+func (r *UpstreamTriggerReconciler) trigger(ctx context.Context, pipeline *v1alpha3.Pipeline, trigger *v1alpha3.UpstreamTrigger,
+	upstream *v1alpha3.PipelineRun, chain []string) error {
+	key := v1alpha3.GetUpstreamKey(pipeline.Namespace, pipeline.Name)
+	for _, upstreamKey := range chain {
+		if upstreamKey == key {
+			r.recorder.Eventf(pipeline, v1.EventTypeWarning, v1alpha3.UpstreamTriggerFailed,
+				"Skipped the upstream PipelineRun %s/%s, the Pipeline has run in the same chain", upstream.Namespace, upstream.Name)
+			return nil
+		}
+	}
+	if len(chain) >= v1alpha3.MaxUpstreamDepth {
+		r.recorder.Eventf(pipeline, v1.EventTypeWarning, v1alpha3.UpstreamTriggerFailed,
+			"Skipped the upstream PipelineRun %s/%s, the chain is longer than %d", upstream.Namespace, upstream.Name, v1alpha3.MaxUpstreamDepth)
+		return nil
+	}
+
+	scm := trigger.SCM.DeepCopy()
+	if scm == nil && pipeline.IsMultiBranch() && upstream.GetRefName() != "" {
+		scm = &v1alpha3.SCM{RefName: upstream.GetRefName(), RefType: upstream.Spec.SCM.RefType}
+	}
+	if scm == nil && pipeline.IsMultiBranch() {
+		// there is nothing to do until the trigger is changed
+		r.recorder.Eventf(pipeline, v1.EventTypeWarning, v1alpha3.UpstreamTriggerFailed,
+			"Skipped the upstream PipelineRun %s/%s, the SCM reference is required by a multi-branch Pipeline", upstream.Namespace, upstream.Name)
+		return nil
+	}
+
+	pr := newDownstreamPipelineRun(pipeline, trigger, upstream, scm)
+	err := r.Create(ctx, pr)
+	if apierrors.IsAlreadyExists(err) {
+		// the name is hashed from the UID of the upstream PipelineRun, it's taken by others in case of a collision
+		var triggered bool
+		if triggered, err = r.isTriggered(ctx, pipeline, upstream); err != nil || triggered {
+			return err
+		}
+		pr.Name, pr.GenerateName = "", pipeline.Name+"-up-"
+		err = r.Create(ctx, pr)
+	}
+	if err != nil {
+		r.recorder.Eventf(pipeline, v1.EventTypeWarning, v1alpha3.UpstreamTriggerFailed,
+			"Failed to create PipelineRun %s triggered by %s/%s, and error was %v", pr.Name, upstream.Namespace, upstream.Name, err)
+		return err
+	}
+	r.recorder.Eventf(pipeline, v1.EventTypeNormal, v1alpha3.UpstreamTriggered,
+		"Created PipelineRun %s triggered by %s/%s", pr.Name, upstream.Namespace, upstream.Name)
+	return nil
+}
+
+// isTriggered returns true if the Pipeline has a PipelineRun which was triggered by the upstream PipelineRun
+func (r *UpstreamTriggerReconciler) isTriggered(ctx context.Context, pipeline *v1alpha3.Pipeline, upstream *v1alpha3.PipelineRun) (
+	bool, error) {
+	prs := &v1alpha3.PipelineRunList{}
+	if err := r.List(ctx, prs, client.InNamespace(pipeline.Namespace), client.MatchingLabels{
+		v1alpha3.PipelineNameLabelKey:        pipeline.Name,
+		v1alpha3.PipelineRunUpstreamLabelKey: string(upstream.UID),
+	}); err != nil {
+		return false, err
+	}
+	return len(prs.Items) > 0, nil
+}
+
+// getUpstreamPipelines returns the keys of the Pipelines in the chain of a PipelineRun, from the PipelineRun itself.
+// The chain ends at a PipelineRun which was deleted.
+func (r *UpstreamTriggerReconciler) getUpstreamPipelines(ctx context.Context, pr *v1alpha3.PipelineRun) (keys []string, err error) {
+	for current := pr; ; {
+		keys = append(keys, v1alpha3.GetUpstreamKey(current.Namespace, current.GetPipelineName()))
+		ref := current.Spec.Upstream
+		if ref == nil || len(keys) >= v1alpha3.MaxUpstreamDepth {
+			return
+		}
+		current = &v1alpha3.PipelineRun{}
+		if err = r.Get(ctx, client.ObjectKey{Namespace: ref.Namespace, Name: ref.Name}, current); apierrors.IsNotFound(err) {
+			keys = append(keys, v1alpha3.GetUpstreamKey(ref.Namespace, ref.Pipeline))
+			return keys, nil
+		} else if err != nil {
+			return
+		}
+	}
+}
+
+func (r *UpstreamTriggerReconciler) now() time.Time {
+	if r.clock != nil {
+		return r.clock()
+	}
+	return time.Now()
+}
+
+// newDownstreamPipelineRun creates a PipelineRun triggered by an upstream PipelineRun, the name is decided by the
+// upstream PipelineRun, so it never triggers the same Pipeline twice. The name might collide with the one of another
+// upstream PipelineRun, see isTriggered
+func newDownstreamPipelineRun(pipeline *v1alpha3.Pipeline, trigger *v1alpha3.UpstreamTrigger, upstream *v1alpha3.PipelineRun,
+	scm *v1alpha3.SCM) *v1alpha3.PipelineRun {
+	pipeline = pipeline.DeepCopy()
+	pipeline.SetGroupVersionKind(v1alpha3.GroupVersion.WithKind(v1alpha3.ResourceKindPipeline))
+	pr := pipelinerun.CreateBarePipelineRun(pipeline, trigger.GetParameters(upstream), scm)
+	hash := fnv.New32a()
+	_, _ = hash.Write([]byte(upstream.UID))
+	pr.GenerateName = ""
+	pr.Name = fmt.Sprintf("%s-up-%08x", pipeline.Name, hash.Sum32())
+	pr.Labels[v1alpha3.PipelineRunUpstreamLabelKey] = string(upstream.UID)
+	pr.Spec.Upstream = v1alpha3.NewUpstreamReference(upstream)
+	return pr
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *UpstreamTriggerReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	r.recorder = mgr.GetEventRecorderFor("pipeline-upstream-trigger")
+	r.log = ctrl.Log.WithName("pipeline-upstream-trigger")
+
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("jenkins_pipeline_upstream_trigger").
+		For(&v1alpha3.PipelineRun{}, builder.WithPredicates(predicate.NewPredicateFuncs(func(obj client.Object) bool {
+			pr, ok := obj.(*v1alpha3.PipelineRun)
+			return ok && pr.HasCompleted()
+		}))).
+		Complete(r)
+}
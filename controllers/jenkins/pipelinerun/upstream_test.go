@@ -0,0 +1,250 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinerun
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/kubesphere/ks-devops/pkg/api/devops/v1alpha3"
+)
+
+func TestUpstreamTriggerReconciler(t *testing.T) {
+	schema, err := v1alpha3.SchemeBuilder.Register().Build()
+	assert.Nil(t, err)
+	now := time.Date(2022, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	newUpstream := func(namespace, name, pipeline string, phase v1alpha3.RunPhase) *v1alpha3.PipelineRun {
+		return &v1alpha3.PipelineRun{
+			ObjectMeta: v1.ObjectMeta{
+				Namespace: namespace,
+				Name:      name,
+				UID:       "7c5d1f2e-uid",
+				Labels:    map[string]string{v1alpha3.PipelineNameLabelKey: pipeline},
+			},
+			Spec: v1alpha3.PipelineRunSpec{
+				PipelineRef: &corev1.ObjectReference{Name: pipeline},
+				Parameters:  []v1alpha3.Parameter{{Name: "version", Value: "v1.0"}},
+			},
+			Status: v1alpha3.PipelineRunStatus{
+				Phase:          phase,
+				CompletionTime: &v1.Time{Time: now.Add(-time.Minute)},
+			},
+		}
+	}
+	newPipeline := func(namespace, name string, triggers ...v1alpha3.UpstreamTrigger) *v1alpha3.Pipeline {
+		return &v1alpha3.Pipeline{
+			ObjectMeta: v1.ObjectMeta{Namespace: namespace, Name: name},
+			Spec: v1alpha3.PipelineSpec{
+				Type:             v1alpha3.NoScmPipelineType,
+				UpstreamTriggers: triggers,
+			},
+		}
+	}
+	deploy := newPipeline("team-b", "deploy", v1alpha3.UpstreamTrigger{
+		Namespace:  "team-a",
+		Pipeline:   "build",
+		Parameters: []v1alpha3.Parameter{{Name: "image", Value: "app:$(upstream.params.version)"}},
+	})
+	notify := newPipeline("team-a", "notify", v1alpha3.UpstreamTrigger{Pipeline: "build", Phases: []v1alpha3.RunPhase{v1alpha3.Failed}})
+	unrelated := newPipeline("team-a", "unrelated")
+	// the upstream Pipeline allows team-b to subscribe to it
+	build := newPipeline("team-a", "build")
+	build.Annotations = map[string]string{v1alpha3.PipelineDownstreamNamespacesAnnoKey: "team-b"}
+
+	tests := []struct {
+		name      string
+		upstream  *v1alpha3.PipelineRun
+		objects   []client.Object
+		wantRuns  map[string]string
+		wantEvent string
+		verify    func(t *testing.T, c client.Client)
+	}{{
+		name:     "succeeded",
+		upstream: newUpstream("team-a", "build-x7k2p", "build", v1alpha3.Succeeded),
+		objects:  []client.Object{build, deploy, notify, unrelated},
+		wantRuns: map[string]string{"team-b": "deploy"},
+		verify: func(t *testing.T, c client.Client) {
+			upstream := &v1alpha3.PipelineRun{}
+			assert.Nil(t, c.Get(context.Background(), client.ObjectKey{Namespace: "team-a", Name: "build-x7k2p"}, upstream))
+			assert.Equal(t, now.Format(time.RFC3339), upstream.Annotations[v1alpha3.PipelineRunDownstreamTriggeredAnnoKey])
+
+			prs := &v1alpha3.PipelineRunList{}
+			assert.Nil(t, c.List(context.Background(), prs, client.InNamespace("team-b")))
+			if assert.Len(t, prs.Items, 1) {
+				pr := prs.Items[0]
+				assert.Equal(t, "deploy", pr.Labels[v1alpha3.PipelineNameLabelKey])
+				assert.Equal(t, "7c5d1f2e-uid", pr.Labels[v1alpha3.PipelineRunUpstreamLabelKey])
+				assert.Equal(t, &v1alpha3.UpstreamReference{Namespace: "team-a", Name: "build-x7k2p", Pipeline: "build", UID: "7c5d1f2e-uid"},
+					pr.Spec.Upstream)
+				assert.Equal(t, []v1alpha3.Parameter{{Name: "image", Value: "app:v1.0"}}, pr.Spec.Parameters)
+			}
+		},
+		wantEvent: "Normal UpstreamTriggered Created PipelineRun deploy-up-",
+	}, {
+		name:     "failed",
+		upstream: newUpstream("team-a", "build-x7k2p", "build", v1alpha3.Failed),
+		objects:  []client.Object{deploy, notify},
+		wantRuns: map[string]string{"team-a": "notify"},
+	}, {
+		name:      "another namespace is not allowed by the upstream Pipeline",
+		upstream:  newUpstream("team-a", "build-x7k2p", "build", v1alpha3.Succeeded),
+		objects:   []client.Object{newPipeline("team-a", "build"), deploy},
+		wantEvent: "Warning UpstreamTriggerFailed Skipped the upstream PipelineRun team-a/build-x7k2p, DevOpsProject team-b is not allowed by the upstream Pipeline",
+	}, {
+		name: "handled already",
+		upstream: func() *v1alpha3.PipelineRun {
+			pr := newUpstream("team-a", "build-x7k2p", "build", v1alpha3.Failed)
+			pr.Annotations = map[string]string{v1alpha3.PipelineRunDownstreamTriggeredAnnoKey: now.Format(time.RFC3339)}
+			return pr
+		}(),
+		objects: []client.Object{notify},
+	}, {
+		name:     "the name is taken by another upstream PipelineRun",
+		upstream: newUpstream("team-a", "build-x7k2p", "build", v1alpha3.Failed),
+		objects: []client.Object{notify, func() client.Object {
+			pr := newDownstreamPipelineRun(notify, &notify.Spec.UpstreamTriggers[0],
+				newUpstream("team-a", "build-x7k2p", "build", v1alpha3.Failed), nil)
+			pr.Labels[v1alpha3.PipelineRunUpstreamLabelKey] = "another-uid"
+			pr.Spec.Upstream = nil
+			return pr
+		}()},
+		wantRuns: map[string]string{"team-a": "notify"},
+		verify: func(t *testing.T, c client.Client) {
+			prs := &v1alpha3.PipelineRunList{}
+			assert.Nil(t, c.List(context.Background(), prs, client.MatchingLabels{v1alpha3.PipelineNameLabelKey: "notify"}))
+			assert.Len(t, prs.Items, 2)
+		},
+	}, {
+		name: "not completed",
+		upstream: func() *v1alpha3.PipelineRun {
+			pr := newUpstream("team-a", "build-x7k2p", "build", v1alpha3.Running)
+			pr.Status.CompletionTime = nil
+			return pr
+		}(),
+		objects: []client.Object{deploy},
+	}, {
+		name: "completed long ago",
+		upstream: func() *v1alpha3.PipelineRun {
+			pr := newUpstream("team-a", "build-x7k2p", "build", v1alpha3.Succeeded)
+			pr.Status.CompletionTime = &v1.Time{Time: now.Add(-time.Hour)}
+			return pr
+		}(),
+		objects:   []client.Object{build, deploy},
+		wantEvent: "Warning UpstreamTriggerFailed Missed the upstream PipelineRun team-a/build-x7k2p, it completed at 2022-01-02T02:04:05Z which is more than 10m0s ago",
+	}, {
+		name: "a child of a matrix",
+		upstream: func() *v1alpha3.PipelineRun {
+			pr := newUpstream("team-a", "build-x7k2p-0", "build", v1alpha3.Succeeded)
+			pr.Labels[v1alpha3.PipelineRunMatrixParentLabelKey] = "build-x7k2p"
+			return pr
+		}(),
+		objects: []client.Object{deploy},
+	}, {
+		name: "a loop",
+		upstream: func() *v1alpha3.PipelineRun {
+			pr := newUpstream("team-a", "build-x7k2p", "build", v1alpha3.Succeeded)
+			pr.Spec.Upstream = &v1alpha3.UpstreamReference{Namespace: "team-b", Name: "deploy-abc", Pipeline: "deploy"}
+			return pr
+		}(),
+		objects:   []client.Object{build, deploy, newUpstream("team-b", "deploy-abc", "deploy", v1alpha3.Succeeded)},
+		wantEvent: "Warning UpstreamTriggerFailed Skipped the upstream PipelineRun team-a/build-x7k2p, the Pipeline has run in the same chain",
+	}, {
+		name:     "a multi-branch Pipeline without SCM",
+		upstream: newUpstream("team-a", "build-x7k2p", "build", v1alpha3.Succeeded),
+		objects: []client.Object{func() client.Object {
+			pipeline := newPipeline("team-a", "multi", v1alpha3.UpstreamTrigger{Pipeline: "build"})
+			pipeline.Spec.Type = v1alpha3.MultiBranchPipelineType
+			return pipeline
+		}()},
+		wantEvent: "Warning UpstreamTriggerFailed Skipped the upstream PipelineRun team-a/build-x7k2p, the SCM reference is required by a multi-branch Pipeline",
+	}, {
+		name: "a multi-branch Pipeline runs the upstream branch",
+		upstream: func() *v1alpha3.PipelineRun {
+			pr := newUpstream("team-a", "build-x7k2p", "build", v1alpha3.Succeeded)
+			pr.Spec.PipelineSpec = &v1alpha3.PipelineSpec{Type: v1alpha3.MultiBranchPipelineType}
+			pr.Spec.SCM = &v1alpha3.SCM{RefName: "main", RefType: "branch"}
+			return pr
+		}(),
+		objects: []client.Object{func() client.Object {
+			pipeline := newPipeline("team-a", "multi", v1alpha3.UpstreamTrigger{Pipeline: "build", Branch: "main"})
+			pipeline.Spec.Type = v1alpha3.MultiBranchPipelineType
+			return pipeline
+		}()},
+		wantRuns: map[string]string{"team-a": "multi"},
+		verify: func(t *testing.T, c client.Client) {
+			prs := &v1alpha3.PipelineRunList{}
+			assert.Nil(t, c.List(context.Background(), prs, client.MatchingLabels{v1alpha3.PipelineNameLabelKey: "multi"}))
+			if assert.Len(t, prs.Items, 1) {
+				assert.Equal(t, &v1alpha3.SCM{RefName: "main", RefType: "branch"}, prs.Items[0].Spec.SCM)
+			}
+		},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := fake.NewClientBuilder().WithScheme(schema).
+				WithObjects(append(tt.objects, tt.upstream)...).
+				WithIndex(&v1alpha3.Pipeline{}, v1alpha3.PipelineUpstreamIndexerName, func(o client.Object) []string {
+					return o.(*v1alpha3.Pipeline).GetUpstreamKeys()
+				}).Build()
+			recorder := record.NewFakeRecorder(10)
+			r := &UpstreamTriggerReconciler{
+				Client:   c,
+				log:      logr.Discard(),
+				recorder: recorder,
+				clock:    func() time.Time { return now },
+			}
+			req := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(tt.upstream)}
+
+			// it's idempotent
+			for i := 0; i < 2; i++ {
+				_, err := r.Reconcile(context.Background(), req)
+				assert.Nil(t, err)
+			}
+
+			prs := &v1alpha3.PipelineRunList{}
+			assert.Nil(t, c.List(context.Background(), prs, client.HasLabels{v1alpha3.PipelineRunUpstreamLabelKey}))
+			runs := map[string]string{}
+			for _, pr := range prs.Items {
+				if pr.Spec.Upstream != nil && pr.Spec.Upstream.Name == tt.upstream.Name {
+					runs[pr.Namespace] = pr.Labels[v1alpha3.PipelineNameLabelKey]
+				}
+			}
+			if tt.wantRuns == nil {
+				assert.Empty(t, runs)
+			} else {
+				assert.Equal(t, tt.wantRuns, runs)
+			}
+			if tt.verify != nil {
+				tt.verify(t, c)
+			}
+			if tt.wantEvent != "" {
+				assert.Contains(t, <-recorder.Events, tt.wantEvent)
+			}
+		})
+	}
+}
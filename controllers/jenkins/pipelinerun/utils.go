@@ -112,6 +112,19 @@ func (pbApplier pipelineBuildApplier) apply(prStatus *v1alpha3.PipelineRunStatus
 	prStatus.AddCondition(&condition)
 	prStatus.UpdateTime = &v1.Time{Time: time.Now()}
 	prStatus.StartTime = &v1.Time{Time: pbApplier.StartTime.Time}
+	if changeTime := pbApplier.getChangeTime(); !changeTime.IsZero() {
+		prStatus.ChangeTime = &v1.Time{Time: changeTime}
+	}
+}
+
+// getChangeTime returns the commit time of the earliest change in the change set of the run
+func (pbApplier pipelineBuildApplier) getChangeTime() (changeTime time.Time) {
+	for _, change := range pbApplier.ChangeSet {
+		if !change.Timestamp.IsZero() && (changeTime.IsZero() || change.Timestamp.Before(changeTime)) {
+			changeTime = change.Timestamp.Time
+		}
+	}
+	return
 }
 
 func (pbApplier pipelineBuildApplier) whenPipelineRunFinished(condition *v1alpha3.Condition, prStatus *v1alpha3.PipelineRunStatus) {
@@ -146,6 +159,43 @@ func (pbApplier pipelineBuildApplier) whenPipelineRunFinished(condition *v1alpha
 	}
 }
 
+// actionApplier applies the Action which has been handled to PipelineRunStatus.
+type actionApplier struct {
+	action v1alpha3.Action
+}
+
+func (applier actionApplier) apply(prStatus *v1alpha3.PipelineRunStatus) {
+	now := v1.Now()
+	condition := v1alpha3.Condition{
+		Type:               v1alpha3.ConditionReady,
+		Status:             v1alpha3.ConditionUnknown,
+		LastProbeTime:      now,
+		LastTransitionTime: now,
+	}
+
+	switch applier.action {
+	case v1alpha3.Stop:
+		condition.Type = v1alpha3.ConditionSucceeded
+		condition.Status = v1alpha3.ConditionFalse
+		condition.Reason = string(v1alpha3.Cancelled)
+		condition.Message = "the PipelineRun was stopped by the Stop action"
+		prStatus.Phase = v1alpha3.Cancelled
+		prStatus.CompletionTime = &now
+	case v1alpha3.Pause:
+		condition.Reason = Paused.String()
+		condition.Message = "the PipelineRun was paused by the Pause action"
+		prStatus.Phase = v1alpha3.Pending
+	case v1alpha3.Resume:
+		condition.Reason = Running.String()
+		condition.Message = "the PipelineRun was resumed by the Resume action"
+		prStatus.Phase = v1alpha3.Running
+	default:
+		return
+	}
+	prStatus.AddCondition(&condition)
+	prStatus.UpdateTime = &now
+}
+
 // parameterConverter is responsible to convert Parameter slice of PipelineRun into job.Parameter slice.
 type parameterConverter struct {
 	parameters []v1alpha3.Parameter
@@ -293,6 +293,28 @@ func Test_pipelineBuildApplier_apply(t *testing.T) {
 			assert.Equal(t, v1alpha3.ConditionReady, prStatus.Conditions[1].Type)
 			assert.Equal(t, v1alpha3.ConditionUnknown, prStatus.Conditions[1].Status)
 		},
+	}, {
+		name: "PipelineRun built some changes",
+		fields: fields{
+			pb: &job.PipelineRun{
+				BlueItemRun: job.BlueItemRun{
+					ID:    "1",
+					State: Running.String(),
+					ChangeSet: []job.BlueChangeSetEntry{
+						{CommitID: "b", Timestamp: job.Time{Time: time.Date(2022, 1, 2, 0, 0, 0, 0, time.UTC)}},
+						{CommitID: "a", Timestamp: job.Time{Time: time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)}},
+						{CommitID: "unknown"},
+					},
+				},
+			},
+		},
+		args: args{
+			prStatus: &v1alpha3.PipelineRunStatus{},
+		},
+		assertion: func(prStatus *v1alpha3.PipelineRunStatus) {
+			commonStatusAssert(prStatus)
+			assert.Equal(t, &v1.Time{Time: time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)}, prStatus.ChangeTime)
+		},
 	}, {
 		name: "Nil PipelineRun",
 		fields: fields{
@@ -316,6 +338,59 @@ func Test_pipelineBuildApplier_apply(t *testing.T) {
 	}
 }
 
+func Test_actionApplier_apply(t *testing.T) {
+	tests := []struct {
+		name      string
+		action    v1alpha3.Action
+		assertion func(*v1alpha3.PipelineRunStatus)
+	}{{
+		name:   "Stop",
+		action: v1alpha3.Stop,
+		assertion: func(prStatus *v1alpha3.PipelineRunStatus) {
+			assert.Equal(t, v1alpha3.Cancelled, prStatus.Phase)
+			assert.NotNil(t, prStatus.CompletionTime)
+			assert.NotNil(t, prStatus.UpdateTime)
+			assert.Equal(t, 1, len(prStatus.Conditions))
+			assert.Equal(t, v1alpha3.ConditionSucceeded, prStatus.Conditions[0].Type)
+			assert.Equal(t, v1alpha3.ConditionFalse, prStatus.Conditions[0].Status)
+		},
+	}, {
+		name:   "Pause",
+		action: v1alpha3.Pause,
+		assertion: func(prStatus *v1alpha3.PipelineRunStatus) {
+			assert.Equal(t, v1alpha3.Pending, prStatus.Phase)
+			assert.Nil(t, prStatus.CompletionTime)
+			assert.Equal(t, 1, len(prStatus.Conditions))
+			assert.Equal(t, v1alpha3.ConditionReady, prStatus.Conditions[0].Type)
+			assert.Equal(t, Paused.String(), prStatus.Conditions[0].Reason)
+		},
+	}, {
+		name:   "Resume",
+		action: v1alpha3.Resume,
+		assertion: func(prStatus *v1alpha3.PipelineRunStatus) {
+			assert.Equal(t, v1alpha3.Running, prStatus.Phase)
+			assert.Nil(t, prStatus.CompletionTime)
+			assert.Equal(t, 1, len(prStatus.Conditions))
+			assert.Equal(t, Running.String(), prStatus.Conditions[0].Reason)
+		},
+	}, {
+		name:   "Unknown action",
+		action: v1alpha3.Action("fake"),
+		assertion: func(prStatus *v1alpha3.PipelineRunStatus) {
+			assert.Equal(t, v1alpha3.RunPhase(""), prStatus.Phase)
+			assert.Nil(t, prStatus.UpdateTime)
+			assert.Equal(t, 0, len(prStatus.Conditions))
+		},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prStatus := &v1alpha3.PipelineRunStatus{}
+			actionApplier{tt.action}.apply(prStatus)
+			tt.assertion(prStatus)
+		})
+	}
+}
+
 func Test_parameterConverter_convert(t *testing.T) {
 	type fields struct {
 		parameters []v1alpha3.Parameter
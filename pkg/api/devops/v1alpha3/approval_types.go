@@ -0,0 +1,246 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha3
+
+import (
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ApprovalPhase is the phase of an Approval
+type ApprovalPhase string
+
+const (
+	// ApprovalPending means the Approval is waiting for the decisions
+	ApprovalPending ApprovalPhase = "Pending"
+	// ApprovalApproved means the Approval got enough approvals, and the input step proceeded
+	ApprovalApproved ApprovalPhase = "Approved"
+	// ApprovalRejected means the Approval was rejected, and the input step was aborted
+	ApprovalRejected ApprovalPhase = "Rejected"
+	// ApprovalTimedOut means the Approval did not get enough approvals before the timeout, and the input step was aborted
+	ApprovalTimedOut ApprovalPhase = "TimedOut"
+)
+
+// ApprovalDecisionType is the type of a decision
+// +kubebuilder:validation:Enum=Approve;Reject
+type ApprovalDecisionType string
+
+const (
+	// ApprovalDecisionApprove approves the input step
+	ApprovalDecisionApprove ApprovalDecisionType = "Approve"
+	// ApprovalDecisionReject rejects the input step
+	ApprovalDecisionReject ApprovalDecisionType = "Reject"
+)
+
+// Approvers are the users, groups or roles who are allowed to approve. Nobody is allowed if there are no approvers,
+// unless Anyone is set explicitly.
+type Approvers struct {
+	// Anyone allows everyone who is able to access the approval API to approve, the users and roles are ignored
+	// +optional
+	Anyone bool `json:"anyone,omitempty" description:"Allow everyone who is able to access the approval API to approve"`
+
+	// Users are the names of the users
+	// +optional
+	Users []string `json:"users,omitempty" description:"The names of the users"`
+
+	// Groups are the names of the groups, the users in these groups are allowed
+	// +optional
+	Groups []string `json:"groups,omitempty" description:"The names of the groups, the users in these groups are allowed"`
+
+	// Roles are the names of the Roles in the namespace, the users bound to these Roles are allowed
+	// +optional
+	Roles []string `json:"roles,omitempty" description:"The names of the Roles in the namespace, the users bound to these Roles are allowed"`
+
+	// ClusterRoles are the names of the ClusterRoles, the users bound to these ClusterRoles by the RoleBindings in the
+	// namespace are allowed
+	// +optional
+	ClusterRoles []string `json:"clusterRoles,omitempty" description:"The names of the ClusterRoles, the users bound to these ClusterRoles by the RoleBindings in the namespace are allowed"`
+}
+
+// HasRoles indicates if the RoleBindings are required to check the approvers
+func (a Approvers) HasRoles() bool {
+	return len(a.Roles) > 0 || len(a.ClusterRoles) > 0
+}
+
+// ApprovalPolicy describes the Approvals of the input steps of a Pipeline
+type ApprovalPolicy struct {
+	// Approvers are allowed to approve, the submitters of the input step are allowed as well
+	// +optional
+	Approvers Approvers `json:"approvers,omitempty" description:"The users or roles who are allowed to approve"`
+
+	// RequiredApprovals is the number of the approvals from different users to proceed, it's 1 by default
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	RequiredApprovals int `json:"requiredApprovals,omitempty" description:"The number of the approvals from different users to proceed, it's 1 by default"`
+
+	// Timeout is the duration to wait for the approvals, the input step is aborted once it timed out.
+	// It's unlimited if it is empty.
+	// +optional
+	Timeout *metav1.Duration `json:"timeout,omitempty" description:"The duration to wait for the approvals, the input step is aborted once it timed out"`
+}
+
+// ApprovalSpec defines the desired state of Approval
+type ApprovalSpec struct {
+	ApprovalPolicy `json:",inline"`
+
+	// PipelineRunRef is the PipelineRun which is waiting for the approval
+	PipelineRunRef v1.LocalObjectReference `json:"pipelineRunRef" description:"The PipelineRun which is waiting for the approval"`
+
+	// NodeID is the ID of the node which contains the input step
+	NodeID string `json:"nodeID" description:"The ID of the node which contains the input step"`
+
+	// StepID is the ID of the input step
+	StepID string `json:"stepID" description:"The ID of the input step"`
+
+	// InputID is the ID of the input, it's required by Jenkins to submit the input
+	// +optional
+	InputID string `json:"inputID,omitempty" description:"The ID of the input"`
+
+	// Message is the message of the input
+	// +optional
+	Message string `json:"message,omitempty" description:"The message of the input"`
+}
+
+// ApprovalDecision is a decision made by a user
+type ApprovalDecision struct {
+	// User is the name of the user who made the decision
+	User string `json:"user" description:"The name of the user who made the decision"`
+
+	// Decision is the type of the decision
+	Decision ApprovalDecisionType `json:"decision" description:"The type of the decision, could be Approve or Reject"`
+
+	// Time is when the decision was made
+	Time metav1.Time `json:"time" description:"When the decision was made"`
+
+	// Comment is the comment of the decision
+	// +optional
+	Comment string `json:"comment,omitempty" description:"The comment of the decision"`
+
+	// Parameters are the values of the input parameters
+	// +optional
+	Parameters []Parameter `json:"parameters,omitempty" description:"The values of the input parameters"`
+}
+
+// ApprovalStatus defines the observed state of Approval
+type ApprovalStatus struct {
+	// Phase is the phase of the Approval
+	// +optional
+	Phase ApprovalPhase `json:"phase,omitempty"`
+
+	// Decisions are the audit trail of the Approval, in the order of time
+	// +optional
+	Decisions []ApprovalDecision `json:"decisions,omitempty"`
+
+	// CompletionTime is when the Approval completed
+	// +optional
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+
+	// Submitted means the decision of a completed Approval was forwarded to Jenkins. The PipelineRun controller
+	// forwards it again until it succeeded.
+	// +optional
+	Submitted bool `json:"submitted,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="PipelineRun",type=string,JSONPath=`.spec.pipelineRunRef.name`,description="The PipelineRun which is waiting for the approval"
+// +kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`,description="The phase of an Approval"
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`,description="The age of an Approval"
+// +kubebuilder:resource:categories="devops"
+
+// Approval records the decisions of an input step of a PipelineRun
+type Approval struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ApprovalSpec   `json:"spec,omitempty"`
+	Status ApprovalStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// ApprovalList contains a list of Approval
+type ApprovalList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []Approval `json:"items"`
+}
+
+// GetRequiredApprovals returns the number of the approvals to proceed, it's at least 1
+func (a *Approval) GetRequiredApprovals() int {
+	if a.Spec.RequiredApprovals < 1 {
+		return 1
+	}
+	return a.Spec.RequiredApprovals
+}
+
+// HasCompleted indicates if the Approval has completed
+func (a *Approval) HasCompleted() bool {
+	return a.Status.Phase != "" && a.Status.Phase != ApprovalPending
+}
+
+// NeedsSubmission indicates if the Approval has completed, but the decision has not been forwarded to Jenkins yet
+func (a *Approval) NeedsSubmission() bool {
+	return a.HasCompleted() && !a.Status.Submitted
+}
+
+// GetSubmission returns if the input step should be aborted, and the parameters to proceed. The parameters are taken
+// from the last approval.
+func (a *Approval) GetSubmission() (abort bool, parameters []Parameter) {
+	if a.Status.Phase != ApprovalApproved {
+		return true, nil
+	}
+	for i := len(a.Status.Decisions) - 1; i >= 0; i-- {
+		if a.Status.Decisions[i].Decision == ApprovalDecisionApprove {
+			return false, a.Status.Decisions[i].Parameters
+		}
+	}
+	return false, nil
+}
+
+// HasTimedOut indicates if the Approval has timed out at the given time
+func (a *Approval) HasTimedOut(now time.Time) bool {
+	return a.Spec.Timeout != nil && a.Spec.Timeout.Duration > 0 && !a.CreationTimestamp.IsZero() &&
+		now.Sub(a.CreationTimestamp.Time) > a.Spec.Timeout.Duration
+}
+
+// CountApprovals returns the number of the users who approved
+func (a *Approval) CountApprovals() int {
+	users := map[string]bool{}
+	for _, decision := range a.Status.Decisions {
+		if decision.Decision == ApprovalDecisionApprove {
+			users[decision.User] = true
+		}
+	}
+	return len(users)
+}
+
+// HasDecided indicates if the user has made a decision on the Approval
+func (a *Approval) HasDecided(user string) bool {
+	for _, decision := range a.Status.Decisions {
+		if decision.User == user {
+			return true
+		}
+	}
+	return false
+}
+
+func init() {
+	SchemeBuilder.Register(&Approval{}, &ApprovalList{})
+}
@@ -0,0 +1,70 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha3
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestApproval(t *testing.T) {
+	creation := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	approval := &Approval{
+		ObjectMeta: v1.ObjectMeta{CreationTimestamp: v1.NewTime(creation)},
+	}
+	assert.Equal(t, 1, approval.GetRequiredApprovals())
+	assert.False(t, approval.HasCompleted())
+	assert.False(t, approval.HasTimedOut(creation.Add(time.Hour)))
+
+	approval.Spec.RequiredApprovals = 2
+	approval.Spec.Timeout = &v1.Duration{Duration: time.Hour}
+	approval.Status.Decisions = []ApprovalDecision{
+		{User: "alice", Decision: ApprovalDecisionApprove},
+		{User: "alice", Decision: ApprovalDecisionApprove},
+		{User: "bob", Decision: ApprovalDecisionReject},
+	}
+	assert.Equal(t, 2, approval.GetRequiredApprovals())
+	assert.Equal(t, 1, approval.CountApprovals())
+	assert.True(t, approval.HasDecided("bob"))
+	assert.False(t, approval.HasDecided("carol"))
+	assert.False(t, approval.HasTimedOut(creation.Add(time.Hour)))
+	assert.True(t, approval.HasTimedOut(creation.Add(time.Hour+time.Second)))
+	// the Approval has not been created yet
+	assert.False(t, (&Approval{Spec: approval.Spec}).HasTimedOut(creation.Add(2*time.Hour)))
+
+	approval.Status.Phase = ApprovalPending
+	assert.False(t, approval.HasCompleted())
+	approval.Status.Phase = ApprovalRejected
+	assert.True(t, approval.HasCompleted())
+	assert.True(t, approval.NeedsSubmission())
+	abort, parameters := approval.GetSubmission()
+	assert.True(t, abort)
+	assert.Nil(t, parameters)
+
+	// the parameters of the last approval are submitted
+	approval.Status.Phase = ApprovalApproved
+	approval.Status.Decisions[1].Parameters = []Parameter{{Name: "version", Value: "v1"}}
+	abort, parameters = approval.GetSubmission()
+	assert.False(t, abort)
+	assert.Equal(t, []Parameter{{Name: "version", Value: "v1"}}, parameters)
+
+	approval.Status.Submitted = true
+	assert.False(t, approval.NeedsSubmission())
+}
@@ -36,10 +36,58 @@ const (
 	PipelineNameLabelKey = devops.GroupName + "/pipeline"
 	// PipelineRunCreatorAnnoKey is annotation key of PipelineRun's creator
 	PipelineRunCreatorAnnoKey = devops.GroupName + "/creator"
+	// PipelineRunActionAnnoKey is annotation key of the last Action which has been applied to a PipelineRun.
+	PipelineRunActionAnnoKey = devops.GroupName + "/pipelinerun-action"
+	// PipelineExecutorAnnoKey is annotation key of the executor which runs PipelineRuns.
+	// It could be set on a Pipeline or DevOpsProject, and it will be recorded on a PipelineRun once it started.
+	PipelineExecutorAnnoKey = devops.GroupName + "/executor"
+	// PipelineRunNameLabelKey is label key of PipelineRun name.
+	PipelineRunNameLabelKey = devops.GroupName + "/pipelinerun"
+	// PipelineRunStepLabelKey is label key of the step index of a PipelineRun.
+	PipelineRunStepLabelKey = devops.GroupName + "/pipelinerun-step"
+	// PipelineRunAttemptAnnoKey is annotation key of the attempt number of a retried PipelineRun, the first attempt is 1.
+	PipelineRunAttemptAnnoKey = devops.GroupName + "/retry-attempt"
+	// PipelineRunRetryParentAnnoKey is annotation key of the PipelineRun which is retried by the current one.
+	PipelineRunRetryParentAnnoKey = devops.GroupName + "/retry-parent"
+	// PipelineRunRetryAfterAnnoKey is annotation key of the time in RFC3339 format before which the retry won't start.
+	PipelineRunRetryAfterAnnoKey = devops.GroupName + "/retry-after"
+	// PipelineRunKeepForeverLabelKey is label key of the PipelineRuns which are never pruned by the retention policy.
+	PipelineRunKeepForeverLabelKey = devops.GroupName + "/keep-forever"
+	// PipelinePrunedJenkinsRunsAnnoKey is annotation key of the Jenkins runs whose PipelineRuns were pruned by the
+	// retention policy but the Jenkins records were kept. The value is a JSON object of the highest pruned run number
+	// of each branch, and the synchronization skips the runs up to it.
+	PipelinePrunedJenkinsRunsAnnoKey = devops.GroupName + "/pruned-jenkins-runs"
+	// PipelineRunLastEventAnnoKey is annotation key of the last Jenkins event of a PipelineRun, the value is the event
+	// type and the receiving time. The running data is retrieved from Jenkins once it changed.
+	PipelineRunLastEventAnnoKey = devops.GroupName + "/jenkins-last-event"
+	// PipelineRunTestReportPendingAnnoKey is annotation key of the completed PipelineRuns whose test reports were not
+	// collected yet, the collection is retried until it succeeded.
+	PipelineRunTestReportPendingAnnoKey = devops.GroupName + "/test-report-pending"
+	// DeploymentSelectorAnnoKey is annotation key of the label selector of the PipelineRuns which are counted as
+	// deployments by the delivery analytics, it could be set on a DevOpsProject.
+	DeploymentSelectorAnnoKey = devops.GroupName + "/deployment-selector"
+	// PipelineRunScheduledTimeAnnoKey is annotation key of the scheduled time in RFC3339 format of a PipelineRun which
+	// was created by the schedule of its Pipeline.
+	PipelineRunScheduledTimeAnnoKey = devops.GroupName + "/scheduled-time"
+	// PipelineRunMatrixParentLabelKey is label key of the matrix PipelineRun which created the current one for a
+	// combination of its matrix.
+	PipelineRunMatrixParentLabelKey = devops.GroupName + "/matrix-parent"
+	// PipelineRunMatrixIndexAnnoKey is annotation key of the index of the matrix combination which the PipelineRun runs.
+	PipelineRunMatrixIndexAnnoKey = devops.GroupName + "/matrix-index"
+	// PipelineRunUpstreamLabelKey is label key of the UID of the upstream PipelineRun which triggered the current one.
+	PipelineRunUpstreamLabelKey = devops.GroupName + "/upstream-uid"
+	// PipelineRunDownstreamTriggeredAnnoKey is annotation key of the completed PipelineRuns whose downstream Pipelines
+	// were handled, so they are never handled again when the controller restarts.
+	PipelineRunDownstreamTriggeredAnnoKey = devops.GroupName + "/downstream-triggered"
+	// PipelineRevisionLabelKey is label key of the revision number of a Pipeline, it's set on the PipelineRevisions and
+	// the PipelineRuns which ran the revision.
+	PipelineRevisionLabelKey = devops.GroupName + "/pipeline-revision"
 	// PipelineRunSCMRefNameField is the field name of SCM reference name in PipelineRun spec.
 	PipelineRunSCMRefNameField = "spec.scm.ref-name"
 	// PipelineRunIdentifierIndexerName is an indexer name of PipelineRun identifier.
 	PipelineRunIdentifierIndexerName = "pipelinerun.identifier"
+	// PipelineUpstreamIndexerName is an indexer name of the upstream Pipelines of a Pipeline.
+	PipelineUpstreamIndexerName = "pipeline.upstream"
 
 	JenkinsAgentPodNameAnnoKey  = devops.GroupName + "/agent-pod-name"
 	JenkinsAgentNodeNameAnnoKey = devops.GroupName + "/agent-node-name"
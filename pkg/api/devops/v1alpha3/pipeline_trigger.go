@@ -0,0 +1,213 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha3
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// MaxUpstreamDepth limits the length of a chain of the PipelineRuns which were triggered by the upstream triggers
+const MaxUpstreamDepth = 10
+
+// UpstreamTrigger runs the Pipeline when a PipelineRun of another Pipeline completed
+type UpstreamTrigger struct {
+	// Namespace is the DevOpsProject of the upstream Pipeline, it's the same as the current one by default. The upstream
+	// Pipeline in another DevOpsProject must allow the current one through its downstream namespaces annotation
+	// +optional
+	Namespace string `json:"namespace,omitempty" description:"The DevOpsProject of the upstream Pipeline, it's the same as the current one by default. The upstream Pipeline in another DevOpsProject must allow the current one"`
+
+	// Pipeline is the name of the upstream Pipeline
+	Pipeline string `json:"pipeline" description:"The name of the upstream Pipeline"`
+
+	// Phases are the phases of the upstream PipelineRuns which trigger the Pipeline, it's Succeeded by default
+	// +optional
+	Phases []RunPhase `json:"phases,omitempty" description:"The phases of the upstream PipelineRuns which trigger the Pipeline, it's Succeeded by default"`
+
+	// Branch is the SCM reference name of the upstream PipelineRuns, all of them trigger the Pipeline if it's empty
+	// +optional
+	Branch string `json:"branch,omitempty" description:"The SCM reference name of the upstream PipelineRuns, all of them trigger the Pipeline if it's empty"`
+
+	// PassParameters passes all the parameters of the upstream PipelineRun to the triggered one
+	// +optional
+	PassParameters bool `json:"passParameters,omitempty" description:"Pass all the parameters of the upstream PipelineRun"`
+
+	// Parameters are passed to the triggered PipelineRuns, they take precedence over the passed parameters. The values
+	// could refer to the upstream PipelineRun, like $(upstream.pipelinerun) or $(upstream.params.version).
+	// +optional
+	Parameters []Parameter `json:"parameters,omitempty" description:"The parameters of the triggered PipelineRuns, the values could refer to the upstream PipelineRun like $(upstream.params.version)"`
+
+	// SCM is the SCM reference of the triggered PipelineRuns. A multi-branch Pipeline runs the same branch as the
+	// upstream PipelineRun by default.
+	// +optional
+	SCM *SCM `json:"scm,omitempty" description:"The SCM reference of the triggered PipelineRuns, a multi-branch Pipeline runs the upstream branch by default"`
+}
+
+// UpstreamReference refers to the PipelineRun which triggered the current one
+type UpstreamReference struct {
+	// Namespace is the namespace of the upstream PipelineRun
+	Namespace string `json:"namespace" description:"The namespace of the upstream PipelineRun"`
+
+	// Name is the name of the upstream PipelineRun
+	Name string `json:"name" description:"The name of the upstream PipelineRun"`
+
+	// Pipeline is the name of the upstream Pipeline
+	// +optional
+	Pipeline string `json:"pipeline,omitempty" description:"The name of the upstream Pipeline"`
+
+	// UID is the UID of the upstream PipelineRun
+	// +optional
+	UID types.UID `json:"uid,omitempty" description:"The UID of the upstream PipelineRun"`
+}
+
+// GetUpstreamKey returns the key of an upstream Pipeline for indexing the downstream Pipelines
+func GetUpstreamKey(namespace, pipeline string) string {
+	return namespace + "/" + pipeline
+}
+
+// GetUpstreamKeys returns the keys of the upstream Pipelines of the Pipeline
+func (p *Pipeline) GetUpstreamKeys() (keys []string) {
+	for i := range p.Spec.UpstreamTriggers {
+		key := GetUpstreamKey(p.Spec.UpstreamTriggers[i].GetNamespace(p.Namespace), p.Spec.UpstreamTriggers[i].Pipeline)
+		if !containsString(keys, key) {
+			keys = append(keys, key)
+		}
+	}
+	return
+}
+
+// AllowsDownstream returns true if the Pipeline is allowed to trigger the Pipelines in the given namespace
+func (p *Pipeline) AllowsDownstream(namespace string) bool {
+	if namespace == p.Namespace {
+		return true
+	} else if namespace == "" {
+		return false
+	}
+	for _, allowed := range strings.Split(p.Annotations[PipelineDownstreamNamespacesAnnoKey], ",") {
+		if strings.TrimSpace(allowed) == namespace {
+			return true
+		}
+	}
+	return false
+}
+
+// GetNamespace returns the namespace of the upstream Pipeline
+func (t *UpstreamTrigger) GetNamespace(namespace string) string {
+	if t.Namespace != "" {
+		return t.Namespace
+	}
+	return namespace
+}
+
+// Matches returns true if the completed upstream PipelineRun should trigger the Pipeline in the given namespace
+func (t *UpstreamTrigger) Matches(namespace string, upstream *PipelineRun) bool {
+	if !upstream.HasCompleted() || upstream.Namespace != t.GetNamespace(namespace) ||
+		upstream.GetPipelineName() != t.Pipeline || (t.Branch != "" && upstream.GetRefName() != t.Branch) {
+		return false
+	}
+	if len(t.Phases) == 0 {
+		return upstream.Status.Phase == Succeeded
+	}
+	for _, phase := range t.Phases {
+		if phase == upstream.Status.Phase {
+			return true
+		}
+	}
+	return false
+}
+
+var upstreamReferencePattern = regexp.MustCompile(`\$\(upstream\.([A-Za-z0-9_.\-]+)\)`)
+
+// GetParameters returns the parameters of the PipelineRun triggered by the upstream PipelineRun
+func (t *UpstreamTrigger) GetParameters(upstream *PipelineRun) (parameters []Parameter) {
+	if t.PassParameters {
+		parameters = append(parameters, upstream.Spec.Parameters...)
+	}
+	for _, parameter := range t.Parameters {
+		value := upstreamReferencePattern.ReplaceAllStringFunc(parameter.Value, func(ref string) string {
+			resolved, ok := resolveUpstreamReference(upstream, upstreamReferencePattern.FindStringSubmatch(ref)[1])
+			if !ok {
+				return ref
+			}
+			return resolved
+		})
+		overridden := false
+		for i := range parameters {
+			if overridden = parameters[i].Name == parameter.Name; overridden {
+				parameters[i].Value = value
+				break
+			}
+		}
+		if !overridden {
+			parameters = append(parameters, Parameter{Name: parameter.Name, Value: value})
+		}
+	}
+	return
+}
+
+// resolveUpstreamReference returns the value of a reference like pipelinerun or params.version, an absent parameter
+// is resolved as empty
+func resolveUpstreamReference(upstream *PipelineRun, ref string) (string, bool) {
+	if name := strings.TrimPrefix(ref, "params."); name != ref {
+		for _, parameter := range upstream.Spec.Parameters {
+			if parameter.Name == name {
+				return parameter.Value, true
+			}
+		}
+		return "", true
+	}
+	switch ref {
+	case "namespace":
+		return upstream.Namespace, true
+	case "pipeline":
+		return upstream.GetPipelineName(), true
+	case "pipelinerun":
+		return upstream.Name, true
+	case "branch":
+		return upstream.GetRefName(), true
+	case "phase":
+		return string(upstream.Status.Phase), true
+	}
+	return "", false
+}
+
+// NewUpstreamReference returns the reference to an upstream PipelineRun
+func NewUpstreamReference(upstream *PipelineRun) *UpstreamReference {
+	return &UpstreamReference{
+		Namespace: upstream.Namespace,
+		Name:      upstream.Name,
+		Pipeline:  upstream.GetPipelineName(),
+		UID:       upstream.UID,
+	}
+}
+
+// String returns the namespaced name of the upstream PipelineRun
+func (r *UpstreamReference) String() string {
+	return fmt.Sprintf("%s/%s", r.Namespace, r.Name)
+}
+
+func containsString(items []string, item string) bool {
+	for _, s := range items {
+		if s == item {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,171 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha3
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func newUpstreamPipelineRun(phase RunPhase, branch string) *PipelineRun {
+	now := metav1.Now()
+	pr := &PipelineRun{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "team-a",
+			Name:      "build-x7k2p",
+			UID:       "uid",
+			Labels:    map[string]string{PipelineNameLabelKey: "build"},
+		},
+		Spec: PipelineRunSpec{
+			PipelineRef: &v1.ObjectReference{Name: "build"},
+			Parameters:  []Parameter{{Name: "version", Value: "v1.0"}, {Name: "debug", Value: "true"}},
+		},
+		Status: PipelineRunStatus{Phase: phase, CompletionTime: &now},
+	}
+	if branch != "" {
+		pr.Spec.PipelineSpec = &PipelineSpec{Type: MultiBranchPipelineType}
+		pr.Spec.SCM = &SCM{RefName: branch}
+	}
+	return pr
+}
+
+func TestUpstreamTrigger_Matches(t *testing.T) {
+	running := newUpstreamPipelineRun(Running, "")
+	running.Status.CompletionTime = nil
+
+	tests := []struct {
+		name      string
+		trigger   UpstreamTrigger
+		namespace string
+		upstream  *PipelineRun
+		want      bool
+	}{{
+		name:      "succeeded by default",
+		trigger:   UpstreamTrigger{Pipeline: "build"},
+		namespace: "team-a",
+		upstream:  newUpstreamPipelineRun(Succeeded, ""),
+		want:      true,
+	}, {
+		name:      "failed by default",
+		trigger:   UpstreamTrigger{Pipeline: "build"},
+		namespace: "team-a",
+		upstream:  newUpstreamPipelineRun(Failed, ""),
+	}, {
+		name:      "the given phases",
+		trigger:   UpstreamTrigger{Pipeline: "build", Phases: []RunPhase{Failed, Cancelled}},
+		namespace: "team-a",
+		upstream:  newUpstreamPipelineRun(Failed, ""),
+		want:      true,
+	}, {
+		name:      "not completed",
+		trigger:   UpstreamTrigger{Pipeline: "build", Phases: []RunPhase{Running}},
+		namespace: "team-a",
+		upstream:  running,
+	}, {
+		name:      "another namespace",
+		trigger:   UpstreamTrigger{Pipeline: "build"},
+		namespace: "team-b",
+		upstream:  newUpstreamPipelineRun(Succeeded, ""),
+	}, {
+		name:      "the given namespace",
+		trigger:   UpstreamTrigger{Namespace: "team-a", Pipeline: "build"},
+		namespace: "team-b",
+		upstream:  newUpstreamPipelineRun(Succeeded, ""),
+		want:      true,
+	}, {
+		name:      "another Pipeline",
+		trigger:   UpstreamTrigger{Pipeline: "test"},
+		namespace: "team-a",
+		upstream:  newUpstreamPipelineRun(Succeeded, ""),
+	}, {
+		name:      "the given branch",
+		trigger:   UpstreamTrigger{Pipeline: "build", Branch: "main"},
+		namespace: "team-a",
+		upstream:  newUpstreamPipelineRun(Succeeded, "main"),
+		want:      true,
+	}, {
+		name:      "another branch",
+		trigger:   UpstreamTrigger{Pipeline: "build", Branch: "main"},
+		namespace: "team-a",
+		upstream:  newUpstreamPipelineRun(Succeeded, "dev"),
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.trigger.Matches(tt.namespace, tt.upstream))
+		})
+	}
+}
+
+func TestUpstreamTrigger_GetParameters(t *testing.T) {
+	upstream := newUpstreamPipelineRun(Succeeded, "main")
+
+	trigger := &UpstreamTrigger{Parameters: []Parameter{
+		{Name: "image", Value: "app:$(upstream.params.version)-$(upstream.branch)"},
+		{Name: "source", Value: "$(upstream.namespace)/$(upstream.pipeline)/$(upstream.pipelinerun) $(upstream.phase)"},
+		{Name: "absent", Value: "$(upstream.params.absent)"},
+		{Name: "unknown", Value: "$(upstream.unknown) $(params.version)"},
+	}}
+	assert.Equal(t, []Parameter{
+		{Name: "image", Value: "app:v1.0-main"},
+		{Name: "source", Value: "team-a/build/build-x7k2p Succeeded"},
+		{Name: "absent", Value: ""},
+		{Name: "unknown", Value: "$(upstream.unknown) $(params.version)"},
+	}, trigger.GetParameters(upstream))
+
+	trigger = &UpstreamTrigger{PassParameters: true, Parameters: []Parameter{{Name: "debug", Value: "false"}, {Name: "env", Value: "staging"}}}
+	assert.Equal(t, []Parameter{
+		{Name: "version", Value: "v1.0"},
+		{Name: "debug", Value: "false"},
+		{Name: "env", Value: "staging"},
+	}, trigger.GetParameters(upstream))
+	// the parameters of the upstream PipelineRun are not changed
+	assert.Equal(t, "true", upstream.Spec.Parameters[1].Value)
+}
+
+func TestPipeline_GetUpstreamKeys(t *testing.T) {
+	pipeline := &Pipeline{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "team-b", Name: "deploy"},
+		Spec: PipelineSpec{UpstreamTriggers: []UpstreamTrigger{
+			{Pipeline: "build"},
+			{Pipeline: "build", Phases: []RunPhase{Failed}},
+			{Namespace: "team-a", Pipeline: "build"},
+		}},
+	}
+	assert.Equal(t, []string{"team-b/build", "team-a/build"}, pipeline.GetUpstreamKeys())
+	assert.Nil(t, (&Pipeline{}).GetUpstreamKeys())
+}
+
+func TestPipeline_AllowsDownstream(t *testing.T) {
+	pipeline := &Pipeline{ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "build"}}
+	assert.True(t, pipeline.AllowsDownstream("team-a"))
+	assert.False(t, pipeline.AllowsDownstream("team-b"))
+	assert.False(t, pipeline.AllowsDownstream(""))
+
+	pipeline.Annotations = map[string]string{PipelineDownstreamNamespacesAnnoKey: "team-b, team-c"}
+	assert.True(t, pipeline.AllowsDownstream("team-b"))
+	assert.True(t, pipeline.AllowsDownstream("team-c"))
+	assert.False(t, pipeline.AllowsDownstream("team-d"))
+}
+
+func TestPipelineRun_GetPipelineName(t *testing.T) {
+	assert.Equal(t, "build", newUpstreamPipelineRun(Succeeded, "").GetPipelineName())
+	assert.Equal(t, "build", (&PipelineRun{Spec: PipelineRunSpec{PipelineRef: &v1.ObjectReference{Name: "build"}}}).GetPipelineName())
+	assert.Equal(t, "", (&PipelineRun{}).GetPipelineName())
+}
@@ -17,9 +17,16 @@ limitations under the License.
 package v1alpha3
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"strconv"
 	"strings"
+	"time"
 
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -49,6 +56,28 @@ const (
 	PipelineJenkinsfileEditModeAnnoKey = PipelinePrefix + "jenkinsfile.edit.mode"
 	// PipelineJenkinsfileValidateAnnoKey is the annotation key of the Jenkinsfile validate, success or failure
 	PipelineJenkinsfileValidateAnnoKey = PipelinePrefix + "jenkinsfile.validate"
+	// PipelineJenkinsfileValidateMessageAnnoKey is the annotation key of the reason why the Jenkinsfile is invalid
+	PipelineJenkinsfileValidateMessageAnnoKey = PipelinePrefix + "jenkinsfile.validate.message"
+	// PipelineSpecAuthorAnnoKey is the annotation key of the user who made the last change of the Pipeline spec through
+	// the API, it's recorded as the author of the next PipelineRevision
+	PipelineSpecAuthorAnnoKey = PipelinePrefix + "spec-author"
+	// PipelineSpecChangeCauseAnnoKey is the annotation key of the cause of the last change of the Pipeline spec, like
+	// a rollback. It's recorded as the message of the next PipelineRevision
+	PipelineSpecChangeCauseAnnoKey = PipelinePrefix + "spec-change-cause"
+	// PipelineSpecAuthorHashAnnoKey is the annotation key of the spec hash which the author and the cause belong to.
+	// They are ignored once the spec was changed by others, like kubectl, without setting them again
+	PipelineSpecAuthorHashAnnoKey = PipelinePrefix + "spec-author-hash"
+	// PipelineDownstreamNamespacesAnnoKey is the annotation key of the comma-separated DevOpsProjects whose Pipelines are
+	// allowed to be triggered by the Pipeline through their upstream triggers. The Pipelines in the same DevOpsProject are
+	// always allowed
+	PipelineDownstreamNamespacesAnnoKey = PipelinePrefix + "downstream-namespaces"
+	// PipelineSourceCommitAnnoKey is the annotation key of the commit which the Git-managed Pipeline is synchronized from
+	PipelineSourceCommitAnnoKey = PipelinePrefix + "source-commit"
+	// PipelineSourceSyncTimeAnnoKey is the annotation key of the last time when the Git-managed Pipeline was synchronized
+	PipelineSourceSyncTimeAnnoKey = PipelinePrefix + "source-synctime"
+	// PipelineRequestToSyncSourceAnnoKey is the annotation key of requesting to synchronize the Git-managed Pipeline,
+	// it's set by the SCM webhook once there are new commits pushed
+	PipelineRequestToSyncSourceAnnoKey = PipelinePrefix + "request-to-sync-source"
 
 	// PipelineJenkinsfileEditModeJSON indicates the Jenkinsfile editing mode is JSON
 	PipelineJenkinsfileEditModeJSON = "json"
@@ -66,12 +95,292 @@ type PipelineSpec struct {
 	Type                PipelineType         `json:"type" description:"type of devops pipeline, in scm or no scm"`
 	Pipeline            *NoScmPipeline       `json:"pipeline,omitempty" description:"no scm pipeline structs"`
 	MultiBranchPipeline *MultiBranchPipeline `json:"multi_branch_pipeline,omitempty" description:"in scm pipeline structs"`
+	ConcurrencyPolicy   *ConcurrencyPolicy   `json:"concurrencyPolicy,omitempty" description:"The policy of running the PipelineRuns concurrently, all of them are allowed by default"`
+	RetryPolicy         *RetryPolicy         `json:"retryPolicy,omitempty" description:"The policy of retrying the failed PipelineRuns, they are not retried by default"`
+	RetentionPolicy     *RetentionPolicy     `json:"retentionPolicy,omitempty" description:"The policy of pruning the completed PipelineRuns, nothing is pruned if it is empty"`
+	Schedule            *Schedule            `json:"schedule,omitempty" description:"The schedule of creating PipelineRuns, it falls back to the cron of the timer trigger if it is empty"`
+	ApprovalPolicy      *ApprovalPolicy      `json:"approvalPolicy,omitempty" description:"The policy of approving the input steps, the submitters of the input steps are allowed by default"`
+	Source              *PipelineSource      `json:"source,omitempty" description:"The Git source of the Pipeline definition, the Pipeline is managed by Git if it is set"`
+	UpstreamTriggers    []UpstreamTrigger    `json:"upstreamTriggers,omitempty" description:"Run the Pipeline when a PipelineRun of another Pipeline completed"`
+}
+
+// ConcurrencyPolicyType describes how to treat a new PipelineRun when there are other PipelineRuns not completed
+// +kubebuilder:validation:Enum=Allow;Forbid;Replace;Queue
+type ConcurrencyPolicyType string
+
+const (
+	// ConcurrencyAllow runs the PipelineRuns concurrently
+	ConcurrencyAllow ConcurrencyPolicyType = "Allow"
+	// ConcurrencyForbid cancels the new PipelineRun if there are other PipelineRuns not completed
+	ConcurrencyForbid ConcurrencyPolicyType = "Forbid"
+	// ConcurrencyReplace stops the PipelineRuns not completed, then runs the new one
+	ConcurrencyReplace ConcurrencyPolicyType = "Replace"
+	// ConcurrencyQueue holds the new PipelineRun in a queue until the previous PipelineRuns completed
+	ConcurrencyQueue ConcurrencyPolicyType = "Queue"
+)
+
+// ConcurrencyPolicy limits the concurrent PipelineRuns of a Pipeline. The PipelineRuns are limited in groups, all the
+// PipelineRuns of a Pipeline are in the same group by default.
+type ConcurrencyPolicy struct {
+	// Type is the type of the policy
+	Type ConcurrencyPolicyType `json:"type" description:"The type of the policy, could be Allow, Forbid, Replace or Queue"`
+
+	// MaxQueueDepth is the max number of the queued PipelineRuns in a group, it's unlimited if it is zero.
+	// The new PipelineRun will be cancelled once the queue is full. It's only for the Queue policy.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MaxQueueDepth int `json:"maxQueueDepth,omitempty" description:"The max number of the queued PipelineRuns, it's unlimited if it is zero"`
+
+	// KeyByBranch groups the PipelineRuns by the SCM reference name
+	// +optional
+	KeyByBranch bool `json:"keyByBranch,omitempty" description:"Group the PipelineRuns by the SCM reference name"`
+
+	// KeyByParameters groups the PipelineRuns by the values of these parameters
+	// +optional
+	KeyByParameters []string `json:"keyByParameters,omitempty" description:"Group the PipelineRuns by the values of these parameters"`
+}
+
+// GetConcurrencyPolicy returns the concurrency policy of the Pipeline. The PipelineRuns are queued if the concurrent
+// builds were disabled but there is no policy.
+func (spec *PipelineSpec) GetConcurrencyPolicy() ConcurrencyPolicy {
+	if spec.ConcurrencyPolicy != nil && spec.ConcurrencyPolicy.Type != "" {
+		return *spec.ConcurrencyPolicy
+	}
+	if spec.Pipeline != nil && spec.Pipeline.DisableConcurrent {
+		return ConcurrencyPolicy{Type: ConcurrencyQueue}
+	}
+	return ConcurrencyPolicy{Type: ConcurrencyAllow}
+}
+
+// RetryPolicy describes how to retry a failed PipelineRun. A retry is a new PipelineRun which has the same spec as
+// the failed one, it is linked to the failed one by annotations.
+type RetryPolicy struct {
+	// MaxAttempts is the max number of the attempts, including the first one
+	// +kubebuilder:validation:Minimum=1
+	MaxAttempts int `json:"maxAttempts" description:"The max number of the attempts, including the first one"`
+
+	// Backoff is the delay before the first retry, it is doubled for each of the following retries
+	// +optional
+	Backoff *metav1.Duration `json:"backoff,omitempty" description:"The delay before the first retry, it is doubled for each of the following retries"`
+
+	// Reasons are the results of the failed PipelineRuns which should be retried, such as FAILURE, UNSTABLE or ABORTED.
+	// All the failed PipelineRuns are retried if it is empty.
+	// +optional
+	Reasons []string `json:"reasons,omitempty" description:"The results of the failed PipelineRuns which should be retried, such as FAILURE, UNSTABLE or ABORTED"`
+
+	// Stages are the names of the stages, the failed PipelineRuns are retried only if one of these stages failed.
+	// All the failed PipelineRuns are retried if it is empty.
+	// +optional
+	Stages []string `json:"stages,omitempty" description:"The names of the stages, retry only if one of these stages failed"`
+}
+
+// RetentionPolicy decides which completed PipelineRuns should be kept. The PipelineRuns which have the keep-forever
+// label are always kept, and they are not counted.
+type RetentionPolicy struct {
+	// MaxCount is the max number of the completed PipelineRuns to keep, it's unlimited if it is zero
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MaxCount int `json:"maxCount,omitempty" description:"The max number of the completed PipelineRuns to keep, it's unlimited if it is zero"`
+
+	// MaxAge is the max duration to keep a completed PipelineRun since it completed, it's unlimited if it is empty
+	// +optional
+	MaxAge *metav1.Duration `json:"maxAge,omitempty" description:"The max duration to keep a completed PipelineRun since it completed"`
+
+	// Phases are the phases of the PipelineRuns which could be pruned, all the completed phases are included if it
+	// is empty
+	// +optional
+	Phases []RunPhase `json:"phases,omitempty" description:"The phases of the PipelineRuns which could be pruned, all the completed phases are included if it is empty"`
+
+	// PerBranch applies the MaxCount to each SCM reference of a multi-branch Pipeline
+	// +optional
+	PerBranch bool `json:"perBranch,omitempty" description:"Apply the max count to each SCM reference of a multi-branch Pipeline"`
+
+	// KeepJenkinsRecords keeps the Jenkins builds of the pruned PipelineRuns
+	// +optional
+	KeepJenkinsRecords bool `json:"keepJenkinsRecords,omitempty" description:"Keep the Jenkins builds of the pruned PipelineRuns"`
+
+	// FromDiscarder takes the MaxCount and MaxAge from the discarder of the Pipeline if they are not set
+	// +optional
+	FromDiscarder bool `json:"fromDiscarder,omitempty" description:"Take the max count and the max age from the discarder of the Pipeline if they are not set"`
+}
+
+// GetRetentionPolicy returns the retention policy of the Pipeline, nil means keeping all the PipelineRuns. The max
+// count and the max age are converted from the discarder only if the policy opts in with FromDiscarder.
+func (spec *PipelineSpec) GetRetentionPolicy() *RetentionPolicy {
+	if spec.RetentionPolicy == nil || !spec.RetentionPolicy.FromDiscarder {
+		return spec.RetentionPolicy
+	}
+
+	var discarder *DiscarderProperty
+	if spec.Pipeline != nil {
+		discarder = spec.Pipeline.Discarder
+	} else if spec.MultiBranchPipeline != nil {
+		discarder = spec.MultiBranchPipeline.Discarder
+	}
+
+	policy := spec.RetentionPolicy.DeepCopy()
+	if discarder != nil {
+		// the negative or invalid values mean unlimited in Jenkins
+		if num, err := strconv.Atoi(discarder.NumToKeep); err == nil && num > 0 && policy.MaxCount == 0 {
+			policy.MaxCount = num
+		}
+		if days, err := strconv.Atoi(discarder.DaysToKeep); err == nil && days > 0 && policy.MaxAge == nil {
+			policy.MaxAge = &metav1.Duration{Duration: time.Duration(days) * 24 * time.Hour}
+		}
+		// Jenkins discards the builds of each branch separately
+		policy.PerBranch = policy.PerBranch || spec.MultiBranchPipeline != nil
+	}
+	if policy.MaxCount == 0 && policy.MaxAge == nil {
+		return nil
+	}
+	return policy
+}
+
+// MissedRunPolicyType describes how to treat the scheduled times which were missed, for example, the controller was
+// down or the schedule was suspended
+// +kubebuilder:validation:Enum=Skip;RunOnce;RunAll
+type MissedRunPolicyType string
+
+const (
+	// MissedRunSkip skips all the missed times, only the on-time runs are created
+	MissedRunSkip MissedRunPolicyType = "Skip"
+	// MissedRunOnce creates one PipelineRun for the latest missed time
+	MissedRunOnce MissedRunPolicyType = "RunOnce"
+	// MissedRunAll creates PipelineRuns for all the missed times
+	MissedRunAll MissedRunPolicyType = "RunAll"
+)
+
+// Schedule creates the PipelineRuns of a Pipeline periodically. The PipelineRuns are created in Kubernetes instead of
+// Jenkins, so they follow the concurrency policy of the Pipeline.
+type Schedule struct {
+	// Cron is the schedule in the cron format, the Jenkins syntax like H, H/15 and @midnight is supported
+	Cron string `json:"cron" description:"The schedule in the cron format, the Jenkins syntax like H, H/15 and @midnight is supported"`
+
+	// TimeZone is the IANA name of the time zone of the schedule, such as Asia/Shanghai. It's UTC by default.
+	// +optional
+	TimeZone string `json:"timeZone,omitempty" description:"The IANA name of the time zone of the schedule, it's UTC by default"`
+
+	// StartingDeadlineSeconds is the deadline in seconds for starting a PipelineRun if it missed the scheduled time.
+	// The PipelineRuns which missed the deadline are skipped.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	StartingDeadlineSeconds *int64 `json:"startingDeadlineSeconds,omitempty" description:"The deadline in seconds for starting a PipelineRun if it missed the scheduled time"`
+
+	// MissedRunPolicy decides how to treat the missed scheduled times, it's RunOnce by default
+	// +optional
+	MissedRunPolicy MissedRunPolicyType `json:"missedRunPolicy,omitempty" description:"How to treat the missed scheduled times, could be Skip, RunOnce or RunAll"`
+
+	// Suspend stops creating the PipelineRuns, it does not apply to the PipelineRuns which were created
+	// +optional
+	Suspend bool `json:"suspend,omitempty" description:"Stop creating the PipelineRuns"`
+
+	// Parameters are passed to the scheduled PipelineRuns
+	// +optional
+	Parameters []Parameter `json:"parameters,omitempty" description:"The parameters of the scheduled PipelineRuns"`
+
+	// SCM is the SCM reference of the scheduled PipelineRuns, it's required by a multi-branch Pipeline
+	// +optional
+	SCM *SCM `json:"scm,omitempty" description:"The SCM reference of the scheduled PipelineRuns, it's required by a multi-branch Pipeline"`
+}
+
+// GetSchedule returns the schedule of the Pipeline. It is converted from the cron of the timer trigger if there is no
+// schedule, nil means the Pipeline is not scheduled.
+func (spec *PipelineSpec) GetSchedule() *Schedule {
+	if spec.Schedule != nil {
+		return spec.Schedule
+	}
+	if spec.Pipeline != nil && spec.Pipeline.TimerTrigger != nil && strings.TrimSpace(spec.Pipeline.TimerTrigger.Cron) != "" {
+		return &Schedule{Cron: spec.Pipeline.TimerTrigger.Cron}
+	}
+	return nil
+}
+
+// PipelineSource is the Git source of the Pipeline definition. The definition is pulled from a GitRepository when new
+// commits are pushed, or periodically. The Pipeline can't be edited through the API while it is managed by Git.
+type PipelineSource struct {
+	// GitRepository is the name of the GitRepository in the namespace of the Pipeline
+	GitRepository string `json:"gitRepository" description:"The name of the GitRepository in the namespace of the Pipeline"`
+
+	// Ref is the branch or tag of the definition, it's the default branch of the repository by default
+	// +optional
+	Ref string `json:"ref,omitempty" description:"The branch or tag of the definition, it's the default branch by default"`
+
+	// Path is the path of the definition in the repository. It's a Pipeline manifest, such as .kubesphere/pipeline.yaml,
+	// if it ends with .yaml or .yml, otherwise it's a Jenkinsfile.
+	// +kubebuilder:validation:MinLength=1
+	Path string `json:"path" description:"The path of the definition, it's a Pipeline manifest if it ends with .yaml or .yml, otherwise it's a Jenkinsfile"`
+
+	// Interval is the interval of pulling the definition, it's only pulled on the SCM webhook events if it is empty
+	// +optional
+	Interval *metav1.Duration `json:"interval,omitempty" description:"The interval of pulling the definition, it's only pulled on the SCM webhook events if it is empty"`
+}
+
+// IsManifest returns true if the source is a Pipeline manifest instead of a Jenkinsfile
+func (s *PipelineSource) IsManifest() bool {
+	return strings.HasSuffix(s.Path, ".yaml") || strings.HasSuffix(s.Path, ".yml")
+}
+
+// IsGitManaged returns true if the definition of the Pipeline is synchronized from Git
+func (p *Pipeline) IsGitManaged() bool {
+	return p != nil && p.Spec.Source != nil
+}
+
+// HasSameDefinition returns true if the specs are the same except the Git source
+func (spec *PipelineSpec) HasSameDefinition(other *PipelineSpec) bool {
+	a, b := spec.DeepCopy(), other.DeepCopy()
+	a.Source, b.Source = nil, nil
+	return equality.Semantic.DeepEqual(a, b)
+}
+
+// SetSpecAuthor sets the author and the cause of the spec change, they are recorded in the next PipelineRevision.
+// It must be called after the spec was changed, since both of them only belong to the current spec. The empty values
+// are removed.
+func (p *Pipeline) SetSpecAuthor(author, cause string) {
+	if p.Annotations == nil {
+		p.Annotations = map[string]string{}
+	}
+	hash := ""
+	if author != "" || cause != "" {
+		hash = GetPipelineSpecHash(&p.Spec)
+	}
+	for key, value := range map[string]string{
+		PipelineSpecAuthorAnnoKey:      author,
+		PipelineSpecChangeCauseAnnoKey: cause,
+		PipelineSpecAuthorHashAnnoKey:  hash,
+	} {
+		if value == "" {
+			delete(p.Annotations, key)
+		} else {
+			p.Annotations[key] = value
+		}
+	}
+}
+
+// GetSpecAuthor returns the author and the cause of the current spec. Both of them are empty if the spec was changed
+// without setting them, like being edited by kubectl.
+func (p *Pipeline) GetSpecAuthor() (author, cause string) {
+	if hash := p.Annotations[PipelineSpecAuthorHashAnnoKey]; hash == "" || hash != GetPipelineSpecHash(&p.Spec) {
+		return
+	}
+	return p.Annotations[PipelineSpecAuthorAnnoKey], p.Annotations[PipelineSpecChangeCauseAnnoKey]
+}
+
+// GetPipelineSpecHash returns the hash of a Pipeline spec
+func GetPipelineSpecHash(spec *PipelineSpec) string {
+	data, _ := json.Marshal(spec)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:8])
 }
 
 // PipelineStatus defines the observed state of Pipeline
 type PipelineStatus struct {
-	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
-	// Important: Run "make" to regenerate code after modifying this file
+	// LastScheduleTime is the last time when a PipelineRun was scheduled
+	// +optional
+	LastScheduleTime *metav1.Time `json:"lastScheduleTime,omitempty"`
+
+	// NextScheduleTime is the next time when a PipelineRun will be scheduled
+	// +optional
+	NextScheduleTime *metav1.Time `json:"nextScheduleTime,omitempty"`
 }
 
 // +genclient
@@ -82,6 +391,7 @@ type PipelineStatus struct {
 // +kubebuilder:printcolumn:name="Type",type=string,JSONPath=`.spec.type`,description="The type of a Pipeline"
 // +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`,description="The age of a Pipeline"
 // +kubebuilder:resource:shortName="pip",categories="devops"
+// +kubebuilder:subresource:status
 type Pipeline struct {
 	metav1.TypeMeta   `json:",inline"`
 	metav1.ObjectMeta `json:"metadata,omitempty"`
@@ -115,6 +425,13 @@ const (
 	MultiBranchPipelineType PipelineType = "multi-branch-pipeline"
 )
 
+const (
+	// ExecutorJenkins runs PipelineRuns in Jenkins, it's the default executor.
+	ExecutorJenkins = "jenkins"
+	// ExecutorKubernetes runs PipelineRuns as a sequence of Kubernetes Pods, only the steps of NoScmPipeline are supported.
+	ExecutorKubernetes = "kubernetes"
+)
+
 const (
 	SourceTypeSVN       = "svn"
 	SourceTypeGit       = "git"
@@ -134,6 +451,17 @@ type NoScmPipeline struct {
 	RemoteTrigger     *RemoteTrigger        `json:"remote_trigger,omitempty" mapstructure:"remote_trigger" description:"Remote api define to trigger pipeline run"`
 	GenericWebhook    *GenericWebhook       `json:"generic_webhook,omitempty" mapstructure:"generic_webhook" description:"Generic webhook config"`
 	Jenkinsfile       string                `json:"jenkinsfile,omitempty" description:"Jenkinsfile's content'"`
+	Steps             []PipelineStep        `json:"steps,omitempty" description:"Steps of pipeline, they are used by the executors which are not based on Jenkinsfile"`
+}
+
+// PipelineStep is a step which runs in a container
+type PipelineStep struct {
+	Name       string      `json:"name" description:"name of the step"`
+	Image      string      `json:"image" description:"container image of the step"`
+	Command    []string    `json:"command,omitempty" description:"entrypoint array of the step"`
+	Args       []string    `json:"args,omitempty" description:"arguments to the entrypoint"`
+	Env        []v1.EnvVar `json:"env,omitempty" description:"environment variables of the step"`
+	WorkingDir string      `json:"workingDir,omitempty" description:"working directory of the step"`
 }
 
 type MultiBranchPipeline struct {
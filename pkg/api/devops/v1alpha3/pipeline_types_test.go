@@ -17,8 +17,11 @@ limitations under the License.
 package v1alpha3
 
 import (
-	"github.com/stretchr/testify/assert"
 	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 func TestPipeline_IsMultiBranch(t *testing.T) {
@@ -124,3 +127,151 @@ func TestMultiBranchPipeline_GetGitURL(t *testing.T) {
 		})
 	}
 }
+
+func TestPipelineSpec_GetConcurrencyPolicy(t *testing.T) {
+	tests := []struct {
+		name string
+		spec PipelineSpec
+		want ConcurrencyPolicyType
+	}{{
+		name: "no policy",
+		spec: PipelineSpec{},
+		want: ConcurrencyAllow,
+	}, {
+		name: "concurrent builds were disabled",
+		spec: PipelineSpec{Pipeline: &NoScmPipeline{DisableConcurrent: true}},
+		want: ConcurrencyQueue,
+	}, {
+		name: "the policy comes first",
+		spec: PipelineSpec{
+			Pipeline:          &NoScmPipeline{DisableConcurrent: true},
+			ConcurrencyPolicy: &ConcurrencyPolicy{Type: ConcurrencyForbid},
+		},
+		want: ConcurrencyForbid,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.spec.GetConcurrencyPolicy().Type)
+		})
+	}
+}
+
+func TestPipelineSpec_GetRetentionPolicy(t *testing.T) {
+	tests := []struct {
+		name string
+		spec PipelineSpec
+		want *RetentionPolicy
+	}{{
+		name: "no policy",
+		spec: PipelineSpec{Pipeline: &NoScmPipeline{}},
+		want: nil,
+	}, {
+		name: "the discarder is not used without opting in",
+		spec: PipelineSpec{Pipeline: &NoScmPipeline{Discarder: &DiscarderProperty{DaysToKeep: "7", NumToKeep: "10"}}},
+		want: nil,
+	}, {
+		name: "from the discarder",
+		spec: PipelineSpec{
+			Pipeline:        &NoScmPipeline{Discarder: &DiscarderProperty{DaysToKeep: "7", NumToKeep: "10"}},
+			RetentionPolicy: &RetentionPolicy{FromDiscarder: true},
+		},
+		want: &RetentionPolicy{MaxCount: 10, MaxAge: &metav1.Duration{Duration: 7 * 24 * time.Hour}, FromDiscarder: true},
+	}, {
+		name: "from the discarder of a multi-branch Pipeline",
+		spec: PipelineSpec{
+			MultiBranchPipeline: &MultiBranchPipeline{Discarder: &DiscarderProperty{DaysToKeep: "-1", NumToKeep: "5"}},
+			RetentionPolicy:     &RetentionPolicy{FromDiscarder: true, KeepJenkinsRecords: true},
+		},
+		want: &RetentionPolicy{MaxCount: 5, PerBranch: true, FromDiscarder: true, KeepJenkinsRecords: true},
+	}, {
+		name: "the discarder keeps everything",
+		spec: PipelineSpec{
+			Pipeline:        &NoScmPipeline{Discarder: &DiscarderProperty{DaysToKeep: "-1", NumToKeep: ""}},
+			RetentionPolicy: &RetentionPolicy{FromDiscarder: true},
+		},
+		want: nil,
+	}, {
+		name: "no discarder",
+		spec: PipelineSpec{Pipeline: &NoScmPipeline{}, RetentionPolicy: &RetentionPolicy{FromDiscarder: true}},
+		want: nil,
+	}, {
+		name: "the policy comes first",
+		spec: PipelineSpec{
+			Pipeline:        &NoScmPipeline{Discarder: &DiscarderProperty{DaysToKeep: "7", NumToKeep: "10"}},
+			RetentionPolicy: &RetentionPolicy{MaxCount: 3, FromDiscarder: true},
+		},
+		want: &RetentionPolicy{MaxCount: 3, MaxAge: &metav1.Duration{Duration: 7 * 24 * time.Hour}, FromDiscarder: true},
+	}, {
+		name: "the policy without the discarder",
+		spec: PipelineSpec{
+			Pipeline:        &NoScmPipeline{Discarder: &DiscarderProperty{NumToKeep: "10"}},
+			RetentionPolicy: &RetentionPolicy{MaxCount: 3},
+		},
+		want: &RetentionPolicy{MaxCount: 3},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.spec.GetRetentionPolicy())
+		})
+	}
+}
+
+func TestPipelineSource(t *testing.T) {
+	assert.True(t, (&PipelineSource{Path: ".kubesphere/pipeline.yaml"}).IsManifest())
+	assert.True(t, (&PipelineSource{Path: "pipeline.yml"}).IsManifest())
+	assert.False(t, (&PipelineSource{Path: "Jenkinsfile"}).IsManifest())
+
+	var pipeline *Pipeline
+	assert.False(t, pipeline.IsGitManaged())
+	pipeline = &Pipeline{}
+	assert.False(t, pipeline.IsGitManaged())
+	pipeline.Spec.Source = &PipelineSource{GitRepository: "repo", Path: "Jenkinsfile"}
+	assert.True(t, pipeline.IsGitManaged())
+}
+
+func TestPipelineSpec_HasSameDefinition(t *testing.T) {
+	spec := &PipelineSpec{
+		Type:     NoScmPipelineType,
+		Pipeline: &NoScmPipeline{Name: "demo", Jenkinsfile: "pipeline {}"},
+		Source:   &PipelineSource{GitRepository: "repo", Path: "Jenkinsfile"},
+	}
+
+	other := spec.DeepCopy()
+	other.Source = nil
+	assert.True(t, spec.HasSameDefinition(other))
+
+	other.Source = &PipelineSource{GitRepository: "repo", Path: "Jenkinsfile", Ref: "dev"}
+	assert.True(t, spec.HasSameDefinition(other))
+
+	other.Pipeline.Description = "changed"
+	assert.False(t, spec.HasSameDefinition(other))
+	assert.NotNil(t, spec.Source, "the spec should not be changed")
+}
+
+func TestPipeline_SetSpecAuthor(t *testing.T) {
+	pipeline := &Pipeline{}
+	pipeline.SetSpecAuthor("admin", "Rolled back to revision 1")
+	assert.Equal(t, map[string]string{
+		PipelineSpecAuthorAnnoKey:      "admin",
+		PipelineSpecChangeCauseAnnoKey: "Rolled back to revision 1",
+		PipelineSpecAuthorHashAnnoKey:  GetPipelineSpecHash(&pipeline.Spec),
+	}, pipeline.Annotations)
+	author, cause := pipeline.GetSpecAuthor()
+	assert.Equal(t, "admin", author)
+	assert.Equal(t, "Rolled back to revision 1", cause)
+
+	pipeline.SetSpecAuthor("tester", "")
+	assert.Equal(t, map[string]string{
+		PipelineSpecAuthorAnnoKey:     "tester",
+		PipelineSpecAuthorHashAnnoKey: GetPipelineSpecHash(&pipeline.Spec),
+	}, pipeline.Annotations)
+
+	// the spec is changed without setting the author
+	pipeline.Spec.Type = NoScmPipelineType
+	author, cause = pipeline.GetSpecAuthor()
+	assert.Empty(t, author)
+	assert.Empty(t, cause)
+
+	pipeline.SetSpecAuthor("", "")
+	assert.Empty(t, pipeline.Annotations)
+}
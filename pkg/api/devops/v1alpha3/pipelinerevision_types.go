@@ -0,0 +1,94 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha3
+
+import (
+	"fmt"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// PipelineRevisionSpec is an immutable snapshot of a Pipeline spec
+type PipelineRevisionSpec struct {
+	// PipelineRef is the Pipeline which the revision belongs to
+	PipelineRef v1.LocalObjectReference `json:"pipelineRef" description:"The Pipeline which the revision belongs to"`
+
+	// Revision is the sequence number of the revision in the Pipeline, it starts from 1
+	// +kubebuilder:validation:Minimum=1
+	Revision int64 `json:"revision" description:"The sequence number of the revision in the Pipeline, it starts from 1"`
+
+	// SpecHash is the hash of the Pipeline spec
+	SpecHash string `json:"specHash" description:"The hash of the Pipeline spec"`
+
+	// Author is the user who made the change. It's empty if the change was not made through the API, like a
+	// synchronization from Git
+	// +optional
+	Author string `json:"author,omitempty" description:"The user who made the change"`
+
+	// Message describes the change, like a rollback or a synchronization from Git
+	// +optional
+	Message string `json:"message,omitempty" description:"The description of the change, like a rollback or a synchronization from Git"`
+
+	// Diff is the unified diff from the previous revision, it's empty for the first revision
+	// +optional
+	Diff string `json:"diff,omitempty" description:"The unified diff from the previous revision"`
+
+	// Pipeline is the snapshot of the Pipeline spec
+	Pipeline PipelineSpec `json:"pipeline" description:"The snapshot of the Pipeline spec"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:printcolumn:name="Pipeline",type=string,JSONPath=`.spec.pipelineRef.name`,description="The Pipeline which the revision belongs to"
+// +kubebuilder:printcolumn:name="Revision",type=integer,JSONPath=`.spec.revision`,description="The sequence number of the revision"
+// +kubebuilder:printcolumn:name="Author",type=string,JSONPath=`.spec.author`,description="The user who made the change"
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`,description="The age of a PipelineRevision"
+// +kubebuilder:resource:categories="devops"
+
+// PipelineRevision records an accepted change of a Pipeline spec, it's never changed once it was created
+type PipelineRevision struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec PipelineRevisionSpec `json:"spec,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// PipelineRevisionList contains a list of PipelineRevision
+type PipelineRevisionList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []PipelineRevision `json:"items"`
+}
+
+// GetPipelineRevisionName returns the name of a revision of a Pipeline
+func GetPipelineRevisionName(pipeline string, revision int64) string {
+	return fmt.Sprintf("%s-%d", pipeline, revision)
+}
+
+// Event reasons of the Pipeline revisions
+const (
+	// RevisionCreated indicates a new PipelineRevision has been created for the spec change of Pipeline
+	RevisionCreated string = "RevisionCreated"
+	// RevisionFailed indicates that it failed to create the PipelineRevision for the spec change of Pipeline
+	RevisionFailed string = "RevisionFailed"
+)
+
+func init() {
+	SchemeBuilder.Register(&PipelineRevision{}, &PipelineRevisionList{})
+}
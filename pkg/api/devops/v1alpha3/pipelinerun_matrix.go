@@ -0,0 +1,157 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha3
+
+import (
+	"fmt"
+)
+
+// MaxMatrixCombinations limits the combinations of a matrix
+const MaxMatrixCombinations = 256
+
+// Matrix fans out a PipelineRun over the combinations of parameter values. The combinations are the cartesian
+// product of the axes, except the excluded ones, plus the included ones.
+type Matrix struct {
+	// Axes are the parameters and their values
+	Axes []MatrixAxis `json:"axes" description:"The parameters and their values"`
+
+	// Include are the extra combinations, the parameters of an extra combination are not limited to the axes
+	// +optional
+	Include []MatrixCombination `json:"include,omitempty" description:"The extra combinations"`
+
+	// Exclude removes the combinations which match all the parameters of any item
+	// +optional
+	Exclude []MatrixCombination `json:"exclude,omitempty" description:"Remove the combinations which match all the parameters of any item"`
+
+	// MaxConcurrency is the max number of the child PipelineRuns running at the same time, it's unlimited if it is zero
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MaxConcurrency int `json:"maxConcurrency,omitempty" description:"The max number of the child PipelineRuns running at the same time, it's unlimited if it is zero"`
+
+	// FailFast stops the running child PipelineRuns and cancels the remaining combinations once a child failed
+	// +optional
+	FailFast bool `json:"failFast,omitempty" description:"Stop the running child PipelineRuns and cancel the remaining combinations once a child failed"`
+}
+
+// MatrixAxis is a parameter and its values
+type MatrixAxis struct {
+	// Name is the name of the parameter
+	Name string `json:"name" description:"The name of the parameter"`
+
+	// Values are the values of the parameter
+	Values []string `json:"values" description:"The values of the parameter"`
+}
+
+// MatrixCombination is a set of parameter values
+type MatrixCombination struct {
+	// Parameters are the parameter values of the combination
+	Parameters []Parameter `json:"parameters" description:"The parameter values of the combination"`
+}
+
+// MatrixRunStatus is the status of a combination of a matrix PipelineRun
+type MatrixRunStatus struct {
+	// Parameters are the parameter values of the combination
+	Parameters []Parameter `json:"parameters" description:"The parameter values of the combination"`
+
+	// PipelineRun is the name of the latest child PipelineRun of the combination, it's empty if it's not created yet
+	// +optional
+	PipelineRun string `json:"pipelineRun,omitempty" description:"The name of the latest child PipelineRun of the combination"`
+
+	// Phase is the phase of the child PipelineRun, it's Cancelled if the combination was cancelled before it started
+	// +optional
+	Phase RunPhase `json:"phase,omitempty" description:"The phase of the child PipelineRun"`
+}
+
+// matches returns true if the parameters contain all the parameters of the combination
+func (c *MatrixCombination) matches(parameters []Parameter) bool {
+	for _, expected := range c.Parameters {
+		found := false
+		for _, parameter := range parameters {
+			if parameter.Name == expected.Name {
+				found = parameter.Value == expected.Value
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// GetCombinations returns the parameter values of the combinations in a stable order, or an error if the matrix is
+// invalid
+func (m *Matrix) GetCombinations() (combinations [][]Parameter, err error) {
+	names := map[string]bool{}
+	total := 1
+	for _, axis := range m.Axes {
+		switch {
+		case axis.Name == "":
+			return nil, fmt.Errorf("the name of an axis is empty")
+		case names[axis.Name]:
+			return nil, fmt.Errorf("the axis %s is duplicated", axis.Name)
+		case len(axis.Values) == 0:
+			return nil, fmt.Errorf("the axis %s has no values", axis.Name)
+		}
+		names[axis.Name] = true
+		if total *= len(axis.Values); total > MaxMatrixCombinations {
+			return nil, fmt.Errorf("the matrix has more than %d combinations", MaxMatrixCombinations)
+		}
+	}
+
+	if len(m.Axes) > 0 {
+		combinations = [][]Parameter{{}}
+	}
+	for _, axis := range m.Axes {
+		product := make([][]Parameter, 0, len(combinations)*len(axis.Values))
+		for _, combination := range combinations {
+			for _, value := range axis.Values {
+				parameters := make([]Parameter, len(combination), len(combination)+1)
+				copy(parameters, combination)
+				product = append(product, append(parameters, Parameter{Name: axis.Name, Value: value}))
+			}
+		}
+		combinations = product
+	}
+
+	result := make([][]Parameter, 0, len(combinations)+len(m.Include))
+	for _, combination := range combinations {
+		excluded := false
+		for i := range m.Exclude {
+			if excluded = m.Exclude[i].matches(combination); excluded {
+				break
+			}
+		}
+		if !excluded {
+			result = append(result, combination)
+		}
+	}
+	for _, include := range m.Include {
+		if len(include.Parameters) == 0 {
+			return nil, fmt.Errorf("an included combination has no parameters")
+		}
+		result = append(result, include.Parameters)
+	}
+
+	switch {
+	case len(result) == 0:
+		return nil, fmt.Errorf("the matrix has no combinations")
+	case len(result) > MaxMatrixCombinations:
+		return nil, fmt.Errorf("the matrix has more than %d combinations", MaxMatrixCombinations)
+	}
+	return result, nil
+}
@@ -0,0 +1,97 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha3
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatrix_GetCombinations(t *testing.T) {
+	tests := []struct {
+		name    string
+		matrix  Matrix
+		want    [][]Parameter
+		wantErr string
+	}{{
+		name: "cartesian product",
+		matrix: Matrix{Axes: []MatrixAxis{
+			{Name: "jdk", Values: []string{"8", "11"}},
+			{Name: "os", Values: []string{"linux", "windows"}},
+		}},
+		want: [][]Parameter{
+			{{Name: "jdk", Value: "8"}, {Name: "os", Value: "linux"}},
+			{{Name: "jdk", Value: "8"}, {Name: "os", Value: "windows"}},
+			{{Name: "jdk", Value: "11"}, {Name: "os", Value: "linux"}},
+			{{Name: "jdk", Value: "11"}, {Name: "os", Value: "windows"}},
+		},
+	}, {
+		name: "include and exclude",
+		matrix: Matrix{
+			Axes: []MatrixAxis{
+				{Name: "jdk", Values: []string{"8", "11"}},
+				{Name: "os", Values: []string{"linux", "windows"}},
+			},
+			Exclude: []MatrixCombination{{Parameters: []Parameter{{Name: "os", Value: "windows"}}}},
+			Include: []MatrixCombination{{Parameters: []Parameter{{Name: "jdk", Value: "17"}, {Name: "os", Value: "linux"}, {Name: "experimental", Value: "true"}}}},
+		},
+		want: [][]Parameter{
+			{{Name: "jdk", Value: "8"}, {Name: "os", Value: "linux"}},
+			{{Name: "jdk", Value: "11"}, {Name: "os", Value: "linux"}},
+			{{Name: "jdk", Value: "17"}, {Name: "os", Value: "linux"}, {Name: "experimental", Value: "true"}},
+		},
+	}, {
+		name:   "only the included combinations",
+		matrix: Matrix{Include: []MatrixCombination{{Parameters: []Parameter{{Name: "jdk", Value: "8"}}}}},
+		want:   [][]Parameter{{{Name: "jdk", Value: "8"}}},
+	}, {
+		name:    "duplicated axes",
+		matrix:  Matrix{Axes: []MatrixAxis{{Name: "jdk", Values: []string{"8"}}, {Name: "jdk", Values: []string{"11"}}}},
+		wantErr: "the axis jdk is duplicated",
+	}, {
+		name:    "an axis without values",
+		matrix:  Matrix{Axes: []MatrixAxis{{Name: "jdk"}}},
+		wantErr: "the axis jdk has no values",
+	}, {
+		name: "all excluded",
+		matrix: Matrix{
+			Axes:    []MatrixAxis{{Name: "jdk", Values: []string{"8"}}},
+			Exclude: []MatrixCombination{{Parameters: []Parameter{{Name: "jdk", Value: "8"}}}},
+		},
+		wantErr: "the matrix has no combinations",
+	}, {
+		name: "too many combinations",
+		matrix: Matrix{Axes: []MatrixAxis{
+			{Name: "a", Values: make([]string, 16)},
+			{Name: "b", Values: make([]string, 16)},
+			{Name: "c", Values: make([]string, 2)},
+		}},
+		wantErr: "the matrix has more than 256 combinations",
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			combinations, err := tt.matrix.GetCombinations()
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tt.want, combinations)
+		})
+	}
+}
@@ -18,6 +18,7 @@ package v1alpha3
 
 import (
 	"sort"
+	"strconv"
 	"strings"
 
 	v1 "k8s.io/api/core/v1"
@@ -52,6 +53,19 @@ type PipelineRunSpec struct {
 	// Action indicates what we need to do with current PipelineRun.
 	// +optional
 	Action *Action `json:"action,omitempty"`
+
+	// RetryPolicy overrides the retry policy of the Pipeline.
+	// +optional
+	RetryPolicy *RetryPolicy `json:"retryPolicy,omitempty"`
+
+	// Matrix fans out the PipelineRun over the combinations of parameter values. A matrix PipelineRun doesn't run
+	// by itself, it creates a child PipelineRun for each combination and aggregates their phases.
+	// +optional
+	Matrix *Matrix `json:"matrix,omitempty"`
+
+	// Upstream is the PipelineRun which triggered the current one by an upstream trigger of the Pipeline
+	// +optional
+	Upstream *UpstreamReference `json:"upstream,omitempty"`
 }
 
 // PipelineRunStatus defines the observed state of PipelineRun
@@ -68,6 +82,11 @@ type PipelineRunStatus struct {
 	// +optional
 	UpdateTime *metav1.Time `json:"updateTime,omitempty"`
 
+	// ChangeTime is the commit time of the earliest SCM change which the PipelineRun built, it's empty if there were
+	// no changes since the previous run.
+	// +optional
+	ChangeTime *metav1.Time `json:"changeTime,omitempty"`
+
 	// Current state of PipelineRun.
 	// +optional
 	// +patchMergeKey=type
@@ -77,6 +96,10 @@ type PipelineRunStatus struct {
 	// Current phase of PipelineRun.
 	// +optional
 	Phase RunPhase `json:"phase,omitempty"`
+
+	// Matrix is the status of the combinations of a matrix PipelineRun, ordered by the combination index.
+	// +optional
+	Matrix []MatrixRunStatus `json:"matrix,omitempty"`
 }
 
 // +kubebuilder:object:root=true
@@ -177,6 +200,40 @@ func (pr *PipelineRun) GetRefName() string {
 	return refName
 }
 
+// GetPipelineName returns the name of the Pipeline which the PipelineRun belongs to
+func (pr *PipelineRun) GetPipelineName() string {
+	if name := pr.Labels[PipelineNameLabelKey]; name != "" {
+		return name
+	}
+	if pr.Spec.PipelineRef != nil {
+		return pr.Spec.PipelineRef.Name
+	}
+	return ""
+}
+
+// GetPendingAction returns the Action which has not been applied to the PipelineRun yet.
+func (pr *PipelineRun) GetPendingAction() (action Action, pending bool) {
+	if pr.Spec.Action == nil || *pr.Spec.Action == "" {
+		return
+	}
+	action = *pr.Spec.Action
+	pending = pr.Annotations[PipelineRunActionAnnoKey] != string(action)
+	return
+}
+
+// GetAttempt returns the attempt number of the PipelineRun, it is 1 if the PipelineRun is not a retry.
+func (pr *PipelineRun) GetAttempt() int {
+	if attempt, err := strconv.Atoi(pr.Annotations[PipelineRunAttemptAnnoKey]); err == nil && attempt > 1 {
+		return attempt
+	}
+	return 1
+}
+
+// IsMatrix returns true if the PipelineRun fans out over the combinations of its matrix.
+func (pr *PipelineRun) IsMatrix() bool {
+	return pr.Spec.Matrix != nil
+}
+
 // GetPipelineRunID gets ID of PipelineRun.
 func (pr *PipelineRun) GetPipelineRunID() (pipelineRunID string, exist bool) {
 	pipelineRunID, exist = pr.Annotations[JenkinsPipelineRunIDAnnoKey]
@@ -276,6 +333,10 @@ const (
 	// ConditionSucceeded indicates that the pipeline has finished.
 	// For pipeline which runs to completion
 	ConditionSucceeded ConditionType = "Succeeded"
+
+	// ConditionQueued indicates that the PipelineRun is waiting in the queue of the concurrency policy.
+	// The message contains the position in the queue.
+	ConditionQueued ConditionType = "Queued"
 )
 
 // ConditionStatus is the status of the current condition.
@@ -341,6 +402,52 @@ const (
 	TriggerFailed string = "TriggerFailed"
 	// RetrieveFailed indicates that it failed to retrieve the latest running data
 	RetrieveFailed string = "RetrieveFailed"
+	// StoreFailed indicates that it failed to save the running data into the PipelineRun data store
+	StoreFailed string = "StoreFailed"
+	// Stopped indicates PipelineRun has been stopped by the Stop action
+	Stopped string = "Stopped"
+	// Paused indicates PipelineRun has been paused by the Pause action
+	Paused string = "Paused"
+	// Resumed indicates PipelineRun has been resumed by the Resume action
+	Resumed string = "Resumed"
+	// ActionFailed indicates that it failed to apply the action of PipelineRun
+	ActionFailed string = "ActionFailed"
+	// Queued indicates PipelineRun is waiting for the previous PipelineRuns due to the concurrency policy
+	Queued string = "Queued"
+	// Dequeued indicates PipelineRun has left the queue of the concurrency policy
+	Dequeued string = "Dequeued"
+	// QueueFull indicates PipelineRun has been cancelled because the queue of the concurrency policy is full
+	QueueFull string = "QueueFull"
+	// ConcurrencyForbidden indicates PipelineRun has been cancelled because the concurrency policy forbids it
+	ConcurrencyForbidden string = "ConcurrencyForbidden"
+	// Replaced indicates PipelineRun has been stopped because a new PipelineRun replaced it
+	Replaced string = "Replaced"
+	// Retrying indicates a failed PipelineRun will be retried by a new PipelineRun due to the retry policy
+	Retrying string = "Retrying"
+	// RetryFailed indicates that it failed to create the PipelineRun which retries the failed one
+	RetryFailed string = "RetryFailed"
+	// Pruned indicates PipelineRun has been deleted due to the retention policy
+	Pruned string = "Pruned"
+	// PruneFailed indicates that it failed to delete PipelineRun due to the retention policy
+	PruneFailed string = "PruneFailed"
+	// Scheduled indicates PipelineRun has been created by the schedule of its Pipeline
+	Scheduled string = "Scheduled"
+	// ScheduleFailed indicates that it failed to create PipelineRun by the schedule of its Pipeline
+	ScheduleFailed string = "ScheduleFailed"
+	// MatrixRunCreated indicates a child PipelineRun has been created for a combination of the matrix
+	MatrixRunCreated string = "MatrixRunCreated"
+	// MatrixFailed indicates that it failed to fan out the matrix PipelineRun
+	MatrixFailed string = "MatrixFailed"
+	// UpstreamTriggered indicates a PipelineRun has been created by an upstream trigger of its Pipeline
+	UpstreamTriggered string = "UpstreamTriggered"
+	// UpstreamTriggerFailed indicates that it failed to create PipelineRun by an upstream trigger of its Pipeline
+	UpstreamTriggerFailed string = "UpstreamTriggerFailed"
+	// ApprovalRequested indicates an input step of PipelineRun is waiting for the approval
+	ApprovalRequested string = "ApprovalRequested"
+	// ApprovalCompleted indicates an input step of PipelineRun has been approved, rejected or timed out
+	ApprovalCompleted string = "ApprovalCompleted"
+	// ApprovalFailed indicates that it failed to forward the decision of an approval to Jenkins
+	ApprovalFailed string = "ApprovalFailed"
 )
 
 func init() {
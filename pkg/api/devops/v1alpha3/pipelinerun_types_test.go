@@ -315,3 +315,94 @@ func TestPipelineRun_GetRefName(t *testing.T) {
 		})
 	}
 }
+
+func TestPipelineRun_GetPendingAction(t *testing.T) {
+	stop := Stop
+	pause := Pause
+	empty := Action("")
+	tests := []struct {
+		name        string
+		pipelineRun *PipelineRun
+		wantAction  Action
+		wantPending bool
+	}{{
+		name:        "without action",
+		pipelineRun: &PipelineRun{},
+		wantAction:  "",
+		wantPending: false,
+	}, {
+		name: "empty action",
+		pipelineRun: &PipelineRun{
+			Spec: PipelineRunSpec{Action: &empty},
+		},
+		wantAction:  "",
+		wantPending: false,
+	}, {
+		name: "action has not been applied",
+		pipelineRun: &PipelineRun{
+			Spec: PipelineRunSpec{Action: &stop},
+		},
+		wantAction:  Stop,
+		wantPending: true,
+	}, {
+		name: "action has been applied",
+		pipelineRun: &PipelineRun{
+			ObjectMeta: v1.ObjectMeta{
+				Annotations: map[string]string{
+					PipelineRunActionAnnoKey: string(Stop),
+				},
+			},
+			Spec: PipelineRunSpec{Action: &stop},
+		},
+		wantAction:  Stop,
+		wantPending: false,
+	}, {
+		name: "action was changed",
+		pipelineRun: &PipelineRun{
+			ObjectMeta: v1.ObjectMeta{
+				Annotations: map[string]string{
+					PipelineRunActionAnnoKey: string(Stop),
+				},
+			},
+			Spec: PipelineRunSpec{Action: &pause},
+		},
+		wantAction:  Pause,
+		wantPending: true,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			action, pending := tt.pipelineRun.GetPendingAction()
+			if action != tt.wantAction {
+				t.Errorf("GetPendingAction() action = %v, want %v", action, tt.wantAction)
+			}
+			if pending != tt.wantPending {
+				t.Errorf("GetPendingAction() pending = %v, want %v", pending, tt.wantPending)
+			}
+		})
+	}
+}
+
+func TestPipelineRun_GetAttempt(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		want        int
+	}{{
+		name: "not a retry",
+		want: 1,
+	}, {
+		name:        "a retry",
+		annotations: map[string]string{PipelineRunAttemptAnnoKey: "3"},
+		want:        3,
+	}, {
+		name:        "invalid attempt",
+		annotations: map[string]string{PipelineRunAttemptAnnoKey: "a"},
+		want:        1,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pr := &PipelineRun{ObjectMeta: v1.ObjectMeta{Annotations: tt.annotations}}
+			assert.Equal(t, tt.want, pr.GetAttempt())
+		})
+	}
+}
@@ -583,6 +583,12 @@ type SyncStrategyHook struct {
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
 // +kubebuilder:object:root=true
 // +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Engine",type=string,JSONPath=`.spec.kind`
+// +kubebuilder:printcolumn:name="Sync",type=string,JSONPath=`.status.sync`
+// +kubebuilder:printcolumn:name="Health",type=string,JSONPath=`.status.health.status`
+// +kubebuilder:printcolumn:name="Revision",type=string,JSONPath=`.status.revision`,priority=1
+// +kubebuilder:printcolumn:name="Last Sync",type=date,JSONPath=`.status.lastSyncTime`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
 // +k8s:openapi-gen=true
 
 // Application represents an application the DevOps system
@@ -594,11 +600,39 @@ type Application struct {
 	Status ApplicationStatus `json:"status,omitempty"`
 }
 
-// ApplicationStatus represents the status of the Application
+// ApplicationStatus represents the status of the Application. The engine-neutral fields are filled for both Argo CD
+// and FluxCD, the clients should prefer them to the raw status of the engines.
 type ApplicationStatus struct {
-	Kind    Engine                `json:"kind,omitempty"`
-	ArgoApp string                `json:"argoApp,omitempty"`
+	Kind Engine `json:"kind,omitempty"`
+	// ArgoApp is the raw status of the Argo CD Application in JSON
+	ArgoApp string `json:"argoApp,omitempty"`
+	// FluxApp is the raw status of the FluxCD HelmReleases and Kustomizations
 	FluxApp FluxApplicationStatus `json:"fluxApp,omitempty"`
+
+	// Conditions are the Synced and Ready conditions of the Application
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+	// Sync is whether the live state matches the desired state
+	// +optional
+	Sync SyncStatusCode `json:"sync,omitempty"`
+	// Health is the aggregated health of the resources
+	// +optional
+	Health *HealthStatus `json:"health,omitempty"`
+	// Revision is the revision of the source which was applied at the last time
+	// +optional
+	Revision string `json:"revision,omitempty"`
+	// OperationState is the state of the latest sync operation
+	// +optional
+	OperationState *OperationState `json:"operationState,omitempty"`
+	// Resources are the states of the resources which are managed by the Application
+	// +optional
+	Resources []ResourceStatus `json:"resources,omitempty"`
+	// LastSyncTime is when the Application was synchronized successfully at the last time
+	// +optional
+	LastSyncTime *metav1.Time `json:"lastSyncTime,omitempty"`
+	// History is the revisions which were deployed successfully, the latest one is the last
+	// +optional
+	History []RevisionHistory `json:"history,omitempty"`
 }
 
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
@@ -0,0 +1,199 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// DefaultGenerateInterval is the default interval to generate the Applications again
+const DefaultGenerateInterval = 3 * time.Minute
+
+// ListGenerator generates a parameter set from each element
+type ListGenerator struct {
+	// Elements are the parameter sets, the keys are the names of the parameters
+	Elements []map[string]string `json:"elements"`
+}
+
+// ClusterGenerator generates a parameter set from each KubeSphere cluster placed in the workspace of the namespace, the
+// parameters are name, server and metadata.labels.<key>
+type ClusterGenerator struct {
+	// Selector selects the clusters by labels, all clusters are selected if it's empty
+	// +optional
+	Selector *metav1.LabelSelector `json:"selector,omitempty"`
+	// Values are the extra parameters added to each parameter set
+	// +optional
+	Values map[string]string `json:"values,omitempty"`
+}
+
+// GitDirectory matches the directories of a Git repository
+type GitDirectory struct {
+	// Path is a glob pattern like apps/*
+	Path string `json:"path"`
+	// Exclude excludes the matched directories
+	// +optional
+	Exclude bool `json:"exclude,omitempty"`
+}
+
+// GitGenerator generates a parameter set from each directory of a GitRepository, the parameters are path,
+// path.basename and path.basenameNormalized
+type GitGenerator struct {
+	// Repository is the GitRepository in the same namespace
+	Repository v1.LocalObjectReference `json:"repository"`
+	// Revision is a branch, tag or commit, it's the default branch if it's empty
+	// +optional
+	Revision string `json:"revision,omitempty"`
+	// Directories select the directories which are matched by any item without exclude, and not matched by any item
+	// with exclude
+	// +kubebuilder:validation:MinItems=1
+	Directories []GitDirectory `json:"directories"`
+	// Values are the extra parameters added to each parameter set
+	// +optional
+	Values map[string]string `json:"values,omitempty"`
+}
+
+// BaseGenerator contains the generators which could be combined by a matrix generator, only one of them should be set
+type BaseGenerator struct {
+	List     *ListGenerator    `json:"list,omitempty"`
+	Clusters *ClusterGenerator `json:"clusters,omitempty"`
+	Git      *GitGenerator     `json:"git,omitempty"`
+}
+
+// MatrixGenerator generates the cartesian product of the parameter sets of its generators. The parameters of a latter
+// generator override the ones of the same names
+type MatrixGenerator struct {
+	// +kubebuilder:validation:MinItems=2
+	Generators []BaseGenerator `json:"generators"`
+}
+
+// Generator generates the parameter sets, only one of the generators should be set
+type Generator struct {
+	BaseGenerator `json:",inline"`
+	Matrix        *MatrixGenerator `json:"matrix,omitempty"`
+}
+
+// IsDynamic returns true if the parameter sets come from the clusters or the Git repositories, which are not watched
+func (g BaseGenerator) IsDynamic() bool {
+	return g.Clusters != nil || g.Git != nil
+}
+
+// IsDynamic returns true if the parameter sets come from the clusters or the Git repositories, which are not watched
+func (g Generator) IsDynamic() bool {
+	if g.BaseGenerator.IsDynamic() {
+		return true
+	}
+	if g.Matrix != nil {
+		for _, generator := range g.Matrix.Generators {
+			if generator.IsDynamic() {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ApplicationTemplateMeta is the metadata of the generated Applications
+type ApplicationTemplateMeta struct {
+	// Name is the name of the Application, it must be unique among the parameter sets, like {{path.basename}}-{{name}}
+	Name string `json:"name"`
+	// +optional
+	Labels map[string]string `json:"labels,omitempty"`
+	// +optional
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// ApplicationTemplate is the template of the generated Applications. The parameters like {{name}} in the strings are
+// replaced with the values of a parameter set, the unknown parameters are kept as they are
+type ApplicationTemplate struct {
+	Metadata ApplicationTemplateMeta `json:"metadata"`
+	Spec     ApplicationSpec         `json:"spec"`
+}
+
+// ApplicationGeneratorSpec is the specification of an ApplicationGenerator
+type ApplicationGeneratorSpec struct {
+	// Generators generate the parameter sets, one Application is generated for each parameter set
+	// +kubebuilder:validation:MinItems=1
+	Generators []Generator         `json:"generators"`
+	Template   ApplicationTemplate `json:"template"`
+	// SkipPrune keeps the generated Applications which are not generated anymore
+	// +optional
+	SkipPrune bool `json:"skipPrune,omitempty"`
+	// Interval is the interval to generate again when there are cluster or Git generators, the default is 3m
+	// +optional
+	Interval *metav1.Duration `json:"interval,omitempty"`
+}
+
+// ApplicationGeneratorStatus is the status of an ApplicationGenerator
+type ApplicationGeneratorStatus struct {
+	// Applications are the names of the generated Applications
+	Applications []string `json:"applications,omitempty"`
+	// ParameterSets are the numbers of the parameter sets of each generator in the last generation
+	ParameterSets []int `json:"parameterSets,omitempty"`
+	// Message is the error of the last generation, it's empty if all Applications were generated
+	Message string `json:"message,omitempty"`
+}
+
+// +genclient
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Message",type=string,JSONPath=`.status.message`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+// +k8s:openapi-gen=true
+
+// ApplicationGenerator generates the Applications from a template and the parameter sets
+type ApplicationGenerator struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ApplicationGeneratorSpec   `json:"spec"`
+	Status ApplicationGeneratorStatus `json:"status,omitempty"`
+}
+
+// IsDynamic returns true if any generator is dynamic
+func (g *ApplicationGenerator) IsDynamic() bool {
+	for _, generator := range g.Spec.Generators {
+		if generator.IsDynamic() {
+			return true
+		}
+	}
+	return false
+}
+
+// GetInterval returns the interval to generate again
+func (g *ApplicationGenerator) GetInterval() time.Duration {
+	if g.Spec.Interval == nil || g.Spec.Interval.Duration <= 0 {
+		return DefaultGenerateInterval
+	}
+	return g.Spec.Interval.Duration
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// ApplicationGeneratorList represents a set of the ApplicationGenerators
+type ApplicationGeneratorList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ApplicationGenerator `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ApplicationGenerator{}, &ApplicationGeneratorList{})
+}
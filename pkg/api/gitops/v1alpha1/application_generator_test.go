@@ -0,0 +1,57 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestApplicationGenerator_IsDynamic(t *testing.T) {
+	list := BaseGenerator{List: &ListGenerator{}}
+	git := BaseGenerator{Git: &GitGenerator{}}
+
+	generator := &ApplicationGenerator{}
+	assert.False(t, generator.IsDynamic())
+
+	generator.Spec.Generators = []Generator{{BaseGenerator: list}}
+	assert.False(t, generator.IsDynamic())
+
+	generator.Spec.Generators = append(generator.Spec.Generators, Generator{Matrix: &MatrixGenerator{
+		Generators: []BaseGenerator{list, list}}})
+	assert.False(t, generator.IsDynamic())
+
+	generator.Spec.Generators[1].Matrix.Generators[1] = git
+	assert.True(t, generator.IsDynamic())
+
+	generator.Spec.Generators = []Generator{{BaseGenerator: BaseGenerator{Clusters: &ClusterGenerator{}}}}
+	assert.True(t, generator.IsDynamic())
+}
+
+func TestApplicationGenerator_GetInterval(t *testing.T) {
+	generator := &ApplicationGenerator{}
+	assert.Equal(t, DefaultGenerateInterval, generator.GetInterval())
+
+	generator.Spec.Interval = &metav1.Duration{}
+	assert.Equal(t, DefaultGenerateInterval, generator.GetInterval())
+
+	generator.Spec.Interval.Duration = time.Minute
+	assert.Equal(t, time.Minute, generator.GetInterval())
+}
@@ -0,0 +1,193 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// SyncStatusCode describes whether the live state of an Application matches the desired state
+type SyncStatusCode string
+
+const (
+	// SyncStatusSynced means the live state matches the desired state
+	SyncStatusSynced SyncStatusCode = "Synced"
+	// SyncStatusOutOfSync means the live state differs from the desired state
+	SyncStatusOutOfSync SyncStatusCode = "OutOfSync"
+	// SyncStatusUnknown means the state could not be compared
+	SyncStatusUnknown SyncStatusCode = "Unknown"
+)
+
+// HealthStatusCode describes the health of an Application or a resource
+type HealthStatusCode string
+
+const (
+	// HealthStatusHealthy means the resources are healthy
+	HealthStatusHealthy HealthStatusCode = "Healthy"
+	// HealthStatusSuspended means the resources are suspended or paused
+	HealthStatusSuspended HealthStatusCode = "Suspended"
+	// HealthStatusProgressing means the resources are not healthy yet, but they might be healthy soon
+	HealthStatusProgressing HealthStatusCode = "Progressing"
+	// HealthStatusMissing means the resources are absent
+	HealthStatusMissing HealthStatusCode = "Missing"
+	// HealthStatusDegraded means the resources failed or could not become healthy in time
+	HealthStatusDegraded HealthStatusCode = "Degraded"
+	// HealthStatusUnknown means the health could not be assessed
+	HealthStatusUnknown HealthStatusCode = "Unknown"
+)
+
+// healthOrder orders the health status codes from the best to the worst
+var healthOrder = []HealthStatusCode{HealthStatusHealthy, HealthStatusSuspended, HealthStatusProgressing,
+	HealthStatusMissing, HealthStatusDegraded, HealthStatusUnknown}
+
+// IsWorseHealth returns true if the health a is worse than the health b
+func IsWorseHealth(a, b HealthStatusCode) bool {
+	indexOf := func(code HealthStatusCode) int {
+		for i := range healthOrder {
+			if healthOrder[i] == code {
+				return i
+			}
+		}
+		return len(healthOrder) - 1
+	}
+	return indexOf(a) > indexOf(b)
+}
+
+// OperationPhase is the phase of a sync operation
+type OperationPhase string
+
+const (
+	// OperationRunning means the operation is running
+	OperationRunning OperationPhase = "Running"
+	// OperationTerminating means the operation is being terminated
+	OperationTerminating OperationPhase = "Terminating"
+	// OperationFailed means the operation failed
+	OperationFailed OperationPhase = "Failed"
+	// OperationError means the operation could not run
+	OperationError OperationPhase = "Error"
+	// OperationSucceeded means the operation succeeded
+	OperationSucceeded OperationPhase = "Succeeded"
+)
+
+const (
+	// ApplicationConditionSynced indicates whether the live state matches the desired state
+	ApplicationConditionSynced = "Synced"
+	// ApplicationConditionReady indicates whether the resources of the Application are healthy
+	ApplicationConditionReady = "Ready"
+)
+
+// HealthStatus is the health of an Application or a resource
+type HealthStatus struct {
+	Status  HealthStatusCode `json:"status,omitempty"`
+	Message string           `json:"message,omitempty"`
+}
+
+// OperationState is the state of the latest sync operation
+type OperationState struct {
+	Phase      OperationPhase `json:"phase,omitempty"`
+	Message    string         `json:"message,omitempty"`
+	Revision   string         `json:"revision,omitempty"`
+	StartedAt  *metav1.Time   `json:"startedAt,omitempty"`
+	FinishedAt *metav1.Time   `json:"finishedAt,omitempty"`
+}
+
+// ResourceStatus is the state of a resource which is managed by an Application
+type ResourceStatus struct {
+	Group     string         `json:"group,omitempty"`
+	Version   string         `json:"version,omitempty"`
+	Kind      string         `json:"kind"`
+	Namespace string         `json:"namespace,omitempty"`
+	Name      string         `json:"name"`
+	Status    SyncStatusCode `json:"status,omitempty"`
+	Health    *HealthStatus  `json:"health,omitempty"`
+}
+
+// MaxRevisionHistory is the maximum number of the revision history items of an Application
+const MaxRevisionHistory = 10
+
+// RevisionHistory is a revision which was deployed successfully
+type RevisionHistory struct {
+	// ID is the identifier of a history item, it's increasing
+	ID         int64       `json:"id"`
+	Revision   string      `json:"revision"`
+	DeployedAt metav1.Time `json:"deployedAt"`
+	// Source is the source of an Argo CD Application when the revision was deployed
+	// +optional
+	Source *ApplicationSource `json:"source,omitempty"`
+}
+
+// GetHistory returns the revision history item by the ID, nil is returned if it doesn't exist
+func (s *ApplicationStatus) GetHistory(id int64) *RevisionHistory {
+	for i := range s.History {
+		if s.History[i].ID == id {
+			return &s.History[i]
+		}
+	}
+	return nil
+}
+
+// AddHistory appends a revision history item if the revision is not the latest one, the oldest items are dropped
+// once there are more than MaxRevisionHistory items
+func (s *ApplicationStatus) AddHistory(revision string, deployedAt metav1.Time) {
+	var id int64 = 1
+	if count := len(s.History); count > 0 {
+		if s.History[count-1].Revision == revision {
+			return
+		}
+		id = s.History[count-1].ID + 1
+	}
+	s.History = append(s.History, RevisionHistory{ID: id, Revision: revision, DeployedAt: deployedAt})
+	if count := len(s.History); count > MaxRevisionHistory {
+		s.History = s.History[count-MaxRevisionHistory:]
+	}
+}
+
+// UpdateConditions updates the Synced and Ready conditions according to the sync state and the health
+func (s *ApplicationStatus) UpdateConditions() {
+	synced := metav1.Condition{
+		Type:   ApplicationConditionSynced,
+		Status: metav1.ConditionUnknown,
+		Reason: string(SyncStatusUnknown),
+	}
+	switch s.Sync {
+	case SyncStatusSynced:
+		synced.Status, synced.Reason = metav1.ConditionTrue, string(SyncStatusSynced)
+	case SyncStatusOutOfSync:
+		synced.Status, synced.Reason = metav1.ConditionFalse, string(SyncStatusOutOfSync)
+	}
+	if op := s.OperationState; op != nil && (op.Phase == OperationFailed || op.Phase == OperationError) {
+		synced.Status, synced.Reason, synced.Message = metav1.ConditionFalse, "Sync"+string(op.Phase), op.Message
+	}
+	meta.SetStatusCondition(&s.Conditions, synced)
+
+	ready := metav1.Condition{
+		Type:   ApplicationConditionReady,
+		Status: metav1.ConditionUnknown,
+		Reason: string(HealthStatusUnknown),
+	}
+	if s.Health != nil && s.Health.Status != "" {
+		ready.Reason, ready.Message = string(s.Health.Status), s.Health.Message
+		switch s.Health.Status {
+		case HealthStatusHealthy:
+			ready.Status = metav1.ConditionTrue
+		case HealthStatusDegraded, HealthStatusMissing:
+			ready.Status = metav1.ConditionFalse
+		}
+	}
+	meta.SetStatusCondition(&s.Conditions, ready)
+}
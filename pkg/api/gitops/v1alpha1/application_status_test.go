@@ -0,0 +1,101 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestIsWorseHealth(t *testing.T) {
+	assert.True(t, IsWorseHealth(HealthStatusDegraded, HealthStatusHealthy))
+	assert.True(t, IsWorseHealth(HealthStatusProgressing, HealthStatusSuspended))
+	assert.True(t, IsWorseHealth(HealthStatusUnknown, HealthStatusDegraded))
+	assert.True(t, IsWorseHealth("", HealthStatusMissing))
+	assert.False(t, IsWorseHealth(HealthStatusHealthy, HealthStatusHealthy))
+	assert.False(t, IsWorseHealth(HealthStatusHealthy, HealthStatusProgressing))
+}
+
+func TestApplicationStatus_UpdateConditions(t *testing.T) {
+	type condition struct {
+		status metav1.ConditionStatus
+		reason string
+	}
+	tests := []struct {
+		name   string
+		status ApplicationStatus
+		synced condition
+		ready  condition
+	}{{
+		name:   "empty",
+		synced: condition{metav1.ConditionUnknown, "Unknown"},
+		ready:  condition{metav1.ConditionUnknown, "Unknown"},
+	}, {
+		name:   "synced and healthy",
+		status: ApplicationStatus{Sync: SyncStatusSynced, Health: &HealthStatus{Status: HealthStatusHealthy}},
+		synced: condition{metav1.ConditionTrue, "Synced"},
+		ready:  condition{metav1.ConditionTrue, "Healthy"},
+	}, {
+		name:   "out of sync and progressing",
+		status: ApplicationStatus{Sync: SyncStatusOutOfSync, Health: &HealthStatus{Status: HealthStatusProgressing}},
+		synced: condition{metav1.ConditionFalse, "OutOfSync"},
+		ready:  condition{metav1.ConditionUnknown, "Progressing"},
+	}, {
+		name: "sync failed and degraded",
+		status: ApplicationStatus{
+			Sync:           SyncStatusSynced,
+			Health:         &HealthStatus{Status: HealthStatusDegraded, Message: "back-off"},
+			OperationState: &OperationState{Phase: OperationFailed, Message: "one or more objects failed to apply"},
+		},
+		synced: condition{metav1.ConditionFalse, "SyncFailed"},
+		ready:  condition{metav1.ConditionFalse, "Degraded"},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.status.UpdateConditions()
+			assert.Len(t, tt.status.Conditions, 2)
+			synced := meta.FindStatusCondition(tt.status.Conditions, ApplicationConditionSynced)
+			assert.Equal(t, tt.synced, condition{synced.Status, synced.Reason})
+			ready := meta.FindStatusCondition(tt.status.Conditions, ApplicationConditionReady)
+			assert.Equal(t, tt.ready, condition{ready.Status, ready.Reason})
+			if tt.status.Health != nil {
+				assert.Equal(t, tt.status.Health.Message, ready.Message)
+			}
+		})
+	}
+}
+
+func TestApplicationStatus_AddHistory(t *testing.T) {
+	status := &ApplicationStatus{}
+	now := metav1.Now()
+	status.AddHistory("a", now)
+	status.AddHistory("a", now)
+	assert.Equal(t, []RevisionHistory{{ID: 1, Revision: "a", DeployedAt: now}}, status.History)
+
+	for i := 0; i < MaxRevisionHistory; i++ {
+		status.AddHistory(string(rune('b'+i)), now)
+	}
+	assert.Len(t, status.History, MaxRevisionHistory)
+	assert.Equal(t, int64(2), status.History[0].ID)
+	assert.Equal(t, int64(MaxRevisionHistory+1), status.History[MaxRevisionHistory-1].ID)
+
+	assert.Equal(t, "b", status.GetHistory(2).Revision)
+	assert.Nil(t, status.GetHistory(1))
+}
@@ -24,11 +24,16 @@ const (
 	ArgoCDAppControlByLabelKey = GroupName + "/argocd-application-control-by"
 	AppNamespaceLabelKey       = GroupName + "/application-namespace"
 	AppNameLabelKey            = GroupName + "/application-name"
+	AppGeneratorLabelKey       = GroupName + "/application-generator"
 )
 
 const (
 	// AnnoKeyImages is the key for the image list
 	AnnoKeyImages = GroupName + "/images"
+	// AnnoKeyRollbackRevision is the key for the revision which an Application was rolled back to
+	AnnoKeyRollbackRevision = GroupName + "/rollback-revision"
+	// AnnoKeyRollbackInitiator is the key for the user who rolled back an Application
+	AnnoKeyRollbackInitiator = GroupName + "/rollback-initiator"
 )
 
 // ApplicationFinalizerName is the name of PipelineRun finalizer
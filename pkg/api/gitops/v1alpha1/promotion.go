@@ -0,0 +1,312 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"reflect"
+
+	devopsv1alpha3 "github.com/kubesphere/ks-devops/pkg/api/devops/v1alpha3"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// PromotionGateType is the type of a promotion gate
+// +kubebuilder:validation:Enum=Approval;Health;PipelineRun
+type PromotionGateType string
+
+const (
+	// PromotionGateApproval waits for the approvals of the users
+	PromotionGateApproval PromotionGateType = "Approval"
+	// PromotionGateHealth waits until the Application of the previous environment is synced and healthy
+	PromotionGateHealth PromotionGateType = "Health"
+	// PromotionGatePipelineRun waits until the latest PipelineRun of a Pipeline succeeded after the Application of
+	// the previous environment was synchronized
+	PromotionGatePipelineRun PromotionGateType = "PipelineRun"
+)
+
+// PromotionGate must be passed before the content is promoted to an environment
+type PromotionGate struct {
+	Type PromotionGateType `json:"type"`
+	// Approvers are allowed to approve, nobody is allowed if it's empty. If Anyone is set, everyone who is able to
+	// update the Promotion is allowed. It only works for the Approval gate.
+	// +optional
+	Approvers devopsv1alpha3.Approvers `json:"approvers,omitempty"`
+	// RequiredApprovals is the number of the approvals from different users, it's 1 by default.
+	// It only works for the Approval gate.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	RequiredApprovals int `json:"requiredApprovals,omitempty"`
+	// Pipeline is the name of a Pipeline in the namespace. It only works for the PipelineRun gate.
+	// +optional
+	Pipeline string `json:"pipeline,omitempty"`
+}
+
+// GetRequiredApprovals returns the number of the approvals to pass the gate, it's at least 1
+func (g *PromotionGate) GetRequiredApprovals() int {
+	if g.RequiredApprovals < 1 {
+		return 1
+	}
+	return g.RequiredApprovals
+}
+
+// PromotionGitTarget is where the promoted content is committed to
+type PromotionGitTarget struct {
+	// Repository is the GitRepository in the namespace
+	Repository v1.LocalObjectReference `json:"repository"`
+	// Branch is the branch to commit to
+	Branch string `json:"branch"`
+	// Path is the directory of the environment, the images are written into the kustomization.yaml in it
+	// +optional
+	Path string `json:"path,omitempty"`
+	// ValuesFile is the Helm values file in the path, the Helm values are written into it
+	// +kubebuilder:default:=values.yaml
+	// +optional
+	ValuesFile string `json:"valuesFile,omitempty"`
+}
+
+// PromotionEnvironment is an environment which the content is promoted to
+type PromotionEnvironment struct {
+	// Name is the name of the environment, it's unique in a Promotion
+	Name string `json:"name"`
+	// Application is the Application of the environment in the same namespace
+	Application v1.LocalObjectReference `json:"application"`
+	// Gates must be passed before the content is promoted to the environment, they are ignored for the first one
+	// +optional
+	Gates []PromotionGate `json:"gates,omitempty"`
+	// Write is how the content is promoted. The Application is updated with the built-in method, and the content
+	// is committed to the Git target with the git method.
+	// +kubebuilder:default:=built-in
+	// +kubebuilder:validation:Enum=built-in;git
+	// +optional
+	Write WriteMethod `json:"write,omitempty"`
+	// Git is where the content is committed to, it's required by the git write method
+	// +optional
+	Git *PromotionGitTarget `json:"git,omitempty"`
+}
+
+// PromotionTarget describes what gets promoted
+type PromotionTarget struct {
+	// Revision promotes the deployed revision. It's the target revision of an Argo CD Application, or the chart
+	// version of a FluxCD HelmRelease from a HelmRepository.
+	// +optional
+	Revision bool `json:"revision,omitempty"`
+	// HelmValues are the paths of the Helm values to promote, like "image.tag". They are the Helm parameters of
+	// an Argo CD Application, or the values of a FluxCD HelmRelease.
+	// +optional
+	HelmValues []string `json:"helmValues,omitempty"`
+	// KustomizeImages are the names of the Kustomize images to promote
+	// +optional
+	KustomizeImages []string `json:"kustomizeImages,omitempty"`
+}
+
+// PromotionSpec is the specification of the Promotion
+type PromotionSpec struct {
+	// Environments are promoted in order, the first one is the source of the content
+	// +kubebuilder:validation:MinItems=2
+	Environments []PromotionEnvironment `json:"environments"`
+	// Promote describes what gets promoted
+	Promote PromotionTarget `json:"promote"`
+	// Suspend stops promoting if it's true
+	// +optional
+	Suspend bool `json:"suspend,omitempty"`
+}
+
+// PromotionContent is the content promoted from an environment to the next one
+type PromotionContent struct {
+	// +optional
+	Revision string `json:"revision,omitempty"`
+	// HelmValues are the Helm values by the paths
+	// +optional
+	HelmValues map[string]string `json:"helmValues,omitempty"`
+	// KustomizeImages are the new images by the image names, like "nginx:1.21" or "registry/nginx@sha256:..."
+	// +optional
+	KustomizeImages map[string]string `json:"kustomizeImages,omitempty"`
+}
+
+// IsEmpty returns true if there is nothing to promote
+func (c *PromotionContent) IsEmpty() bool {
+	return c == nil || (c.Revision == "" && len(c.HelmValues) == 0 && len(c.KustomizeImages) == 0)
+}
+
+// Equal returns true if the contents are the same
+func (c *PromotionContent) Equal(other *PromotionContent) bool {
+	if c.IsEmpty() || other.IsEmpty() {
+		return c.IsEmpty() == other.IsEmpty()
+	}
+	return reflect.DeepEqual(c, other)
+}
+
+// PromotionPhase is the phase of promoting to an environment
+type PromotionPhase string
+
+const (
+	// PromotionPhasePromoted means the environment has the content of the previous one
+	PromotionPhasePromoted PromotionPhase = "Promoted"
+	// PromotionPhaseWaiting means the candidate is waiting for the gates
+	PromotionPhaseWaiting PromotionPhase = "Waiting"
+	// PromotionPhasePromoting means the candidate passed the gates, and it's being written to the environment
+	PromotionPhasePromoting PromotionPhase = "Promoting"
+	// PromotionPhaseRejected means the candidate was rejected, it's not promoted until the content changes
+	PromotionPhaseRejected PromotionPhase = "Rejected"
+	// PromotionPhaseFailed means the candidate could not be promoted
+	PromotionPhaseFailed PromotionPhase = "Failed"
+)
+
+// PromotionGateStatus is the state of a gate for the candidate
+type PromotionGateStatus struct {
+	Type    PromotionGateType `json:"type"`
+	Passed  bool              `json:"passed"`
+	Message string            `json:"message,omitempty"`
+}
+
+// PromotionEnvironmentStatus is the state of promoting to an environment
+type PromotionEnvironmentStatus struct {
+	Name  string         `json:"name"`
+	Phase PromotionPhase `json:"phase,omitempty"`
+	// Candidate is the content waiting to be promoted to the environment
+	// +optional
+	Candidate *PromotionContent `json:"candidate,omitempty"`
+	// Gates are the states of the gates for the candidate
+	// +optional
+	Gates []PromotionGateStatus `json:"gates,omitempty"`
+	// Decisions are the decisions on the candidate, in the order of time
+	// +optional
+	Decisions []devopsv1alpha3.ApprovalDecision `json:"decisions,omitempty"`
+	// +optional
+	Message string `json:"message,omitempty"`
+	// LastPromotionTime is when the content was promoted to the environment at the last time
+	// +optional
+	LastPromotionTime *metav1.Time `json:"lastPromotionTime,omitempty"`
+}
+
+// CountApprovals returns the number of the users who approved the candidate
+func (s *PromotionEnvironmentStatus) CountApprovals() int {
+	return len(s.GetApprovers())
+}
+
+// GetApprovers returns the users who approved the candidate, in the order of time
+func (s *PromotionEnvironmentStatus) GetApprovers() (approvers []string) {
+	users := map[string]bool{}
+	for _, decision := range s.Decisions {
+		if decision.Decision == devopsv1alpha3.ApprovalDecisionApprove && !users[decision.User] {
+			users[decision.User] = true
+			approvers = append(approvers, decision.User)
+		}
+	}
+	return
+}
+
+// IsRejected returns true if anyone rejected the candidate
+func (s *PromotionEnvironmentStatus) IsRejected() bool {
+	for _, decision := range s.Decisions {
+		if decision.Decision == devopsv1alpha3.ApprovalDecisionReject {
+			return true
+		}
+	}
+	return false
+}
+
+// HasDecided returns true if the user has made a decision on the candidate
+func (s *PromotionEnvironmentStatus) HasDecided(user string) bool {
+	for _, decision := range s.Decisions {
+		if decision.User == user {
+			return true
+		}
+	}
+	return false
+}
+
+// MaxPromotionHistory is the maximum number of the history items of a Promotion
+const MaxPromotionHistory = 20
+
+// PromotionHistory is a promotion which was carried out
+type PromotionHistory struct {
+	// ID is the identifier of a history item, it's increasing
+	ID int64 `json:"id"`
+	// Environment is the environment which the content was promoted to
+	Environment string `json:"environment"`
+	// Source is the environment which the content was promoted from
+	Source  string           `json:"source"`
+	Content PromotionContent `json:"content"`
+	// Commit is the commit which was pushed by the git write method
+	// +optional
+	Commit     string      `json:"commit,omitempty"`
+	Approvers  []string    `json:"approvers,omitempty"`
+	PromotedAt metav1.Time `json:"promotedAt"`
+}
+
+// PromotionStatus is the status of the Promotion
+type PromotionStatus struct {
+	// Environments are the states of the environments except the first one
+	// +optional
+	Environments []PromotionEnvironmentStatus `json:"environments,omitempty"`
+	// History is the promotions which were carried out, the latest one is the last
+	// +optional
+	History []PromotionHistory `json:"history,omitempty"`
+}
+
+// GetEnvironment returns the state of an environment by the name, nil is returned if it doesn't exist
+func (s *PromotionStatus) GetEnvironment(name string) *PromotionEnvironmentStatus {
+	for i := range s.Environments {
+		if s.Environments[i].Name == name {
+			return &s.Environments[i]
+		}
+	}
+	return nil
+}
+
+// AddHistory appends a history item, the oldest items are dropped once there are more than MaxPromotionHistory items
+func (s *PromotionStatus) AddHistory(history PromotionHistory) {
+	history.ID = 1
+	if count := len(s.History); count > 0 {
+		history.ID = s.History[count-1].ID + 1
+	}
+	s.History = append(s.History, history)
+	if count := len(s.History); count > MaxPromotionHistory {
+		s.History = s.History[count-MaxPromotionHistory:]
+	}
+}
+
+// +genclient
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Suspend",type=boolean,JSONPath=`.spec.suspend`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+// +k8s:openapi-gen=true
+
+// Promotion promotes the content of an Application through the environments in order
+type Promotion struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   PromotionSpec   `json:"spec"`
+	Status PromotionStatus `json:"status,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// PromotionList represents a set of the Promotions
+type PromotionList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []Promotion `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&Promotion{}, &PromotionList{})
+}
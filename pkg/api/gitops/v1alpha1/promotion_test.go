@@ -0,0 +1,80 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"testing"
+
+	devopsv1alpha3 "github.com/kubesphere/ks-devops/pkg/api/devops/v1alpha3"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestPromotionContent_Equal(t *testing.T) {
+	content := &PromotionContent{Revision: "abc", HelmValues: map[string]string{"image.tag": "v1"}}
+	assert.True(t, content.Equal(content.DeepCopy()))
+	assert.True(t, (&PromotionContent{}).Equal(nil))
+	assert.True(t, (*PromotionContent)(nil).IsEmpty())
+	assert.False(t, content.Equal(nil))
+	assert.False(t, content.Equal(&PromotionContent{Revision: "abc"}))
+	assert.False(t, content.Equal(&PromotionContent{Revision: "abc", HelmValues: map[string]string{"image.tag": "v2"}}))
+}
+
+func TestPromotionEnvironmentStatus_Decisions(t *testing.T) {
+	status := &PromotionEnvironmentStatus{}
+	assert.Equal(t, 0, status.CountApprovals())
+	assert.False(t, status.IsRejected())
+	assert.False(t, status.HasDecided("alice"))
+
+	status.Decisions = []devopsv1alpha3.ApprovalDecision{
+		{User: "alice", Decision: devopsv1alpha3.ApprovalDecisionApprove},
+		{User: "bob", Decision: devopsv1alpha3.ApprovalDecisionApprove},
+		{User: "alice", Decision: devopsv1alpha3.ApprovalDecisionApprove},
+	}
+	assert.Equal(t, 2, status.CountApprovals())
+	assert.Equal(t, []string{"alice", "bob"}, status.GetApprovers())
+	assert.True(t, status.HasDecided("bob"))
+	assert.False(t, status.IsRejected())
+
+	status.Decisions = append(status.Decisions, devopsv1alpha3.ApprovalDecision{User: "carol", Decision: devopsv1alpha3.ApprovalDecisionReject})
+	assert.True(t, status.IsRejected())
+}
+
+func TestPromotionGate_GetRequiredApprovals(t *testing.T) {
+	assert.Equal(t, 1, (&PromotionGate{}).GetRequiredApprovals())
+	assert.Equal(t, 2, (&PromotionGate{RequiredApprovals: 2}).GetRequiredApprovals())
+}
+
+func TestPromotionStatus_AddHistory(t *testing.T) {
+	status := &PromotionStatus{}
+	for i := 0; i < MaxPromotionHistory+2; i++ {
+		status.AddHistory(PromotionHistory{Environment: "prod", PromotedAt: metav1.Now()})
+	}
+	assert.Len(t, status.History, MaxPromotionHistory)
+	assert.Equal(t, int64(3), status.History[0].ID)
+	assert.Equal(t, int64(MaxPromotionHistory+2), status.History[MaxPromotionHistory-1].ID)
+}
+
+func TestPromotionStatus_GetEnvironment(t *testing.T) {
+	status := &PromotionStatus{Environments: []PromotionEnvironmentStatus{{Name: "staging"}, {Name: "prod"}}}
+	env := status.GetEnvironment("prod")
+	if assert.NotNil(t, env) {
+		env.Phase = PromotionPhasePromoted
+		assert.Equal(t, PromotionPhasePromoted, status.Environments[1].Phase)
+	}
+	assert.Nil(t, status.GetEnvironment("dev"))
+}
@@ -50,6 +50,7 @@ import (
 	"github.com/kubesphere/ks-devops/pkg/kapis/oauth"
 	"github.com/kubesphere/ks-devops/pkg/kapis/proxy"
 	"github.com/kubesphere/ks-devops/pkg/models/auth"
+	"github.com/kubesphere/ks-devops/pkg/store/provider"
 	utilnet "github.com/kubesphere/ks-devops/pkg/utils/net"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
@@ -145,9 +146,13 @@ func (s *APIServer) InstallDevOpsAPIs() {
 		s.S3Client,
 		s.Config.JenkinsOptions.Host,
 		s.KubernetesClient,
-		jenkinsCore)
+		jenkinsCore,
+		s.Client)
 	utilruntime.Must(err)
-	devopsv1alpha3.AddToContainer(s.container, s.DevopsClient, s.KubernetesClient, s.Client, s.RuntimeCache, jenkinsCore, s.Config)
+	dataStore, err := provider.NewProvider(s.Config.PipelineRunDataStoreOptions, s.Client, s.S3Client)
+	utilruntime.Must(err)
+	devopsv1alpha3.AddToContainer(s.container, s.DevopsClient, s.KubernetesClient, s.Client, s.RuntimeCache, jenkinsCore,
+		s.Config, dataStore, s.CacheClient)
 	oauth.AddToContainer(s.container,
 		auth.NewTokenOperator(
 			s.CacheClient,
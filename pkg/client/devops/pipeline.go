@@ -721,6 +721,7 @@ type CheckScript struct {
 type CronData struct {
 	PipelineName string `json:"pipelineName,omitempty" description:"Pipeline name, if pipeline haven't created, not required'"`
 	Cron         string `json:"cron" description:"Cron script data."`
+	TimeZone     string `json:"timeZone,omitempty" description:"The IANA name of the time zone, it's UTC by default"`
 }
 
 type CheckCronRes struct {
@@ -94,20 +94,23 @@ type Config struct {
 	AuthMode              AuthMode                           `json:"authMode,omitempty" yaml:"authMode,omitempty" mapstructure:"authMode"`
 	JWTSecret             string                             `json:"jwtSecret,omitempty" yaml:"jwtSecret,omitempty" mapstructure:"jwtSecret"`
 	GitOpsOptions         *GitOpsOptions                     `json:"gitops,omitempty" yaml:"gitops,omitempty" mapstructure:"gitops"`
+	// PipelineRunDataStoreOptions chooses the storage of PipelineRun data
+	PipelineRunDataStoreOptions *PipelineRunDataStoreOptions `json:"pipelineRunDataStore,omitempty" yaml:"pipelineRunDataStore,omitempty" mapstructure:"pipelineRunDataStore"`
 }
 
 // New creates a default non-empty Config
 func New() *Config {
 	return &Config{
-		SonarQubeOptions:      sonarqube.NewSonarQubeOptions(),
-		JenkinsOptions:        jenkins.NewJenkinsOptions(),
-		KubernetesOptions:     k8s.NewKubernetesOptions(),
-		S3Options:             s3.NewS3Options(),
-		AuthMode:              AuthModeToken,
-		ArgoCDOption:          &ArgoCDOption{},
-		FluxCDOption:          &FluxCDOption{},
-		GitOpsOptions:         NewGitOpsOptions(),
-		AuthenticationOptions: &authoptions.AuthenticationOptions{},
+		SonarQubeOptions:            sonarqube.NewSonarQubeOptions(),
+		JenkinsOptions:              jenkins.NewJenkinsOptions(),
+		KubernetesOptions:           k8s.NewKubernetesOptions(),
+		S3Options:                   s3.NewS3Options(),
+		AuthMode:                    AuthModeToken,
+		ArgoCDOption:                &ArgoCDOption{},
+		FluxCDOption:                &FluxCDOption{},
+		GitOpsOptions:               NewGitOpsOptions(),
+		PipelineRunDataStoreOptions: NewPipelineRunDataStoreOptions(),
+		AuthenticationOptions:       &authoptions.AuthenticationOptions{},
 	}
 }
 
@@ -0,0 +1,45 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+const (
+	// PipelineRunDataStoreConfigMap stores the PipelineRun data in ConfigMaps
+	PipelineRunDataStoreConfigMap = "configmap"
+	// PipelineRunDataStoreS3 stores the PipelineRun data in the S3 bucket which is configured by the s3 options
+	PipelineRunDataStoreS3 = "s3"
+	// PipelineRunDataStoreFileSystem stores the PipelineRun data in a local directory, usually it's a mounted PVC
+	PipelineRunDataStoreFileSystem = "fs"
+)
+
+// PipelineRunDataStoreOptions is the configuration of the storage of PipelineRun data, such as stages, status and logs
+type PipelineRunDataStoreOptions struct {
+	// Type is the backend of the data store, could be configmap, s3 or fs.
+	// The data is stored in the annotations of PipelineRuns if it is empty.
+	Type string `json:"type,omitempty" yaml:"type,omitempty" mapstructure:"type"`
+	// Path is the root directory of the fs data store
+	Path string `json:"path,omitempty" yaml:"path,omitempty" mapstructure:"path"`
+	// Migrate indicates if moving the existing data from ConfigMaps into the s3 or fs data store
+	Migrate bool `json:"migrate,omitempty" yaml:"migrate,omitempty" mapstructure:"migrate"`
+}
+
+// NewPipelineRunDataStoreOptions creates the default options of the PipelineRun data store
+func NewPipelineRunDataStoreOptions() *PipelineRunDataStoreOptions {
+	return &PipelineRunDataStoreOptions{
+		Type: PipelineRunDataStoreConfigMap,
+		Path: "/var/lib/ks-devops/pipelineruns",
+	}
+}
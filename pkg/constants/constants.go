@@ -40,6 +40,7 @@ const (
 	DevOpsStepTemplateTag    = "DevOps StepTemplate"
 	DevOpsClusterTemplateTag = "DevOps ClusterTemplate"
 	GitOpsTag                = "GitOps"
+	DevOpsAnalyticsTag       = "DevOps Analytics"
 
 	DevOpsManagedKey      = "devops.kubesphere.io/managed"
 	DevOpsSystemNamespace = "kubesphere-devops-system"
@@ -58,6 +59,7 @@ var (
 	DevOpsStepTemplateTags    = []string{DevOpsStepTemplateTag}
 	DevOpsClusterTemplateTags = []string{DevOpsClusterTemplateTag}
 	GitOpsTags                = []string{GitOpsTag}
+	DevOpsAnalyticsTags       = []string{DevOpsAnalyticsTag}
 )
 
 // K8SToken is the context key of k8s token
@@ -31,6 +31,14 @@ const (
 	RunCompleted string = "run.completed"
 	// RunDeleted represents Jenkins run has been deleted.
 	RunDeleted string = "run.deleted"
+	// StageStarted represents a stage of Jenkins run has started.
+	StageStarted string = "stage.started"
+	// StageCompleted represents a stage of Jenkins run has completed.
+	StageCompleted string = "stage.completed"
+	// StepStarted represents a step of Jenkins run has started.
+	StepStarted string = "step.started"
+	// StepCompleted represents a step of Jenkins run has completed.
+	StepCompleted string = "step.completed"
 )
 
 // Event contains common fields of event except event data.
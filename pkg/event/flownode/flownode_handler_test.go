@@ -0,0 +1,95 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package flownode
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/kubesphere/ks-devops/pkg/event/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandlers_Handle(t *testing.T) {
+	createEvent := func(eventType, dataType string, data *Data) *common.Event {
+		dataBytes, _ := json.Marshal(data)
+		return &common.Event{
+			ID:       "fake.id",
+			Type:     eventType,
+			Time:     "fake-time",
+			DataType: dataType,
+			Data:     dataBytes,
+		}
+	}
+	errStageStarted := errors.New("stage started")
+	errStageCompleted := errors.New("stage completed")
+	errStepStarted := errors.New("step started")
+	errStepCompleted := errors.New("step completed")
+	handlers := Handlers{
+		HandleStageStarted:   func(*Data) error { return errStageStarted },
+		HandleStageCompleted: func(*Data) error { return errStageCompleted },
+		HandleStepStarted:    func(*Data) error { return errStepStarted },
+		HandleStepCompleted:  func(*Data) error { return errStepCompleted },
+	}
+
+	tests := []struct {
+		name     string
+		event    *common.Event
+		handlers Handlers
+		wantErr  error
+	}{{
+		name:     "event is nil",
+		handlers: handlers,
+	}, {
+		name:     "stage started",
+		event:    createEvent(common.StageStarted, Type, &Data{}),
+		handlers: handlers,
+		wantErr:  errStageStarted,
+	}, {
+		name:     "stage completed",
+		event:    createEvent(common.StageCompleted, Type, &Data{}),
+		handlers: handlers,
+		wantErr:  errStageCompleted,
+	}, {
+		name:     "step started",
+		event:    createEvent(common.StepStarted, Type, &Data{}),
+		handlers: handlers,
+		wantErr:  errStepStarted,
+	}, {
+		name:     "step completed",
+		event:    createEvent(common.StepCompleted, Type, &Data{}),
+		handlers: handlers,
+		wantErr:  errStepCompleted,
+	}, {
+		name:  "no handler",
+		event: createEvent(common.StepCompleted, Type, &Data{}),
+	}, {
+		name:     "the event type is out of range",
+		event:    createEvent(common.RunStarted, Type, &Data{}),
+		handlers: handlers,
+	}, {
+		name:     "the data type is invalid",
+		event:    createEvent(common.StageStarted, "fake.data.type", &Data{}),
+		handlers: handlers,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.wantErr, tt.handlers.Handle(tt.event))
+		})
+	}
+}
@@ -0,0 +1,32 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package flownode
+
+// Data contains the brief information of a stage or step, and the WorkflowRun which it belongs to.
+type Data struct {
+	ID          string `json:"id"`
+	DisplayName string `json:"displayName"`
+	// Result is the result of a completed node, such as SUCCESS or FAILURE. It is empty if the node is running.
+	Result    string `json:"result"`
+	StartTime int64  `json:"startTime"`
+	Duration  int64  `json:"duration"`
+	// RunID is the ID of the WorkflowRun which the node belongs to.
+	RunID          string `json:"_runId"`
+	ParentFullName string `json:"_parentFullName"`
+	ProjectName    string `json:"_projectName"`
+	IsMultiBranch  bool   `json:"_multiBranch"`
+}
@@ -59,3 +59,19 @@ func extractPipelineRunIdentifier(o client.Object) []string {
 	}
 	return []string{pipelineRun.GetPipelineRunIdentifier()}
 }
+
+// CreatePipelineUpstreamIndexer creates an indexer which could speed up finding the downstream Pipelines of a Pipeline.
+func CreatePipelineUpstreamIndexer(runtimeCache cache.Cache) error {
+	return runtimeCache.IndexField(context.Background(),
+		&v1alpha3.Pipeline{},
+		v1alpha3.PipelineUpstreamIndexerName,
+		extractPipelineUpstreams)
+}
+
+func extractPipelineUpstreams(o client.Object) []string {
+	pipeline, ok := o.(*v1alpha3.Pipeline)
+	if !ok || pipeline == nil {
+		return []string{}
+	}
+	return pipeline.GetUpstreamKeys()
+}
@@ -150,3 +150,42 @@ func Test_extractPipelineRunIdentifier(t *testing.T) {
 		})
 	}
 }
+
+func TestCreatePipelineUpstreamIndexer(t *testing.T) {
+	if err := CreatePipelineUpstreamIndexer(&informertest.FakeInformers{}); err != nil {
+		t.Errorf("CreatePipelineUpstreamIndexer() error = %v", err)
+	}
+}
+
+func Test_extractPipelineUpstreams(t *testing.T) {
+	tests := []struct {
+		name string
+		o    client.Object
+		want []string
+	}{{
+		name: "not expect kind",
+		o:    &v1.ConfigMap{},
+		want: []string{},
+	}, {
+		name: "no upstream triggers",
+		o:    &v1alpha3.Pipeline{},
+		want: nil,
+	}, {
+		name: "upstream triggers",
+		o: &v1alpha3.Pipeline{
+			ObjectMeta: v12.ObjectMeta{Namespace: "team-b"},
+			Spec: v1alpha3.PipelineSpec{UpstreamTriggers: []v1alpha3.UpstreamTrigger{
+				{Pipeline: "build"},
+				{Namespace: "team-a", Pipeline: "build"},
+			}},
+		},
+		want: []string{"team-b/build", "team-a/build"},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := extractPipelineUpstreams(tt.o); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("extractPipelineUpstreams() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
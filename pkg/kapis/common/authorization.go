@@ -0,0 +1,48 @@
+// Copyright 2022 KubeSphere Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package common
+
+import (
+	"context"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Authorize asks the API server if the user is allowed to access the resource through a SubjectAccessReview. The
+// handlers which read or write with the privileged client on behalf of a user use it to check the permission first.
+func Authorize(ctx context.Context, c client.Client, u user.Info, attributes authorizationv1.ResourceAttributes) (
+	allowed bool, err error) {
+	review := &authorizationv1.SubjectAccessReview{
+		Spec: authorizationv1.SubjectAccessReviewSpec{
+			ResourceAttributes: &attributes,
+			User:               u.GetName(),
+			Groups:             u.GetGroups(),
+			UID:                u.GetUID(),
+		},
+	}
+	if extra := u.GetExtra(); len(extra) > 0 {
+		review.Spec.Extra = make(map[string]authorizationv1.ExtraValue, len(extra))
+		for key, values := range extra {
+			review.Spec.Extra[key] = values
+		}
+	}
+	if err = c.Create(ctx, review); err == nil {
+		allowed = review.Status.Allowed
+	}
+	return
+}
@@ -0,0 +1,60 @@
+// Copyright 2022 KubeSphere Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package common
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+)
+
+func TestAuthorize(t *testing.T) {
+	var reviewed *authorizationv1.SubjectAccessReviewSpec
+	c := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithInterceptorFuncs(interceptor.Funcs{
+		Create: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.CreateOption) error {
+			review := obj.(*authorizationv1.SubjectAccessReview)
+			reviewed = review.Spec.DeepCopy()
+			review.Status.Allowed = review.Spec.User == "admin"
+			return nil
+		},
+	}).Build()
+	attributes := authorizationv1.ResourceAttributes{Namespace: "ns", Verb: "get", Resource: "secrets", Name: "token"}
+
+	allowed, err := Authorize(context.TODO(), c, &user.DefaultInfo{
+		Name:   "admin",
+		Groups: []string{"system:authenticated"},
+		Extra:  map[string][]string{"scopes": {"all"}},
+	}, attributes)
+	assert.NoError(t, err)
+	assert.True(t, allowed)
+	assert.Equal(t, &authorizationv1.SubjectAccessReviewSpec{
+		ResourceAttributes: &attributes,
+		User:               "admin",
+		Groups:             []string{"system:authenticated"},
+		Extra:              map[string]authorizationv1.ExtraValue{"scopes": {"all"}},
+	}, reviewed)
+
+	allowed, err = Authorize(context.TODO(), c, &user.DefaultInfo{Name: "tester"}, attributes)
+	assert.NoError(t, err)
+	assert.False(t, allowed)
+}
@@ -34,6 +34,7 @@ import (
 	"github.com/kubesphere/ks-devops/pkg/constants"
 	"github.com/kubesphere/ks-devops/pkg/kapis"
 	"github.com/kubesphere/ks-devops/pkg/models/devops"
+	"github.com/kubesphere/ks-devops/pkg/models/pipelinerun"
 	"kubesphere.io/kubesphere/pkg/apiserver/query"
 )
 
@@ -393,6 +394,22 @@ func (h *ProjectPipelineHandler) hasSubmitPermission(req *restful.Request) (hasP
 	stepId := req.PathParameter("step")
 	branchName := req.PathParameter("branch")
 
+	// the input steps with an Approval are only approvable by the Approval API, the approvers and the decisions are
+	// checked and recorded there
+	if h.genericClient != nil {
+		var approval *v1alpha3.Approval
+		if approval, err = pipelinerun.GetInputApproval(req.Request.Context(), h.genericClient, pipeParam.ProjectName,
+			pipeParam.Name, branchName, runId, nodeId, stepId); err != nil {
+			klog.V(4).Infof("cannot get the approval of the input step, error: %v", err)
+			err = errors.New("cannot get the approval of current step")
+			return
+		} else if approval != nil {
+			err = fmt.Errorf("the step is waiting for the approval %s, please approve it by the Approval API of PipelineRun %s",
+				approval.Name, approval.Spec.PipelineRunRef.Name)
+			return
+		}
+	}
+
 	// check if current user can approve this input
 	var res []dclient.NodesDetail
 
@@ -18,11 +18,15 @@ package v1alpha2
 
 import (
 	"net/http"
+	"net/http/httptest"
 	"testing"
 
 	"github.com/emicklei/go-restful/v3"
 	"github.com/kubesphere/ks-devops/pkg/api/devops/v1alpha3"
 	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 )
 
 func TestParseNameFilterFromQuery(t *testing.T) {
@@ -98,3 +102,34 @@ func TestBuildPipelineSearchQueryParam(t *testing.T) {
 	assert.Equal(t, 20, query.Pagination.Offset)
 	assert.Equal(t, 20, query.Pagination.Limit)
 }
+
+func TestHasSubmitPermission_approval(t *testing.T) {
+	schema, err := v1alpha3.SchemeBuilder.Register().Build()
+	assert.Nil(t, err)
+	pr := &v1alpha3.PipelineRun{
+		ObjectMeta: v1.ObjectMeta{
+			Namespace:   "ns",
+			Name:        "run",
+			Annotations: map[string]string{v1alpha3.JenkinsPipelineRunIDAnnoKey: "1"},
+		},
+	}
+	approval := &v1alpha3.Approval{
+		ObjectMeta: v1.ObjectMeta{
+			Namespace: "ns",
+			Name:      "run-2-3",
+			Labels:    map[string]string{v1alpha3.PipelineNameLabelKey: "pipeline"},
+		},
+		Spec: v1alpha3.ApprovalSpec{PipelineRunRef: corev1.LocalObjectReference{Name: "run"}, NodeID: "2", StepID: "3"},
+	}
+	h := &ProjectPipelineHandler{genericClient: fake.NewClientBuilder().WithScheme(schema).WithObjects(pr, approval).Build()}
+
+	req := restful.NewRequest(httptest.NewRequest(http.MethodPost, "/submit", nil))
+	for key, value := range map[string]string{"devops": "ns", "pipeline": "pipeline", "run": "1", "node": "2", "step": "3"} {
+		req.PathParameters()[key] = value
+	}
+	ok, err := h.hasSubmitPermission(req)
+	assert.False(t, ok)
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "run-2-3")
+	}
+}
@@ -17,6 +17,8 @@ limitations under the License.
 package v1alpha2
 
 import (
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
 	"github.com/kubesphere/ks-devops/pkg/client/clientset/versioned"
 	dclient "github.com/kubesphere/ks-devops/pkg/client/devops"
 	"github.com/kubesphere/ks-devops/pkg/client/informers/externalversions"
@@ -30,6 +32,8 @@ type ProjectPipelineHandler struct {
 	k8sClient               k8s.Client
 	devopsOperator          devops.DevopsOperator
 	projectCredentialGetter devops.ProjectCredentialGetter
+	// genericClient finds the Approvals of the input steps, it's optional
+	genericClient client.Client
 }
 
 type PipelineSonarHandler struct {
@@ -37,11 +41,12 @@ type PipelineSonarHandler struct {
 	pipelineSonarGetter devops.PipelineSonarGetter
 }
 
-func NewProjectPipelineHandler(devopsClient dclient.Interface, k8sClient k8s.Client) ProjectPipelineHandler {
+func NewProjectPipelineHandler(devopsClient dclient.Interface, k8sClient k8s.Client, genericClient client.Client) ProjectPipelineHandler {
 	return ProjectPipelineHandler{
 		devopsOperator:          devops.NewDevopsOperator(devopsClient, k8sClient.Kubernetes(), k8sClient.KubeSphere(), nil),
 		projectCredentialGetter: devops.NewProjectCredentialOperator(devopsClient),
 		k8sClient:               k8sClient,
+		genericClient:           genericClient,
 	}
 }
 
@@ -30,6 +30,7 @@ import (
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/util/proxy"
 	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	"github.com/kubesphere/ks-devops/pkg/api"
 	apidevops "github.com/kubesphere/ks-devops/pkg/api/devops/v1alpha1"
@@ -53,12 +54,13 @@ var GroupVersion = schema.GroupVersion{Group: api.GroupName, Version: "v1alpha2"
 
 func AddToContainer(container *restful.Container, ksInformers externalversions.SharedInformerFactory,
 	devopsClient devops.Interface, sonarqubeClient sonarqube.SonarInterface, ksClient versioned.Interface,
-	s3Client s3.Interface, endpoint string, k8sClient k8s.Client, jenkinsClient core.JenkinsCore) (wss []*restful.WebService, err error) {
+	s3Client s3.Interface, endpoint string, k8sClient k8s.Client, jenkinsClient core.JenkinsCore,
+	genericClient client.Client) (wss []*restful.WebService, err error) {
 	wsWithGroup := runtime.NewWebService(GroupVersion)
 	wss = append(wss, wsWithGroup)
 	// the API endpoint with group version will be removed in the future release
 	if err = addToContainerWithWebService(container, ksInformers, devopsClient, sonarqubeClient, ksClient,
-		s3Client, endpoint, k8sClient, jenkinsClient, wsWithGroup); err != nil {
+		s3Client, endpoint, k8sClient, jenkinsClient, genericClient, wsWithGroup); err != nil {
 		return
 	}
 
@@ -67,8 +69,9 @@ func AddToContainer(container *restful.Container, ksInformers externalversions.S
 
 func addToContainerWithWebService(container *restful.Container, ksInformers externalversions.SharedInformerFactory,
 	devopsClient devops.Interface, sonarqubeClient sonarqube.SonarInterface, ksClient versioned.Interface,
-	s3Client s3.Interface, endpoint string, k8sClient k8s.Client, jenkinsClient core.JenkinsCore, ws *restful.WebService) error {
-	err := AddPipelineToWebService(ws, devopsClient, k8sClient)
+	s3Client s3.Interface, endpoint string, k8sClient k8s.Client, jenkinsClient core.JenkinsCore, genericClient client.Client,
+	ws *restful.WebService) error {
+	err := AddPipelineToWebService(ws, devopsClient, k8sClient, genericClient)
 	if err != nil {
 		return err
 	}
@@ -92,8 +95,9 @@ func addToContainerWithWebService(container *restful.Container, ksInformers exte
 	return nil
 }
 
-func AddPipelineToWebService(webservice *restful.WebService, devopsClient devops.Interface, k8sClient k8s.Client) error {
-	projectPipelineHandler := NewProjectPipelineHandler(devopsClient, k8sClient)
+func AddPipelineToWebService(webservice *restful.WebService, devopsClient devops.Interface, k8sClient k8s.Client,
+	genericClient client.Client) error {
+	projectPipelineHandler := NewProjectPipelineHandler(devopsClient, k8sClient, genericClient)
 
 	webservice.Route(webservice.GET("/namespaces/{devops}/credentials/{credential}/usage").
 		To(projectPipelineHandler.GetProjectCredentialUsage).
@@ -629,6 +633,10 @@ func AddPipelineToWebService(webservice *restful.WebService, devopsClient devops
 		To(projectPipelineHandler.CheckCron).
 		Metadata(restfulspec.KeyOpenAPITags, constants.DevOpsPipelineTags).
 		Param(webservice.PathParameter("devops", "DevOps project's ID, e.g. project-RRRRAzLBlLEm")).
+		Param(webservice.QueryParameter("value", "the cron, it's an alternative of the JSON body").Required(false)).
+		Param(webservice.QueryParameter("pipelineName", "the name of the Pipeline").Required(false)).
+		Param(webservice.QueryParameter("timeZone", "the IANA name of the time zone, it's UTC by default").Required(false)).
+		Consumes("application/x-www-form-urlencoded", "application/json").
 		Produces("application/json", "charset=utf-8").
 		Doc("Check cron script compile.").
 		Reads(devops.CronData{}).
@@ -51,7 +51,7 @@ func TestAPIsExist(t *testing.T) {
 		}), nil, "", k8s.NewFakeClientSets(k8sfake.NewSimpleClientset(), nil, nil, "", nil,
 			fakeclientset.NewSimpleClientset(&v1alpha3.DevOpsProject{
 				ObjectMeta: metav1.ObjectMeta{Name: "fake"},
-			})), core.JenkinsCore{})
+			})), core.JenkinsCore{}, nil)
 	assert.Nil(t, err)
 
 	// case 2, sonarqube client is valid
@@ -70,7 +70,7 @@ func TestAPIsExist(t *testing.T) {
 
 	_, err = AddToContainer(container, informerFactory.KubeSphereSharedInformerFactory(), fakedevops.NewFakeDevops(nil),
 		sonarqube.NewSonar(&sonargo.Client{}),
-		ksclient, fake.NewFakeS3(), "", k8sclient, core.JenkinsCore{})
+		ksclient, fake.NewFakeS3(), "", k8sclient, core.JenkinsCore{}, nil)
 	assert.Nil(t, err)
 
 	type args struct {
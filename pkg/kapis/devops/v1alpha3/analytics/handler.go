@@ -0,0 +1,207 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package analytics
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/emicklei/go-restful/v3"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/kubesphere/ks-devops/pkg/api/devops/v1alpha3"
+	"github.com/kubesphere/ks-devops/pkg/api/gitops/v1alpha1"
+	"github.com/kubesphere/ks-devops/pkg/client/cache"
+	"github.com/kubesphere/ks-devops/pkg/constants"
+	"github.com/kubesphere/ks-devops/pkg/kapis"
+	"github.com/kubesphere/ks-devops/pkg/models/analytics"
+)
+
+const (
+	// defaultWindow is the default time window of the analytics
+	defaultWindow = 30 * 24 * time.Hour
+	// maxWindow limits the time window of the analytics
+	maxWindow = 366 * 24 * time.Hour
+	// cacheExpiration is the expiration of the cached analytics
+	cacheExpiration = 5 * time.Minute
+)
+
+type handler struct {
+	client client.Client
+	cache  cache.Interface
+	now    func() time.Time
+}
+
+func newHandler(c client.Client, cacheClient cache.Interface) *handler {
+	return &handler{client: c, cache: cacheClient, now: time.Now}
+}
+
+// getDeliveryMetrics returns the DORA metrics of a DevOpsProject over a time window. The reports are cached, so the
+// latest PipelineRuns might be not counted in a few minutes.
+func (h *handler) getDeliveryMetrics(request *restful.Request, response *restful.Response) {
+	namespace := request.PathParameter("devops")
+	pipeline := request.QueryParameter("pipeline")
+	branch := request.QueryParameter("branch")
+	application := request.QueryParameter("application")
+	ctx := request.Request.Context()
+
+	start, end, err := h.getWindow(request)
+	if err != nil {
+		kapis.HandleBadRequest(response, request, err)
+		return
+	}
+	deploymentSelector, err := h.getDeploymentSelector(ctx, namespace, request.QueryParameter("deploymentSelector"))
+	if err != nil {
+		kapis.HandleError(request, response, err)
+		return
+	}
+	selector, err := labels.Parse(deploymentSelector)
+	if err == nil && pipeline != "" {
+		var requirement *labels.Requirement
+		if requirement, err = labels.NewRequirement(v1alpha3.PipelineNameLabelKey, selection.Equals, []string{pipeline}); err == nil {
+			selector = selector.Add(*requirement)
+		}
+	}
+	if err != nil {
+		kapis.HandleBadRequest(response, request, fmt.Errorf("invalid deployment selector: %v", err))
+		return
+	}
+
+	cacheKey := fmt.Sprintf("devops:analytics:%s:%d:%d:%s:%s:%s:%s", namespace, start.Unix(), end.Unix(), pipeline, branch,
+		application, deploymentSelector)
+	if report := h.getCachedReport(cacheKey); report != nil {
+		_ = response.WriteEntity(report)
+		return
+	}
+
+	var events []analytics.Event
+	// the PipelineRuns are deployments only if they are selected explicitly
+	if application == "" && deploymentSelector != "" {
+		prs := &v1alpha3.PipelineRunList{}
+		if err = h.client.List(ctx, prs, client.InNamespace(namespace), client.MatchingLabelsSelector{Selector: selector}); err != nil {
+			kapis.HandleError(request, response, err)
+			return
+		}
+		for i := range prs.Items {
+			if event, ok := analytics.GetPipelineRunEvent(&prs.Items[i]); ok && (branch == "" || event.Branch == branch) {
+				events = append(events, event)
+			}
+		}
+	}
+	if pipeline == "" && branch == "" {
+		apps := &v1alpha1.ApplicationList{}
+		if err = h.client.List(ctx, apps, client.InNamespace(namespace)); err != nil {
+			kapis.HandleError(request, response, err)
+			return
+		}
+		for i := range apps.Items {
+			if application == "" || apps.Items[i].Name == application {
+				events = append(events, analytics.GetApplicationEvents(&apps.Items[i])...)
+			}
+		}
+	}
+
+	report := analytics.Compute(namespace, events, start, end)
+	report.DeploymentSelector = deploymentSelector
+	h.setCachedReport(cacheKey, report)
+	_ = response.WriteEntity(report)
+}
+
+// getDeploymentSelector returns the label selector of the PipelineRuns which are deployments. It's taken from the query
+// parameter, then the DevOpsProject which the namespace belongs to. No PipelineRuns are deployments if it's empty.
+func (h *handler) getDeploymentSelector(ctx context.Context, namespace, selectorParam string) (selector string, err error) {
+	if selector = selectorParam; selector != "" {
+		return
+	}
+
+	ns := &corev1.Namespace{}
+	if err = h.client.Get(ctx, types.NamespacedName{Name: namespace}, ns); err != nil {
+		err = client.IgnoreNotFound(err)
+		return
+	}
+	projectName := ns.Labels[constants.DevOpsProjectLabelKey]
+	if projectName == "" {
+		return
+	}
+	project := &v1alpha3.DevOpsProject{}
+	if err = h.client.Get(ctx, types.NamespacedName{Name: projectName}, project); err != nil {
+		err = client.IgnoreNotFound(err)
+		return
+	}
+	selector = project.Annotations[v1alpha3.DeploymentSelectorAnnoKey]
+	return
+}
+
+// getWindow returns the time window from the query parameters, it's the last 30 days by default.
+// The default end time is truncated to minutes to reuse the cached reports.
+func (h *handler) getWindow(request *restful.Request) (start, end time.Time, err error) {
+	end = h.now().Truncate(time.Minute)
+	if endParam := request.QueryParameter("end"); endParam != "" {
+		if end, err = time.Parse(time.RFC3339, endParam); err != nil {
+			err = fmt.Errorf("invalid end time: %s", endParam)
+			return
+		}
+	}
+	start = end.Add(-defaultWindow)
+	if startParam := request.QueryParameter("start"); startParam != "" {
+		if start, err = time.Parse(time.RFC3339, startParam); err != nil {
+			err = fmt.Errorf("invalid start time: %s", startParam)
+			return
+		}
+	}
+	if !start.Before(end) {
+		err = fmt.Errorf("the start time %s should be before the end time %s", start.Format(time.RFC3339), end.Format(time.RFC3339))
+	} else if end.Sub(start) > maxWindow {
+		err = fmt.Errorf("the time window should not be longer than %v", maxWindow)
+	}
+	return
+}
+
+func (h *handler) getCachedReport(key string) *analytics.Report {
+	if h.cache == nil {
+		return nil
+	}
+	data, err := h.cache.Get(key)
+	if err != nil || data == "" {
+		return nil
+	}
+	report := &analytics.Report{}
+	if err = json.Unmarshal([]byte(data), report); err != nil {
+		return nil
+	}
+	return report
+}
+
+func (h *handler) setCachedReport(key string, report *analytics.Report) {
+	if h.cache == nil {
+		return
+	}
+	data, err := json.Marshal(report)
+	if err == nil {
+		err = h.cache.Set(key, string(data), cacheExpiration)
+	}
+	if err != nil {
+		klog.V(4).Infof("failed to cache the analytics report %s, error: %v", key, err)
+	}
+}
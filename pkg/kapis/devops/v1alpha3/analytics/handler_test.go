@@ -0,0 +1,255 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package analytics
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/emicklei/go-restful/v3"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	runtimeSchema "k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/kubesphere/ks-devops/pkg/api"
+	"github.com/kubesphere/ks-devops/pkg/api/devops/v1alpha3"
+	"github.com/kubesphere/ks-devops/pkg/api/gitops/v1alpha1"
+	ksruntime "github.com/kubesphere/ks-devops/pkg/apiserver/runtime"
+	"github.com/kubesphere/ks-devops/pkg/client/cache"
+	"github.com/kubesphere/ks-devops/pkg/constants"
+	"github.com/kubesphere/ks-devops/pkg/models/analytics"
+)
+
+func TestDeliveryMetricsAPI(t *testing.T) {
+	schema, err := v1alpha3.SchemeBuilder.Register().Build()
+	assert.Nil(t, err)
+	err = v1alpha1.AddToScheme(schema)
+	assert.Nil(t, err)
+	err = corev1.AddToScheme(schema)
+	assert.Nil(t, err)
+
+	const deploymentSelector = v1alpha3.PipelineNameLabelKey + " in (a,b)"
+	ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+		Name:   "ns",
+		Labels: map[string]string{constants.DevOpsProjectLabelKey: "project"},
+	}}
+	project := &v1alpha3.DevOpsProject{ObjectMeta: metav1.ObjectMeta{
+		Name:        "project",
+		Annotations: map[string]string{v1alpha3.DeploymentSelectorAnnoKey: deploymentSelector},
+	}}
+	completed := metav1.NewTime(time.Date(2022, 6, 2, 0, 0, 0, 0, time.UTC))
+	newPipelineRun := func(name, pipeline string, phase v1alpha3.RunPhase) *v1alpha3.PipelineRun {
+		return &v1alpha3.PipelineRun{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: "ns",
+				Name:      name,
+				Labels:    map[string]string{v1alpha3.PipelineNameLabelKey: pipeline},
+			},
+			Status: v1alpha3.PipelineRunStatus{Phase: phase, CompletionTime: &completed},
+		}
+	}
+	app := &v1alpha1.Application{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "app"},
+		Status: v1alpha1.ApplicationStatus{
+			ArgoApp: `{"history": [{"deployedAt": "2022-06-03T00:00:00Z"}]}`,
+		},
+	}
+	instances := []runtime.Object{
+		newPipelineRun("a-1", "a", v1alpha3.Succeeded),
+		newPipelineRun("a-2", "a", v1alpha3.Failed),
+		newPipelineRun("b-1", "b", v1alpha3.Succeeded),
+		newPipelineRun("b-2", "b", v1alpha3.Running),
+		newPipelineRun("test-1", "test", v1alpha3.Succeeded),
+		app,
+		ns,
+		project,
+	}
+	const window = "start=2022-06-01T00:00:00Z&end=2022-06-11T00:00:00Z"
+
+	tests := []struct {
+		name    string
+		uri     string
+		prepare func(cache.Interface)
+		verify  func(code int, report *analytics.Report, t *testing.T)
+	}{{
+		name: "invalid start time",
+		uri:  "/namespaces/ns/deliverymetrics?start=yesterday",
+		verify: func(code int, _ *analytics.Report, t *testing.T) {
+			assert.Equal(t, http.StatusBadRequest, code)
+		},
+	}, {
+		name: "the start time is after the end time",
+		uri:  "/namespaces/ns/deliverymetrics?start=2022-06-11T00:00:00Z&end=2022-06-01T00:00:00Z",
+		verify: func(code int, _ *analytics.Report, t *testing.T) {
+			assert.Equal(t, http.StatusBadRequest, code)
+		},
+	}, {
+		name: "the time window is too long",
+		uri:  "/namespaces/ns/deliverymetrics?start=2020-06-01T00:00:00Z&end=2022-06-01T00:00:00Z",
+		verify: func(code int, _ *analytics.Report, t *testing.T) {
+			assert.Equal(t, http.StatusBadRequest, code)
+		},
+	}, {
+		name: "the default time window",
+		uri:  "/namespaces/ns/deliverymetrics",
+		verify: func(code int, report *analytics.Report, t *testing.T) {
+			assert.Equal(t, http.StatusOK, code)
+			assert.Equal(t, 30*24*time.Hour, report.End.Sub(report.Start.Time))
+		},
+	}, {
+		name: "all the Pipelines and Applications",
+		uri:  "/namespaces/ns/deliverymetrics?" + window,
+		verify: func(code int, report *analytics.Report, t *testing.T) {
+			assert.Equal(t, http.StatusOK, code)
+			assert.Equal(t, "ns", report.Namespace)
+			assert.Equal(t, 3, report.Project.Deployments)
+			assert.Equal(t, 1, report.Project.Failures)
+			assert.Equal(t, 2, len(report.Pipelines))
+			assert.Equal(t, 1, len(report.Applications))
+			assert.Equal(t, deploymentSelector, report.DeploymentSelector)
+		},
+	}, {
+		name: "the deployment selector from the query",
+		uri:  "/namespaces/ns/deliverymetrics?deploymentSelector=" + url.QueryEscape(v1alpha3.PipelineNameLabelKey+"=test") + "&" + window,
+		verify: func(code int, report *analytics.Report, t *testing.T) {
+			assert.Equal(t, http.StatusOK, code)
+			assert.Equal(t, 2, report.Project.Deployments)
+			assert.Equal(t, []analytics.GroupMetrics{{
+				Pipeline: "test",
+				Metrics:  analytics.Metrics{Deployments: 1, DeploymentFrequency: 0.1},
+			}}, report.Pipelines)
+		},
+	}, {
+		name: "the selected PipelineRuns of a Pipeline",
+		uri:  "/namespaces/ns/deliverymetrics?pipeline=test&" + window,
+		verify: func(code int, report *analytics.Report, t *testing.T) {
+			assert.Equal(t, http.StatusOK, code)
+			assert.Equal(t, 0, report.Project.Deployments)
+		},
+	}, {
+		name: "invalid deployment selector",
+		uri:  "/namespaces/ns/deliverymetrics?deploymentSelector=a%3D%3D%3Db&" + window,
+		verify: func(code int, _ *analytics.Report, t *testing.T) {
+			assert.Equal(t, http.StatusBadRequest, code)
+		},
+	}, {
+		name: "filter by Pipeline",
+		uri:  "/namespaces/ns/deliverymetrics?pipeline=a&" + window,
+		verify: func(code int, report *analytics.Report, t *testing.T) {
+			assert.Equal(t, http.StatusOK, code)
+			assert.Equal(t, 1, report.Project.Deployments)
+			assert.Equal(t, 1, report.Project.Failures)
+			assert.Equal(t, 1, len(report.Pipelines))
+			assert.Empty(t, report.Applications)
+		},
+	}, {
+		name: "filter by Application",
+		uri:  "/namespaces/ns/deliverymetrics?application=app&" + window,
+		verify: func(code int, report *analytics.Report, t *testing.T) {
+			assert.Equal(t, http.StatusOK, code)
+			assert.Equal(t, 1, report.Project.Deployments)
+			assert.Empty(t, report.Pipelines)
+			assert.Equal(t, 1, len(report.Applications))
+		},
+	}, {
+		name: "cached report",
+		uri:  "/namespaces/ns/deliverymetrics?" + window,
+		prepare: func(cacheClient cache.Interface) {
+			start := time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC)
+			end := time.Date(2022, 6, 11, 0, 0, 0, 0, time.UTC)
+			data, _ := json.Marshal(&analytics.Report{Namespace: "ns", Project: analytics.Metrics{Deployments: 100}})
+			_ = cacheClient.Set("devops:analytics:ns:"+formatUnix(start)+":"+formatUnix(end)+":::"+":"+deploymentSelector, string(data), time.Minute)
+		},
+		verify: func(code int, report *analytics.Report, t *testing.T) {
+			assert.Equal(t, http.StatusOK, code)
+			assert.Equal(t, 100, report.Project.Deployments)
+		},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cacheClient := cache.NewSimpleCache()
+			if tt.prepare != nil {
+				tt.prepare(cacheClient)
+			}
+			c := fake.NewClientBuilder().WithScheme(schema).WithRuntimeObjects(instances...).Build()
+
+			ws := ksruntime.NewWebService(runtimeSchema.GroupVersion{Group: api.GroupName, Version: "v1alpha3"})
+			RegisterRoutes(ws, c, cacheClient)
+			container := restful.NewContainer()
+			container.Add(ws)
+
+			httpRequest, _ := http.NewRequest(http.MethodGet, "http://fake.com/kapis/devops.kubesphere.io/v1alpha3"+tt.uri, nil)
+			httpWriter := httptest.NewRecorder()
+			container.Dispatch(httpWriter, httpRequest)
+
+			report := &analytics.Report{}
+			if httpWriter.Code == http.StatusOK {
+				assert.Nil(t, json.Unmarshal(httpWriter.Body.Bytes(), report))
+			}
+			tt.verify(httpWriter.Code, report, t)
+		})
+	}
+}
+
+func TestReportIsCached(t *testing.T) {
+	schema, err := v1alpha3.SchemeBuilder.Register().Build()
+	assert.Nil(t, err)
+	err = v1alpha1.AddToScheme(schema)
+	assert.Nil(t, err)
+	err = corev1.AddToScheme(schema)
+	assert.Nil(t, err)
+
+	now := time.Date(2022, 6, 11, 0, 0, 30, 0, time.UTC)
+	h := &handler{
+		client: fake.NewClientBuilder().WithScheme(schema).Build(),
+		cache:  cache.NewSimpleCache(),
+		now:    func() time.Time { return now },
+	}
+	ws := ksruntime.NewWebService(runtimeSchema.GroupVersion{Group: api.GroupName, Version: "v1alpha3"})
+	ws.Route(ws.GET("/namespaces/{devops}/deliverymetrics").To(h.getDeliveryMetrics))
+	container := restful.NewContainer()
+	container.Add(ws)
+
+	httpRequest, _ := http.NewRequest(http.MethodGet, "http://fake.com/kapis/devops.kubesphere.io/v1alpha3/namespaces/ns/deliverymetrics", nil)
+	httpWriter := httptest.NewRecorder()
+	container.Dispatch(httpWriter, httpRequest)
+	assert.Equal(t, http.StatusOK, httpWriter.Code)
+
+	end := now.Truncate(time.Minute)
+	report := h.getCachedReport("devops:analytics:ns:" + formatUnix(end.Add(-defaultWindow)) + ":" + formatUnix(end) + "::::")
+	if assert.NotNil(t, report) {
+		assert.Equal(t, "ns", report.Namespace)
+		assert.Equal(t, end, report.End.UTC())
+	}
+
+	// the handler without a cache client works as well
+	h.cache = nil
+	assert.Nil(t, h.getCachedReport("key"))
+	h.setCachedReport("key", report)
+}
+
+func formatUnix(t time.Time) string {
+	return strconv.FormatInt(t.Unix(), 10)
+}
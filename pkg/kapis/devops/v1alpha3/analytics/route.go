@@ -0,0 +1,55 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package analytics
+
+import (
+	"net/http"
+
+	restfulspec "github.com/emicklei/go-restful-openapi"
+	"github.com/emicklei/go-restful/v3"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/kubesphere/ks-devops/pkg/api"
+	"github.com/kubesphere/ks-devops/pkg/api/devops/v1alpha3"
+	"github.com/kubesphere/ks-devops/pkg/client/cache"
+	"github.com/kubesphere/ks-devops/pkg/constants"
+	"github.com/kubesphere/ks-devops/pkg/models/analytics"
+)
+
+//+kubebuilder:rbac:groups=gitops.kubesphere.io,resources=applications,verbs=get;list;watch
+//+kubebuilder:rbac:groups=devops.kubesphere.io,resources=devopsprojects,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch
+
+// RegisterRoutes registers the routes of the delivery analytics, the reports are cached if the cache client is not nil
+func RegisterRoutes(ws *restful.WebService, c client.Client, cacheClient cache.Interface) {
+	h := newHandler(c, cacheClient)
+
+	ws.Route(ws.GET("/namespaces/{devops}/deliverymetrics").
+		To(h.getDeliveryMetrics).
+		Doc("Get the deployment frequency, lead time for changes, change failure rate and mean time to restore of a "+
+			"DevOpsProject, and the ones of its Pipelines, branches and Applications").
+		Metadata(restfulspec.KeyOpenAPITags, constants.DevOpsAnalyticsTags).
+		Param(ws.PathParameter("devops", "The namespace of the DevOpsProject")).
+		Param(ws.QueryParameter("start", "The start time in RFC3339, it's 30 days before the end time by default")).
+		Param(ws.QueryParameter("end", "The end time in RFC3339, it's now by default")).
+		Param(ws.QueryParameter("pipeline", "Only count the PipelineRuns of the Pipeline")).
+		Param(ws.QueryParameter("branch", "Only count the PipelineRuns of the branch")).
+		Param(ws.QueryParameter("application", "Only count the deployments of the Application")).
+		Param(ws.QueryParameter("deploymentSelector", "The label selector of the PipelineRuns which are deployments, "+
+			"it's the annotation "+v1alpha3.DeploymentSelectorAnnoKey+" of the DevOpsProject by default")).
+		Returns(http.StatusOK, api.StatusOK, analytics.Report{}))
+}
@@ -999,6 +999,71 @@ func (s *gitRepoService) GetFile(ctx context.Context, input *GetFileInput) (*Get
 	return out, nil
 }
 
+func (s *gitRepoService) GetTree(ctx context.Context, input *GetTreeInput) (*GetTreeOutput, error) {
+	if err := s.fetchOrigin(""); err != nil {
+		klog.Warningf("failed to fetch origin, use the local refs instead: %v", err)
+	}
+
+	hash, err := s.resolveRevision(input.Revision)
+	if err != nil {
+		return nil, err
+	}
+	commit, err := s.repo.CommitObject(*hash)
+	if err != nil {
+		return nil, err
+	}
+	files, err := commit.Files()
+	if err != nil {
+		return nil, err
+	}
+	defer files.Close()
+
+	out := &GetTreeOutput{
+		Commit: convertCommit(commit),
+		Files:  map[string][]byte{},
+	}
+	err = files.ForEach(func(file *object.File) error {
+		if !file.Mode.IsFile() || file.Mode == filemode.Symlink || file.Size > UploadDownloadFileSizeLimit {
+			return nil
+		}
+		if isBinary, err := file.IsBinary(); err != nil || isBinary {
+			return err
+		}
+		content, err := file.Contents()
+		if err != nil {
+			return err
+		}
+		out.Files[file.Name] = []byte(content)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// resolveRevision resolves a branch or tag of origin, or a commit hash
+func (s *gitRepoService) resolveRevision(revision string) (*plumbing.Hash, error) {
+	candidates := []string{"refs/remotes/origin/HEAD", "HEAD"}
+	if revision != "" && revision != "HEAD" {
+		candidates = []string{
+			"refs/remotes/origin/" + revision,
+			"refs/tags/" + revision,
+			"refs/heads/" + revision,
+			revision,
+		}
+	}
+
+	var err error
+	for _, candidate := range candidates {
+		var hash *plumbing.Hash
+		if hash, err = s.repo.ResolveRevision(plumbing.Revision(candidate)); err == nil {
+			return hash, nil
+		}
+	}
+	return nil, fmt.Errorf("revision %s not found: %v", revision, err)
+}
+
 var _ GitRepoService = &gitRepoService{}
 
 func NewGitRepoService(opts *GitRepoOptions) GitRepoService {
@@ -250,6 +250,17 @@ type GetFileOutput struct {
 	Reader io.ReadCloser `json:"-"` // Note: the reader might be closed if File.Data is not empty
 }
 
+// GetTreeInput gets all the files of a revision, the revision could be a branch, a tag or a commit hash
+type GetTreeInput struct {
+	Revision string `json:"revision"` // the default branch of origin if it's empty or HEAD
+}
+
+type GetTreeOutput struct {
+	Commit *Commit `json:"commit"`
+	// Files are the regular text files keyed by the path, the binary and the large files are skipped
+	Files map[string][]byte `json:"-"`
+}
+
 type UploadFilesInput struct {
 	Files []*FileNameData `json:"files"`
 }
@@ -268,6 +279,7 @@ type GitRepoService interface {
 	DeleteFiles(ctx context.Context, input *DeleteFilesInput) (*DeleteFilesOutput, error)
 	ListFiles(ctx context.Context, input *ListFilesInput) (*ListFilesOutput, error)
 	GetFile(ctx context.Context, input *GetFileInput) (*GetFileOutput, error)
+	GetTree(ctx context.Context, input *GetTreeInput) (*GetTreeOutput, error)
 	CommitAndPush(ctx context.Context, input *CommitAndPushInput) (*CommitAndPushOutput, error)
 	CleanAndPull(ctx context.Context, input *CleanAndPullInput) (*CleanAndPullOutput, error)
 	DeleteClone(ctx context.Context, input *DeleteCloneInput) (*DeleteCloneOutput, error)
@@ -215,6 +215,7 @@ func (h *devopsHandler) CreatePipeline(request *restful.Request, response *restf
 		return
 	}
 
+	pipeline.SetSpecAuthor(getUserName(request), "")
 	if devopsOperator, err := h.getDevOps(request); err == nil {
 		created, err := devopsOperator.CreatePipelineObj(devops, &pipeline)
 		errorHandle(request, response, created, err)
@@ -235,6 +236,7 @@ func (h *devopsHandler) UpdatePipeline(request *restful.Request, response *restf
 		return
 	}
 
+	pipeline.SetSpecAuthor(getUserName(request), "")
 	if devopsOperator, err := h.getDevOps(request); err == nil {
 		obj, err := devopsOperator.UpdatePipelineObj(devops, &pipeline)
 		errorHandle(request, response, obj, err)
@@ -243,6 +245,14 @@ func (h *devopsHandler) UpdatePipeline(request *restful.Request, response *restf
 	}
 }
 
+// getUserName returns the name of the request user, it's recorded as the author of the Pipeline spec change
+func getUserName(request *restful.Request) string {
+	if requestUser, ok := apiserverRequest.UserFrom(request.Request.Context()); ok && requestUser != nil {
+		return requestUser.GetName()
+	}
+	return ""
+}
+
 // GenericPayload represents a generic HTTP request payload data structure
 type GenericPayload struct {
 	Data string `json:"data"`
@@ -289,7 +299,7 @@ func (h *devopsHandler) UpdateJenkinsfile(request *restful.Request, response *re
 
 	var devopsOperator devopsModel.DevopsOperator
 	if devopsOperator, err = h.getDevOps(request); err == nil {
-		err = devopsOperator.UpdateJenkinsfile(projectName, pipelineName, mode, payload.Data)
+		err = devopsOperator.UpdateJenkinsfile(projectName, pipelineName, mode, payload.Data, getUserName(request))
 	}
 	errorHandle(request, response, NewSuccessResponse(), err)
 }
@@ -382,6 +392,10 @@ func errorHandle(request *restful.Request, response *restful.Response, obj inter
 			kapis.HandleNotFound(response, request, err)
 			return
 		}
+		if errors.IsForbidden(err) {
+			kapis.HandleForbidden(response, request, err)
+			return
+		}
 		kapis.HandleBadRequest(response, request, err)
 		return
 	}
@@ -25,6 +25,7 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	"github.com/kubesphere/ks-devops/pkg/api"
+	"github.com/kubesphere/ks-devops/pkg/api/devops/v1alpha3"
 	"github.com/kubesphere/ks-devops/pkg/constants"
 	"github.com/kubesphere/ks-devops/pkg/models/pipeline"
 )
@@ -56,4 +57,42 @@ func RegisterRoutes(ws *restful.WebService, c client.Client) {
 		Param(ws.PathParameter("pipeline", "Name of the Pipeline")).
 		Param(ws.PathParameter("branch", "Name of branch, tag or pull request")).
 		Returns(http.StatusOK, api.StatusOK, pipeline.Branch{}))
+
+	ws.Route(ws.GET("/namespaces/{namespace}/pipelines/{pipeline}/revisions").
+		To(handler.listRevisions).
+		Metadata(restfulspec.KeyOpenAPITags, constants.DevOpsPipelineTags).
+		Doc("Paging query the spec revisions of the Pipeline, the latest one goes first").
+		Param(ws.PathParameter("namespace", "Namespace of the Pipeline")).
+		Param(ws.PathParameter("pipeline", "Name of the Pipeline")).
+		Param(ws.QueryParameter(query.ParameterPage, "page").Required(false).DataFormat("page=%d").DefaultValue("page=1")).
+		Param(ws.QueryParameter(query.ParameterLimit, "limit").Required(false)).
+		Returns(http.StatusOK, api.StatusOK, api.ListResult{Items: []interface{}{v1alpha3.PipelineRevision{}}}))
+
+	ws.Route(ws.GET("/namespaces/{namespace}/pipelines/{pipeline}/revisions/{revision}").
+		To(handler.getRevision).
+		Metadata(restfulspec.KeyOpenAPITags, constants.DevOpsPipelineTags).
+		Doc("Get a spec revision of the Pipeline").
+		Param(ws.PathParameter("namespace", "Namespace of the Pipeline")).
+		Param(ws.PathParameter("pipeline", "Name of the Pipeline")).
+		Param(ws.PathParameter("revision", "Sequence number of the revision")).
+		Returns(http.StatusOK, api.StatusOK, v1alpha3.PipelineRevision{}))
+
+	ws.Route(ws.GET("/namespaces/{namespace}/pipelines/{pipeline}/revisions/{revision}/diff").
+		To(handler.getRevisionDiff).
+		Metadata(restfulspec.KeyOpenAPITags, constants.DevOpsPipelineTags).
+		Doc("Get the diff between two spec revisions of the Pipeline").
+		Param(ws.PathParameter("namespace", "Namespace of the Pipeline")).
+		Param(ws.PathParameter("pipeline", "Name of the Pipeline")).
+		Param(ws.PathParameter("revision", "Sequence number of the target revision")).
+		Param(ws.QueryParameter("base", "Sequence number of the base revision, it's the previous revision by default").Required(false)).
+		Returns(http.StatusOK, api.StatusOK, pipeline.RevisionDiff{}))
+
+	ws.Route(ws.POST("/namespaces/{namespace}/pipelines/{pipeline}/revisions/{revision}/rollback").
+		To(handler.rollback).
+		Metadata(restfulspec.KeyOpenAPITags, constants.DevOpsPipelineTags).
+		Doc("Roll back the Pipeline to a spec revision, the rollback is recorded as a new revision").
+		Param(ws.PathParameter("namespace", "Namespace of the Pipeline")).
+		Param(ws.PathParameter("pipeline", "Name of the Pipeline")).
+		Param(ws.PathParameter("revision", "Sequence number of the revision")).
+		Returns(http.StatusOK, api.StatusOK, v1alpha3.Pipeline{}))
 }
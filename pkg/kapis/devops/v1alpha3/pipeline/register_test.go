@@ -57,6 +57,12 @@ func TestAPIsExist(t *testing.T) {
 			method: http.MethodGet,
 			uri:    "/namespaces/fake/pipelines/fake/branches/fake",
 		},
+	}, {
+		name: "get revisions of the pipeline",
+		args: args{
+			method: http.MethodGet,
+			uri:    "/namespaces/fake/pipelines/fake/revisions",
+		},
 	}}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
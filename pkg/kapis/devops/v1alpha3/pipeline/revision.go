@@ -0,0 +1,141 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipeline
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/emicklei/go-restful/v3"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"kubesphere.io/kubesphere/pkg/apiserver/query"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/kubesphere/ks-devops/pkg/api"
+	"github.com/kubesphere/ks-devops/pkg/api/devops/v1alpha3"
+	apiserverrequest "github.com/kubesphere/ks-devops/pkg/apiserver/request"
+	"github.com/kubesphere/ks-devops/pkg/kapis"
+	models "github.com/kubesphere/ks-devops/pkg/models/pipeline"
+)
+
+func (h *apiHandler) listRevisions(request *restful.Request, response *restful.Response) {
+	namespaceName := request.PathParameter("namespace")
+	pipelineName := request.PathParameter("pipeline")
+
+	revisions, err := models.ListRevisions(request.Request.Context(), h.client, namespaceName, pipelineName)
+	if err != nil {
+		kapis.HandleError(request, response, err)
+		return
+	}
+
+	queryParam := query.ParseQueryParameter(request)
+	total := len(revisions)
+	startIndex, endIndex := queryParam.Pagination.GetValidPagination(total)
+	items := make([]interface{}, 0, endIndex-startIndex)
+	for i := startIndex; i < endIndex; i++ {
+		items = append(items, revisions[i])
+	}
+	_ = response.WriteEntity(api.NewListResult(items, total))
+}
+
+func (h *apiHandler) getRevision(request *restful.Request, response *restful.Response) {
+	if revision, err := h.getRevisionByParameter(request, request.PathParameter("revision")); err != nil {
+		kapis.HandleError(request, response, err)
+	} else {
+		_ = response.WriteEntity(revision)
+	}
+}
+
+// getRevisionDiff returns the diff between a revision and the base revision, the base is the previous revision by default
+func (h *apiHandler) getRevisionDiff(request *restful.Request, response *restful.Response) {
+	target, err := h.getRevisionByParameter(request, request.PathParameter("revision"))
+	if err != nil {
+		kapis.HandleError(request, response, err)
+		return
+	}
+
+	result := &models.RevisionDiff{To: target.Spec.Revision}
+	var base *v1alpha3.PipelineRevision
+	if baseParam := request.QueryParameter("base"); baseParam != "" {
+		base, err = h.getRevisionByParameter(request, baseParam)
+	} else if target.Spec.Revision > 1 {
+		base, err = h.getRevisionByParameter(request, strconv.FormatInt(target.Spec.Revision-1, 10))
+	}
+	if err != nil {
+		kapis.HandleError(request, response, err)
+		return
+	}
+
+	var baseSpec *v1alpha3.PipelineSpec
+	baseName := ""
+	if base != nil {
+		result.From = base.Spec.Revision
+		baseSpec, baseName = &base.Spec.Pipeline, base.Name
+	}
+	if result.Diff, err = models.DiffSpecs(baseSpec, &target.Spec.Pipeline, baseName, target.Name); err != nil {
+		kapis.HandleError(request, response, err)
+		return
+	}
+	_ = response.WriteEntity(result)
+}
+
+// rollback sets the definition of a Pipeline to a revision, a new revision is going to be recorded for it
+func (h *apiHandler) rollback(request *restful.Request, response *restful.Response) {
+	namespaceName := request.PathParameter("namespace")
+	pipelineName := request.PathParameter("pipeline")
+	ctx := request.Request.Context()
+
+	user, ok := apiserverrequest.UserFrom(ctx)
+	if !ok || user == nil {
+		// should never happen
+		kapis.HandleUnauthorized(response, request, fmt.Errorf("unauthenticated user entered to roll back Pipeline '%s/%s'", namespaceName, pipelineName))
+		return
+	}
+
+	revision, err := h.getRevisionByParameter(request, request.PathParameter("revision"))
+	if err != nil {
+		kapis.HandleError(request, response, err)
+		return
+	}
+
+	pipeline := &v1alpha3.Pipeline{}
+	if err = h.client.Get(ctx, client.ObjectKey{Namespace: namespaceName, Name: pipelineName}, pipeline); err != nil {
+		kapis.HandleError(request, response, err)
+		return
+	}
+	if pipeline.IsGitManaged() {
+		kapis.HandleForbidden(response, request, fmt.Errorf("the Pipeline is managed by GitRepository %s, please roll back %s in the repository instead",
+			pipeline.Spec.Source.GitRepository, pipeline.Spec.Source.Path))
+		return
+	}
+
+	models.Rollback(pipeline, revision, user.GetName())
+	if err = h.client.Update(ctx, pipeline); err != nil {
+		kapis.HandleError(request, response, err)
+		return
+	}
+	_ = response.WriteEntity(pipeline)
+}
+
+func (h *apiHandler) getRevisionByParameter(request *restful.Request, revisionParam string) (*v1alpha3.PipelineRevision, error) {
+	revision, err := strconv.ParseInt(revisionParam, 10, 64)
+	if err != nil || revision < 1 {
+		return nil, errors.NewBadRequest(fmt.Sprintf("invalid revision: %s", revisionParam))
+	}
+	return models.GetRevision(request.Request.Context(), h.client, request.PathParameter("namespace"),
+		request.PathParameter("pipeline"), revision)
+}
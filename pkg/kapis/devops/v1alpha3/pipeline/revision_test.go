@@ -0,0 +1,149 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/emicklei/go-restful/v3"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/kubesphere/ks-devops/pkg/api/devops/v1alpha3"
+	apiserverrequest "github.com/kubesphere/ks-devops/pkg/apiserver/request"
+	models "github.com/kubesphere/ks-devops/pkg/models/pipeline"
+)
+
+func newRevisionRequest(revision, base string, withUser bool) *restful.Request {
+	httpRequest := httptest.NewRequest(http.MethodGet, "/namespaces/ns/pipelines/demo/revisions?base="+base, nil)
+	if withUser {
+		httpRequest = httpRequest.WithContext(apiserverrequest.WithUser(httpRequest.Context(), &user.DefaultInfo{Name: "admin"}))
+	}
+	request := restful.NewRequest(httpRequest)
+	request.PathParameters()["namespace"] = "ns"
+	request.PathParameters()["pipeline"] = "demo"
+	request.PathParameters()["revision"] = revision
+	return request
+}
+
+func newRevisionResponse() (*restful.Response, *httptest.ResponseRecorder) {
+	recorder := httptest.NewRecorder()
+	response := restful.NewResponse(recorder)
+	response.SetRequestAccepts(restful.MIME_JSON)
+	return response, recorder
+}
+
+func TestRevisionAPIs(t *testing.T) {
+	schema, err := v1alpha3.SchemeBuilder.Register().Build()
+	assert.Nil(t, err)
+
+	pipeline := &v1alpha3.Pipeline{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "demo"},
+		Spec: v1alpha3.PipelineSpec{
+			Type:     v1alpha3.NoScmPipelineType,
+			Pipeline: &v1alpha3.NoScmPipeline{Name: "demo", Jenkinsfile: "pipeline {}"},
+		},
+	}
+	c := fake.NewClientBuilder().WithScheme(schema).WithObjects(pipeline.DeepCopy()).Build()
+	ctx := context.Background()
+	for _, jenkinsfile := range []string{"pipeline {}", "pipeline { }", "pipeline {  }"} {
+		pipeline.Spec.Pipeline.Jenkinsfile = jenkinsfile
+		_, _, err = models.EnsureRevision(ctx, c, pipeline)
+		assert.Nil(t, err)
+	}
+	h := newAPIHandler(apiHandlerOption{client: c})
+
+	t.Run("list revisions", func(t *testing.T) {
+		response, recorder := newRevisionResponse()
+		h.listRevisions(newRevisionRequest("", "", false), response)
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		result := &struct {
+			Items      []v1alpha3.PipelineRevision `json:"items"`
+			TotalItems int                         `json:"totalItems"`
+		}{}
+		assert.Nil(t, json.Unmarshal(recorder.Body.Bytes(), result))
+		assert.Equal(t, 3, result.TotalItems)
+		assert.Equal(t, "demo-3", result.Items[0].Name)
+	})
+
+	t.Run("get a revision", func(t *testing.T) {
+		response, recorder := newRevisionResponse()
+		h.getRevision(newRevisionRequest("2", "", false), response)
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.Contains(t, recorder.Body.String(), `"revision": 2`)
+
+		response, recorder = newRevisionResponse()
+		h.getRevision(newRevisionRequest("4", "", false), response)
+		assert.Equal(t, http.StatusNotFound, recorder.Code)
+
+		response, recorder = newRevisionResponse()
+		h.getRevision(newRevisionRequest("latest", "", false), response)
+		assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	})
+
+	t.Run("diff", func(t *testing.T) {
+		response, recorder := newRevisionResponse()
+		h.getRevisionDiff(newRevisionRequest("3", "", false), response)
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		diff := &models.RevisionDiff{}
+		assert.Nil(t, json.Unmarshal(recorder.Body.Bytes(), diff))
+		assert.Equal(t, int64(2), diff.From)
+		assert.Equal(t, int64(3), diff.To)
+		assert.Contains(t, diff.Diff, "-  jenkinsfile: pipeline { }\n+  jenkinsfile: pipeline {  }\n")
+
+		response, recorder = newRevisionResponse()
+		h.getRevisionDiff(newRevisionRequest("3", "1", false), response)
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.Nil(t, json.Unmarshal(recorder.Body.Bytes(), diff))
+		assert.Equal(t, int64(1), diff.From)
+		assert.Contains(t, diff.Diff, "-  jenkinsfile: pipeline {}\n")
+
+		response, recorder = newRevisionResponse()
+		h.getRevisionDiff(newRevisionRequest("1", "", false), response)
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.Nil(t, json.Unmarshal(recorder.Body.Bytes(), diff))
+		assert.Equal(t, int64(0), diff.From)
+		assert.Contains(t, diff.Diff, "+  jenkinsfile: pipeline {}\n")
+	})
+
+	t.Run("rollback", func(t *testing.T) {
+		response, recorder := newRevisionResponse()
+		h.rollback(newRevisionRequest("1", "", false), response)
+		assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+
+		response, recorder = newRevisionResponse()
+		h.rollback(newRevisionRequest("1", "", true), response)
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		latest := &v1alpha3.Pipeline{}
+		assert.Nil(t, c.Get(ctx, client.ObjectKeyFromObject(pipeline), latest))
+		assert.Equal(t, "pipeline {}", latest.Spec.Pipeline.Jenkinsfile)
+		assert.Equal(t, "admin", latest.Annotations[v1alpha3.PipelineSpecAuthorAnnoKey])
+
+		latest.Spec.Source = &v1alpha3.PipelineSource{GitRepository: "repo", Path: "Jenkinsfile"}
+		assert.Nil(t, c.Update(ctx, latest))
+		response, recorder = newRevisionResponse()
+		h.rollback(newRevisionRequest("2", "", true), response)
+		assert.Equal(t, http.StatusForbidden, recorder.Code)
+	})
+}
@@ -0,0 +1,198 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinerun
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/emicklei/go-restful/v3"
+	rbacv1 "k8s.io/api/rbac/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/kubesphere/ks-devops/pkg/api/devops/v1alpha3"
+	apiserverrequest "github.com/kubesphere/ks-devops/pkg/apiserver/request"
+	"github.com/kubesphere/ks-devops/pkg/kapis"
+	"github.com/kubesphere/ks-devops/pkg/models/pipelinerun"
+)
+
+// listApprovals returns the Approvals of a PipelineRun
+func (h *apiHandler) listApprovals(request *restful.Request, response *restful.Response) {
+	nsName := request.PathParameter("namespace")
+	prName := request.PathParameter("pipelinerun")
+
+	approvals, err := h.getApprovals(request.Request.Context(), nsName, prName)
+	if err != nil {
+		kapis.HandleError(request, response, err)
+		return
+	}
+	_ = response.WriteEntity(approvals)
+}
+
+// submitApproval approves or rejects an Approval on behalf of the current user. The decision is recorded in the status
+// of the Approval first, then it's forwarded to Jenkins once the Approval is rejected or gets enough approvals. The
+// PipelineRun controller forwards it again if it failed here.
+func (h *apiHandler) submitApproval(request *restful.Request, response *restful.Response) {
+	nsName := request.PathParameter("namespace")
+	prName := request.PathParameter("pipelinerun")
+	approvalName := request.PathParameter("approval")
+	ctx := request.Request.Context()
+
+	payload := pipelinerun.ApprovalRequest{}
+	if err := request.ReadEntity(&payload); err != nil {
+		kapis.HandleBadRequest(response, request, err)
+		return
+	}
+	if payload.Decision != v1alpha3.ApprovalDecisionApprove && payload.Decision != v1alpha3.ApprovalDecisionReject {
+		kapis.HandleBadRequest(response, request, fmt.Errorf("invalid decision: %q", payload.Decision))
+		return
+	}
+
+	currentUser, ok := apiserverrequest.UserFrom(ctx)
+	if !ok || currentUser == nil || currentUser.GetName() == "" {
+		kapis.HandleUnauthorized(response, request, fmt.Errorf("unauthenticated user entered to approve '%s/%s'", nsName, approvalName))
+		return
+	}
+
+	pr := &v1alpha3.PipelineRun{}
+	if err := h.client.Get(ctx, client.ObjectKey{Namespace: nsName, Name: prName}, pr); err != nil {
+		kapis.HandleError(request, response, err)
+		return
+	}
+	approval := &v1alpha3.Approval{}
+	if err := h.client.Get(ctx, client.ObjectKey{Namespace: nsName, Name: approvalName}, approval); err != nil {
+		kapis.HandleError(request, response, err)
+		return
+	}
+	if approval.Spec.PipelineRunRef.Name != prName {
+		kapis.HandleBadRequest(response, request, fmt.Errorf("approval %s does not belong to PipelineRun %s", approvalName, prName))
+		return
+	}
+	if approval.HasCompleted() {
+		kapis.HandleConflict(response, request, fmt.Errorf("approval %s has already been %s", approvalName, approval.Status.Phase))
+		return
+	}
+	if approval.HasDecided(currentUser.GetName()) {
+		kapis.HandleConflict(response, request, fmt.Errorf("user %s has already made a decision on approval %s", currentUser.GetName(), approvalName))
+		return
+	}
+
+	roleBindings, err := h.getRoleBindings(ctx, nsName, approval.Spec.Approvers)
+	if err != nil {
+		kapis.HandleError(request, response, err)
+		return
+	}
+	if !pipelinerun.IsApprover(approval.Spec.Approvers, currentUser, roleBindings) {
+		kapis.HandleForbidden(response, request, fmt.Errorf("user %s is not allowed to approve %s", currentUser.GetName(), approvalName))
+		return
+	}
+
+	now := v1.Now()
+	approval.Status.Phase = v1alpha3.ApprovalPending
+	approval.Status.Decisions = append(approval.Status.Decisions, v1alpha3.ApprovalDecision{
+		User:       currentUser.GetName(),
+		Decision:   payload.Decision,
+		Time:       now,
+		Comment:    payload.Comment,
+		Parameters: payload.Parameters,
+	})
+	if payload.Decision == v1alpha3.ApprovalDecisionReject {
+		approval.Status.Phase = v1alpha3.ApprovalRejected
+	} else if approval.CountApprovals() >= approval.GetRequiredApprovals() {
+		approval.Status.Phase = v1alpha3.ApprovalApproved
+	}
+
+	if approval.HasCompleted() {
+		approval.Status.CompletionTime = &now
+	}
+	// the resource version makes sure that no decisions are lost, and Jenkins gets the decision after it was recorded
+	if err := h.client.Status().Update(ctx, approval); err != nil {
+		kapis.HandleError(request, response, err)
+		return
+	}
+
+	if approval.NeedsSubmission() {
+		if err := pipelinerun.SubmitApproval(h.devopsClient, pr, approval); err != nil {
+			// the decision has been recorded, the PipelineRun controller forwards it later
+			klog.Errorf("failed to submit the approval %s/%s to Jenkins, error: %v", nsName, approvalName, err)
+		} else {
+			approval.Status.Submitted = true
+			if err := h.client.Status().Update(ctx, approval); err != nil {
+				klog.Errorf("failed to mark the approval %s/%s as submitted, error: %v", nsName, approvalName, err)
+			}
+		}
+	}
+	_ = response.WriteEntity(approval)
+}
+
+// getApprovals returns the Approvals of a PipelineRun
+func (h *apiHandler) getApprovals(ctx context.Context, nsName, prName string) (*v1alpha3.ApprovalList, error) {
+	approvals := &v1alpha3.ApprovalList{}
+	err := h.client.List(ctx, approvals, client.InNamespace(nsName),
+		client.MatchingLabels{v1alpha3.PipelineRunNameLabelKey: prName})
+	return approvals, err
+}
+
+// getRoleBindings returns the RoleBindings in the namespace, they are only required if there are approver roles
+func (h *apiHandler) getRoleBindings(ctx context.Context, nsName string, approvers v1alpha3.Approvers) ([]rbacv1.RoleBinding, error) {
+	if !approvers.HasRoles() {
+		return nil, nil
+	}
+	roleBindings := &rbacv1.RoleBindingList{}
+	if err := h.client.List(ctx, roleBindings, client.InNamespace(nsName)); err != nil {
+		return nil, err
+	}
+	return roleBindings.Items, nil
+}
+
+// setApprovable sets the approvable field of the steps for the current user. The steps without an Approval are
+// approvable for backward compatibility, the permission of them is checked by Jenkins.
+func (h *apiHandler) setApprovable(ctx context.Context, pr *v1alpha3.PipelineRun, stages []pipelinerun.NodeDetail,
+	currentUser user.Info) error {
+	approvals, err := h.getApprovals(ctx, pr.Namespace, pr.Name)
+	if err != nil {
+		return err
+	}
+	approvalMap := map[string]*v1alpha3.Approval{}
+	var roleBindings []rbacv1.RoleBinding
+	for i := range approvals.Items {
+		approval := &approvals.Items[i]
+		approvalMap[pipelinerun.GetApprovalName(pr.Name, approval.Spec.NodeID, approval.Spec.StepID)] = approval
+		if approval.Spec.Approvers.HasRoles() && roleBindings == nil {
+			if roleBindings, err = h.getRoleBindings(ctx, pr.Namespace, approval.Spec.Approvers); err != nil {
+				return err
+			}
+		}
+	}
+
+	for i := range stages {
+		for j := range stages[i].Steps {
+			step := &stages[i].Steps[j]
+			approval, ok := approvalMap[pipelinerun.GetApprovalName(pr.Name, stages[i].ID, step.ID)]
+			if !ok {
+				step.Approvable = true
+				continue
+			}
+			step.Approvable = currentUser != nil && !approval.HasCompleted() && !approval.HasDecided(currentUser.GetName()) &&
+				pipelinerun.IsApprover(approval.Spec.Approvers, currentUser, roleBindings)
+		}
+	}
+	return nil
+}
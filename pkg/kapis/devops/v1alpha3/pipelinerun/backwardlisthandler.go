@@ -20,8 +20,7 @@ import (
 	"context"
 
 	"github.com/kubesphere/ks-devops/pkg/api"
-	cmstore "github.com/kubesphere/ks-devops/pkg/store/configmap"
-	"sigs.k8s.io/controller-runtime/pkg/client"
+	"github.com/kubesphere/ks-devops/pkg/store/store"
 
 	"github.com/kubesphere/ks-devops/pkg/api/devops/v1alpha3"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -32,7 +31,7 @@ import (
 )
 
 type backwardListHandler struct {
-	client client.Client
+	dataStore store.Provider
 }
 
 func (b backwardListHandler) Comparator() resourcesv1alpha3.CompareFunc {
@@ -65,13 +64,13 @@ func (b backwardListHandler) backwardFilter(object runtime.Object) bool {
 	if pr, valid := checkPipelineRun(object); valid {
 		statusJSON, ok := pr.Annotations[v1alpha3.JenkinsPipelineRunStatusAnnoKey]
 		if !ok {
-			if pipelineRunStore, err := cmstore.NewConfigMapStore(context.Background(), types.NamespacedName{
+			if pipelineRunStore, err := b.dataStore.Get(context.Background(), types.NamespacedName{
 				Namespace: pr.Namespace,
 				Name:      pr.Name,
-			}, b.client); err == nil {
+			}); err == nil {
 				statusJSON = pipelineRunStore.GetStatus()
 			} else {
-				klog.Error(err, "failed to get status from configmap store")
+				klog.Error(err, "failed to get status from the data store")
 			}
 		}
 
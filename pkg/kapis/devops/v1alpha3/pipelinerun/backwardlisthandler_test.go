@@ -22,6 +22,7 @@ import (
 	"testing"
 
 	"github.com/kubesphere/ks-devops/pkg/api/devops/v1alpha3"
+	cmstore "github.com/kubesphere/ks-devops/pkg/store/configmap"
 	"github.com/stretchr/testify/assert"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -160,7 +161,7 @@ func Test_backwardFilter(t *testing.T) {
 
 			handler := backwardListHandler{}
 			if tt.args.obj != nil {
-				handler.client = fake.NewClientBuilder().WithScheme(schema).WithObjects(tt.args.obj).Build()
+				handler.dataStore = cmstore.NewProvider(fake.NewClientBuilder().WithScheme(schema).WithObjects(tt.args.obj).Build())
 			}
 			if got := handler.Filter()(tt.args.obj, tt.args.filter); !reflect.DeepEqual(got, tt.want) {
 				t.Errorf("backwardFilter() = %v, want %v", got, tt.want)
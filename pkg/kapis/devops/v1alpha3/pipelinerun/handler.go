@@ -27,6 +27,7 @@ import (
 	"strconv"
 
 	cmstore "github.com/kubesphere/ks-devops/pkg/store/configmap"
+	"github.com/kubesphere/ks-devops/pkg/store/store"
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/types"
 
@@ -37,7 +38,6 @@ import (
 
 	"github.com/kubesphere/ks-devops/pkg/api/devops/v1alpha3"
 	apiserverrequest "github.com/kubesphere/ks-devops/pkg/apiserver/request"
-	"github.com/kubesphere/ks-devops/pkg/client/devops"
 	devopsClient "github.com/kubesphere/ks-devops/pkg/client/devops"
 	"github.com/kubesphere/ks-devops/pkg/models/pipelinerun"
 	"kubesphere.io/kubesphere/pkg/apiserver/query"
@@ -48,6 +48,15 @@ import (
 type apiHandlerOption struct {
 	devopsClient devopsClient.Interface
 	client       client.Client
+	dataStore    store.Provider
+}
+
+// getDataStore returns the provider of PipelineRun data stores, the ConfigMap stores are the default ones
+func (o apiHandlerOption) getDataStore() store.Provider {
+	if o.dataStore == nil {
+		return cmstore.NewProvider(o.client)
+	}
+	return o.dataStore
 }
 
 // apiHandler contains functions to handle coming request and give a response.
@@ -101,12 +110,12 @@ func (h *apiHandler) listPipelineRuns(request *restful.Request, response *restfu
 		return
 	}
 
-	lh := listHandler{ctx: request.Request.Context(), client: h.client}
+	lh := listHandler{ctx: request.Request.Context(), dataStore: h.getDataStore()}
 	compareFunc := lh.Comparator()
 	filterFunc := lh.Filter()
 	transformFunc := lh.Transformer()
 	if backward {
-		blh := backwardListHandler{client: h.client}
+		blh := backwardListHandler{dataStore: h.getDataStore()}
 		compareFunc = blh.Comparator()
 		filterFunc = blh.Filter()
 		transformFunc = blh.Transformer()
@@ -120,11 +129,17 @@ func (h *apiHandler) createPipelineRun(request *restful.Request, response *restf
 	nsName := request.PathParameter("namespace")
 	pipName := request.PathParameter("pipeline")
 	branch := request.QueryParameter("branch")
-	payload := devops.RunPayload{}
+	payload := pipelinerun.RunPayload{}
 	if err := request.ReadEntity(&payload); err != nil && err != io.EOF {
 		kapis.HandleBadRequest(response, request, err)
 		return
 	}
+	if payload.Matrix != nil {
+		if _, err := payload.Matrix.GetCombinations(); err != nil {
+			kapis.HandleBadRequest(response, request, fmt.Errorf("invalid matrix: %v", err))
+			return
+		}
+	}
 	// validate the Pipeline
 	var pipeline v1alpha3.Pipeline
 	if err := h.client.Get(context.Background(), client.ObjectKey{Namespace: nsName, Name: pipName}, &pipeline); err != nil {
@@ -150,7 +165,8 @@ func (h *apiHandler) createPipelineRun(request *restful.Request, response *restf
 		return
 	}
 	// create PipelineRun
-	pr := CreatePipelineRun(&pipeline, &payload, scm)
+	pr := CreatePipelineRun(&pipeline, &payload.RunPayload, scm)
+	pr.Spec.Matrix = payload.Matrix
 	if user.GetName() != "" {
 		pr.GetAnnotations()[v1alpha3.PipelineRunCreatorAnnoKey] = user.GetName()
 	}
@@ -176,8 +192,8 @@ func (h *apiHandler) getPipelineRun(request *restful.Request, response *restful.
 
 	// get status
 	if _, ok := pr.Annotations[v1alpha3.JenkinsPipelineRunStatusAnnoKey]; !ok {
-		pipelineRunStore, err := cmstore.NewConfigMapStore(ctx, types.NamespacedName{
-			Namespace: nsName, Name: prName}, h.client)
+		pipelineRunStore, err := h.getDataStore().Get(ctx, types.NamespacedName{
+			Namespace: nsName, Name: prName})
 		if err != nil && !errors.IsNotFound(err) {
 			kapis.HandleError(request, response, err)
 			return
@@ -190,6 +206,69 @@ func (h *apiHandler) getPipelineRun(request *restful.Request, response *restful.
 	_ = response.WriteEntity(&pr)
 }
 
+// getPipelineRunAttempts returns all the attempts of a PipelineRun which was retried, ordered from the first one
+func (h *apiHandler) getPipelineRunAttempts(request *restful.Request, response *restful.Response) {
+	nsName := request.PathParameter("namespace")
+	prName := request.PathParameter("pipelinerun")
+	ctx := request.Request.Context()
+
+	pr := &v1alpha3.PipelineRun{}
+	if err := h.client.Get(ctx, client.ObjectKey{Namespace: nsName, Name: prName}, pr); err != nil {
+		kapis.HandleError(request, response, err)
+		return
+	}
+
+	// all the attempts have the same Pipeline label
+	opts := []client.ListOption{client.InNamespace(nsName)}
+	if pipelineName := pr.Labels[v1alpha3.PipelineNameLabelKey]; pipelineName != "" {
+		opts = append(opts, client.MatchingLabels{v1alpha3.PipelineNameLabelKey: pipelineName})
+	}
+	prs := &v1alpha3.PipelineRunList{}
+	if err := h.client.List(ctx, prs, opts...); err != nil {
+		kapis.HandleError(request, response, err)
+		return
+	}
+
+	_ = response.WriteEntity(&v1alpha3.PipelineRunList{Items: buildAttemptChain(prs.Items, prName)})
+}
+
+// getMatrixTable returns the combinations of a matrix PipelineRun with the phases of their runs
+func (h *apiHandler) getMatrixTable(request *restful.Request, response *restful.Response) {
+	nsName := request.PathParameter("namespace")
+	prName := request.PathParameter("pipelinerun")
+
+	pr := &v1alpha3.PipelineRun{}
+	if err := h.client.Get(request.Request.Context(), client.ObjectKey{Namespace: nsName, Name: prName}, pr); err != nil {
+		kapis.HandleError(request, response, err)
+		return
+	}
+	table, err := pipelinerun.GetMatrixTable(pr)
+	if err != nil {
+		kapis.HandleBadRequest(response, request, err)
+		return
+	}
+	_ = response.WriteEntity(table)
+}
+
+// getChain returns the chain of a PipelineRun which was triggered by, or triggered other PipelineRuns
+func (h *apiHandler) getChain(request *restful.Request, response *restful.Response) {
+	nsName := request.PathParameter("namespace")
+	prName := request.PathParameter("pipelinerun")
+	ctx := request.Request.Context()
+
+	pr := &v1alpha3.PipelineRun{}
+	if err := h.client.Get(ctx, client.ObjectKey{Namespace: nsName, Name: prName}, pr); err != nil {
+		kapis.HandleError(request, response, err)
+		return
+	}
+	chain, err := pipelinerun.GetChain(ctx, h.client, pr)
+	if err != nil {
+		kapis.HandleError(request, response, err)
+		return
+	}
+	_ = response.WriteEntity(chain)
+}
+
 func (h *apiHandler) getNodeDetails(request *restful.Request, response *restful.Response) {
 	namespaceName := request.PathParameter("namespace")
 	pipelineRunName := request.PathParameter("pipelinerun")
@@ -205,10 +284,10 @@ func (h *apiHandler) getNodeDetails(request *restful.Request, response *restful.
 	// get stage status
 	stagesJSON, ok := pr.Annotations[v1alpha3.JenkinsPipelineRunStagesStatusAnnoKey]
 	if !ok {
-		if pipelineRunStore, err := cmstore.NewConfigMapStore(ctx, types.NamespacedName{
+		if pipelineRunStore, err := h.getDataStore().Get(ctx, types.NamespacedName{
 			Namespace: namespaceName,
 			Name:      pipelineRunName,
-		}, h.client); err != nil {
+		}); err != nil {
 			// If the stages status does not exist, set it as an empty array
 			stagesJSON = "[]"
 		} else {
@@ -222,12 +301,10 @@ func (h *apiHandler) getNodeDetails(request *restful.Request, response *restful.
 		return
 	}
 
-	// TODO(johnniang): Check current user Handle the approvable field of NodeDetail
-	// this is a temporary solution of approvable
-	for i := range stages {
-		for j := range stages[i].Steps {
-			stages[i].Steps[j].Approvable = true
-		}
+	currentUser, _ := apiserverrequest.UserFrom(ctx)
+	if err := h.setApprovable(ctx, pr, stages, currentUser); err != nil {
+		kapis.HandleError(request, response, err)
+		return
 	}
 
 	_ = response.WriteEntity(&stages)
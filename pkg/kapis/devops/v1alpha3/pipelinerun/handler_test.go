@@ -51,7 +51,7 @@ func TestApis(t *testing.T) {
 		Spec: v1alpha3.PipelineSpec{
 			Type: v1alpha3.NoScmPipelineType,
 		},
-	}).Build())
+	}).Build(), nil)
 	restful.DefaultContainer.Add(wsWithGroup)
 
 	type args struct {
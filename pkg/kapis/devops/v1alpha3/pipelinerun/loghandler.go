@@ -0,0 +1,122 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinerun
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/emicklei/go-restful/v3"
+	"github.com/kubesphere/ks-devops/pkg/api/devops/v1alpha3"
+	"github.com/kubesphere/ks-devops/pkg/kapis"
+	"github.com/kubesphere/ks-devops/pkg/store/store"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// logPollInterval is the interval of checking new log when following the log
+var logPollInterval = 2 * time.Second
+
+// getPipelineRunLog returns the whole log of a PipelineRun from the data store
+func (h *apiHandler) getPipelineRunLog(request *restful.Request, response *restful.Response) {
+	h.writeLog(request, response, func(dataStore store.PipelineRunDataStore) string {
+		return dataStore.GetAllLog()
+	})
+}
+
+// getStepLog returns the log of a step of a PipelineRun from the data store
+func (h *apiHandler) getStepLog(request *restful.Request, response *restful.Response) {
+	stage, err := strconv.Atoi(request.PathParameter("node"))
+	if err != nil {
+		kapis.HandleBadRequest(response, request, fmt.Errorf("invalid node ID: %s", request.PathParameter("node")))
+		return
+	}
+	step, err := strconv.Atoi(request.PathParameter("step"))
+	if err != nil {
+		kapis.HandleBadRequest(response, request, fmt.Errorf("invalid step ID: %s", request.PathParameter("step")))
+		return
+	}
+	h.writeLog(request, response, func(dataStore store.PipelineRunDataStore) string {
+		return dataStore.GetStepLog(stage, step)
+	})
+}
+
+// writeLog writes the log from the start offset. The header X-Text-Size is the offset of next request, and the
+// header X-More-Data indicates if the PipelineRun is still running. It keeps writing the new log in chunks until the
+// PipelineRun completed if the query parameter follow is true.
+func (h *apiHandler) writeLog(request *restful.Request, response *restful.Response,
+	getLog func(dataStore store.PipelineRunDataStore) string) {
+	key := client.ObjectKey{Namespace: request.PathParameter("namespace"), Name: request.PathParameter("pipelinerun")}
+	ctx := request.Request.Context()
+
+	var start int64
+	if startParam := request.QueryParameter("start"); startParam != "" {
+		var err error
+		if start, err = strconv.ParseInt(startParam, 10, 64); err != nil || start < 0 {
+			kapis.HandleBadRequest(response, request, fmt.Errorf("invalid start offset: %s", startParam))
+			return
+		}
+	}
+	follow, _ := strconv.ParseBool(request.QueryParameter("follow"))
+
+	for written := false; ; written = true {
+		pr := &v1alpha3.PipelineRun{}
+		if err := h.client.Get(ctx, key, pr); err != nil {
+			if !written {
+				kapis.HandleError(request, response, err)
+			}
+			return
+		}
+		dataStore, err := h.getDataStore().Get(ctx, key)
+		if err != nil {
+			if !written {
+				kapis.HandleError(request, response, err)
+			}
+			return
+		}
+
+		var text string
+		if log := getLog(dataStore); start < int64(len(log)) {
+			text = log[start:]
+			start = int64(len(log))
+		}
+		completed := pr.HasCompleted()
+
+		if !written {
+			response.AddHeader("Content-Type", "text/plain; charset=utf-8")
+			response.AddHeader("X-Text-Size", strconv.FormatInt(start, 10))
+			response.AddHeader("X-More-Data", strconv.FormatBool(!completed))
+			response.WriteHeader(http.StatusOK)
+		}
+		if text != "" {
+			if _, err = response.Write([]byte(text)); err != nil {
+				return
+			}
+			response.Flush()
+		}
+		if !follow || completed {
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(logPollInterval):
+		}
+	}
+}
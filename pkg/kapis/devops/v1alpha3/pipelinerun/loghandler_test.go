@@ -0,0 +1,203 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinerun
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/emicklei/go-restful/v3"
+	"github.com/kubesphere/ks-devops/pkg/api/devops/v1alpha3"
+	"github.com/kubesphere/ks-devops/pkg/store/store"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestPipelineRunLog(t *testing.T) {
+	schema, err := v1alpha3.SchemeBuilder.Register().Build()
+	assert.Nil(t, err)
+	err = v1.SchemeBuilder.AddToScheme(schema)
+	assert.Nil(t, err)
+
+	pipelineRun := &v1alpha3.PipelineRun{}
+	pipelineRun.SetName("pr1")
+	pipelineRun.SetNamespace("ns")
+
+	cm := &v1.ConfigMap{Data: map[string]string{}}
+	cm.SetName(pipelineRun.GetName())
+	cm.SetNamespace(pipelineRun.GetNamespace())
+	cm.Data[store.DataKeyAllLog] = "hello world"
+	cm.Data[store.StepLogKey(3, 4)] = "step log"
+
+	tests := []struct {
+		name            string
+		query           string
+		node            string
+		step            string
+		wantCode        int
+		wantBody        string
+		wantTextSize    string
+		wantMoreData    string
+		completedBefore bool
+	}{{
+		name:         "the whole log",
+		wantCode:     http.StatusOK,
+		wantBody:     "hello world",
+		wantTextSize: "11",
+		wantMoreData: "true",
+	}, {
+		name:         "the log from an offset",
+		query:        "?start=6",
+		wantCode:     http.StatusOK,
+		wantBody:     "world",
+		wantTextSize: "11",
+		wantMoreData: "true",
+	}, {
+		name:         "the offset is beyond the log",
+		query:        "?start=20",
+		wantCode:     http.StatusOK,
+		wantBody:     "",
+		wantTextSize: "20",
+		wantMoreData: "true",
+	}, {
+		name:         "the log of a step",
+		node:         "3",
+		step:         "4",
+		wantCode:     http.StatusOK,
+		wantBody:     "step log",
+		wantTextSize: "8",
+		wantMoreData: "true",
+	}, {
+		name:            "follow the log of a completed PipelineRun",
+		query:           "?follow=true",
+		completedBefore: true,
+		wantCode:        http.StatusOK,
+		wantBody:        "hello world",
+		wantTextSize:    "11",
+		wantMoreData:    "false",
+	}, {
+		name:     "invalid offset",
+		query:    "?start=-1",
+		wantCode: http.StatusBadRequest,
+	}, {
+		name:     "invalid node",
+		node:     "a",
+		step:     "4",
+		wantCode: http.StatusBadRequest,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pr := pipelineRun.DeepCopy()
+			if tt.completedBefore {
+				pr.Status.CompletionTime = &metav1.Time{Time: time.Now()}
+			}
+			handler := &apiHandler{
+				apiHandlerOption: apiHandlerOption{
+					client: fake.NewClientBuilder().WithScheme(schema).WithObjects(pr, cm.DeepCopy()).Build(),
+				},
+			}
+
+			recorder := httptest.NewRecorder()
+			req := restful.NewRequest(httptest.NewRequest(http.MethodGet, "/log"+tt.query, nil))
+			req.PathParameters()["namespace"] = "ns"
+			req.PathParameters()["pipelinerun"] = "pr1"
+			resp := restful.NewResponse(recorder)
+			if tt.node != "" {
+				req.PathParameters()["node"] = tt.node
+				req.PathParameters()["step"] = tt.step
+				handler.getStepLog(req, resp)
+			} else {
+				handler.getPipelineRunLog(req, resp)
+			}
+
+			assert.Equal(t, tt.wantCode, recorder.Code)
+			if tt.wantCode == http.StatusOK {
+				assert.Equal(t, tt.wantBody, recorder.Body.String())
+				assert.Equal(t, tt.wantTextSize, recorder.Header().Get("X-Text-Size"))
+				assert.Equal(t, tt.wantMoreData, recorder.Header().Get("X-More-Data"))
+			}
+		})
+	}
+}
+
+func TestPipelineRunLog_follow(t *testing.T) {
+	schema, err := v1alpha3.SchemeBuilder.Register().Build()
+	assert.Nil(t, err)
+	err = v1.SchemeBuilder.AddToScheme(schema)
+	assert.Nil(t, err)
+
+	pipelineRun := &v1alpha3.PipelineRun{}
+	pipelineRun.SetName("pr1")
+	pipelineRun.SetNamespace("ns")
+
+	cm := &v1.ConfigMap{Data: map[string]string{}}
+	cm.SetName(pipelineRun.GetName())
+	cm.SetNamespace(pipelineRun.GetNamespace())
+	cm.Data[store.DataKeyAllLog] = "hello"
+
+	c := fake.NewClientBuilder().WithScheme(schema).WithObjects(pipelineRun, cm).Build()
+	handler := &apiHandler{apiHandlerOption: apiHandlerOption{client: c}}
+
+	defaultInterval := logPollInterval
+	logPollInterval = 10 * time.Millisecond
+	defer func() {
+		logPollInterval = defaultInterval
+	}()
+
+	// append the log, then complete the PipelineRun
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		latestCM := &v1.ConfigMap{}
+		assert.Nil(t, c.Get(context.TODO(), types.NamespacedName{Namespace: "ns", Name: "pr1"}, latestCM))
+		latestCM.Data[store.DataKeyAllLog] = "hello world"
+		assert.Nil(t, c.Update(context.TODO(), latestCM))
+
+		time.Sleep(50 * time.Millisecond)
+		latestPR := &v1alpha3.PipelineRun{}
+		assert.Nil(t, c.Get(context.TODO(), types.NamespacedName{Namespace: "ns", Name: "pr1"}, latestPR))
+		latestPR.Status.CompletionTime = &metav1.Time{Time: time.Now()}
+		assert.Nil(t, c.Update(context.TODO(), latestPR))
+	}()
+
+	recorder := httptest.NewRecorder()
+	req := restful.NewRequest(httptest.NewRequest(http.MethodGet, "/log?follow=true", nil))
+	req.PathParameters()["namespace"] = "ns"
+	req.PathParameters()["pipelinerun"] = "pr1"
+	handler.getPipelineRunLog(req, restful.NewResponse(recorder))
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "hello world", recorder.Body.String())
+	assert.True(t, recorder.Flushed)
+
+	// stop following once the request was canceled
+	handler.client = fake.NewClientBuilder().WithScheme(schema).WithObjects(pipelineRun, cm).Build()
+	ctx, cancel := context.WithCancel(context.TODO())
+	cancel()
+	recorder = httptest.NewRecorder()
+	req = restful.NewRequest(httptest.NewRequest(http.MethodGet, "/log?follow=true&start=2", nil).WithContext(ctx))
+	req.PathParameters()["namespace"] = "ns"
+	req.PathParameters()["pipelinerun"] = "pr1"
+	handler.getPipelineRunLog(req, restful.NewResponse(recorder))
+	assert.Equal(t, "llo", recorder.Body.String())
+	assert.Equal(t, "true", recorder.Header().Get("X-More-Data"))
+}
@@ -26,17 +26,19 @@ import (
 
 	"github.com/kubesphere/ks-devops/pkg/api"
 	"github.com/kubesphere/ks-devops/pkg/api/devops/v1alpha3"
-	"github.com/kubesphere/ks-devops/pkg/client/devops"
 	dclient "github.com/kubesphere/ks-devops/pkg/client/devops"
 	"github.com/kubesphere/ks-devops/pkg/constants"
 	"github.com/kubesphere/ks-devops/pkg/models/pipelinerun"
+	"github.com/kubesphere/ks-devops/pkg/models/testreport"
+	"github.com/kubesphere/ks-devops/pkg/store/store"
 )
 
 // RegisterRoutes register routes into web service.
-func RegisterRoutes(ws *restful.WebService, devopsClient dclient.Interface, c client.Client) {
+func RegisterRoutes(ws *restful.WebService, devopsClient dclient.Interface, c client.Client, dataStore store.Provider) {
 	handler := newAPIHandler(apiHandlerOption{
 		devopsClient: devopsClient,
 		client:       c,
+		dataStore:    dataStore,
 	})
 
 	ws.Route(ws.GET("/namespaces/{namespace}/pipelines/{pipeline}/pipelineruns").
@@ -57,12 +59,13 @@ func RegisterRoutes(ws *restful.WebService, devopsClient dclient.Interface, c cl
 
 	ws.Route(ws.POST("/namespaces/{namespace}/pipelines/{pipeline}/pipelineruns").
 		To(handler.createPipelineRun).
-		Doc("Create a PipelineRun for the specified pipeline").
+		Doc("Create a PipelineRun for the specified pipeline. With a matrix, it creates a PipelineRun which runs once "+
+			"per combination of the parameter values").
 		Metadata(restfulspec.KeyOpenAPITags, constants.DevOpsPipelineTags).
 		Param(ws.PathParameter("namespace", "Namespace of the pipeline")).
 		Param(ws.PathParameter("pipeline", "Name of the pipeline")).
 		Param(ws.QueryParameter("branch", "The name of SCM reference, only for multi-branch pipeline")).
-		Reads(devops.RunPayload{}).
+		Reads(pipelinerun.RunPayload{}).
 		Returns(http.StatusCreated, api.StatusOK, v1alpha3.PipelineRun{}))
 
 	ws.Route(ws.GET("/namespaces/{namespace}/pipelineruns/{pipelinerun}").
@@ -73,6 +76,30 @@ func RegisterRoutes(ws *restful.WebService, devopsClient dclient.Interface, c cl
 		Param(ws.PathParameter("pipelinerun", "Name of the PipelineRun")).
 		Returns(http.StatusOK, api.StatusOK, v1alpha3.PipelineRun{}))
 
+	ws.Route(ws.GET("/namespaces/{namespace}/pipelineruns/{pipelinerun}/attempts").
+		To(handler.getPipelineRunAttempts).
+		Doc("Get all the attempts of a PipelineRun which was retried by the retry policy, ordered from the first one").
+		Metadata(restfulspec.KeyOpenAPITags, constants.DevOpsPipelineTags).
+		Param(ws.PathParameter("namespace", "Namespace of the PipelineRun")).
+		Param(ws.PathParameter("pipelinerun", "Name of the PipelineRun")).
+		Returns(http.StatusOK, api.StatusOK, v1alpha3.PipelineRunList{}))
+
+	ws.Route(ws.GET("/namespaces/{namespace}/pipelineruns/{pipelinerun}/matrix").
+		To(handler.getMatrixTable).
+		Doc("Get the combinations of a matrix PipelineRun and the phases of their runs").
+		Metadata(restfulspec.KeyOpenAPITags, constants.DevOpsPipelineTags).
+		Param(ws.PathParameter("namespace", "Namespace of the PipelineRun")).
+		Param(ws.PathParameter("pipelinerun", "Name of the PipelineRun")).
+		Returns(http.StatusOK, api.StatusOK, pipelinerun.MatrixTable{}))
+
+	ws.Route(ws.GET("/namespaces/{namespace}/pipelineruns/{pipelinerun}/chain").
+		To(handler.getChain).
+		Doc("Get the chain of the PipelineRuns triggered by the upstream triggers in the same namespace, from the first upstream PipelineRun").
+		Metadata(restfulspec.KeyOpenAPITags, constants.DevOpsPipelineTags).
+		Param(ws.PathParameter("namespace", "Namespace of the PipelineRun")).
+		Param(ws.PathParameter("pipelinerun", "Name of the PipelineRun")).
+		Returns(http.StatusOK, api.StatusOK, []pipelinerun.ChainNode{}))
+
 	ws.Route(ws.GET("/namespaces/{namespace}/pipelineruns/{pipelinerun}/nodedetails").
 		To(handler.getNodeDetails).
 		Doc("Get node details including steps and approvable for a given Pipeline").
@@ -81,6 +108,86 @@ func RegisterRoutes(ws *restful.WebService, devopsClient dclient.Interface, c cl
 		Param(ws.PathParameter("pipelinerun", "Name of the PipelineRun")).
 		Returns(http.StatusOK, api.StatusOK, []pipelinerun.NodeDetail{}))
 
+	ws.Route(ws.GET("/namespaces/{namespace}/pipelineruns/{pipelinerun}/approvals").
+		To(handler.listApprovals).
+		Doc("Get the approvals of the input steps of a PipelineRun").
+		Metadata(restfulspec.KeyOpenAPITags, constants.DevOpsPipelineTags).
+		Param(ws.PathParameter("namespace", "Namespace of the PipelineRun")).
+		Param(ws.PathParameter("pipelinerun", "Name of the PipelineRun")).
+		Returns(http.StatusOK, api.StatusOK, v1alpha3.ApprovalList{}))
+
+	ws.Route(ws.POST("/namespaces/{namespace}/pipelineruns/{pipelinerun}/approvals/{approval}").
+		To(handler.submitApproval).
+		Doc("Approve or reject an input step of a PipelineRun as the current user, the decision is forwarded to Jenkins "+
+			"once the approval is rejected or gets enough approvals").
+		Metadata(restfulspec.KeyOpenAPITags, constants.DevOpsPipelineTags).
+		Param(ws.PathParameter("namespace", "Namespace of the PipelineRun")).
+		Param(ws.PathParameter("pipelinerun", "Name of the PipelineRun")).
+		Param(ws.PathParameter("approval", "Name of the approval")).
+		Reads(pipelinerun.ApprovalRequest{}).
+		Returns(http.StatusOK, api.StatusOK, v1alpha3.Approval{}))
+
+	ws.Route(ws.GET("/namespaces/{namespace}/pipelineruns/{pipelinerun}/testreport").
+		To(handler.getTestReport).
+		Doc("Get the test report of a PipelineRun, including the summary and the failed cases").
+		Metadata(restfulspec.KeyOpenAPITags, constants.DevOpsPipelineTags).
+		Param(ws.PathParameter("namespace", "Namespace of the PipelineRun")).
+		Param(ws.PathParameter("pipelinerun", "Name of the PipelineRun")).
+		Returns(http.StatusOK, api.StatusOK, testreport.Report{}))
+
+	ws.Route(ws.POST("/namespaces/{namespace}/pipelineruns/{pipelinerun}/testreport").
+		To(handler.uploadTestReport).
+		Doc("Upload a JUnit XML report, it's merged into the test report of a PipelineRun").
+		Metadata(restfulspec.KeyOpenAPITags, constants.DevOpsPipelineTags).
+		Consumes("application/xml", "text/xml").
+		Param(ws.PathParameter("namespace", "Namespace of the PipelineRun")).
+		Param(ws.PathParameter("pipelinerun", "Name of the PipelineRun")).
+		Returns(http.StatusOK, api.StatusOK, testreport.Report{}))
+
+	ws.Route(ws.GET("/namespaces/{namespace}/pipelines/{pipeline}/testtrend").
+		To(handler.getTestTrend).
+		Doc("Get the test summaries of the latest PipelineRuns of a Pipeline, from the latest one").
+		Metadata(restfulspec.KeyOpenAPITags, constants.DevOpsPipelineTags).
+		Param(ws.PathParameter("namespace", "Namespace of the pipeline")).
+		Param(ws.PathParameter("pipeline", "Name of the pipeline")).
+		Param(ws.QueryParameter("branch", "The name of SCM reference, only for multi-branch pipeline")).
+		Param(ws.QueryParameter("limit", "The number of the latest PipelineRuns").DataType("integer").DefaultValue("20")).
+		Returns(http.StatusOK, api.StatusOK, []testreport.TrendItem{}))
+
+	ws.Route(ws.GET("/namespaces/{namespace}/pipelines/{pipeline}/flakytests").
+		To(handler.getFlakyTests).
+		Doc("Get the tests which both passed and failed on the same commit in the latest PipelineRuns of a Pipeline").
+		Metadata(restfulspec.KeyOpenAPITags, constants.DevOpsPipelineTags).
+		Param(ws.PathParameter("namespace", "Namespace of the pipeline")).
+		Param(ws.PathParameter("pipeline", "Name of the pipeline")).
+		Param(ws.QueryParameter("branch", "The name of SCM reference, only for multi-branch pipeline")).
+		Param(ws.QueryParameter("limit", "The number of the latest PipelineRuns").DataType("integer").DefaultValue("20")).
+		Returns(http.StatusOK, api.StatusOK, []testreport.FlakyTest{}))
+
+	ws.Route(ws.GET("/namespaces/{namespace}/pipelineruns/{pipelinerun}/log").
+		To(handler.getPipelineRunLog).
+		Doc("Get the log of a PipelineRun, it's still available after the Jenkins build was dropped").
+		Metadata(restfulspec.KeyOpenAPITags, constants.DevOpsPipelineTags).
+		Produces("text/plain; charset=utf-8").
+		Param(ws.PathParameter("namespace", "Namespace of the PipelineRun")).
+		Param(ws.PathParameter("pipelinerun", "Name of the PipelineRun")).
+		Param(ws.QueryParameter("start", "The offset of the log to start from").DataType("integer").DefaultValue("0")).
+		Param(ws.QueryParameter("follow", "Keep streaming the log until the PipelineRun completed").DataType("boolean").DefaultValue("false")).
+		Returns(http.StatusOK, api.StatusOK, nil))
+
+	ws.Route(ws.GET("/namespaces/{namespace}/pipelineruns/{pipelinerun}/nodes/{node}/steps/{step}/log").
+		To(handler.getStepLog).
+		Doc("Get the log of a step of a PipelineRun, it's still available after the Jenkins build was dropped").
+		Metadata(restfulspec.KeyOpenAPITags, constants.DevOpsPipelineTags).
+		Produces("text/plain; charset=utf-8").
+		Param(ws.PathParameter("namespace", "Namespace of the PipelineRun")).
+		Param(ws.PathParameter("pipelinerun", "Name of the PipelineRun")).
+		Param(ws.PathParameter("node", "The node ID of the PipelineRun")).
+		Param(ws.PathParameter("step", "The step ID of the node")).
+		Param(ws.QueryParameter("start", "The offset of the log to start from").DataType("integer").DefaultValue("0")).
+		Param(ws.QueryParameter("follow", "Keep streaming the log until the PipelineRun completed").DataType("boolean").DefaultValue("false")).
+		Returns(http.StatusOK, api.StatusOK, nil))
+
 	// download PipelineRun artifact
 	ws.Route(ws.GET("/namespaces/{namespace}/pipelineruns/{pipelinerun}/artifacts/download").
 		Param(ws.PathParameter("namespace", "Namespace of the PipelineRun")).
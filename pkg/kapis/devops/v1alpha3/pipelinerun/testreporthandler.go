@@ -0,0 +1,215 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinerun
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/emicklei/go-restful/v3"
+	"github.com/jenkins-zh/jenkins-client/pkg/job"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/kubesphere/ks-devops/pkg/api/devops/v1alpha3"
+	"github.com/kubesphere/ks-devops/pkg/kapis"
+	"github.com/kubesphere/ks-devops/pkg/models/testreport"
+	"github.com/kubesphere/ks-devops/pkg/store/store"
+)
+
+const (
+	// defaultTestTrendLimit is the default number of the latest PipelineRuns in a test trend
+	defaultTestTrendLimit = 20
+	// maxTestReportSize limits the size of an uploaded JUnit report
+	maxTestReportSize = 10 << 20
+)
+
+// getTestReport returns the test report of a PipelineRun, it's empty if there is no test report
+func (h *apiHandler) getTestReport(request *restful.Request, response *restful.Response) {
+	key := client.ObjectKey{Namespace: request.PathParameter("namespace"), Name: request.PathParameter("pipelinerun")}
+	ctx := request.Request.Context()
+
+	if err := h.client.Get(ctx, key, &v1alpha3.PipelineRun{}); err != nil {
+		kapis.HandleError(request, response, err)
+		return
+	}
+	dataStore, err := h.getDataStore().Get(ctx, key)
+	if err != nil {
+		kapis.HandleError(request, response, err)
+		return
+	}
+	report, err := testreport.Parse(dataStore.GetTestReport())
+	if err != nil {
+		kapis.HandleError(request, response, err)
+		return
+	}
+	_ = response.WriteEntity(report)
+}
+
+// uploadTestReport merges a JUnit XML report into the test report of a PipelineRun
+func (h *apiHandler) uploadTestReport(request *restful.Request, response *restful.Response) {
+	key := client.ObjectKey{Namespace: request.PathParameter("namespace"), Name: request.PathParameter("pipelinerun")}
+	ctx := request.Request.Context()
+
+	data, err := io.ReadAll(io.LimitReader(request.Request.Body, maxTestReportSize+1))
+	if err != nil {
+		kapis.HandleBadRequest(response, request, err)
+		return
+	} else if len(data) > maxTestReportSize {
+		kapis.HandleBadRequest(response, request, fmt.Errorf("the test report is larger than %d bytes", maxTestReportSize))
+		return
+	}
+	cases, err := testreport.ParseJUnit(data)
+	if err != nil {
+		kapis.HandleBadRequest(response, request, err)
+		return
+	}
+
+	pr := &v1alpha3.PipelineRun{}
+	if err = h.client.Get(ctx, key, pr); err != nil {
+		kapis.HandleError(request, response, err)
+		return
+	}
+	// the report is merged again if the data store was changed by others, such as the PipelineRun controller
+	var report *testreport.Report
+	err = retry.OnError(retry.DefaultRetry, isDataStoreConflict, func() (err error) {
+		var dataStore store.OwnedStore
+		if dataStore, err = h.getDataStore().Get(ctx, key); err != nil {
+			return
+		}
+		if report, err = testreport.Parse(dataStore.GetTestReport()); err != nil {
+			return
+		}
+		report.Add(cases...)
+		if report.CommitID == "" {
+			report.CommitID = getCommitID(dataStore.GetStatus())
+		}
+		report.Truncate(testreport.MaxStoredSize)
+		dataStore.SetTestReport(report.String())
+		dataStore.SetOwnerReference(v1.OwnerReference{
+			APIVersion: v1alpha3.GroupVersion.String(),
+			Kind:       "PipelineRun",
+			Name:       pr.Name,
+			UID:        pr.UID,
+		})
+		return dataStore.Save()
+	})
+	if err != nil {
+		kapis.HandleError(request, response, err)
+		return
+	}
+	_ = response.WriteEntity(report)
+}
+
+// isDataStoreConflict tells if the data store was created or updated by others since it was read
+func isDataStoreConflict(err error) bool {
+	return apierrors.IsConflict(err) || apierrors.IsAlreadyExists(err)
+}
+
+// getTestTrend returns the test summaries of the latest PipelineRuns of a Pipeline
+func (h *apiHandler) getTestTrend(request *restful.Request, response *restful.Response) {
+	runs, err := h.getRunReports(request)
+	if err != nil {
+		kapis.HandleError(request, response, err)
+		return
+	}
+	_ = response.WriteEntity(testreport.GetTrend(runs))
+}
+
+// getFlakyTests returns the tests which both passed and failed on the same commit in the latest PipelineRuns
+func (h *apiHandler) getFlakyTests(request *restful.Request, response *restful.Response) {
+	runs, err := h.getRunReports(request)
+	if err != nil {
+		kapis.HandleError(request, response, err)
+		return
+	}
+	_ = response.WriteEntity(testreport.GetFlakyTests(runs))
+}
+
+// getRunReports returns the test reports of the latest PipelineRuns of a Pipeline, from the latest one
+func (h *apiHandler) getRunReports(request *restful.Request) (runs []testreport.RunReport, err error) {
+	nsName := request.PathParameter("namespace")
+	pipelineName := request.PathParameter("pipeline")
+	branch := request.QueryParameter("branch")
+	ctx := request.Request.Context()
+
+	limit := defaultTestTrendLimit
+	if limitParam := request.QueryParameter("limit"); limitParam != "" {
+		if limit, err = strconv.Atoi(limitParam); err != nil || limit <= 0 {
+			err = restful.NewError(http.StatusBadRequest, fmt.Sprintf("invalid limit: %s", limitParam))
+			return
+		}
+	}
+
+	prs := &v1alpha3.PipelineRunList{}
+	if err = h.client.List(ctx, prs, client.InNamespace(nsName),
+		client.MatchingLabels{v1alpha3.PipelineNameLabelKey: pipelineName}); err != nil {
+		return
+	}
+	items := make([]v1alpha3.PipelineRun, 0, len(prs.Items))
+	for _, pr := range prs.Items {
+		if branch == "" || pr.GetRefName() == branch {
+			items = append(items, pr)
+		}
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[j].CreationTimestamp.Before(&items[i].CreationTimestamp)
+	})
+	if len(items) > limit {
+		items = items[:limit]
+	}
+
+	runs = make([]testreport.RunReport, 0, len(items))
+	for i := range items {
+		var report *testreport.Report
+		if report, err = h.getRunTestReport(ctx, &items[i]); err != nil {
+			return
+		}
+		runID, _ := items[i].GetPipelineRunID()
+		runs = append(runs, testreport.RunReport{PipelineRun: items[i].Name, RunID: runID, Report: report})
+	}
+	return
+}
+
+// getRunTestReport returns the test report of a PipelineRun, the invalid reports are ignored
+func (h *apiHandler) getRunTestReport(ctx context.Context, pr *v1alpha3.PipelineRun) (*testreport.Report, error) {
+	dataStore, err := h.getDataStore().Get(ctx, client.ObjectKey{Namespace: pr.Namespace, Name: pr.Name})
+	if err != nil {
+		return nil, err
+	}
+	report, err := testreport.Parse(dataStore.GetTestReport())
+	if err != nil {
+		return nil, nil
+	}
+	return report, nil
+}
+
+// getCommitID returns the commit ID from the stored status of a PipelineRun
+func getCommitID(status string) string {
+	run := &job.PipelineRun{}
+	if status == "" || json.Unmarshal([]byte(status), run) != nil {
+		return ""
+	}
+	return run.CommitID
+}
@@ -0,0 +1,87 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinerun
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/emicklei/go-restful/v3"
+	"github.com/kubesphere/ks-devops/pkg/api/devops/v1alpha3"
+	"github.com/kubesphere/ks-devops/pkg/models/testreport"
+	"github.com/kubesphere/ks-devops/pkg/store/store"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+)
+
+func TestUploadTestReport(t *testing.T) {
+	schema, err := v1alpha3.SchemeBuilder.Register().Build()
+	assert.Nil(t, err)
+	err = v1.SchemeBuilder.AddToScheme(schema)
+	assert.Nil(t, err)
+
+	pipelineRun := &v1alpha3.PipelineRun{}
+	pipelineRun.SetName("pr1")
+	pipelineRun.SetNamespace("ns")
+
+	collected := &testreport.Report{}
+	collected.Add(testreport.Case{Name: "collected", Status: testreport.CasePassed})
+	cm := &v1.ConfigMap{Data: map[string]string{}}
+	cm.SetName(pipelineRun.GetName())
+	cm.SetNamespace(pipelineRun.GetNamespace())
+	cm.Data[store.DataKeyTestReport] = collected.String()
+
+	// the first update conflicts with the one from the PipelineRun controller
+	var updates int
+	handler := &apiHandler{
+		apiHandlerOption: apiHandlerOption{
+			client: fake.NewClientBuilder().WithScheme(schema).WithObjects(pipelineRun, cm).
+				WithInterceptorFuncs(interceptor.Funcs{
+					Update: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.UpdateOption) error {
+						if updates++; updates == 1 {
+							return apierrors.NewConflict(v1.Resource("configmaps"), obj.GetName(), nil)
+						}
+						return c.Update(ctx, obj, opts...)
+					},
+				}).Build(),
+		},
+	}
+
+	recorder := httptest.NewRecorder()
+	req := restful.NewRequest(httptest.NewRequest(http.MethodPost, "/testreport", strings.NewReader(
+		`<testsuite name="suite"><testcase classname="a" name="uploaded"><failure message="fail"/></testcase></testsuite>`)))
+	req.PathParameters()["namespace"] = "ns"
+	req.PathParameters()["pipelinerun"] = "pr1"
+	resp := restful.NewResponse(recorder)
+	resp.SetRequestAccepts(restful.MIME_JSON)
+	handler.uploadTestReport(req, resp)
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, 2, updates)
+	stored := &v1.ConfigMap{}
+	assert.Nil(t, handler.client.Get(context.TODO(), client.ObjectKeyFromObject(cm), stored))
+	report, err := testreport.Parse(stored.Data[store.DataKeyTestReport])
+	assert.Nil(t, err)
+	assert.Equal(t, testreport.Summary{Total: 2, Passed: 1, Failed: 1}, report.Summary)
+}
@@ -122,3 +122,38 @@ func CreateBarePipelineRun(pipeline *v1alpha3.Pipeline, parameters []v1alpha3.Pa
 	}
 	return pipelineRun
 }
+
+// buildAttemptChain returns the attempts of the given PipelineRun in order. The retries are linked to the previous
+// attempts by the parent annotation.
+func buildAttemptChain(prs []v1alpha3.PipelineRun, name string) (chain []v1alpha3.PipelineRun) {
+	byName := make(map[string]v1alpha3.PipelineRun, len(prs))
+	retries := make(map[string]string, len(prs))
+	for _, pr := range prs {
+		byName[pr.Name] = pr
+		if parent := pr.Annotations[v1alpha3.PipelineRunRetryParentAnnoKey]; parent != "" {
+			retries[parent] = pr.Name
+		}
+	}
+	if _, ok := byName[name]; !ok {
+		return
+	}
+
+	// find out the first attempt, the visited names prevent it from looping
+	visited := map[string]bool{name: true}
+	first := name
+	for {
+		parent := byName[first].Annotations[v1alpha3.PipelineRunRetryParentAnnoKey]
+		if _, ok := byName[parent]; !ok || visited[parent] {
+			break
+		}
+		visited[parent] = true
+		first = parent
+	}
+
+	visited = map[string]bool{}
+	for current, ok := first, true; ok && !visited[current]; current, ok = retries[current] {
+		visited[current] = true
+		chain = append(chain, byName[current])
+	}
+	return
+}
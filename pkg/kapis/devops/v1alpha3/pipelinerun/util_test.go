@@ -227,3 +227,33 @@ func TestCreatePipelineRun(t *testing.T) {
 	assert.Equal(t, pipelineRun.Namespace, pipeline.Namespace)
 	assert.NotNil(t, pipelineRun.Annotations)
 }
+
+func Test_buildAttemptChain(t *testing.T) {
+	newPipelineRun := func(name, parent string) v1alpha3.PipelineRun {
+		pr := v1alpha3.PipelineRun{ObjectMeta: v1.ObjectMeta{Name: name}}
+		if parent != "" {
+			pr.Annotations = map[string]string{v1alpha3.PipelineRunRetryParentAnnoKey: parent}
+		}
+		return pr
+	}
+	prs := []v1alpha3.PipelineRun{
+		newPipelineRun("run-retry-3", "run-retry-2"),
+		newPipelineRun("other", ""),
+		newPipelineRun("run", ""),
+		newPipelineRun("run-retry-2", "run"),
+		newPipelineRun("loop-a", "loop-b"),
+		newPipelineRun("loop-b", "loop-a"),
+	}
+	names := func(chain []v1alpha3.PipelineRun) (result []string) {
+		for _, pr := range chain {
+			result = append(result, pr.Name)
+		}
+		return
+	}
+
+	assert.Equal(t, []string{"run", "run-retry-2", "run-retry-3"}, names(buildAttemptChain(prs, "run")))
+	assert.Equal(t, []string{"run", "run-retry-2", "run-retry-3"}, names(buildAttemptChain(prs, "run-retry-2")))
+	assert.Equal(t, []string{"other"}, names(buildAttemptChain(prs, "other")))
+	assert.Equal(t, []string{"loop-b", "loop-a"}, names(buildAttemptChain(prs, "loop-a")))
+	assert.Empty(t, buildAttemptChain(prs, "not-found"))
+}
@@ -34,9 +34,11 @@ import (
 	"github.com/kubesphere/ks-devops/pkg/api"
 	"github.com/kubesphere/ks-devops/pkg/api/devops/v1alpha3"
 	"github.com/kubesphere/ks-devops/pkg/apiserver/runtime"
+	cacheclient "github.com/kubesphere/ks-devops/pkg/client/cache"
 	dclient "github.com/kubesphere/ks-devops/pkg/client/devops"
 	"github.com/kubesphere/ks-devops/pkg/client/k8s"
 	"github.com/kubesphere/ks-devops/pkg/constants"
+	"github.com/kubesphere/ks-devops/pkg/kapis/devops/v1alpha3/analytics"
 	"github.com/kubesphere/ks-devops/pkg/kapis/devops/v1alpha3/common"
 	"github.com/kubesphere/ks-devops/pkg/kapis/devops/v1alpha3/pipeline"
 	"github.com/kubesphere/ks-devops/pkg/kapis/devops/v1alpha3/pipelinerun"
@@ -45,20 +47,26 @@ import (
 	"github.com/kubesphere/ks-devops/pkg/kapis/devops/v1alpha3/template"
 	"github.com/kubesphere/ks-devops/pkg/kapis/devops/v1alpha3/webhook"
 	"github.com/kubesphere/ks-devops/pkg/server/params"
+	"github.com/kubesphere/ks-devops/pkg/store/store"
 	"kubesphere.io/kubesphere/pkg/apiserver/query"
 )
 
 // TODO perhaps we can find a better way to declaim the permission needs of the apiserver
 //+kubebuilder:rbac:groups=devops.kubesphere.io,resources=devopsprojects,verbs=get;list;update;delete;create;watch
-//+kubebuilder:rbac:groups=devops.kubesphere.io,resources=pipelines,verbs=get;list;update;delete;create;watch
+//+kubebuilder:rbac:groups=devops.kubesphere.io,resources=pipelines,verbs=get;list;update;patch;delete;create;watch
 //+kubebuilder:rbac:groups=devops.kubesphere.io,resources=pipelineruns,verbs=get;list;update;delete;create;watch
+//+kubebuilder:rbac:groups=devops.kubesphere.io,resources=pipelinerevisions,verbs=get;list;watch
+//+kubebuilder:rbac:groups=devops.kubesphere.io,resources=approvals,verbs=get;list;watch
+//+kubebuilder:rbac:groups=devops.kubesphere.io,resources=approvals/status,verbs=get;update
+//+kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=rolebindings,verbs=get;list;watch
 
 // GroupVersion describes CRD group and its version.
 var GroupVersion = schema.GroupVersion{Group: api.GroupName, Version: "v1alpha3"}
 
 // AddToContainer adds web service into container.
 func AddToContainer(container *restful.Container, devopsClient dclient.Interface, k8sClient k8s.Client,
-	client client.Client, runtimeCache cache.Cache, jenkins core.JenkinsCore, cfg *config.Config) (wss []*restful.WebService) {
+	client client.Client, runtimeCache cache.Cache, jenkins core.JenkinsCore, cfg *config.Config,
+	dataStore store.Provider, cacheClient cacheclient.Interface) (wss []*restful.WebService) {
 
 	services := []*restful.WebService{
 		runtime.NewWebService(v1alpha3.GroupVersion),
@@ -66,7 +74,7 @@ func AddToContainer(container *restful.Container, devopsClient dclient.Interface
 
 	for _, service := range services {
 		registerRoutes(cfg, devopsClient, k8sClient, client, runtimeCache, service)
-		pipelinerun.RegisterRoutes(service, devopsClient, client)
+		pipelinerun.RegisterRoutes(service, devopsClient, client, dataStore)
 		pipeline.RegisterRoutes(service, client)
 		template.RegisterRoutes(service, &common.Options{
 			GenericClient: client,
@@ -75,6 +83,7 @@ func AddToContainer(container *restful.Container, devopsClient dclient.Interface
 			GenericClient: client,
 		})
 		webhook.RegisterWebhooks(client, service, jenkins)
+		analytics.RegisterRoutes(service, client, cacheClient)
 		container.Add(service)
 	}
 	return services
@@ -19,6 +19,7 @@ package webhook
 import (
 	"github.com/emicklei/go-restful/v3"
 	"github.com/kubesphere/ks-devops/pkg/event/common"
+	"github.com/kubesphere/ks-devops/pkg/event/flownode"
 	"github.com/kubesphere/ks-devops/pkg/event/workflowrun"
 	"github.com/kubesphere/ks-devops/pkg/kapis"
 	"k8s.io/apimachinery/pkg/util/errors"
@@ -28,12 +29,15 @@ import (
 // Handler handles requests from webhooks.
 type Handler struct {
 	client.Client
+	// flowNodeDebouncer merges the FlowNode events of the same run, they are notified one by one if it is nil
+	flowNodeDebouncer *eventDebouncer
 }
 
 // NewHandler creates a new handler for handling webhooks.
 func NewHandler(genericClient client.Client) *Handler {
 	return &Handler{
-		Client: genericClient,
+		Client:            genericClient,
+		flowNodeDebouncer: newEventDebouncer(flowNodeEventDebounceInterval),
 	}
 }
 
@@ -52,16 +56,27 @@ func (handler *Handler) ReceiveEventsFromJenkins(request *restful.Request, respo
 	var errs []error
 	workflowRunHandlers := workflowrun.Handlers{
 		HandleInitialize: handler.handleWorkflowRunInitialize,
+		HandleStarted:    handler.notifyWorkflowRun(common.RunStarted),
+		HandleFinalized:  handler.notifyWorkflowRun(common.RunFinalized),
+		HandleCompleted:  handler.notifyWorkflowRun(common.RunCompleted),
 		// TODO Handler others
-		HandleStarted:   nil,
-		HandleFinalized: nil,
-		HandleCompleted: nil,
-		HandleDeleted:   nil,
+		HandleDeleted: nil,
 	}
 	if err := workflowRunHandlers.Handle(event); err != nil {
 		errs = append(errs, err)
 	}
 
+	// register FlowNode event handler, the stages and steps of PipelineRuns are updated by them
+	flowNodeHandlers := flownode.Handlers{
+		HandleStageStarted:   handler.notifyFlowNode(common.StageStarted),
+		HandleStageCompleted: handler.notifyFlowNode(common.StageCompleted),
+		HandleStepStarted:    handler.notifyFlowNode(common.StepStarted),
+		HandleStepCompleted:  handler.notifyFlowNode(common.StepCompleted),
+	}
+	if err := flowNodeHandlers.Handle(event); err != nil {
+		errs = append(errs, err)
+	}
+
 	// TODO Register other event handlers here
 
 	if len(errs) > 0 {
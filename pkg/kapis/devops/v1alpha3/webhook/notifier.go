@@ -0,0 +1,132 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/kubesphere/ks-devops/pkg/api/devops/v1alpha3"
+	"github.com/kubesphere/ks-devops/pkg/event/flownode"
+	"github.com/kubesphere/ks-devops/pkg/event/workflowrun"
+)
+
+// flowNodeEventDebounceInterval is the interval of merging the FlowNode events of the same run. A run might have
+// plenty of steps, and every notification makes the PipelineRun controller retrieve the running data from Jenkins.
+const flowNodeEventDebounceInterval = 5 * time.Second
+
+// eventDebouncer merges the events of the same run within an interval, only the last one of them is notified at the
+// end of the interval
+type eventDebouncer struct {
+	interval time.Duration
+	mutex    sync.Mutex
+	// pending is the last event type of the runs which are waiting for the notification
+	pending map[string]string
+}
+
+func newEventDebouncer(interval time.Duration) *eventDebouncer {
+	return &eventDebouncer{interval: interval, pending: map[string]string{}}
+}
+
+// debounce calls the notify function with the last event type of the run once the interval passed
+func (d *eventDebouncer) debounce(key, eventType string, notify func(eventType string)) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	_, waiting := d.pending[key]
+	d.pending[key] = eventType
+	if waiting {
+		return
+	}
+	time.AfterFunc(d.interval, func() {
+		d.mutex.Lock()
+		lastEventType := d.pending[key]
+		delete(d.pending, key)
+		d.mutex.Unlock()
+		notify(lastEventType)
+	})
+}
+
+// notifyWorkflowRun returns a handler which notifies the PipelineRun of the WorkflowRun event
+func (handler *Handler) notifyWorkflowRun(eventType string) workflowrun.Handler {
+	return func(workflowRunData *workflowrun.Data) error {
+		return handler.notifyPipelineRun(extractPipelineRunIdentifier(workflowRunData), eventType)
+	}
+}
+
+// notifyFlowNode returns a handler which notifies the PipelineRun of the stage or step event
+func (handler *Handler) notifyFlowNode(eventType string) flownode.Handler {
+	return func(flowNodeData *flownode.Data) error {
+		if flowNodeData == nil {
+			return nil
+		}
+		identifier := extractPipelineRunIdentifier(&workflowrun.Data{
+			ID:             flowNodeData.RunID,
+			ParentFullName: flowNodeData.ParentFullName,
+			ProjectName:    flowNodeData.ProjectName,
+			IsMultiBranch:  flowNodeData.IsMultiBranch,
+		})
+		if handler.flowNodeDebouncer == nil || identifier == nil || identifier.buildNumber == "" {
+			return handler.notifyPipelineRun(identifier, eventType)
+		}
+		handler.flowNodeDebouncer.debounce(identifier.String(), eventType, func(lastEventType string) {
+			if err := handler.notifyPipelineRun(identifier, lastEventType); err != nil {
+				klog.Errorf("failed to notify the PipelineRun %s of the event %s, error: %v", identifier, lastEventType, err)
+			}
+		})
+		return nil
+	}
+}
+
+// notifyPipelineRun records the last event on the PipelineRun, then the PipelineRun controller retrieves the running
+// data from Jenkins instead of waiting for the next polling. The completed PipelineRuns are skipped.
+func (handler *Handler) notifyPipelineRun(identifier *pipelineRunIdentifier, eventType string) error {
+	if identifier == nil || identifier.buildNumber == "" {
+		// we should skip this event if the Pipeline is not a standard Pipeline in ks-devops.
+		return nil
+	}
+
+	ctx := context.Background()
+	pipelineRunList := &v1alpha3.PipelineRunList{}
+	if err := handler.List(ctx, pipelineRunList,
+		client.InNamespace(identifier.namespaceName),
+		client.MatchingFields{v1alpha3.PipelineRunIdentifierIndexerName: identifier.String()}); err != nil {
+		return err
+	}
+
+	lastEvent := fmt.Sprintf("%s@%s", eventType, time.Now().Format(time.RFC3339Nano))
+	for i := range pipelineRunList.Items {
+		pipelineRun := &pipelineRunList.Items[i]
+		if pipelineRun.HasCompleted() {
+			continue
+		}
+		// the merge patch does not conflict with the updates from the PipelineRun controller
+		patch := client.MergeFrom(pipelineRun.DeepCopy())
+		if pipelineRun.Annotations == nil {
+			pipelineRun.Annotations = map[string]string{}
+		}
+		pipelineRun.Annotations[v1alpha3.PipelineRunLastEventAnnoKey] = lastEvent
+		if err := handler.Patch(ctx, pipelineRun, patch); err != nil {
+			return client.IgnoreNotFound(err)
+		}
+	}
+	return nil
+}
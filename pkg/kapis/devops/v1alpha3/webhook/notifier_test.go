@@ -0,0 +1,123 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/kubesphere/ks-devops/pkg/api/devops/v1alpha3"
+	"github.com/kubesphere/ks-devops/pkg/event/common"
+	"github.com/kubesphere/ks-devops/pkg/event/flownode"
+)
+
+func TestHandler_notifyPipelineRun(t *testing.T) {
+	scheme := runtime.NewScheme()
+	assert.Nil(t, v1alpha3.AddToScheme(scheme))
+
+	newPipelineRun := func(name, runID string, completed bool) *v1alpha3.PipelineRun {
+		pr := &v1alpha3.PipelineRun{
+			ObjectMeta: v1.ObjectMeta{
+				Namespace:   "fake-namespace",
+				Name:        name,
+				Labels:      map[string]string{v1alpha3.PipelineNameLabelKey: "fake-pipeline"},
+				Annotations: map[string]string{v1alpha3.JenkinsPipelineRunIDAnnoKey: runID},
+			},
+		}
+		if completed {
+			pr.Status.CompletionTime = &v1.Time{Time: time.Now()}
+		}
+		return pr
+	}
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme).
+		WithObjects(newPipelineRun("running", "1", false), newPipelineRun("completed", "2", true)).
+		WithIndex(&v1alpha3.PipelineRun{}, v1alpha3.PipelineRunIdentifierIndexerName, func(o client.Object) []string {
+			return []string{o.(*v1alpha3.PipelineRun).GetPipelineRunIdentifier()}
+		}).Build()
+	handler := &Handler{Client: fakeClient}
+
+	getLastEvent := func(name string) string {
+		pr := &v1alpha3.PipelineRun{}
+		assert.Nil(t, fakeClient.Get(context.Background(), client.ObjectKey{Namespace: "fake-namespace", Name: name}, pr))
+		return pr.Annotations[v1alpha3.PipelineRunLastEventAnnoKey]
+	}
+
+	// notified by the WorkflowRun event
+	err := handler.notifyWorkflowRun(common.RunStarted)(createWorkflowRun("fake-namespace", "fake-pipeline", "1", false))
+	assert.Nil(t, err)
+	assert.True(t, strings.HasPrefix(getLastEvent("running"), common.RunStarted+"@"))
+
+	// notified by the FlowNode event
+	err = handler.notifyFlowNode(common.StepCompleted)(&flownode.Data{
+		RunID:          "1",
+		ParentFullName: "fake-namespace",
+		ProjectName:    "fake-pipeline",
+	})
+	assert.Nil(t, err)
+	assert.True(t, strings.HasPrefix(getLastEvent("running"), common.StepCompleted+"@"))
+
+	// the completed PipelineRun is skipped
+	err = handler.notifyWorkflowRun(common.RunCompleted)(createWorkflowRun("fake-namespace", "fake-pipeline", "2", false))
+	assert.Nil(t, err)
+	assert.Empty(t, getLastEvent("completed"))
+
+	// the events of unknown runs are skipped
+	assert.Nil(t, handler.notifyWorkflowRun(common.RunCompleted)(createWorkflowRun("fake-namespace", "fake-pipeline", "3", false)))
+	assert.Nil(t, handler.notifyWorkflowRun(common.RunCompleted)(createWorkflowRun("", "", "", false)))
+	assert.Nil(t, handler.notifyFlowNode(common.StepCompleted)(nil))
+}
+
+func Test_eventDebouncer(t *testing.T) {
+	debouncer := newEventDebouncer(50 * time.Millisecond)
+	notified := make(chan string, 10)
+	notify := func(eventType string) {
+		notified <- eventType
+	}
+
+	debouncer.debounce("run-1", common.StepStarted, notify)
+	debouncer.debounce("run-1", common.StepCompleted, notify)
+	debouncer.debounce("run-2", common.StageStarted, notify)
+
+	var events []string
+	for i := 0; i < 2; i++ {
+		select {
+		case eventType := <-notified:
+			events = append(events, eventType)
+		case <-time.After(time.Second):
+			t.Fatal("the events were not notified")
+		}
+	}
+	assert.ElementsMatch(t, []string{common.StepCompleted, common.StageStarted}, events)
+
+	// the run is notified again after the interval
+	debouncer.debounce("run-1", common.StepStarted, notify)
+	select {
+	case eventType := <-notified:
+		assert.Equal(t, common.StepStarted, eventType)
+	case <-time.After(time.Second):
+		t.Fatal("the event was not notified")
+	}
+	assert.Empty(t, notified)
+}
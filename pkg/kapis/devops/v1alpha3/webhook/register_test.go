@@ -28,6 +28,7 @@ import (
 	"time"
 
 	"github.com/jenkins-zh/jenkins-client/pkg/core"
+	corev1 "k8s.io/api/core/v1"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/util/retry"
@@ -189,6 +190,17 @@ func TestSCMWebhook(t *testing.T) {
 		scmAnnotationKey:    "https://gitlab.com/linuxsuren/test",
 	})
 
+	gitRepo := &v1alpha3.GitRepository{}
+	gitRepo.SetName("test")
+	gitRepo.SetNamespace("default")
+	gitRepo.Spec.URL = "https://gitlab.com/linuxsuren/test"
+	gitRepo.Spec.Secret = &corev1.SecretReference{Name: "gitlab"}
+	gitSecret := &corev1.Secret{
+		ObjectMeta: v1.ObjectMeta{Name: "gitlab", Namespace: "default"},
+		Type:       v1alpha3.SecretTypeSecretText,
+		Data:       map[string][]byte{v1alpha3.SecretTextSecretKey: []byte("token")},
+	}
+
 	type args struct {
 		method     string
 		uri        string
@@ -199,6 +211,7 @@ func TestSCMWebhook(t *testing.T) {
 	tests := []struct {
 		name      string
 		args      args
+		wantCode  int
 		assertion func(t *testing.T, c client.Client, body string)
 	}{{
 		name: "unknown SCM webhook",
@@ -225,19 +238,158 @@ func TestSCMWebhook(t *testing.T) {
 			assert.Equal(t, "no pipeline matched", body)
 		},
 	}, {
-		name: "gitlab webhook",
+		name: "gitlab webhook without any secret",
+		args: args{
+			method:     http.MethodPost,
+			uri:        "/webhooks/scm",
+			initObject: []client.Object{defaultPipeline.DeepCopy()},
+			bodyJSON:   gitlabWebhookBody,
+			header: map[string]string{
+				"X-Gitlab-Event": "Push Hook",
+			},
+		},
+		wantCode: http.StatusUnauthorized,
+	}, {
+		name: "gitlab webhook of a Pipeline which allows unsigned webhooks",
+		args: args{
+			method: http.MethodPost,
+			uri:    "/webhooks/scm",
+			initObject: []client.Object{&v1alpha3.Pipeline{
+				ObjectMeta: v1.ObjectMeta{
+					Name:      "fake",
+					Namespace: "default",
+					Annotations: map[string]string{
+						scmRefAnnotationKey:        `["master"]`,
+						scmAnnotationKey:           "https://gitlab.com/linuxsuren/test",
+						allowUnsignedAnnotationKey: "true",
+					},
+				},
+			}},
+			bodyJSON: gitlabWebhookBody,
+			header: map[string]string{
+				"X-Gitlab-Event": "Push Hook",
+			},
+		},
+		assertion: func(t *testing.T, c client.Client, body string) {
+			assert.Equal(t, "ok", body)
+		},
+	}, {
+		name: "gitlab tag push webhook of a regular Pipeline",
+		args: args{
+			method: http.MethodPost,
+			uri:    "/webhooks/scm",
+			initObject: []client.Object{&v1alpha3.Pipeline{
+				ObjectMeta: v1.ObjectMeta{
+					Name:      "fake",
+					Namespace: "default",
+					Annotations: map[string]string{
+						scmRefAnnotationKey: `{"tag": ["^v.*"]}`,
+						scmAnnotationKey:    "https://gitlab.com/linuxsuren/test",
+					},
+				},
+			}, gitRepo.DeepCopy(), gitSecret.DeepCopy()},
+			bodyJSON: strings.ReplaceAll(strings.ReplaceAll(gitlabWebhookBody,
+				`"refs/heads/master"`, `"refs/tags/v1.0.0"`), `"object_kind": "push"`, `"object_kind": "tag_push"`),
+			header: map[string]string{
+				"X-Gitlab-Event": "Tag Push Hook",
+				"X-Gitlab-Token": "token",
+			},
+		},
+		assertion: func(t *testing.T, c client.Client, body string) {
+			// a regular Pipeline always builds its own checkout
+			assert.Equal(t, "no pipeline matched", body)
+			pipelineRuns := &v1alpha3.PipelineRunList{}
+			assert.Nil(t, c.List(context.Background(), pipelineRuns))
+			assert.Empty(t, pipelineRuns.Items)
+		},
+	}, {
+		name: "gitlab tag push webhook without tag rules",
 		args: args{
 			method:     http.MethodPost,
 			uri:        "/webhooks/scm",
 			initObject: []client.Object{defaultPipeline.DeepCopy()},
+			bodyJSON: strings.ReplaceAll(strings.ReplaceAll(gitlabWebhookBody,
+				`"refs/heads/master"`, `"refs/tags/v1.0.0"`), `"object_kind": "push"`, `"object_kind": "tag_push"`),
+			header: map[string]string{
+				"X-Gitlab-Event": "Tag Push Hook",
+			},
+		},
+		assertion: func(t *testing.T, c client.Client, body string) {
+			assert.Equal(t, "no pipeline matched", body)
+		},
+	}, {
+		name: "gitlab webhook with a valid token",
+		args: args{
+			method:     http.MethodPost,
+			uri:        "/webhooks/scm",
+			initObject: []client.Object{defaultPipeline.DeepCopy(), gitRepo.DeepCopy(), gitSecret.DeepCopy()},
 			bodyJSON:   gitlabWebhookBody,
 			header: map[string]string{
 				"X-Gitlab-Event": "Push Hook",
+				"X-Gitlab-Token": "token",
 			},
 		},
 		assertion: func(t *testing.T, c client.Client, body string) {
 			assert.Equal(t, "ok", body)
 		},
+	}, {
+		name: "gitlab webhook with an invalid token",
+		args: args{
+			method:     http.MethodPost,
+			uri:        "/webhooks/scm",
+			initObject: []client.Object{defaultPipeline.DeepCopy(), gitRepo.DeepCopy(), gitSecret.DeepCopy()},
+			bodyJSON:   gitlabWebhookBody,
+			header: map[string]string{
+				"X-Gitlab-Event": "Push Hook",
+				"X-Gitlab-Token": "invalid",
+			},
+		},
+		wantCode: http.StatusUnauthorized,
+		assertion: func(t *testing.T, c client.Client, body string) {
+			pipelineRuns := &v1alpha3.PipelineRunList{}
+			assert.Nil(t, c.List(context.Background(), pipelineRuns))
+			assert.Empty(t, pipelineRuns.Items)
+		},
+	}, {
+		name: "gitlab webhook without token",
+		args: args{
+			method:     http.MethodPost,
+			uri:        "/webhooks/scm",
+			initObject: []client.Object{defaultPipeline.DeepCopy(), gitRepo.DeepCopy(), gitSecret.DeepCopy()},
+			bodyJSON:   gitlabWebhookBody,
+			header: map[string]string{
+				"X-Gitlab-Event": "Push Hook",
+			},
+		},
+		wantCode: http.StatusUnauthorized,
+	}, {
+		name: "gitlab webhook signed by the secret of another namespace",
+		args: args{
+			method: http.MethodPost,
+			uri:    "/webhooks/scm",
+			initObject: []client.Object{defaultPipeline.DeepCopy(), &v1alpha3.GitRepository{
+				ObjectMeta: v1.ObjectMeta{Name: "test", Namespace: "other"},
+				Spec: v1alpha3.GitRepositorySpec{
+					URL:    "https://gitlab.com/linuxsuren/test",
+					Secret: &corev1.SecretReference{Name: "gitlab"},
+				},
+			}, &corev1.Secret{
+				ObjectMeta: v1.ObjectMeta{Name: "gitlab", Namespace: "other"},
+				Type:       v1alpha3.SecretTypeSecretText,
+				Data:       map[string][]byte{v1alpha3.SecretTextSecretKey: []byte("token")},
+			}},
+			bodyJSON: gitlabWebhookBody,
+			header: map[string]string{
+				"X-Gitlab-Event": "Push Hook",
+				"X-Gitlab-Token": "token",
+			},
+		},
+		wantCode: http.StatusUnauthorized,
+		assertion: func(t *testing.T, c client.Client, body string) {
+			pipelineRuns := &v1alpha3.PipelineRunList{}
+			assert.Nil(t, c.List(context.Background(), pipelineRuns))
+			assert.Empty(t, pipelineRuns.Items)
+		},
 	}}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -262,7 +414,10 @@ func TestSCMWebhook(t *testing.T) {
 			}
 			httpWriter := httptest.NewRecorder()
 			container.Dispatch(httpWriter, httpRequest)
-			assert.Equal(t, 200, httpWriter.Code)
+			if tt.wantCode == 0 {
+				tt.wantCode = http.StatusOK
+			}
+			assert.Equal(t, tt.wantCode, httpWriter.Code)
 			if tt.assertion != nil {
 				body := httpWriter.Body
 				var bodyResponse string
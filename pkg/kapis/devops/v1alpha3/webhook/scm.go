@@ -75,29 +75,41 @@ func getSCMClient(request *http.Request) *scm.Client {
 func (h *SCMHandler) scmWebhook(request *restful.Request, response *restful.Response) {
 	scmClient := getSCMClient(request.Request)
 	if scmClient == nil {
+		auditWebhook(request.Request, nil, false, http.StatusOK, "unknown SCM type")
 		_, _ = response.Write([]byte("unknown SCM type"))
 		return
 	}
 
-	webhook, err := scmClient.Webhooks.Parse(request.Request, func(webhook scm.Webhook) (string, error) {
-		return "", nil
-	})
+	ctx := context.TODO()
+	webhook, verifier, err := h.parseWebhook(scmClient, request.Request)
 	if err != nil {
+		auditWebhook(request.Request, webhook, false, http.StatusOK, err.Error())
 		_, _ = response.Write([]byte(err.Error()))
 		return
 	}
 
-	ctx := context.TODO()
-	found := false
-	if webhook.Kind() == scm.WebhookKindPush {
+	// rejected means that some Pipelines are not triggered because the webhook is not signed by their GitRepositories
+	found, triggered, rejected := false, false, false
+	if event := getSCMEvent(scmClient.Driver, webhook); event != nil {
 		repo := webhook.Repository()
-		pushHook := webhook.(*scm.PushHook)
+		// authorize returns true if the Pipeline is allowed to be triggered by the webhook
+		authorize := func(pipeline *v1alpha3.Pipeline) bool {
+			verified, verifyErr := verifier.verifyPipeline(ctx, pipeline, repo)
+			if verifyErr != nil {
+				err = verifyErr
+			} else if !verified {
+				rejected = true
+			} else {
+				triggered = true
+			}
+			return verified
+		}
 
 		pipelineList := &v1alpha3.PipelineList{}
 		if err = h.List(ctx, pipelineList); err == nil {
 			for i := range pipelineList.Items {
 				pipeline := pipelineList.Items[i]
-				if !branchMatch(pipeline, pushHook.Ref) {
+				if !refMatch(pipeline, event) {
 					continue
 				}
 				found = true
@@ -105,35 +117,71 @@ func (h *SCMHandler) scmWebhook(request *restful.Request, response *restful.Resp
 				gitURL := pipeline.GetAnnotations()[scmAnnotationKey]
 				if pipeline.IsMultiBranch() {
 					gitURL = pipeline.Spec.MultiBranchPipeline.GetGitURL()
-					if gitURL != "" && gitRepoMatch(gitURL, repo.Link, repo.Clone, repo.CloneSSH) {
-						err = scanJenkinsMultiBranchPipeline(pipeline, h.jenkins)
+					if gitURL != "" && gitRepoMatch(gitURL, repo.Link, repo.Clone, repo.CloneSSH) && authorize(&pipeline) {
+						err = h.buildMultiBranchPipeline(pipeline, event)
 					}
 				} else if gitURL != "" {
-					if gitRepoMatch(gitURL, repo.Link, repo.Clone, repo.CloneSSH) {
-						err = h.createPipelineRun(pipeline, pushHook)
-					} else {
+					if !gitRepoMatch(gitURL, repo.Link, repo.Clone, repo.CloneSSH) {
 						err = fmt.Errorf("expect URL: %s, got: %v", gitURL, []string{repo.Link, repo.Clone, repo.CloneSSH})
+					} else if authorize(&pipeline) {
+						err = h.createPipelineRun(pipeline, event)
 					}
 				}
 			}
 		}
+
+		// the Git-managed Pipelines pull their definitions once the source references are pushed
+		requested, requestRejected, requestErr := h.requestToSyncPipelineSources(ctx, verifier, repo, event)
+		if requested {
+			found, triggered = true, true
+		}
+		if requestRejected {
+			found, rejected = true, true
+		}
+		if err == nil {
+			err = requestErr
+		}
 	}
 
+	verified := verifier.isVerified()
 	if !found {
+		auditWebhook(request.Request, webhook, verified, http.StatusOK, "no pipeline matched")
 		_ = response.WriteErrorString(http.StatusOK, "no pipeline matched")
 		return
+	} else if rejected && !triggered && err == nil {
+		auditWebhook(request.Request, webhook, verified, http.StatusUnauthorized, scm.ErrSignatureInvalid.Error())
+		_ = response.WriteError(http.StatusUnauthorized, scm.ErrSignatureInvalid)
 	} else if err != nil {
+		auditWebhook(request.Request, webhook, verified, http.StatusBadRequest, err.Error())
 		_ = response.WriteError(http.StatusBadRequest, err)
 	} else {
+		auditWebhook(request.Request, webhook, verified, http.StatusOK, "ok")
 		_, _ = response.Write([]byte("ok"))
 	}
 }
 
-func (h *SCMHandler) createPipelineRun(pipeline v1alpha3.Pipeline, hook *scm.PushHook) (err error) {
-	branch := strings.TrimPrefix(hook.Ref, "refs/heads/")
+// buildMultiBranchPipeline scans the multi-branch Pipeline for a branch push. A tag or pull request is built directly
+// if its branch job exists in Jenkins, otherwise the scan creates and builds the branch job.
+func (h *SCMHandler) buildMultiBranchPipeline(pipeline v1alpha3.Pipeline, event *scmEvent) error {
+	if event.refType == v1alpha3.Branch {
+		return scanJenkinsMultiBranchPipeline(pipeline, h.jenkins)
+	}
+	exists, err := branchJobExists(pipeline, event.refName, h.jenkins)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return scanJenkinsMultiBranchPipeline(pipeline, h.jenkins)
+	}
+	return h.createPipelineRun(pipeline, event)
+}
 
+func (h *SCMHandler) createPipelineRun(pipeline v1alpha3.Pipeline, event *scmEvent) (err error) {
 	var scmObj *v1alpha3.SCM
-	if scmObj, err = pipelinerun.CreateScm(&pipeline.Spec, branch); err == nil {
+	if scmObj, err = pipelinerun.CreateScm(&pipeline.Spec, event.refName); err == nil {
+		if scmObj != nil {
+			scmObj.RefType = event.refType
+		}
 		run := pipelinerun.CreatePipelineRun(&pipeline, &devops.RunPayload{}, scmObj)
 		run.Annotations[triggerAnnotationKey] = "webhook"
 		err = h.Create(context.Background(), run)
@@ -141,6 +189,118 @@ func (h *SCMHandler) createPipelineRun(pipeline v1alpha3.Pipeline, hook *scm.Pus
 	return
 }
 
+// requestToSyncPipelineSources requests to synchronize the Git-managed Pipelines whose source reference is pushed.
+// A source without the reference follows the default branch of the repository. The Pipelines are rejected if the
+// webhook is not signed by the secrets of their GitRepositories.
+func (h *SCMHandler) requestToSyncPipelineSources(ctx context.Context, verifier *webhookVerifier, repo scm.Repository,
+	event *scmEvent) (requested, rejected bool, err error) {
+	if event.refType != v1alpha3.Branch && event.refType != v1alpha3.Tag {
+		return
+	}
+
+	repoList := &v1alpha3.GitRepositoryList{}
+	if err = h.List(ctx, repoList); err != nil {
+		err = fmt.Errorf("failed to list GitRepositories, error: %v", err)
+		return
+	}
+	for i := range repoList.Items {
+		gitRepo := repoList.Items[i]
+		if !gitRepoURLMatch(gitRepo.Spec.URL, repo) {
+			continue
+		}
+
+		pipelineList := &v1alpha3.PipelineList{}
+		if err = h.List(ctx, pipelineList, client.InNamespace(gitRepo.Namespace)); err != nil {
+			return
+		}
+		for j := range pipelineList.Items {
+			pipeline := &pipelineList.Items[j]
+			if source := pipeline.Spec.Source; source == nil || source.GitRepository != gitRepo.Name ||
+				!sourceRefMatch(source.Ref, repo.Branch, event) {
+				continue
+			}
+			if pipeline.Annotations[allowUnsignedAnnotationKey] != "true" && !verifier.verifyGitRepository(ctx, &gitRepo) {
+				rejected = true
+				continue
+			}
+			requested = true
+
+			patch := client.MergeFrom(pipeline.DeepCopy())
+			if pipeline.Annotations == nil {
+				pipeline.Annotations = map[string]string{}
+			}
+			pipeline.Annotations[v1alpha3.PipelineRequestToSyncSourceAnnoKey] = time.Now().Format(time.RFC3339Nano)
+			if err = h.Patch(ctx, pipeline, patch); err != nil {
+				return
+			}
+		}
+	}
+	return
+}
+
+// sourceRefMatch returns true if the reference of a Pipeline source is pushed, the empty reference means the default branch
+func sourceRefMatch(ref, defaultBranch string, event *scmEvent) bool {
+	if ref == "" {
+		return event.refType == v1alpha3.Branch && (defaultBranch == "" || defaultBranch == event.refName)
+	}
+	return ref == event.refName
+}
+
+// scmEvent represents a SCM event which is able to trigger Pipelines
+type scmEvent struct {
+	refType v1alpha3.RefType
+	refName string
+	// ref is the full Git reference, such as refs/heads/master
+	ref string
+}
+
+// getSCMEvent returns the event which is able to trigger Pipelines, or nil if it's not supported.
+// Supported events are: branch or tag push, pull request (merge request) opened, synchronized or reopened.
+func getSCMEvent(driver scm.Driver, webhook scm.Webhook) *scmEvent {
+	switch hook := webhook.(type) {
+	case *scm.PushHook:
+		if hook.Deleted {
+			return nil
+		}
+		if strings.HasPrefix(hook.Ref, "refs/tags/") {
+			return &scmEvent{refType: v1alpha3.Tag, refName: strings.TrimPrefix(hook.Ref, "refs/tags/"), ref: hook.Ref}
+		}
+		return &scmEvent{refType: v1alpha3.Branch, refName: strings.TrimPrefix(hook.Ref, "refs/heads/"), ref: hook.Ref}
+	case *scm.PullRequestHook:
+		switch hook.Action {
+		case scm.ActionOpen, scm.ActionSync, scm.ActionReopen:
+		case scm.ActionUpdate:
+			// Gitlab sends the update action when new commits pushed into a merge request
+			if driver != scm.DriverGitlab {
+				return nil
+			}
+		default:
+			return nil
+		}
+
+		// follow the naming convention of the Jenkins SCM branch source plugins
+		if driver == scm.DriverGitlab {
+			return &scmEvent{refType: v1alpha3.MergeRequest, refName: fmt.Sprintf("MR-%d", hook.PullRequest.Number),
+				ref: hook.PullRequest.Ref}
+		}
+		return &scmEvent{refType: v1alpha3.PullRequest, refName: fmt.Sprintf("PR-%d", hook.PullRequest.Number),
+			ref: hook.PullRequest.Ref}
+	}
+	return nil
+}
+
+// branchJobExists returns true if the branch job of a multi-branch Pipeline exists in Jenkins
+func branchJobExists(pipeline v1alpha3.Pipeline, refName string, jenkins core.JenkinsCore) (exists bool, err error) {
+	api := fmt.Sprintf("/job/%s/job/%s/job/%s/api/json", pipeline.Namespace, pipeline.Name, refName)
+	var statusCode int
+	statusCode, err = jenkins.RequestWithoutData(http.MethodGet, api, nil, nil, http.StatusOK)
+	if statusCode == http.StatusNotFound {
+		err = nil
+	}
+	exists = err == nil && statusCode == http.StatusOK
+	return
+}
+
 func scanJenkinsMultiBranchPipeline(pipeline v1alpha3.Pipeline, jenkins core.JenkinsCore) (err error) {
 	jclient := job.Client{
 		JenkinsCore: jenkins,
@@ -150,20 +310,30 @@ func scanJenkinsMultiBranchPipeline(pipeline v1alpha3.Pipeline, jenkins core.Jen
 	return
 }
 
-// branchMatch matches the branch rules from annotation.
-// It supports regexp pattern, or returns true if no annotation found
-func branchMatch(pipeline v1alpha3.Pipeline, branch string) (ok bool) {
-	branchRules := pipeline.Annotations[scmRefAnnotationKey]
-	if branchRules == "" {
+// refMatch matches the reference rules from annotation. It supports regexp pattern.
+// The rules could be an array which is only for branches, or an object which has rules for each reference type, such as:
+// {"branch": ["master"], "tag": ["v.*"], "pr": [".*"]}.
+// It returns true for branches if no rules found. Tags and pull requests only match multi-branch Pipelines, because a
+// regular Pipeline always builds its own checkout.
+// A rule matches either the reference name or the full Git reference, such as master or refs/heads/master.
+func refMatch(pipeline v1alpha3.Pipeline, event *scmEvent) (ok bool) {
+	if event.refType != v1alpha3.Branch && !pipeline.IsMultiBranch() {
+		return
+	}
+	rules, found := getRefRules(pipeline.Annotations[scmRefAnnotationKey], event.refType)
+	if !found {
 		ok = true
 		return
 	}
-	var branchSlice []string
-	if err := json.Unmarshal([]byte(branchRules), &branchSlice); err == nil {
-		for i := range branchSlice {
-			rule := branchSlice[i]
 
-			if ok, _ = regexp.Match(rule, []byte(branch)); ok {
+	for i := range rules {
+		rule := rules[i]
+
+		if ok, _ = regexp.MatchString(rule, event.refName); ok {
+			return
+		}
+		if event.ref != "" {
+			if ok, _ = regexp.MatchString(rule, event.ref); ok {
 				return
 			}
 		}
@@ -171,6 +341,39 @@ func branchMatch(pipeline v1alpha3.Pipeline, branch string) (ok bool) {
 	return
 }
 
+// getRefRules returns the rules of the given reference type from the annotation value
+func getRefRules(refRules string, refType v1alpha3.RefType) (rules []string, found bool) {
+	if refRules == "" {
+		return
+	}
+
+	if err := json.Unmarshal([]byte(refRules), &rules); err == nil {
+		// the array rules are only for branches
+		if found = refType == v1alpha3.Branch; !found {
+			rules = nil
+		}
+		return
+	}
+
+	typedRules := map[v1alpha3.RefType][]string{}
+	if err := json.Unmarshal([]byte(refRules), &typedRules); err != nil {
+		// treat the invalid rules as no branch matched
+		found = true
+		return
+	}
+	if rules, found = typedRules[refType]; !found && refType == v1alpha3.MergeRequest {
+		// a merge request is the same as a pull request
+		rules, found = typedRules[v1alpha3.PullRequest]
+	}
+	return
+}
+
+// gitRepoURLMatch returns true if the URL of a GitRepository is one of the addresses of the repository
+func gitRepoURLMatch(url string, repo scm.Repository) bool {
+	return url != "" && gitRepoMatch(strings.TrimSuffix(url, ".git"),
+		strings.TrimSuffix(repo.Link, ".git"), strings.TrimSuffix(repo.Clone, ".git"), strings.TrimSuffix(repo.CloneSSH, ".git"))
+}
+
 // gitRepoMatch if the source matches target
 func gitRepoMatch(source string, targets ...string) (ok bool) {
 	for _, target := range targets {
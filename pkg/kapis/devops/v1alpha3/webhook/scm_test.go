@@ -17,14 +17,20 @@ limitations under the License.
 package webhook
 
 import (
+	"context"
 	"github.com/jenkins-x/go-scm/scm"
 	"github.com/jenkins-x/go-scm/scm/driver/bitbucket"
 	"github.com/jenkins-x/go-scm/scm/driver/github"
 	"github.com/jenkins-x/go-scm/scm/driver/gitlab"
+	"github.com/jenkins-zh/jenkins-client/pkg/core"
 	"github.com/kubesphere/ks-devops/pkg/api/devops/v1alpha3"
 	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 	"net/http"
+	"net/http/httptest"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 	"testing"
 )
 
@@ -85,10 +91,16 @@ func Test_getSCMClient(t *testing.T) {
 	}
 }
 
-func Test_branchMatch(t *testing.T) {
+func Test_refMatch(t *testing.T) {
+	multiBranchPipeline := v1alpha3.Pipeline{
+		Spec: v1alpha3.PipelineSpec{
+			Type: v1alpha3.MultiBranchPipelineType,
+		},
+	}
+
 	type args struct {
 		pipeline v1alpha3.Pipeline
-		branch   string
+		event    *scmEvent
 	}
 	tests := []struct {
 		name   string
@@ -98,7 +110,7 @@ func Test_branchMatch(t *testing.T) {
 		name: "no any annotations",
 		args: args{
 			pipeline: v1alpha3.Pipeline{},
-			branch:   "master",
+			event:    &scmEvent{refType: v1alpha3.Branch, refName: "master"},
 		},
 		wantOk: true,
 	}, {
@@ -111,7 +123,7 @@ func Test_branchMatch(t *testing.T) {
 					},
 				},
 			},
-			branch: "master",
+			event: &scmEvent{refType: v1alpha3.Branch, refName: "master"},
 		},
 		wantOk: true,
 	}, {
@@ -124,13 +136,535 @@ func Test_branchMatch(t *testing.T) {
 					},
 				},
 			},
-			branch: "feat-login",
+			event: &scmEvent{refType: v1alpha3.Branch, refName: "feat-login"},
+		},
+		wantOk: true,
+	}, {
+		name: "branch rule anchored on the full reference",
+		args: args{
+			pipeline: v1alpha3.Pipeline{
+				ObjectMeta: v1.ObjectMeta{
+					Annotations: map[string]string{
+						scmRefAnnotationKey: `["^refs/heads/master$"]`,
+					},
+				},
+			},
+			event: &scmEvent{refType: v1alpha3.Branch, refName: "master", ref: "refs/heads/master"},
+		},
+		wantOk: true,
+	}, {
+		name: "branch rule anchored on the full reference not matched",
+		args: args{
+			pipeline: v1alpha3.Pipeline{
+				ObjectMeta: v1.ObjectMeta{
+					Annotations: map[string]string{
+						scmRefAnnotationKey: `["^refs/heads/master$"]`,
+					},
+				},
+			},
+			event: &scmEvent{refType: v1alpha3.Branch, refName: "dev", ref: "refs/heads/dev"},
+		},
+		wantOk: false,
+	}, {
+		name: "invalid rules",
+		args: args{
+			pipeline: v1alpha3.Pipeline{
+				ObjectMeta: v1.ObjectMeta{
+					Annotations: map[string]string{
+						scmRefAnnotationKey: `invalid`,
+					},
+				},
+			},
+			event: &scmEvent{refType: v1alpha3.Branch, refName: "master"},
+		},
+		wantOk: false,
+	}, {
+		name: "tag without any annotations",
+		args: args{
+			pipeline: v1alpha3.Pipeline{},
+			event:    &scmEvent{refType: v1alpha3.Tag, refName: "v1.0.0"},
+		},
+		wantOk: false,
+	}, {
+		name: "tag of a multi-branch Pipeline without any annotations",
+		args: args{
+			pipeline: multiBranchPipeline,
+			event:    &scmEvent{refType: v1alpha3.Tag, refName: "v1.0.0"},
 		},
 		wantOk: true,
+	}, {
+		name: "tag with branch rules only",
+		args: args{
+			pipeline: v1alpha3.Pipeline{
+				ObjectMeta: v1.ObjectMeta{
+					Annotations: map[string]string{
+						scmRefAnnotationKey: `[".*"]`,
+					},
+				},
+			},
+			event: &scmEvent{refType: v1alpha3.Tag, refName: "v1.0.0"},
+		},
+		wantOk: false,
+	}, {
+		name: "tag with typed rules",
+		args: args{
+			pipeline: v1alpha3.Pipeline{
+				ObjectMeta: v1.ObjectMeta{
+					Annotations: map[string]string{
+						scmRefAnnotationKey: `{"branch": ["master"], "tag": ["^v.*"]}`,
+					},
+				},
+				Spec: multiBranchPipeline.Spec,
+			},
+			event: &scmEvent{refType: v1alpha3.Tag, refName: "v1.0.0"},
+		},
+		wantOk: true,
+	}, {
+		name: "tag of a regular Pipeline with typed rules",
+		args: args{
+			pipeline: v1alpha3.Pipeline{
+				ObjectMeta: v1.ObjectMeta{
+					Annotations: map[string]string{
+						scmRefAnnotationKey: `{"branch": ["master"], "tag": ["^v.*"]}`,
+					},
+				},
+			},
+			event: &scmEvent{refType: v1alpha3.Tag, refName: "v1.0.0"},
+		},
+		wantOk: false,
+	}, {
+		name: "branch not matched with typed rules",
+		args: args{
+			pipeline: v1alpha3.Pipeline{
+				ObjectMeta: v1.ObjectMeta{
+					Annotations: map[string]string{
+						scmRefAnnotationKey: `{"branch": ["master"], "tag": ["^v.*"]}`,
+					},
+				},
+			},
+			event: &scmEvent{refType: v1alpha3.Branch, refName: "dev"},
+		},
+		wantOk: false,
+	}, {
+		name: "merge request matched with pull request rules",
+		args: args{
+			pipeline: v1alpha3.Pipeline{
+				ObjectMeta: v1.ObjectMeta{
+					Annotations: map[string]string{
+						scmRefAnnotationKey: `{"pr": ["PR-.*", "MR-.*"]}`,
+					},
+				},
+				Spec: multiBranchPipeline.Spec,
+			},
+			event: &scmEvent{refType: v1alpha3.MergeRequest, refName: "MR-1"},
+		},
+		wantOk: true,
+	}, {
+		name: "pull request of a multi-branch Pipeline not matched with typed rules",
+		args: args{
+			pipeline: v1alpha3.Pipeline{
+				ObjectMeta: v1.ObjectMeta{
+					Annotations: map[string]string{
+						scmRefAnnotationKey: `{"pr": []}`,
+					},
+				},
+				Spec: multiBranchPipeline.Spec,
+			},
+			event: &scmEvent{refType: v1alpha3.PullRequest, refName: "PR-1"},
+		},
+		wantOk: false,
 	}}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			assert.Equalf(t, tt.wantOk, branchMatch(tt.args.pipeline, tt.args.branch), "branchMatch(%v, %v)", tt.args.pipeline, tt.args.branch)
+			assert.Equalf(t, tt.wantOk, refMatch(tt.args.pipeline, tt.args.event),
+				"refMatch(%v, %v)", tt.args.pipeline, tt.args.event)
+		})
+	}
+}
+
+func Test_getSCMEvent(t *testing.T) {
+	tests := []struct {
+		name    string
+		driver  scm.Driver
+		webhook scm.Webhook
+		want    *scmEvent
+	}{{
+		name:    "branch push",
+		driver:  scm.DriverGithub,
+		webhook: &scm.PushHook{Ref: "refs/heads/master"},
+		want:    &scmEvent{refType: v1alpha3.Branch, refName: "master", ref: "refs/heads/master"},
+	}, {
+		name:    "tag push",
+		driver:  scm.DriverGithub,
+		webhook: &scm.PushHook{Ref: "refs/tags/v1.0.0"},
+		want:    &scmEvent{refType: v1alpha3.Tag, refName: "v1.0.0", ref: "refs/tags/v1.0.0"},
+	}, {
+		name:    "deleted tag",
+		driver:  scm.DriverGithub,
+		webhook: &scm.PushHook{Ref: "refs/tags/v1.0.0", Deleted: true},
+		want:    nil,
+	}, {
+		name:    "pull request opened",
+		driver:  scm.DriverGithub,
+		webhook: &scm.PullRequestHook{Action: scm.ActionOpen, PullRequest: scm.PullRequest{Number: 1, Ref: "refs/pull/1/head"}},
+		want:    &scmEvent{refType: v1alpha3.PullRequest, refName: "PR-1", ref: "refs/pull/1/head"},
+	}, {
+		name:    "pull request synchronized",
+		driver:  scm.DriverBitbucket,
+		webhook: &scm.PullRequestHook{Action: scm.ActionSync, PullRequest: scm.PullRequest{Number: 2}},
+		want:    &scmEvent{refType: v1alpha3.PullRequest, refName: "PR-2"},
+	}, {
+		name:    "pull request updated",
+		driver:  scm.DriverGithub,
+		webhook: &scm.PullRequestHook{Action: scm.ActionUpdate, PullRequest: scm.PullRequest{Number: 1}},
+		want:    nil,
+	}, {
+		name:    "pull request closed",
+		driver:  scm.DriverGithub,
+		webhook: &scm.PullRequestHook{Action: scm.ActionClose, PullRequest: scm.PullRequest{Number: 1}},
+		want:    nil,
+	}, {
+		name:    "merge request reopened",
+		driver:  scm.DriverGitlab,
+		webhook: &scm.PullRequestHook{Action: scm.ActionReopen, PullRequest: scm.PullRequest{Number: 3}},
+		want:    &scmEvent{refType: v1alpha3.MergeRequest, refName: "MR-3"},
+	}, {
+		name:    "merge request updated",
+		driver:  scm.DriverGitlab,
+		webhook: &scm.PullRequestHook{Action: scm.ActionUpdate, PullRequest: scm.PullRequest{Number: 3}},
+		want:    &scmEvent{refType: v1alpha3.MergeRequest, refName: "MR-3"},
+	}, {
+		name:    "unsupported event",
+		driver:  scm.DriverGithub,
+		webhook: &scm.PingHook{},
+		want:    nil,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, getSCMEvent(tt.driver, tt.webhook))
+		})
+	}
+}
+
+func Test_getTokenFromSecret(t *testing.T) {
+	tests := []struct {
+		name   string
+		secret *corev1.Secret
+		want   string
+	}{{
+		name: "basic auth",
+		secret: &corev1.Secret{
+			Type: corev1.SecretTypeBasicAuth,
+			Data: map[string][]byte{corev1.BasicAuthPasswordKey: []byte("password")},
+		},
+		want: "password",
+	}, {
+		name: "DevOps basic auth",
+		secret: &corev1.Secret{
+			Type: v1alpha3.SecretTypeBasicAuth,
+			Data: map[string][]byte{v1alpha3.BasicAuthPasswordKey: []byte("password")},
+		},
+		want: "password",
+	}, {
+		name: "secret text",
+		secret: &corev1.Secret{
+			Type: v1alpha3.SecretTypeSecretText,
+			Data: map[string][]byte{v1alpha3.SecretTextSecretKey: []byte("secret")},
+		},
+		want: "secret",
+	}, {
+		name: "opaque",
+		secret: &corev1.Secret{
+			Type: corev1.SecretTypeOpaque,
+			Data: map[string][]byte{corev1.ServiceAccountTokenKey: []byte("token")},
+		},
+		want: "token",
+	}, {
+		name:   "no data",
+		secret: &corev1.Secret{},
+		want:   "",
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, getTokenFromSecret(tt.secret))
+		})
+	}
+}
+
+func TestSCMHandler_getWebhookSecrets(t *testing.T) {
+	schema, err := v1alpha3.SchemeBuilder.Register().Build()
+	assert.Nil(t, err)
+	assert.Nil(t, corev1.AddToScheme(schema))
+
+	gitRepo := &v1alpha3.GitRepository{
+		ObjectMeta: v1.ObjectMeta{Name: "repo", Namespace: "ns"},
+		Spec: v1alpha3.GitRepositorySpec{
+			URL:      "https://github.com/linuxsuren/test",
+			Secret:   &corev1.SecretReference{Name: "repo-secret"},
+			Webhooks: []corev1.LocalObjectReference{{Name: "hook"}, {Name: "missing"}},
+		},
+	}
+	otherRepo := &v1alpha3.GitRepository{
+		ObjectMeta: v1.ObjectMeta{Name: "other", Namespace: "ns"},
+		Spec: v1alpha3.GitRepositorySpec{
+			URL:    "https://github.com/linuxsuren/other",
+			Secret: &corev1.SecretReference{Name: "other-secret"},
+		},
+	}
+	webhook := &v1alpha3.Webhook{
+		ObjectMeta: v1.ObjectMeta{Name: "hook", Namespace: "ns"},
+		Spec: v1alpha3.WebhookSpec{
+			Secret: &corev1.SecretReference{Name: "hook-secret"},
+		},
+	}
+	newSecret := func(name, token string) *corev1.Secret {
+		return &corev1.Secret{
+			ObjectMeta: v1.ObjectMeta{Name: name, Namespace: "ns"},
+			Type:       v1alpha3.SecretTypeSecretText,
+			Data:       map[string][]byte{v1alpha3.SecretTextSecretKey: []byte(token)},
+		}
+	}
+
+	handler := NewSCMHandler(fake.NewClientBuilder().WithScheme(schema).WithObjects(gitRepo.DeepCopy(), otherRepo.DeepCopy(),
+		webhook.DeepCopy(), newSecret("repo-secret", "repo-token"), newSecret("hook-secret", "hook-token"),
+		newSecret("other-secret", "other-token")).Build(), core.JenkinsCore{})
+	assert.Equal(t, []string{"hook-token", "repo-token"}, handler.getWebhookSecrets(context.Background(), gitRepo))
+	assert.Equal(t, []string{"other-token"}, handler.getWebhookSecrets(context.Background(), otherRepo))
+}
+
+func TestSCMHandler_getBoundGitRepositories(t *testing.T) {
+	schema, err := v1alpha3.SchemeBuilder.Register().Build()
+	assert.Nil(t, err)
+
+	newGitRepo := func(namespace, name, url string) *v1alpha3.GitRepository {
+		return &v1alpha3.GitRepository{
+			ObjectMeta: v1.ObjectMeta{Name: name, Namespace: namespace},
+			Spec:       v1alpha3.GitRepositorySpec{URL: url},
+		}
+	}
+	newPipeline := func(namespace, gitRepository string) *v1alpha3.Pipeline {
+		pipeline := &v1alpha3.Pipeline{ObjectMeta: v1.ObjectMeta{Name: "pipeline", Namespace: namespace}}
+		if gitRepository != "" {
+			pipeline.Spec.Source = &v1alpha3.PipelineSource{GitRepository: gitRepository}
+		}
+		return pipeline
+	}
+	repo := scm.Repository{Link: "https://github.com/linuxsuren/test", Clone: "https://github.com/linuxsuren/test.git"}
+
+	tests := []struct {
+		name     string
+		pipeline *v1alpha3.Pipeline
+		repo     scm.Repository
+		want     []string
+	}{{
+		name:     "the GitRepositories of the namespace",
+		pipeline: newPipeline("ns", ""),
+		repo:     repo,
+		want:     []string{"ns/repo", "ns/repo-copy"},
+	}, {
+		name:     "the GitRepository of the source",
+		pipeline: newPipeline("ns", "repo-copy"),
+		repo:     repo,
+		want:     []string{"ns/repo-copy"},
+	}, {
+		name:     "the source is another repository",
+		pipeline: newPipeline("ns", "other"),
+		repo:     repo,
+	}, {
+		name:     "the GitRepository of the source does not exist",
+		pipeline: newPipeline("ns", "missing"),
+		repo:     repo,
+	}, {
+		name:     "no GitRepository in the namespace",
+		pipeline: newPipeline("empty", ""),
+		repo:     repo,
+	}, {
+		name:     "not matched",
+		pipeline: newPipeline("ns", ""),
+		repo:     scm.Repository{Link: "https://github.com/linuxsuren/fake"},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewSCMHandler(fake.NewClientBuilder().WithScheme(schema).WithObjects(
+				newGitRepo("ns", "repo", "https://github.com/linuxsuren/test"),
+				newGitRepo("ns", "repo-copy", "https://github.com/linuxsuren/test.git"),
+				newGitRepo("ns", "other", "https://github.com/linuxsuren/other"),
+				newGitRepo("tenant", "repo", "https://github.com/linuxsuren/test")).Build(), core.JenkinsCore{})
+			gitRepos, err := handler.getBoundGitRepositories(context.Background(), tt.pipeline, tt.repo)
+			assert.Nil(t, err)
+			var names []string
+			for _, gitRepo := range gitRepos {
+				names = append(names, gitRepo.Namespace+"/"+gitRepo.Name)
+			}
+			assert.Equal(t, tt.want, names)
+		})
+	}
+}
+
+func TestSCMHandler_requestToSyncPipelineSources(t *testing.T) {
+	schema, err := v1alpha3.SchemeBuilder.Register().Build()
+	assert.Nil(t, err)
+
+	gitRepo := &v1alpha3.GitRepository{
+		ObjectMeta: v1.ObjectMeta{Name: "repo", Namespace: "ns"},
+		Spec:       v1alpha3.GitRepositorySpec{URL: "https://github.com/linuxsuren/test.git"},
+	}
+	newPipeline := func(name, gitRepository, ref string) *v1alpha3.Pipeline {
+		return &v1alpha3.Pipeline{
+			ObjectMeta: v1.ObjectMeta{Name: name, Namespace: "ns"},
+			Spec: v1alpha3.PipelineSpec{
+				Type:   v1alpha3.NoScmPipelineType,
+				Source: &v1alpha3.PipelineSource{GitRepository: gitRepository, Ref: ref, Path: "Jenkinsfile"},
+			},
+		}
+	}
+	repo := scm.Repository{Link: "https://github.com/linuxsuren/test", Branch: "master"}
+
+	tests := []struct {
+		name          string
+		repo          scm.Repository
+		event         *scmEvent
+		unsigned      bool
+		wantRequested bool
+		wantRejected  bool
+		wantPipelines []string
+	}{{
+		name:          "push to the default branch",
+		repo:          repo,
+		event:         &scmEvent{refType: v1alpha3.Branch, refName: "master"},
+		wantRequested: true,
+		wantPipelines: []string{"default"},
+	}, {
+		name:          "push to a branch",
+		repo:          repo,
+		event:         &scmEvent{refType: v1alpha3.Branch, refName: "dev"},
+		wantRequested: true,
+		wantPipelines: []string{"dev"},
+	}, {
+		name:         "unsigned webhook",
+		repo:         repo,
+		event:        &scmEvent{refType: v1alpha3.Branch, refName: "master"},
+		unsigned:     true,
+		wantRejected: true,
+	}, {
+		name:  "push a tag",
+		repo:  repo,
+		event: &scmEvent{refType: v1alpha3.Tag, refName: "v1.0"},
+	}, {
+		name:  "pull request",
+		repo:  repo,
+		event: &scmEvent{refType: v1alpha3.PullRequest, refName: "PR-1"},
+	}, {
+		name:  "other repository",
+		repo:  scm.Repository{Link: "https://github.com/linuxsuren/fake"},
+		event: &scmEvent{refType: v1alpha3.Branch, refName: "master"},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := fake.NewClientBuilder().WithScheme(schema).WithObjects(gitRepo.DeepCopy(),
+				newPipeline("default", "repo", ""), newPipeline("dev", "repo", "dev"),
+				newPipeline("other", "other", ""), &v1alpha3.Pipeline{ObjectMeta: v1.ObjectMeta{Name: "none", Namespace: "ns"}}).Build()
+			handler := NewSCMHandler(c, core.JenkinsCore{})
+			verifier := &webhookVerifier{handler: handler, results: map[types.NamespacedName]bool{
+				{Namespace: "ns", Name: "repo"}: !tt.unsigned,
+			}}
+			requested, rejected, err := handler.requestToSyncPipelineSources(context.Background(), verifier, tt.repo, tt.event)
+			assert.Nil(t, err)
+			assert.Equal(t, tt.wantRequested, requested)
+			assert.Equal(t, tt.wantRejected, rejected)
+
+			pipelines := &v1alpha3.PipelineList{}
+			assert.Nil(t, c.List(context.Background(), pipelines))
+			var requestedPipelines []string
+			for _, pipeline := range pipelines.Items {
+				if _, ok := pipeline.Annotations[v1alpha3.PipelineRequestToSyncSourceAnnoKey]; ok {
+					requestedPipelines = append(requestedPipelines, pipeline.Name)
+				}
+			}
+			assert.Equal(t, tt.wantPipelines, requestedPipelines)
+		})
+	}
+}
+
+func TestSCMHandler_buildMultiBranchPipeline(t *testing.T) {
+	schema, err := v1alpha3.SchemeBuilder.Register().Build()
+	assert.Nil(t, err)
+
+	pipeline := v1alpha3.Pipeline{
+		ObjectMeta: v1.ObjectMeta{Name: "pipeline", Namespace: "ns"},
+		Spec:       v1alpha3.PipelineSpec{Type: v1alpha3.MultiBranchPipelineType},
+	}
+	tests := []struct {
+		name         string
+		event        *scmEvent
+		branchJobs   []string
+		wantScanned  bool
+		wantSCM      *v1alpha3.SCM
+		wantErrorMsg string
+	}{{
+		name:        "branch push",
+		event:       &scmEvent{refType: v1alpha3.Branch, refName: "master"},
+		branchJobs:  []string{"master"},
+		wantScanned: true,
+	}, {
+		name:       "pull request with an existing branch job",
+		event:      &scmEvent{refType: v1alpha3.PullRequest, refName: "PR-1"},
+		branchJobs: []string{"PR-1"},
+		wantSCM:    &v1alpha3.SCM{RefName: "PR-1", RefType: v1alpha3.PullRequest},
+	}, {
+		name:       "tag with an existing branch job",
+		event:      &scmEvent{refType: v1alpha3.Tag, refName: "v1.0"},
+		branchJobs: []string{"v1.0"},
+		wantSCM:    &v1alpha3.SCM{RefName: "v1.0", RefType: v1alpha3.Tag},
+	}, {
+		name:        "merge request without the branch job",
+		event:       &scmEvent{refType: v1alpha3.MergeRequest, refName: "MR-1"},
+		wantScanned: true,
+	}, {
+		name:         "failed to get the branch job",
+		event:        &scmEvent{refType: v1alpha3.PullRequest, refName: "error"},
+		wantErrorMsg: "unexpected status code: 500",
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scanned := false
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch {
+				case r.Method == http.MethodPost && r.URL.Path == "/job/ns/job/pipeline/build":
+					scanned = true
+					w.WriteHeader(http.StatusCreated)
+				case r.URL.Path == "/job/ns/job/pipeline/job/error/api/json":
+					w.WriteHeader(http.StatusInternalServerError)
+				default:
+					for _, branchJob := range tt.branchJobs {
+						if r.URL.Path == "/job/ns/job/pipeline/job/"+branchJob+"/api/json" {
+							_, _ = w.Write([]byte("{}"))
+							return
+						}
+					}
+					w.WriteHeader(http.StatusNotFound)
+				}
+			}))
+			defer server.Close()
+
+			c := fake.NewClientBuilder().WithScheme(schema).Build()
+			handler := NewSCMHandler(c, core.JenkinsCore{URL: server.URL})
+			err := handler.buildMultiBranchPipeline(pipeline, tt.event)
+			if tt.wantErrorMsg != "" {
+				assert.EqualError(t, err, tt.wantErrorMsg)
+			} else {
+				assert.Nil(t, err)
+			}
+			assert.Equal(t, tt.wantScanned, scanned)
+
+			runs := &v1alpha3.PipelineRunList{}
+			assert.Nil(t, c.List(context.Background(), runs))
+			if tt.wantSCM == nil {
+				assert.Empty(t, runs.Items)
+			} else if assert.Len(t, runs.Items, 1) {
+				assert.Equal(t, tt.wantSCM, runs.Items[0].Spec.SCM)
+				assert.Equal(t, "webhook", runs.Items[0].Annotations[triggerAnnotationKey])
+			}
 		})
 	}
 }
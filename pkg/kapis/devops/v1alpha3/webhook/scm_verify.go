@@ -0,0 +1,226 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/kubesphere/ks-devops/pkg/api/devops/v1alpha3"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// maxPayloadSize is the max size of a webhook payload, it's the same as the limitation of go-scm
+const maxPayloadSize = 10000000
+
+// allowUnsignedAnnotationKey allows a Pipeline to be triggered by the webhooks which are not signed by the secrets
+// of its GitRepositories, it's only for the SCM servers which are unable to sign the webhooks
+const allowUnsignedAnnotationKey = "scm.devops.kubesphere.io/allow-unsigned"
+
+// webhookVerifier verifies the signature of a webhook payload against the secrets of the GitRepositories, the results
+// are cached by GitRepository
+type webhookVerifier struct {
+	handler   *SCMHandler
+	scmClient *scm.Client
+	request   *http.Request
+	payload   []byte
+	results   map[types.NamespacedName]bool
+}
+
+// parseWebhook parses the webhook payload without verifying its signature, the signature is verified by the returned
+// verifier against the GitRepositories of the triggered Pipelines
+func (h *SCMHandler) parseWebhook(scmClient *scm.Client, request *http.Request) (
+	webhook scm.Webhook, verifier *webhookVerifier, err error) {
+	var payload []byte
+	if payload, err = io.ReadAll(io.LimitReader(request.Body, maxPayloadSize)); err != nil {
+		return
+	}
+	verifier = &webhookVerifier{
+		handler:   h,
+		scmClient: scmClient,
+		request:   request,
+		payload:   payload,
+		results:   map[types.NamespacedName]bool{},
+	}
+
+	request.Body = io.NopCloser(bytes.NewReader(payload))
+	// an empty secret skips the verification
+	webhook, err = scmClient.Webhooks.Parse(request, func(scm.Webhook) (string, error) {
+		return "", nil
+	})
+	return
+}
+
+// verifyPipeline returns true if the webhook is signed by a secret of the GitRepositories bound to the Pipeline, or
+// the Pipeline allows the unsigned webhooks
+func (v *webhookVerifier) verifyPipeline(ctx context.Context, pipeline *v1alpha3.Pipeline, repo scm.Repository) (
+	verified bool, err error) {
+	if pipeline.Annotations[allowUnsignedAnnotationKey] == "true" {
+		verified = true
+		return
+	}
+
+	var gitRepos []v1alpha3.GitRepository
+	if gitRepos, err = v.handler.getBoundGitRepositories(ctx, pipeline, repo); err != nil {
+		return
+	}
+	for i := range gitRepos {
+		if verified = v.verifyGitRepository(ctx, &gitRepos[i]); verified {
+			return
+		}
+	}
+	return
+}
+
+// verifyGitRepository returns true if the webhook is signed by one of the secrets of the GitRepository
+func (v *webhookVerifier) verifyGitRepository(ctx context.Context, gitRepo *v1alpha3.GitRepository) (verified bool) {
+	key := types.NamespacedName{Namespace: gitRepo.Namespace, Name: gitRepo.Name}
+	var cached bool
+	if verified, cached = v.results[key]; cached {
+		return
+	}
+
+	// try all secrets, there might be multiple Webhooks for the same GitRepository
+	for _, secret := range v.handler.getWebhookSecrets(ctx, gitRepo) {
+		v.request.Body = io.NopCloser(bytes.NewReader(v.payload))
+		if _, err := v.scmClient.Webhooks.Parse(v.request, func(scm.Webhook) (string, error) {
+			return secret, nil
+		}); err == nil {
+			verified = true
+			break
+		}
+	}
+	v.results[key] = verified
+	return
+}
+
+// isVerified returns true if the webhook was signed by the secret of any GitRepository
+func (v *webhookVerifier) isVerified() bool {
+	if v == nil {
+		return false
+	}
+	for _, verified := range v.results {
+		if verified {
+			return true
+		}
+	}
+	return false
+}
+
+// getBoundGitRepositories returns the GitRepositories whose secrets are able to sign the webhooks of a Pipeline. It's
+// the GitRepository of the source if the Pipeline is Git-managed, otherwise they are the GitRepositories of the
+// repository in the namespace of the Pipeline.
+func (h *SCMHandler) getBoundGitRepositories(ctx context.Context, pipeline *v1alpha3.Pipeline, repo scm.Repository) (
+	gitRepos []v1alpha3.GitRepository, err error) {
+	if source := pipeline.Spec.Source; source != nil && source.GitRepository != "" {
+		gitRepo := &v1alpha3.GitRepository{}
+		if err = h.Get(ctx, types.NamespacedName{Namespace: pipeline.Namespace, Name: source.GitRepository}, gitRepo); err != nil {
+			err = client.IgnoreNotFound(err)
+		} else if gitRepoURLMatch(gitRepo.Spec.URL, repo) {
+			gitRepos = append(gitRepos, *gitRepo)
+		}
+		return
+	}
+
+	repoList := &v1alpha3.GitRepositoryList{}
+	if err = h.List(ctx, repoList, client.InNamespace(pipeline.Namespace)); err != nil {
+		err = fmt.Errorf("failed to list GitRepositories, error: %v", err)
+		return
+	}
+	for i := range repoList.Items {
+		if gitRepoURLMatch(repoList.Items[i].Spec.URL, repo) {
+			gitRepos = append(gitRepos, repoList.Items[i])
+		}
+	}
+	return
+}
+
+// getWebhookSecrets returns the secrets which might be used to sign the webhooks of a GitRepository.
+// The secrets of its Webhooks come first, then the secret of the GitRepository.
+func (h *SCMHandler) getWebhookSecrets(ctx context.Context, gitRepo *v1alpha3.GitRepository) (secrets []string) {
+	for _, webhookRef := range gitRepo.Spec.Webhooks {
+		webhook := &v1alpha3.Webhook{}
+		if err := h.Get(ctx, types.NamespacedName{Namespace: gitRepo.Namespace, Name: webhookRef.Name}, webhook); err != nil {
+			klog.V(4).Infof("cannot find webhook %s/%s, error: %v", gitRepo.Namespace, webhookRef.Name, err)
+			continue
+		}
+		if token := h.getTokenFromSecretRef(ctx, webhook.Spec.Secret, webhook.Namespace); token != "" {
+			secrets = appendIfAbsent(secrets, token)
+		}
+	}
+	if token := h.getTokenFromSecretRef(ctx, gitRepo.Spec.Secret, gitRepo.Namespace); token != "" {
+		secrets = appendIfAbsent(secrets, token)
+	}
+	return
+}
+
+// getTokenFromSecretRef returns the token of a secret, taking the default namespace if it is empty
+func (h *SCMHandler) getTokenFromSecretRef(ctx context.Context, ref *v1.SecretReference, defaultNamespace string) (token string) {
+	if ref == nil || ref.Name == "" {
+		return
+	}
+	ns := ref.Namespace
+	if ns == "" {
+		ns = defaultNamespace
+	}
+
+	secret := &v1.Secret{}
+	if err := h.Get(ctx, types.NamespacedName{Namespace: ns, Name: ref.Name}, secret); err != nil {
+		klog.V(4).Infof("cannot get secret %s/%s, error: %v", ns, ref.Name, err)
+		return
+	}
+	return getTokenFromSecret(secret)
+}
+
+func getTokenFromSecret(secret *v1.Secret) (token string) {
+	switch secret.Type {
+	case v1.SecretTypeBasicAuth, v1alpha3.SecretTypeBasicAuth:
+		token = string(secret.Data[v1.BasicAuthPasswordKey])
+	case v1alpha3.SecretTypeSecretText:
+		token = string(secret.Data[v1alpha3.SecretTextSecretKey])
+	default:
+		token = string(secret.Data[v1.ServiceAccountTokenKey])
+	}
+	return
+}
+
+func appendIfAbsent(items []string, item string) []string {
+	for _, existing := range items {
+		if existing == item {
+			return items
+		}
+	}
+	return append(items, item)
+}
+
+// auditWebhook records who sent a webhook, and how it was handled
+func auditWebhook(request *http.Request, webhook scm.Webhook, verified bool, code int, message string) {
+	var kind, repo string
+	if webhook != nil {
+		kind = string(webhook.Kind())
+		repo = webhook.Repository().Link
+	}
+	klog.InfoS("received SCM webhook", "remoteAddr", request.RemoteAddr, "forwardedFor", request.Header.Get("X-Forwarded-For"),
+		"userAgent", request.UserAgent(), "kind", kind, "repository", repo, "verified", verified, "code", code, "message", message)
+}
@@ -17,6 +17,7 @@ package argocd
 
 import (
 	"context"
+	"fmt"
 	"net/http"
 
 	"github.com/emicklei/go-restful/v3"
@@ -42,6 +43,9 @@ var invalidRequestBodyError = restful.NewError(http.StatusBadRequest,
 var unauthenticatedError = restful.NewError(http.StatusUnauthorized,
 	"unauthenticated request")
 
+var invalidRollbackRequestError = restful.NewError(http.StatusBadRequest,
+	"invalid application rollback request")
+
 func (h *handler) createApplication(req *restful.Request, res *restful.Response) {
 	var err error
 	namespace := common.GetPathParameter(req, common.NamespacePathParameter)
@@ -159,7 +163,64 @@ func (h *handler) syncApplication(namespace, name string, syncRequest *Applicati
 	return h.updateOperation(namespace, name, operation)
 }
 
-func (h *handler) updateOperation(namespace, name string, operation *v1alpha1.Operation) (*v1alpha1.Application, error) {
+func (h *handler) handleRollbackApplication(req *restful.Request, res *restful.Response) {
+	namespace := common.GetPathParameter(req, common.NamespacePathParameter)
+	name := common.GetPathParameter(req, pathParameterApplication)
+	rollbackRequest := &ApplicationRollbackRequest{}
+	if err := req.ReadEntity(rollbackRequest); err != nil || rollbackRequest.ID <= 0 {
+		common.Response(req, res, nil, invalidRollbackRequestError)
+		return
+	}
+
+	currentUser, ok := serverrequest.UserFrom(req.Request.Context())
+	if !ok || currentUser == nil {
+		common.Response(req, res, nil, unauthenticatedError)
+		return
+	}
+
+	app, err := h.rollbackApplication(namespace, name, rollbackRequest, currentUser)
+	common.Response(req, res, app, err)
+}
+
+// rollbackApplication syncs an Application to a revision in its history. The automated sync is disabled, otherwise
+// Argo CD would sync it to the latest revision right after the rollback.
+func (h *handler) rollbackApplication(namespace, name string, rollbackRequest *ApplicationRollbackRequest, currentUser user.Info) (*v1alpha1.Application, error) {
+	app := &v1alpha1.Application{}
+	if err := h.Get(context.Background(), types.NamespacedName{Namespace: namespace, Name: name}, app); err != nil {
+		return nil, err
+	}
+	if app.Spec.ArgoApp == nil {
+		return nil, argoAppNotConfiguredError
+	}
+	history := app.Status.GetHistory(rollbackRequest.ID)
+	if history == nil {
+		return nil, restful.NewError(http.StatusBadRequest, fmt.Sprintf("revision history %d not found", rollbackRequest.ID))
+	}
+
+	operation := &v1alpha1.Operation{
+		Sync: &v1alpha1.SyncOperation{
+			Revision: history.Revision,
+			Source:   history.Source.DeepCopy(),
+			Prune:    rollbackRequest.Prune,
+			DryRun:   rollbackRequest.DryRun,
+		},
+		InitiatedBy: v1alpha1.OperationInitiator{Username: currentUser.GetName()},
+		Info:        []*v1alpha1.Info{{Name: "Reason", Value: fmt.Sprintf("Rollback to revision %s (%d)", history.Revision, history.ID)}},
+	}
+	return h.updateOperation(namespace, name, operation, disableAutomatedSync)
+}
+
+// disableAutomatedSync disables the automated sync of an Argo CD Application
+func disableAutomatedSync(argoApp *v1alpha1.ArgoApplication) {
+	if argoApp.Spec.SyncPolicy != nil {
+		argoApp.Spec.SyncPolicy.Automated = nil
+	}
+}
+
+// updateOperation sets the operation of an Argo CD Application, the mutators change the Argo CD Application along
+// with the operation
+func (h *handler) updateOperation(namespace, name string, operation *v1alpha1.Operation,
+	mutators ...func(*v1alpha1.ArgoApplication)) (*v1alpha1.Application, error) {
 	var app *v1alpha1.Application
 	return app, utilretry.RetryOnConflict(utilretry.DefaultRetry, func() error {
 		app = &v1alpha1.Application{}
@@ -175,6 +236,9 @@ func (h *handler) updateOperation(namespace, name string, operation *v1alpha1.Op
 		}
 
 		app.Spec.ArgoApp.Operation = operation
+		for _, mutate := range mutators {
+			mutate(app.Spec.ArgoApp)
+		}
 		if err := h.Update(context.Background(), app); err != nil {
 			return err
 		}
@@ -304,3 +304,55 @@ func Test_handler_updateOperation(t *testing.T) {
 		})
 	}
 }
+
+func Test_handler_rollbackApplication(t *testing.T) {
+	source := &v1alpha1.ApplicationSource{RepoURL: "https://github.com/fake/repo", Path: "config", TargetRevision: "v1"}
+	createApp := func(name string, op *v1alpha1.Operation) *v1alpha1.Application {
+		return &v1alpha1.Application{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "fake-namespace"},
+			Spec: v1alpha1.ApplicationSpec{
+				ArgoApp: &v1alpha1.ArgoApplication{
+					Operation: op,
+					Spec: v1alpha1.ArgoApplicationSpec{
+						SyncPolicy: &v1alpha1.SyncPolicy{
+							Automated:   &v1alpha1.SyncPolicyAutomated{Prune: true},
+							SyncOptions: v1alpha1.SyncOptions{"CreateNamespace=true"},
+						},
+					},
+				},
+			},
+			Status: v1alpha1.ApplicationStatus{History: []v1alpha1.RevisionHistory{
+				{ID: 1, Revision: "abc", Source: source},
+				{ID: 2, Revision: "def"},
+			}},
+		}
+	}
+	notArgoApp := createApp("not-argo", nil)
+	notArgoApp.Spec.ArgoApp = nil
+
+	utilruntime.Must(v1alpha1.AddToScheme(scheme.Scheme))
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(createApp("fake-app", nil),
+		createApp("running-app", &v1alpha1.Operation{}), notArgoApp).Build()
+	h := &handler{Handler: &gitops.Handler{Client: fakeClient}}
+	currentUser := &user.DefaultInfo{Name: "fake-user"}
+
+	_, err := h.rollbackApplication("fake-namespace", "not-argo", &ApplicationRollbackRequest{ID: 1}, currentUser)
+	assert.Equal(t, argoAppNotConfiguredError, err)
+
+	_, err = h.rollbackApplication("fake-namespace", "fake-app", &ApplicationRollbackRequest{ID: 3}, currentUser)
+	assert.Equal(t, restful.NewError(http.StatusBadRequest, "revision history 3 not found"), err)
+
+	_, err = h.rollbackApplication("fake-namespace", "running-app", &ApplicationRollbackRequest{ID: 1}, currentUser)
+	assert.Equal(t, operationAlreadyInProgressError, err)
+
+	app, err := h.rollbackApplication("fake-namespace", "fake-app", &ApplicationRollbackRequest{ID: 1, Prune: true}, currentUser)
+	assert.Nil(t, err)
+	assert.Nil(t, app.Spec.ArgoApp.Spec.SyncPolicy.Automated)
+	assert.Equal(t, v1alpha1.SyncOptions{"CreateNamespace=true"}, app.Spec.ArgoApp.Spec.SyncPolicy.SyncOptions)
+	op := app.Spec.ArgoApp.Operation
+	if assert.NotNil(t, op) {
+		assert.Equal(t, &v1alpha1.SyncOperation{Revision: "abc", Source: source, Prune: true}, op.Sync)
+		assert.Equal(t, v1alpha1.OperationInitiator{Username: "fake-user"}, op.InitiatedBy)
+		assert.Equal(t, []*v1alpha1.Info{{Name: "Reason", Value: "Rollback to revision abc (1)"}}, op.Info)
+	}
+}
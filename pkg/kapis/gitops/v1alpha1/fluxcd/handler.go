@@ -17,15 +17,31 @@ package fluxcd
 
 import (
 	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
 	"github.com/emicklei/go-restful/v3"
 	"github.com/kubesphere/ks-devops/pkg/api/gitops/v1alpha1"
+	serverrequest "github.com/kubesphere/ks-devops/pkg/apiserver/request"
 	"github.com/kubesphere/ks-devops/pkg/config"
 	"github.com/kubesphere/ks-devops/pkg/kapis/common"
 	"github.com/kubesphere/ks-devops/pkg/kapis/gitops/v1alpha1/gitops"
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apiserver/pkg/authentication/user"
+	utilretry "k8s.io/client-go/util/retry"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
+var invalidRollbackRequestError = restful.NewError(http.StatusBadRequest,
+	"invalid application rollback request")
+var unauthenticatedError = restful.NewError(http.StatusUnauthorized,
+	"unauthenticated request")
+var rollbackNotSupportedError = restful.NewError(http.StatusBadRequest,
+	"only the application with a chart from a HelmRepository can be rolled back")
+
 func (h *handler) createApplication(req *restful.Request, res *restful.Response) {
 	var err error
 	namespace := common.GetPathParameter(req, common.NamespacePathParameter)
@@ -58,6 +74,76 @@ func (h *handler) getClusters(req *restful.Request, res *restful.Response) {
 	common.Response(req, res, fluxClusters, err)
 }
 
+func (h *handler) handleRollbackApplication(req *restful.Request, res *restful.Response) {
+	namespace := common.GetPathParameter(req, common.NamespacePathParameter)
+	name := common.GetPathParameter(req, pathParameterApplication)
+	rollbackRequest := &ApplicationRollbackRequest{}
+	if err := req.ReadEntity(rollbackRequest); err != nil || rollbackRequest.ID <= 0 {
+		common.Response(req, res, nil, invalidRollbackRequestError)
+		return
+	}
+
+	currentUser, ok := serverrequest.UserFrom(req.Request.Context())
+	if !ok || currentUser == nil {
+		common.Response(req, res, nil, unauthenticatedError)
+		return
+	}
+
+	app, err := h.rollbackApplication(namespace, name, rollbackRequest, currentUser)
+	common.Response(req, res, app, err)
+}
+
+// rollbackApplication pins the chart version of an Application to a revision in its history. The pinned version
+// stops the automated upgrades until it's changed again.
+func (h *handler) rollbackApplication(namespace, name string, rollbackRequest *ApplicationRollbackRequest, currentUser user.Info) (*v1alpha1.Application, error) {
+	var app *v1alpha1.Application
+	return app, utilretry.RetryOnConflict(utilretry.DefaultRetry, func() error {
+		app = &v1alpha1.Application{}
+		if err := h.Get(context.Background(), types.NamespacedName{Namespace: namespace, Name: name}, app); err != nil {
+			return err
+		}
+		if !supportRollback(app) {
+			return rollbackNotSupportedError
+		}
+		history := app.Status.GetHistory(rollbackRequest.ID)
+		if history == nil {
+			return restful.NewError(http.StatusBadRequest, fmt.Sprintf("revision history %d not found", rollbackRequest.ID))
+		}
+		if !chartVersionPattern.MatchString(history.Revision) {
+			// the history might be recorded from a Kustomization by the old versions
+			return restful.NewError(http.StatusBadRequest, fmt.Sprintf("revision %s is not a chart version", history.Revision))
+		}
+
+		app.Spec.FluxApp.Spec.Config.HelmRelease.Chart.Version = getChartVersion(history.Revision)
+		if app.Annotations == nil {
+			app.Annotations = map[string]string{}
+		}
+		app.Annotations[v1alpha1.AnnoKeyRollbackRevision] = history.Revision
+		app.Annotations[v1alpha1.AnnoKeyRollbackInitiator] = currentUser.GetName()
+		return h.Update(context.Background(), app)
+	})
+}
+
+// supportRollback returns true if the chart version of an Application takes effect. The version is ignored by
+// FluxCD for the charts from a GitRepository or Bucket.
+func supportRollback(app *v1alpha1.Application) bool {
+	fluxApp := app.Spec.FluxApp
+	if fluxApp == nil || fluxApp.Spec.Source == nil || fluxApp.Spec.Source.SourceRef.Kind != "HelmRepository" {
+		return false
+	}
+	config := fluxApp.Spec.Config
+	return config != nil && config.HelmRelease != nil && config.HelmRelease.Chart != nil
+}
+
+// chartVersionPattern matches the SemVer 2 versions of the charts
+var chartVersionPattern = regexp.MustCompile(`^v?\d+\.\d+\.\d+(-[0-9A-Za-z.-]+)?(\+[0-9A-Za-z.-]+)?$`)
+
+// getChartVersion returns the chart version of a HelmRelease revision, the revision might have the build metadata
+// like "0.1.0+1"
+func getChartVersion(revision string) string {
+	return strings.SplitN(revision, "+", 2)[0]
+}
+
 type handler struct {
 	*gitops.Handler
 }
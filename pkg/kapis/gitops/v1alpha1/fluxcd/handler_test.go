@@ -23,11 +23,13 @@ import (
 
 	"github.com/emicklei/go-restful/v3"
 	"github.com/kubesphere/ks-devops/pkg/api/gitops/v1alpha1"
+	helmv2 "github.com/kubesphere/ks-devops/pkg/external/fluxcd/helm/v2beta1"
 	"github.com/kubesphere/ks-devops/pkg/kapis/gitops/v1alpha1/gitops"
 	"github.com/stretchr/testify/assert"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apiserver/pkg/authentication/user"
 	"k8s.io/client-go/kubernetes/scheme"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
@@ -115,3 +117,46 @@ func Test_handler_applicationGet(t *testing.T) {
 		})
 	}
 }
+
+func Test_handler_rollbackApplication(t *testing.T) {
+	createApp := func(name, sourceKind string) *v1alpha1.Application {
+		return &v1alpha1.Application{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "ns"},
+			Spec: v1alpha1.ApplicationSpec{
+				Kind: v1alpha1.FluxCD,
+				FluxApp: &v1alpha1.FluxApplication{Spec: v1alpha1.FluxApplicationSpec{
+					Source: &v1alpha1.FluxApplicationSource{SourceRef: helmv2.CrossNamespaceObjectReference{Kind: sourceKind, Name: "repo"}},
+					Config: &v1alpha1.FluxApplicationConfig{HelmRelease: &v1alpha1.HelmReleaseSpec{
+						Chart: &v1alpha1.HelmChartTemplateSpec{Chart: "demo", Version: "*"},
+					}},
+				}},
+			},
+			Status: v1alpha1.ApplicationStatus{History: []v1alpha1.RevisionHistory{
+				{ID: 1, Revision: "0.1.0"},
+				{ID: 2, Revision: "0.2.0+1"},
+				{ID: 3, Revision: "main/abc"},
+			}},
+		}
+	}
+
+	utilruntime.Must(v1alpha1.AddToScheme(scheme.Scheme))
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme.Scheme).
+		WithObjects(createApp("helm-app", "HelmRepository"), createApp("git-app", "GitRepository")).Build()
+	h := &handler{Handler: &gitops.Handler{Client: fakeClient}}
+	currentUser := &user.DefaultInfo{Name: "fake-user"}
+
+	_, err := h.rollbackApplication("ns", "git-app", &ApplicationRollbackRequest{ID: 1}, currentUser)
+	assert.Equal(t, rollbackNotSupportedError, err)
+
+	_, err = h.rollbackApplication("ns", "helm-app", &ApplicationRollbackRequest{ID: 4}, currentUser)
+	assert.Equal(t, restful.NewError(http.StatusBadRequest, "revision history 4 not found"), err)
+
+	_, err = h.rollbackApplication("ns", "helm-app", &ApplicationRollbackRequest{ID: 3}, currentUser)
+	assert.Equal(t, restful.NewError(http.StatusBadRequest, "revision main/abc is not a chart version"), err)
+
+	app, err := h.rollbackApplication("ns", "helm-app", &ApplicationRollbackRequest{ID: 2}, currentUser)
+	assert.Nil(t, err)
+	assert.Equal(t, "0.2.0", app.Spec.FluxApp.Spec.Config.HelmRelease.Chart.Version)
+	assert.Equal(t, "0.2.0+1", app.Annotations[v1alpha1.AnnoKeyRollbackRevision])
+	assert.Equal(t, "fake-user", app.Annotations[v1alpha1.AnnoKeyRollbackInitiator])
+}
@@ -26,6 +26,7 @@ import (
 	"github.com/kubesphere/ks-devops/pkg/config"
 	"github.com/kubesphere/ks-devops/pkg/constants"
 	"github.com/kubesphere/ks-devops/pkg/kapis/common"
+	"github.com/kubesphere/ks-devops/pkg/kapis/gitops/v1alpha1/gitops"
 )
 
 var (
@@ -36,6 +37,12 @@ var (
 	cascadeQueryParam        = restful.QueryParameter("cascade",
 		"Delete both the app and its resources, rather than only the application if cascade is true").
 		DefaultValue("false").DataType("boolean")
+	previewRevisionQueryParam = restful.QueryParameter("revision",
+		"The revision to render, it could be a branch, a tag or a commit. The target revision of the application by default")
+	previewDiffQueryParam = restful.QueryParameter("diff",
+		"Compare the rendered manifests with the live objects if it's true").DefaultValue("true").DataType("boolean")
+	// pathParameterPromotion is a path parameter definition for promotion.
+	pathParameterPromotion = restful.PathParameter("promotion", "The promotion name")
 )
 
 // ApplicationPageResult is the model of page result of Applications.
@@ -44,6 +51,11 @@ type ApplicationPageResult struct {
 	TotalItems int                    `json:"totalItems"`
 }
 
+// ApplicationRollbackRequest is a request to roll back an Application to a revision in its history.
+type ApplicationRollbackRequest struct {
+	ID int64 `json:"id" description:"The ID of the revision history"`
+}
+
 // RegisterRoutes is for registering Argo CD Application routes into WebService.
 func RegisterRoutes(service *restful.WebService, options *common.Options, fluxOption *config.FluxCDOption) {
 	handler := newHandler(options, fluxOption)
@@ -71,6 +83,42 @@ func RegisterRoutes(service *restful.WebService, options *common.Options, fluxOp
 		Metadata(restfulspec.KeyOpenAPITags, constants.GitOpsTags).
 		Returns(http.StatusOK, api.StatusOK, v1alpha1.Application{}))
 
+	service.Route(service.GET("/namespaces/{namespace}/applications/{application}/history").
+		To(handler.ApplicationHistory).
+		Param(common.NamespacePathParameter).
+		Param(pathParameterApplication).
+		Doc("Get the revisions which a particular application was deployed").
+		Metadata(restfulspec.KeyOpenAPITags, constants.GitOpsTags).
+		Returns(http.StatusOK, api.StatusOK, []v1alpha1.RevisionHistory{}))
+
+	service.Route(service.GET("/namespaces/{namespace}/applications/{application}/preview").
+		To(handler.ApplicationPreview).
+		Param(common.NamespacePathParameter).
+		Param(pathParameterApplication).
+		Param(previewRevisionQueryParam).
+		Param(previewDiffQueryParam).
+		Doc("Render the manifests of a particular application and compare them with the live objects").
+		Metadata(restfulspec.KeyOpenAPITags, constants.GitOpsTags).
+		Returns(http.StatusOK, api.StatusOK, gitops.Preview{}))
+
+	service.Route(service.POST("/namespaces/{namespace}/promotions/{promotion}/approval").
+		To(handler.PromotionApproval).
+		Param(common.NamespacePathParameter).
+		Param(pathParameterPromotion).
+		Reads(gitops.PromotionApprovalRequest{}).
+		Doc("Approve or reject the candidate which is waiting to be promoted to an environment").
+		Metadata(restfulspec.KeyOpenAPITags, constants.GitOpsTags).
+		Returns(http.StatusOK, api.StatusOK, v1alpha1.Promotion{}))
+
+	service.Route(service.POST("/namespaces/{namespace}/applications/{application}/rollback").
+		To(handler.handleRollbackApplication).
+		Param(common.NamespacePathParameter).
+		Param(pathParameterApplication).
+		Reads(ApplicationRollbackRequest{}).
+		Doc("Roll back a particular application to a revision in its history by pinning the chart version").
+		Metadata(restfulspec.KeyOpenAPITags, constants.GitOpsTags).
+		Returns(http.StatusOK, api.StatusOK, v1alpha1.Application{}))
+
 	service.Route(service.DELETE("/namespaces/{namespace}/applications/{application}").
 		To(handler.DelApplication).
 		Param(common.NamespacePathParameter).
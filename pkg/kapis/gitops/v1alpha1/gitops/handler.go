@@ -27,6 +27,7 @@ import (
 
 	"github.com/kubesphere/ks-devops/pkg/api/gitops/v1alpha1"
 	"github.com/kubesphere/ks-devops/pkg/kapis/common"
+	devopsgitops "github.com/kubesphere/ks-devops/pkg/kapis/devops/v1alpha3/gitops"
 	"github.com/kubesphere/ks-devops/pkg/utils/k8sutil"
 	"kubesphere.io/kubesphere/pkg/apiserver/query"
 	"kubesphere.io/kubesphere/pkg/models/resources/v1alpha3"
@@ -35,9 +36,11 @@ import (
 var (
 	// pathParameterApplication is a path parameter definition for application.
 	pathParameterApplication = restful.PathParameter("application", "The application name")
-	syncStatusQueryParam     = restful.QueryParameter("syncStatus", `Filter by sync status. Available values: "Unknown", "Synced" and "OutOfSync"`)
-	healthStatusQueryParam   = restful.QueryParameter("healthStatus", `Filter by health status. Available values: "Unknown", "Progressing", "Healthy", "Suspended", "Degraded" and "Missing"`)
-	cascadeQueryParam        = restful.QueryParameter("cascade",
+	// pathParameterPromotion is a path parameter definition for promotion.
+	pathParameterPromotion = restful.PathParameter("promotion", "The promotion name")
+	syncStatusQueryParam   = restful.QueryParameter("syncStatus", `Filter by sync status. Available values: "Unknown", "Synced" and "OutOfSync"`)
+	healthStatusQueryParam = restful.QueryParameter("healthStatus", `Filter by health status. Available values: "Unknown", "Progressing", "Healthy", "Suspended", "Degraded" and "Missing"`)
+	cascadeQueryParam      = restful.QueryParameter("cascade",
 		"Delete both the app and its resources, rather than only the application if cascade is true").
 		DefaultValue("false").DataType("boolean")
 )
@@ -84,6 +87,27 @@ func (h *Handler) GetApplication(req *restful.Request, res *restful.Response) {
 	common.Response(req, res, application, err)
 }
 
+// ApplicationHistory returns the revisions which an Application was deployed, the latest one is the first
+func (h *Handler) ApplicationHistory(req *restful.Request, res *restful.Response) {
+	namespace := common.GetPathParameter(req, common.NamespacePathParameter)
+	name := common.GetPathParameter(req, pathParameterApplication)
+
+	application := &v1alpha1.Application{}
+	if err := h.Get(context.Background(), types.NamespacedName{
+		Namespace: namespace,
+		Name:      name,
+	}, application); err != nil {
+		common.Response(req, res, nil, err)
+		return
+	}
+
+	history := make([]v1alpha1.RevisionHistory, 0, len(application.Status.History))
+	for i := len(application.Status.History) - 1; i >= 0; i-- {
+		history = append(history, application.Status.History[i])
+	}
+	common.Response(req, res, history, nil)
+}
+
 func (h *Handler) DelApplication(req *restful.Request, res *restful.Response) {
 	namespace := common.GetPathParameter(req, common.NamespacePathParameter)
 	name := common.GetPathParameter(req, pathParameterApplication)
@@ -141,6 +165,8 @@ func (h *Handler) UpdateApplication(req *restful.Request, res *restful.Response)
 
 type Handler struct {
 	client.Client
+	// RepoFactory provides the local clones of the git repositories, the one of the DevOps APIs is used by default
+	RepoFactory devopsgitops.GitRepoFactory
 }
 
 func NewHandler(options *common.Options) *Handler {
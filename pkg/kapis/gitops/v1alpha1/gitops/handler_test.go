@@ -558,3 +558,40 @@ func Test_handler_applicationUpdate(t *testing.T) {
 		})
 	}
 }
+
+func Test_handler_ApplicationHistory(t *testing.T) {
+	now := metav1.Now()
+	app := &v1alpha1.Application{
+		ObjectMeta: metav1.ObjectMeta{Name: "fake-app", Namespace: "fake-ns"},
+		Status: v1alpha1.ApplicationStatus{History: []v1alpha1.RevisionHistory{
+			{ID: 1, Revision: "a", DeployedAt: now},
+			{ID: 2, Revision: "b", DeployedAt: now},
+		}},
+	}
+	utilruntime.Must(v1alpha1.AddToScheme(scheme.Scheme))
+	h := &Handler{Client: fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(app).Build()}
+
+	request := func(name string) (*restful.Request, *restful.Response, *httptest.ResponseRecorder) {
+		req := restful.NewRequest(httptest.NewRequest(http.MethodGet, "/history", nil))
+		req.PathParameters()[common.NamespacePathParameter.Data().Name] = "fake-ns"
+		req.PathParameters()[pathParameterApplication.Data().Name] = name
+		recorder := httptest.NewRecorder()
+		resp := restful.NewResponse(recorder)
+		resp.SetRequestAccepts(restful.MIME_JSON)
+		return req, resp, recorder
+	}
+
+	req, resp, recorder := request("fake-app")
+	h.ApplicationHistory(req, resp)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	history := []v1alpha1.RevisionHistory{}
+	assert.Nil(t, json.Unmarshal(recorder.Body.Bytes(), &history))
+	if assert.Len(t, history, 2) {
+		assert.Equal(t, "b", history[0].Revision)
+		assert.Equal(t, "a", history[1].Revision)
+	}
+
+	req, resp, recorder = request("not-found")
+	h.ApplicationHistory(req, resp)
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+}
@@ -0,0 +1,425 @@
+// Copyright 2022 KubeSphere Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package gitops
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/emicklei/go-restful/v3"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apiserver/pkg/authentication/user"
+	serverrequest "k8s.io/apiserver/pkg/endpoints/request"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/kubesphere/ks-devops/pkg/api/devops/v1alpha3"
+	"github.com/kubesphere/ks-devops/pkg/api/gitops/v1alpha1"
+	"github.com/kubesphere/ks-devops/pkg/kapis/common"
+	devopsgitops "github.com/kubesphere/ks-devops/pkg/kapis/devops/v1alpha3/gitops"
+	"github.com/kubesphere/ks-devops/pkg/models/manifest"
+)
+
+var (
+	previewRevisionQueryParam = restful.QueryParameter("revision",
+		"The revision to render, it could be a branch, a tag or a commit. The target revision of the application by default")
+	previewDiffQueryParam = restful.QueryParameter("diff",
+		"Compare the rendered manifests with the live objects if it's true").DefaultValue("true").DataType("boolean")
+
+	unauthenticatedError        = restful.NewError(http.StatusUnauthorized, "cannot obtain user info")
+	repoServiceUnavailableError = restful.NewError(http.StatusServiceUnavailable, "the git repository service is not available")
+	appNotConfiguredError       = restful.NewError(http.StatusBadRequest, "neither the Argo CD nor the FluxCD application is configured")
+)
+
+// fluxRepoPrefix is the name prefix of the Flux GitRepository created for a GitRepository
+const fluxRepoPrefix = "fluxcd-"
+
+// inClusterServers are the destination servers of Argo CD which stand for the host cluster
+var inClusterServers = map[string]bool{
+	"":                               true,
+	"https://kubernetes.default.svc": true,
+}
+
+// Preview is the rendered manifests of an Application and their differences from the live objects
+type Preview struct {
+	// Revision is the commit which the manifests are rendered from
+	Revision  string                   `json:"revision,omitempty"`
+	Resources []*manifest.ResourceDiff `json:"resources"`
+}
+
+// previewTarget is the source of a part of an Application, and where it's deployed
+type previewTarget struct {
+	repo      types.NamespacedName
+	revision  string
+	source    *manifest.Source
+	inCluster bool
+}
+
+// ApplicationPreview renders the manifests of an Application from the local clone of its repository, then compares
+// them with the live objects. The live objects are only looked up in the destination namespace of the Application on
+// behalf of the requester, and the data of the Secrets is masked.
+func (h *Handler) ApplicationPreview(req *restful.Request, res *restful.Response) {
+	namespace := common.GetPathParameter(req, common.NamespacePathParameter)
+	name := common.GetPathParameter(req, pathParameterApplication)
+	revision := common.GetQueryParameter(req, previewRevisionQueryParam)
+	withDiff := common.GetQueryParameter(req, previewDiffQueryParam) != "false"
+
+	currentUser, ok := serverrequest.UserFrom(req.Request.Context())
+	if !ok || currentUser == nil {
+		common.Response(req, res, nil, unauthenticatedError)
+		return
+	}
+
+	preview, err := h.previewApplication(req.Request.Context(), namespace, name, revision, withDiff, currentUser)
+	common.Response(req, res, preview, err)
+}
+
+func (h *Handler) previewApplication(ctx context.Context, namespace, name, revision string, withDiff bool,
+	currentUser user.Info) (preview *Preview, err error) {
+	app := &v1alpha1.Application{}
+	if err = h.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, app); err != nil {
+		return
+	}
+	factory := h.RepoFactory
+	if factory == nil {
+		factory = devopsgitops.DefaultGitRepoFactory
+	}
+	if factory == nil {
+		err = repoServiceUnavailableError
+		return
+	}
+
+	var targets []previewTarget
+	var ignores []v1alpha1.ResourceIgnoreDifferences
+	switch {
+	case app.Spec.ArgoApp != nil:
+		targets, err = h.argoPreviewTargets(ctx, app, revision)
+		ignores = app.Spec.ArgoApp.Spec.IgnoreDifferences
+	case app.Spec.FluxApp != nil:
+		targets, err = fluxPreviewTargets(app, revision)
+	default:
+		err = appNotConfiguredError
+	}
+	if err != nil {
+		return
+	}
+
+	preview = &Preview{Resources: []*manifest.ResourceDiff{}}
+	trees := map[types.NamespacedName]*devopsgitops.GetTreeOutput{}
+	rendered := map[string]bool{}
+	for _, target := range targets {
+		tree, ok := trees[target.repo]
+		if !ok {
+			var service devopsgitops.GitRepoService
+			if service, err = factory.NewRepoService(ctx, currentUser, target.repo); err != nil {
+				return
+			}
+			if tree, err = service.GetTree(ctx, &devopsgitops.GetTreeInput{Revision: target.revision}); err != nil {
+				err = restful.NewError(http.StatusBadRequest, err.Error())
+				return
+			}
+			trees[target.repo] = tree
+		}
+		if tree.Commit != nil {
+			preview.Revision = tree.Commit.Hash
+		}
+
+		var objects []*unstructured.Unstructured
+		if objects, err = manifest.Render(tree.Files, target.source); err != nil {
+			err = restful.NewError(http.StatusBadRequest, err.Error())
+			return
+		}
+		for _, obj := range objects {
+			rendered[resourceKey(obj.GroupVersionKind().GroupKind(), obj.GetNamespace(), obj.GetName())] = true
+
+			var diff *manifest.ResourceDiff
+			switch {
+			case !withDiff:
+				diff = manifest.RenderedDiff(obj, "")
+			case !target.inCluster:
+				diff = manifest.UnknownDiff(obj)
+			default:
+				if diff, err = h.diffLiveObject(ctx, currentUser, target.source.Namespace, obj, nil, ignores); err != nil {
+					return
+				}
+			}
+			preview.Resources = append(preview.Resources, diff)
+		}
+	}
+
+	// only Argo CD reports the managed resources, so the removed ones could be found
+	if withDiff && app.Spec.ArgoApp != nil && len(targets) > 0 && targets[0].inCluster {
+		for _, resource := range app.Status.Resources {
+			gvk := schema.GroupVersionKind{Group: resource.Group, Version: resource.Version, Kind: resource.Kind}
+			if rendered[resourceKey(gvk.GroupKind(), resource.Namespace, resource.Name)] {
+				continue
+			}
+			live := &unstructured.Unstructured{}
+			live.SetGroupVersionKind(gvk)
+			live.SetNamespace(resource.Namespace)
+			live.SetName(resource.Name)
+
+			var diff *manifest.ResourceDiff
+			if diff, err = h.diffLiveObject(ctx, currentUser, targets[0].source.Namespace, nil, live, ignores); err != nil {
+				return
+			}
+			if diff != nil {
+				preview.Resources = append(preview.Resources, diff)
+			}
+		}
+	}
+	return
+}
+
+// diffLiveObject fetches the live object which is identified by the target, or the given live object, then compares
+// them. The status is unknown if the live object cannot be fetched, or the requester is not allowed to, see
+// getLiveObject.
+func (h *Handler) diffLiveObject(ctx context.Context, requester user.Info, namespace string,
+	target, live *unstructured.Unstructured, ignores []v1alpha1.ResourceIgnoreDifferences) (*manifest.ResourceDiff, error) {
+	key := live
+	if target != nil {
+		key = target
+	}
+	obj, err := h.getLiveObject(ctx, requester, namespace, key)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return manifest.Diff(target, nil, ignores)
+		}
+		klog.V(4).Infof("failed to get the live object %s %s: %v", key.GetKind(), client.ObjectKeyFromObject(key), err)
+		if target == nil {
+			return nil, nil
+		}
+		return manifest.UnknownDiff(target), nil
+	}
+
+	diff, err := manifest.Diff(target, obj, ignores)
+	if manifest.IsUnsupported(err) {
+		err = restful.NewError(http.StatusBadRequest, err.Error())
+	}
+	return diff, err
+}
+
+// getLiveObject gets a live object on behalf of the requester. The manifests come from the repository, so they could
+// name any object. Only the namespaced objects in the destination namespace of the Application are looked up, and the
+// requester must be allowed to get them, otherwise it's forbidden.
+func (h *Handler) getLiveObject(ctx context.Context, requester user.Info, namespace string, key *unstructured.Unstructured) (
+	obj *unstructured.Unstructured, err error) {
+	gvk := key.GroupVersionKind()
+	var mapping *meta.RESTMapping
+	if mapping, err = h.RESTMapper().RESTMapping(gvk.GroupKind(), gvk.Version); err != nil {
+		return
+	}
+	if namespace == "" || key.GetNamespace() != namespace || mapping.Scope.Name() != meta.RESTScopeNameNamespace {
+		err = apierrors.NewForbidden(mapping.Resource.GroupResource(), key.GetName(),
+			fmt.Errorf("only the objects in the destination namespace %q are compared", namespace))
+		return
+	}
+
+	var allowed bool
+	if allowed, err = common.Authorize(ctx, h.Client, requester, authorizationv1.ResourceAttributes{
+		Namespace: namespace,
+		Verb:      "get",
+		Group:     gvk.Group,
+		Version:   gvk.Version,
+		Resource:  mapping.Resource.Resource,
+		Name:      key.GetName(),
+	}); err != nil {
+		return
+	} else if !allowed {
+		err = apierrors.NewForbidden(mapping.Resource.GroupResource(), key.GetName(),
+			fmt.Errorf("user %q cannot get it", requester.GetName()))
+		return
+	}
+
+	obj = &unstructured.Unstructured{}
+	obj.SetGroupVersionKind(gvk)
+	err = h.Get(ctx, client.ObjectKeyFromObject(key), obj)
+	return
+}
+
+func resourceKey(gk schema.GroupKind, namespace, name string) string {
+	return fmt.Sprintf("%s/%s/%s", gk.String(), namespace, name)
+}
+
+// argoPreviewTargets finds the GitRepository by the repository URL of the Argo CD Application
+func (h *Handler) argoPreviewTargets(ctx context.Context, app *v1alpha1.Application, revision string) (
+	targets []previewTarget, err error) {
+	spec := app.Spec.ArgoApp.Spec
+	source := spec.Source
+	switch {
+	case source.Chart != "":
+		err = restful.NewError(http.StatusBadRequest, "unsupported: the chart of a Helm repository")
+		return
+	case source.Plugin != nil || source.Ksonnet != nil:
+		err = restful.NewError(http.StatusBadRequest, "unsupported: the config management plugin or Ksonnet")
+		return
+	}
+
+	repos := &v1alpha3.GitRepositoryList{}
+	if err = h.List(ctx, repos, client.InNamespace(app.Namespace)); err != nil {
+		return
+	}
+	var repo *v1alpha3.GitRepository
+	for i := range repos.Items {
+		if normalizeRepoURL(repos.Items[i].Spec.URL) == normalizeRepoURL(source.RepoURL) {
+			repo = &repos.Items[i]
+			break
+		}
+	}
+	if repo == nil {
+		err = restful.NewError(http.StatusBadRequest,
+			fmt.Sprintf("no GitRepository of %s found in namespace %s", source.RepoURL, app.Namespace))
+		return
+	}
+
+	if revision == "" {
+		revision = source.TargetRevision
+	}
+	releaseName := app.Name
+	if source.Helm != nil && source.Helm.ReleaseName != "" {
+		releaseName = source.Helm.ReleaseName
+	}
+	targets = []previewTarget{{
+		repo:     types.NamespacedName{Namespace: repo.Namespace, Name: repo.Name},
+		revision: revision,
+		source: &manifest.Source{
+			Path:        source.Path,
+			Directory:   source.Directory,
+			Kustomize:   source.Kustomize,
+			Helm:        source.Helm,
+			Namespace:   spec.Destination.Namespace,
+			ReleaseName: releaseName,
+		},
+		inCluster: inClusterServers[spec.Destination.Server] &&
+			(spec.Destination.Name == "" || spec.Destination.Name == "in-cluster"),
+	}}
+	return
+}
+
+func normalizeRepoURL(url string) string {
+	return strings.TrimSuffix(strings.TrimSuffix(strings.ToLower(strings.TrimSpace(url)), "/"), ".git")
+}
+
+// fluxPreviewTargets returns the sources of each HelmRelease and Kustomization of the FluxCD Application
+func fluxPreviewTargets(app *v1alpha1.Application, revision string) (targets []previewTarget, err error) {
+	spec := app.Spec.FluxApp.Spec
+	if spec.Source == nil || spec.Config == nil {
+		err = restful.NewError(http.StatusBadRequest, "the source or config of the FluxCD application is missing")
+		return
+	}
+	sourceRef := spec.Source.SourceRef
+	if sourceRef.Kind != "GitRepository" {
+		err = restful.NewError(http.StatusBadRequest, fmt.Sprintf("unsupported: the source of kind %s", sourceRef.Kind))
+		return
+	}
+	repo := types.NamespacedName{Namespace: sourceRef.Namespace, Name: strings.TrimPrefix(sourceRef.Name, fluxRepoPrefix)}
+	if repo.Namespace == "" {
+		repo.Namespace = app.Namespace
+	}
+
+	if helmRelease := spec.Config.HelmRelease; helmRelease != nil && helmRelease.Chart != nil {
+		for _, deploy := range helmRelease.Deploy {
+			if len(deploy.ValuesFrom) > 0 || len(deploy.PostRenderers) > 0 {
+				err = restful.NewError(http.StatusBadRequest, "unsupported: the valuesFrom or postRenderers of a HelmRelease")
+				return
+			}
+			helm := &v1alpha1.ApplicationSourceHelm{
+				ValueFiles:  helmRelease.Chart.ValuesFiles,
+				ReleaseName: deploy.ReleaseName,
+			}
+			if deploy.Values != nil {
+				// JSON is valid YAML
+				helm.Values = string(deploy.Values.Raw)
+			}
+			if helm.ReleaseName == "" {
+				helm.ReleaseName = fluxReleaseName(deploy.Destination)
+			}
+			targets = append(targets, previewTarget{
+				repo:     repo,
+				revision: revision,
+				source: &manifest.Source{
+					Path:      helmRelease.Chart.Chart,
+					Helm:      helm,
+					Namespace: deploy.Destination.TargetNamespace,
+				},
+				inCluster: deploy.Destination.KubeConfig == nil,
+			})
+		}
+	}
+
+	for _, kus := range spec.Config.Kustomization {
+		if len(kus.Patches) > 0 || kus.PostBuild != nil && len(kus.PostBuild.SubstituteFrom) > 0 {
+			err = restful.NewError(http.StatusBadRequest, "unsupported: the patches or substituteFrom of a Kustomization")
+			return
+		}
+		source := &manifest.Source{
+			Path:      kus.Path,
+			Namespace: kus.Destination.TargetNamespace,
+			// Flux generates a Kustomization of all the manifests if there isn't one
+			Directory: &v1alpha1.ApplicationSourceDirectory{Recurse: true},
+		}
+		if source.Path == "" {
+			source.Path = "."
+		}
+		if len(kus.Images) > 0 {
+			source.Kustomize = &v1alpha1.ApplicationSourceKustomize{}
+			for _, image := range kus.Images {
+				source.Kustomize.Images = append(source.Kustomize.Images, toKustomizeImage(image.Name, image.NewName, image.NewTag, image.Digest))
+			}
+		}
+		if kus.PostBuild != nil {
+			source.Substitute = kus.PostBuild.Substitute
+		}
+		targets = append(targets, previewTarget{
+			repo:      repo,
+			revision:  revision,
+			source:    source,
+			inCluster: kus.Destination.KubeConfig == nil,
+		})
+	}
+	return
+}
+
+// fluxReleaseName is the default release name of a HelmRelease, see also the HelmRelease name in the controller
+func fluxReleaseName(destination v1alpha1.FluxApplicationDestination) string {
+	name := destination.TargetNamespace
+	if destination.KubeConfig != nil {
+		name = destination.KubeConfig.SecretRef.Name + "-" + name
+	}
+	return destination.TargetNamespace + "-" + name
+}
+
+// toKustomizeImage converts an image override into the format of Argo CD, like old=new:tag or old=new@digest
+func toKustomizeImage(name, newName, newTag, digest string) v1alpha1.KustomizeImage {
+	image := newName
+	if image == "" {
+		image = name
+	}
+	switch {
+	case digest != "":
+		image += "@" + digest
+	case newTag != "":
+		image += ":" + newTag
+	}
+	return v1alpha1.KustomizeImage(name + "=" + image)
+}
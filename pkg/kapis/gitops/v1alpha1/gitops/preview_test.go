@@ -0,0 +1,399 @@
+// Copyright 2022 KubeSphere Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package gitops
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/emicklei/go-restful/v3"
+
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/api/meta/testrestmapper"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apiserver/pkg/authentication/user"
+	serverrequest "k8s.io/apiserver/pkg/endpoints/request"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	"github.com/kubesphere/ks-devops/pkg/api/devops/v1alpha3"
+	"github.com/kubesphere/ks-devops/pkg/api/gitops/v1alpha1"
+	helmv2 "github.com/kubesphere/ks-devops/pkg/external/fluxcd/helm/v2beta1"
+	kusv1 "github.com/kubesphere/ks-devops/pkg/external/fluxcd/kustomize/v1beta2"
+	"github.com/kubesphere/ks-devops/pkg/kapis/common"
+	devopsgitops "github.com/kubesphere/ks-devops/pkg/kapis/devops/v1alpha3/gitops"
+	"github.com/kubesphere/ks-devops/pkg/models/manifest"
+)
+
+type fakeRepoFactory struct {
+	devopsgitops.GitRepoFactory
+	service *fakeRepoService
+	repos   []types.NamespacedName
+}
+
+func (f *fakeRepoFactory) NewRepoService(ctx context.Context, user user.Info, repo types.NamespacedName) (devopsgitops.GitRepoService, error) {
+	f.repos = append(f.repos, repo)
+	return f.service, nil
+}
+
+type fakeRepoService struct {
+	devopsgitops.GitRepoService
+	files     map[string][]byte
+	revisions []string
+}
+
+func (s *fakeRepoService) GetTree(ctx context.Context, input *devopsgitops.GetTreeInput) (*devopsgitops.GetTreeOutput, error) {
+	s.revisions = append(s.revisions, input.Revision)
+	return &devopsgitops.GetTreeOutput{
+		Commit: &devopsgitops.Commit{Hash: "abc"},
+		Files:  s.files,
+	}, nil
+}
+
+const previewManifests = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  replicas: 2
+  selector:
+    matchLabels:
+      app: web
+  template:
+    metadata:
+      labels:
+        app: web
+    spec:
+      containers:
+      - name: web
+        image: nginx:1.21
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+`
+
+func Test_handler_ApplicationPreview(t *testing.T) {
+	utilruntime.Must(v1alpha1.AddToScheme(scheme.Scheme))
+	utilruntime.Must(v1alpha3.AddToScheme(scheme.Scheme))
+
+	argoApp := &v1alpha1.Application{
+		ObjectMeta: metav1.ObjectMeta{Name: "argo", Namespace: "ns"},
+		Spec: v1alpha1.ApplicationSpec{ArgoApp: &v1alpha1.ArgoApplication{Spec: v1alpha1.ArgoApplicationSpec{
+			Source: v1alpha1.ApplicationSource{
+				RepoURL:        "https://github.com/org/repo.git",
+				Path:           "apps/web",
+				TargetRevision: "main",
+			},
+			Destination: v1alpha1.ApplicationDestination{
+				Server:    "https://kubernetes.default.svc",
+				Namespace: "default",
+			},
+		}}},
+		Status: v1alpha1.ApplicationStatus{Resources: []v1alpha1.ResourceStatus{
+			{Group: "apps", Version: "v1", Kind: "Deployment", Namespace: "default", Name: "web"},
+			{Version: "v1", Kind: "Service", Namespace: "default", Name: "old"},
+			{Version: "v1", Kind: "Secret", Namespace: "default", Name: "deleted"},
+		}},
+	}
+	fluxApp := &v1alpha1.Application{
+		ObjectMeta: metav1.ObjectMeta{Name: "flux", Namespace: "ns"},
+		Spec: v1alpha1.ApplicationSpec{FluxApp: &v1alpha1.FluxApplication{Spec: v1alpha1.FluxApplicationSpec{
+			Source: &v1alpha1.FluxApplicationSource{SourceRef: helmv2.CrossNamespaceObjectReference{
+				Kind: "GitRepository",
+				Name: "fluxcd-repo",
+			}},
+			Config: &v1alpha1.FluxApplicationConfig{Kustomization: []*v1alpha1.KustomizationSpec{{
+				Path: "apps/web",
+				Destination: v1alpha1.FluxApplicationDestination{
+					TargetNamespace: "default",
+					KubeConfig:      &helmv2.KubeConfig{},
+				},
+			}}},
+		}}},
+	}
+	noRepoApp := argoApp.DeepCopy()
+	noRepoApp.Name = "no-repo"
+	noRepoApp.Spec.ArgoApp.Spec.Source.RepoURL = "https://github.com/org/other"
+	repo := &v1alpha3.GitRepository{
+		ObjectMeta: metav1.ObjectMeta{Name: "repo", Namespace: "ns"},
+		Spec:       v1alpha3.GitRepositorySpec{URL: "https://github.com/org/repo"},
+	}
+	liveDeploy := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default", ResourceVersion: "1"},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: ptr.To[int32](1),
+			Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"app": "web"}},
+				Spec: corev1.PodSpec{Containers: []corev1.Container{{
+					Name:  "web",
+					Image: "nginx:1.20",
+				}}},
+			},
+		},
+	}
+	liveService := &corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "old", Namespace: "default"}}
+
+	newHandler := func(objects ...runtime.Object) (*Handler, *fakeRepoFactory) {
+		factory := &fakeRepoFactory{service: &fakeRepoService{files: map[string][]byte{
+			"apps/web/manifests.yaml": []byte(previewManifests),
+		}}}
+		return &Handler{
+			Client: fake.NewClientBuilder().WithScheme(scheme.Scheme).WithRuntimeObjects(objects...).
+				WithRESTMapper(testrestmapper.TestOnlyStaticRESTMapper(scheme.Scheme)).
+				WithInterceptorFuncs(interceptor.Funcs{
+					Create: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.CreateOption) error {
+						if review, ok := obj.(*authorizationv1.SubjectAccessReview); ok {
+							review.Status.Allowed = review.Spec.User != "guest"
+							return nil
+						}
+						return c.Create(ctx, obj, opts...)
+					},
+				}).Build(),
+			RepoFactory: factory,
+		}, factory
+	}
+	request := func(name, query string, withUser bool) (*restful.Request, *restful.Response, *httptest.ResponseRecorder) {
+		httpReq := httptest.NewRequest(http.MethodGet, "/preview"+query, nil)
+		if withUser {
+			httpReq = httpReq.WithContext(serverrequest.WithUser(httpReq.Context(), &user.DefaultInfo{Name: "admin"}))
+		}
+		req := restful.NewRequest(httpReq)
+		req.PathParameters()[common.NamespacePathParameter.Data().Name] = "ns"
+		req.PathParameters()[pathParameterApplication.Data().Name] = name
+		recorder := httptest.NewRecorder()
+		resp := restful.NewResponse(recorder)
+		resp.SetRequestAccepts(restful.MIME_JSON)
+		return req, resp, recorder
+	}
+	statuses := func(preview *Preview) (result []manifest.DiffStatus) {
+		for _, resource := range preview.Resources {
+			result = append(result, resource.Status)
+		}
+		return
+	}
+
+	t.Run("argo application", func(t *testing.T) {
+		h, factory := newHandler(argoApp, repo, liveDeploy, liveService)
+		req, resp, recorder := request("argo", "", true)
+		h.ApplicationPreview(req, resp)
+		assert.Equal(t, http.StatusOK, recorder.Code)
+
+		preview := &Preview{}
+		assert.Nil(t, json.Unmarshal(recorder.Body.Bytes(), preview))
+		assert.Equal(t, "abc", preview.Revision)
+		assert.Equal(t, []manifest.DiffStatus{manifest.DiffStatusModified, manifest.DiffStatusAdded,
+			manifest.DiffStatusRemoved}, statuses(preview))
+		assert.Equal(t, []manifest.FieldChange{
+			{Path: "/spec/replicas", Live: float64(1), Target: float64(2)},
+			{Path: "/spec/template/spec/containers/0/image", Live: "nginx:1.20", Target: "nginx:1.21"},
+		}, preview.Resources[0].Changes)
+		assert.Equal(t, "default", preview.Resources[1].Namespace)
+		assert.Equal(t, "old", preview.Resources[2].Name)
+		assert.Equal(t, []types.NamespacedName{{Namespace: "ns", Name: "repo"}}, factory.repos)
+		assert.Equal(t, []string{"main"}, factory.service.revisions)
+	})
+
+	t.Run("argo application with the ignored differences and another revision", func(t *testing.T) {
+		app := argoApp.DeepCopy()
+		app.Spec.ArgoApp.Spec.IgnoreDifferences = []v1alpha1.ResourceIgnoreDifferences{{
+			Group:             "apps",
+			Kind:              "Deployment",
+			JSONPointers:      []string{"/spec/replicas"},
+			JQPathExpressions: []string{".spec.template.spec.containers[].image"},
+		}}
+		h, factory := newHandler(app, repo, liveDeploy, liveService)
+		preview, err := h.previewApplication(context.TODO(), "ns", "argo", "v1.0.0", true, &user.DefaultInfo{})
+		assert.NoError(t, err)
+		assert.Equal(t, []manifest.DiffStatus{manifest.DiffStatusUnchanged, manifest.DiffStatusAdded,
+			manifest.DiffStatusRemoved}, statuses(preview))
+		assert.Equal(t, []string{"v1.0.0"}, factory.service.revisions)
+	})
+
+	t.Run("not allowed to get the live objects", func(t *testing.T) {
+		h, _ := newHandler(argoApp, repo, liveDeploy, liveService)
+		preview, err := h.previewApplication(context.TODO(), "ns", "argo", "", true, &user.DefaultInfo{Name: "guest"})
+		assert.NoError(t, err)
+		assert.Equal(t, []manifest.DiffStatus{manifest.DiffStatusUnknown, manifest.DiffStatusUnknown}, statuses(preview))
+		assert.Nil(t, preview.Resources[0].Live)
+	})
+
+	t.Run("secrets", func(t *testing.T) {
+		h, factory := newHandler(argoApp, repo,
+			&corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "token", Namespace: "kube-system"},
+				Data:       map[string][]byte{"token": []byte("secret")},
+			},
+			&corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "creds", Namespace: "default"},
+				Data:       map[string][]byte{"password": []byte("old"), "username": []byte("admin")},
+			})
+		factory.service.files = map[string][]byte{"apps/web/secrets.yaml": []byte(`apiVersion: v1
+kind: Secret
+metadata:
+  name: token
+  namespace: kube-system
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: creds
+stringData:
+  password: new
+  username: admin
+`)}
+		preview, err := h.previewApplication(context.TODO(), "ns", "argo", "", true, &user.DefaultInfo{Name: "admin"})
+		assert.NoError(t, err)
+		resources := map[string]*manifest.ResourceDiff{}
+		for _, resource := range preview.Resources {
+			resources[resource.Name] = resource
+		}
+		if assert.Contains(t, resources, "token") {
+			assert.Equal(t, manifest.DiffStatusUnknown, resources["token"].Status)
+			assert.Nil(t, resources["token"].Live)
+		}
+		if assert.Contains(t, resources, "creds") {
+			creds := resources["creds"]
+			assert.Equal(t, manifest.DiffStatusModified, creds.Status)
+			assert.Equal(t, []manifest.FieldChange{
+				{Path: "/data/password", Live: "+++++++++", Target: "++++++++"},
+			}, creds.Changes)
+			assert.Equal(t, map[string]interface{}{"password": "+++++++++", "username": "++++++++"}, creds.Live["data"])
+			assert.NotContains(t, creds.Target, "stringData")
+		}
+	})
+
+	t.Run("without diff", func(t *testing.T) {
+		h, _ := newHandler(argoApp, repo, liveDeploy, liveService)
+		preview, err := h.previewApplication(context.TODO(), "ns", "argo", "", false, &user.DefaultInfo{})
+		assert.NoError(t, err)
+		if assert.Len(t, preview.Resources, 2) {
+			assert.Empty(t, preview.Resources[0].Status)
+			assert.NotNil(t, preview.Resources[0].Target)
+			assert.Nil(t, preview.Resources[0].Live)
+		}
+	})
+
+	t.Run("flux application in another cluster", func(t *testing.T) {
+		h, factory := newHandler(fluxApp, repo)
+		preview, err := h.previewApplication(context.TODO(), "ns", "flux", "", true, &user.DefaultInfo{})
+		assert.NoError(t, err)
+		assert.Equal(t, []manifest.DiffStatus{manifest.DiffStatusUnknown, manifest.DiffStatusUnknown}, statuses(preview))
+		assert.Equal(t, []types.NamespacedName{{Namespace: "ns", Name: "repo"}}, factory.repos)
+	})
+
+	t.Run("no git repository", func(t *testing.T) {
+		h, _ := newHandler(noRepoApp, repo)
+		_, err := h.previewApplication(context.TODO(), "ns", "no-repo", "", true, &user.DefaultInfo{})
+		assert.Equal(t, restful.NewError(http.StatusBadRequest,
+			"no GitRepository of https://github.com/org/other found in namespace ns"), err)
+	})
+
+	t.Run("no git repository service", func(t *testing.T) {
+		h, _ := newHandler(argoApp, repo)
+		h.RepoFactory = nil
+		devopsgitops.DefaultGitRepoFactory = nil
+		_, err := h.previewApplication(context.TODO(), "ns", "argo", "", true, &user.DefaultInfo{})
+		assert.Equal(t, repoServiceUnavailableError, err)
+	})
+
+	t.Run("unauthenticated or not found", func(t *testing.T) {
+		h, _ := newHandler(argoApp, repo)
+		req, resp, recorder := request("argo", "", false)
+		h.ApplicationPreview(req, resp)
+		assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+
+		req, resp, recorder = request("not-found", "?diff=false", true)
+		h.ApplicationPreview(req, resp)
+		assert.Equal(t, http.StatusNotFound, recorder.Code)
+	})
+}
+
+func Test_fluxPreviewTargets(t *testing.T) {
+	app := &v1alpha1.Application{
+		ObjectMeta: metav1.ObjectMeta{Name: "flux", Namespace: "ns"},
+		Spec: v1alpha1.ApplicationSpec{FluxApp: &v1alpha1.FluxApplication{Spec: v1alpha1.FluxApplicationSpec{
+			Source: &v1alpha1.FluxApplicationSource{SourceRef: helmv2.CrossNamespaceObjectReference{
+				Kind:      "GitRepository",
+				Name:      "fluxcd-repo",
+				Namespace: "devops",
+			}},
+			Config: &v1alpha1.FluxApplicationConfig{
+				HelmRelease: &v1alpha1.HelmReleaseSpec{
+					Chart: &v1alpha1.HelmChartTemplateSpec{Chart: "charts/web", ValuesFiles: []string{"values-prod.yaml"}},
+					Deploy: []*v1alpha1.Deploy{{
+						Destination: v1alpha1.FluxApplicationDestination{TargetNamespace: "prod"},
+						Values:      &apiextensionsv1.JSON{Raw: []byte(`{"replicas":3}`)},
+					}},
+				},
+				Kustomization: []*v1alpha1.KustomizationSpec{{
+					Destination: v1alpha1.FluxApplicationDestination{TargetNamespace: "dev"},
+					Images:      []kusv1.Image{{Name: "nginx", NewTag: "1.21"}},
+				}},
+			},
+		}}},
+	}
+
+	targets, err := fluxPreviewTargets(app, "main")
+	assert.NoError(t, err)
+	if assert.Len(t, targets, 2) {
+		repo := types.NamespacedName{Namespace: "devops", Name: "repo"}
+		assert.Equal(t, previewTarget{
+			repo:     repo,
+			revision: "main",
+			source: &manifest.Source{
+				Path: "charts/web",
+				Helm: &v1alpha1.ApplicationSourceHelm{
+					ValueFiles:  []string{"values-prod.yaml"},
+					Values:      `{"replicas":3}`,
+					ReleaseName: "prod-prod",
+				},
+				Namespace: "prod",
+			},
+			inCluster: true,
+		}, targets[0])
+		assert.Equal(t, previewTarget{
+			repo:     repo,
+			revision: "main",
+			source: &manifest.Source{
+				Path:      ".",
+				Directory: &v1alpha1.ApplicationSourceDirectory{Recurse: true},
+				Kustomize: &v1alpha1.ApplicationSourceKustomize{Images: v1alpha1.KustomizeImages{"nginx=nginx:1.21"}},
+				Namespace: "dev",
+			},
+			inCluster: true,
+		}, targets[1])
+	}
+
+	app.Spec.FluxApp.Spec.Source.SourceRef.Kind = "HelmRepository"
+	_, err = fluxPreviewTargets(app, "")
+	assert.Equal(t, restful.NewError(http.StatusBadRequest, "unsupported: the source of kind HelmRepository"), err)
+}
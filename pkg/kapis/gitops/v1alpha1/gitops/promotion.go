@@ -0,0 +1,148 @@
+// Copyright 2022 KubeSphere Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package gitops
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/emicklei/go-restful/v3"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apiserver/pkg/authentication/user"
+	serverrequest "k8s.io/apiserver/pkg/endpoints/request"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/kubesphere/ks-devops/pkg/api/devops/v1alpha3"
+	"github.com/kubesphere/ks-devops/pkg/api/gitops/v1alpha1"
+	"github.com/kubesphere/ks-devops/pkg/kapis/common"
+	"github.com/kubesphere/ks-devops/pkg/models/pipelinerun"
+)
+
+// PromotionApprovalRequest is the request body of making a decision on the candidate of an environment
+type PromotionApprovalRequest struct {
+	Environment string                        `json:"environment" description:"The environment which the candidate is promoted to"`
+	Candidate   *v1alpha1.PromotionContent    `json:"candidate" description:"The candidate which the decision is made on, it must be the one waiting for the approval"`
+	Decision    v1alpha3.ApprovalDecisionType `json:"decision" description:"The type of the decision, could be Approve or Reject"`
+	Comment     string                        `json:"comment,omitempty" description:"The comment of the decision"`
+}
+
+// PromotionApproval approves or rejects the candidate of an environment on behalf of the current user. The
+// decision is recorded in the status of the Promotion, then the controller carries out the promotion once the
+// candidate gets enough approvals.
+func (h *Handler) PromotionApproval(req *restful.Request, res *restful.Response) {
+	namespace := common.GetPathParameter(req, common.NamespacePathParameter)
+	name := common.GetPathParameter(req, pathParameterPromotion)
+
+	payload := &PromotionApprovalRequest{}
+	if err := req.ReadEntity(payload); err != nil {
+		common.Response(req, res, nil, restful.NewError(http.StatusBadRequest, err.Error()))
+		return
+	}
+	if payload.Decision != v1alpha3.ApprovalDecisionApprove && payload.Decision != v1alpha3.ApprovalDecisionReject {
+		common.Response(req, res, nil, restful.NewError(http.StatusBadRequest, fmt.Sprintf("invalid decision: %q", payload.Decision)))
+		return
+	}
+	if payload.Candidate.IsEmpty() {
+		common.Response(req, res, nil, restful.NewError(http.StatusBadRequest, "the candidate is required"))
+		return
+	}
+
+	currentUser, ok := serverrequest.UserFrom(req.Request.Context())
+	if !ok || currentUser == nil || currentUser.GetName() == "" {
+		common.Response(req, res, nil, unauthenticatedError)
+		return
+	}
+
+	promotion, err := h.approvePromotion(req.Request.Context(), namespace, name, payload, currentUser)
+	common.Response(req, res, promotion, err)
+}
+
+func (h *Handler) approvePromotion(ctx context.Context, namespace, name string, payload *PromotionApprovalRequest,
+	currentUser user.Info) (*v1alpha1.Promotion, error) {
+	promotion := &v1alpha1.Promotion{}
+	if err := h.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, promotion); err != nil {
+		return nil, err
+	}
+
+	var gate *v1alpha1.PromotionGate
+	for i, env := range promotion.Spec.Environments {
+		if i == 0 || env.Name != payload.Environment {
+			continue
+		}
+		for j := range env.Gates {
+			if env.Gates[j].Type == v1alpha1.PromotionGateApproval {
+				gate = &env.Gates[j]
+				break
+			}
+		}
+	}
+	if gate == nil {
+		return nil, restful.NewError(http.StatusBadRequest, fmt.Sprintf("environment %q has no approval gate", payload.Environment))
+	}
+	envStatus := promotion.Status.GetEnvironment(payload.Environment)
+	if envStatus == nil || envStatus.Phase != v1alpha1.PromotionPhaseWaiting || envStatus.Candidate == nil {
+		return nil, restful.NewError(http.StatusConflict, fmt.Sprintf("no candidate of environment %s is waiting for the approval", payload.Environment))
+	}
+	if !envStatus.Candidate.Equal(payload.Candidate) {
+		return nil, restful.NewError(http.StatusConflict, fmt.Sprintf("the candidate of environment %s has changed", payload.Environment))
+	}
+	if envStatus.HasDecided(currentUser.GetName()) {
+		return nil, restful.NewError(http.StatusConflict, fmt.Sprintf("user %s has already made a decision on environment %s", currentUser.GetName(), payload.Environment))
+	}
+
+	var roleBindings []rbacv1.RoleBinding
+	if gate.Approvers.HasRoles() {
+		roleBindingList := &rbacv1.RoleBindingList{}
+		if err := h.List(ctx, roleBindingList, client.InNamespace(namespace)); err != nil {
+			return nil, err
+		}
+		roleBindings = roleBindingList.Items
+	}
+	allowed := pipelinerun.IsApprover(gate.Approvers, currentUser, roleBindings)
+	if allowed && gate.Approvers.Anyone {
+		// the status is written with the privileged client, so anyone must be able to update the Promotion by itself
+		var err error
+		if allowed, err = common.Authorize(ctx, h.Client, currentUser, authorizationv1.ResourceAttributes{
+			Namespace: namespace,
+			Verb:      "update",
+			Group:     v1alpha1.GroupVersion.Group,
+			Version:   v1alpha1.GroupVersion.Version,
+			Resource:  "promotions",
+			Name:      name,
+		}); err != nil {
+			return nil, err
+		}
+	}
+	if !allowed {
+		return nil, restful.NewError(http.StatusForbidden, fmt.Sprintf("user %s is not allowed to approve environment %s", currentUser.GetName(), payload.Environment))
+	}
+
+	envStatus.Decisions = append(envStatus.Decisions, v1alpha3.ApprovalDecision{
+		User:     currentUser.GetName(),
+		Decision: payload.Decision,
+		Time:     metav1.Now(),
+		Comment:  payload.Comment,
+	})
+	// the resource version makes sure that the candidate is not changed by the controller since it's compared
+	if err := h.Status().Update(ctx, promotion); err != nil {
+		return nil, err
+	}
+	return promotion, nil
+}
@@ -0,0 +1,212 @@
+// Copyright 2022 KubeSphere Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package gitops
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/emicklei/go-restful/v3"
+	"github.com/stretchr/testify/assert"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apiserver/pkg/authentication/user"
+	serverrequest "k8s.io/apiserver/pkg/endpoints/request"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	"github.com/kubesphere/ks-devops/pkg/api/devops/v1alpha3"
+	"github.com/kubesphere/ks-devops/pkg/api/gitops/v1alpha1"
+	"github.com/kubesphere/ks-devops/pkg/kapis/common"
+)
+
+func Test_handler_PromotionApproval(t *testing.T) {
+	utilruntime.Must(v1alpha1.AddToScheme(scheme.Scheme))
+
+	newPromotion := func(approvers v1alpha3.Approvers, phase v1alpha1.PromotionPhase) *v1alpha1.Promotion {
+		return &v1alpha1.Promotion{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "web"},
+			Spec: v1alpha1.PromotionSpec{Environments: []v1alpha1.PromotionEnvironment{
+				{Name: "dev", Gates: []v1alpha1.PromotionGate{{Type: v1alpha1.PromotionGateApproval}}},
+				{Name: "staging", Gates: []v1alpha1.PromotionGate{{Type: v1alpha1.PromotionGateHealth}}},
+				{Name: "prod", Gates: []v1alpha1.PromotionGate{{Type: v1alpha1.PromotionGateApproval, Approvers: approvers}}},
+			}},
+			Status: v1alpha1.PromotionStatus{Environments: []v1alpha1.PromotionEnvironmentStatus{{
+				Name:      "prod",
+				Phase:     phase,
+				Candidate: &v1alpha1.PromotionContent{Revision: "abc"},
+				Decisions: []v1alpha3.ApprovalDecision{{User: "bob", Decision: v1alpha3.ApprovalDecisionApprove}},
+			}}},
+		}
+	}
+	roleBinding := &rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "operators"},
+		RoleRef:    rbacv1.RoleRef{Kind: "Role", Name: "operator"},
+		Subjects:   []rbacv1.Subject{{Kind: rbacv1.UserKind, Name: "admin"}},
+	}
+	newHandler := func(objects ...runtime.Object) *Handler {
+		return &Handler{Client: fake.NewClientBuilder().WithScheme(scheme.Scheme).WithRuntimeObjects(objects...).
+			WithStatusSubresource(&v1alpha1.Promotion{}).
+			WithInterceptorFuncs(interceptor.Funcs{
+				Create: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.CreateOption) error {
+					if review, ok := obj.(*authorizationv1.SubjectAccessReview); ok {
+						review.Status.Allowed = review.Spec.User == "admin" && review.Spec.ResourceAttributes.Verb == "update" &&
+							review.Spec.ResourceAttributes.Resource == "promotions" && review.Spec.ResourceAttributes.Name == "web"
+						return nil
+					}
+					return c.Create(ctx, obj, opts...)
+				},
+			}).Build()}
+	}
+	request := func(body, username string) (*restful.Request, *restful.Response, *httptest.ResponseRecorder) {
+		httpReq := httptest.NewRequest(http.MethodPost, "/approval", strings.NewReader(body))
+		httpReq.Header.Set("Content-Type", restful.MIME_JSON)
+		if username != "" {
+			httpReq = httpReq.WithContext(serverrequest.WithUser(httpReq.Context(), &user.DefaultInfo{Name: username}))
+		}
+		req := restful.NewRequest(httpReq)
+		req.PathParameters()[common.NamespacePathParameter.Data().Name] = "ns"
+		req.PathParameters()[pathParameterPromotion.Data().Name] = "web"
+		recorder := httptest.NewRecorder()
+		resp := restful.NewResponse(recorder)
+		resp.SetRequestAccepts(restful.MIME_JSON)
+		return req, resp, recorder
+	}
+
+	tests := []struct {
+		name     string
+		objects  []runtime.Object
+		body     string
+		username string
+		wantCode int
+	}{{
+		name:     "invalid payload",
+		objects:  []runtime.Object{newPromotion(v1alpha3.Approvers{}, v1alpha1.PromotionPhaseWaiting)},
+		body:     `{`,
+		username: "admin",
+		wantCode: http.StatusBadRequest,
+	}, {
+		name:     "invalid decision",
+		objects:  []runtime.Object{newPromotion(v1alpha3.Approvers{}, v1alpha1.PromotionPhaseWaiting)},
+		body:     `{"environment":"prod","candidate":{"revision":"abc"},"decision":"Maybe"}`,
+		username: "admin",
+		wantCode: http.StatusBadRequest,
+	}, {
+		name:     "no candidate",
+		objects:  []runtime.Object{newPromotion(v1alpha3.Approvers{}, v1alpha1.PromotionPhaseWaiting)},
+		body:     `{"environment":"prod","decision":"Approve"}`,
+		username: "admin",
+		wantCode: http.StatusBadRequest,
+	}, {
+		name:     "unauthenticated",
+		objects:  []runtime.Object{newPromotion(v1alpha3.Approvers{}, v1alpha1.PromotionPhaseWaiting)},
+		body:     `{"environment":"prod","candidate":{"revision":"abc"},"decision":"Approve"}`,
+		wantCode: http.StatusUnauthorized,
+	}, {
+		name:     "promotion not found",
+		body:     `{"environment":"prod","candidate":{"revision":"abc"},"decision":"Approve"}`,
+		username: "admin",
+		wantCode: http.StatusNotFound,
+	}, {
+		name:     "the first environment has no gates",
+		objects:  []runtime.Object{newPromotion(v1alpha3.Approvers{}, v1alpha1.PromotionPhaseWaiting)},
+		body:     `{"environment":"dev","candidate":{"revision":"abc"},"decision":"Approve"}`,
+		username: "admin",
+		wantCode: http.StatusBadRequest,
+	}, {
+		name:     "no approval gate",
+		objects:  []runtime.Object{newPromotion(v1alpha3.Approvers{}, v1alpha1.PromotionPhaseWaiting)},
+		body:     `{"environment":"staging","candidate":{"revision":"abc"},"decision":"Approve"}`,
+		username: "admin",
+		wantCode: http.StatusBadRequest,
+	}, {
+		name:     "not waiting",
+		objects:  []runtime.Object{newPromotion(v1alpha3.Approvers{}, v1alpha1.PromotionPhaseRejected)},
+		body:     `{"environment":"prod","candidate":{"revision":"abc"},"decision":"Approve"}`,
+		username: "admin",
+		wantCode: http.StatusConflict,
+	}, {
+		name:     "the candidate has changed",
+		objects:  []runtime.Object{newPromotion(v1alpha3.Approvers{Anyone: true}, v1alpha1.PromotionPhaseWaiting)},
+		body:     `{"environment":"prod","candidate":{"revision":"def"},"decision":"Approve"}`,
+		username: "admin",
+		wantCode: http.StatusConflict,
+	}, {
+		name:     "already decided",
+		objects:  []runtime.Object{newPromotion(v1alpha3.Approvers{}, v1alpha1.PromotionPhaseWaiting)},
+		body:     `{"environment":"prod","candidate":{"revision":"abc"},"decision":"Approve"}`,
+		username: "bob",
+		wantCode: http.StatusConflict,
+	}, {
+		name:     "not an approver",
+		objects:  []runtime.Object{newPromotion(v1alpha3.Approvers{Users: []string{"bob"}, Roles: []string{"operator"}}, v1alpha1.PromotionPhaseWaiting), roleBinding},
+		body:     `{"environment":"prod","candidate":{"revision":"abc"},"decision":"Approve"}`,
+		username: "alice",
+		wantCode: http.StatusForbidden,
+	}, {
+		name:     "anyone who is not able to update the promotion",
+		objects:  []runtime.Object{newPromotion(v1alpha3.Approvers{Anyone: true}, v1alpha1.PromotionPhaseWaiting)},
+		body:     `{"environment":"prod","candidate":{"revision":"abc"},"decision":"Approve"}`,
+		username: "alice",
+		wantCode: http.StatusForbidden,
+	}, {
+		name:     "approved by anyone who is able to update the promotion",
+		objects:  []runtime.Object{newPromotion(v1alpha3.Approvers{Anyone: true}, v1alpha1.PromotionPhaseWaiting)},
+		body:     `{"environment":"prod","candidate":{"revision":"abc"},"decision":"Approve","comment":"lgtm"}`,
+		username: "admin",
+		wantCode: http.StatusOK,
+	}, {
+		name:     "approved by the role",
+		objects:  []runtime.Object{newPromotion(v1alpha3.Approvers{Roles: []string{"operator"}}, v1alpha1.PromotionPhaseWaiting), roleBinding},
+		body:     `{"environment":"prod","candidate":{"revision":"abc"},"decision":"Approve","comment":"lgtm"}`,
+		username: "admin",
+		wantCode: http.StatusOK,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newHandler(tt.objects...)
+			req, resp, recorder := request(tt.body, tt.username)
+			h.PromotionApproval(req, resp)
+			assert.Equal(t, tt.wantCode, recorder.Code, recorder.Body.String())
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+
+			result := &v1alpha1.Promotion{}
+			assert.Nil(t, json.Unmarshal(recorder.Body.Bytes(), result))
+			promotion := &v1alpha1.Promotion{}
+			assert.Nil(t, h.Get(context.Background(), types.NamespacedName{Namespace: "ns", Name: "web"}, promotion))
+			for _, p := range []*v1alpha1.Promotion{result, promotion} {
+				decisions := p.Status.GetEnvironment("prod").Decisions
+				if assert.Len(t, decisions, 2) {
+					assert.Equal(t, "admin", decisions[1].User)
+					assert.Equal(t, v1alpha3.ApprovalDecisionApprove, decisions[1].Decision)
+					assert.Equal(t, "lgtm", decisions[1].Comment)
+				}
+			}
+		})
+	}
+}
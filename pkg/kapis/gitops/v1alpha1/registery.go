@@ -29,6 +29,8 @@ import (
 
 // TODO perhaps we can find a better way to declaim the permission needs of the apiserver
 //+kubebuilder:rbac:groups=gitops.kubesphere.io,resources=applications,verbs=get;list;update;delete;create;watch
+//+kubebuilder:rbac:groups=gitops.kubesphere.io,resources=promotions,verbs=get
+//+kubebuilder:rbac:groups=gitops.kubesphere.io,resources=promotions/status,verbs=update
 
 // AddToContainer adds web services into web service container.
 func AddToContainer(container *restful.Container, options *common.Options, argoOption *config.ArgoCDOption, fluxOption *config.FluxCDOption) []*restful.WebService {
@@ -0,0 +1,135 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package metrics contains the domain metrics of ks-devops, all of them are registered on the
+// controller-runtime metrics registry. So they are exported by the metrics server of the controller-manager.
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const metricsNamespace = "ks_devops"
+
+var (
+	pipelineRunLabelNames = []string{"namespace", "devops_project", "pipeline"}
+
+	pipelineRunPhaseTransitions = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "pipelinerun",
+		Name:      "phase_transitions_total",
+		Help:      "Total number of the phase transitions of PipelineRuns",
+	}, append(pipelineRunLabelNames, "from", "to"))
+
+	pipelineRunQueueDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "pipelinerun",
+		Name:      "queue_duration_seconds",
+		Help:      "The time from the creation to the start of PipelineRuns",
+		Buckets:   []float64{1, 5, 10, 30, 60, 120, 300, 600, 1800},
+	}, pipelineRunLabelNames)
+
+	pipelineRunDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "pipelinerun",
+		Name:      "duration_seconds",
+		Help:      "The time from the start to the completion of PipelineRuns",
+		Buckets:   []float64{10, 30, 60, 120, 300, 600, 1200, 1800, 3600, 7200},
+	}, append(pipelineRunLabelNames, "phase"))
+
+	pipelineRunFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "pipelinerun",
+		Name:      "failures_total",
+		Help:      "Total number of the failures when triggering PipelineRuns or retrieving the running data",
+	}, append(pipelineRunLabelNames, "reason"))
+
+	applicationSyncStatus = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "application",
+		Name:      "sync_status",
+		Help:      "The sync status of GitOps Applications, the value is 1 for the current status",
+	}, []string{"namespace", "application", "status"})
+
+	applicationHealthStatus = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "application",
+		Name:      "health_status",
+		Help:      "The health status of GitOps Applications, the value is 1 for the current status",
+	}, []string{"namespace", "application", "status"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(pipelineRunPhaseTransitions, pipelineRunQueueDuration, pipelineRunDuration,
+		pipelineRunFailures, applicationSyncStatus, applicationHealthStatus)
+}
+
+// PipelineRunLabels are the common labels of the PipelineRun metrics
+type PipelineRunLabels struct {
+	Namespace     string
+	DevOpsProject string
+	Pipeline      string
+}
+
+func (l PipelineRunLabels) values(extra ...string) []string {
+	return append([]string{l.Namespace, l.DevOpsProject, l.Pipeline}, extra...)
+}
+
+// RecordPipelineRunPhaseTransition counts a phase transition of a PipelineRun
+func RecordPipelineRunPhaseTransition(labels PipelineRunLabels, from, to string) {
+	pipelineRunPhaseTransitions.WithLabelValues(labels.values(from, to)...).Inc()
+}
+
+// ObservePipelineRunQueueDuration records the time which a PipelineRun waited before it started
+func ObservePipelineRunQueueDuration(labels PipelineRunLabels, duration time.Duration) {
+	pipelineRunQueueDuration.WithLabelValues(labels.values()...).Observe(duration.Seconds())
+}
+
+// ObservePipelineRunDuration records the running time of a completed PipelineRun
+func ObservePipelineRunDuration(labels PipelineRunLabels, phase string, duration time.Duration) {
+	pipelineRunDuration.WithLabelValues(labels.values(phase)...).Observe(duration.Seconds())
+}
+
+// RecordPipelineRunFailure counts a failure of a PipelineRun, the reason is the same as the event reason
+func RecordPipelineRunFailure(labels PipelineRunLabels, reason string) {
+	pipelineRunFailures.WithLabelValues(labels.values(reason)...).Inc()
+}
+
+// SetApplicationSyncStatus sets the current sync status of an Application
+func SetApplicationSyncStatus(namespace, name, status string) {
+	setStatusGauge(applicationSyncStatus, namespace, name, status)
+}
+
+// SetApplicationHealthStatus sets the current health status of an Application
+func SetApplicationHealthStatus(namespace, name, status string) {
+	setStatusGauge(applicationHealthStatus, namespace, name, status)
+}
+
+// DeleteApplicationStatus removes all the status metrics of an Application
+func DeleteApplicationStatus(namespace, name string) {
+	labels := prometheus.Labels{"namespace": namespace, "application": name}
+	applicationSyncStatus.DeletePartialMatch(labels)
+	applicationHealthStatus.DeletePartialMatch(labels)
+}
+
+func setStatusGauge(gauge *prometheus.GaugeVec, namespace, name, status string) {
+	// only keep the current status
+	gauge.DeletePartialMatch(prometheus.Labels{"namespace": namespace, "application": name})
+	gauge.WithLabelValues(namespace, name, status).Set(1)
+}
@@ -0,0 +1,61 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPipelineRunMetrics(t *testing.T) {
+	labels := PipelineRunLabels{Namespace: "ns", DevOpsProject: "project", Pipeline: "pipeline"}
+
+	RecordPipelineRunPhaseTransition(labels, "Pending", "Running")
+	RecordPipelineRunPhaseTransition(labels, "Pending", "Running")
+	assert.Equal(t, float64(2), testutil.ToFloat64(
+		pipelineRunPhaseTransitions.WithLabelValues("ns", "project", "pipeline", "Pending", "Running")))
+
+	RecordPipelineRunFailure(labels, "TriggerFailed")
+	assert.Equal(t, float64(1), testutil.ToFloat64(
+		pipelineRunFailures.WithLabelValues("ns", "project", "pipeline", "TriggerFailed")))
+
+	ObservePipelineRunQueueDuration(labels, 3*time.Second)
+	assert.Equal(t, 1, testutil.CollectAndCount(pipelineRunQueueDuration))
+
+	ObservePipelineRunDuration(labels, "Succeeded", time.Minute)
+	ObservePipelineRunDuration(labels, "Failed", time.Minute)
+	assert.Equal(t, 2, testutil.CollectAndCount(pipelineRunDuration))
+}
+
+func TestApplicationStatusMetrics(t *testing.T) {
+	SetApplicationSyncStatus("ns", "app", "OutOfSync")
+	SetApplicationSyncStatus("ns", "app", "Synced")
+	SetApplicationHealthStatus("ns", "app", "Healthy")
+	SetApplicationHealthStatus("ns", "other", "Degraded")
+
+	// only the current status is kept
+	assert.Equal(t, 1, testutil.CollectAndCount(applicationSyncStatus))
+	assert.Equal(t, float64(1), testutil.ToFloat64(applicationSyncStatus.WithLabelValues("ns", "app", "Synced")))
+	assert.Equal(t, 2, testutil.CollectAndCount(applicationHealthStatus))
+
+	DeleteApplicationStatus("ns", "app")
+	assert.Equal(t, 0, testutil.CollectAndCount(applicationSyncStatus))
+	assert.Equal(t, 1, testutil.CollectAndCount(applicationHealthStatus))
+}
@@ -0,0 +1,278 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package analytics
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/kubesphere/ks-devops/pkg/api/devops/v1alpha3"
+	"github.com/kubesphere/ks-devops/pkg/api/gitops/v1alpha1"
+)
+
+// Event is a finished deployment, it's either successful or failed
+type Event struct {
+	Pipeline    string
+	Branch      string
+	Application string
+	// ChangeTime is when the change was made, it's zero if it's unknown
+	ChangeTime time.Time
+	// Time is when the deployment finished
+	Time   time.Time
+	Failed bool
+}
+
+// streamKey identifies a stream of deployments, a failure is restored by a successful deployment of the same stream
+type streamKey struct {
+	pipeline, branch, application string
+}
+
+func (e *Event) streamKey() streamKey {
+	return streamKey{pipeline: e.Pipeline, branch: e.Branch, application: e.Application}
+}
+
+// Metrics are the DORA metrics over a time window
+type Metrics struct {
+	Deployments         int     `json:"deployments" description:"The number of the successful deployments"`
+	Failures            int     `json:"failures" description:"The number of the failed deployments"`
+	Restores            int     `json:"restores" description:"The number of the restores from failures"`
+	DeploymentFrequency float64 `json:"deploymentFrequency" description:"The number of the successful deployments per day"`
+	LeadTimeForChanges  float64 `json:"leadTimeForChanges" description:"The mean seconds from a change to its successful deployment"`
+	ChangeFailureRate   float64 `json:"changeFailureRate" description:"The ratio of the failed deployments, from 0 to 1"`
+	MeanTimeToRestore   float64 `json:"meanTimeToRestore" description:"The mean seconds from a failure to the next successful deployment"`
+}
+
+// GroupMetrics are the metrics of a Pipeline, a branch of a Pipeline or an Application
+type GroupMetrics struct {
+	Pipeline    string `json:"pipeline,omitempty"`
+	Branch      string `json:"branch,omitempty"`
+	Application string `json:"application,omitempty"`
+	Metrics
+}
+
+// Report is the delivery analytics of a DevOpsProject over a time window
+type Report struct {
+	Namespace string      `json:"namespace"`
+	Start     metav1.Time `json:"start"`
+	End       metav1.Time `json:"end"`
+	// DeploymentSelector selects the PipelineRuns which are deployments
+	DeploymentSelector string         `json:"deploymentSelector,omitempty" description:"The label selector of the PipelineRuns which are deployments"`
+	Project            Metrics        `json:"project" description:"The metrics of all the Pipelines and Applications"`
+	Pipelines          []GroupMetrics `json:"pipelines"`
+	Branches           []GroupMetrics `json:"branches" description:"The metrics of the branches of the multi-branch Pipelines"`
+	Applications       []GroupMetrics `json:"applications"`
+}
+
+// accumulator accumulates the events of a group
+type accumulator struct {
+	deployments, failures, restores int
+	leadTimes, leadTimeCount        float64
+	restoreTime                     float64
+}
+
+func (a *accumulator) metrics(days float64) (metrics Metrics) {
+	metrics = Metrics{Deployments: a.deployments, Failures: a.failures, Restores: a.restores}
+	if days > 0 {
+		metrics.DeploymentFrequency = float64(a.deployments) / days
+	}
+	if a.leadTimeCount > 0 {
+		metrics.LeadTimeForChanges = a.leadTimes / a.leadTimeCount
+	}
+	if total := a.deployments + a.failures; total > 0 {
+		metrics.ChangeFailureRate = float64(a.failures) / float64(total)
+	}
+	if a.restores > 0 {
+		metrics.MeanTimeToRestore = a.restoreTime / float64(a.restores)
+	}
+	return
+}
+
+// Compute computes the metrics of the events which finished in the time window [start, end). The earlier events are
+// only used to find out the failures which are restored in the time window.
+func Compute(namespace string, events []Event, start, end time.Time) *Report {
+	sorted := make([]Event, len(events))
+	copy(sorted, events)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Time.Before(sorted[j].Time)
+	})
+
+	project := &accumulator{}
+	groups := map[streamKey]*accumulator{}
+	getGroup := func(key streamKey) *accumulator {
+		if _, ok := groups[key]; !ok {
+			groups[key] = &accumulator{}
+		}
+		return groups[key]
+	}
+	failingSince := map[streamKey]time.Time{}
+	for i := range sorted {
+		event := &sorted[i]
+		if !event.Time.Before(end) {
+			break
+		}
+		key := event.streamKey()
+		inWindow := !event.Time.Before(start)
+		accumulators := []*accumulator{project, getGroup(key)}
+		if event.Pipeline != "" && event.Branch != "" {
+			accumulators = append(accumulators, getGroup(streamKey{pipeline: event.Pipeline}))
+		}
+
+		since, failing := failingSince[key]
+		if event.Failed {
+			if !failing {
+				failingSince[key] = event.Time
+			}
+		} else {
+			delete(failingSince, key)
+		}
+		if !inWindow {
+			continue
+		}
+
+		for _, a := range accumulators {
+			if event.Failed {
+				a.failures++
+				continue
+			}
+			a.deployments++
+			if !event.ChangeTime.IsZero() && event.Time.After(event.ChangeTime) {
+				a.leadTimes += event.Time.Sub(event.ChangeTime).Seconds()
+				a.leadTimeCount++
+			}
+			if failing {
+				a.restores++
+				a.restoreTime += event.Time.Sub(since).Seconds()
+			}
+		}
+	}
+
+	days := end.Sub(start).Hours() / 24
+	report := &Report{
+		Namespace:    namespace,
+		Start:        metav1.NewTime(start),
+		End:          metav1.NewTime(end),
+		Project:      project.metrics(days),
+		Pipelines:    []GroupMetrics{},
+		Branches:     []GroupMetrics{},
+		Applications: []GroupMetrics{},
+	}
+	for key, a := range groups {
+		if a.deployments+a.failures == 0 {
+			continue
+		}
+		group := GroupMetrics{Pipeline: key.pipeline, Branch: key.branch, Application: key.application, Metrics: a.metrics(days)}
+		switch {
+		case key.application != "":
+			report.Applications = append(report.Applications, group)
+		case key.branch != "":
+			report.Branches = append(report.Branches, group)
+		default:
+			report.Pipelines = append(report.Pipelines, group)
+		}
+	}
+	for _, items := range [][]GroupMetrics{report.Pipelines, report.Branches, report.Applications} {
+		sort.Slice(items, func(i, j int) bool {
+			if items[i].Pipeline != items[j].Pipeline {
+				return items[i].Pipeline < items[j].Pipeline
+			}
+			if items[i].Branch != items[j].Branch {
+				return items[i].Branch < items[j].Branch
+			}
+			return items[i].Application < items[j].Application
+		})
+	}
+	return report
+}
+
+// GetPipelineRunEvent returns the event of a completed PipelineRun. The change time is the commit time of the earliest
+// SCM change which the PipelineRun built, the cancelled and running PipelineRuns are ignored. The matrix PipelineRuns
+// are ignored as well, since their children are counted.
+func GetPipelineRunEvent(pr *v1alpha3.PipelineRun) (event Event, ok bool) {
+	if pr.IsMatrix() || pr.Status.CompletionTime == nil || (pr.Status.Phase != v1alpha3.Succeeded && pr.Status.Phase != v1alpha3.Failed) {
+		return
+	}
+	event = Event{
+		Pipeline: pr.Labels[v1alpha3.PipelineNameLabelKey],
+		Branch:   pr.GetRefName(),
+		Time:     pr.Status.CompletionTime.Time,
+		Failed:   pr.Status.Phase == v1alpha3.Failed,
+	}
+	if pr.Status.ChangeTime != nil {
+		event.ChangeTime = pr.Status.ChangeTime.Time
+	}
+	if event.Pipeline == "" && pr.Spec.PipelineRef != nil {
+		event.Pipeline = pr.Spec.PipelineRef.Name
+	}
+	ok = true
+	return
+}
+
+// argoStatus contains the fields of the Argo CD Application status for the analytics
+type argoStatus struct {
+	History []struct {
+		DeployedAt metav1.Time `json:"deployedAt"`
+	} `json:"history"`
+	OperationState *struct {
+		Phase      string       `json:"phase"`
+		FinishedAt *metav1.Time `json:"finishedAt"`
+	} `json:"operationState"`
+}
+
+// GetApplicationEvents returns the events of an Application. The events of an Argo CD Application are the deployment
+// history and the last failed sync operation. The events of a FluxCD Application are the current ready states of the
+// HelmReleases and Kustomizations. The change time of an Application event is unknown.
+func GetApplicationEvents(app *v1alpha1.Application) (events []Event) {
+	if app.Status.ArgoApp != "" {
+		status := &argoStatus{}
+		if err := json.Unmarshal([]byte(app.Status.ArgoApp), status); err != nil {
+			return
+		}
+		for _, history := range status.History {
+			events = append(events, Event{Application: app.Name, Time: history.DeployedAt.Time})
+		}
+		if op := status.OperationState; op != nil && op.FinishedAt != nil && (op.Phase == "Failed" || op.Phase == "Error") {
+			events = append(events, Event{Application: app.Name, Time: op.FinishedAt.Time, Failed: true})
+		}
+		return
+	}
+
+	var conditions [][]metav1.Condition
+	for _, status := range app.Status.FluxApp.HelmReleaseStatus {
+		if status != nil {
+			conditions = append(conditions, status.Conditions)
+		}
+	}
+	for _, status := range app.Status.FluxApp.KustomizationStatus {
+		if status != nil {
+			conditions = append(conditions, status.Conditions)
+		}
+	}
+	for _, items := range conditions {
+		if ready := meta.FindStatusCondition(items, "Ready"); ready != nil && ready.Status != metav1.ConditionUnknown {
+			events = append(events, Event{
+				Application: app.Name,
+				Time:        ready.LastTransitionTime.Time,
+				Failed:      ready.Status == metav1.ConditionFalse,
+			})
+		}
+	}
+	return
+}
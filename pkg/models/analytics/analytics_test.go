@@ -0,0 +1,227 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package analytics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/kubesphere/ks-devops/pkg/api/devops/v1alpha3"
+	"github.com/kubesphere/ks-devops/pkg/api/gitops/v1alpha1"
+	helmv2 "github.com/kubesphere/ks-devops/pkg/external/fluxcd/helm/v2beta1"
+	kusv1 "github.com/kubesphere/ks-devops/pkg/external/fluxcd/kustomize/v1beta2"
+)
+
+func TestCompute(t *testing.T) {
+	start := time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC)
+	end := start.Add(10 * 24 * time.Hour)
+	at := func(hours int) time.Time {
+		return start.Add(time.Duration(hours) * time.Hour)
+	}
+
+	t.Run("no events", func(t *testing.T) {
+		report := Compute("ns", nil, start, end)
+		assert.Equal(t, "ns", report.Namespace)
+		assert.Equal(t, Metrics{}, report.Project)
+		assert.Empty(t, report.Pipelines)
+		assert.Empty(t, report.Branches)
+		assert.Empty(t, report.Applications)
+	})
+
+	t.Run("pipelines, branches and applications", func(t *testing.T) {
+		events := []Event{
+			// failed before the window, restored in the window
+			{Pipeline: "a", Time: at(-2), Failed: true},
+			{Pipeline: "a", ChangeTime: at(1), Time: at(2)},
+			{Pipeline: "a", ChangeTime: at(3), Time: at(4), Failed: true},
+			{Pipeline: "a", ChangeTime: at(5), Time: at(6), Failed: true},
+			{Pipeline: "a", ChangeTime: at(7), Time: at(8)},
+			// after the window
+			{Pipeline: "a", ChangeTime: at(240), Time: at(241)},
+			{Pipeline: "b", Branch: "main", ChangeTime: at(10), Time: at(12)},
+			{Pipeline: "b", Branch: "dev", ChangeTime: at(10), Time: at(11), Failed: true},
+			{Application: "app", Time: at(20)},
+		}
+		report := Compute("ns", events, start, end)
+
+		assert.Equal(t, Metrics{
+			Deployments:         4,
+			Failures:            3,
+			Restores:            2,
+			DeploymentFrequency: 0.4,
+			LeadTimeForChanges:  time.Hour.Seconds() * 4 / 3,
+			ChangeFailureRate:   3.0 / 7,
+			MeanTimeToRestore:   time.Hour.Seconds() * 4,
+		}, report.Project)
+
+		assert.Equal(t, []GroupMetrics{{
+			Pipeline: "a",
+			Metrics: Metrics{
+				Deployments:         2,
+				Failures:            2,
+				Restores:            2,
+				DeploymentFrequency: 0.2,
+				LeadTimeForChanges:  time.Hour.Seconds(),
+				ChangeFailureRate:   0.5,
+				MeanTimeToRestore:   time.Hour.Seconds() * 4,
+			},
+		}, {
+			Pipeline: "b",
+			Metrics: Metrics{
+				Deployments:         1,
+				Failures:            1,
+				DeploymentFrequency: 0.1,
+				LeadTimeForChanges:  time.Hour.Seconds() * 2,
+				ChangeFailureRate:   0.5,
+			},
+		}}, report.Pipelines)
+
+		assert.Equal(t, 2, len(report.Branches))
+		assert.Equal(t, "dev", report.Branches[0].Branch)
+		assert.Equal(t, 1, report.Branches[0].Failures)
+		assert.Equal(t, "main", report.Branches[1].Branch)
+		assert.Equal(t, 1, report.Branches[1].Deployments)
+
+		assert.Equal(t, []GroupMetrics{{
+			Application: "app",
+			Metrics:     Metrics{Deployments: 1, DeploymentFrequency: 0.1},
+		}}, report.Applications)
+	})
+}
+
+func TestGetPipelineRunEvent(t *testing.T) {
+	changed := metav1.NewTime(time.Date(2022, 5, 31, 0, 0, 0, 0, time.UTC))
+	created := metav1.NewTime(time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC))
+	completed := metav1.NewTime(created.Add(time.Minute))
+
+	t.Run("running", func(t *testing.T) {
+		_, ok := GetPipelineRunEvent(&v1alpha3.PipelineRun{Status: v1alpha3.PipelineRunStatus{Phase: v1alpha3.Running}})
+		assert.False(t, ok)
+	})
+
+	t.Run("cancelled", func(t *testing.T) {
+		_, ok := GetPipelineRunEvent(&v1alpha3.PipelineRun{
+			Status: v1alpha3.PipelineRunStatus{Phase: v1alpha3.Cancelled, CompletionTime: &completed},
+		})
+		assert.False(t, ok)
+	})
+
+	t.Run("matrix", func(t *testing.T) {
+		_, ok := GetPipelineRunEvent(&v1alpha3.PipelineRun{
+			Spec:   v1alpha3.PipelineRunSpec{Matrix: &v1alpha3.Matrix{}},
+			Status: v1alpha3.PipelineRunStatus{Phase: v1alpha3.Succeeded, CompletionTime: &completed},
+		})
+		assert.False(t, ok)
+	})
+
+	t.Run("failed multi-branch PipelineRun", func(t *testing.T) {
+		event, ok := GetPipelineRunEvent(&v1alpha3.PipelineRun{
+			ObjectMeta: metav1.ObjectMeta{
+				CreationTimestamp: created,
+				Labels:            map[string]string{v1alpha3.PipelineNameLabelKey: "pipeline"},
+			},
+			Spec: v1alpha3.PipelineRunSpec{
+				PipelineSpec: &v1alpha3.PipelineSpec{Type: v1alpha3.MultiBranchPipelineType},
+				SCM:          &v1alpha3.SCM{RefName: "main"},
+			},
+			Status: v1alpha3.PipelineRunStatus{Phase: v1alpha3.Failed, CompletionTime: &completed, ChangeTime: &changed},
+		})
+		assert.True(t, ok)
+		assert.Equal(t, Event{
+			Pipeline:   "pipeline",
+			Branch:     "main",
+			ChangeTime: changed.Time,
+			Time:       completed.Time,
+			Failed:     true,
+		}, event)
+	})
+
+	t.Run("succeeded PipelineRun without the label", func(t *testing.T) {
+		event, ok := GetPipelineRunEvent(&v1alpha3.PipelineRun{
+			Spec:   v1alpha3.PipelineRunSpec{PipelineRef: &v1.ObjectReference{Name: "pipeline"}},
+			Status: v1alpha3.PipelineRunStatus{Phase: v1alpha3.Succeeded, CompletionTime: &completed},
+		})
+		assert.True(t, ok)
+		assert.Equal(t, "pipeline", event.Pipeline)
+		assert.False(t, event.Failed)
+		// the lead time is unknown without the SCM changes
+		assert.True(t, event.ChangeTime.IsZero())
+	})
+}
+
+func TestGetApplicationEvents(t *testing.T) {
+	t.Run("invalid Argo CD status", func(t *testing.T) {
+		app := &v1alpha1.Application{Status: v1alpha1.ApplicationStatus{ArgoApp: "{"}}
+		assert.Empty(t, GetApplicationEvents(app))
+	})
+
+	t.Run("Argo CD Application", func(t *testing.T) {
+		app := &v1alpha1.Application{
+			ObjectMeta: metav1.ObjectMeta{Name: "app"},
+			Status: v1alpha1.ApplicationStatus{ArgoApp: `{
+"history": [{"deployedAt": "2022-06-01T00:00:00Z"}, {"deployedAt": "2022-06-02T00:00:00Z"}],
+"operationState": {"phase": "Failed", "finishedAt": "2022-06-03T00:00:00Z"}}`},
+		}
+		assert.Equal(t, []Event{
+			{Application: "app", Time: time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC)},
+			{Application: "app", Time: time.Date(2022, 6, 2, 0, 0, 0, 0, time.UTC)},
+			{Application: "app", Time: time.Date(2022, 6, 3, 0, 0, 0, 0, time.UTC), Failed: true},
+		}, toUTC(GetApplicationEvents(app)))
+	})
+
+	t.Run("Argo CD Application with a succeeded operation", func(t *testing.T) {
+		app := &v1alpha1.Application{
+			ObjectMeta: metav1.ObjectMeta{Name: "app"},
+			Status: v1alpha1.ApplicationStatus{ArgoApp: `{
+"history": [{"deployedAt": "2022-06-01T00:00:00Z"}],
+"operationState": {"phase": "Succeeded", "finishedAt": "2022-06-01T00:00:00Z"}}`},
+		}
+		assert.Equal(t, 1, len(GetApplicationEvents(app)))
+	})
+
+	t.Run("FluxCD Application", func(t *testing.T) {
+		ready := metav1.NewTime(time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC))
+		app := &v1alpha1.Application{
+			ObjectMeta: metav1.ObjectMeta{Name: "app"},
+			Status: v1alpha1.ApplicationStatus{FluxApp: v1alpha1.FluxApplicationStatus{
+				HelmReleaseStatus: map[string]*helmv2.HelmReleaseStatus{
+					"helm": {Conditions: []metav1.Condition{{Type: "Ready", Status: metav1.ConditionFalse, LastTransitionTime: ready}}},
+					"nil":  nil,
+				},
+				KustomizationStatus: map[string]*kusv1.KustomizationStatus{
+					"kus":     {Conditions: []metav1.Condition{{Type: "Ready", Status: metav1.ConditionTrue, LastTransitionTime: ready}}},
+					"unknown": {Conditions: []metav1.Condition{{Type: "Ready", Status: metav1.ConditionUnknown}}},
+				},
+			}},
+		}
+		assert.Equal(t, []Event{
+			{Application: "app", Time: ready.Time, Failed: true},
+			{Application: "app", Time: ready.Time},
+		}, GetApplicationEvents(app))
+	})
+}
+
+func toUTC(events []Event) []Event {
+	for i := range events {
+		events[i].Time = events[i].Time.UTC()
+	}
+	return events
+}
@@ -26,7 +26,9 @@ import (
 	"net/http"
 	"strings"
 	"sync"
+	"time"
 
+	"github.com/emicklei/go-restful/v3"
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -44,6 +46,7 @@ import (
 	kubesphere "github.com/kubesphere/ks-devops/pkg/client/clientset/versioned"
 	"github.com/kubesphere/ks-devops/pkg/client/devops"
 	"github.com/kubesphere/ks-devops/pkg/constants"
+	"github.com/kubesphere/ks-devops/pkg/utils/cronutil"
 	"github.com/kubesphere/ks-devops/pkg/utils/secretutil"
 	"kubesphere.io/kubesphere/pkg/apiserver/query"
 	resourcesv1alpha3 "kubesphere.io/kubesphere/pkg/models/resources/v1alpha3"
@@ -70,7 +73,7 @@ type DevopsOperator interface {
 	DeletePipelineObj(projectName string, pipelineName string) error
 	UpdatePipelineObj(projectName string, pipeline *devopsv1alpha3.Pipeline) (*devopsv1alpha3.Pipeline, error)
 	ListPipelineObj(projectName string, query *query.Query) (api.ListResult, error)
-	UpdateJenkinsfile(projectName, pipelineName, mode, jenkinsfile string) error
+	UpdateJenkinsfile(projectName, pipelineName, mode, jenkinsfile, author string) error
 
 	CreateCredentialObj(projectName string, s *v1.Secret) (*v1.Secret, error)
 	GetCredentialObj(projectName string, secretName string) (*v1.Secret, error)
@@ -350,6 +353,11 @@ func (d devopsOperator) UpdatePipelineObj(projectName string, pipeline *devopsv1
 		if pipeline.Spec.Pipeline != nil && latestPipe.Spec.Pipeline != nil {
 			pipeline.Spec.Pipeline.Jenkinsfile = latestPipe.Spec.Pipeline.Jenkinsfile
 		}
+
+		// only the metadata and the Git source are editable if the definition is synchronized from Git
+		if latestPipe.IsGitManaged() && !latestPipe.Spec.HasSameDefinition(&pipeline.Spec) {
+			return nil, newGitManagedError(latestPipe)
+		}
 	} else {
 		return nil, fmt.Errorf("cannot found pipeline %s/%s, error: %v", ns, name, err)
 	}
@@ -357,12 +365,16 @@ func (d devopsOperator) UpdatePipelineObj(projectName string, pipeline *devopsv1
 	return d.ksclient.DevopsV1alpha3().Pipelines(ns).Update(d.context, pipeline, metav1.UpdateOptions{})
 }
 
-// UpdateJenkinsfile updates the Jenkinsfile value with specific edit mode
-func (d devopsOperator) UpdateJenkinsfile(projectName, pipelineName, mode, jenkinsfile string) (err error) {
+// UpdateJenkinsfile updates the Jenkinsfile value with specific edit mode, the author is recorded in the next revision
+func (d devopsOperator) UpdateJenkinsfile(projectName, pipelineName, mode, jenkinsfile, author string) (err error) {
 	var pipeline *devopsv1alpha3.Pipeline
 	if pipeline, err = d.ksclient.DevopsV1alpha3().Pipelines(projectName).Get(d.context, pipelineName, metav1.GetOptions{}); err != nil {
 		return
 	}
+	if pipeline.IsGitManaged() {
+		err = newGitManagedError(pipeline)
+		return
+	}
 
 	if pipeline.Annotations == nil {
 		pipeline.Annotations = map[string]string{}
@@ -380,10 +392,19 @@ func (d devopsOperator) UpdateJenkinsfile(projectName, pipelineName, mode, jenki
 		err = fmt.Errorf("invalid edit mode: %s", mode)
 		return
 	}
+	// the JSON is converted to the Jenkinsfile later, the author is carried over to the converted spec then
+	pipeline.SetSpecAuthor(author, "")
 	_, err = d.ksclient.DevopsV1alpha3().Pipelines(projectName).Update(d.context, pipeline, metav1.UpdateOptions{})
 	return
 }
 
+// newGitManagedError returns the error of editing a Pipeline which is managed by Git
+func newGitManagedError(pipeline *devopsv1alpha3.Pipeline) error {
+	return errors.NewForbidden(devopsv1alpha3.Resource(devopsv1alpha3.ResourcePluralPipeline), pipeline.Name,
+		fmt.Errorf("the Pipeline is managed by GitRepository %s, please edit %s in the repository instead",
+			pipeline.Spec.Source.GitRepository, pipeline.Spec.Source.Path))
+}
+
 func (d devopsOperator) ListPipelineObj(projectName string, queryParam *query.Query) (api.ListResult, error) {
 	project, err := d.ksclient.DevopsV1alpha3().DevOpsProjects().Get(d.context, projectName, metav1.GetOptions{})
 	if err != nil {
@@ -1026,16 +1047,68 @@ func (d devopsOperator) CheckScriptCompile(projectName, pipelineName string, req
 	return resBody, err
 }
 
+// cronMessageLayout is the layout of the times in the message of checking cron, it's the same as the one of Jenkins
+const cronMessageLayout = "Monday, January 2, 2006 3:04:05 PM MST"
+
+// CheckCron validates the cron natively, it gives the last and next time of the schedule like Jenkins does
 func (d devopsOperator) CheckCron(projectName string, req *http.Request) (*devops.CheckCronRes, error) {
+	cronData, err := getCronData(req)
+	if err != nil {
+		klog.Error(err)
+		return nil, restful.NewError(http.StatusBadRequest, err.Error())
+	}
+	return checkCron(projectName, cronData, time.Now()), nil
+}
 
-	res, err := d.devopsClient.CheckCron(projectName, convertToHttpParameters(req))
+// getCronData reads the cron from the JSON body, or from the form and query parameters like the cron checking of
+// Jenkins. The parameter "value" is an alias of "cron".
+func getCronData(req *http.Request) (cronData *devops.CronData, err error) {
+	cronData = &devops.CronData{}
+	values := req.URL.Query()
+	if strings.HasPrefix(req.Header.Get("Content-Type"), "application/x-www-form-urlencoded") {
+		if err = req.ParseForm(); err != nil {
+			return
+		}
+		values = req.Form
+	}
+	if cron := values.Get("cron"); cron != "" || values.Get("value") != "" {
+		if cron == "" {
+			cron = values.Get("value")
+		}
+		cronData.Cron = cron
+		cronData.PipelineName = values.Get("pipelineName")
+		cronData.TimeZone = values.Get("timeZone")
+		return
+	}
+	err = json.NewDecoder(req.Body).Decode(cronData)
+	return
+}
 
+func checkCron(projectName string, cronData *devops.CronData, now time.Time) *devops.CheckCronRes {
+	// the key is the same as the one of the scheduler, then the H symbols give the same times
+	var key string
+	if cronData.PipelineName != "" {
+		key = projectName + "/" + cronData.PipelineName
+	}
+	schedule, err := cronutil.Parse(cronData.Cron, cronData.TimeZone, key)
 	if err != nil {
-		klog.Error(err)
-		return nil, err
+		return &devops.CheckCronRes{Result: "error", Message: err.Error()}
 	}
 
-	return res, err
+	res := &devops.CheckCronRes{Result: "ok"}
+	var messages []string
+	if last := schedule.Prev(now); !last.IsZero() {
+		res.LastTime = last.Format(time.RFC3339)
+		messages = append(messages, fmt.Sprintf("Would last have run at %s", last.Format(cronMessageLayout)))
+	}
+	if next := schedule.Next(now); !next.IsZero() {
+		res.NextTime = next.Format(time.RFC3339)
+		messages = append(messages, fmt.Sprintf("would next run at %s", next.Format(cronMessageLayout)))
+	}
+	if len(messages) > 0 {
+		res.Message = strings.Join(messages, "; ") + "."
+	}
+	return res
 }
 
 func (d devopsOperator) GetJenkinsAgentLabels() (labels []string, err error) {
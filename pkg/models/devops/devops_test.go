@@ -23,7 +23,9 @@ import (
 	"k8s.io/client-go/kubernetes"
 	k8sfake "k8s.io/client-go/kubernetes/fake"
 	"net/http"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/kubesphere/ks-devops/pkg/api/devops/v1alpha3"
 	"github.com/kubesphere/ks-devops/pkg/client/clientset/versioned"
@@ -439,7 +441,7 @@ func Test_devopsOperator_UpdateJenkinsfile(t *testing.T) {
 				ksclient:     tt.fields.ksclient,
 				context:      tt.fields.context,
 			}
-			tt.wantErr(t, d.UpdateJenkinsfile(tt.args.projectName, tt.args.pipelineName, tt.args.mode, tt.args.jenkinsfile), fmt.Sprintf("UpdateJenkinsfile(%v, %v, %v, %v)", tt.args.projectName, tt.args.pipelineName, tt.args.mode, tt.args.jenkinsfile))
+			tt.wantErr(t, d.UpdateJenkinsfile(tt.args.projectName, tt.args.pipelineName, tt.args.mode, tt.args.jenkinsfile, "admin"), fmt.Sprintf("UpdateJenkinsfile(%v, %v, %v, %v)", tt.args.projectName, tt.args.pipelineName, tt.args.mode, tt.args.jenkinsfile))
 			if tt.verify != nil {
 				tt.verify(t, tt.fields.ksclient)
 			}
@@ -673,3 +675,52 @@ func Test_devopsOperator_CheckDevopsName(t *testing.T) {
 		})
 	}
 }
+
+func Test_devopsOperator_CheckCron(t *testing.T) {
+	d := devopsOperator{}
+
+	req, _ := http.NewRequest(http.MethodPost, "", strings.NewReader(`{"cron": "0 12 * * *"}`))
+	res, err := d.CheckCron("project", req)
+	assert.Nil(t, err)
+	assert.Equal(t, "ok", res.Result)
+	assert.NotEmpty(t, res.LastTime)
+	assert.NotEmpty(t, res.NextTime)
+
+	req, _ = http.NewRequest(http.MethodPost, "", strings.NewReader(`{"cron": "0 25 * * *"}`))
+	res, err = d.CheckCron("project", req)
+	assert.Nil(t, err)
+	assert.Equal(t, "error", res.Result)
+	assert.NotEmpty(t, res.Message)
+
+	req, _ = http.NewRequest(http.MethodPost, "", strings.NewReader(`invalid`))
+	_, err = d.CheckCron("project", req)
+	assert.NotNil(t, err)
+
+	// the form parameters like the cron checking of Jenkins
+	req, _ = http.NewRequest(http.MethodPost, "", strings.NewReader(`value=0+12+*+*+*&pipelineName=pipeline`))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	res, err = d.CheckCron("project", req)
+	assert.Nil(t, err)
+	assert.Equal(t, "ok", res.Result)
+
+	req, _ = http.NewRequest(http.MethodPost, "?cron=0+25+*+*+*", nil)
+	res, err = d.CheckCron("project", req)
+	assert.Nil(t, err)
+	assert.Equal(t, "error", res.Result)
+}
+
+func Test_checkCron(t *testing.T) {
+	now := time.Date(2022, 1, 1, 10, 30, 0, 0, time.UTC)
+	res := checkCron("project", &devops.CronData{Cron: "0 12 * * *", TimeZone: "Asia/Shanghai"}, now)
+	assert.Equal(t, &devops.CheckCronRes{
+		Result:   "ok",
+		Message:  "Would last have run at Saturday, January 1, 2022 4:00:00 AM UTC; would next run at Sunday, January 2, 2022 4:00:00 AM UTC.",
+		LastTime: "2022-01-01T04:00:00Z",
+		NextTime: "2022-01-02T04:00:00Z",
+	}, res)
+
+	// the hashed times are stable for a Pipeline
+	first := checkCron("project", &devops.CronData{PipelineName: "pipeline", Cron: "H H * * *"}, now)
+	second := checkCron("project", &devops.CronData{PipelineName: "pipeline", Cron: "H H * * *"}, now)
+	assert.Equal(t, first, second)
+}
@@ -0,0 +1,374 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jenkinsfile
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const indentUnit = "  "
+
+type generator struct {
+	builder strings.Builder
+	depth   int
+	err     error
+}
+
+// Generate generates a declarative Jenkinsfile from the definition. An UnsupportedError is returned if the definition
+// can't be written as the supported subset, like a stage with multiple branches.
+func Generate(definition *Definition) (string, error) {
+	pipeline := &definition.Pipeline
+	if len(pipeline.Stages) == 0 {
+		return "", &UnsupportedError{Message: "a Pipeline without stages"}
+	}
+
+	g := &generator{}
+	g.open("pipeline")
+	g.agent(pipeline.Agent)
+	g.environment(pipeline.Environment)
+	g.stages("stages", pipeline.Stages)
+	g.post(pipeline.Post)
+	g.close()
+	if g.err != nil {
+		return "", g.err
+	}
+	return g.builder.String(), nil
+}
+
+func (g *generator) agent(agent *Agent) {
+	switch {
+	case agent == nil:
+	case agent.Type == "":
+		g.fail("an agent without type")
+	case agent.Argument == nil && len(agent.Arguments) == 0:
+		if agent.Type == "any" || agent.Type == "none" {
+			g.line("agent " + agent.Type)
+		} else {
+			g.open("agent")
+			g.open(agent.Type)
+			g.close()
+			g.close()
+		}
+	case agent.Argument != nil:
+		g.open("agent")
+		g.line(agent.Type + " " + g.value(*agent.Argument))
+		g.close()
+	default:
+		g.open("agent")
+		g.open(agent.Type)
+		for _, arg := range agent.Arguments {
+			g.line(arg.Key + " " + g.value(arg.Value))
+		}
+		g.close()
+		g.close()
+	}
+}
+
+func (g *generator) environment(environment []NamedArgument) {
+	if len(environment) == 0 {
+		return
+	}
+	g.open("environment")
+	for _, env := range environment {
+		g.line(env.Key + " = " + g.value(env.Value))
+	}
+	g.close()
+}
+
+func (g *generator) stages(keyword string, stages []Stage) {
+	g.open(keyword)
+	for i := range stages {
+		g.stage(&stages[i])
+	}
+	g.close()
+}
+
+func (g *generator) stage(stage *Stage) {
+	g.open("stage(" + quote(stage.Name) + ")")
+	g.agent(stage.Agent)
+	g.environment(stage.Environment)
+	g.when(stage.When)
+	if stage.FailFast {
+		g.line("failFast true")
+	}
+	switch {
+	case len(stage.Branches)+len(stage.Parallel)+len(stage.Stages) == 0:
+		g.fail("stage '%s' without steps", stage.Name)
+	case len(stage.Branches) > 0 && len(stage.Parallel)+len(stage.Stages) > 0,
+		len(stage.Parallel) > 0 && len(stage.Stages) > 0:
+		g.fail("stage '%s' with more than one of steps, parallel and stages", stage.Name)
+	case len(stage.Branches) > 1:
+		g.fail("stage '%s' with multiple branches", stage.Name)
+	case len(stage.Branches) == 1:
+		g.steps("steps", stage.Branches[0].Steps)
+	case len(stage.Parallel) > 0:
+		g.stages("parallel", stage.Parallel)
+	default:
+		g.stages("stages", stage.Stages)
+	}
+	g.post(stage.Post)
+	g.close()
+}
+
+func (g *generator) steps(keyword string, steps []Step) {
+	if len(steps) == 0 {
+		g.fail("no step in '%s'", keyword)
+	}
+	g.open(keyword)
+	for i := range steps {
+		g.step(&steps[i])
+	}
+	g.close()
+}
+
+func (g *generator) step(step *Step) {
+	if code, ok := scriptCode(step.Arguments); ok && step.Name == "script" {
+		g.code(step.Name, code)
+		return
+	}
+
+	args, positional := g.arguments(step.Name, step.Arguments, stepDefaultKeys)
+	switch {
+	case len(step.Children) > 0:
+		if args != "" {
+			g.open(step.Name + "(" + args + ")")
+		} else {
+			g.open(step.Name)
+		}
+		for i := range step.Children {
+			g.step(&step.Children[i])
+		}
+		g.close()
+	case positional:
+		g.line(step.Name + " " + args)
+	default:
+		g.line(step.Name + "(" + args + ")")
+	}
+}
+
+func (g *generator) when(when *When) {
+	if when == nil {
+		return
+	}
+	if len(when.Conditions) == 0 {
+		g.fail("no condition in 'when'")
+	}
+	g.open("when")
+	if when.BeforeAgent {
+		g.line("beforeAgent true")
+	}
+	for i := range when.Conditions {
+		g.condition(&when.Conditions[i])
+	}
+	g.close()
+}
+
+func (g *generator) condition(condition *Condition) {
+	switch condition.Name {
+	case "expression":
+		code, ok := scriptCode(condition.Arguments)
+		if single := condition.Arguments.Single; !ok && single != nil && !single.IsLiteral {
+			if expression, isString := single.Value.(string); isString && isExpression(expression) {
+				code, ok = expression[2:len(expression)-1], true
+			}
+		}
+		if !ok {
+			g.fail("the expression condition without a script block")
+		}
+		g.code(condition.Name, code)
+	case "not", "allOf", "anyOf":
+		if len(condition.Children) == 0 {
+			g.fail("no condition in '%s'", condition.Name)
+		}
+		g.open(condition.Name)
+		for i := range condition.Children {
+			g.condition(&condition.Children[i])
+		}
+		g.close()
+	default:
+		if args, _ := g.arguments(condition.Name, condition.Arguments, conditionDefaultKeys); args != "" {
+			g.line(condition.Name + " " + args)
+		} else {
+			g.line(condition.Name + "()")
+		}
+	}
+}
+
+func (g *generator) post(post *Post) {
+	if post == nil || len(post.Conditions) == 0 {
+		return
+	}
+	g.open("post")
+	for _, condition := range post.Conditions {
+		if len(condition.Branches) != 1 {
+			g.fail("post condition '%s' should have exactly one branch", condition.Condition)
+			continue
+		}
+		g.steps(condition.Condition, condition.Branches[0].Steps)
+	}
+	g.close()
+}
+
+// arguments returns the arguments separated by commas. It's positional if there is only the single argument or the
+// default argument, so the parentheses can be omitted.
+func (g *generator) arguments(name string, args Arguments, defaultKeys map[string]string) (text string, positional bool) {
+	if args.Single != nil {
+		return g.value(*args.Single), true
+	}
+	if len(args.Named) == 1 && args.Named[0].Key == defaultKeys[name] {
+		return g.value(args.Named[0].Value), true
+	}
+
+	items := make([]string, len(args.Named))
+	for i, arg := range args.Named {
+		key := arg.Key
+		if !isIdentifier(key) {
+			key = quote(key)
+		}
+		items[i] = key + ": " + g.value(arg.Value)
+	}
+	return strings.Join(items, ", "), false
+}
+
+// code writes the Groovy code in a block
+func (g *generator) code(keyword, code string) {
+	g.open(keyword)
+	for _, line := range strings.Split(code, "\n") {
+		if strings.TrimSpace(line) == "" {
+			g.builder.WriteString("\n")
+		} else {
+			g.line(line)
+		}
+	}
+	g.close()
+}
+
+// value returns the Groovy code of a value
+func (g *generator) value(value Value) string {
+	if value.IsLiteral {
+		switch v := value.Value.(type) {
+		case string:
+			return quote(v)
+		case json.Number:
+			return v.String()
+		case float64:
+			return strconv.FormatFloat(v, 'f', -1, 64)
+		case bool:
+			return strconv.FormatBool(v)
+		case nil:
+			return "null"
+		}
+		g.fail("the literal value %v", value.Value)
+		return ""
+	}
+
+	v, ok := value.Value.(string)
+	switch {
+	case !ok:
+		g.fail("the non-literal value %v", value.Value)
+		return ""
+	case isExpression(v):
+		return v[2 : len(v)-1]
+	case strings.Contains(v, "\n"):
+		return `"""` + v + `"""`
+	default:
+		return `"` + v + `"`
+	}
+}
+
+func (g *generator) open(text string) {
+	g.line(text + " {")
+	g.depth++
+}
+
+func (g *generator) close() {
+	g.depth--
+	g.line("}")
+}
+
+func (g *generator) line(text string) {
+	g.builder.WriteString(strings.Repeat(indentUnit, g.depth))
+	g.builder.WriteString(text)
+	g.builder.WriteString("\n")
+}
+
+func (g *generator) fail(format string, args ...interface{}) {
+	if g.err == nil {
+		g.err = &UnsupportedError{Message: fmt.Sprintf(format, args...)}
+	}
+}
+
+// scriptCode returns the code of a script block
+func scriptCode(args Arguments) (string, bool) {
+	if len(args.Named) != 1 || args.Named[0].Key != "scriptBlock" {
+		return "", false
+	}
+	code, ok := args.Named[0].Value.Value.(string)
+	return code, ok
+}
+
+// isExpression returns true if the value is a single expression like "${env.BRANCH_NAME}", instead of a GString
+// like "${env.BRANCH_NAME}-${env.BUILD_NUMBER}"
+func isExpression(value string) bool {
+	if !strings.HasPrefix(value, "${") || !strings.HasSuffix(value, "}") {
+		return false
+	}
+	depth := 1
+	for i := 2; i < len(value); i++ {
+		switch value[i] {
+		case '{':
+			depth++
+		case '}':
+			if depth--; depth == 0 {
+				return i == len(value)-1
+			}
+		}
+	}
+	return false
+}
+
+// quote returns a single-quoted string, or a triple-single-quoted string if there are multiple lines
+func quote(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	if !strings.Contains(value, "\n") {
+		return "'" + strings.ReplaceAll(value, "'", `\'`) + "'"
+	}
+	// a single quote is escaped only if it might be taken as a part of the closing quotes
+	var builder strings.Builder
+	for i := 0; i < len(value); i++ {
+		if value[i] == '\'' && (i == len(value)-1 || value[i+1] == '\'') {
+			builder.WriteByte('\\')
+		}
+		builder.WriteByte(value[i])
+	}
+	return "'''" + builder.String() + "'''"
+}
+
+func isIdentifier(value string) bool {
+	if value == "" || !isIdentStart(value[0]) {
+		return false
+	}
+	for i := 1; i < len(value); i++ {
+		if !isIdentPart(value[i]) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,118 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jenkinsfile
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRoundTrip(t *testing.T) {
+	data, err := ToJSON(sampleJenkinsfile)
+	assert.Nil(t, err)
+	jenkinsfile, err := ToJenkinsfile(data)
+	assert.Nil(t, err)
+	assert.Equal(t, sampleJenkinsfile, jenkinsfile)
+}
+
+func TestToJenkinsfile(t *testing.T) {
+	tests := []struct {
+		name        string
+		data        string
+		want        string
+		unsupported bool
+	}{{
+		name: "the editor format",
+		data: `{"pipeline":{"agent":{"type":"label","argument":{"isLiteral":true,"value":"base"}},"stages":[{"name":"it's \\ ok",
+			"when":{"conditions":[{"name":"not","arguments":[],"children":[{"name":"environment","arguments":[
+				{"key":"name","value":{"isLiteral":true,"value":"SKIP"}},{"key":"value","value":{"isLiteral":true,"value":"true"}}]}]},
+				{"name":"expression","arguments":{"isLiteral":false,"value":"${params.DEPLOY}"}}]},
+			"branches":[{"name":"default","steps":[
+				{"name":"sh","arguments":[{"key":"script","value":{"isLiteral":true,"value":"echo 'a\\b'"}}]},
+				{"name":"retry","arguments":{"isLiteral":true,"value":3},"children":[
+					{"name":"echo","arguments":[{"key":"message","value":{"isLiteral":false,"value":"${env.A}-${env.B}"}}]}]},
+				{"name":"sleep","arguments":[{"key":"time","value":{"isLiteral":true,"value":1}},{"key":"unit-name","value":{"isLiteral":true,"value":"SECONDS"}}]}
+			]}]}]}}`,
+		want: `pipeline {
+  agent {
+    label 'base'
+  }
+  stages {
+    stage('it\'s \\ ok') {
+      when {
+        not {
+          environment name: 'SKIP', value: 'true'
+        }
+        expression {
+          params.DEPLOY
+        }
+      }
+      steps {
+        sh 'echo \'a\\b\''
+        retry(3) {
+          echo "${env.A}-${env.B}"
+        }
+        sleep(time: 1, 'unit-name': 'SECONDS')
+      }
+    }
+  }
+}
+`,
+	}, {
+		name:        "unknown field",
+		data:        `{"pipeline":{"agent":{"type":"any"},"options":[],"stages":[]}}`,
+		unsupported: true,
+	}, {
+		name: "multiple branches",
+		data: `{"pipeline":{"agent":{"type":"any"},"stages":[{"name":"a","branches":[
+			{"name":"x","steps":[{"name":"echo","arguments":{"isLiteral":true,"value":"x"}}]},
+			{"name":"y","steps":[{"name":"echo","arguments":{"isLiteral":true,"value":"y"}}]}]}]}}`,
+		unsupported: true,
+	}, {
+		name:        "invalid JSON",
+		data:        `json`,
+		unsupported: true,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			jenkinsfile, err := ToJenkinsfile(tt.data)
+			assert.Equal(t, tt.want, jenkinsfile)
+			assert.Equal(t, tt.unsupported, IsUnsupported(err))
+			if !tt.unsupported {
+				assert.Nil(t, err)
+				// the generated Jenkinsfile should be parsed as the same definition
+				_, err = ToJSON(jenkinsfile)
+				assert.Nil(t, err)
+			}
+		})
+	}
+}
+
+func TestQuote(t *testing.T) {
+	assert.Equal(t, `'a\'b'`, quote("a'b"))
+	assert.Equal(t, "'''a\nb'''", quote("a\nb"))
+	assert.Equal(t, "'''a\n\\'\\'\\''''", quote("a\n'''"))
+	assert.Equal(t, "'''it's\nok'''", quote("it's\nok"))
+	assert.Equal(t, "'''a\nb\\''''", quote("a\nb'"))
+
+	for _, value := range []string{"a'b", "a\nb", "a\n'''", "a\nb'", `a\b`, "a\n\\'"} {
+		tokens, err := tokenize(quote(value))
+		assert.Nil(t, err)
+		assert.Equal(t, value, tokens[0].value)
+	}
+}
@@ -0,0 +1,195 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package jenkinsfile converts between the declarative Jenkinsfile and the JSON format of the Pipeline editor without
+// Jenkins. Only the subset which the Pipeline editor produces is supported, the callers should fall back to the
+// Jenkins converter when an UnsupportedError is returned.
+package jenkinsfile
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// Value is the value of an argument, the non-literal value is a Groovy expression like "${env.BRANCH_NAME}" or the
+// content of a GString like "hello ${name}"
+type Value struct {
+	IsLiteral bool        `json:"isLiteral"`
+	Value     interface{} `json:"value"`
+}
+
+// NamedArgument is a named argument of a step, an agent or a when condition. It's also used as an environment variable
+type NamedArgument struct {
+	Key   string `json:"key"`
+	Value Value  `json:"value"`
+}
+
+// Arguments are either a single positional value or a list of the named arguments
+type Arguments struct {
+	Single *Value
+	Named  []NamedArgument
+}
+
+// MarshalJSON marshals the arguments as an object or an array
+func (a Arguments) MarshalJSON() ([]byte, error) {
+	if a.Single != nil {
+		return json.Marshal(a.Single)
+	}
+	if a.Named == nil {
+		return []byte("[]"), nil
+	}
+	return json.Marshal(a.Named)
+}
+
+// UnmarshalJSON unmarshals the arguments from an object or an array
+func (a *Arguments) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '{' {
+		a.Single = &Value{}
+		return unmarshalStrictly(data, a.Single)
+	}
+	return unmarshalStrictly(data, &a.Named)
+}
+
+// Step is a step of a stage, the block steps like "container" and "withCredentials" have children
+type Step struct {
+	Name      string    `json:"name"`
+	Arguments Arguments `json:"arguments"`
+	Children  []Step    `json:"children,omitempty"`
+}
+
+// Branch holds the steps of a stage or a post condition
+type Branch struct {
+	Name  string `json:"name"`
+	Steps []Step `json:"steps"`
+}
+
+// Agent is where a Pipeline or a stage runs, like "any", "none", "label" and "node"
+type Agent struct {
+	Type      string          `json:"type"`
+	Argument  *Value          `json:"argument,omitempty"`
+	Arguments []NamedArgument `json:"arguments,omitempty"`
+}
+
+// Condition is a when condition, "not", "allOf" and "anyOf" have the nested conditions as children
+type Condition struct {
+	Name      string      `json:"name"`
+	Arguments Arguments   `json:"arguments"`
+	Children  []Condition `json:"children,omitempty"`
+}
+
+// When decides whether a stage should be executed
+type When struct {
+	Conditions  []Condition `json:"conditions"`
+	BeforeAgent bool        `json:"beforeAgent,omitempty"`
+}
+
+// PostCondition holds the steps which run in a condition like "always" and "failure"
+type PostCondition struct {
+	Condition string   `json:"condition"`
+	Branches  []Branch `json:"branches"`
+}
+
+// Post holds the steps which run at the end of a Pipeline or a stage
+type Post struct {
+	Conditions []PostCondition `json:"conditions"`
+}
+
+// Stage is a stage of a Pipeline, it has either steps in the default branch, parallel stages or sequential stages
+type Stage struct {
+	Name        string          `json:"name"`
+	Agent       *Agent          `json:"agent,omitempty"`
+	Environment []NamedArgument `json:"environment,omitempty"`
+	When        *When           `json:"when,omitempty"`
+	FailFast    bool            `json:"failFast,omitempty"`
+	Branches    []Branch        `json:"branches,omitempty"`
+	Parallel    []Stage         `json:"parallel,omitempty"`
+	Stages      []Stage         `json:"stages,omitempty"`
+	Post        *Post           `json:"post,omitempty"`
+}
+
+// Pipeline is a declarative Pipeline
+type Pipeline struct {
+	Agent       *Agent          `json:"agent,omitempty"`
+	Environment []NamedArgument `json:"environment,omitempty"`
+	Stages      []Stage         `json:"stages"`
+	Post        *Post           `json:"post,omitempty"`
+}
+
+// Definition is the JSON format of a Jenkinsfile
+type Definition struct {
+	Pipeline Pipeline `json:"pipeline"`
+}
+
+// SyntaxError is an error in a Jenkinsfile, Jenkins doesn't accept it either
+type SyntaxError struct {
+	Line    int
+	Column  int
+	Message string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("line %d, column %d: %s", e.Line, e.Column, e.Message)
+}
+
+// UnsupportedError means the Jenkinsfile or the JSON might be valid, but it's out of the supported subset
+type UnsupportedError struct {
+	// Line and Column are the position in the Jenkinsfile, they're zero when converting the JSON
+	Line    int
+	Column  int
+	Message string
+}
+
+func (e *UnsupportedError) Error() string {
+	if e.Line == 0 {
+		return fmt.Sprintf("unsupported: %s", e.Message)
+	}
+	return fmt.Sprintf("line %d, column %d: unsupported: %s", e.Line, e.Column, e.Message)
+}
+
+// IsUnsupported returns true if the error is an UnsupportedError
+func IsUnsupported(err error) bool {
+	var unsupported *UnsupportedError
+	return errors.As(err, &unsupported)
+}
+
+// ToJSON converts a declarative Jenkinsfile to the JSON format
+func ToJSON(jenkinsfile string) (string, error) {
+	definition, err := Parse(jenkinsfile)
+	if err != nil {
+		return "", err
+	}
+	data, err := json.Marshal(definition)
+	return string(data), err
+}
+
+// ToJenkinsfile converts the JSON format to a declarative Jenkinsfile. Any unknown field is reported as an
+// UnsupportedError, so that the Jenkins converter can take over.
+func ToJenkinsfile(data string) (string, error) {
+	definition := &Definition{}
+	if err := unmarshalStrictly([]byte(data), definition); err != nil {
+		return "", &UnsupportedError{Message: err.Error()}
+	}
+	return Generate(definition)
+}
+
+func unmarshalStrictly(data []byte, v interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	decoder.UseNumber()
+	return decoder.Decode(v)
+}
@@ -0,0 +1,425 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jenkinsfile
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	// tokenNewline is a line break or a semicolon, both of them end a statement
+	tokenNewline
+	tokenIdent
+	// tokenString is a string without interpolation
+	tokenString
+	// tokenGString is a double-quoted, slashy or dollar-slashy string with interpolation
+	tokenGString
+	tokenNumber
+	tokenPunct
+)
+
+type token struct {
+	kind tokenKind
+	// text is the source text of the token
+	text string
+	// value is the decoded value of a string, or the content of a GString
+	value      string
+	start, end int
+}
+
+// operators are the multi-character operators, the longer ones go first
+var operators = []string{
+	"==~", "<=>", "...", "==", "!=", "=~", "<=", ">=", "&&", "||", "->", "?.", "?:", "..", "<<", ">>", "++", "--",
+	"**", "*.", "+=", "-=", "*=", "/=",
+}
+
+// groovyBlocks are the blocks of Groovy code, the errors in them are left to Jenkins
+var groovyBlocks = map[string]bool{"script": true, "expression": true}
+
+type lexer struct {
+	src    string
+	pos    int
+	tokens []token
+	// blocks tells if each of the open braces starts a block of Groovy code
+	blocks []bool
+	// groovyDepth is the number of the open blocks of Groovy code
+	groovyDepth int
+}
+
+// tokenize splits a Jenkinsfile into tokens, the comments are dropped. The errors in the blocks of Groovy code are
+// UnsupportedErrors, since the lexer doesn't know all the Groovy syntax.
+func tokenize(src string) ([]token, error) {
+	l := &lexer{src: src}
+	for {
+		if err := l.skipSpacesAndComments(); err != nil {
+			return nil, err
+		}
+		if l.pos >= len(l.src) {
+			l.tokens = append(l.tokens, token{kind: tokenEOF, start: l.pos, end: l.pos})
+			return l.tokens, nil
+		}
+
+		start := l.pos
+		c := l.src[l.pos]
+		var err error
+		switch {
+		case c == '\n' || c == ';':
+			l.pos++
+			l.emit(tokenNewline, start, "")
+		case c == '\'' || c == '"':
+			err = l.lexString()
+		case strings.HasPrefix(l.src[l.pos:], "$/"):
+			err = l.lexDollarSlashyString()
+		case c == '/' && l.expectsValue():
+			err = l.lexSlashyString()
+		case isIdentStart(c):
+			for l.pos < len(l.src) && isIdentPart(l.src[l.pos]) {
+				l.pos++
+			}
+			l.emit(tokenIdent, start, "")
+		case c >= '0' && c <= '9':
+			l.lexNumber()
+		case c >= utf8.RuneSelf:
+			r, size := utf8.DecodeRuneInString(l.src[l.pos:])
+			if !unicode.IsLetter(r) {
+				return nil, l.errorf(start, "unexpected character %q", r)
+			}
+			l.pos += size
+			for l.pos < len(l.src) && isIdentPart(l.src[l.pos]) {
+				l.pos++
+			}
+			l.emit(tokenIdent, start, "")
+		default:
+			l.lexPunct()
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (l *lexer) emit(kind tokenKind, start int, value string) {
+	l.tokens = append(l.tokens, token{kind: kind, text: l.src[start:l.pos], value: value, start: start, end: l.pos})
+}
+
+// errorf returns a SyntaxError, or an UnsupportedError in a block of Groovy code
+func (l *lexer) errorf(offset int, format string, args ...interface{}) error {
+	if l.groovyDepth > 0 {
+		return newUnsupportedError(l.src, offset, format+" in the Groovy code", args...)
+	}
+	return newSyntaxError(l.src, offset, format, args...)
+}
+
+// expectsValue returns true if a value is expected rather than an operator, so a slash starts a slashy string
+// instead of a division
+func (l *lexer) expectsValue() bool {
+	if len(l.tokens) == 0 {
+		return true
+	}
+	last := l.tokens[len(l.tokens)-1]
+	switch last.kind {
+	case tokenNewline:
+		return true
+	case tokenPunct:
+		return last.text != ")" && last.text != "]" && last.text != "}"
+	case tokenIdent:
+		return last.text == "return"
+	}
+	return false
+}
+
+// trackBlock records if the brace of the last token opens or closes a block of Groovy code
+func (l *lexer) trackBlock() {
+	switch l.tokens[len(l.tokens)-1].text {
+	case "{":
+		groovy := false
+		for i := len(l.tokens) - 2; i >= 0; i-- {
+			if l.tokens[i].kind != tokenNewline {
+				groovy = l.tokens[i].kind == tokenIdent && groovyBlocks[l.tokens[i].text]
+				break
+			}
+		}
+		if groovy {
+			l.groovyDepth++
+		}
+		l.blocks = append(l.blocks, groovy)
+	case "}":
+		if n := len(l.blocks); n > 0 {
+			if l.blocks[n-1] {
+				l.groovyDepth--
+			}
+			l.blocks = l.blocks[:n-1]
+		}
+	}
+}
+
+func (l *lexer) skipSpacesAndComments() error {
+	for l.pos < len(l.src) {
+		switch {
+		case l.src[l.pos] == ' ' || l.src[l.pos] == '\t' || l.src[l.pos] == '\r':
+			l.pos++
+		case strings.HasPrefix(l.src[l.pos:], "\\\n"):
+			l.pos += 2
+		case strings.HasPrefix(l.src[l.pos:], "//"):
+			if end := strings.IndexByte(l.src[l.pos:], '\n'); end >= 0 {
+				l.pos += end
+			} else {
+				l.pos = len(l.src)
+			}
+		case strings.HasPrefix(l.src[l.pos:], "/*"):
+			end := strings.Index(l.src[l.pos+2:], "*/")
+			if end < 0 {
+				return l.errorf(l.pos, "unterminated comment")
+			}
+			l.pos += end + 4
+		default:
+			return nil
+		}
+	}
+	return nil
+}
+
+func (l *lexer) lexNumber() {
+	start := l.pos
+	for l.pos < len(l.src) && l.src[l.pos] >= '0' && l.src[l.pos] <= '9' {
+		l.pos++
+	}
+	if l.pos+1 < len(l.src) && l.src[l.pos] == '.' && l.src[l.pos+1] >= '0' && l.src[l.pos+1] <= '9' {
+		l.pos++
+		for l.pos < len(l.src) && l.src[l.pos] >= '0' && l.src[l.pos] <= '9' {
+			l.pos++
+		}
+	}
+	// the suffixes like 10L and 1.5G
+	for l.pos < len(l.src) && isIdentPart(l.src[l.pos]) {
+		l.pos++
+	}
+	l.emit(tokenNumber, start, "")
+}
+
+func (l *lexer) lexPunct() {
+	start := l.pos
+	for _, op := range operators {
+		if strings.HasPrefix(l.src[l.pos:], op) {
+			l.pos += len(op)
+			l.emit(tokenPunct, start, "")
+			return
+		}
+	}
+	l.pos++
+	l.emit(tokenPunct, start, "")
+	l.trackBlock()
+}
+
+// lexString lexes the single-quoted, double-quoted and triple-quoted strings. A double-quoted string with
+// interpolation is a GString, its value is the raw content, so it can be written back as it is.
+func (l *lexer) lexString() error {
+	start := l.pos
+	quote := l.src[l.pos : l.pos+1]
+	if strings.HasPrefix(l.src[l.pos:], strings.Repeat(quote, 3)) {
+		quote = strings.Repeat(quote, 3)
+	}
+	l.pos += len(quote)
+	contentStart := l.pos
+
+	var value strings.Builder
+	interpolated := false
+	for {
+		if l.pos >= len(l.src) || (len(quote) == 1 && l.src[l.pos] == '\n') {
+			return l.errorf(start, "unterminated string")
+		}
+		if strings.HasPrefix(l.src[l.pos:], quote) {
+			break
+		}
+
+		c := l.src[l.pos]
+		switch {
+		case c == '\\' && l.pos+1 < len(l.src):
+			l.pos++
+			escaped := l.src[l.pos]
+			l.pos++
+			switch escaped {
+			case 'n':
+				value.WriteByte('\n')
+			case 't':
+				value.WriteByte('\t')
+			case 'r':
+				value.WriteByte('\r')
+			case 'b':
+				value.WriteByte('\b')
+			case 'f':
+				value.WriteByte('\f')
+			case '\n':
+				// a line continuation
+			default:
+				value.WriteByte(escaped)
+			}
+		case quote[0] == '"' && l.isInterpolation():
+			interpolated = true
+			if err := l.lexInterpolation(&value); err != nil {
+				return err
+			}
+		default:
+			value.WriteByte(c)
+			l.pos++
+		}
+	}
+	content := l.src[contentStart:l.pos]
+	l.pos += len(quote)
+
+	l.emitString(start, content, value.String(), interpolated)
+	return nil
+}
+
+// lexSlashyString lexes a slashy string like /a"b/, which is usually a regular expression. Only the slash could be
+// escaped, the other backslashes are kept.
+func (l *lexer) lexSlashyString() error {
+	start := l.pos
+	l.pos++
+	contentStart := l.pos
+
+	var value strings.Builder
+	interpolated := false
+	for {
+		if l.pos >= len(l.src) {
+			return l.errorf(start, "unterminated slashy string")
+		}
+		c := l.src[l.pos]
+		if c == '/' {
+			break
+		}
+		switch {
+		case strings.HasPrefix(l.src[l.pos:], "\\/"):
+			value.WriteByte('/')
+			l.pos += 2
+		case l.isInterpolation():
+			interpolated = true
+			if err := l.lexInterpolation(&value); err != nil {
+				return err
+			}
+		default:
+			value.WriteByte(c)
+			l.pos++
+		}
+	}
+	content := l.src[contentStart:l.pos]
+	l.pos++
+
+	l.emitString(start, content, value.String(), interpolated)
+	return nil
+}
+
+// lexDollarSlashyString lexes a dollar-slashy string like $/a/b/$, the dollar sign is the escape character
+func (l *lexer) lexDollarSlashyString() error {
+	start := l.pos
+	l.pos += 2
+	contentStart := l.pos
+
+	var value strings.Builder
+	interpolated := false
+	for {
+		if l.pos >= len(l.src) {
+			return l.errorf(start, "unterminated dollar-slashy string")
+		}
+		if strings.HasPrefix(l.src[l.pos:], "/$") {
+			break
+		}
+		switch {
+		case strings.HasPrefix(l.src[l.pos:], "$$"), strings.HasPrefix(l.src[l.pos:], "$/"):
+			value.WriteByte(l.src[l.pos+1])
+			l.pos += 2
+		case l.isInterpolation():
+			interpolated = true
+			if err := l.lexInterpolation(&value); err != nil {
+				return err
+			}
+		default:
+			value.WriteByte(l.src[l.pos])
+			l.pos++
+		}
+	}
+	content := l.src[contentStart:l.pos]
+	l.pos += 2
+
+	l.emitString(start, content, value.String(), interpolated)
+	return nil
+}
+
+// isInterpolation returns true if there is an interpolation like $name or ${expression} at the position
+func (l *lexer) isInterpolation() bool {
+	return l.src[l.pos] == '$' && l.pos+1 < len(l.src) && (l.src[l.pos+1] == '{' || isIdentStart(l.src[l.pos+1]))
+}
+
+// lexInterpolation writes an interpolation into the value as it is
+func (l *lexer) lexInterpolation(value *strings.Builder) error {
+	if l.src[l.pos+1] == '{' {
+		end := strings.IndexByte(l.src[l.pos:], '}')
+		if end < 0 {
+			return l.errorf(l.pos, "unterminated string interpolation")
+		}
+		value.WriteString(l.src[l.pos : l.pos+end+1])
+		l.pos += end + 1
+	} else {
+		value.WriteByte(l.src[l.pos])
+		l.pos++
+	}
+	return nil
+}
+
+// emitString emits a GString with the raw content if it is interpolated, or a string with the decoded value
+func (l *lexer) emitString(start int, content, value string, interpolated bool) {
+	if interpolated {
+		l.emit(tokenGString, start, content)
+	} else {
+		l.emit(tokenString, start, value)
+	}
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || c == '$' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isIdentPart(c byte) bool {
+	return isIdentStart(c) || (c >= '0' && c <= '9') || c >= utf8.RuneSelf
+}
+
+// position returns the line and column of an offset, both of them start from 1
+func position(src string, offset int) (line, column int) {
+	if offset > len(src) {
+		offset = len(src)
+	}
+	line = strings.Count(src[:offset], "\n") + 1
+	lineStart := strings.LastIndexByte(src[:offset], '\n') + 1
+	column = utf8.RuneCountInString(src[lineStart:offset]) + 1
+	return
+}
+
+func newSyntaxError(src string, offset int, format string, args ...interface{}) *SyntaxError {
+	line, column := position(src, offset)
+	return &SyntaxError{Line: line, Column: column, Message: fmt.Sprintf(format, args...)}
+}
+
+func newUnsupportedError(src string, offset int, format string, args ...interface{}) *UnsupportedError {
+	line, column := position(src, offset)
+	return &UnsupportedError{Line: line, Column: column, Message: fmt.Sprintf(format, args...)}
+}
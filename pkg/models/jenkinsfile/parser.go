@@ -0,0 +1,698 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jenkinsfile
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// stepDefaultKeys are the keys of the single positional argument of some steps, for example, "sh 'make'" is the same
+// as "sh script: 'make'"
+var stepDefaultKeys = map[string]string{
+	"sh":         "script",
+	"bat":        "script",
+	"powershell": "script",
+	"pwsh":       "script",
+	"echo":       "message",
+	"error":      "message",
+}
+
+// conditionDefaultKeys are the keys of the single positional argument of some when conditions
+var conditionDefaultKeys = map[string]string{
+	"branch":    "pattern",
+	"tag":       "pattern",
+	"changelog": "pattern",
+	"changeset": "pattern",
+}
+
+var postConditions = map[string]bool{
+	"always": true, "changed": true, "fixed": true, "regression": true, "aborted": true, "failure": true,
+	"success": true, "unstable": true, "unsuccessful": true, "notBuilt": true, "cleanup": true,
+}
+
+// unsupportedDirectives are valid in a declarative Pipeline, but out of the supported subset
+var unsupportedDirectives = map[string]bool{
+	"options": true, "parameters": true, "triggers": true, "tools": true, "libraries": true, "input": true,
+	"matrix": true, "beforeInput": true, "beforeOptions": true,
+}
+
+type parser struct {
+	src    string
+	tokens []token
+	pos    int
+}
+
+// Parse parses a declarative Jenkinsfile. A SyntaxError is returned if the Jenkinsfile is invalid, and an
+// UnsupportedError is returned if the Jenkinsfile is out of the supported subset, like a scripted Pipeline.
+func Parse(jenkinsfile string) (*Definition, error) {
+	tokens, err := tokenize(jenkinsfile)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{src: jenkinsfile, tokens: tokens}
+	return p.parseDefinition()
+}
+
+func (p *parser) parseDefinition() (*Definition, error) {
+	p.skipNewlines()
+	keyword := p.next()
+	if keyword.kind != tokenIdent || keyword.text != "pipeline" {
+		return nil, p.unsupportedf(keyword, "only the declarative Pipeline is supported, it should start with 'pipeline {'")
+	}
+
+	definition := &Definition{}
+	pipeline := &definition.Pipeline
+	seen := map[string]bool{}
+	_, err := p.parseBlock(func(t token) (err error) {
+		if err = p.checkDirective(t, seen); err != nil {
+			return
+		}
+		switch t.text {
+		case "agent":
+			pipeline.Agent, err = p.parseAgent()
+		case "environment":
+			pipeline.Environment, err = p.parseEnvironment()
+		case "stages":
+			pipeline.Stages, err = p.parseStages()
+		case "post":
+			pipeline.Post, err = p.parsePost()
+		default:
+			err = p.unknownDirective(t)
+		}
+		return
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	p.skipNewlines()
+	if t := p.peek(); t.kind != tokenEOF {
+		return nil, p.errorf(t, "unexpected %s after the pipeline block", describe(t))
+	}
+	if pipeline.Agent == nil {
+		return nil, p.errorf(keyword, "missing 'agent' in the pipeline")
+	}
+	if pipeline.Stages == nil {
+		return nil, p.errorf(keyword, "missing 'stages' in the pipeline")
+	}
+	return definition, nil
+}
+
+func (p *parser) parseAgent() (*Agent, error) {
+	if t := p.peek(); t.kind == tokenIdent && (t.text == "any" || t.text == "none") {
+		p.next()
+		return &Agent{Type: t.text}, nil
+	}
+	if t := p.peek(); !p.isPunct("{") {
+		return nil, p.errorf(t, "expected 'any', 'none' or '{' after 'agent', got %s", describe(t))
+	}
+
+	agent := &Agent{}
+	open, err := p.parseBlock(func(t token) error {
+		p.next()
+		if t.kind != tokenIdent {
+			return p.errorf(t, "expected the agent type, got %s", describe(t))
+		}
+		if agent.Type != "" {
+			return p.errorf(t, "only one agent type is allowed, got '%s' after '%s'", t.text, agent.Type)
+		}
+		agent.Type = t.text
+
+		if !p.isPunct("{") {
+			value, err := p.parseValue(false)
+			agent.Argument = &value
+			return err
+		}
+		_, err := p.parseBlock(func(t token) error {
+			p.next()
+			if t.kind != tokenIdent {
+				return p.errorf(t, "expected an option of the agent, got %s", describe(t))
+			}
+			if p.isPunct("{") {
+				return p.unsupportedf(t, "the nested block '%s' of the agent", t.text)
+			}
+			value, err := p.parseValue(false)
+			agent.Arguments = append(agent.Arguments, NamedArgument{Key: t.text, Value: value})
+			return err
+		})
+		return err
+	})
+	if err == nil && agent.Type == "" {
+		err = p.errorf(open, "missing the agent type")
+	}
+	return agent, err
+}
+
+func (p *parser) parseEnvironment() (environment []NamedArgument, err error) {
+	environment = []NamedArgument{}
+	_, err = p.parseBlock(func(t token) error {
+		p.next()
+		if t.kind != tokenIdent {
+			return p.errorf(t, "expected the name of an environment variable, got %s", describe(t))
+		}
+		if _, err := p.expectPunct("="); err != nil {
+			return err
+		}
+		value, err := p.parseValue(false)
+		environment = append(environment, NamedArgument{Key: t.text, Value: value})
+		return err
+	})
+	return
+}
+
+func (p *parser) parseStages() (stages []Stage, err error) {
+	stages = []Stage{}
+	var open token
+	open, err = p.parseBlock(func(t token) error {
+		p.next()
+		if t.kind != tokenIdent || t.text != "stage" {
+			return p.errorf(t, "expected 'stage', got %s", describe(t))
+		}
+		stage, err := p.parseStage(t)
+		if err == nil {
+			stages = append(stages, *stage)
+		}
+		return err
+	})
+	if err == nil && len(stages) == 0 {
+		err = p.errorf(open, "no stage in the block")
+	}
+	return
+}
+
+func (p *parser) parseStage(keyword token) (*Stage, error) {
+	if _, err := p.expectPunct("("); err != nil {
+		return nil, err
+	}
+	name := p.next()
+	switch name.kind {
+	case tokenString:
+	case tokenGString:
+		return nil, p.unsupportedf(name, "the stage name with interpolation")
+	default:
+		return nil, p.errorf(name, "expected the stage name, got %s", describe(name))
+	}
+	if _, err := p.expectPunct(")"); err != nil {
+		return nil, err
+	}
+
+	stage := &Stage{Name: name.value}
+	seen := map[string]bool{}
+	_, err := p.parseBlock(func(t token) (err error) {
+		if err = p.checkDirective(t, seen); err != nil {
+			return
+		}
+		switch t.text {
+		case "agent":
+			stage.Agent, err = p.parseAgent()
+		case "environment":
+			stage.Environment, err = p.parseEnvironment()
+		case "when":
+			stage.When, err = p.parseWhen()
+		case "failFast":
+			stage.FailFast, err = p.parseBool(t)
+		case "steps":
+			var steps []Step
+			if steps, err = p.parseSteps(); err == nil {
+				stage.Branches = []Branch{{Name: "default", Steps: steps}}
+			}
+		case "parallel":
+			stage.Parallel, err = p.parseStages()
+		case "stages":
+			stage.Stages, err = p.parseStages()
+		case "post":
+			stage.Post, err = p.parsePost()
+		default:
+			err = p.unknownDirective(t)
+		}
+		return
+	})
+	if err != nil {
+		return nil, err
+	}
+	count := 0
+	for _, directive := range []string{"steps", "parallel", "stages"} {
+		if seen[directive] {
+			count++
+		}
+	}
+	if count != 1 {
+		return nil, p.errorf(keyword, "stage '%s' should have exactly one of 'steps', 'parallel' and 'stages'", stage.Name)
+	}
+	return stage, nil
+}
+
+func (p *parser) parseSteps() (steps []Step, err error) {
+	steps = []Step{}
+	var open token
+	open, err = p.parseBlock(func(t token) error {
+		step, err := p.parseStep()
+		if err == nil {
+			steps = append(steps, *step)
+		}
+		return err
+	})
+	if err == nil && len(steps) == 0 {
+		err = p.errorf(open, "no step in the block")
+	}
+	return
+}
+
+func (p *parser) parseStep() (*Step, error) {
+	name := p.next()
+	if name.kind != tokenIdent {
+		return nil, p.errorf(name, "expected a step, got %s", describe(name))
+	}
+	if next := p.peek(); name.text == "def" || (next.kind == tokenPunct && next.text != "(" && next.text != "{" &&
+		next.text != "}" && next.text != "[") {
+		return nil, p.unsupportedf(name, "the Groovy statements out of a 'script' block")
+	}
+
+	if name.text == "script" {
+		code, err := p.parseCode()
+		if err != nil {
+			return nil, err
+		}
+		return &Step{Name: name.text, Arguments: scriptBlock(code)}, nil
+	}
+
+	step := &Step{Name: name.text}
+	var err error
+	if step.Arguments, err = p.parseArguments(name.text, stepDefaultKeys); err != nil {
+		return nil, err
+	}
+	if p.isPunct("{") {
+		if step.Children, err = p.parseSteps(); err != nil {
+			return nil, err
+		}
+	}
+	return step, nil
+}
+
+func (p *parser) parseWhen() (*When, error) {
+	when := &When{Conditions: []Condition{}}
+	open, err := p.parseBlock(func(t token) (err error) {
+		if t.kind == tokenIdent && t.text == "beforeAgent" {
+			p.next()
+			when.BeforeAgent, err = p.parseBool(t)
+			return
+		}
+		var condition *Condition
+		if condition, err = p.parseCondition(); err == nil {
+			when.Conditions = append(when.Conditions, *condition)
+		}
+		return
+	})
+	if err == nil && len(when.Conditions) == 0 {
+		err = p.errorf(open, "no condition in 'when'")
+	}
+	return when, err
+}
+
+func (p *parser) parseCondition() (*Condition, error) {
+	name := p.next()
+	if name.kind != tokenIdent {
+		return nil, p.errorf(name, "expected a condition, got %s", describe(name))
+	}
+	if unsupportedDirectives[name.text] {
+		return nil, p.unsupportedf(name, "'%s'", name.text)
+	}
+
+	condition := &Condition{Name: name.text}
+	switch name.text {
+	case "expression":
+		code, err := p.parseCode()
+		if err != nil {
+			return nil, err
+		}
+		condition.Arguments = scriptBlock(code)
+	case "not", "allOf", "anyOf":
+		condition.Children = []Condition{}
+		open, err := p.parseBlock(func(t token) error {
+			child, err := p.parseCondition()
+			if err == nil {
+				condition.Children = append(condition.Children, *child)
+			}
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		if name.text == "not" && len(condition.Children) != 1 {
+			return nil, p.errorf(open, "'not' should have exactly one condition")
+		} else if len(condition.Children) == 0 {
+			return nil, p.errorf(open, "no condition in '%s'", name.text)
+		}
+	default:
+		var err error
+		if condition.Arguments, err = p.parseArguments(name.text, conditionDefaultKeys); err != nil {
+			return nil, err
+		}
+		if t := p.peek(); p.isPunct("{") {
+			return nil, p.unsupportedf(t, "the block of the condition '%s'", name.text)
+		}
+	}
+	return condition, nil
+}
+
+func (p *parser) parsePost() (*Post, error) {
+	post := &Post{Conditions: []PostCondition{}}
+	seen := map[string]bool{}
+	_, err := p.parseBlock(func(t token) error {
+		p.next()
+		if t.kind != tokenIdent || !postConditions[t.text] {
+			return p.errorf(t, "expected a post condition, got %s", describe(t))
+		}
+		if seen[t.text] {
+			return p.errorf(t, "duplicate post condition '%s'", t.text)
+		}
+		seen[t.text] = true
+		steps, err := p.parseSteps()
+		post.Conditions = append(post.Conditions, PostCondition{
+			Condition: t.text,
+			Branches:  []Branch{{Name: "default", Steps: steps}},
+		})
+		return err
+	})
+	return post, err
+}
+
+// parseArguments parses the arguments in parentheses, or the arguments without parentheses till the end of line
+func (p *parser) parseArguments(name string, defaultKeys map[string]string) (args Arguments, err error) {
+	parenthesized := p.isPunct("(")
+	if parenthesized {
+		p.next()
+		p.skipNewlines()
+		if p.isPunct(")") {
+			p.next()
+			return
+		}
+	} else if t := p.peek(); t.kind == tokenNewline || t.kind == tokenEOF || p.isPunct("}") || p.isPunct("{") {
+		return
+	}
+
+	start := p.peek()
+	var positional []Value
+	for {
+		t := p.peek()
+		if next := p.peekAt(1); (t.kind == tokenIdent || t.kind == tokenString) && next.kind == tokenPunct && next.text == ":" {
+			p.next()
+			p.next()
+			key := t.text
+			if t.kind == tokenString {
+				key = t.value
+			}
+			var value Value
+			if value, err = p.parseValue(parenthesized); err != nil {
+				return
+			}
+			args.Named = append(args.Named, NamedArgument{Key: key, Value: value})
+		} else {
+			var value Value
+			if value, err = p.parseValue(parenthesized); err != nil {
+				return
+			}
+			positional = append(positional, value)
+		}
+
+		if !p.isPunct(",") {
+			break
+		}
+		p.next()
+		p.skipNewlines()
+	}
+	if parenthesized {
+		p.skipNewlines()
+		if _, err = p.expectPunct(")"); err != nil {
+			return
+		}
+	}
+
+	switch {
+	case len(positional) == 0:
+	case len(positional) == 1 && len(args.Named) == 0:
+		if key, ok := defaultKeys[name]; ok {
+			args.Named = []NamedArgument{{Key: key, Value: positional[0]}}
+		} else {
+			args.Single = &positional[0]
+		}
+	default:
+		err = p.unsupportedf(start, "multiple positional arguments of '%s'", name)
+	}
+	return
+}
+
+// parseValue parses a value till the next comma, or the end of the arguments. A single string, number or boolean is
+// a literal, the others are Groovy expressions.
+func (p *parser) parseValue(parenthesized bool) (value Value, err error) {
+	first := p.pos
+	depth := 0
+loop:
+	for {
+		t := p.peek()
+		switch {
+		case t.kind == tokenEOF:
+			break loop
+		case t.kind == tokenNewline:
+			if depth == 0 && !parenthesized {
+				break loop
+			}
+		case t.kind == tokenPunct && depth == 0 && t.text == ",":
+			break loop
+		case t.kind == tokenPunct && (t.text == "(" || t.text == "[" || t.text == "{"):
+			if depth == 0 && !parenthesized && t.text == "{" {
+				if p.pos == first {
+					return value, p.unsupportedf(t, "the closure out of parentheses")
+				}
+				break loop
+			}
+			depth++
+		case t.kind == tokenPunct && (t.text == ")" || t.text == "]" || t.text == "}"):
+			if depth == 0 {
+				break loop
+			}
+			depth--
+		}
+		p.next()
+	}
+
+	var tokens []token
+	for _, t := range p.tokens[first:p.pos] {
+		if t.kind != tokenNewline {
+			tokens = append(tokens, t)
+		}
+	}
+	if len(tokens) == 0 {
+		t := p.peek()
+		return value, p.errorf(t, "expected a value, got %s", describe(t))
+	}
+
+	if len(tokens) == 1 {
+		t := tokens[0]
+		switch {
+		case t.kind == tokenString:
+			return Value{IsLiteral: true, Value: t.value}, nil
+		case t.kind == tokenGString:
+			return Value{IsLiteral: false, Value: t.value}, nil
+		case t.kind == tokenNumber && isPlainNumber(t.text):
+			return Value{IsLiteral: true, Value: json.Number(t.text)}, nil
+		case t.kind == tokenIdent && (t.text == "true" || t.text == "false"):
+			return Value{IsLiteral: true, Value: t.text == "true"}, nil
+		}
+	}
+	expression := p.src[tokens[0].start:tokens[len(tokens)-1].end]
+	return Value{IsLiteral: false, Value: "${" + expression + "}"}, nil
+}
+
+func (p *parser) parseBool(keyword token) (bool, error) {
+	value, err := p.parseValue(false)
+	if err != nil {
+		return false, err
+	}
+	b, ok := value.Value.(bool)
+	if !value.IsLiteral || !ok {
+		return false, p.errorf(keyword, "'%s' should be true or false", keyword.text)
+	}
+	return b, nil
+}
+
+// parseCode returns the Groovy code in a block, like the block of the "script" step
+func (p *parser) parseCode() (string, error) {
+	p.skipNewlines()
+	open, err := p.expectPunct("{")
+	if err != nil {
+		return "", err
+	}
+	for depth := 1; ; {
+		t := p.next()
+		switch {
+		case t.kind == tokenEOF:
+			return "", p.errorf(open, "missing '}' of the block")
+		case t.kind == tokenPunct && t.text == "{":
+			depth++
+		case t.kind == tokenPunct && t.text == "}":
+			if depth--; depth == 0 {
+				return dedent(p.src[open.end:t.start]), nil
+			}
+		}
+	}
+}
+
+// parseBlock parses the statements of a block, the body is called with the first token of each statement.
+// It returns the opening brace of the block.
+func (p *parser) parseBlock(body func(t token) error) (open token, err error) {
+	p.skipNewlines()
+	if open, err = p.expectPunct("{"); err != nil {
+		return
+	}
+	for {
+		p.skipNewlines()
+		t := p.peek()
+		switch {
+		case p.isPunct("}"):
+			p.next()
+			return
+		case t.kind == tokenEOF:
+			err = p.errorf(open, "missing '}' of the block")
+			return
+		}
+		if err = body(t); err != nil {
+			return
+		}
+		if t = p.peek(); t.kind != tokenNewline && t.kind != tokenEOF && !p.isPunct("}") {
+			err = p.errorf(t, "expected the end of line, got %s", describe(t))
+			return
+		}
+	}
+}
+
+// checkDirective consumes the name of a directive, and makes sure it's not duplicated
+func (p *parser) checkDirective(t token, seen map[string]bool) error {
+	p.next()
+	if t.kind != tokenIdent {
+		return p.errorf(t, "expected a directive, got %s", describe(t))
+	}
+	if seen[t.text] {
+		return p.errorf(t, "duplicate '%s'", t.text)
+	}
+	seen[t.text] = true
+	return nil
+}
+
+func (p *parser) unknownDirective(t token) error {
+	if unsupportedDirectives[t.text] {
+		return p.unsupportedf(t, "the directive '%s'", t.text)
+	}
+	return p.errorf(t, "unknown directive '%s'", t.text)
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) peekAt(n int) token {
+	if p.pos+n < len(p.tokens) {
+		return p.tokens[p.pos+n]
+	}
+	return p.tokens[len(p.tokens)-1]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) skipNewlines() {
+	for p.peek().kind == tokenNewline {
+		p.pos++
+	}
+}
+
+func (p *parser) isPunct(text string) bool {
+	t := p.peek()
+	return t.kind == tokenPunct && t.text == text
+}
+
+func (p *parser) expectPunct(text string) (token, error) {
+	if t := p.peek(); t.kind != tokenPunct || t.text != text {
+		return t, p.errorf(t, "expected '%s', got %s", text, describe(t))
+	}
+	return p.next(), nil
+}
+
+func (p *parser) errorf(t token, format string, args ...interface{}) error {
+	return newSyntaxError(p.src, t.start, format, args...)
+}
+
+func (p *parser) unsupportedf(t token, format string, args ...interface{}) error {
+	return newUnsupportedError(p.src, t.start, format, args...)
+}
+
+func describe(t token) string {
+	switch t.kind {
+	case tokenEOF:
+		return "the end of file"
+	case tokenNewline:
+		return "the end of line"
+	default:
+		return fmt.Sprintf("'%s'", t.text)
+	}
+}
+
+func scriptBlock(code string) Arguments {
+	return Arguments{Named: []NamedArgument{{Key: "scriptBlock", Value: Value{IsLiteral: true, Value: code}}}}
+}
+
+func isPlainNumber(text string) bool {
+	_, err := strconv.ParseFloat(text, 64)
+	return err == nil
+}
+
+// dedent removes the blank lines around the code, and the common indentation of the lines
+func dedent(code string) string {
+	lines := strings.Split(strings.ReplaceAll(code, "\r\n", "\n"), "\n")
+	for len(lines) > 0 && strings.TrimSpace(lines[0]) == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	indent := -1
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if n := len(line) - len(strings.TrimLeft(line, " \t")); indent < 0 || n < indent {
+			indent = n
+		}
+	}
+	for i, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			lines[i] = ""
+		} else {
+			lines[i] = line[indent:]
+		}
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
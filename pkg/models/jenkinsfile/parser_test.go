@@ -0,0 +1,300 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jenkinsfile
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const sampleJenkinsfile = `pipeline {
+  agent {
+    node {
+      label 'maven'
+    }
+  }
+  environment {
+    REGISTRY = 'docker.io'
+    TAG = "v${BUILD_NUMBER}"
+  }
+  stages {
+    stage('checkout') {
+      steps {
+        git(url: 'https://github.com/kubesphere/devops-maven-sample.git', branch: 'master', changelog: true)
+      }
+    }
+    stage('build') {
+      parallel {
+        stage('unit test') {
+          steps {
+            container('maven') {
+              sh 'mvn test'
+            }
+          }
+        }
+        stage('package') {
+          steps {
+            sh '''mvn clean package
+ls target'''
+            withCredentials([usernamePassword(credentialsId: 'dockerhub', passwordVariable: 'PASS', usernameVariable: 'USER')]) {
+              echo "login as ${USER}"
+            }
+          }
+        }
+      }
+    }
+    stage('deploy') {
+      when {
+        branch 'master'
+      }
+      steps {
+        input(id: 'deploy', message: 'deploy to production?')
+        script {
+          if (env.BRANCH_NAME == 'master') {
+            echo 'deploying'
+          }
+        }
+        timeout(time: 5, unit: 'MINUTES') {
+          sh 'kubectl apply -f deploy'
+        }
+      }
+    }
+  }
+  post {
+    always {
+      junit 'target/surefire-reports/*.xml'
+    }
+  }
+}
+`
+
+func TestParse(t *testing.T) {
+	definition, err := Parse(sampleJenkinsfile)
+	assert.Nil(t, err)
+	pipeline := definition.Pipeline
+
+	assert.Equal(t, &Agent{Type: "node", Arguments: []NamedArgument{{Key: "label", Value: Value{IsLiteral: true, Value: "maven"}}}}, pipeline.Agent)
+	assert.Equal(t, []NamedArgument{
+		{Key: "REGISTRY", Value: Value{IsLiteral: true, Value: "docker.io"}},
+		{Key: "TAG", Value: Value{IsLiteral: false, Value: "v${BUILD_NUMBER}"}},
+	}, pipeline.Environment)
+	assert.Equal(t, 3, len(pipeline.Stages))
+
+	checkout := pipeline.Stages[0].Branches[0].Steps[0]
+	assert.Equal(t, "git", checkout.Name)
+	assert.Equal(t, []NamedArgument{
+		{Key: "url", Value: Value{IsLiteral: true, Value: "https://github.com/kubesphere/devops-maven-sample.git"}},
+		{Key: "branch", Value: Value{IsLiteral: true, Value: "master"}},
+		{Key: "changelog", Value: Value{IsLiteral: true, Value: true}},
+	}, checkout.Arguments.Named)
+
+	build := pipeline.Stages[1]
+	assert.Equal(t, 2, len(build.Parallel))
+	container := build.Parallel[0].Branches[0].Steps[0]
+	assert.Equal(t, &Value{IsLiteral: true, Value: "maven"}, container.Arguments.Single)
+	assert.Equal(t, []Step{{Name: "sh", Arguments: Arguments{Named: []NamedArgument{
+		{Key: "script", Value: Value{IsLiteral: true, Value: "mvn test"}},
+	}}}}, container.Children)
+	packageSteps := build.Parallel[1].Branches[0].Steps
+	assert.Equal(t, "mvn clean package\nls target", packageSteps[0].Arguments.Named[0].Value.Value)
+	assert.Equal(t, &Value{IsLiteral: false,
+		Value: "${[usernamePassword(credentialsId: 'dockerhub', passwordVariable: 'PASS', usernameVariable: 'USER')]}"},
+		packageSteps[1].Arguments.Single)
+	assert.Equal(t, Value{IsLiteral: false, Value: "login as ${USER}"}, packageSteps[1].Children[0].Arguments.Named[0].Value)
+
+	deploy := pipeline.Stages[2]
+	assert.Equal(t, &When{Conditions: []Condition{{Name: "branch", Arguments: Arguments{Named: []NamedArgument{
+		{Key: "pattern", Value: Value{IsLiteral: true, Value: "master"}},
+	}}}}}, deploy.When)
+	deploySteps := deploy.Branches[0].Steps
+	assert.Equal(t, "script", deploySteps[1].Name)
+	assert.Equal(t, "if (env.BRANCH_NAME == 'master') {\n  echo 'deploying'\n}", deploySteps[1].Arguments.Named[0].Value.Value)
+	assert.Equal(t, Value{IsLiteral: true, Value: json.Number("5")}, deploySteps[2].Arguments.Named[0].Value)
+	assert.Equal(t, "always", pipeline.Post.Conditions[0].Condition)
+}
+
+func TestParseGroovyStrings(t *testing.T) {
+	definition, err := Parse(`pipeline {
+  agent any
+  stages {
+    stage('build') {
+      when {
+        expression { return env.BRANCH_NAME ==~ /release-.*/ }
+      }
+      steps {
+        sh $/echo "C:\dir" $$HOME/$
+        script {
+          def m = ("a\"b" =~ /a"b/)
+          def p = /a\/b\d+/
+          def half = (10 / 2) / 1
+        }
+      }
+    }
+  }
+}`)
+	if !assert.Nil(t, err) {
+		return
+	}
+	stage := definition.Pipeline.Stages[0]
+	assert.Equal(t, "return env.BRANCH_NAME ==~ /release-.*/", stage.When.Conditions[0].Arguments.Named[0].Value.Value)
+	steps := stage.Branches[0].Steps
+	assert.Equal(t, Value{IsLiteral: true, Value: `echo "C:\dir" $HOME`}, steps[0].Arguments.Named[0].Value)
+	assert.Equal(t, "def m = (\"a\\\"b\" =~ /a\"b/)\ndef p = /a\\/b\\d+/\ndef half = (10 / 2) / 1",
+		steps[1].Arguments.Named[0].Value.Value)
+}
+
+func TestTokenizeStrings(t *testing.T) {
+	tests := []struct {
+		name  string
+		src   string
+		kind  tokenKind
+		value string
+	}{{
+		name:  "slashy string",
+		src:   `x = /a"b\/c\d/`,
+		kind:  tokenString,
+		value: `a"b/c\d`,
+	}, {
+		name:  "interpolated slashy string",
+		src:   "x = /${name}-\\d+/",
+		kind:  tokenGString,
+		value: "${name}-\\d+",
+	}, {
+		name:  "multi-line slashy string",
+		src:   "x = /a\nb/",
+		kind:  tokenString,
+		value: "a\nb",
+	}, {
+		name:  "dollar-slashy string",
+		src:   `x = $/a/b $$c $/ "d"/$`,
+		kind:  tokenString,
+		value: `a/b $c / "d"`,
+	}, {
+		name:  "interpolated dollar-slashy string",
+		src:   `x = $/$name/$`,
+		kind:  tokenGString,
+		value: "$name",
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tokens, err := tokenize(tt.src)
+			if assert.Nil(t, err) && assert.Len(t, tokens, 4) {
+				assert.Equal(t, tt.kind, tokens[2].kind)
+				assert.Equal(t, tt.value, tokens[2].value)
+			}
+		})
+	}
+
+	// a slash after a value is a division
+	tokens, err := tokenize("a / b / (c) / 2")
+	assert.Nil(t, err)
+	for _, token := range tokens {
+		assert.NotEqual(t, tokenString, token.kind)
+	}
+}
+
+func TestToJSON(t *testing.T) {
+	data, err := ToJSON(`pipeline {
+  agent any
+  stages {
+    stage('build') {
+      steps {
+        deleteDir()
+        checkout(scm)
+      }
+    }
+  }
+}`)
+	assert.Nil(t, err)
+	assert.JSONEq(t, `{"pipeline":{"agent":{"type":"any"},"stages":[{"name":"build","branches":[{"name":"default","steps":[
+		{"name":"deleteDir","arguments":[]},
+		{"name":"checkout","arguments":{"isLiteral":false,"value":"${scm}"}}
+	]}]}]}}`, data)
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		jenkinsfile string
+		wantErr     string
+		unsupported bool
+	}{{
+		name:        "unterminated string",
+		jenkinsfile: "pipeline {\n  agent any\n  stages {\n    stage('build) {\n",
+		wantErr:     "line 4, column 11: unterminated string",
+	}, {
+		name:        "missing brace",
+		jenkinsfile: "pipeline {\n  agent any\n  stages {\n    stage('build') {\n      steps {\n        sh 'make'\n      }\n    }\n}\n",
+		wantErr:     "line 1, column 10: missing '}' of the block",
+	}, {
+		name:        "unknown directive",
+		jenkinsfile: "pipeline {\n  agent any\n  stage {\n  }\n}",
+		wantErr:     "line 3, column 3: unknown directive 'stage'",
+	}, {
+		name:        "missing stages",
+		jenkinsfile: "pipeline {\n  agent any\n}",
+		wantErr:     "line 1, column 1: missing 'stages' in the pipeline",
+	}, {
+		name:        "stage without steps",
+		jenkinsfile: "pipeline {\n  agent any\n  stages {\n    stage('build') {\n      agent any\n    }\n  }\n}",
+		wantErr:     "line 4, column 5: stage 'build' should have exactly one of 'steps', 'parallel' and 'stages'",
+	}, {
+		name:        "two statements in a line",
+		jenkinsfile: "pipeline {\n  agent any stages {\n  }\n}",
+		wantErr:     "line 2, column 13: expected the end of line, got 'stages'",
+	}, {
+		name:        "unterminated string in a script block",
+		jenkinsfile: "pipeline {\n  agent any\n  stages {\n    stage('build') {\n      steps {\n        script {\n          def s = \"abc\n        }\n      }\n    }\n  }\n}",
+		wantErr:     "line 7, column 19: unsupported: unterminated string in the Groovy code",
+		unsupported: true,
+	}, {
+		name:        "unterminated string in an expression block",
+		jenkinsfile: "pipeline {\n  agent any\n  stages {\n    stage('build') {\n      when {\n        expression { return 'abc }\n      }\n    }\n  }\n}",
+		wantErr:     "line 6, column 29: unsupported: unterminated string in the Groovy code",
+		unsupported: true,
+	}, {
+		name:        "unterminated string after a script block",
+		jenkinsfile: "pipeline {\n  agent any\n  stages {\n    stage('build') {\n      steps {\n        script {\n          echo 'a'\n        }\n        sh 'make\n      }\n    }\n  }\n}",
+		wantErr:     "line 9, column 12: unterminated string",
+	}, {
+		name:        "scripted pipeline",
+		jenkinsfile: "node {\n  sh 'make'\n}",
+		wantErr:     "line 1, column 1: unsupported: only the declarative Pipeline is supported, it should start with 'pipeline {'",
+		unsupported: true,
+	}, {
+		name:        "options",
+		jenkinsfile: "pipeline {\n  agent any\n  options {\n    timeout(time: 1, unit: 'HOURS')\n  }\n}",
+		wantErr:     "line 3, column 3: unsupported: the directive 'options'",
+		unsupported: true,
+	}, {
+		name:        "Groovy statements out of a script block",
+		jenkinsfile: "pipeline {\n  agent any\n  stages {\n    stage('build') {\n      steps {\n        def a = 1\n      }\n    }\n  }\n}",
+		wantErr:     "line 6, column 9: unsupported: the Groovy statements out of a 'script' block",
+		unsupported: true,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(tt.jenkinsfile)
+			if assert.NotNil(t, err) {
+				assert.Equal(t, tt.wantErr, err.Error())
+				assert.Equal(t, tt.unsupported, IsUnsupported(err))
+			}
+		})
+	}
+}
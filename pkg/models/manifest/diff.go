@@ -0,0 +1,315 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package manifest
+
+import (
+	"encoding/base64"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/kubesphere/ks-devops/pkg/api/gitops/v1alpha1"
+)
+
+// DiffStatus is the status of a resource comparing the rendered manifest with the live object
+type DiffStatus string
+
+const (
+	// DiffStatusAdded means the resource does not exist in the cluster
+	DiffStatusAdded DiffStatus = "Added"
+	// DiffStatusModified means some fields of the live object are different from the rendered manifest
+	DiffStatusModified DiffStatus = "Modified"
+	// DiffStatusUnchanged means the live object is the same as the rendered manifest
+	DiffStatusUnchanged DiffStatus = "Unchanged"
+	// DiffStatusRemoved means the resource is managed by the Application, but it's not rendered anymore
+	DiffStatusRemoved DiffStatus = "Removed"
+	// DiffStatusUnknown means the live object cannot be fetched
+	DiffStatusUnknown DiffStatus = "Unknown"
+)
+
+// FieldChange is a changed field, the path is a JSON pointer
+type FieldChange struct {
+	Path   string      `json:"path"`
+	Live   interface{} `json:"live,omitempty"`
+	Target interface{} `json:"target,omitempty"`
+}
+
+// ResourceDiff is the rendered manifest and the live object of a resource, and the changes between them
+type ResourceDiff struct {
+	Group     string                 `json:"group,omitempty"`
+	Version   string                 `json:"version,omitempty"`
+	Kind      string                 `json:"kind"`
+	Namespace string                 `json:"namespace,omitempty"`
+	Name      string                 `json:"name"`
+	Status    DiffStatus             `json:"status,omitempty"`
+	Target    map[string]interface{} `json:"target,omitempty"`
+	Live      map[string]interface{} `json:"live,omitempty"`
+	Changes   []FieldChange          `json:"changes,omitempty"`
+}
+
+// NewResourceDiff returns a diff which is identified by the object
+func NewResourceDiff(obj *unstructured.Unstructured, status DiffStatus) *ResourceDiff {
+	gvk := obj.GroupVersionKind()
+	return &ResourceDiff{
+		Group:     gvk.Group,
+		Version:   gvk.Version,
+		Kind:      gvk.Kind,
+		Namespace: obj.GetNamespace(),
+		Name:      obj.GetName(),
+		Status:    status,
+	}
+}
+
+// UnknownDiff returns the diff of a rendered manifest whose live object cannot be fetched
+func UnknownDiff(target *unstructured.Unstructured) *ResourceDiff {
+	return RenderedDiff(target, DiffStatusUnknown)
+}
+
+// RenderedDiff returns the diff of a rendered manifest which is not compared with the live object
+func RenderedDiff(target *unstructured.Unstructured, status DiffStatus) *ResourceDiff {
+	target, _ = hideSecretData(target, nil)
+	diff := NewResourceDiff(target, status)
+	diff.Target = target.Object
+	return diff
+}
+
+// Diff compares the rendered manifest with the live object, either of them could be nil. Like applying the manifest,
+// only the fields of the manifest are compared. The fields ignored by the rules are removed from both objects. The
+// data of the Secrets is never returned, see hideSecretData.
+func Diff(target, live *unstructured.Unstructured, ignores []v1alpha1.ResourceIgnoreDifferences) (diff *ResourceDiff, err error) {
+	target, live = hideSecretData(target, live)
+	switch {
+	case target == nil && live == nil:
+		return nil, nil
+	case live == nil:
+		diff = NewResourceDiff(target, DiffStatusAdded)
+		diff.Target = target.Object
+		return
+	case target == nil:
+		diff = NewResourceDiff(live, DiffStatusRemoved)
+		diff.Live = normalize(live).Object
+		return
+	}
+
+	var paths [][]pathToken
+	if paths, err = ignoredPaths(ignores, target, live); err != nil {
+		return
+	}
+	targetObj, liveObj := normalize(target), normalize(live)
+	for _, path := range paths {
+		removeField(targetObj.Object, path)
+		removeField(liveObj.Object, path)
+	}
+
+	diff = NewResourceDiff(target, DiffStatusUnchanged)
+	diff.Target, diff.Live = targetObj.Object, liveObj.Object
+	compareField("", targetObj.Object, liveObj.Object, &diff.Changes)
+	if len(diff.Changes) > 0 {
+		diff.Status = DiffStatusModified
+	}
+	return
+}
+
+// secretMask replaces the values of a Secret. The values which differ from each other are replaced by the masks of
+// different lengths, so the changes are still reported without the values, it's the same as Argo CD.
+const secretMask = "++++++++"
+
+// hideSecretData returns the copies of the Secrets whose values are masked, the stringData is merged into the data
+// like the API server does. Other objects are returned as they are.
+func hideSecretData(target, live *unstructured.Unstructured) (*unstructured.Unstructured, *unstructured.Unstructured) {
+	isSecret := func(obj *unstructured.Unstructured) bool {
+		return obj != nil && obj.GroupVersionKind().Group == "" && obj.GetKind() == "Secret"
+	}
+	if !isSecret(target) && !isSecret(live) {
+		return target, live
+	}
+
+	targetData, liveData := secretData(target), secretData(live)
+	keys := map[string]bool{}
+	for key := range targetData {
+		keys[key] = true
+	}
+	for key := range liveData {
+		keys[key] = true
+	}
+	targetMasked, liveMasked := map[string]interface{}{}, map[string]interface{}{}
+	for key := range keys {
+		targetValue, inTarget := targetData[key]
+		liveValue, inLive := liveData[key]
+		if inTarget {
+			targetMasked[key] = secretMask
+		}
+		switch {
+		case inLive && inTarget && liveValue != targetValue:
+			liveMasked[key] = secretMask + "+"
+		case inLive:
+			liveMasked[key] = secretMask
+		}
+	}
+
+	mask := func(obj *unstructured.Unstructured, masked map[string]interface{}) *unstructured.Unstructured {
+		if obj == nil {
+			return nil
+		}
+		obj = obj.DeepCopy()
+		delete(obj.Object, "stringData")
+		delete(obj.Object, "data")
+		if len(masked) > 0 {
+			obj.Object["data"] = masked
+		}
+		return obj
+	}
+	return mask(target, targetMasked), mask(live, liveMasked)
+}
+
+// secretData returns the base64 encoded data of a Secret, the stringData overrides the data
+func secretData(obj *unstructured.Unstructured) map[string]string {
+	data := map[string]string{}
+	if obj == nil {
+		return data
+	}
+	if values, ok := obj.Object["data"].(map[string]interface{}); ok {
+		for key, value := range values {
+			data[key], _ = value.(string)
+		}
+	}
+	if values, ok := obj.Object["stringData"].(map[string]interface{}); ok {
+		for key, value := range values {
+			text, _ := value.(string)
+			data[key] = base64.StdEncoding.EncodeToString([]byte(text))
+		}
+	}
+	return data
+}
+
+// normalize returns a copy without the status and the fields maintained by the server
+func normalize(obj *unstructured.Unstructured) *unstructured.Unstructured {
+	result := obj.DeepCopy()
+	delete(result.Object, "status")
+	for _, field := range []string{"uid", "resourceVersion", "generation", "creationTimestamp", "deletionTimestamp",
+		"deletionGracePeriodSeconds", "managedFields", "selfLink"} {
+		unstructured.RemoveNestedField(result.Object, "metadata", field)
+	}
+	unstructured.RemoveNestedField(result.Object, "metadata", "annotations", "kubectl.kubernetes.io/last-applied-configuration")
+	if annotations, found, _ := unstructured.NestedMap(result.Object, "metadata", "annotations"); found && len(annotations) == 0 {
+		unstructured.RemoveNestedField(result.Object, "metadata", "annotations")
+	}
+	return result
+}
+
+func compareField(path string, target, live interface{}, changes *[]FieldChange) {
+	switch t := target.(type) {
+	case nil:
+		return
+	case map[string]interface{}:
+		liveMap, ok := live.(map[string]interface{})
+		if !ok {
+			break
+		}
+		for _, key := range sortedKeys(t) {
+			compareField(path+"/"+escapePointer(key), t[key], liveMap[key], changes)
+		}
+		return
+	case []interface{}:
+		liveList, ok := live.([]interface{})
+		if !ok {
+			break
+		}
+		if names, ok := itemNames(t); ok && len(t) > 0 {
+			if liveNames, ok := itemNames(liveList); ok {
+				for i, name := range names {
+					var liveItem interface{}
+					for j, liveName := range liveNames {
+						if liveName == name {
+							liveItem = liveList[j]
+							break
+						}
+					}
+					compareField(path+"/"+strconv.Itoa(i), t[i], liveItem, changes)
+				}
+				return
+			}
+		}
+		if len(t) != len(liveList) {
+			break
+		}
+		for i := range t {
+			compareField(path+"/"+strconv.Itoa(i), t[i], liveList[i], changes)
+		}
+		return
+	default:
+		if equalValue(target, live) {
+			return
+		}
+	}
+	*changes = append(*changes, FieldChange{Path: path, Live: live, Target: target})
+}
+
+// itemNames returns the names if all the items are maps with a name, like the containers or ports
+func itemNames(items []interface{}) (names []string, ok bool) {
+	for _, item := range items {
+		itemMap, isMap := item.(map[string]interface{})
+		if !isMap {
+			return nil, false
+		}
+		name, isString := itemMap["name"].(string)
+		if !isString {
+			return nil, false
+		}
+		names = append(names, name)
+	}
+	return names, true
+}
+
+// equalValue compares the values, the numbers are compared regardless of their types
+func equalValue(a, b interface{}) bool {
+	if af, ok := toNumber(a); ok {
+		bf, ok := toNumber(b)
+		return ok && af == bf
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func toNumber(val interface{}) (float64, bool) {
+	switch v := val.(type) {
+	case int:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+	return 0, false
+}
+
+// sortedKeys returns the sorted keys of a map
+func sortedKeys(m map[string]interface{}) (keys []string) {
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return
+}
+
+func escapePointer(key string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(key, "~", "~0"), "/", "~1")
+}
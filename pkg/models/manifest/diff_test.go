@@ -0,0 +1,233 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package manifest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/kubesphere/ks-devops/pkg/api/gitops/v1alpha1"
+)
+
+func newDeployment(replicas int64, image string) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata": map[string]interface{}{
+			"name":      "web",
+			"namespace": "default",
+		},
+		"spec": map[string]interface{}{
+			"replicas": replicas,
+			"template": map[string]interface{}{
+				"spec": map[string]interface{}{
+					"containers": []interface{}{
+						map[string]interface{}{"name": "web", "image": image},
+					},
+				},
+			},
+		},
+	}}
+}
+
+func newLiveDeployment(replicas int64, image string) *unstructured.Unstructured {
+	live := newDeployment(replicas, image)
+	live.SetUID("uid")
+	live.SetResourceVersion("1")
+	live.SetAnnotations(map[string]string{"kubectl.kubernetes.io/last-applied-configuration": "{}"})
+	live.Object["status"] = map[string]interface{}{"replicas": replicas}
+	_ = unstructured.SetNestedField(live.Object, "Always", "spec", "template", "spec", "restartPolicy")
+	containers, _, _ := unstructured.NestedSlice(live.Object, "spec", "template", "spec", "containers")
+	containers = append([]interface{}{map[string]interface{}{"name": "sidecar", "image": "busybox"}}, containers...)
+	_ = unstructured.SetNestedSlice(live.Object, containers, "spec", "template", "spec", "containers")
+	return live
+}
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		name        string
+		target      *unstructured.Unstructured
+		live        *unstructured.Unstructured
+		ignores     []v1alpha1.ResourceIgnoreDifferences
+		wantStatus  DiffStatus
+		wantChanges []FieldChange
+	}{{
+		name:       "added",
+		target:     newDeployment(1, "nginx"),
+		wantStatus: DiffStatusAdded,
+	}, {
+		name:       "removed",
+		live:       newLiveDeployment(1, "nginx"),
+		wantStatus: DiffStatusRemoved,
+	}, {
+		name:       "unchanged with the defaulted fields and the reordered containers",
+		target:     newDeployment(1, "nginx"),
+		live:       newLiveDeployment(1, "nginx"),
+		wantStatus: DiffStatusUnchanged,
+	}, {
+		name:       "modified",
+		target:     newDeployment(2, "nginx:1.21"),
+		live:       newLiveDeployment(1, "nginx"),
+		wantStatus: DiffStatusModified,
+		wantChanges: []FieldChange{
+			{Path: "/spec/replicas", Live: int64(1), Target: int64(2)},
+			{Path: "/spec/template/spec/containers/0/image", Live: "nginx", Target: "nginx:1.21"},
+		},
+	}, {
+		name:   "ignored",
+		target: newDeployment(2, "nginx:1.21"),
+		live:   newLiveDeployment(1, "nginx"),
+		ignores: []v1alpha1.ResourceIgnoreDifferences{{
+			Group:        "apps",
+			Kind:         "Deployment",
+			JSONPointers: []string{"/spec/replicas"},
+		}, {
+			Group:             "apps",
+			Kind:              "Deployment",
+			Name:              "web",
+			JQPathExpressions: []string{".spec.template.spec.containers[].image"},
+		}, {
+			Kind:         "Service",
+			JSONPointers: []string{"/spec"},
+		}},
+		wantStatus: DiffStatusUnchanged,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diff, err := Diff(tt.target, tt.live, tt.ignores)
+			assert.NoError(t, err)
+			assert.Equal(t, "Deployment", diff.Kind)
+			assert.Equal(t, "apps", diff.Group)
+			assert.Equal(t, "web", diff.Name)
+			assert.Equal(t, tt.wantStatus, diff.Status)
+			assert.Equal(t, tt.wantChanges, diff.Changes)
+			if diff.Live != nil {
+				assert.NotContains(t, diff.Live, "status")
+				assert.NotContains(t, diff.Live["metadata"], "uid")
+				assert.NotContains(t, diff.Live["metadata"], "annotations")
+			}
+		})
+	}
+
+	diff, err := Diff(nil, nil, nil)
+	assert.NoError(t, err)
+	assert.Nil(t, diff)
+	assert.Equal(t, DiffStatusUnknown, UnknownDiff(newDeployment(1, "nginx")).Status)
+}
+
+func TestDiff_managedFields(t *testing.T) {
+	target := newDeployment(2, "nginx:1.21")
+	live := newLiveDeployment(1, "nginx")
+	live.SetManagedFields([]metav1.ManagedFieldsEntry{{
+		Manager:  "hpa",
+		FieldsV1: &metav1.FieldsV1{Raw: []byte(`{"f:spec":{"f:replicas":{}}}`)},
+	}, {
+		Manager: "image-updater",
+		FieldsV1: &metav1.FieldsV1{Raw: []byte(
+			`{"f:spec":{"f:template":{"f:spec":{"f:containers":{"k:{\"name\":\"web\"}":{".":{},"f:image":{}}}}}}}`)},
+	}})
+
+	diff, err := Diff(target, live, []v1alpha1.ResourceIgnoreDifferences{{
+		Group:                 "apps",
+		Kind:                  "Deployment",
+		ManagedFieldsManagers: []string{"hpa"},
+	}})
+	assert.NoError(t, err)
+	assert.Equal(t, []FieldChange{
+		{Path: "/spec/template/spec/containers/0/image", Live: "nginx", Target: "nginx:1.21"},
+	}, diff.Changes)
+
+	diff, err = Diff(target, live, []v1alpha1.ResourceIgnoreDifferences{{
+		Group:                 "apps",
+		Kind:                  "Deployment",
+		ManagedFieldsManagers: []string{"hpa", "image-updater"},
+	}})
+	assert.NoError(t, err)
+	assert.Equal(t, DiffStatusUnchanged, diff.Status)
+	containers, _, _ := unstructured.NestedSlice(diff.Live, "spec", "template", "spec", "containers")
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"name": "sidecar", "image": "busybox"},
+		map[string]interface{}{"name": "web"},
+	}, containers)
+}
+
+func TestDiff_secret(t *testing.T) {
+	newSecret := func(data, stringData map[string]interface{}) *unstructured.Unstructured {
+		secret := &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "Secret",
+			"metadata":   map[string]interface{}{"name": "token", "namespace": "default"},
+		}}
+		if data != nil {
+			secret.Object["data"] = data
+		}
+		if stringData != nil {
+			secret.Object["stringData"] = stringData
+		}
+		return secret
+	}
+	// "c2FtZQ==" is "same"
+	target := newSecret(map[string]interface{}{"changed": "bmV3"}, map[string]interface{}{"same": "same", "added": "new"})
+	live := newSecret(map[string]interface{}{"same": "c2FtZQ==", "changed": "b2xk", "removed": "b2xk"}, nil)
+
+	diff, err := Diff(target, live, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, DiffStatusModified, diff.Status)
+	assert.Equal(t, map[string]interface{}{"same": "++++++++", "changed": "++++++++", "added": "++++++++"},
+		diff.Target["data"])
+	assert.Equal(t, map[string]interface{}{"same": "++++++++", "changed": "+++++++++", "removed": "++++++++"},
+		diff.Live["data"])
+	assert.NotContains(t, diff.Target, "stringData")
+	assert.Equal(t, []FieldChange{
+		{Path: "/data/added", Target: "++++++++"},
+		{Path: "/data/changed", Live: "+++++++++", Target: "++++++++"},
+	}, diff.Changes)
+	assert.Equal(t, "bmV3", target.Object["data"].(map[string]interface{})["changed"], "the target should not be changed")
+
+	diff, err = Diff(nil, live, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"same": "++++++++", "changed": "++++++++", "removed": "++++++++"},
+		diff.Live["data"])
+
+	diff = UnknownDiff(target)
+	assert.Equal(t, map[string]interface{}{"changed": "++++++++", "same": "++++++++", "added": "++++++++"},
+		diff.Target["data"])
+}
+
+func TestCompareField(t *testing.T) {
+	var changes []FieldChange
+	compareField("", map[string]interface{}{
+		"a/b":  "x",
+		"list": []interface{}{"a", "b"},
+		"num":  int64(1),
+		"map":  map[string]interface{}{"k": "v"},
+		"none": nil,
+	}, map[string]interface{}{
+		"a/b":  "y",
+		"list": []interface{}{"a"},
+		"num":  float64(1),
+		"map":  "v",
+	}, &changes)
+	assert.Equal(t, []FieldChange{
+		{Path: "/a~1b", Live: "y", Target: "x"},
+		{Path: "/list", Live: []interface{}{"a"}, Target: []interface{}{"a", "b"}},
+		{Path: "/map", Live: "v", Target: map[string]interface{}{"k": "v"}},
+	}, changes)
+}
@@ -0,0 +1,87 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package manifest
+
+import (
+	"path"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/kubesphere/ks-devops/pkg/api/gitops/v1alpha1"
+)
+
+var manifestExtensions = []string{".yaml", ".yml", ".json"}
+
+// renderDirectory decodes the plain manifests in a directory
+func renderDirectory(files Files, dir string, opts *v1alpha1.ApplicationSourceDirectory) (objects []*unstructured.Unstructured, err error) {
+	if opts == nil {
+		opts = &v1alpha1.ApplicationSourceDirectory{}
+	}
+	if len(opts.Jsonnet.ExtVars) > 0 || len(opts.Jsonnet.TLAs) > 0 || len(opts.Jsonnet.Libs) > 0 {
+		err = &UnsupportedError{Message: "jsonnet"}
+		return
+	}
+
+	for _, name := range files.List(dir, opts.Recurse) {
+		if strings.HasSuffix(name, ".jsonnet") || strings.HasSuffix(name, ".libsonnet") {
+			err = &UnsupportedError{Message: "jsonnet file " + name}
+			return
+		}
+		rel := strings.TrimPrefix(name, dirPrefix(dir))
+		if !isManifest(name) || !matchGlobs(opts.Include, rel, true) || matchGlobs(opts.Exclude, rel, false) {
+			continue
+		}
+
+		var items []*unstructured.Unstructured
+		if items, err = decode(name, files[name]); err != nil {
+			return
+		}
+		objects = append(objects, items...)
+	}
+	return
+}
+
+func isManifest(name string) bool {
+	for _, ext := range manifestExtensions {
+		if strings.HasSuffix(name, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchGlobs matches the file against the patterns like "*.yaml" or "{a.yaml,b.yaml}", both the base name and the
+// path relative to the source are checked. It returns the fallback if there's no pattern.
+func matchGlobs(patterns, name string, fallback bool) bool {
+	if patterns = strings.TrimSpace(patterns); patterns == "" {
+		return fallback
+	}
+	if strings.HasPrefix(patterns, "{") && strings.HasSuffix(patterns, "}") {
+		patterns = patterns[1 : len(patterns)-1]
+	}
+	for _, pattern := range strings.Split(patterns, ",") {
+		pattern = strings.TrimSpace(pattern)
+		if matched, _ := path.Match(pattern, path.Base(name)); matched {
+			return true
+		}
+		if matched, _ := path.Match(strings.TrimPrefix(pattern, "/"), name); matched {
+			return true
+		}
+	}
+	return false
+}
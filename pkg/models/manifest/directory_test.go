@@ -0,0 +1,76 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package manifest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kubesphere/ks-devops/pkg/api/gitops/v1alpha1"
+)
+
+func TestRenderDirectory(t *testing.T) {
+	files := Files{
+		"app/a.yaml":        []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: a\n"),
+		"app/b.yml":         []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: b\n"),
+		"app/README.md":     []byte("# readme"),
+		"app/sub/c.json":    []byte(`{"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "c"}}`),
+		"jsonnet/a.jsonnet": []byte("{}"),
+	}
+
+	tests := []struct {
+		name      string
+		dir       string
+		opts      *v1alpha1.ApplicationSourceDirectory
+		wantNames []string
+		wantErr   bool
+	}{{
+		name:      "not recursive",
+		dir:       "app",
+		wantNames: []string{"a", "b"},
+	}, {
+		name:      "recursive",
+		dir:       "app",
+		opts:      &v1alpha1.ApplicationSourceDirectory{Recurse: true},
+		wantNames: []string{"a", "b", "c"},
+	}, {
+		name:      "include and exclude",
+		dir:       "app",
+		opts:      &v1alpha1.ApplicationSourceDirectory{Recurse: true, Include: "{*.yaml,*.json}", Exclude: "sub/*"},
+		wantNames: []string{"a"},
+	}, {
+		name:    "jsonnet",
+		dir:     "jsonnet",
+		wantErr: true,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			objects, err := renderDirectory(files, tt.dir, tt.opts)
+			if tt.wantErr {
+				assert.True(t, IsUnsupported(err))
+				return
+			}
+			assert.NoError(t, err)
+			var names []string
+			for _, obj := range objects {
+				names = append(names, obj.GetName())
+			}
+			assert.Equal(t, tt.wantNames, names)
+		})
+	}
+}
@@ -0,0 +1,293 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package manifest
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime/debug"
+	"sort"
+	"strings"
+
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/engine"
+	"helm.sh/helm/v3/pkg/strvals"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/yaml"
+
+	"github.com/kubesphere/ks-devops/pkg/api/gitops/v1alpha1"
+)
+
+// helmCapabilities are the capabilities of the cluster for the charts. Like the helm binary, the Kubernetes version is
+// the one of the client libraries, and the API versions are the built-in ones. Like a cluster, the kinds are available
+// as well, such as "apps/v1/Deployment".
+var helmCapabilities = func() *chartutil.Capabilities {
+	caps := chartutil.DefaultCapabilities.Copy()
+	caps.APIVersions = append(chartutil.VersionSet{}, caps.APIVersions...)
+	for _, gv := range scheme.Scheme.PrioritizedVersionsAllGroups() {
+		for kind := range scheme.Scheme.KnownTypes(gv) {
+			if !strings.HasSuffix(kind, "List") && !strings.HasSuffix(kind, "Options") {
+				caps.APIVersions = append(caps.APIVersions, gv.String()+"/"+kind)
+			}
+		}
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, dep := range info.Deps {
+			if dep.Path != "k8s.io/client-go" {
+				continue
+			}
+			// the client v0.31.3 is the one of Kubernetes v1.31.3
+			if version, err := chartutil.ParseKubeVersion("v1" + strings.TrimPrefix(dep.Version, "v0")); err == nil {
+				caps.KubeVersion = *version
+			}
+		}
+	}
+	return caps
+}()
+
+// renderHelm renders a local chart like "helm template --include-crds" with the Helm engine. The values are merged in
+// order of the value files, the values, the parameters and the file parameters, then coalesced with the values of
+// the chart.
+func renderHelm(files Files, dir string, source *Source) (objects []*unstructured.Unstructured, err error) {
+	opts := source.Helm
+	if opts == nil {
+		opts = &v1alpha1.ApplicationSourceHelm{}
+	}
+
+	if !files.Exists(path.Join(dir, "Chart.yaml")) {
+		err = fmt.Errorf("no Chart.yaml found in %s", dir)
+		return
+	}
+	var ch *chart.Chart
+	if ch, err = loadChart(files, dir); err != nil {
+		return
+	}
+	if err = checkChart(ch); err != nil {
+		return
+	}
+
+	var values map[string]interface{}
+	if values, err = helmValues(files, dir, opts); err != nil {
+		return
+	}
+	if err = chartutil.ProcessDependenciesWithMerge(ch, values); err != nil {
+		err = fmt.Errorf("failed to process the dependencies of %s: %v", dir, err)
+		return
+	}
+
+	releaseName := opts.ReleaseName
+	if releaseName == "" {
+		releaseName = source.ReleaseName
+	}
+	if releaseName == "" {
+		releaseName = path.Base(dir)
+	}
+	var renderValues chartutil.Values
+	if renderValues, err = chartutil.ToRenderValues(ch, values, chartutil.ReleaseOptions{
+		Name:      releaseName,
+		Namespace: source.Namespace,
+		Revision:  1,
+		IsInstall: true,
+	}, helmCapabilities); err != nil {
+		err = fmt.Errorf("failed to get the values of %s: %v", dir, err)
+		return
+	}
+	var rendered map[string]string
+	if rendered, err = engine.Render(ch, renderValues); err != nil {
+		err = fmt.Errorf("failed to render %s: %v", dir, err)
+		return
+	}
+
+	if !opts.SkipCrds {
+		for _, crd := range ch.CRDObjects() {
+			var items []*unstructured.Unstructured
+			if items, err = decode(crd.Filename, crd.File.Data); err != nil {
+				return
+			}
+			objects = append(objects, items...)
+		}
+	}
+
+	names := make([]string, 0, len(rendered))
+	for name := range rendered {
+		// the partials and notes are not manifests, they are skipped like Helm does
+		if base := path.Base(name); !strings.HasPrefix(base, "_") && base != "NOTES.txt" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		var items []*unstructured.Unstructured
+		if items, err = decode(name, []byte(rendered[name])); err != nil {
+			return
+		}
+		objects = append(objects, items...)
+	}
+	return
+}
+
+// loadChart loads the chart in a directory. The files are written into a temporary directory first, so that the
+// .helmignore and the vendored dependencies are handled by Helm.
+func loadChart(files Files, dir string) (ch *chart.Chart, err error) {
+	var tmp string
+	if tmp, err = os.MkdirTemp("", "chart"); err != nil {
+		return
+	}
+	defer func() {
+		_ = os.RemoveAll(tmp)
+	}()
+
+	prefix := dirPrefix(dir)
+	for _, name := range files.List(dir, true) {
+		target := filepath.Join(tmp, filepath.FromSlash(strings.TrimPrefix(name, prefix)))
+		if err = os.MkdirAll(filepath.Dir(target), 0750); err == nil {
+			err = os.WriteFile(target, files[name], 0600)
+		}
+		if err != nil {
+			return
+		}
+	}
+	if ch, err = loader.LoadDir(tmp); err != nil {
+		err = fmt.Errorf("failed to load the chart in %s: %v", dir, err)
+	}
+	return
+}
+
+// checkChart checks if the chart could be installed, the dependencies must be vendored in the charts directory since
+// they are not downloaded
+func checkChart(ch *chart.Chart) error {
+	if ch.Metadata.Type == "library" {
+		return fmt.Errorf("library chart %s is not installable", ch.Name())
+	}
+	if ch.Metadata.KubeVersion != "" &&
+		!chartutil.IsCompatibleRange(ch.Metadata.KubeVersion, helmCapabilities.KubeVersion.String()) {
+		return fmt.Errorf("chart %s requires kubeVersion %s which is incompatible with Kubernetes %s",
+			ch.Name(), ch.Metadata.KubeVersion, helmCapabilities.KubeVersion.String())
+	}
+
+	var missing []string
+	for _, dependency := range ch.Metadata.Dependencies {
+		vendored := false
+		for _, sub := range ch.Dependencies() {
+			if sub.Name() == dependency.Name {
+				vendored = true
+				break
+			}
+		}
+		if !vendored {
+			missing = append(missing, dependency.Name)
+		}
+	}
+	if len(missing) > 0 {
+		return &UnsupportedError{Message: fmt.Sprintf("helm chart dependencies %s which are not in the charts directory",
+			strings.Join(missing, ", "))}
+	}
+	return nil
+}
+
+// helmValues merges the values like the arguments "--values", "--set", "--set-string" and "--set-file" of Argo CD
+func helmValues(files Files, dir string, opts *v1alpha1.ApplicationSourceHelm) (values map[string]interface{}, err error) {
+	values = map[string]interface{}{}
+	for _, file := range opts.ValueFiles {
+		if strings.Contains(file, "://") {
+			err = &UnsupportedError{Message: "remote value file " + file}
+			return
+		}
+		if !files.Exists(path.Join(dir, file)) && opts.IgnoreMissingValueFiles {
+			continue
+		}
+
+		var data []byte
+		if data, err = files.Get(path.Join(dir, file)); err != nil {
+			return
+		}
+		if err = mergeYAMLValues(values, data); err != nil {
+			err = fmt.Errorf("failed to parse %s: %v", file, err)
+			return
+		}
+	}
+	if err = mergeYAMLValues(values, []byte(opts.Values)); err != nil {
+		err = fmt.Errorf("failed to parse the values: %v", err)
+		return
+	}
+
+	for _, param := range opts.Parameters {
+		expression := param.Name + "=" + escapeParameterValue(param.Value)
+		if param.ForceString {
+			err = strvals.ParseIntoString(expression, values)
+		} else {
+			err = strvals.ParseInto(expression, values)
+		}
+		if err != nil {
+			err = fmt.Errorf("failed to set the parameter %s: %v", param.Name, err)
+			return
+		}
+	}
+	for _, param := range opts.FileParameters {
+		if err = strvals.ParseIntoFile(param.Name+"="+param.Path, values, func(file []rune) (interface{}, error) {
+			data, err := files.Get(path.Join(dir, string(file)))
+			return string(data), err
+		}); err != nil {
+			err = fmt.Errorf("failed to set the file parameter %s: %v", param.Name, err)
+			return
+		}
+	}
+	return
+}
+
+// escapeParameterValue escapes the commas of a parameter value, otherwise Helm splits it into several parameters. A
+// list like "{a,b}" is kept, it's the same as Argo CD.
+func escapeParameterValue(val string) string {
+	if strings.HasPrefix(val, "{") && strings.HasSuffix(val, "}") {
+		return val
+	}
+	var escaped strings.Builder
+	for i := 0; i < len(val); i++ {
+		if val[i] == ',' && (i == 0 || val[i-1] != '\\') {
+			escaped.WriteByte('\\')
+		}
+		escaped.WriteByte(val[i])
+	}
+	return escaped.String()
+}
+
+func mergeYAMLValues(values map[string]interface{}, data []byte) (err error) {
+	src := map[string]interface{}{}
+	if err = yaml.Unmarshal(data, &src); err == nil {
+		mergeValues(values, src)
+	}
+	return
+}
+
+// mergeValues merges the source into the destination recursively like Helm merges the value files. A null value is
+// kept, it deletes the default value of the chart when coalescing.
+func mergeValues(dst, src map[string]interface{}) {
+	for key, val := range src {
+		srcMap, srcIsMap := val.(map[string]interface{})
+		dstMap, dstIsMap := dst[key].(map[string]interface{})
+		if srcIsMap && dstIsMap {
+			mergeValues(dstMap, srcMap)
+			continue
+		}
+		dst[key] = val
+	}
+}
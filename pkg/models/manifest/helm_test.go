@@ -0,0 +1,263 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package manifest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/kubesphere/ks-devops/pkg/api/gitops/v1alpha1"
+)
+
+func TestRenderHelm(t *testing.T) {
+	files := Files{
+		"chart/Chart.yaml": []byte(`apiVersion: v2
+name: web
+version: 0.1.0
+appVersion: "1.20"
+kubeVersion: ">=1.20.0-0"
+dependencies:
+- name: cache
+  version: 0.1.0
+  condition: cache.enabled
+`),
+		"chart/.helmignore":             []byte("ignored.yaml\n"),
+		"chart/templates/ignored.yaml":  []byte("{{ fail \"ignored\" }}"),
+		"chart/charts/cache/Chart.yaml": []byte("apiVersion: v2\nname: cache\nversion: 0.1.0\n"),
+		"chart/charts/cache/templates/cm.yaml": []byte(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: {{ .Release.Name }}-cache
+data:
+  size: {{ .Values.size | quote }}
+`),
+		"chart/values.yaml": []byte(`replicas: 1
+image:
+  repository: nginx
+  tag: ""
+labels:
+  team: a
+service:
+  enabled: true
+cache:
+  enabled: false
+  size: 1Gi
+`),
+		"chart/values-prod.yaml": []byte("replicas: 3\nlabels: null\n"),
+		"chart/config.txt":       []byte("key=value"),
+		"chart/templates/_helpers.tpl": []byte(`{{- define "web.fullname" -}}
+{{ .Release.Name }}-{{ .Chart.Name }}
+{{- end -}}`),
+		"chart/templates/deploy.yaml": []byte(`apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: {{ include "web.fullname" . }}
+  namespace: {{ .Release.Namespace }}
+  {{- with .Values.labels }}
+  labels:
+    {{- toYaml . | nindent 4 }}
+  {{- end }}
+spec:
+  replicas: {{ .Values.replicas }}
+  template:
+    spec:
+      containers:
+      - name: web
+        image: "{{ .Values.image.repository }}:{{ .Values.image.tag | default .Chart.AppVersion }}"
+        args: {{ .Values.args | toJson }}
+        env:
+        - name: MISSING
+          value: "{{ .Values.missing }}"
+        - name: CONFIG
+          value: {{ .Files.Get "config.txt" | quote }}
+`),
+		"chart/templates/svc.yaml": []byte(`{{- if and .Values.service.enabled (.Capabilities.APIVersions.Has "v1/Service") }}
+apiVersion: v1
+kind: Service
+metadata:
+  name: {{ tpl .Values.serviceName . }}
+  annotations:
+    description: {{ .Values.description | quote }}
+    new-api: {{ semverCompare ">=1.25.0-0" .Capabilities.KubeVersion.Version | quote }}
+{{- end }}
+`),
+		"chart/templates/NOTES.txt": []byte("{{ .Release.Name }} installed"),
+		"chart/crds/crd.yaml": []byte(`apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: webs.example.com
+`),
+	}
+
+	objects, err := renderHelm(files, "chart", &Source{
+		Namespace: "prod",
+		Helm: &v1alpha1.ApplicationSourceHelm{
+			ReleaseName: "release",
+			ValueFiles:  []string{"values-prod.yaml", "values-missing.yaml"},
+			Values:      "image:\n  tag: \"1.21\"\nserviceName: \"{{ .Release.Name }}-svc\"\n",
+			Parameters: []v1alpha1.HelmParameter{
+				{Name: "replicas", Value: "5"},
+				{Name: "args[1]", Value: "true", ForceString: true},
+				{Name: "description", Value: "a,b", ForceString: true},
+			},
+			IgnoreMissingValueFiles: true,
+		},
+	})
+	assert.NoError(t, err)
+	if !assert.Len(t, objects, 3) {
+		return
+	}
+
+	assert.Equal(t, "CustomResourceDefinition", objects[0].GetKind())
+
+	deploy := objects[1]
+	assert.Equal(t, "release-web", deploy.GetName())
+	assert.Equal(t, "prod", deploy.GetNamespace())
+	assert.Empty(t, deploy.GetLabels())
+	replicas, _, _ := unstructured.NestedInt64(deploy.Object, "spec", "replicas")
+	assert.Equal(t, int64(5), replicas)
+	containers, _, _ := unstructured.NestedSlice(deploy.Object, "spec", "template", "spec", "containers")
+	container := containers[0].(map[string]interface{})
+	assert.Equal(t, "nginx:1.21", container["image"])
+	assert.Equal(t, []interface{}{nil, "true"}, container["args"])
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"name": "MISSING", "value": ""},
+		map[string]interface{}{"name": "CONFIG", "value": "key=value"},
+	}, container["env"])
+
+	assert.Equal(t, "release-svc", objects[2].GetName())
+	assert.Equal(t, map[string]string{"description": "a,b", "new-api": "true"}, objects[2].GetAnnotations())
+
+	objects, err = renderHelm(files, "chart", &Source{Helm: &v1alpha1.ApplicationSourceHelm{
+		SkipCrds: true,
+		Parameters: []v1alpha1.HelmParameter{
+			{Name: "service.enabled", Value: "false"},
+			{Name: "cache.enabled", Value: "true"},
+		},
+	}})
+	assert.NoError(t, err)
+	if assert.Len(t, objects, 2) {
+		assert.Equal(t, "chart-cache", objects[0].GetName())
+		assert.Equal(t, map[string]interface{}{"size": "1Gi"}, objects[0].Object["data"])
+		assert.Equal(t, "chart-web", objects[1].GetName())
+	}
+}
+
+func TestRenderHelm_errors(t *testing.T) {
+	chart := []byte("name: web\nversion: 0.1.0\n")
+	tests := []struct {
+		name            string
+		files           Files
+		opts            *v1alpha1.ApplicationSourceHelm
+		wantUnsupported bool
+	}{{
+		name: "dependencies not in the charts directory",
+		files: Files{
+			"chart/Chart.yaml": []byte("apiVersion: v2\nname: web\nversion: 0.1.0\ndependencies:\n- name: redis\n"),
+		},
+		wantUnsupported: true,
+	}, {
+		name: "library chart",
+		files: Files{
+			"chart/Chart.yaml": []byte("apiVersion: v2\nname: web\nversion: 0.1.0\ntype: library\n"),
+		},
+	}, {
+		name: "incompatible Kubernetes version",
+		files: Files{
+			"chart/Chart.yaml": []byte("apiVersion: v2\nname: web\nversion: 0.1.0\nkubeVersion: <1.0.0\n"),
+		},
+	}, {
+		name: "unknown function",
+		files: Files{
+			"chart/Chart.yaml":        chart,
+			"chart/templates/cm.yaml": []byte(`{{ unknown "a" }}`),
+		},
+	}, {
+		name: "remote value file",
+		files: Files{
+			"chart/Chart.yaml": chart,
+		},
+		opts:            &v1alpha1.ApplicationSourceHelm{ValueFiles: []string{"https://example.com/values.yaml"}},
+		wantUnsupported: true,
+	}, {
+		name: "missing value file",
+		files: Files{
+			"chart/Chart.yaml": chart,
+		},
+		opts: &v1alpha1.ApplicationSourceHelm{ValueFiles: []string{"none.yaml"}},
+	}, {
+		name: "required value",
+		files: Files{
+			"chart/Chart.yaml":        chart,
+			"chart/templates/cm.yaml": []byte(`{{ required "name is required" .Values.name }}`),
+		},
+	}, {
+		name: "no Chart.yaml",
+		files: Files{
+			"chart/templates/cm.yaml": []byte(""),
+		},
+		opts: &v1alpha1.ApplicationSourceHelm{},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := renderHelm(tt.files, "chart", &Source{Helm: tt.opts})
+			assert.Error(t, err)
+			assert.Equal(t, tt.wantUnsupported, IsUnsupported(err))
+		})
+	}
+}
+
+func TestHelmValues(t *testing.T) {
+	files := Files{
+		"chart/values-a.yaml": []byte("a:\n  b: c\n  d: e\nlist: [1, 2]\n"),
+		"values-b.yaml":       []byte("a:\n  d: null\n"),
+		"chart/config.txt":    []byte("key=value"),
+	}
+	values, err := helmValues(files, "chart", &v1alpha1.ApplicationSourceHelm{
+		ValueFiles: []string{"values-a.yaml", "../values-b.yaml"},
+		Values:     "list: [3]\n",
+		Parameters: []v1alpha1.HelmParameter{
+			{Name: "a.f", Value: "1"},
+			{Name: `annotations.example\.com/name`, Value: "x,y"},
+			{Name: "items[1].name", Value: "y"},
+			{Name: "hosts", Value: "{a,b}"},
+			{Name: "version", Value: "1", ForceString: true},
+		},
+		FileParameters: []v1alpha1.HelmFileParameter{{Name: "config", Path: "config.txt"}},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"a":           map[string]interface{}{"b": "c", "d": nil, "f": int64(1)},
+		"list":        []interface{}{float64(3)},
+		"annotations": map[string]interface{}{"example.com/name": "x,y"},
+		"items":       []interface{}{nil, map[string]interface{}{"name": "y"}},
+		"hosts":       []interface{}{"a", "b"},
+		"version":     "1",
+		"config":      "key=value",
+	}, values)
+
+	_, err = helmValues(files, "chart", &v1alpha1.ApplicationSourceHelm{
+		Parameters: []v1alpha1.HelmParameter{{Name: "items[100000]", Value: "z"}},
+	})
+	assert.Error(t, err)
+	_, err = helmValues(files, "chart", &v1alpha1.ApplicationSourceHelm{
+		FileParameters: []v1alpha1.HelmFileParameter{{Name: "config", Path: "none.txt"}},
+	})
+	assert.Error(t, err)
+}
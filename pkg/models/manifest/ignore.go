@@ -0,0 +1,301 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package manifest
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/kubesphere/ks-devops/pkg/api/gitops/v1alpha1"
+)
+
+// pathToken is an element of a field path, it selects a key of a map, or the items of a list
+type pathToken struct {
+	key      string
+	index    int
+	wildcard bool
+	// match selects the items of a list which contain all the fields
+	match map[string]interface{}
+	// value selects the items of a list which equal it
+	value    interface{}
+	hasValue bool
+}
+
+func keyToken(key string) pathToken {
+	return pathToken{key: key, index: -1}
+}
+
+func (t pathToken) isKey() bool {
+	return t.index < 0 && !t.wildcard && t.match == nil && !t.hasValue
+}
+
+// matchesItem returns true if the token selects the list item
+func (t pathToken) matchesItem(i int, item interface{}) bool {
+	switch {
+	case t.wildcard:
+		return true
+	case t.index >= 0:
+		return t.index == i
+	case t.match != nil:
+		itemMap, ok := item.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		for key, val := range t.match {
+			if !equalValue(val, itemMap[key]) {
+				return false
+			}
+		}
+		return true
+	case t.hasValue:
+		return equalValue(t.value, item)
+	}
+	return false
+}
+
+// removeField removes the fields selected by the path
+func removeField(node interface{}, tokens []pathToken) interface{} {
+	if len(tokens) == 0 {
+		return node
+	}
+	token, last := tokens[0], len(tokens) == 1
+
+	switch n := node.(type) {
+	case map[string]interface{}:
+		if !token.isKey() {
+			return n
+		}
+		if child, ok := n[token.key]; ok {
+			if last {
+				delete(n, token.key)
+			} else {
+				n[token.key] = removeField(child, tokens[1:])
+			}
+		}
+		return n
+	case []interface{}:
+		if token.isKey() {
+			// the keys of a JSON pointer are the indexes of a list
+			index, err := strconv.Atoi(token.key)
+			if err != nil {
+				return n
+			}
+			token = pathToken{index: index}
+		}
+		result := make([]interface{}, 0, len(n))
+		for i, item := range n {
+			if token.matchesItem(i, item) {
+				if last {
+					continue
+				}
+				item = removeField(item, tokens[1:])
+			}
+			result = append(result, item)
+		}
+		return result
+	}
+	return node
+}
+
+// ruleMatches returns true if the rule is for the object
+func ruleMatches(rule v1alpha1.ResourceIgnoreDifferences, obj *unstructured.Unstructured) bool {
+	gvk := obj.GroupVersionKind()
+	return rule.Group == gvk.Group && (rule.Kind == gvk.Kind || rule.Kind == "*") &&
+		(rule.Name == "" || rule.Name == obj.GetName()) &&
+		(rule.Namespace == "" || rule.Namespace == obj.GetNamespace())
+}
+
+// ignoredPaths returns the paths of the fields ignored by the rules, the managed fields are read from the live object
+func ignoredPaths(rules []v1alpha1.ResourceIgnoreDifferences, obj, live *unstructured.Unstructured) (paths [][]pathToken, err error) {
+	for _, rule := range rules {
+		if !ruleMatches(rule, obj) {
+			continue
+		}
+		for _, pointer := range rule.JSONPointers {
+			paths = append(paths, parseJSONPointer(pointer))
+		}
+		for _, expression := range rule.JQPathExpressions {
+			var tokens []pathToken
+			if tokens, err = parseJQPath(expression); err != nil {
+				return
+			}
+			paths = append(paths, tokens)
+		}
+		if live != nil && len(rule.ManagedFieldsManagers) > 0 {
+			var managed [][]pathToken
+			if managed, err = managedFieldPaths(live, rule.ManagedFieldsManagers); err != nil {
+				return
+			}
+			paths = append(paths, managed...)
+		}
+	}
+	return
+}
+
+// parseJSONPointer parses a pointer like "/spec/replicas"
+func parseJSONPointer(pointer string) (tokens []pathToken) {
+	for _, part := range strings.Split(strings.TrimPrefix(pointer, "/"), "/") {
+		part = strings.ReplaceAll(strings.ReplaceAll(part, "~1", "/"), "~0", "~")
+		tokens = append(tokens, keyToken(part))
+	}
+	return
+}
+
+// parseJQPath parses the simple path expressions of jq, like `.spec.template.spec.containers[].image` or
+// `.metadata.annotations["foo"]`, the filters and pipes are unsupported
+func parseJQPath(expression string) (tokens []pathToken, err error) {
+	unsupported := &UnsupportedError{Message: "jq path expression " + expression}
+	rest := strings.TrimSpace(expression)
+	if !strings.HasPrefix(rest, ".") {
+		return nil, unsupported
+	}
+
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			end := 1
+			for end < len(rest) && isIdentifierChar(rest[end]) {
+				end++
+			}
+			if end > 1 {
+				tokens = append(tokens, keyToken(rest[1:end]))
+			} else if len(rest) == 1 || rest[1] != '[' {
+				return nil, unsupported
+			}
+			rest = rest[end:]
+		case '[':
+			end := strings.Index(rest, "]")
+			if end < 0 {
+				return nil, unsupported
+			}
+			inner := strings.TrimSpace(rest[1:end])
+			switch {
+			case inner == "":
+				tokens = append(tokens, pathToken{index: -1, wildcard: true})
+			case strings.HasPrefix(inner, `"`):
+				key, unquoteErr := strconv.Unquote(inner)
+				if unquoteErr != nil {
+					return nil, unsupported
+				}
+				tokens = append(tokens, keyToken(key))
+			default:
+				index, atoiErr := strconv.Atoi(inner)
+				if atoiErr != nil || index < 0 {
+					return nil, unsupported
+				}
+				tokens = append(tokens, pathToken{index: index})
+			}
+			rest = rest[end+1:]
+		default:
+			return nil, unsupported
+		}
+	}
+	if len(tokens) == 0 {
+		return nil, unsupported
+	}
+	return
+}
+
+func isIdentifierChar(c byte) bool {
+	return c == '_' || c == '-' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+// managedFieldPaths returns the paths of the fields owned by the managers, it reads the FieldsV1 of the live object
+func managedFieldPaths(live *unstructured.Unstructured, managers []string) (paths [][]pathToken, err error) {
+	for _, entry := range live.GetManagedFields() {
+		if !contains(managers, entry.Manager) || entry.FieldsV1 == nil {
+			continue
+		}
+		fields := map[string]interface{}{}
+		if err = json.Unmarshal(entry.FieldsV1.Raw, &fields); err != nil {
+			err = fmt.Errorf("failed to parse the managed fields of %s: %v", entry.Manager, err)
+			return
+		}
+		if err = collectFieldPaths(fields, nil, &paths); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// collectFieldPaths walks a FieldsV1 set, a leaf is a field without the owned children
+func collectFieldPaths(fields map[string]interface{}, prefix []pathToken, paths *[][]pathToken) error {
+	for _, key := range sortedKeys(fields) {
+		if key == "." {
+			continue
+		}
+		token, err := parseFieldKey(key)
+		if err != nil {
+			return err
+		}
+		path := append(append([]pathToken{}, prefix...), token)
+
+		children, _ := fields[key].(map[string]interface{})
+		hasChildren := false
+		for child := range children {
+			if child != "." {
+				hasChildren = true
+				break
+			}
+		}
+		if !hasChildren {
+			*paths = append(*paths, path)
+			continue
+		}
+		if err = collectFieldPaths(children, path, paths); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseFieldKey parses a key of FieldsV1, see also sigs.k8s.io/structured-merge-diff
+func parseFieldKey(key string) (token pathToken, err error) {
+	if len(key) < 2 || key[1] != ':' {
+		err = fmt.Errorf("invalid managed field %s", key)
+		return
+	}
+	kind, val := key[0], key[2:]
+	switch kind {
+	case 'f':
+		token = keyToken(val)
+	case 'i':
+		token.index, err = strconv.Atoi(val)
+	case 'k':
+		token.index = -1
+		err = json.Unmarshal([]byte(val), &token.match)
+	case 'v':
+		token.index, token.hasValue = -1, true
+		err = json.Unmarshal([]byte(val), &token.value)
+	default:
+		err = fmt.Errorf("invalid managed field %s", key)
+	}
+	return
+}
+
+func contains(items []string, item string) bool {
+	for _, i := range items {
+		if i == item {
+			return true
+		}
+	}
+	return false
+}
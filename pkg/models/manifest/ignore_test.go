@@ -0,0 +1,99 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package manifest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseJQPath(t *testing.T) {
+	tests := []struct {
+		expression      string
+		want            []pathToken
+		wantUnsupported bool
+	}{{
+		expression: ".spec.replicas",
+		want:       []pathToken{keyToken("spec"), keyToken("replicas")},
+	}, {
+		expression: `.spec.containers[].env[0].value`,
+		want: []pathToken{keyToken("spec"), keyToken("containers"), {index: -1, wildcard: true}, keyToken("env"),
+			{index: 0}, keyToken("value")},
+	}, {
+		expression: `.metadata.annotations["example.com/name"]`,
+		want:       []pathToken{keyToken("metadata"), keyToken("annotations"), keyToken("example.com/name")},
+	}, {
+		expression:      `.spec.containers[] | select(.name == "web")`,
+		wantUnsupported: true,
+	}, {
+		expression:      "spec",
+		wantUnsupported: true,
+	}, {
+		expression:      ".spec[abc]",
+		wantUnsupported: true,
+	}, {
+		expression:      ".",
+		wantUnsupported: true,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			got, err := parseJQPath(tt.expression)
+			assert.Equal(t, tt.wantUnsupported, IsUnsupported(err))
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestParseJSONPointer(t *testing.T) {
+	assert.Equal(t, []pathToken{keyToken("metadata"), keyToken("annotations"), keyToken("a/b~c")},
+		parseJSONPointer("/metadata/annotations/a~1b~0c"))
+}
+
+func TestRemoveField(t *testing.T) {
+	obj := map[string]interface{}{
+		"list": []interface{}{
+			map[string]interface{}{"name": "a", "value": "1"},
+			map[string]interface{}{"name": "b", "value": "2"},
+		},
+		"values": []interface{}{"x", "y"},
+	}
+	removeField(obj, parseJSONPointer("/list/1/value"))
+	removeField(obj, []pathToken{keyToken("values"), {index: -1, value: "x", hasValue: true}})
+	removeField(obj, []pathToken{keyToken("list"), {index: -1, match: map[string]interface{}{"name": "a"}}})
+	removeField(obj, parseJSONPointer("/none/field"))
+	assert.Equal(t, map[string]interface{}{
+		"list":   []interface{}{map[string]interface{}{"name": "b"}},
+		"values": []interface{}{"y"},
+	}, obj)
+}
+
+func TestParseFieldKey(t *testing.T) {
+	token, err := parseFieldKey(`k:{"containerPort":80,"protocol":"TCP"}`)
+	assert.NoError(t, err)
+	assert.True(t, token.matchesItem(0, map[string]interface{}{"containerPort": int64(80), "protocol": "TCP"}))
+	assert.False(t, token.matchesItem(0, map[string]interface{}{"containerPort": int64(81), "protocol": "TCP"}))
+
+	token, err = parseFieldKey("i:1")
+	assert.NoError(t, err)
+	assert.True(t, token.matchesItem(1, nil))
+
+	_, err = parseFieldKey("x:1")
+	assert.Error(t, err)
+	_, err = parseFieldKey("f")
+	assert.Error(t, err)
+}
@@ -0,0 +1,321 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package manifest
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/kustomize/api/krusty"
+	"sigs.k8s.io/kustomize/api/types"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+	"sigs.k8s.io/yaml"
+
+	"github.com/kubesphere/ks-devops/pkg/api/gitops/v1alpha1"
+)
+
+// kustomizationFileNames are the recognized names of the Kustomization file
+var kustomizationFileNames = []string{"kustomization.yaml", "kustomization.yml", "Kustomization"}
+
+// generatedResourcesFile holds the manifests of a directory in the generated Kustomization
+const generatedResourcesFile = "resources.yaml"
+
+// kustomizeImage is an image override of Kustomize
+type kustomizeImage struct {
+	Name    string
+	NewName string
+	NewTag  string
+	Digest  string
+}
+
+func findKustomization(files Files, dir string) string {
+	for _, name := range kustomizationFileNames {
+		if file := path.Join(dir, name); files.Exists(file) {
+			return cleanPath(file)
+		}
+	}
+	return ""
+}
+
+// renderKustomize builds the Kustomization in the directory like "kustomize build". The overrides of the Application
+// are set into the Kustomization first, it's the same as "kustomize edit" of Argo CD.
+func renderKustomize(files Files, dir string, opts *v1alpha1.ApplicationSourceKustomize) (objects []*unstructured.Unstructured, err error) {
+	file := findKustomization(files, dir)
+	if file == "" {
+		err = fmt.Errorf("no kustomization found in %s", dir)
+		return
+	}
+	if err = checkRemoteKustomizations(files, dir, map[string]bool{}); err != nil {
+		return
+	}
+
+	fs := filesys.MakeFsInMemory()
+	for name, data := range files {
+		if err = fs.WriteFile("/"+name, data); err != nil {
+			return
+		}
+	}
+	if opts != nil {
+		var data []byte
+		if data, err = editKustomization(files[file], opts); err != nil {
+			return
+		}
+		if err = fs.WriteFile("/"+file, data); err != nil {
+			return
+		}
+	}
+	return buildKustomization(fs, "/"+dir)
+}
+
+// applyKustomizeOverrides applies the name prefix and suffix, common labels and annotations, and images of an
+// Application to the manifests of a directory through a generated Kustomization
+func applyKustomizeOverrides(objects []*unstructured.Unstructured, opts *v1alpha1.ApplicationSourceKustomize) (
+	[]*unstructured.Unstructured, error) {
+	if opts == nil || len(objects) == 0 {
+		return objects, nil
+	}
+
+	var resources bytes.Buffer
+	for _, obj := range objects {
+		data, err := yaml.Marshal(obj.Object)
+		if err != nil {
+			return nil, err
+		}
+		resources.WriteString("---\n")
+		resources.Write(data)
+	}
+	data, err := editKustomization([]byte("resources:\n- "+generatedResourcesFile+"\n"), opts)
+	if err != nil {
+		return nil, err
+	}
+
+	fs := filesys.MakeFsInMemory()
+	if err = fs.WriteFile("/"+generatedResourcesFile, resources.Bytes()); err == nil {
+		err = fs.WriteFile("/kustomization.yaml", data)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return buildKustomization(fs, "/")
+}
+
+func buildKustomization(fs filesys.FileSystem, dir string) (objects []*unstructured.Unstructured, err error) {
+	resources, err := krusty.MakeKustomizer(krusty.MakeDefaultOptions()).Run(fs, dir)
+	if err != nil {
+		err = fmt.Errorf("failed to build the kustomization in %s: %v", strings.TrimPrefix(dir, "/"), err)
+		return
+	}
+	var data []byte
+	if data, err = resources.AsYaml(); err == nil {
+		objects, err = decode(dir, data)
+	}
+	return
+}
+
+// editKustomization sets the overrides of an Application into a Kustomization like "kustomize edit". The name prefix
+// and suffix are replaced, the images are replaced by their names, the common labels and annotations are added, the
+// existing ones are replaced only if it's forced.
+func editKustomization(data []byte, opts *v1alpha1.ApplicationSourceKustomize) (result []byte, err error) {
+	if opts.Version != "" {
+		err = &UnsupportedError{Message: "kustomize version " + opts.Version}
+		return
+	}
+
+	k := map[string]interface{}{}
+	if err = yaml.Unmarshal(data, &k); err != nil {
+		err = fmt.Errorf("failed to parse the kustomization: %v", err)
+		return
+	}
+	if opts.NamePrefix != "" {
+		k["namePrefix"] = opts.NamePrefix
+	}
+	if opts.NameSuffix != "" {
+		k["nameSuffix"] = opts.NameSuffix
+	}
+	if err = addKustomizeStrings(k, "commonLabels", opts.CommonLabels, opts.ForceCommonLabels); err != nil {
+		return
+	}
+	if err = addKustomizeStrings(k, "commonAnnotations", opts.CommonAnnotations, opts.ForceCommonAnnotations); err != nil {
+		return
+	}
+
+	images, _ := k["images"].([]interface{})
+	for _, item := range opts.Images {
+		image := parseKustomizeImage(string(item))
+		override := map[string]interface{}{"name": image.Name}
+		for key, val := range map[string]string{"newName": image.NewName, "newTag": image.NewTag, "digest": image.Digest} {
+			if val != "" {
+				override[key] = val
+			}
+		}
+
+		replaced := false
+		for i, existing := range images {
+			if existing, ok := existing.(map[string]interface{}); ok && existing["name"] == image.Name {
+				images[i], replaced = override, true
+				break
+			}
+		}
+		if !replaced {
+			images = append(images, override)
+		}
+	}
+	if len(images) > 0 {
+		k["images"] = images
+	}
+	result, err = yaml.Marshal(k)
+	return
+}
+
+func addKustomizeStrings(k map[string]interface{}, field string, values map[string]string, force bool) error {
+	if len(values) == 0 {
+		return nil
+	}
+	existing, _ := k[field].(map[string]interface{})
+	if existing == nil {
+		existing = map[string]interface{}{}
+	}
+	for _, key := range sortedStringKeys(values) {
+		if _, ok := existing[key]; ok && !force {
+			return fmt.Errorf("%s %s already in the kustomization, it's replaced only if it's forced", field, key)
+		}
+		existing[key] = values[key]
+	}
+	k[field] = existing
+	return nil
+}
+
+func sortedStringKeys(m map[string]string) (keys []string) {
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return
+}
+
+// checkRemoteKustomizations checks the Kustomization in the directory and the ones it refers to, the remote resources
+// are unsupported. Kustomize clones the repositories or downloads the files, they are never fetched by the preview.
+func checkRemoteKustomizations(files Files, dir string, checked map[string]bool) error {
+	file := findKustomization(files, dir)
+	if file == "" || checked[file] {
+		return nil
+	}
+	checked[file] = true
+
+	k := &types.Kustomization{}
+	if err := yaml.Unmarshal(files[file], k); err != nil {
+		return fmt.Errorf("failed to parse %s: %v", file, err)
+	}
+	for _, ref := range kustomizationReferences(k) {
+		if isRemoteResource(ref) {
+			return &UnsupportedError{Message: fmt.Sprintf("remote kustomize resource %s in %s", ref, file)}
+		}
+	}
+	for _, ref := range append(append(k.Resources, k.Bases...), k.Components...) {
+		if target := cleanPath(path.Join(dir, ref)); files.IsDir(target) {
+			if err := checkRemoteKustomizations(files, target, checked); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// kustomizationReferences returns the paths which are loaded by Kustomize, the inline patches and plugins are skipped
+func kustomizationReferences(k *types.Kustomization) (refs []string) {
+	refs = append(refs, k.Resources...)
+	refs = append(refs, k.Bases...)
+	refs = append(refs, k.Components...)
+	refs = append(refs, k.Crds...)
+	refs = append(refs, k.Configurations...)
+	refs = append(refs, k.Generators...)
+	refs = append(refs, k.Transformers...)
+	refs = append(refs, k.Validators...)
+	refs = append(refs, k.OpenAPI["path"])
+	for _, patch := range k.PatchesStrategicMerge {
+		refs = append(refs, string(patch))
+	}
+	for _, patch := range append(k.Patches, k.PatchesJson6902...) {
+		refs = append(refs, patch.Path)
+	}
+	for _, replacement := range k.Replacements {
+		refs = append(refs, replacement.Path)
+	}
+	var sources []types.KvPairSources
+	for _, generator := range k.ConfigMapGenerator {
+		sources = append(sources, generator.KvPairSources)
+	}
+	for _, generator := range k.SecretGenerator {
+		sources = append(sources, generator.KvPairSources)
+	}
+	for _, source := range sources {
+		for _, file := range source.FileSources {
+			// the file source is like [{key}=]{path}
+			refs = append(refs, file[strings.Index(file, "=")+1:])
+		}
+		refs = append(refs, source.EnvSources...)
+		refs = append(refs, source.EnvSource)
+	}
+	return
+}
+
+// remoteUserRegex matches the user of a SCP-like Git URL, like "git@github.com:org/repo"
+var remoteUserRegex = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9-]*@`)
+
+// isRemoteResource returns true if Kustomize takes it as a Git repository or a URL
+func isRemoteResource(resource string) bool {
+	if strings.Contains(resource, "\n") {
+		// an inline patch or plugin
+		return false
+	}
+	resource = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(resource)), "git::")
+	return strings.Contains(resource, "://") || strings.HasPrefix(resource, "github.com/") ||
+		strings.HasPrefix(resource, "github.com:") || remoteUserRegex.MatchString(resource)
+}
+
+// parseKustomizeImage parses the image override of an Application, the format is [old_image_name=]<image_name>:<tag>
+// or [old_image_name=]<image_name>@<digest>
+func parseKustomizeImage(image string) (result kustomizeImage) {
+	name, override := image, image
+	if i := strings.Index(image, "="); i >= 0 {
+		name, override = image[:i], image[i+1:]
+	}
+	result.Name, _, _ = splitImage(name)
+	newName, tag, digest := splitImage(override)
+	if newName != result.Name {
+		result.NewName = newName
+	}
+	result.NewTag, result.Digest = tag, digest
+	return
+}
+
+// splitImage splits an image reference into the name, tag and digest
+func splitImage(image string) (name, tag, digest string) {
+	name = image
+	if i := strings.Index(name, "@"); i >= 0 {
+		name, digest = name[:i], name[i+1:]
+	}
+	if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		name, tag = name[:i], name[i+1:]
+	}
+	return
+}
@@ -0,0 +1,211 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package manifest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/kubesphere/ks-devops/pkg/api/gitops/v1alpha1"
+)
+
+const deploymentYAML = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  selector:
+    matchLabels:
+      app: web
+  template:
+    metadata:
+      labels:
+        app: web
+    spec:
+      containers:
+      - name: web
+        image: nginx:1.20
+      - name: sidecar
+        image: busybox
+`
+
+func TestRenderKustomize(t *testing.T) {
+	files := Files{
+		"base/kustomization.yaml": []byte(`resources:
+- deploy.yaml
+commonLabels:
+  tier: frontend
+configMapGenerator:
+- name: config
+  literals:
+  - key=value
+`),
+		"base/deploy.yaml": []byte(deploymentYAML),
+		"overlays/dev/kustomization.yml": []byte(`bases:
+- ../../base
+namespace: dev
+nameSuffix: -v1
+commonAnnotations:
+  owner: team
+images:
+- name: busybox
+  newTag: "1.36"
+patches:
+- target:
+    kind: Deployment
+  patch: |-
+    - op: add
+      path: /spec/template/spec/volumes
+      value: [{name: config, configMap: {name: config}}]
+`),
+		"remote/kustomization.yaml":        []byte("resources:\n- github.com/org/repo/app?ref=main\n"),
+		"remote-base/kustomization.yaml":   []byte("resources:\n- ../remote\n"),
+		"remote-patch/kustomization.yaml":  []byte("patches:\n- path: https://example.com/patch.yaml\n"),
+		"cycle/kustomization.yaml":         []byte("resources:\n- ../cycle\n"),
+		"missing/kustomization.yaml":       []byte("resources:\n- none.yaml\n"),
+		"no-kustomization/deploy.yaml":     []byte(deploymentYAML),
+		"helm-generator/kustomization.yml": []byte("helmCharts:\n- name: web\n"),
+	}
+
+	objects, err := renderKustomize(files, "overlays/dev", &v1alpha1.ApplicationSourceKustomize{
+		NamePrefix:   "my-",
+		Images:       v1alpha1.KustomizeImages{"nginx=registry.io/nginx:1.21"},
+		CommonLabels: map[string]string{"env": "dev"},
+	})
+	assert.NoError(t, err)
+	resources := map[string]*unstructured.Unstructured{}
+	for _, obj := range objects {
+		resources[obj.GetKind()] = obj
+	}
+	if assert.Len(t, objects, 2) && assert.Contains(t, resources, "Deployment") && assert.Contains(t, resources, "ConfigMap") {
+		obj := resources["Deployment"]
+		assert.Equal(t, "my-web-v1", obj.GetName())
+		assert.Equal(t, "dev", obj.GetNamespace())
+		labels := map[string]string{"app": "web", "tier": "frontend", "env": "dev"}
+		assert.Equal(t, map[string]string{"tier": "frontend", "env": "dev"}, obj.GetLabels())
+		assert.Equal(t, map[string]string{"owner": "team"}, obj.GetAnnotations())
+		selector, _, _ := unstructured.NestedStringMap(obj.Object, "spec", "selector", "matchLabels")
+		assert.Equal(t, labels, selector)
+		templateLabels, _, _ := unstructured.NestedStringMap(obj.Object, "spec", "template", "metadata", "labels")
+		assert.Equal(t, labels, templateLabels)
+		containers, _, _ := unstructured.NestedSlice(obj.Object, "spec", "template", "spec", "containers")
+		assert.Equal(t, "registry.io/nginx:1.21", containers[0].(map[string]interface{})["image"])
+		assert.Equal(t, "busybox:1.36", containers[1].(map[string]interface{})["image"])
+
+		// the generated ConfigMap is renamed with a hash, and the reference to it is updated
+		configMap := resources["ConfigMap"]
+		assert.Regexp(t, "^my-config-v1-[a-z0-9]+$", configMap.GetName())
+		volumes, _, _ := unstructured.NestedSlice(obj.Object, "spec", "template", "spec", "volumes")
+		if assert.Len(t, volumes, 1) {
+			name, _, _ := unstructured.NestedString(volumes[0].(map[string]interface{}), "configMap", "name")
+			assert.Equal(t, configMap.GetName(), name)
+		}
+	}
+
+	for _, dir := range []string{"remote", "remote-base", "remote-patch"} {
+		_, err = renderKustomize(files, dir, nil)
+		assert.True(t, IsUnsupported(err), dir)
+	}
+	_, err = renderKustomize(files, "base", &v1alpha1.ApplicationSourceKustomize{Version: "v4"})
+	assert.True(t, IsUnsupported(err))
+	for _, dir := range []string{"cycle", "missing", "no-kustomization", "helm-generator"} {
+		_, err = renderKustomize(files, dir, nil)
+		assert.Error(t, err, dir)
+	}
+
+	// the existing common labels are replaced only if it's forced
+	_, err = renderKustomize(files, "base", &v1alpha1.ApplicationSourceKustomize{
+		CommonLabels: map[string]string{"tier": "backend"},
+	})
+	assert.Error(t, err)
+	objects, err = renderKustomize(files, "base", &v1alpha1.ApplicationSourceKustomize{
+		CommonLabels:      map[string]string{"tier": "backend"},
+		ForceCommonLabels: true,
+	})
+	assert.NoError(t, err)
+	if assert.NotEmpty(t, objects) {
+		assert.Equal(t, "backend", objects[0].GetLabels()["tier"])
+	}
+}
+
+func TestParseKustomizeImage(t *testing.T) {
+	tests := []struct {
+		image string
+		want  kustomizeImage
+	}{{
+		image: "nginx:1.21",
+		want:  kustomizeImage{Name: "nginx", NewTag: "1.21"},
+	}, {
+		image: "nginx=registry.io:5000/nginx:1.21",
+		want:  kustomizeImage{Name: "nginx", NewName: "registry.io:5000/nginx", NewTag: "1.21"},
+	}, {
+		image: "nginx@sha256:abc",
+		want:  kustomizeImage{Name: "nginx", Digest: "sha256:abc"},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.image, func(t *testing.T) {
+			assert.Equal(t, tt.want, parseKustomizeImage(tt.image))
+		})
+	}
+}
+
+func TestEditKustomization(t *testing.T) {
+	data, err := editKustomization([]byte(`resources:
+- deploy.yaml
+images:
+- name: nginx
+  newTag: "1.20"
+- name: busybox
+  newTag: "1.36"
+`), &v1alpha1.ApplicationSourceKustomize{
+		NameSuffix:        "-v1",
+		Images:            v1alpha1.KustomizeImages{"nginx@sha256:abc", "redis:7"},
+		CommonAnnotations: map[string]string{"owner": "team"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, `commonAnnotations:
+  owner: team
+images:
+- digest: sha256:abc
+  name: nginx
+- name: busybox
+  newTag: "1.36"
+- name: redis
+  newTag: "7"
+nameSuffix: -v1
+resources:
+- deploy.yaml
+`, string(data))
+}
+
+func TestIsRemoteResource(t *testing.T) {
+	for _, resource := range []string{
+		"github.com/org/repo/app?ref=main",
+		"GitHub.com:org/repo",
+		"git@gitlab.com:org/repo.git",
+		"git::https://gitlab.com/org/repo",
+		"https://example.com/deploy.yaml",
+		"ssh://git@example.com/org/repo",
+	} {
+		assert.True(t, isRemoteResource(resource), resource)
+	}
+	for _, resource := range []string{"", "deploy.yaml", "../base", "a=config/app.properties", "- op: add\n  path: /a"} {
+		assert.False(t, isRemoteResource(resource), resource)
+	}
+}
@@ -0,0 +1,253 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package manifest renders the manifests of a GitOps Application from the files of its source, and compares them
+// with the live objects. Kustomize and Helm are rendered by their libraries, what needs the network or the plugins is
+// reported as an UnsupportedError.
+package manifest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/yaml"
+
+	"github.com/kubesphere/ks-devops/pkg/api/gitops/v1alpha1"
+)
+
+// Files are the files of a source, the keys are the slash-separated paths relative to the root of the repository
+type Files map[string][]byte
+
+// Exists returns true if the file exists
+func (f Files) Exists(name string) bool {
+	_, ok := f[cleanPath(name)]
+	return ok
+}
+
+// Get returns the content of a file
+func (f Files) Get(name string) ([]byte, error) {
+	data, ok := f[cleanPath(name)]
+	if !ok {
+		return nil, fmt.Errorf("file %s not found", name)
+	}
+	return data, nil
+}
+
+// IsDir returns true if there is any file in the directory
+func (f Files) IsDir(dir string) bool {
+	prefix := dirPrefix(dir)
+	for name := range f {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// List returns the sorted paths of the files in a directory, the files in the subdirectories are included if it's
+// recursive
+func (f Files) List(dir string, recursive bool) (names []string) {
+	prefix := dirPrefix(dir)
+	for name := range f {
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		if recursive || !strings.Contains(name[len(prefix):], "/") {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return
+}
+
+func cleanPath(name string) string {
+	return strings.TrimPrefix(path.Clean("/"+name), "/")
+}
+
+func dirPrefix(dir string) string {
+	if dir = cleanPath(dir); dir == "" {
+		return ""
+	}
+	return dir + "/"
+}
+
+// Source describes how to render the manifests, it's engine-neutral
+type Source struct {
+	// Path is the directory of the manifests, the Kustomization or the Helm chart
+	Path      string
+	Directory *v1alpha1.ApplicationSourceDirectory
+	Kustomize *v1alpha1.ApplicationSourceKustomize
+	Helm      *v1alpha1.ApplicationSourceHelm
+	// Namespace is the default namespace of the namespaced resources
+	Namespace string
+	// ReleaseName is the name of the Helm release
+	ReleaseName string
+	// Substitute are the variables which replace the ${var} in the manifests
+	Substitute map[string]string
+}
+
+// UnsupportedError means the source might be valid, but it's out of the supported subset
+type UnsupportedError struct {
+	Message string
+}
+
+func (e *UnsupportedError) Error() string {
+	return fmt.Sprintf("unsupported: %s", e.Message)
+}
+
+// IsUnsupported returns true if the error is an UnsupportedError
+func IsUnsupported(err error) bool {
+	var unsupported *UnsupportedError
+	return errors.As(err, &unsupported)
+}
+
+// Render renders the manifests of a source. It's a Helm chart if there is Chart.yaml or the Helm options, a
+// Kustomization if there is kustomization.yaml or only the Kustomize options, otherwise it's a directory of manifests.
+func Render(files Files, source *Source) (objects []*unstructured.Unstructured, err error) {
+	dir := cleanPath(source.Path)
+	if !files.IsDir(dir) {
+		return nil, fmt.Errorf("path %s not found", source.Path)
+	}
+
+	switch {
+	case source.Helm != nil || files.Exists(path.Join(dir, "Chart.yaml")):
+		objects, err = renderHelm(files, dir, source)
+	case findKustomization(files, dir) != "" || source.Kustomize != nil && source.Directory == nil:
+		objects, err = renderKustomize(files, dir, source.Kustomize)
+	default:
+		// the Kustomize options are applied to a directory like a generated Kustomization
+		if objects, err = renderDirectory(files, dir, source.Directory); err == nil {
+			objects, err = applyKustomizeOverrides(objects, source.Kustomize)
+		}
+	}
+	if err != nil {
+		return
+	}
+
+	if source.Substitute != nil {
+		if objects, err = substitute(objects, source.Substitute); err != nil {
+			return
+		}
+	}
+	for _, obj := range objects {
+		if obj.GetNamespace() == "" && source.Namespace != "" && !IsClusterScoped(obj) {
+			obj.SetNamespace(source.Namespace)
+		}
+	}
+	return
+}
+
+// substituteDisabledAnnotation disables the variable substitution of a resource, it's the same as the one of Flux
+const substituteDisabledAnnotation = "kustomize.toolkit.fluxcd.io/substitute"
+
+var variableRegex = regexp.MustCompile(`\$\{([a-zA-Z_][a-zA-Z0-9_]*)(?::?[=-]([^}]*))?\}`)
+
+// substitute replaces the ${var} and ${var:=default} in the manifests like the post build of Flux
+func substitute(objects []*unstructured.Unstructured, vars map[string]string) (result []*unstructured.Unstructured, err error) {
+	for _, obj := range objects {
+		if obj.GetAnnotations()[substituteDisabledAnnotation] == "disabled" {
+			result = append(result, obj)
+			continue
+		}
+
+		var data []byte
+		if data, err = yaml.Marshal(obj.Object); err != nil {
+			return
+		}
+		data = variableRegex.ReplaceAllFunc(data, func(match []byte) []byte {
+			groups := variableRegex.FindSubmatch(match)
+			if val, ok := vars[string(groups[1])]; ok {
+				return []byte(val)
+			}
+			return groups[2]
+		})
+
+		var items []*unstructured.Unstructured
+		if items, err = decode(obj.GetName(), data); err != nil {
+			return
+		}
+		result = append(result, items...)
+	}
+	return
+}
+
+// decode decodes the YAML or JSON documents, the empty documents are skipped and the lists are expanded
+func decode(name string, data []byte) (objects []*unstructured.Unstructured, err error) {
+	decoder := utilyaml.NewYAMLOrJSONDecoder(bytes.NewReader(data), 4096)
+	for {
+		var raw json.RawMessage
+		if err = decoder.Decode(&raw); err != nil {
+			if err == io.EOF {
+				err = nil
+				return
+			}
+			err = fmt.Errorf("failed to decode %s: %v", name, err)
+			return
+		}
+		if doc := string(bytes.TrimSpace(raw)); doc == "" || doc == "null" || doc == "{}" {
+			continue
+		}
+
+		var obj runtime.Object
+		if obj, _, err = unstructured.UnstructuredJSONScheme.Decode(raw, nil, nil); err != nil {
+			err = fmt.Errorf("failed to decode %s: %v", name, err)
+			return
+		}
+		switch item := obj.(type) {
+		case *unstructured.Unstructured:
+			objects = append(objects, item)
+		case *unstructured.UnstructuredList:
+			for i := range item.Items {
+				objects = append(objects, &item.Items[i])
+			}
+		}
+	}
+}
+
+// clusterScopedKinds are the well-known kinds which are not namespaced
+var clusterScopedKinds = map[string]bool{
+	"Namespace":                      true,
+	"Node":                           true,
+	"PersistentVolume":               true,
+	"ClusterRole":                    true,
+	"ClusterRoleBinding":             true,
+	"CustomResourceDefinition":       true,
+	"StorageClass":                   true,
+	"PriorityClass":                  true,
+	"IngressClass":                   true,
+	"RuntimeClass":                   true,
+	"APIService":                     true,
+	"MutatingWebhookConfiguration":   true,
+	"ValidatingWebhookConfiguration": true,
+	"PodSecurityPolicy":              true,
+	"CSIDriver":                      true,
+	"VolumeSnapshotClass":            true,
+}
+
+// IsClusterScoped returns true if the object is a well-known cluster-scoped one
+func IsClusterScoped(obj *unstructured.Unstructured) bool {
+	return clusterScopedKinds[obj.GetKind()]
+}
@@ -0,0 +1,193 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package manifest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/kubesphere/ks-devops/pkg/api/gitops/v1alpha1"
+)
+
+func TestFiles(t *testing.T) {
+	files := Files{
+		"a/b.yaml":   nil,
+		"a/c/d.yaml": nil,
+		"e.yaml":     nil,
+	}
+	assert.True(t, files.Exists("/a/b.yaml"))
+	assert.True(t, files.Exists("./a/../e.yaml"))
+	assert.False(t, files.Exists("a"))
+	assert.True(t, files.IsDir("a"))
+	assert.True(t, files.IsDir(""))
+	assert.False(t, files.IsDir("a/b"))
+	assert.Equal(t, []string{"a/b.yaml"}, files.List("a", false))
+	assert.Equal(t, []string{"a/b.yaml", "a/c/d.yaml"}, files.List("a/", true))
+	assert.Equal(t, []string{"a/b.yaml", "a/c/d.yaml", "e.yaml"}, files.List(".", true))
+	_, err := files.Get("f.yaml")
+	assert.Error(t, err)
+}
+
+func TestRender(t *testing.T) {
+	files := Files{
+		"plain/cm.yaml": []byte(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: cm
+---
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: ns
+`),
+		"kustomize/kustomization.yaml": []byte(`resources:
+- cm.yaml
+namePrefix: dev-
+`),
+		"kustomize/cm.yaml": []byte(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: cm
+`),
+		"chart/Chart.yaml": []byte(`name: chart
+version: 0.1.0
+`),
+		"chart/templates/cm.yaml": []byte(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: {{ .Release.Name }}
+`),
+	}
+
+	tests := []struct {
+		name      string
+		source    *Source
+		wantNames []string
+		wantNs    []string
+		wantErr   bool
+	}{{
+		name:      "directory",
+		source:    &Source{Path: "plain", Namespace: "default"},
+		wantNames: []string{"cm", "ns"},
+		wantNs:    []string{"default", ""},
+	}, {
+		name:      "kustomization",
+		source:    &Source{Path: "kustomize", Namespace: "default"},
+		wantNames: []string{"dev-cm"},
+		wantNs:    []string{"default"},
+	}, {
+		name:      "chart",
+		source:    &Source{Path: "chart", ReleaseName: "release"},
+		wantNames: []string{"release"},
+		wantNs:    []string{""},
+	}, {
+		name:    "a directory as a Kustomization",
+		source:  &Source{Path: "plain", Kustomize: &v1alpha1.ApplicationSourceKustomize{}},
+		wantErr: true,
+	}, {
+		name: "a directory with the Kustomize options",
+		source: &Source{Path: "plain", Directory: &v1alpha1.ApplicationSourceDirectory{},
+			Kustomize: &v1alpha1.ApplicationSourceKustomize{NameSuffix: "-v1"}},
+		wantNames: []string{"cm-v1", "ns"},
+		wantNs:    []string{"", ""},
+	}, {
+		name:    "path not found",
+		source:  &Source{Path: "none"},
+		wantErr: true,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			objects, err := Render(files, tt.source)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			var names, namespaces []string
+			for _, obj := range objects {
+				names = append(names, obj.GetName())
+				namespaces = append(namespaces, obj.GetNamespace())
+			}
+			assert.Equal(t, tt.wantNames, names)
+			assert.Equal(t, tt.wantNs, namespaces)
+		})
+	}
+}
+
+func TestDecode(t *testing.T) {
+	objects, err := decode("list.json", []byte(`{"apiVersion": "v1", "kind": "List", "items": [
+{"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "a"}},
+{"apiVersion": "v1", "kind": "Secret", "metadata": {"name": "b"}}]}`))
+	assert.NoError(t, err)
+	if assert.Len(t, objects, 2) {
+		assert.Equal(t, "ConfigMap", objects[0].GetKind())
+		assert.Equal(t, "b", objects[1].GetName())
+	}
+
+	objects, err = decode("empty.yaml", []byte("---\n# comment\n---\n"))
+	assert.NoError(t, err)
+	assert.Empty(t, objects)
+
+	_, err = decode("invalid.yaml", []byte("metadata:\n  name: a\n"))
+	assert.Error(t, err)
+}
+
+func TestIsUnsupported(t *testing.T) {
+	err := error(&UnsupportedError{Message: "jsonnet"})
+	assert.True(t, IsUnsupported(err))
+	assert.Equal(t, "unsupported: jsonnet", err.Error())
+	assert.False(t, IsUnsupported(assert.AnError))
+}
+
+func TestSubstitute(t *testing.T) {
+	files := Files{
+		"app/deploy.yaml": []byte(`apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web-${env}
+spec:
+  replicas: ${replicas:=1}
+  template:
+    spec:
+      containers:
+      - name: web
+        image: nginx:${tag}
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: script
+  annotations:
+    kustomize.toolkit.fluxcd.io/substitute: disabled
+data:
+  run.sh: echo ${HOME}
+`),
+	}
+	objects, err := Render(files, &Source{Path: "app", Substitute: map[string]string{"env": "dev", "tag": "1.21"}})
+	assert.NoError(t, err)
+	if assert.Len(t, objects, 2) {
+		assert.Equal(t, "web-dev", objects[0].GetName())
+		replicas, _, _ := unstructured.NestedInt64(objects[0].Object, "spec", "replicas")
+		assert.Equal(t, int64(1), replicas)
+		containers, _, _ := unstructured.NestedSlice(objects[0].Object, "spec", "template", "spec", "containers")
+		assert.Equal(t, "nginx:1.21", containers[0].(map[string]interface{})["image"])
+		script, _, _ := unstructured.NestedString(objects[1].Object, "data", "run.sh")
+		assert.Equal(t, "echo ${HOME}", script)
+	}
+}
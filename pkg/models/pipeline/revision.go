@@ -0,0 +1,223 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pmezard/go-difflib/difflib"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+
+	"github.com/kubesphere/ks-devops/pkg/api/devops/v1alpha3"
+)
+
+// maxRevisionAttempts limits the attempts to create a revision when the revision number was taken by others
+const maxRevisionAttempts = 5
+
+// GetSpecHash returns the hash of a Pipeline spec
+func GetSpecHash(spec *v1alpha3.PipelineSpec) string {
+	return v1alpha3.GetPipelineSpecHash(spec)
+}
+
+// DiffSpecs returns the unified diff between two Pipeline specs in YAML, the from spec could be nil
+func DiffSpecs(from, to *v1alpha3.PipelineSpec, fromName, toName string) (string, error) {
+	var fromYAML []byte
+	if from != nil {
+		var err error
+		if fromYAML, err = yaml.Marshal(from); err != nil {
+			return "", err
+		}
+	}
+	toYAML, err := yaml.Marshal(to)
+	if err != nil {
+		return "", err
+	}
+	return difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        splitLines(string(fromYAML)),
+		B:        splitLines(string(toYAML)),
+		FromFile: fromName,
+		ToFile:   toName,
+		Context:  3,
+	})
+}
+
+// splitLines splits the text into lines with their line endings, there is no empty line at the end
+func splitLines(text string) []string {
+	lines := strings.SplitAfter(text, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// ListRevisions returns the revisions of a Pipeline, the latest one goes first
+func ListRevisions(ctx context.Context, c client.Reader, namespace, pipelineName string) (
+	revisions []v1alpha3.PipelineRevision, err error) {
+	list := &v1alpha3.PipelineRevisionList{}
+	if err = c.List(ctx, list, client.InNamespace(namespace),
+		client.MatchingLabels{v1alpha3.PipelineNameLabelKey: pipelineName}); err != nil {
+		return
+	}
+	for i := range list.Items {
+		if list.Items[i].Spec.PipelineRef.Name == pipelineName {
+			revisions = append(revisions, list.Items[i])
+		}
+	}
+	sort.Slice(revisions, func(i, j int) bool {
+		return revisions[i].Spec.Revision > revisions[j].Spec.Revision
+	})
+	return
+}
+
+// GetRevision returns a revision of a Pipeline
+func GetRevision(ctx context.Context, c client.Reader, namespace, pipelineName string, revision int64) (
+	*v1alpha3.PipelineRevision, error) {
+	result := &v1alpha3.PipelineRevision{}
+	name := v1alpha3.GetPipelineRevisionName(pipelineName, revision)
+	if err := c.Get(ctx, client.ObjectKey{Namespace: namespace, Name: name}, result); err != nil {
+		return nil, err
+	}
+	if result.Spec.PipelineRef.Name != pipelineName {
+		return nil, apierrors.NewNotFound(v1alpha3.Resource("pipelinerevisions"), name)
+	}
+	return result, nil
+}
+
+// EnsureRevision makes sure the current spec of a Pipeline is recorded as its latest revision. A new revision is
+// created if the spec differs from the latest revision. The revision names are sequential, so the concurrent callers
+// never create the same revision twice.
+func EnsureRevision(ctx context.Context, c client.Client, pipeline *v1alpha3.Pipeline) (
+	revision *v1alpha3.PipelineRevision, created bool, err error) {
+	hash := GetSpecHash(&pipeline.Spec)
+	var revisions []v1alpha3.PipelineRevision
+	if revisions, err = ListRevisions(ctx, c, pipeline.Namespace, pipeline.Name); err != nil {
+		return
+	}
+	var latest *v1alpha3.PipelineRevision
+	if len(revisions) > 0 {
+		latest = &revisions[0]
+	}
+
+	for i := 0; i < maxRevisionAttempts; i++ {
+		if latest != nil && latest.Spec.SpecHash == hash {
+			return latest, false, nil
+		}
+		if revision, err = newRevision(pipeline, latest, hash); err != nil {
+			return
+		}
+		if err = c.Create(ctx, revision); err == nil {
+			created = true
+			return
+		} else if !apierrors.IsAlreadyExists(err) {
+			return
+		}
+		// the list might be stale, so the next attempt is based on the revision which took the number
+		latest = getTakenRevision(ctx, c, revision, latest)
+	}
+	err = fmt.Errorf("failed to create the revision of Pipeline %s/%s after %d attempts, error: %v",
+		pipeline.Namespace, pipeline.Name, maxRevisionAttempts, err)
+	return
+}
+
+// getTakenRevision returns the revision which took the number of a new revision. It's not in the cache yet if it was
+// just created by others, then the number is skipped, and the new revision is still compared with the latest one.
+func getTakenRevision(ctx context.Context, c client.Reader, revision, latest *v1alpha3.PipelineRevision) *v1alpha3.PipelineRevision {
+	taken := &v1alpha3.PipelineRevision{}
+	if err := c.Get(ctx, client.ObjectKeyFromObject(revision), taken); err == nil &&
+		taken.Spec.PipelineRef.Name == revision.Spec.PipelineRef.Name {
+		return taken
+	}
+
+	skipped := &v1alpha3.PipelineRevision{}
+	if latest != nil {
+		latest.DeepCopyInto(skipped)
+	}
+	skipped.Name = revision.Name
+	skipped.Spec.Revision = revision.Spec.Revision
+	skipped.Spec.SpecHash = ""
+	return skipped
+}
+
+func newRevision(pipeline *v1alpha3.Pipeline, latest *v1alpha3.PipelineRevision, hash string) (
+	revision *v1alpha3.PipelineRevision, err error) {
+	number := int64(1)
+	diff := ""
+	if latest != nil {
+		number = latest.Spec.Revision + 1
+		if diff, err = DiffSpecs(&latest.Spec.Pipeline, &pipeline.Spec,
+			latest.Name, v1alpha3.GetPipelineRevisionName(pipeline.Name, number)); err != nil {
+			return
+		}
+	}
+
+	// both are empty if the spec was changed by others, like kubectl, since the last change through the API
+	author, message := pipeline.GetSpecAuthor()
+
+	revision = &v1alpha3.PipelineRevision{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      v1alpha3.GetPipelineRevisionName(pipeline.Name, number),
+			Namespace: pipeline.Namespace,
+			Labels: map[string]string{
+				v1alpha3.PipelineNameLabelKey:     pipeline.Name,
+				v1alpha3.PipelineRevisionLabelKey: strconv.FormatInt(number, 10),
+			},
+			OwnerReferences: []metav1.OwnerReference{{
+				APIVersion: v1alpha3.GroupVersion.String(),
+				Kind:       v1alpha3.ResourceKindPipeline,
+				Name:       pipeline.Name,
+				UID:        pipeline.UID,
+			}},
+		},
+		Spec: v1alpha3.PipelineRevisionSpec{
+			PipelineRef: corev1.LocalObjectReference{Name: pipeline.Name},
+			Revision:    number,
+			SpecHash:    hash,
+			Author:      author,
+			Message:     message,
+			Diff:        diff,
+			Pipeline:    *pipeline.Spec.DeepCopy(),
+		},
+	}
+	return
+}
+
+// RevisionDiff is the diff between two revisions of a Pipeline
+type RevisionDiff struct {
+	From int64  `json:"from" description:"The base revision"`
+	To   int64  `json:"to" description:"The target revision"`
+	Diff string `json:"diff" description:"The unified diff of the Pipeline specs in YAML"`
+}
+
+// Rollback sets the definition of a Pipeline to the one of a revision, the Git source of the Pipeline is kept. The
+// Jenkinsfile is going to be converted to JSON again, since the JSON format of the current one is out of date.
+func Rollback(pipeline *v1alpha3.Pipeline, revision *v1alpha3.PipelineRevision, user string) {
+	source := pipeline.Spec.Source
+	pipeline.Spec = *revision.Spec.Pipeline.DeepCopy()
+	pipeline.Spec.Source = source
+	pipeline.SetSpecAuthor(user, fmt.Sprintf("Rolled back to revision %d", revision.Spec.Revision))
+	if pipeline.Spec.Type == v1alpha3.NoScmPipelineType && pipeline.Spec.Pipeline != nil {
+		pipeline.Annotations[v1alpha3.PipelineJenkinsfileEditModeAnnoKey] = v1alpha3.PipelineJenkinsfileEditModeRaw
+	}
+}
@@ -0,0 +1,217 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipeline
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	"github.com/kubesphere/ks-devops/pkg/api/devops/v1alpha3"
+)
+
+func newPipeline(jenkinsfile string) *v1alpha3.Pipeline {
+	return &v1alpha3.Pipeline{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "ns",
+			Name:      "demo",
+			UID:       "uid",
+		},
+		Spec: v1alpha3.PipelineSpec{
+			Type:     v1alpha3.NoScmPipelineType,
+			Pipeline: &v1alpha3.NoScmPipeline{Name: "demo", Jenkinsfile: jenkinsfile},
+		},
+	}
+}
+
+func TestDiffSpecs(t *testing.T) {
+	from, to := newPipeline("pipeline {}").Spec, newPipeline("pipeline { }").Spec
+	diff, err := DiffSpecs(&from, &to, "demo-1", "demo-2")
+	assert.Nil(t, err)
+	assert.Equal(t, `--- demo-1
++++ demo-2
+@@ -1,4 +1,4 @@
+ pipeline:
+-  jenkinsfile: pipeline {}
++  jenkinsfile: pipeline { }
+   name: demo
+ type: pipeline
+`, diff)
+
+	diff, err = DiffSpecs(&from, &from, "demo-1", "demo-1")
+	assert.Nil(t, err)
+	assert.Empty(t, diff)
+
+	diff, err = DiffSpecs(nil, &from, "", "demo-1")
+	assert.Nil(t, err)
+	assert.Contains(t, diff, "+  jenkinsfile: pipeline {}")
+}
+
+func TestEnsureRevision(t *testing.T) {
+	schema, err := v1alpha3.SchemeBuilder.Register().Build()
+	assert.Nil(t, err)
+	c := fake.NewClientBuilder().WithScheme(schema).Build()
+	ctx := context.TODO()
+
+	pipeline := newPipeline("pipeline {}")
+	pipeline.SetSpecAuthor("admin", "")
+	revision, created, err := EnsureRevision(ctx, c, pipeline)
+	assert.Nil(t, err)
+	assert.True(t, created)
+	assert.Equal(t, "demo-1", revision.Name)
+	assert.Equal(t, int64(1), revision.Spec.Revision)
+	assert.Equal(t, "admin", revision.Spec.Author)
+	assert.Empty(t, revision.Spec.Diff)
+	assert.Equal(t, "1", revision.Labels[v1alpha3.PipelineRevisionLabelKey])
+	assert.Equal(t, "demo", revision.OwnerReferences[0].Name)
+
+	// nothing changed
+	revision, created, err = EnsureRevision(ctx, c, pipeline)
+	assert.Nil(t, err)
+	assert.False(t, created)
+	assert.Equal(t, "demo-1", revision.Name)
+
+	pipeline.Spec.Pipeline.Jenkinsfile = "pipeline { }"
+	pipeline.SetSpecAuthor("tester", "fix the Jenkinsfile")
+	revision, created, err = EnsureRevision(ctx, c, pipeline)
+	assert.Nil(t, err)
+	assert.True(t, created)
+	assert.Equal(t, "demo-2", revision.Name)
+	assert.Equal(t, "tester", revision.Spec.Author)
+	assert.Equal(t, "fix the Jenkinsfile", revision.Spec.Message)
+	assert.Contains(t, revision.Spec.Diff, "+  jenkinsfile: pipeline { }")
+
+	revisions, err := ListRevisions(ctx, c, "ns", "demo")
+	assert.Nil(t, err)
+	if assert.Len(t, revisions, 2) {
+		assert.Equal(t, "demo-2", revisions[0].Name)
+		assert.Equal(t, "demo-1", revisions[1].Name)
+	}
+
+	revision, err = GetRevision(ctx, c, "ns", "demo", 1)
+	assert.Nil(t, err)
+	assert.Equal(t, "pipeline {}", revision.Spec.Pipeline.Pipeline.Jenkinsfile)
+	_, err = GetRevision(ctx, c, "ns", "demo", 3)
+	assert.NotNil(t, err)
+}
+
+func TestEnsureRevision_EditedByOthers(t *testing.T) {
+	schema, err := v1alpha3.SchemeBuilder.Register().Build()
+	assert.Nil(t, err)
+	c := fake.NewClientBuilder().WithScheme(schema).Build()
+
+	pipeline := newPipeline("pipeline {}")
+	pipeline.SetSpecAuthor("admin", "Rolled back to revision 1")
+	// the spec is changed by kubectl, the annotations are left as they were
+	pipeline.Spec.Pipeline.Jenkinsfile = "pipeline { }"
+	revision, _, err := EnsureRevision(context.TODO(), c, pipeline)
+	assert.Nil(t, err)
+	assert.Empty(t, revision.Spec.Author)
+	assert.Empty(t, revision.Spec.Message)
+}
+
+func TestEnsureRevision_Conflict(t *testing.T) {
+	schema, err := v1alpha3.SchemeBuilder.Register().Build()
+	assert.Nil(t, err)
+	// the revision was created by others but not labeled yet
+	existing := &v1alpha3.PipelineRevision{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "demo-1"},
+		Spec:       v1alpha3.PipelineRevisionSpec{Revision: 1},
+	}
+	c := fake.NewClientBuilder().WithScheme(schema).WithObjects(existing).Build()
+
+	// the taken number is skipped
+	revision, created, err := EnsureRevision(context.TODO(), c, newPipeline("pipeline {}"))
+	assert.Nil(t, err)
+	assert.True(t, created)
+	assert.Equal(t, "demo-2", revision.Name)
+
+	// it gives up once the attempts are used up
+	failing := interceptor.NewClient(fake.NewClientBuilder().WithScheme(schema).Build(), interceptor.Funcs{
+		Create: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.CreateOption) error {
+			return apierrors.NewAlreadyExists(v1alpha3.Resource("pipelinerevisions"), obj.GetName())
+		},
+	})
+	_, _, err = EnsureRevision(context.TODO(), failing, newPipeline("pipeline {}"))
+	assert.NotNil(t, err)
+}
+
+func TestEnsureRevision_StaleCache(t *testing.T) {
+	schema, err := v1alpha3.SchemeBuilder.Register().Build()
+	assert.Nil(t, err)
+	ctx := context.TODO()
+	c := fake.NewClientBuilder().WithScheme(schema).Build()
+	_, _, err = EnsureRevision(ctx, c, newPipeline("pipeline {}"))
+	assert.Nil(t, err)
+
+	// the revisions created by others are not listed yet
+	staleList := interceptor.Funcs{
+		List: func(ctx context.Context, c client.WithWatch, list client.ObjectList, opts ...client.ListOption) error {
+			return nil
+		},
+	}
+	stale := interceptor.NewClient(c.(client.WithWatch), staleList)
+
+	revision, created, err := EnsureRevision(ctx, stale, newPipeline("pipeline {}"))
+	assert.Nil(t, err)
+	assert.False(t, created)
+	assert.Equal(t, "demo-1", revision.Name)
+
+	revision, created, err = EnsureRevision(ctx, stale, newPipeline("pipeline { }"))
+	assert.Nil(t, err)
+	assert.True(t, created)
+	assert.Equal(t, "demo-2", revision.Name)
+	assert.Contains(t, revision.Spec.Diff, "--- demo-1")
+	assert.Contains(t, revision.Spec.Diff, "+  jenkinsfile: pipeline { }")
+
+	// the revisions created by others are not got either
+	staleList.Get = func(ctx context.Context, c client.WithWatch, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+		return apierrors.NewNotFound(v1alpha3.Resource("pipelinerevisions"), key.Name)
+	}
+	stale = interceptor.NewClient(c.(client.WithWatch), staleList)
+	revision, created, err = EnsureRevision(ctx, stale, newPipeline("pipeline {  }"))
+	assert.Nil(t, err)
+	assert.True(t, created)
+	assert.Equal(t, "demo-3", revision.Name)
+	assert.Equal(t, int64(3), revision.Spec.Revision)
+}
+
+func TestRollback(t *testing.T) {
+	pipeline := newPipeline("pipeline { }")
+	revision := &v1alpha3.PipelineRevision{Spec: v1alpha3.PipelineRevisionSpec{
+		Revision: 1,
+		Pipeline: newPipeline("pipeline {}").Spec,
+	}}
+	revision.Spec.Pipeline.Source = &v1alpha3.PipelineSource{GitRepository: "repo", Path: "Jenkinsfile"}
+
+	Rollback(pipeline, revision, "admin")
+	assert.Equal(t, "pipeline {}", pipeline.Spec.Pipeline.Jenkinsfile)
+	assert.Equal(t, "admin", pipeline.Annotations[v1alpha3.PipelineSpecAuthorAnnoKey])
+	assert.Equal(t, "Rolled back to revision 1", pipeline.Annotations[v1alpha3.PipelineSpecChangeCauseAnnoKey])
+	assert.Equal(t, v1alpha3.PipelineJenkinsfileEditModeRaw, pipeline.Annotations[v1alpha3.PipelineJenkinsfileEditModeAnnoKey])
+	assert.False(t, pipeline.IsGitManaged(), "the Git source should be kept")
+
+	// the revision should not be changed
+	pipeline.Spec.Pipeline.Jenkinsfile = "changed"
+	assert.Equal(t, "pipeline {}", revision.Spec.Pipeline.Pipeline.Jenkinsfile)
+}
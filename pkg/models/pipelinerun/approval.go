@@ -0,0 +1,200 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinerun
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/kubesphere/ks-devops/pkg/api/devops/v1alpha3"
+	"github.com/kubesphere/ks-devops/pkg/client/devops"
+	"github.com/kubesphere/ks-devops/pkg/utils/sliceutil"
+)
+
+// GetApprovalName returns the name of the Approval of an input step, a step has one Approval at most
+func GetApprovalName(prName, nodeID, stepID string) string {
+	return strings.ToLower(fmt.Sprintf("%s-%s-%s", prName, nodeID, stepID))
+}
+
+// NewApproval creates an Approval for a paused input step of a PipelineRun. The submitters of the input are allowed
+// to approve besides the approvers of the policy, they could be users or groups as Jenkins does. Anyone is allowed if
+// there is neither a policy nor a submitter, it's the same as Jenkins does.
+func NewApproval(pr *v1alpha3.PipelineRun, policy *v1alpha3.ApprovalPolicy, node NodeDetail, step Step) *v1alpha3.Approval {
+	approval := &v1alpha3.Approval{
+		ObjectMeta: v1.ObjectMeta{
+			Namespace: pr.Namespace,
+			Name:      GetApprovalName(pr.Name, node.ID, step.ID),
+			Labels: map[string]string{
+				v1alpha3.PipelineRunNameLabelKey: pr.Name,
+			},
+			OwnerReferences: []v1.OwnerReference{*v1.NewControllerRef(pr, v1alpha3.GroupVersion.WithKind("PipelineRun"))},
+		},
+		Spec: v1alpha3.ApprovalSpec{
+			PipelineRunRef: corev1.LocalObjectReference{Name: pr.Name},
+			NodeID:         node.ID,
+			StepID:         step.ID,
+		},
+		Status: v1alpha3.ApprovalStatus{Phase: v1alpha3.ApprovalPending},
+	}
+	if pipelineName := pr.Labels[v1alpha3.PipelineNameLabelKey]; pipelineName != "" {
+		approval.Labels[v1alpha3.PipelineNameLabelKey] = pipelineName
+	}
+	if policy != nil {
+		approval.Spec.ApprovalPolicy = *policy.DeepCopy()
+	}
+	if step.Input != nil {
+		approval.Spec.InputID = step.Input.ID
+		approval.Spec.Message = step.Input.Message
+		for _, submitter := range strings.Split(step.Input.Submitter, ",") {
+			if submitter = strings.TrimSpace(submitter); submitter != "" &&
+				!sliceutil.HasString(approval.Spec.Approvers.Users, submitter) {
+				approval.Spec.Approvers.Users = append(approval.Spec.Approvers.Users, submitter)
+			}
+			if submitter != "" && !sliceutil.HasString(approval.Spec.Approvers.Groups, submitter) {
+				approval.Spec.Approvers.Groups = append(approval.Spec.Approvers.Groups, submitter)
+			}
+		}
+	}
+	if policy == nil && len(approval.Spec.Approvers.Users) == 0 {
+		approval.Spec.Approvers.Anyone = true
+	}
+	return approval
+}
+
+// IsApprover checks if the user is allowed to approve. The user is allowed if the user is one of the approver users,
+// or in one of the approver groups, or one of the RoleBindings binds the user to an approver Role or ClusterRole.
+// Nobody is allowed if there are no approvers, unless anyone is allowed explicitly.
+func IsApprover(approvers v1alpha3.Approvers, userInfo user.Info, roleBindings []rbacv1.RoleBinding) bool {
+	if approvers.Anyone {
+		return true
+	}
+	if sliceutil.HasString(approvers.Users, userInfo.GetName()) {
+		return true
+	}
+	for _, group := range userInfo.GetGroups() {
+		if sliceutil.HasString(approvers.Groups, group) {
+			return true
+		}
+	}
+	for _, roleBinding := range roleBindings {
+		if !isApproverRole(approvers, roleBinding.RoleRef) {
+			continue
+		}
+		for _, subject := range roleBinding.Subjects {
+			if (subject.Kind == rbacv1.UserKind && subject.Name == userInfo.GetName()) ||
+				(subject.Kind == rbacv1.GroupKind && sliceutil.HasString(userInfo.GetGroups(), subject.Name)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isApproverRole checks if the Role or ClusterRole referred by a RoleBinding is one of the approvers
+func isApproverRole(approvers v1alpha3.Approvers, roleRef rbacv1.RoleRef) bool {
+	switch roleRef.Kind {
+	case "Role":
+		return sliceutil.HasString(approvers.Roles, roleRef.Name)
+	case "ClusterRole":
+		return sliceutil.HasString(approvers.ClusterRoles, roleRef.Name)
+	}
+	return false
+}
+
+// SubmitApproval forwards the decision of a completed Approval to Jenkins, the input step proceeds or aborts
+func SubmitApproval(devopsClient devops.Interface, pr *v1alpha3.PipelineRun, approval *v1alpha3.Approval) (err error) {
+	runID, exists := pr.GetPipelineRunID()
+	if !exists {
+		return fmt.Errorf("the PipelineRun %s/%s has not started yet", pr.Namespace, pr.Name)
+	}
+	if !approval.HasCompleted() {
+		return fmt.Errorf("the approval %s/%s has not completed yet", approval.Namespace, approval.Name)
+	}
+
+	abort, parameters := approval.GetSubmission()
+	payload := devops.CheckPlayload{ID: approval.Spec.InputID, Abort: abort}
+	for _, parameter := range parameters {
+		payload.Parameters = append(payload.Parameters, devops.CheckPlayloadParameters{Name: parameter.Name, Value: parameter.Value})
+	}
+	var data []byte
+	if data, err = json.Marshal(payload); err != nil {
+		return
+	}
+	httpParameters := &devops.HttpParameters{
+		Method: http.MethodPost,
+		Header: http.Header{"Content-Type": []string{"application/json"}},
+		Body:   ioutil.NopCloser(bytes.NewReader(data)),
+		Url:    &url.URL{},
+	}
+
+	pipelineName := pr.Labels[v1alpha3.PipelineNameLabelKey]
+	if pr.Spec.IsMultiBranchPipeline() {
+		_, err = devopsClient.SubmitBranchInputStep(pr.Namespace, pipelineName, pr.GetRefName(), runID,
+			approval.Spec.NodeID, approval.Spec.StepID, httpParameters)
+	} else {
+		_, err = devopsClient.SubmitInputStep(pr.Namespace, pipelineName, runID,
+			approval.Spec.NodeID, approval.Spec.StepID, httpParameters)
+	}
+	return
+}
+
+// GetInputApproval returns the Approval of an input step of a Jenkins run, it's nil if there is no such Approval
+func GetInputApproval(ctx context.Context, c client.Client, namespace, pipelineName, branch, runID, nodeID,
+	stepID string) (*v1alpha3.Approval, error) {
+	approvals := &v1alpha3.ApprovalList{}
+	if err := c.List(ctx, approvals, client.InNamespace(namespace),
+		client.MatchingLabels{v1alpha3.PipelineNameLabelKey: pipelineName}); err != nil {
+		return nil, err
+	}
+	for i := range approvals.Items {
+		approval := &approvals.Items[i]
+		if approval.Spec.NodeID != nodeID || approval.Spec.StepID != stepID {
+			continue
+		}
+		pr := &v1alpha3.PipelineRun{}
+		if err := c.Get(ctx, client.ObjectKey{Namespace: namespace, Name: approval.Spec.PipelineRunRef.Name}, pr); err != nil {
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+			return nil, err
+		}
+		if id, _ := pr.GetPipelineRunID(); id == runID && pr.GetRefName() == branch {
+			return approval, nil
+		}
+	}
+	return nil, nil
+}
+
+// ApprovalRequest is the request body of making a decision on an Approval
+type ApprovalRequest struct {
+	Decision   v1alpha3.ApprovalDecisionType `json:"decision" description:"The type of the decision, could be Approve or Reject"`
+	Comment    string                        `json:"comment,omitempty" description:"The comment of the decision"`
+	Parameters []v1alpha3.Parameter          `json:"parameters,omitempty" description:"The values of the input parameters"`
+}
@@ -0,0 +1,198 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinerun
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jenkins-zh/jenkins-client/pkg/job"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/kubesphere/ks-devops/pkg/api/devops/v1alpha3"
+)
+
+func TestGetApprovalName(t *testing.T) {
+	assert.Equal(t, "run-12-34", GetApprovalName("run", "12", "34"))
+	assert.Equal(t, "run-abc-def", GetApprovalName("run", "ABC", "Def"))
+}
+
+func TestIsApprover(t *testing.T) {
+	roleBindings := []rbacv1.RoleBinding{{
+		RoleRef:  rbacv1.RoleRef{Kind: "Role", Name: "reviewer"},
+		Subjects: []rbacv1.Subject{{Kind: rbacv1.UserKind, Name: "bob"}, {Kind: rbacv1.GroupKind, Name: "qa"}},
+	}, {
+		RoleRef:  rbacv1.RoleRef{Kind: "Role", Name: "viewer"},
+		Subjects: []rbacv1.Subject{{Kind: rbacv1.UserKind, Name: "carol"}},
+	}, {
+		RoleRef:  rbacv1.RoleRef{Kind: "ClusterRole", Name: "reviewer"},
+		Subjects: []rbacv1.Subject{{Kind: rbacv1.UserKind, Name: "frank"}},
+	}, {
+		RoleRef:  rbacv1.RoleRef{Kind: "ClusterRole", Name: "release-manager"},
+		Subjects: []rbacv1.Subject{{Kind: rbacv1.UserKind, Name: "grace"}},
+	}}
+	approvers := v1alpha3.Approvers{
+		Users:        []string{"alice"},
+		Groups:       []string{"ops"},
+		Roles:        []string{"reviewer"},
+		ClusterRoles: []string{"release-manager"},
+	}
+
+	tests := []struct {
+		name      string
+		approvers v1alpha3.Approvers
+		userInfo  user.Info
+		want      bool
+	}{{
+		name:     "nobody is allowed without approvers",
+		userInfo: &user.DefaultInfo{Name: "carol"},
+		want:     false,
+	}, {
+		name:      "everyone is allowed explicitly",
+		approvers: v1alpha3.Approvers{Anyone: true},
+		userInfo:  &user.DefaultInfo{Name: "carol"},
+		want:      true,
+	}, {
+		name:      "an approver user",
+		approvers: approvers,
+		userInfo:  &user.DefaultInfo{Name: "alice"},
+		want:      true,
+	}, {
+		name:      "an approver group",
+		approvers: approvers,
+		userInfo:  &user.DefaultInfo{Name: "dave", Groups: []string{"ops"}},
+		want:      true,
+	}, {
+		name:      "a user bound to an approver role",
+		approvers: approvers,
+		userInfo:  &user.DefaultInfo{Name: "bob"},
+		want:      true,
+	}, {
+		name:      "a group bound to an approver role",
+		approvers: approvers,
+		userInfo:  &user.DefaultInfo{Name: "erin", Groups: []string{"qa"}},
+		want:      true,
+	}, {
+		name:      "a group name is not a user",
+		approvers: approvers,
+		userInfo:  &user.DefaultInfo{Name: "ops"},
+		want:      false,
+	}, {
+		name:      "a user name is not a group",
+		approvers: approvers,
+		userInfo:  &user.DefaultInfo{Name: "dave", Groups: []string{"alice"}},
+		want:      false,
+	}, {
+		name:      "a user bound to an approver cluster role",
+		approvers: approvers,
+		userInfo:  &user.DefaultInfo{Name: "grace"},
+		want:      true,
+	}, {
+		name:      "a user bound to a cluster role with the name of an approver role",
+		approvers: approvers,
+		userInfo:  &user.DefaultInfo{Name: "frank"},
+		want:      false,
+	}, {
+		name:      "a user bound to another role",
+		approvers: approvers,
+		userInfo:  &user.DefaultInfo{Name: "carol"},
+		want:      false,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, IsApprover(tt.approvers, tt.userInfo, roleBindings))
+		})
+	}
+}
+
+func TestNewApproval(t *testing.T) {
+	pr := &v1alpha3.PipelineRun{ObjectMeta: v1.ObjectMeta{Namespace: "ns", Name: "run"}}
+	node := NodeDetail{Node: job.Node{ID: "1"}}
+	withSubmitter := Step{Step: job.Step{ID: "2", Input: &job.Input{ID: "Deploy", Submitter: "alice"}}}
+	withoutSubmitter := Step{Step: job.Step{ID: "2", Input: &job.Input{ID: "Deploy"}}}
+
+	// anyone is allowed like Jenkins if there is neither a policy nor a submitter
+	approval := NewApproval(pr, nil, node, withoutSubmitter)
+	assert.True(t, approval.Spec.Approvers.Anyone)
+
+	approval = NewApproval(pr, nil, node, withSubmitter)
+	assert.False(t, approval.Spec.Approvers.Anyone)
+	assert.Equal(t, []string{"alice"}, approval.Spec.Approvers.Users)
+	assert.Equal(t, []string{"alice"}, approval.Spec.Approvers.Groups)
+
+	// the policy decides if there is one
+	approval = NewApproval(pr, &v1alpha3.ApprovalPolicy{}, node, withoutSubmitter)
+	assert.False(t, approval.Spec.Approvers.Anyone)
+	approval = NewApproval(pr, &v1alpha3.ApprovalPolicy{Approvers: v1alpha3.Approvers{Anyone: true}}, node, withoutSubmitter)
+	assert.True(t, approval.Spec.Approvers.Anyone)
+}
+
+func TestGetInputApproval(t *testing.T) {
+	schema, err := v1alpha3.SchemeBuilder.Register().Build()
+	assert.Nil(t, err)
+
+	newPipelineRun := func(name, runID, branch string) *v1alpha3.PipelineRun {
+		pr := &v1alpha3.PipelineRun{
+			ObjectMeta: v1.ObjectMeta{
+				Namespace:   "ns",
+				Name:        name,
+				Annotations: map[string]string{v1alpha3.JenkinsPipelineRunIDAnnoKey: runID},
+			},
+		}
+		if branch != "" {
+			pr.Spec.PipelineSpec = &v1alpha3.PipelineSpec{Type: v1alpha3.MultiBranchPipelineType}
+			pr.Spec.SCM = &v1alpha3.SCM{RefName: branch}
+		}
+		return pr
+	}
+	newApproval := func(prName string) *v1alpha3.Approval {
+		return &v1alpha3.Approval{
+			ObjectMeta: v1.ObjectMeta{
+				Namespace: "ns",
+				Name:      GetApprovalName(prName, "1", "2"),
+				Labels:    map[string]string{v1alpha3.PipelineNameLabelKey: "pipeline"},
+			},
+			Spec: v1alpha3.ApprovalSpec{PipelineRunRef: corev1.LocalObjectReference{Name: prName}, NodeID: "1", StepID: "2"},
+		}
+	}
+	c := fake.NewClientBuilder().WithScheme(schema).WithObjects(
+		newPipelineRun("run-1", "1", ""), newApproval("run-1"),
+		newPipelineRun("main-1", "1", "main"), newApproval("main-1"),
+		newApproval("deleted")).Build()
+
+	approval, err := GetInputApproval(context.Background(), c, "ns", "pipeline", "", "1", "1", "2")
+	assert.Nil(t, err)
+	if assert.NotNil(t, approval) {
+		assert.Equal(t, "run-1", approval.Spec.PipelineRunRef.Name)
+	}
+	approval, err = GetInputApproval(context.Background(), c, "ns", "pipeline", "main", "1", "1", "2")
+	assert.Nil(t, err)
+	if assert.NotNil(t, approval) {
+		assert.Equal(t, "main-1", approval.Spec.PipelineRunRef.Name)
+	}
+	approval, err = GetInputApproval(context.Background(), c, "ns", "pipeline", "", "2", "1", "2")
+	assert.Nil(t, err)
+	assert.Nil(t, approval)
+	approval, err = GetInputApproval(context.Background(), c, "ns", "pipeline", "", "1", "1", "3")
+	assert.Nil(t, err)
+	assert.Nil(t, approval)
+}
@@ -0,0 +1,96 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinerun
+
+import (
+	"context"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/kubesphere/ks-devops/pkg/api/devops/v1alpha3"
+)
+
+// ChainNode is a PipelineRun in a chain of the upstream triggers
+type ChainNode struct {
+	Namespace      string            `json:"namespace"`
+	Name           string            `json:"name"`
+	Pipeline       string            `json:"pipeline"`
+	Phase          v1alpha3.RunPhase `json:"phase,omitempty"`
+	StartTime      *metav1.Time      `json:"startTime,omitempty"`
+	CompletionTime *metav1.Time      `json:"completionTime,omitempty"`
+	Upstream       string            `json:"upstream,omitempty" description:"The namespaced name of the upstream PipelineRun, it's empty for the first one"`
+}
+
+func newChainNode(pr *v1alpha3.PipelineRun) ChainNode {
+	node := ChainNode{
+		Namespace:      pr.Namespace,
+		Name:           pr.Name,
+		Pipeline:       pr.GetPipelineName(),
+		Phase:          pr.Status.Phase,
+		StartTime:      pr.Status.StartTime,
+		CompletionTime: pr.Status.CompletionTime,
+	}
+	if pr.Spec.Upstream != nil {
+		node.Upstream = pr.Spec.Upstream.String()
+	}
+	return node
+}
+
+// GetChain returns the chain of a PipelineRun. It starts from the first upstream PipelineRun which still exists, then
+// the PipelineRun itself, then the downstream PipelineRuns level by level. The chain only has the PipelineRuns in the
+// same namespace, since the requester might not be allowed to see the other ones.
+func GetChain(ctx context.Context, c client.Reader, pr *v1alpha3.PipelineRun) (chain []ChainNode, err error) {
+	var upstreams []ChainNode
+	for current := pr; current.Spec.Upstream != nil && len(upstreams) < v1alpha3.MaxUpstreamDepth; {
+		ref := current.Spec.Upstream
+		if ref.Namespace != pr.Namespace {
+			break
+		}
+		current = &v1alpha3.PipelineRun{}
+		if err = c.Get(ctx, client.ObjectKey{Namespace: ref.Namespace, Name: ref.Name}, current); err != nil {
+			if err = client.IgnoreNotFound(err); err != nil {
+				return
+			}
+			break
+		}
+		upstreams = append(upstreams, newChainNode(current))
+	}
+	for i := len(upstreams) - 1; i >= 0; i-- {
+		chain = append(chain, upstreams[i])
+	}
+	chain = append(chain, newChainNode(pr))
+
+	level := []v1alpha3.PipelineRun{*pr}
+	for depth := 0; len(level) > 0 && depth < v1alpha3.MaxUpstreamDepth; depth++ {
+		var next []v1alpha3.PipelineRun
+		for i := range level {
+			downstreams := &v1alpha3.PipelineRunList{}
+			if err = c.List(ctx, downstreams, client.InNamespace(pr.Namespace), client.MatchingLabels{
+				v1alpha3.PipelineRunUpstreamLabelKey: string(level[i].UID),
+			}); err != nil {
+				return
+			}
+			for j := range downstreams.Items {
+				chain = append(chain, newChainNode(&downstreams.Items[j]))
+			}
+			next = append(next, downstreams.Items...)
+		}
+		level = next
+	}
+	return
+}
@@ -0,0 +1,95 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinerun
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/kubesphere/ks-devops/pkg/api/devops/v1alpha3"
+)
+
+func TestGetChain(t *testing.T) {
+	schema, err := v1alpha3.SchemeBuilder.Register().Build()
+	assert.Nil(t, err)
+
+	newPipelineRun := func(namespace, name, pipeline string, upstream *v1alpha3.PipelineRun) *v1alpha3.PipelineRun {
+		pr := &v1alpha3.PipelineRun{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: namespace,
+				Name:      name,
+				UID:       types.UID(namespace + "-" + name),
+				Labels:    map[string]string{v1alpha3.PipelineNameLabelKey: pipeline},
+			},
+			Status: v1alpha3.PipelineRunStatus{Phase: v1alpha3.Succeeded},
+		}
+		if upstream != nil {
+			pr.Labels[v1alpha3.PipelineRunUpstreamLabelKey] = string(upstream.UID)
+			pr.Spec.Upstream = v1alpha3.NewUpstreamReference(upstream)
+		}
+		return pr
+	}
+	build := newPipelineRun("team-a", "build-1", "build", nil)
+	test := newPipelineRun("team-a", "test-1", "test", build)
+	deploy := newPipelineRun("team-b", "deploy-1", "deploy", test)
+	notify := newPipelineRun("team-a", "notify-1", "notify", test)
+	verify := newPipelineRun("team-b", "verify-1", "verify", deploy)
+	orphan := newPipelineRun("team-a", "test-2", "test", newPipelineRun("team-a", "build-2", "build", nil))
+
+	c := fake.NewClientBuilder().WithScheme(schema).WithObjects(build, test, deploy, verify, notify, orphan).Build()
+
+	tests := []struct {
+		name string
+		pr   *v1alpha3.PipelineRun
+		want []ChainNode
+	}{{
+		name: "the first one",
+		pr:   build,
+		want: []ChainNode{newChainNode(build), newChainNode(test), newChainNode(notify)},
+	}, {
+		name: "in the middle",
+		pr:   test,
+		want: []ChainNode{newChainNode(build), newChainNode(test), newChainNode(notify)},
+	}, {
+		name: "the upstream is in another namespace",
+		pr:   deploy,
+		want: []ChainNode{newChainNode(deploy), newChainNode(verify)},
+	}, {
+		name: "the last one",
+		pr:   verify,
+		want: []ChainNode{newChainNode(deploy), newChainNode(verify)},
+	}, {
+		name: "the upstream was deleted",
+		pr:   orphan,
+		want: []ChainNode{newChainNode(orphan)},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chain, err := GetChain(context.Background(), c, tt.pr)
+			assert.Nil(t, err)
+			assert.Equal(t, tt.want, chain)
+		})
+	}
+
+	assert.Equal(t, ChainNode{Namespace: "team-b", Name: "deploy-1", Pipeline: "deploy", Phase: v1alpha3.Succeeded, Upstream: "team-a/test-1"},
+		newChainNode(deploy))
+}
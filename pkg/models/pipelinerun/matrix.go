@@ -0,0 +1,87 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinerun
+
+import (
+	"fmt"
+
+	"github.com/kubesphere/ks-devops/pkg/api/devops/v1alpha3"
+	"github.com/kubesphere/ks-devops/pkg/client/devops"
+)
+
+// RunPayload is the payload to run a Pipeline, the PipelineRun fans out over the combinations if the matrix is set
+type RunPayload struct {
+	devops.RunPayload
+	Matrix *v1alpha3.Matrix `json:"matrix,omitempty" description:"Run once per combination of the parameter values"`
+}
+
+// MatrixTable is the table of a matrix PipelineRun, a row per combination
+type MatrixTable struct {
+	Columns []string    `json:"columns" description:"The names of the parameters, the axes come first"`
+	Rows    []MatrixRow `json:"rows"`
+}
+
+// MatrixRow is a combination of a matrix PipelineRun and the run of it
+type MatrixRow struct {
+	Values      []string          `json:"values" description:"The parameter values in the order of the columns, it's empty if the parameter is absent"`
+	PipelineRun string            `json:"pipelineRun,omitempty" description:"The name of the child PipelineRun, it's empty if it was not created"`
+	Phase       v1alpha3.RunPhase `json:"phase"`
+}
+
+// GetMatrixTable returns the table of a matrix PipelineRun. The combinations are pending before the matrix is
+// reconciled.
+func GetMatrixTable(pr *v1alpha3.PipelineRun) (*MatrixTable, error) {
+	if !pr.IsMatrix() {
+		return nil, fmt.Errorf("PipelineRun %s is not a matrix", pr.Name)
+	}
+	rows := pr.Status.Matrix
+	if len(rows) == 0 {
+		combinations, err := pr.Spec.Matrix.GetCombinations()
+		if err != nil {
+			return nil, err
+		}
+		for _, combination := range combinations {
+			rows = append(rows, v1alpha3.MatrixRunStatus{Parameters: combination, Phase: v1alpha3.Pending})
+		}
+	}
+
+	table := &MatrixTable{Columns: []string{}, Rows: make([]MatrixRow, len(rows))}
+	columns := map[string]int{}
+	addColumn := func(name string) {
+		if _, ok := columns[name]; !ok {
+			columns[name] = len(table.Columns)
+			table.Columns = append(table.Columns, name)
+		}
+	}
+	for _, axis := range pr.Spec.Matrix.Axes {
+		addColumn(axis.Name)
+	}
+	// the included combinations might have the parameters out of the axes
+	for _, row := range rows {
+		for _, parameter := range row.Parameters {
+			addColumn(parameter.Name)
+		}
+	}
+
+	for i, row := range rows {
+		table.Rows[i] = MatrixRow{Values: make([]string, len(table.Columns)), PipelineRun: row.PipelineRun, Phase: row.Phase}
+		for _, parameter := range row.Parameters {
+			table.Rows[i].Values[columns[parameter.Name]] = parameter.Value
+		}
+	}
+	return table, nil
+}
@@ -0,0 +1,71 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipelinerun
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kubesphere/ks-devops/pkg/api/devops/v1alpha3"
+)
+
+func TestGetMatrixTable(t *testing.T) {
+	matrix := &v1alpha3.Matrix{
+		Axes: []v1alpha3.MatrixAxis{{Name: "os", Values: []string{"linux"}}, {Name: "jdk", Values: []string{"8", "11"}}},
+		Include: []v1alpha3.MatrixCombination{{
+			Parameters: []v1alpha3.Parameter{{Name: "os", Value: "windows"}, {Name: "arch", Value: "arm64"}},
+		}},
+	}
+
+	t.Run("not a matrix", func(t *testing.T) {
+		_, err := GetMatrixTable(&v1alpha3.PipelineRun{})
+		assert.NotNil(t, err)
+	})
+
+	t.Run("not reconciled", func(t *testing.T) {
+		table, err := GetMatrixTable(&v1alpha3.PipelineRun{Spec: v1alpha3.PipelineRunSpec{Matrix: matrix}})
+		assert.Nil(t, err)
+		assert.Equal(t, &MatrixTable{
+			Columns: []string{"os", "jdk", "arch"},
+			Rows: []MatrixRow{
+				{Values: []string{"linux", "8", ""}, Phase: v1alpha3.Pending},
+				{Values: []string{"linux", "11", ""}, Phase: v1alpha3.Pending},
+				{Values: []string{"windows", "", "arm64"}, Phase: v1alpha3.Pending},
+			},
+		}, table)
+	})
+
+	t.Run("reconciled", func(t *testing.T) {
+		table, err := GetMatrixTable(&v1alpha3.PipelineRun{
+			Spec: v1alpha3.PipelineRunSpec{Matrix: matrix},
+			Status: v1alpha3.PipelineRunStatus{Matrix: []v1alpha3.MatrixRunStatus{{
+				Parameters:  []v1alpha3.Parameter{{Name: "os", Value: "linux"}, {Name: "jdk", Value: "8"}},
+				PipelineRun: "build-abc-0",
+				Phase:       v1alpha3.Succeeded,
+			}}},
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"os", "jdk"}, table.Columns)
+		assert.Equal(t, []MatrixRow{{Values: []string{"linux", "8"}, PipelineRun: "build-abc-0", Phase: v1alpha3.Succeeded}}, table.Rows)
+	})
+
+	t.Run("invalid matrix", func(t *testing.T) {
+		_, err := GetMatrixTable(&v1alpha3.PipelineRun{Spec: v1alpha3.PipelineRunSpec{Matrix: &v1alpha3.Matrix{}}})
+		assert.NotNil(t, err)
+	})
+}
@@ -0,0 +1,354 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package testreport
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// CaseStatus is the status of a test case
+type CaseStatus string
+
+const (
+	// CasePassed means the test case passed
+	CasePassed CaseStatus = "PASSED"
+	// CaseFailed means the test case failed or got an error
+	CaseFailed CaseStatus = "FAILED"
+	// CaseSkipped means the test case was skipped
+	CaseSkipped CaseStatus = "SKIPPED"
+)
+
+const (
+	// maxFailedCases limits the number of the stored failed cases, the summary still counts all of them
+	maxFailedCases = 500
+	// maxPassedCases limits the number of the stored names of the passed cases
+	maxPassedCases = 5000
+	// maxErrorLength limits the length of the error details and the stack trace of a failed case
+	maxErrorLength = 4096
+)
+
+// MaxStoredSize is the max size of a stored report. The PipelineRun data store is shared with the logs, and the
+// ConfigMap store is limited to 1 MiB.
+const MaxStoredSize = 64 * 1024
+
+// Case is a test case
+type Case struct {
+	Suite           string     `json:"suite,omitempty"`
+	ClassName       string     `json:"className,omitempty"`
+	Name            string     `json:"name"`
+	Status          CaseStatus `json:"status"`
+	Duration        float64    `json:"duration,omitempty" description:"The duration in seconds"`
+	ErrorDetails    string     `json:"errorDetails,omitempty"`
+	ErrorStackTrace string     `json:"errorStackTrace,omitempty"`
+}
+
+// FullName returns the name which identifies a test case across runs
+func (c Case) FullName() string {
+	if c.ClassName != "" {
+		return c.ClassName + "." + c.Name
+	}
+	if c.Suite != "" {
+		return c.Suite + "." + c.Name
+	}
+	return c.Name
+}
+
+// Summary is the summary of a test report
+type Summary struct {
+	Total    int     `json:"total"`
+	Passed   int     `json:"passed"`
+	Failed   int     `json:"failed"`
+	Skipped  int     `json:"skipped"`
+	Duration float64 `json:"duration" description:"The duration in seconds"`
+}
+
+// Report is the test report of a PipelineRun. Only the failed cases are stored in detail, the passed cases are stored
+// by name to find out the flaky tests.
+type Report struct {
+	Summary  Summary  `json:"summary"`
+	CommitID string   `json:"commitId,omitempty" description:"The commit which was tested"`
+	Failed   []Case   `json:"failed,omitempty" description:"The failed cases"`
+	Passed   []string `json:"passed,omitempty" description:"The names of the passed cases"`
+	// Truncated indicates some cases were dropped due to the size limitation, the summary still counts them
+	Truncated bool `json:"truncated,omitempty" description:"Some cases were dropped due to the size limitation"`
+}
+
+// Add adds the test cases into the report
+func (r *Report) Add(cases ...Case) {
+	for _, c := range cases {
+		r.Summary.Total++
+		r.Summary.Duration += c.Duration
+		switch c.Status {
+		case CasePassed:
+			r.Summary.Passed++
+			if len(r.Passed) < maxPassedCases {
+				r.Passed = append(r.Passed, c.FullName())
+			}
+		case CaseFailed:
+			r.Summary.Failed++
+			if len(r.Failed) < maxFailedCases {
+				c.ErrorDetails = truncate(c.ErrorDetails)
+				c.ErrorStackTrace = truncate(c.ErrorStackTrace)
+				r.Failed = append(r.Failed, c)
+			}
+		default:
+			r.Summary.Skipped++
+		}
+	}
+}
+
+// Parse parses a stored report, the report is empty if there is no data
+func Parse(data string) (report *Report, err error) {
+	report = &Report{}
+	if data != "" {
+		err = json.Unmarshal([]byte(data), report)
+	}
+	return
+}
+
+// Truncate drops the details of the cases until the report fits the size. The stack traces are dropped first, then
+// the latter half of the passed and the failed cases in turn, the summary is always kept.
+func (r *Report) Truncate(size int) {
+	for len(r.String()) > size {
+		switch {
+		case r.hasStackTraces():
+			for i := range r.Failed {
+				r.Failed[i].ErrorStackTrace = ""
+			}
+		case len(r.Passed) > 0:
+			r.Passed = r.Passed[:len(r.Passed)/2]
+		case len(r.Failed) > 0:
+			r.Failed = r.Failed[:len(r.Failed)/2]
+		default:
+			return
+		}
+		r.Truncated = true
+	}
+}
+
+func (r *Report) hasStackTraces() bool {
+	for _, c := range r.Failed {
+		if c.ErrorStackTrace != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// String returns the report in JSON
+func (r *Report) String() string {
+	data, _ := json.Marshal(r)
+	return string(data)
+}
+
+func truncate(text string) string {
+	if len(text) > maxErrorLength {
+		return text[:maxErrorLength] + "..."
+	}
+	return text
+}
+
+// jenkinsReport is the test report from the Jenkins API /testReport/api/json of the junit plugin
+type jenkinsReport struct {
+	Suites []struct {
+		Name  string `json:"name"`
+		Cases []struct {
+			ClassName       string  `json:"className"`
+			Name            string  `json:"name"`
+			Status          string  `json:"status"`
+			Duration        float64 `json:"duration"`
+			ErrorDetails    string  `json:"errorDetails"`
+			ErrorStackTrace string  `json:"errorStackTrace"`
+		} `json:"cases"`
+	} `json:"suites"`
+}
+
+// ParseJenkinsReport parses the test cases from the Jenkins test report
+func ParseJenkinsReport(data []byte) (cases []Case, err error) {
+	report := &jenkinsReport{}
+	if err = json.Unmarshal(data, report); err != nil {
+		return
+	}
+	for _, suite := range report.Suites {
+		for _, c := range suite.Cases {
+			status := CasePassed
+			switch c.Status {
+			case "FAILED", "REGRESSION":
+				status = CaseFailed
+			case "SKIPPED":
+				status = CaseSkipped
+			}
+			cases = append(cases, Case{
+				Suite:           suite.Name,
+				ClassName:       c.ClassName,
+				Name:            c.Name,
+				Status:          status,
+				Duration:        c.Duration,
+				ErrorDetails:    c.ErrorDetails,
+				ErrorStackTrace: c.ErrorStackTrace,
+			})
+		}
+	}
+	return
+}
+
+// junitSuite is a testsuite of a JUnit XML report, the suites might be nested
+type junitSuite struct {
+	Name   string       `xml:"name,attr"`
+	Suites []junitSuite `xml:"testsuite"`
+	Cases  []struct {
+		ClassName string `xml:"classname,attr"`
+		Name      string `xml:"name,attr"`
+		Time      string `xml:"time,attr"`
+		Failure   *struct {
+			Message string `xml:"message,attr"`
+			Text    string `xml:",chardata"`
+		} `xml:"failure"`
+		Error *struct {
+			Message string `xml:"message,attr"`
+			Text    string `xml:",chardata"`
+		} `xml:"error"`
+		Skipped *struct{} `xml:"skipped"`
+	} `xml:"testcase"`
+}
+
+// ParseJUnit parses the test cases from a JUnit XML report, the root element could be testsuites or testsuite
+func ParseJUnit(data []byte) (cases []Case, err error) {
+	root := &junitSuite{}
+	if err = xml.Unmarshal(data, root); err != nil {
+		err = fmt.Errorf("invalid JUnit report: %v", err)
+		return
+	}
+	cases = root.cases()
+	return
+}
+
+func (s *junitSuite) cases() (cases []Case) {
+	for _, c := range s.Cases {
+		duration, _ := strconv.ParseFloat(strings.ReplaceAll(c.Time, ",", ""), 64)
+		testCase := Case{Suite: s.Name, ClassName: c.ClassName, Name: c.Name, Status: CasePassed, Duration: duration}
+		switch {
+		case c.Failure != nil:
+			testCase.Status = CaseFailed
+			testCase.ErrorDetails, testCase.ErrorStackTrace = c.Failure.Message, strings.TrimSpace(c.Failure.Text)
+		case c.Error != nil:
+			testCase.Status = CaseFailed
+			testCase.ErrorDetails, testCase.ErrorStackTrace = c.Error.Message, strings.TrimSpace(c.Error.Text)
+		case c.Skipped != nil:
+			testCase.Status = CaseSkipped
+		}
+		cases = append(cases, testCase)
+	}
+	for i := range s.Suites {
+		cases = append(cases, s.Suites[i].cases()...)
+	}
+	return
+}
+
+// RunReport is the test report of a PipelineRun
+type RunReport struct {
+	PipelineRun string  `json:"pipelineRun"`
+	RunID       string  `json:"runId,omitempty"`
+	Report      *Report `json:"-"`
+}
+
+// TrendItem is the test summary of a PipelineRun
+type TrendItem struct {
+	PipelineRun string  `json:"pipelineRun"`
+	RunID       string  `json:"runId,omitempty"`
+	CommitID    string  `json:"commitId,omitempty"`
+	Summary     Summary `json:"summary"`
+}
+
+// GetTrend returns the test summaries of the PipelineRuns in the same order, the runs without reports are ignored
+func GetTrend(runs []RunReport) (trend []TrendItem) {
+	trend = []TrendItem{}
+	for _, run := range runs {
+		if run.Report == nil || run.Report.Summary.Total == 0 {
+			continue
+		}
+		trend = append(trend, TrendItem{
+			PipelineRun: run.PipelineRun,
+			RunID:       run.RunID,
+			CommitID:    run.Report.CommitID,
+			Summary:     run.Report.Summary,
+		})
+	}
+	return
+}
+
+// FlakyTest is a test which both passed and failed on the same commit
+type FlakyTest struct {
+	Name         string   `json:"name"`
+	CommitID     string   `json:"commitId"`
+	PassedRuns   []string `json:"passedRuns"`
+	FailedRuns   []string `json:"failedRuns"`
+	ErrorDetails string   `json:"errorDetails,omitempty" description:"The error details of the latest failure"`
+}
+
+// GetFlakyTests returns the tests which both passed and failed on the same commit, the runs without a commit are
+// ignored. The runs are expected in the order from the latest one.
+func GetFlakyTests(runs []RunReport) (flakyTests []FlakyTest) {
+	type key struct{ name, commitID string }
+	results := map[key]*FlakyTest{}
+	getResult := func(k key) *FlakyTest {
+		if result, ok := results[k]; ok {
+			return result
+		}
+		results[k] = &FlakyTest{Name: k.name, CommitID: k.commitID, PassedRuns: []string{}, FailedRuns: []string{}}
+		return results[k]
+	}
+	for _, run := range runs {
+		if run.Report == nil || run.Report.CommitID == "" {
+			continue
+		}
+		for _, c := range run.Report.Failed {
+			result := getResult(key{c.FullName(), run.Report.CommitID})
+			result.FailedRuns = append(result.FailedRuns, run.PipelineRun)
+			if result.ErrorDetails == "" {
+				result.ErrorDetails = c.ErrorDetails
+			}
+		}
+		for _, name := range run.Report.Passed {
+			result := getResult(key{name, run.Report.CommitID})
+			result.PassedRuns = append(result.PassedRuns, run.PipelineRun)
+		}
+	}
+
+	flakyTests = []FlakyTest{}
+	for _, result := range results {
+		if len(result.PassedRuns) > 0 && len(result.FailedRuns) > 0 {
+			flakyTests = append(flakyTests, *result)
+		}
+	}
+	// the most flaky ones first
+	sort.Slice(flakyTests, func(i, j int) bool {
+		if len(flakyTests[i].FailedRuns) != len(flakyTests[j].FailedRuns) {
+			return len(flakyTests[i].FailedRuns) > len(flakyTests[j].FailedRuns)
+		}
+		if flakyTests[i].Name != flakyTests[j].Name {
+			return flakyTests[i].Name < flakyTests[j].Name
+		}
+		return flakyTests[i].CommitID < flakyTests[j].CommitID
+	})
+	return
+}
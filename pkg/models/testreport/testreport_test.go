@@ -0,0 +1,161 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package testreport
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseJUnit(t *testing.T) {
+	cases, err := ParseJUnit([]byte(`<?xml version="1.0" encoding="UTF-8"?>
+<testsuites>
+  <testsuite name="suite-a">
+    <testcase classname="pkg.A" name="pass" time="1.5"/>
+    <testcase classname="pkg.A" name="fail" time="0.5">
+      <failure message="expected 1">stack trace</failure>
+    </testcase>
+    <testsuite name="nested">
+      <testcase classname="pkg.B" name="error"><error message="panic"/></testcase>
+      <testcase classname="pkg.B" name="skip"><skipped/></testcase>
+    </testsuite>
+  </testsuite>
+</testsuites>`))
+	assert.Nil(t, err)
+	assert.Equal(t, []Case{
+		{Suite: "suite-a", ClassName: "pkg.A", Name: "pass", Status: CasePassed, Duration: 1.5},
+		{Suite: "suite-a", ClassName: "pkg.A", Name: "fail", Status: CaseFailed, Duration: 0.5,
+			ErrorDetails: "expected 1", ErrorStackTrace: "stack trace"},
+		{Suite: "nested", ClassName: "pkg.B", Name: "error", Status: CaseFailed, ErrorDetails: "panic"},
+		{Suite: "nested", ClassName: "pkg.B", Name: "skip", Status: CaseSkipped},
+	}, cases)
+
+	// a single testsuite is the root element
+	cases, err = ParseJUnit([]byte(`<testsuite name="single"><testcase name="pass" time="1,000.5"/></testsuite>`))
+	assert.Nil(t, err)
+	assert.Equal(t, []Case{{Suite: "single", Name: "pass", Status: CasePassed, Duration: 1000.5}}, cases)
+
+	_, err = ParseJUnit([]byte("invalid"))
+	assert.NotNil(t, err)
+}
+
+func TestParseJenkinsReport(t *testing.T) {
+	cases, err := ParseJenkinsReport([]byte(`{"failCount":2,"passCount":2,"skipCount":1,"suites":[{"name":"suite","cases":[
+{"className":"pkg.A","name":"passed","status":"PASSED","duration":0.1},
+{"className":"pkg.A","name":"fixed","status":"FIXED"},
+{"className":"pkg.A","name":"failed","status":"FAILED","errorDetails":"details","errorStackTrace":"trace"},
+{"className":"pkg.A","name":"regression","status":"REGRESSION"},
+{"className":"pkg.A","name":"skipped","status":"SKIPPED"}]}]}`))
+	assert.Nil(t, err)
+	report := &Report{}
+	report.Add(cases...)
+	assert.Equal(t, Summary{Total: 5, Passed: 2, Failed: 2, Skipped: 1, Duration: 0.1}, report.Summary)
+	assert.Equal(t, []string{"pkg.A.passed", "pkg.A.fixed"}, report.Passed)
+	assert.Equal(t, "details", report.Failed[0].ErrorDetails)
+	assert.Equal(t, "trace", report.Failed[0].ErrorStackTrace)
+
+	_, err = ParseJenkinsReport([]byte("invalid"))
+	assert.NotNil(t, err)
+}
+
+func TestReport(t *testing.T) {
+	report, err := Parse("")
+	assert.Nil(t, err)
+	assert.Equal(t, &Report{}, report)
+	_, err = Parse("invalid")
+	assert.NotNil(t, err)
+
+	for i := 0; i < maxFailedCases+1; i++ {
+		report.Add(Case{Name: "fail", Status: CaseFailed, ErrorDetails: strings.Repeat("a", maxErrorLength+1)})
+	}
+	assert.Equal(t, maxFailedCases+1, report.Summary.Failed)
+	assert.Len(t, report.Failed, maxFailedCases)
+	assert.Len(t, report.Failed[0].ErrorDetails, maxErrorLength+3)
+
+	parsed, err := Parse(report.String())
+	assert.Nil(t, err)
+	assert.Equal(t, report, parsed)
+}
+
+func TestReport_Truncate(t *testing.T) {
+	report := &Report{}
+	for i := 0; i < 100; i++ {
+		report.Add(Case{Name: fmt.Sprintf("pass-%d", i), Status: CasePassed})
+		report.Add(Case{Name: fmt.Sprintf("fail-%d", i), Status: CaseFailed, ErrorDetails: "error",
+			ErrorStackTrace: strings.Repeat("a", maxErrorLength)})
+	}
+
+	small := *report
+	small.Truncate(len(report.String()))
+	assert.False(t, small.Truncated)
+
+	report.Truncate(4096)
+	assert.True(t, report.Truncated)
+	assert.LessOrEqual(t, len(report.String()), 4096)
+	assert.Equal(t, Summary{Total: 200, Passed: 100, Failed: 100}, report.Summary)
+	assert.Empty(t, report.Failed[0].ErrorStackTrace)
+	assert.Equal(t, "error", report.Failed[0].ErrorDetails)
+
+	report.Truncate(0)
+	assert.Empty(t, report.Passed)
+	assert.Empty(t, report.Failed)
+	assert.Equal(t, 200, report.Summary.Total)
+}
+
+func TestCase_FullName(t *testing.T) {
+	assert.Equal(t, "pkg.A.test", Case{Suite: "suite", ClassName: "pkg.A", Name: "test"}.FullName())
+	assert.Equal(t, "suite.test", Case{Suite: "suite", Name: "test"}.FullName())
+	assert.Equal(t, "test", Case{Name: "test"}.FullName())
+}
+
+func TestGetTrendAndFlakyTests(t *testing.T) {
+	newReport := func(commitID string, passed []string, failed ...string) *Report {
+		report := &Report{CommitID: commitID}
+		for _, name := range passed {
+			report.Add(Case{Name: name, Status: CasePassed})
+		}
+		for _, name := range failed {
+			report.Add(Case{Name: name, Status: CaseFailed, ErrorDetails: "error of " + commitID})
+		}
+		return report
+	}
+	runs := []RunReport{
+		{PipelineRun: "run-4", RunID: "4", Report: newReport("c2", []string{"a", "b"})},
+		{PipelineRun: "run-3", RunID: "3", Report: newReport("c2", []string{"b"}, "a")},
+		{PipelineRun: "run-2", RunID: "2", Report: newReport("c1", []string{"a"}, "b")},
+		{PipelineRun: "run-1", RunID: "1", Report: newReport("", []string{"a"}, "b")},
+		{PipelineRun: "run-0", RunID: "0", Report: &Report{}},
+		{PipelineRun: "run-x"},
+	}
+
+	trend := GetTrend(runs)
+	assert.Len(t, trend, 4)
+	assert.Equal(t, TrendItem{PipelineRun: "run-3", RunID: "3", CommitID: "c2",
+		Summary: Summary{Total: 2, Passed: 1, Failed: 1}}, trend[1])
+	assert.Equal(t, []TrendItem{}, GetTrend(nil))
+
+	assert.Equal(t, []FlakyTest{{
+		Name:         "a",
+		CommitID:     "c2",
+		PassedRuns:   []string{"run-4"},
+		FailedRuns:   []string{"run-3"},
+		ErrorDetails: "error of c2",
+	}}, GetFlakyTests(runs))
+}
@@ -22,6 +22,7 @@ import (
 	"github.com/kubesphere/ks-devops/pkg/utils/k8sutil"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
@@ -92,6 +93,16 @@ func (s *ConfigMapStore) SetAllLog(log string) {
 	s.Set(store.DataKeyAllLog, log)
 }
 
+// GetTestReport returns the test report
+func (s *ConfigMapStore) GetTestReport() string {
+	return s.Get(store.DataKeyTestReport)
+}
+
+// SetTestReport stores the test report
+func (s *ConfigMapStore) SetTestReport(report string) {
+	s.Set(store.DataKeyTestReport, report)
+}
+
 // Get returns the value by a key
 func (s *ConfigMapStore) Get(key string) string {
 	return s.cache.Data[key]
@@ -118,3 +129,23 @@ func (s *ConfigMapStore) Save() (err error) {
 func (s *ConfigMapStore) SetOwnerReference(owner metav1.OwnerReference) {
 	s.owner = owner
 }
+
+// Provider provides the PipelineRun data stores base on ConfigMaps
+type Provider struct {
+	k8sClient client.Client
+}
+
+// NewProvider creates a provider of the ConfigMap stores
+func NewProvider(k8sClient client.Client) *Provider {
+	return &Provider{k8sClient: k8sClient}
+}
+
+// Get returns the ConfigMap store of a PipelineRun
+func (p *Provider) Get(ctx context.Context, key types.NamespacedName) (store.OwnedStore, error) {
+	return NewConfigMapStore(ctx, key, p.k8sClient)
+}
+
+// Delete does nothing, the ConfigMap will be removed by the garbage collector due to the owner reference
+func (p *Provider) Delete(_ context.Context, _ types.NamespacedName) error {
+	return nil
+}
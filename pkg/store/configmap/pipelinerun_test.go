@@ -55,5 +55,24 @@ func TestConfigMapStore(t *testing.T) {
 	cmStore.SetAllLog("log")
 	assert.Equal(t, "log", cmStore.GetAllLog())
 
+	assert.Empty(t, cmStore.GetTestReport())
+	cmStore.SetTestReport("report")
+	assert.Equal(t, "report", cmStore.GetTestReport())
+
 	assert.Nil(t, cmStore.Save())
 }
+
+func TestProvider(t *testing.T) {
+	key := types.NamespacedName{Namespace: "ns", Name: "name"}
+	provider := NewProvider(fake.NewClientBuilder().Build())
+
+	dataStore, err := provider.Get(context.Background(), key)
+	assert.Nil(t, err)
+	dataStore.SetStatus("status")
+	assert.Nil(t, dataStore.Save())
+
+	dataStore, err = provider.Get(context.Background(), key)
+	assert.Nil(t, err)
+	assert.Equal(t, "status", dataStore.GetStatus())
+	assert.Nil(t, provider.Delete(context.Background(), key))
+}
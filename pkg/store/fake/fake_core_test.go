@@ -46,6 +46,10 @@ func TestFakeStore(t *testing.T) {
 	store.SetStepLog(1, 1, "step")
 	assert.Equal(t, "step", store.GetStepLog(1, 1))
 
+	assert.Empty(t, store.GetTestReport())
+	store.SetTestReport("report")
+	assert.Equal(t, "report", store.GetTestReport())
+
 	assert.Nil(t, store.Save())
 	assert.NotNil(t, store.WithError(errors.New("fake")).Save())
 }
@@ -91,3 +91,13 @@ func (s *FakeStore) GetAllLog() string {
 func (s *FakeStore) SetAllLog(log string) {
 	s.data[store.DataKeyAllLog] = log
 }
+
+// GetTestReport is a fake method
+func (s *FakeStore) GetTestReport() string {
+	return s.Get(store.DataKeyTestReport)
+}
+
+// SetTestReport is a fake method
+func (s *FakeStore) SetTestReport(report string) {
+	s.data[store.DataKeyTestReport] = report
+}
@@ -0,0 +1,124 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package object provides the PipelineRun data stores base on object storages, such as S3 or a file system.
+// Every key of the data is stored as a separate object, so only the changed keys are written when saving. The data
+// appended to a key, such as the logs, is written as a new chunk object instead of rewriting the whole key.
+package object
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	awss3 "github.com/aws/aws-sdk-go/service/s3"
+	"github.com/kubesphere/ks-devops/pkg/client/s3"
+)
+
+// ErrObjectNotFound indicates the object does not exist in the bucket
+var ErrObjectNotFound = errors.New("object not found")
+
+// Bucket represents a storage of objects
+type Bucket interface {
+	// Read returns the content of an object, the error is ErrObjectNotFound if the object does not exist
+	Read(key string) ([]byte, error)
+	// Write creates or overwrites an object
+	Write(key string, data []byte) error
+	// Delete removes an object, it is not an error if the object does not exist
+	Delete(key string) error
+}
+
+type s3Bucket struct {
+	client s3.Interface
+}
+
+// NewS3Bucket creates a bucket base on the S3 client
+func NewS3Bucket(client s3.Interface) Bucket {
+	return &s3Bucket{client: client}
+}
+
+func (b *s3Bucket) Read(key string) (data []byte, err error) {
+	if data, err = b.client.Read(key); err != nil {
+		var awsErr awserr.Error
+		if errors.As(err, &awsErr) && (awsErr.Code() == awss3.ErrCodeNoSuchKey || awsErr.Code() == "NotFound") {
+			err = ErrObjectNotFound
+		}
+	}
+	return
+}
+
+func (b *s3Bucket) Write(key string, data []byte) error {
+	return b.client.Upload(key, path.Base(key), bytes.NewReader(data))
+}
+
+func (b *s3Bucket) Delete(key string) error {
+	return b.client.Delete(key)
+}
+
+type fileSystemBucket struct {
+	root string
+}
+
+// NewFileSystemBucket creates a bucket base on a directory, it could be a mounted PersistentVolume
+func NewFileSystemBucket(root string) Bucket {
+	return &fileSystemBucket{root: root}
+}
+
+func (b *fileSystemBucket) Read(key string) (data []byte, err error) {
+	if data, err = os.ReadFile(b.path(key)); os.IsNotExist(err) {
+		err = ErrObjectNotFound
+	}
+	return
+}
+
+func (b *fileSystemBucket) Write(key string, data []byte) (err error) {
+	filePath := b.path(key)
+	if err = os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		return
+	}
+
+	// write into a temporary file first, then the readers never get a partial file
+	var tmp *os.File
+	if tmp, err = os.CreateTemp(filepath.Dir(filePath), filepath.Base(filePath)+".tmp"); err != nil {
+		return
+	}
+	defer func() {
+		_ = os.Remove(tmp.Name())
+	}()
+	if _, err = tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return
+	}
+	if err = tmp.Close(); err == nil {
+		err = os.Rename(tmp.Name(), filePath)
+	}
+	return
+}
+
+func (b *fileSystemBucket) Delete(key string) (err error) {
+	if err = os.Remove(b.path(key)); os.IsNotExist(err) {
+		err = nil
+	}
+	return
+}
+
+func (b *fileSystemBucket) path(key string) string {
+	// the key is cleaned as an absolute path, then it cannot escape from the root directory
+	return filepath.Join(b.root, filepath.FromSlash(path.Clean("/"+key)))
+}
@@ -0,0 +1,69 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package object
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	fakes3 "github.com/kubesphere/ks-devops/pkg/client/s3/fake"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileSystemBucket(t *testing.T) {
+	root := t.TempDir()
+	bucket := NewFileSystemBucket(root)
+
+	_, err := bucket.Read("a/b")
+	assert.Equal(t, ErrObjectNotFound, err)
+
+	assert.Nil(t, bucket.Write("a/b", []byte("data")))
+	data, err := bucket.Read("a/b")
+	assert.Nil(t, err)
+	assert.Equal(t, "data", string(data))
+	assert.Nil(t, bucket.Write("a/b", []byte("new")))
+	data, err = bucket.Read("a/b")
+	assert.Nil(t, err)
+	assert.Equal(t, "new", string(data))
+
+	// the key cannot escape from the root directory
+	assert.Nil(t, bucket.Write("../../c", []byte("data")))
+	_, err = os.Stat(filepath.Join(root, "c"))
+	assert.Nil(t, err)
+
+	assert.Nil(t, bucket.Delete("a/b"))
+	assert.Nil(t, bucket.Delete("a/b"))
+	_, err = bucket.Read("a/b")
+	assert.Equal(t, ErrObjectNotFound, err)
+}
+
+func TestS3Bucket(t *testing.T) {
+	bucket := NewS3Bucket(fakes3.NewFakeS3())
+
+	_, err := bucket.Read("a/b")
+	assert.Equal(t, ErrObjectNotFound, err)
+
+	assert.Nil(t, bucket.Write("a/b", []byte("data")))
+	data, err := bucket.Read("a/b")
+	assert.Nil(t, err)
+	assert.Equal(t, "data", string(data))
+
+	assert.Nil(t, bucket.Delete("a/b"))
+	_, err = bucket.Read("a/b")
+	assert.Equal(t, ErrObjectNotFound, err)
+}
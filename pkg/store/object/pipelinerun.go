@@ -0,0 +1,297 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package object
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/kubesphere/ks-devops/pkg/store/store"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// keyPrefix is the common prefix of all PipelineRun objects, it avoids conflicts in a shared bucket
+	keyPrefix = "pipelineruns"
+	indexKey  = "index.json"
+)
+
+// index records the keys and the owner of the PipelineRun data
+type index struct {
+	Owner *metav1.OwnerReference `json:"owner,omitempty"`
+	Keys  []string               `json:"keys"`
+	// Chunks is the number of objects of the keys which were appended, a key without chunks is a single object
+	Chunks map[string]int `json:"chunks,omitempty"`
+}
+
+// getChunks returns the number of objects of a key
+func (idx *index) getChunks(key string) int {
+	if chunks := idx.Chunks[key]; chunks > 1 {
+		return chunks
+	}
+	return 1
+}
+
+// Store represents a PipelineRun data store base on a bucket
+type Store struct {
+	bucket Bucket
+	prefix string
+
+	index      index
+	indexDirty bool
+	cache      map[string]string
+	// dirty is the length of the value which was already in the bucket, only the rest of the value is written if the
+	// value was appended, such as the logs
+	dirty map[string]int
+}
+
+// Get returns the value by a key, the objects are only read once
+func (s *Store) Get(key string) string {
+	if value, ok := s.cache[key]; ok {
+		return value
+	}
+	if !s.hasKey(key) {
+		return ""
+	}
+
+	var value []byte
+	for i := 0; i < s.index.getChunks(key); i++ {
+		data, err := s.bucket.Read(path.Join(s.prefix, getChunkKey(key, i)))
+		if err != nil {
+			klog.V(4).Infof("failed to read the object %s/%s, error: %v", s.prefix, getChunkKey(key, i), err)
+			return ""
+		}
+		value = append(value, data...)
+	}
+	s.cache[key] = string(value)
+	return s.cache[key]
+}
+
+// Set puts a key and value
+func (s *Store) Set(key, value string) {
+	current, cached := s.cache[key]
+	if cached && current == value {
+		return
+	}
+
+	// the value is appended if it starts with the current one which is in the bucket already
+	var stored int
+	if cached && s.hasKey(key) && strings.HasPrefix(value, current) {
+		stored = len(current)
+		if dirtyStored, dirty := s.dirty[key]; dirty && dirtyStored < stored {
+			stored = dirtyStored
+		}
+	}
+	s.cache[key] = value
+	s.dirty[key] = stored
+	if !s.hasKey(key) {
+		s.index.Keys = append(s.index.Keys, key)
+		s.indexDirty = true
+	}
+}
+
+// Save writes the changed keys into the bucket, the appended data is written as a new chunk
+func (s *Store) Save() (err error) {
+	var staleObjects []string
+	for key, stored := range s.dirty {
+		value, chunks := s.cache[key], s.index.getChunks(key)
+		if stored > 0 {
+			if err = s.bucket.Write(path.Join(s.prefix, getChunkKey(key, chunks)), []byte(value[stored:])); err != nil {
+				return
+			}
+			if s.index.Chunks == nil {
+				s.index.Chunks = map[string]int{}
+			}
+			s.index.Chunks[key] = chunks + 1
+		} else {
+			if err = s.bucket.Write(path.Join(s.prefix, key), []byte(value)); err != nil {
+				return
+			}
+			// the old chunks are removed once the index doesn't refer to them
+			for i := 1; i < chunks; i++ {
+				staleObjects = append(staleObjects, path.Join(s.prefix, getChunkKey(key, i)))
+			}
+			delete(s.index.Chunks, key)
+		}
+		if stored > 0 || chunks > 1 {
+			s.indexDirty = true
+		}
+		delete(s.dirty, key)
+	}
+
+	// the index is written at last, then all the keys in the index are readable
+	if s.indexDirty {
+		var data []byte
+		if data, err = json.Marshal(s.index); err == nil {
+			if err = s.bucket.Write(path.Join(s.prefix, indexKey), data); err == nil {
+				s.indexDirty = false
+			}
+		}
+	}
+	if err == nil {
+		for _, object := range staleObjects {
+			if deleteErr := s.bucket.Delete(object); deleteErr != nil {
+				klog.V(4).Infof("failed to delete the stale object %s, error: %v", object, deleteErr)
+			}
+		}
+	}
+	return
+}
+
+// SetOwnerReference records the owner of the data
+func (s *Store) SetOwnerReference(owner metav1.OwnerReference) {
+	if s.index.Owner == nil || !reflect.DeepEqual(*s.index.Owner, owner) {
+		s.index.Owner = &owner
+		s.indexDirty = true
+	}
+}
+
+// GetStages returns the stage data
+func (s *Store) GetStages() string {
+	return s.Get(store.DataKeyStage)
+}
+
+// SetStages stores the stage data
+func (s *Store) SetStages(stages string) {
+	s.Set(store.DataKeyStage, stages)
+}
+
+// GetStatus returns the status
+func (s *Store) GetStatus() string {
+	return s.Get(store.DataKeyStatus)
+}
+
+// SetStatus stores the status
+func (s *Store) SetStatus(status string) {
+	s.Set(store.DataKeyStatus, status)
+}
+
+// GetStepLog returns the step log
+func (s *Store) GetStepLog(stage, step int) string {
+	return s.Get(store.StepLogKey(stage, step))
+}
+
+// SetStepLog stores the step log
+func (s *Store) SetStepLog(stage, step int, log string) {
+	s.Set(store.StepLogKey(stage, step), log)
+}
+
+// GetAllLog returns the whole log
+func (s *Store) GetAllLog() string {
+	return s.Get(store.DataKeyAllLog)
+}
+
+// SetAllLog store the whole log
+func (s *Store) SetAllLog(log string) {
+	s.Set(store.DataKeyAllLog, log)
+}
+
+// GetTestReport returns the test report
+func (s *Store) GetTestReport() string {
+	return s.Get(store.DataKeyTestReport)
+}
+
+// SetTestReport stores the test report
+func (s *Store) SetTestReport(report string) {
+	s.Set(store.DataKeyTestReport, report)
+}
+
+func (s *Store) hasKey(key string) bool {
+	for _, item := range s.index.Keys {
+		if item == key {
+			return true
+		}
+	}
+	return false
+}
+
+// Provider provides the PipelineRun data stores base on a bucket
+type Provider struct {
+	bucket Bucket
+}
+
+// NewProvider creates a provider of the object stores
+func NewProvider(bucket Bucket) *Provider {
+	return &Provider{bucket: bucket}
+}
+
+// Get returns the object store of a PipelineRun
+func (p *Provider) Get(_ context.Context, key types.NamespacedName) (dataStore store.OwnedStore, err error) {
+	var idx *index
+	if idx, err = p.readIndex(key); err == nil {
+		dataStore = &Store{
+			bucket: p.bucket,
+			prefix: getPrefix(key),
+			index:  *idx,
+			cache:  map[string]string{},
+			dirty:  map[string]int{},
+		}
+	}
+	return
+}
+
+// Delete removes all the objects of a PipelineRun
+func (p *Provider) Delete(_ context.Context, key types.NamespacedName) (err error) {
+	var idx *index
+	if idx, err = p.readIndex(key); err != nil {
+		return
+	}
+
+	prefix := getPrefix(key)
+	for _, item := range idx.Keys {
+		for i := 0; i < idx.getChunks(item); i++ {
+			if err = p.bucket.Delete(path.Join(prefix, getChunkKey(item, i))); err != nil {
+				return
+			}
+		}
+	}
+	// the index is removed at last, then it is able to retry if failed to delete the other objects
+	err = p.bucket.Delete(path.Join(prefix, indexKey))
+	return
+}
+
+func (p *Provider) readIndex(key types.NamespacedName) (idx *index, err error) {
+	idx = &index{}
+	var data []byte
+	if data, err = p.bucket.Read(path.Join(getPrefix(key), indexKey)); err == ErrObjectNotFound {
+		err = nil
+	} else if err == nil {
+		if err = json.Unmarshal(data, idx); err != nil {
+			err = fmt.Errorf("failed to parse the data index of PipelineRun %s, error: %v", key.String(), err)
+		}
+	}
+	return
+}
+
+func getPrefix(key types.NamespacedName) string {
+	return path.Join(keyPrefix, key.Namespace, key.Name)
+}
+
+// getChunkKey returns the object key of a chunk, the first chunk is the key itself
+func getChunkKey(key string, chunk int) string {
+	if chunk == 0 {
+		return key
+	}
+	return key + "." + strconv.Itoa(chunk)
+}
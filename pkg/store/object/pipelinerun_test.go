@@ -0,0 +1,121 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package object
+
+import (
+	"context"
+	"testing"
+
+	"github.com/kubesphere/ks-devops/pkg/store/store"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// countingBucket counts the writing of objects
+type countingBucket struct {
+	Bucket
+	writes map[string]int
+}
+
+func (b *countingBucket) Write(key string, data []byte) error {
+	b.writes[key]++
+	return b.Bucket.Write(key, data)
+}
+
+func TestProvider(t *testing.T) {
+	bucket := &countingBucket{Bucket: NewFileSystemBucket(t.TempDir()), writes: map[string]int{}}
+	provider := NewProvider(bucket)
+	key := types.NamespacedName{Namespace: "ns", Name: "name"}
+	ctx := context.Background()
+
+	// no data of the PipelineRun
+	dataStore, err := provider.Get(ctx, key)
+	assert.Nil(t, err)
+	assert.Empty(t, dataStore.GetStatus())
+	assert.Nil(t, dataStore.Save())
+	assert.Empty(t, bucket.writes)
+
+	dataStore.SetOwnerReference(metav1.OwnerReference{Name: "name", UID: "uid"})
+	dataStore.SetStatus("status")
+	dataStore.SetStages("stages")
+	dataStore.SetStepLog(1, 2, "step")
+	dataStore.SetAllLog("log")
+	assert.Nil(t, dataStore.Save())
+	assert.Equal(t, 1, bucket.writes["pipelineruns/ns/name/index.json"])
+	assert.Equal(t, 1, bucket.writes["pipelineruns/ns/name/"+store.DataKeyAllLog])
+
+	// only the changed keys are written
+	dataStore, err = provider.Get(ctx, key)
+	assert.Nil(t, err)
+	assert.Equal(t, "status", dataStore.GetStatus())
+	assert.Equal(t, "stages", dataStore.GetStages())
+	assert.Equal(t, "step", dataStore.GetStepLog(1, 2))
+	assert.Equal(t, "log", dataStore.GetAllLog())
+	assert.Empty(t, dataStore.GetStepLog(1, 3))
+	dataStore.SetOwnerReference(metav1.OwnerReference{Name: "name", UID: "uid"})
+	dataStore.SetStatus("status")
+	dataStore.SetAllLog("log more")
+	dataStore.SetAllLog("log more and more")
+	assert.Nil(t, dataStore.Save())
+	assert.Equal(t, 2, bucket.writes["pipelineruns/ns/name/index.json"])
+	assert.Equal(t, 1, bucket.writes["pipelineruns/ns/name/"+store.DataKeyStatus])
+	// the appended log is written as a new chunk
+	assert.Equal(t, 1, bucket.writes["pipelineruns/ns/name/"+store.DataKeyAllLog])
+	assert.Equal(t, 1, bucket.writes["pipelineruns/ns/name/"+store.DataKeyAllLog+".1"])
+	data, err := bucket.Read("pipelineruns/ns/name/" + store.DataKeyAllLog + ".1")
+	assert.Nil(t, err)
+	assert.Equal(t, " more and more", string(data))
+
+	dataStore, err = provider.Get(ctx, key)
+	assert.Nil(t, err)
+	assert.Equal(t, "log more and more", dataStore.GetAllLog())
+	dataStore.SetAllLog("log more and more!")
+	assert.Nil(t, dataStore.Save())
+	dataStore, err = provider.Get(ctx, key)
+	assert.Nil(t, err)
+	assert.Equal(t, "log more and more!", dataStore.GetAllLog())
+
+	// the chunks are merged if the value is rewritten
+	dataStore.SetAllLog("new log")
+	assert.Nil(t, dataStore.Save())
+	assert.Equal(t, 2, bucket.writes["pipelineruns/ns/name/"+store.DataKeyAllLog])
+	_, err = bucket.Read("pipelineruns/ns/name/" + store.DataKeyAllLog + ".1")
+	assert.Equal(t, ErrObjectNotFound, err)
+	dataStore, err = provider.Get(ctx, key)
+	assert.Nil(t, err)
+	assert.Equal(t, "new log", dataStore.GetAllLog())
+	dataStore.SetAllLog("new log appended")
+	assert.Nil(t, dataStore.Save())
+
+	// remove all the data
+	assert.Nil(t, provider.Delete(ctx, key))
+	assert.Nil(t, provider.Delete(ctx, key))
+	dataStore, err = provider.Get(ctx, key)
+	assert.Nil(t, err)
+	assert.Empty(t, dataStore.GetAllLog())
+	_, err = bucket.Read("pipelineruns/ns/name/" + store.DataKeyAllLog + ".1")
+	assert.Equal(t, ErrObjectNotFound, err)
+}
+
+func TestProvider_invalidIndex(t *testing.T) {
+	bucket := NewFileSystemBucket(t.TempDir())
+	assert.Nil(t, bucket.Write("pipelineruns/ns/name/index.json", []byte("invalid")))
+
+	_, err := NewProvider(bucket).Get(context.Background(), types.NamespacedName{Namespace: "ns", Name: "name"})
+	assert.NotNil(t, err)
+}
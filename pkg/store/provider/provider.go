@@ -0,0 +1,175 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package provider creates the PipelineRun data store provider which is chosen in the configuration
+package provider
+
+import (
+	"context"
+	"fmt"
+
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/kubesphere/ks-devops/pkg/api/devops/v1alpha3"
+	"github.com/kubesphere/ks-devops/pkg/client/s3"
+	"github.com/kubesphere/ks-devops/pkg/config"
+	cmstore "github.com/kubesphere/ks-devops/pkg/store/configmap"
+	"github.com/kubesphere/ks-devops/pkg/store/object"
+	"github.com/kubesphere/ks-devops/pkg/store/store"
+)
+
+// NewProvider creates the PipelineRun data store provider according to the options.
+// The ConfigMap provider is the default one, because the existing data might be there.
+func NewProvider(options *config.PipelineRunDataStoreOptions, k8sClient client.Client, s3Client s3.Interface) (
+	provider store.Provider, err error) {
+	if options == nil {
+		options = config.NewPipelineRunDataStoreOptions()
+	}
+
+	switch options.Type {
+	case "", config.PipelineRunDataStoreConfigMap:
+		provider = cmstore.NewProvider(k8sClient)
+	case config.PipelineRunDataStoreS3:
+		if s3Client == nil {
+			err = fmt.Errorf("the s3 options are required by the PipelineRun data store type: %s", options.Type)
+		} else {
+			provider = object.NewProvider(object.NewS3Bucket(s3Client))
+		}
+	case config.PipelineRunDataStoreFileSystem:
+		if options.Path == "" {
+			err = fmt.Errorf("the path is required by the PipelineRun data store type: %s", options.Type)
+		} else {
+			provider = object.NewProvider(object.NewFileSystemBucket(options.Path))
+		}
+	default:
+		err = fmt.Errorf("unknown pipelineRun data store type: %s", options.Type)
+	}
+	return
+}
+
+// migrateBatchSize is the page size of listing PipelineRuns when migrating
+const migrateBatchSize = 500
+
+// Migrate moves the PipelineRun data from ConfigMaps into the target provider, the ConfigMaps are deleted once the
+// copies were verified. The reader should not be a cached client, or it will cache all the ConfigMaps of the cluster.
+func Migrate(ctx context.Context, reader client.Reader, k8sClient client.Client, target store.Provider) (
+	count int, err error) {
+	listOptions := &client.ListOptions{Limit: migrateBatchSize}
+	for {
+		pipelineRuns := &v1alpha3.PipelineRunList{}
+		if err = reader.List(ctx, pipelineRuns, listOptions); err != nil {
+			return
+		}
+
+		for i := range pipelineRuns.Items {
+			var migrated bool
+			if migrated, err = migratePipelineRun(ctx, reader, k8sClient, target, &pipelineRuns.Items[i]); err != nil {
+				return
+			} else if migrated {
+				count++
+			}
+		}
+
+		if listOptions.Continue = pipelineRuns.Continue; listOptions.Continue == "" {
+			return
+		}
+	}
+}
+
+func migratePipelineRun(ctx context.Context, reader client.Reader, k8sClient client.Client, target store.Provider,
+	pr *v1alpha3.PipelineRun) (migrated bool, err error) {
+	key := types.NamespacedName{Namespace: pr.Namespace, Name: pr.Name}
+	cm := &v1.ConfigMap{}
+	if err = reader.Get(ctx, key, cm); err != nil {
+		if apierrors.IsNotFound(err) {
+			err = nil
+		}
+		return
+	}
+	// only move the ConfigMap which belongs to the PipelineRun
+	if !isOwnedBy(cm, pr) {
+		return
+	}
+
+	var dataStore store.OwnedStore
+	if dataStore, err = target.Get(ctx, key); err != nil {
+		return
+	}
+	copied := map[string]string{}
+	for dataKey, value := range cm.Data {
+		// the data in the target store is newer
+		if dataStore.Get(dataKey) == "" {
+			dataStore.Set(dataKey, value)
+			copied[dataKey] = value
+		}
+	}
+	dataStore.SetOwnerReference(ownerReference(pr))
+	if err = dataStore.Save(); err != nil {
+		return
+	}
+	if err = verifyMigration(ctx, target, key, cm, copied); err != nil {
+		return
+	}
+
+	// the ConfigMap might be changed after it was copied
+	if err = client.IgnoreNotFound(k8sClient.Delete(ctx, cm,
+		client.Preconditions{ResourceVersion: &cm.ResourceVersion})); err == nil {
+		migrated = true
+		klog.V(4).Infof("moved the data of PipelineRun %s from the ConfigMap", key.String())
+	}
+	return
+}
+
+// verifyMigration reads the data of a PipelineRun from the target store again, and makes sure that all the data of the
+// ConfigMap is there
+func verifyMigration(ctx context.Context, target store.Provider, key types.NamespacedName, cm *v1.ConfigMap,
+	copied map[string]string) (err error) {
+	var dataStore store.OwnedStore
+	if dataStore, err = target.Get(ctx, key); err != nil {
+		return
+	}
+	for dataKey := range cm.Data {
+		value := dataStore.Get(dataKey)
+		if expected, ok := copied[dataKey]; (ok && value != expected) || (!ok && value == "") {
+			err = fmt.Errorf("failed to verify the data %s of PipelineRun %s in the target store", dataKey, key.String())
+			return
+		}
+	}
+	return
+}
+
+func isOwnedBy(cm *v1.ConfigMap, pr *v1alpha3.PipelineRun) bool {
+	for _, owner := range cm.OwnerReferences {
+		if owner.UID == pr.UID {
+			return true
+		}
+	}
+	return false
+}
+
+func ownerReference(pr *v1alpha3.PipelineRun) metav1.OwnerReference {
+	return metav1.OwnerReference{
+		APIVersion: v1alpha3.GroupVersion.String(),
+		Kind:       "PipelineRun",
+		Name:       pr.Name,
+		UID:        pr.UID,
+	}
+}
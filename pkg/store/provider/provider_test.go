@@ -0,0 +1,173 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package provider
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/kubesphere/ks-devops/pkg/api/devops/v1alpha3"
+	"github.com/kubesphere/ks-devops/pkg/client/s3"
+	fakes3 "github.com/kubesphere/ks-devops/pkg/client/s3/fake"
+	"github.com/kubesphere/ks-devops/pkg/config"
+	cmstore "github.com/kubesphere/ks-devops/pkg/store/configmap"
+	"github.com/kubesphere/ks-devops/pkg/store/object"
+	"github.com/kubesphere/ks-devops/pkg/store/store"
+)
+
+func TestNewProvider(t *testing.T) {
+	tests := []struct {
+		name     string
+		options  *config.PipelineRunDataStoreOptions
+		s3Client s3.Interface
+		wantType store.Provider
+		wantErr  bool
+	}{{
+		name:     "default options",
+		wantType: &cmstore.Provider{},
+	}, {
+		name:     "configmap",
+		options:  &config.PipelineRunDataStoreOptions{Type: config.PipelineRunDataStoreConfigMap},
+		wantType: &cmstore.Provider{},
+	}, {
+		name:     "s3",
+		options:  &config.PipelineRunDataStoreOptions{Type: config.PipelineRunDataStoreS3},
+		s3Client: fakes3.NewFakeS3(),
+		wantType: &object.Provider{},
+	}, {
+		name:    "s3 without client",
+		options: &config.PipelineRunDataStoreOptions{Type: config.PipelineRunDataStoreS3},
+		wantErr: true,
+	}, {
+		name:     "fs",
+		options:  &config.PipelineRunDataStoreOptions{Type: config.PipelineRunDataStoreFileSystem, Path: "/tmp"},
+		wantType: &object.Provider{},
+	}, {
+		name:    "fs without path",
+		options: &config.PipelineRunDataStoreOptions{Type: config.PipelineRunDataStoreFileSystem},
+		wantErr: true,
+	}, {
+		name:    "unknown type",
+		options: &config.PipelineRunDataStoreOptions{Type: "fake"},
+		wantErr: true,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider, err := NewProvider(tt.options, fake.NewClientBuilder().Build(), tt.s3Client)
+			if tt.wantErr {
+				assert.NotNil(t, err)
+			} else {
+				assert.Nil(t, err)
+				assert.IsType(t, tt.wantType, provider)
+			}
+		})
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	schema, err := v1alpha3.SchemeBuilder.Register().Build()
+	assert.Nil(t, err)
+	err = v1.SchemeBuilder.AddToScheme(schema)
+	assert.Nil(t, err)
+
+	pr := &v1alpha3.PipelineRun{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "pr", UID: "uid"}}
+	newPR := &v1alpha3.PipelineRun{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "new", UID: "new-uid"}}
+	otherPR := &v1alpha3.PipelineRun{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "other", UID: "other-uid"}}
+	cm := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:       "ns",
+			Name:            "pr",
+			OwnerReferences: []metav1.OwnerReference{{Name: "pr", UID: "uid"}},
+		},
+		Data: map[string]string{
+			store.DataKeyStatus: "status",
+			store.DataKeyAllLog: "log",
+		},
+	}
+	// the ConfigMap does not belong to the PipelineRun
+	otherCM := &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "other"}}
+
+	c := fake.NewClientBuilder().WithScheme(schema).WithObjects(pr, newPR, otherPR, cm, otherCM).Build()
+	target := object.NewProvider(object.NewFileSystemBucket(t.TempDir()))
+	ctx := context.Background()
+
+	// the data in the target store is kept
+	dataStore, err := target.Get(ctx, types.NamespacedName{Namespace: "ns", Name: "pr"})
+	assert.Nil(t, err)
+	dataStore.SetAllLog("newer log")
+	assert.Nil(t, dataStore.Save())
+
+	count, err := Migrate(ctx, c, c, target)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, count)
+
+	dataStore, err = target.Get(ctx, types.NamespacedName{Namespace: "ns", Name: "pr"})
+	assert.Nil(t, err)
+	assert.Equal(t, "status", dataStore.GetStatus())
+	assert.Equal(t, "newer log", dataStore.GetAllLog())
+
+	err = c.Get(ctx, types.NamespacedName{Namespace: "ns", Name: "pr"}, &v1.ConfigMap{})
+	assert.True(t, apierrors.IsNotFound(err))
+	assert.Nil(t, c.Get(ctx, types.NamespacedName{Namespace: "ns", Name: "other"}, &v1.ConfigMap{}))
+
+	// nothing to move
+	count, err = Migrate(ctx, c, c, target)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, count)
+}
+
+// lossyBucket drops the writing of objects silently
+type lossyBucket struct {
+	object.Bucket
+}
+
+func (b *lossyBucket) Write(_ string, _ []byte) error {
+	return nil
+}
+
+func TestMigrate_verify(t *testing.T) {
+	schema, err := v1alpha3.SchemeBuilder.Register().Build()
+	assert.Nil(t, err)
+	err = v1.SchemeBuilder.AddToScheme(schema)
+	assert.Nil(t, err)
+
+	pr := &v1alpha3.PipelineRun{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "pr", UID: "uid"}}
+	cm := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:       "ns",
+			Name:            "pr",
+			OwnerReferences: []metav1.OwnerReference{{Name: "pr", UID: "uid"}},
+		},
+		Data: map[string]string{store.DataKeyStatus: "status"},
+	}
+	c := fake.NewClientBuilder().WithScheme(schema).WithObjects(pr, cm).Build()
+	target := object.NewProvider(&lossyBucket{Bucket: object.NewFileSystemBucket(t.TempDir())})
+	ctx := context.Background()
+
+	// the ConfigMap is kept if the data is not in the target store
+	count, err := Migrate(ctx, c, c, target)
+	assert.NotNil(t, err)
+	assert.Equal(t, 0, count)
+	assert.Nil(t, c.Get(ctx, types.NamespacedName{Namespace: "ns", Name: "pr"}, &v1.ConfigMap{}))
+}
@@ -17,8 +17,11 @@ limitations under the License.
 package store
 
 import (
+	"context"
 	"fmt"
+
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 )
 
 const (
@@ -28,6 +31,12 @@ const (
 	DataKeyStage = "stage"
 	// DataKeyStatus is the key of status
 	DataKeyStatus = "status"
+	// DataKeyTestReport is the key of the test report
+	DataKeyTestReport = "test-report"
+	// DataKeyTestReportCollected is the key which indicates the test report was collected from the executor
+	DataKeyTestReportCollected = "test-report-collected"
+	// DataKeyLogCollectedTime is the key of the last time when the logs were collected from the executor
+	DataKeyLogCollectedTime = "log-collected-time"
 )
 
 // StepLogKey generates a unique key by stage and step number
@@ -35,6 +44,11 @@ func StepLogKey(stage, step int) string {
 	return fmt.Sprintf("log-step-%d-%d", stage, step)
 }
 
+// StepLogCompletedKey generates a key which indicates the step log was completely stored
+func StepLogCompletedKey(stage, step int) string {
+	return StepLogKey(stage, step) + "-completed"
+}
+
 // KeyValueStore represents a key-value store
 type KeyValueStore interface {
 	Get(key string) string
@@ -54,10 +68,25 @@ type PipelineRunDataStore interface {
 	SetStepLog(stage, step int, log string)
 	GetAllLog() string
 	SetAllLog(log string)
+	GetTestReport() string
+	SetTestReport(report string)
 }
 
-// ConfigMapStore represents a store base on a ConfigMap
-type ConfigMapStore interface {
+// OwnedStore represents a PipelineRun data store which belongs to an owner
+type OwnedStore interface {
 	PipelineRunDataStore
 	SetOwnerReference(owner metav1.OwnerReference)
 }
+
+// ConfigMapStore represents a store base on a ConfigMap
+type ConfigMapStore interface {
+	OwnedStore
+}
+
+// Provider provides the data stores of PipelineRuns from a storage backend
+type Provider interface {
+	// Get returns the data store of a PipelineRun, it is empty if there is no data of the PipelineRun
+	Get(ctx context.Context, key types.NamespacedName) (OwnedStore, error)
+	// Delete removes all the data of a PipelineRun
+	Delete(ctx context.Context, key types.NamespacedName) error
+}
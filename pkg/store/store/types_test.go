@@ -43,3 +43,9 @@ func TestStepLogKey(t *testing.T) {
 		})
 	}
 }
+
+func TestStepLogCompletedKey(t *testing.T) {
+	if got := StepLogCompletedKey(1, 2); got != "log-step-1-2-completed" {
+		t.Errorf("StepLogCompletedKey() = %v, want %v", got, "log-step-1-2-completed")
+	}
+}
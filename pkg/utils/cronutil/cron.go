@@ -0,0 +1,188 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cronutil
+
+import (
+	"fmt"
+	"hash/fnv"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// aliases are the Jenkins descriptors, they are hashed to spread the load
+var aliases = map[string]string{
+	"@yearly":   "H H H H *",
+	"@annually": "H H H H *",
+	"@monthly":  "H H H * *",
+	"@weekly":   "H H * * H",
+	"@daily":    "H H * * *",
+	"@midnight": "H H(0-2) * * *",
+	"@hourly":   "H * * * *",
+}
+
+// hashRanges are the ranges of the H symbol in each field, the day of month is limited to 28 like Jenkins does
+var hashRanges = [5][2]int{{0, 59}, {0, 23}, {1, 28}, {1, 12}, {0, 6}}
+
+var hashPattern = regexp.MustCompile(`^H(\((\d+)-(\d+)\))?(/(\d+))?$`)
+
+var parser = cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow)
+
+// Schedule is a union of the schedules, each line of a Jenkins cron is a schedule
+type Schedule []cron.Schedule
+
+// Next returns the earliest next time of the schedules, it's zero if there is no next time
+func (s Schedule) Next(t time.Time) (next time.Time) {
+	for _, schedule := range s {
+		if item := schedule.Next(t); !item.IsZero() && (next.IsZero() || item.Before(next)) {
+			next = item
+		}
+	}
+	return
+}
+
+// Prev returns the latest time of the schedule which is not after the given time, it's zero if there is no such
+// time in the last five years
+func (s Schedule) Prev(t time.Time) time.Time {
+	for _, window := range []time.Duration{time.Hour, 24 * time.Hour, 32 * 24 * time.Hour, 366 * 24 * time.Hour, 5 * 366 * 24 * time.Hour} {
+		var prev time.Time
+		for next := s.Next(t.Add(-window)); !next.IsZero() && !next.After(t); next = s.Next(next) {
+			prev = next
+		}
+		if !prev.IsZero() {
+			return prev
+		}
+	}
+	return time.Time{}
+}
+
+// Parse parses a cron in the Jenkins syntax. The comments and the empty lines are ignored, a line like TZ=Asia/Shanghai
+// overrides the given time zone. The H symbols are replaced by the values hashed from the key, so the schedules of
+// different Pipelines are spread out, they are replaced by the lowest values if the key is empty.
+func Parse(spec, timeZone, key string) (schedule Schedule, err error) {
+	var location *time.Location
+	if location, err = time.LoadLocation(timeZone); err != nil {
+		err = fmt.Errorf("invalid time zone %q: %v", timeZone, err)
+		return
+	}
+
+	for _, line := range strings.Split(spec, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, "TZ=") {
+			zone := strings.TrimPrefix(line, "TZ=")
+			if location, err = time.LoadLocation(zone); err != nil {
+				err = fmt.Errorf("invalid time zone %q: %v", zone, err)
+				return
+			}
+			continue
+		}
+
+		var expanded string
+		if expanded, err = expandHash(line, key); err != nil {
+			return
+		}
+		var item cron.Schedule
+		if item, err = parser.Parse(expanded); err != nil {
+			err = fmt.Errorf("invalid cron %q: %v", line, err)
+			return
+		}
+		if specSchedule, ok := item.(*cron.SpecSchedule); ok {
+			specSchedule.Location = location
+		}
+		schedule = append(schedule, item)
+	}
+	if len(schedule) == 0 {
+		err = fmt.Errorf("no schedule found in the cron %q", spec)
+	}
+	return
+}
+
+// expandHash replaces the aliases and the H symbols in a line of cron
+func expandHash(line, key string) (string, error) {
+	if strings.HasPrefix(line, "@") {
+		alias, ok := aliases[line]
+		if !ok {
+			return "", fmt.Errorf("unsupported cron descriptor %q", line)
+		}
+		line = alias
+	}
+
+	fields := strings.Fields(line)
+	if len(fields) != len(hashRanges) {
+		return "", fmt.Errorf("invalid cron %q: expected %d fields, found %d", line, len(hashRanges), len(fields))
+	}
+	for i, field := range fields {
+		items := strings.Split(field, ",")
+		for j, item := range items {
+			if !strings.HasPrefix(item, "H") {
+				continue
+			}
+			expanded, err := expandHashItem(item, hashRanges[i][0], hashRanges[i][1], hashOf(key, i))
+			if err != nil {
+				return "", fmt.Errorf("invalid cron %q: %v", line, err)
+			}
+			items[j] = expanded
+		}
+		fields[i] = strings.Join(items, ",")
+	}
+	return strings.Join(fields, " "), nil
+}
+
+// expandHashItem replaces an item like H, H(0-29), H/15 or H(0-29)/10 with a value or a range
+func expandHashItem(item string, low, high int, hash uint32) (string, error) {
+	matches := hashPattern.FindStringSubmatch(item)
+	if matches == nil {
+		return "", fmt.Errorf("unexpected symbol %q", item)
+	}
+	if matches[1] != "" {
+		from, _ := strconv.Atoi(matches[2])
+		to, _ := strconv.Atoi(matches[3])
+		if from < low || to > high || from > to {
+			return "", fmt.Errorf("the range of %q should be in %d-%d", item, low, high)
+		}
+		low, high = from, to
+	}
+
+	if matches[4] == "" {
+		return strconv.Itoa(low + int(hash%uint32(high-low+1))), nil
+	}
+	step, _ := strconv.Atoi(matches[5])
+	if step <= 0 {
+		return "", fmt.Errorf("the step of %q should be positive", item)
+	}
+	span := step
+	if span > high-low+1 {
+		span = high - low + 1
+	}
+	return fmt.Sprintf("%d-%d/%d", low+int(hash%uint32(span)), high, step), nil
+}
+
+// hashOf returns the hash of a key for a field, it's zero if the key is empty
+func hashOf(key string, field int) uint32 {
+	if key == "" {
+		return 0
+	}
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(fmt.Sprintf("%s#%d", key, field)))
+	return h.Sum32()
+}
@@ -0,0 +1,123 @@
+/*
+Copyright 2022 The KubeSphere Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cronutil
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	now := time.Date(2022, 1, 1, 10, 30, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		spec     string
+		timeZone string
+		key      string
+		wantErr  bool
+		wantPrev time.Time
+		wantNext time.Time
+	}{{
+		name:     "standard cron",
+		spec:     "0 12 * * *",
+		wantPrev: time.Date(2021, 12, 31, 12, 0, 0, 0, time.UTC),
+		wantNext: time.Date(2022, 1, 1, 12, 0, 0, 0, time.UTC),
+	}, {
+		name:     "with time zone",
+		spec:     "0 12 * * *",
+		timeZone: "Asia/Shanghai",
+		wantPrev: time.Date(2022, 1, 1, 4, 0, 0, 0, time.UTC),
+		wantNext: time.Date(2022, 1, 2, 4, 0, 0, 0, time.UTC),
+	}, {
+		name:     "the time zone line takes precedence",
+		spec:     "TZ=Asia/Shanghai\n0 12 * * *",
+		timeZone: "America/New_York",
+		wantPrev: time.Date(2022, 1, 1, 4, 0, 0, 0, time.UTC),
+		wantNext: time.Date(2022, 1, 2, 4, 0, 0, 0, time.UTC),
+	}, {
+		name:     "multiple lines with comments",
+		spec:     "# nightly\n0 12 * * *\n\n45 10 * * *",
+		wantPrev: time.Date(2021, 12, 31, 12, 0, 0, 0, time.UTC),
+		wantNext: time.Date(2022, 1, 1, 10, 45, 0, 0, time.UTC),
+	}, {
+		name:     "the H symbol without a key",
+		spec:     "H/15 H(10-12) * * *",
+		wantPrev: time.Date(2022, 1, 1, 10, 30, 0, 0, time.UTC),
+		wantNext: time.Date(2022, 1, 1, 10, 45, 0, 0, time.UTC),
+	}, {
+		name:     "the alias without a key",
+		spec:     "@midnight",
+		wantPrev: time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC),
+		wantNext: time.Date(2022, 1, 2, 0, 0, 0, 0, time.UTC),
+	}, {
+		name:     "invalid time zone",
+		spec:     "0 12 * * *",
+		timeZone: "Mars/Olympus",
+		wantErr:  true,
+	}, {
+		name:    "invalid cron",
+		spec:    "0 25 * * *",
+		wantErr: true,
+	}, {
+		name:    "invalid fields",
+		spec:    "0 12 * *",
+		wantErr: true,
+	}, {
+		name:    "invalid hash range",
+		spec:    "H(50-70) * * * *",
+		wantErr: true,
+	}, {
+		name:    "unsupported descriptor",
+		spec:    "@every 1h",
+		wantErr: true,
+	}, {
+		name:    "only comments",
+		spec:    "# nothing",
+		wantErr: true,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schedule, err := Parse(tt.spec, tt.timeZone, tt.key)
+			if tt.wantErr {
+				assert.NotNil(t, err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.True(t, tt.wantPrev.Equal(schedule.Prev(now)), "prev: %v", schedule.Prev(now))
+			assert.True(t, tt.wantNext.Equal(schedule.Next(now)), "next: %v", schedule.Next(now))
+		})
+	}
+}
+
+func TestParse_hash(t *testing.T) {
+	first, err := Parse("H H * * *", "", "ns/pipeline-a")
+	assert.Nil(t, err)
+	again, err := Parse("H H * * *", "", "ns/pipeline-a")
+	assert.Nil(t, err)
+	now := time.Now()
+	assert.Equal(t, first.Next(now), again.Next(now))
+
+	for i := 0; i < 10; i++ {
+		schedule, err := Parse("H(0-29)/10 * * * *", "", string(rune('a'+i)))
+		assert.Nil(t, err)
+		next := schedule.Next(now)
+		assert.True(t, next.Minute() < 30)
+		assert.Equal(t, next.Minute()%10, schedule.Next(next).Minute()%10)
+	}
+}